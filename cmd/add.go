@@ -9,6 +9,9 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/gitlink"
+	"github.com/zate/ctx/internal/ingest"
+	"github.com/zate/ctx/internal/related"
 )
 
 var addCmd = &cobra.Command{
@@ -71,22 +74,48 @@ func runAdd(cmd *cobra.Command, args []string) error {
 		addTags = append(addTags, at)
 	}
 
-	node, err := d.CreateNode(db.CreateNodeInput{
+	input := db.CreateNodeInput{
 		Type:     addType,
 		Content:  content,
 		Metadata: metadata,
 		Tags:     addTags,
-	})
+	}
+	commit, err := gitlink.Stamp(&input, settings.GitLink)
 	if err != nil {
 		return err
 	}
 
+	node, chunks, err := ingest.CreateNode(d, input, ingest.MaxNodeTokens())
+	if err != nil {
+		return err
+	}
+
+	var linked []string
+	if settings.AutoLink {
+		if linked, err = related.LinkRefs(d, node.ID, content); err != nil {
+			return fmt.Errorf("failed to link mentioned nodes: %w", err)
+		}
+	}
+
+	if _, err := gitlink.Link(d, node.ID, commit); err != nil {
+		return err
+	}
+
 	switch format {
 	case "json":
 		data, _ := json.MarshalIndent(node, "", "  ")
 		fmt.Println(string(data))
 	default:
 		fmt.Printf("Added: %s\n", node.ID)
+		if chunks > 0 {
+			fmt.Printf("Content exceeded %d tokens: split into %d CHILD_OF chunks\n", ingest.MaxNodeTokens(), chunks)
+		}
+		if len(linked) > 0 {
+			fmt.Printf("Linked %s: %s\n", related.EdgeType, strings.Join(linked, ", "))
+		}
+		if commit != nil {
+			fmt.Printf("Linked to commit %s\n", commit)
+		}
 	}
 
 	return nil
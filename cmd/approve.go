@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/approval"
+	"github.com/zate/ctx/internal/db"
+)
+
+var (
+	approveAll  bool
+	approveDeny bool
+)
+
+var approveCmd = &cobra.Command{
+	Use:   "approve [op-id...]",
+	Short: "Approve destructive operations staged by MCP clients",
+	Long: `Approve (or with --deny, discard) operations that MCP tools staged while
+approval mode was on. Without arguments, lists the staged operations.
+
+Enable approval mode with "approval_mode: true" in ~/.ctx/mcp.yaml or
+CTX_MCP_APPROVAL=true. Tools listed under "auto_approve" bypass staging.`,
+	RunE: runApprove,
+}
+
+func init() {
+	approveCmd.Flags().BoolVar(&approveAll, "all", false, "Act on every staged operation")
+	approveCmd.Flags().BoolVar(&approveDeny, "deny", false, "Discard the operations instead of applying them")
+	rootCmd.AddCommand(approveCmd)
+}
+
+func runApprove(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if len(args) == 0 && !approveAll {
+		return printStagedOps(d)
+	}
+
+	var ops []*approval.Op
+	if approveAll {
+		ops, err = approval.List(d)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, arg := range args {
+			op, err := approval.Get(d, arg)
+			if err != nil {
+				return err
+			}
+			ops = append(ops, op)
+		}
+	}
+
+	for _, op := range ops {
+		if approveDeny {
+			if err := approval.Discard(d, op); err != nil {
+				return err
+			}
+			fmt.Printf("Denied %s: %s\n", op.ID, op.Describe())
+			continue
+		}
+		result, err := approval.Approve(d, op)
+		if err != nil {
+			return fmt.Errorf("operation %s failed: %w", op.ID, err)
+		}
+		fmt.Printf("Approved %s: %s\n", op.ID, result)
+	}
+	return nil
+}
+
+func printStagedOps(d db.Store) error {
+	ops, err := approval.List(d)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(ops, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(ops) == 0 {
+			fmt.Println("No staged operations.")
+			return nil
+		}
+		clock := settings.Clock()
+		for _, op := range ops {
+			fmt.Printf("[%s] %s (staged %s)\n", op.ID, op.Describe(), clock.Format(op.CreatedAt))
+		}
+		fmt.Println("\nApprove with: ctx approve <op-id>  |  discard with: ctx approve --deny <op-id>")
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/validate"
+)
+
+var (
+	attachName      string
+	attachMediaType string
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <id> <file>",
+	Short: "Keep a file with a node",
+	Long: `Store a file such as a screenshot, a PDF or the original source of a
+fact with a node. The file is copied into the database, up to 32 MiB, and
+deleted with the node. Its media type is guessed from the name and content
+unless --type is given.
+
+List a node's attachments with ctx attachments <id> and save one with
+ctx attachments get <attachment-id>.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runAttach,
+}
+
+var attachmentsCmd = &cobra.Command{
+	Use:   "attachments <id>",
+	Short: "List the files attached to a node",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAttachments,
+}
+
+var attachmentsGetCmd = &cobra.Command{
+	Use:   "get <attachment-id> [file]",
+	Short: "Save an attachment to a file",
+	Long: `Save an attachment to file, by default under its own name in the current
+directory. Use - to write it to stdout. An existing file is not
+overwritten.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runAttachmentsGet,
+}
+
+var attachmentsRmCmd = &cobra.Command{
+	Use:   "rm <attachment-id>",
+	Short: "Delete an attachment",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAttachmentsRm,
+}
+
+func init() {
+	attachCmd.Flags().StringVar(&attachName, "name", "", "Name to store the file under (default: the file's base name)")
+	attachCmd.Flags().StringVar(&attachMediaType, "type", "", "Media type, e.g. image/png (default: guessed)")
+	attachmentsCmd.AddCommand(attachmentsGetCmd, attachmentsRmCmd)
+	rootCmd.AddCommand(attachCmd, attachmentsCmd)
+}
+
+func runAttach(cmd *cobra.Command, args []string) error {
+	info, err := os.Stat(args[1])
+	if err != nil {
+		return err
+	}
+	if info.Size() > db.MaxAttachmentSize {
+		return fmt.Errorf("%s: %w", args[1], db.ErrAttachmentTooLarge)
+	}
+	data, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	nodeID, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	name := attachName
+	if name == "" {
+		name = filepath.Base(args[1])
+	}
+	a, err := d.AddAttachment(nodeID, name, attachMediaType, data)
+	if err != nil {
+		return fmt.Errorf("failed to attach %s: %w", args[1], err)
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(a, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Attached: %s to %s as %s (%s, %s)\n", a.Name, nodeID[:8], a.ID, a.MediaType, formatSize(a.Size))
+	}
+	return nil
+}
+
+func runAttachments(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	nodeID, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	attachments, err := d.ListAttachments(nodeID)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if attachments == nil {
+			attachments = []*db.Attachment{}
+		}
+		data, _ := json.MarshalIndent(attachments, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(attachments) == 0 {
+			fmt.Println("No attachments.")
+			return nil
+		}
+		printAttachments(attachments, "")
+	}
+	return nil
+}
+
+// printAttachments lists attachments one per line, each preceded by indent.
+func printAttachments(attachments []*db.Attachment, indent string) {
+	for _, a := range attachments {
+		fmt.Printf("%s%s  %s  %s, %s\n", indent, a.ID, a.Name, a.MediaType, formatSize(a.Size))
+	}
+}
+
+func runAttachmentsGet(cmd *cobra.Command, args []string) error {
+	if err := validate.ID(args[0]); err != nil {
+		return err
+	}
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	a, data, err := d.GetAttachment(args[0])
+	if err != nil {
+		return fmt.Errorf("attachment %s: %w", args[0], err)
+	}
+
+	path := a.Name
+	if len(args) == 2 {
+		path = args[1]
+	}
+	if path == "-" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Saved: %s (%s)\n", path, formatSize(a.Size))
+	return nil
+}
+
+func runAttachmentsRm(cmd *cobra.Command, args []string) error {
+	if err := validate.ID(args[0]); err != nil {
+		return err
+	}
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.DeleteAttachment(args[0]); err != nil {
+		return fmt.Errorf("attachment %s: %w", args[0], err)
+	}
+	fmt.Printf("Deleted attachment: %s\n", args[0])
+	return nil
+}
+
+// formatSize renders a byte count in B, KiB or MiB.
+func formatSize(n int64) string {
+	switch {
+	case n < 1<<10:
+		return fmt.Sprintf("%d B", n)
+	case n < 1<<20:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+}
@@ -19,8 +19,10 @@ type authConfig struct {
 	Token        string `json:"token"`
 	RefreshToken string `json:"refresh_token"`
 	DeviceID     string `json:"device_id"`
+	DeviceName   string `json:"device_name,omitempty"`
 	ServerURL    string `json:"server_url"`
 	UpdatedAt    string `json:"updated_at"`
+	ExpiresAt    string `json:"expires_at,omitempty"`
 }
 
 var authDeviceName string
@@ -37,6 +39,19 @@ var authStatusCmd = &cobra.Command{
 	RunE:  runAuthStatus,
 }
 
+var authWhoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Verify the stored token with the server and show this device's record",
+	RunE:  runAuthWhoami,
+}
+
+var authRenameDeviceCmd = &cobra.Command{
+	Use:   "rename-device <name>",
+	Short: "Rename this device on the server",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runAuthRenameDevice,
+}
+
 var authLogoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Remove stored authentication credentials",
@@ -50,6 +65,8 @@ func init() {
 	}
 	authCmd.Flags().StringVar(&authDeviceName, "device-name", hostname, "Name for this device")
 	authCmd.AddCommand(authStatusCmd)
+	authCmd.AddCommand(authWhoamiCmd)
+	authCmd.AddCommand(authRenameDeviceCmd)
 	authCmd.AddCommand(authLogoutCmd)
 	rootCmd.AddCommand(authCmd)
 }
@@ -125,18 +142,24 @@ func runAuth(cmd *cobra.Command, args []string) error {
 			var tokenData struct {
 				AccessToken  string `json:"access_token"`
 				RefreshToken string `json:"refresh_token"`
+				ExpiresIn    int    `json:"expires_in"`
 				DeviceID     string `json:"device_id"`
 			}
 			if err := json.Unmarshal(respBytes, &tokenData); err != nil {
 				return fmt.Errorf("failed to parse token response: %w", err)
 			}
 
+			now := time.Now().UTC()
 			cfg := authConfig{
 				Token:        tokenData.AccessToken,
 				RefreshToken: tokenData.RefreshToken,
 				DeviceID:     tokenData.DeviceID,
+				DeviceName:   authDeviceName,
 				ServerURL:    remoteCfg.URL,
-				UpdatedAt:    time.Now().UTC().Format(time.RFC3339),
+				UpdatedAt:    now.Format(time.RFC3339),
+			}
+			if tokenData.ExpiresIn > 0 {
+				cfg.ExpiresAt = now.Add(time.Duration(tokenData.ExpiresIn) * time.Second).Format(time.RFC3339)
 			}
 
 			if err := saveAuthConfig(&cfg); err != nil {
@@ -161,7 +184,115 @@ func runAuthStatus(cmd *cobra.Command, args []string) error {
 	}
 	fmt.Printf("Server:    %s\n", cfg.ServerURL)
 	fmt.Printf("Device ID: %s\n", cfg.DeviceID)
+	if cfg.DeviceName != "" {
+		fmt.Printf("Device:    %s\n", cfg.DeviceName)
+	}
 	fmt.Printf("Updated:   %s\n", cfg.UpdatedAt)
+	fmt.Printf("Expires:   %s\n", describeExpiry(cfg.ExpiresAt, time.Now()))
+	return nil
+}
+
+// describeExpiry renders a token expiry as a date and countdown, e.g.
+// "2025-02-01 12:00 (in 29d 4h)".
+func describeExpiry(expiresAt string, now time.Time) string {
+	t, err := time.Parse(time.RFC3339, expiresAt)
+	if err != nil {
+		return "unknown (re-run 'ctx auth' to record it)"
+	}
+	stamp := settings.Clock().Stamp(t)
+	left := t.Sub(now)
+	if left <= 0 {
+		return fmt.Sprintf("%s (expired %s ago; run 'ctx auth')", stamp, formatCountdown(-left))
+	}
+	return fmt.Sprintf("%s (in %s)", stamp, formatCountdown(left))
+}
+
+func formatCountdown(d time.Duration) string {
+	days := int(d.Hours()) / 24
+	hours := int(d.Hours()) % 24
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+func runAuthWhoami(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAuthConfig()
+	if err != nil {
+		return fmt.Errorf("not authenticated. Run 'ctx auth' first")
+	}
+
+	resp, err := authedRequest("GET", cfg.ServerURL+"/api/auth/whoami", nil, cfg.Token)
+	if err != nil {
+		return fmt.Errorf("cannot reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return fmt.Errorf("token rejected by %s. Run 'ctx auth' to re-authenticate", cfg.ServerURL)
+	case http.StatusForbidden:
+		return fmt.Errorf("this device has been revoked on %s", cfg.ServerURL)
+	default:
+		return fmt.Errorf("server error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	if format == "json" {
+		fmt.Println(string(body))
+		return nil
+	}
+
+	var device struct {
+		ID        string `json:"id"`
+		Name      string `json:"name"`
+		LastSeen  string `json:"last_seen"`
+		LastIP    string `json:"last_ip"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := json.Unmarshal(body, &device); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	fmt.Printf("Server:     %s\n", cfg.ServerURL)
+	fmt.Printf("Device ID:  %s\n", device.ID)
+	fmt.Printf("Name:       %s\n", device.Name)
+	fmt.Printf("Authorized: %s\n", device.CreatedAt)
+	fmt.Printf("Last seen:  %s\n", orNA(device.LastSeen))
+	if device.LastIP != "" {
+		fmt.Printf("Last IP:    %s\n", device.LastIP)
+	}
+	fmt.Printf("Expires:    %s\n", describeExpiry(cfg.ExpiresAt, time.Now()))
+	return nil
+}
+
+func runAuthRenameDevice(cmd *cobra.Command, args []string) error {
+	cfg, err := loadAuthConfig()
+	if err != nil {
+		return fmt.Errorf("not authenticated. Run 'ctx auth' first")
+	}
+
+	resp, err := authedRequest("POST", cfg.ServerURL+"/api/devices/"+cfg.DeviceID+"/rename",
+		map[string]string{"name": args[0]}, cfg.Token)
+	if err != nil {
+		return fmt.Errorf("cannot reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("server error (%d): %s", resp.StatusCode, string(body))
+	}
+
+	cfg.DeviceName = args[0]
+	if err := saveAuthConfig(cfg); err != nil {
+		return fmt.Errorf("failed to save credentials: %w", err)
+	}
+	fmt.Printf("Device %s renamed to %s\n", cfg.DeviceID, args[0])
 	return nil
 }
 
@@ -178,11 +309,11 @@ func runAuthLogout(cmd *cobra.Command, args []string) error {
 }
 
 func authConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+	dir := settings.StateDir()
+	if dir == "" {
+		return "", fmt.Errorf("cannot determine home directory")
 	}
-	return filepath.Join(home, ".ctx", "auth.json"), nil
+	return filepath.Join(dir, "auth.json"), nil
 }
 
 func loadAuthConfig() (*authConfig, error) {
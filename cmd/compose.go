@@ -1,13 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
-	"strconv"
+	"os/exec"
+	"runtime"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/queue"
+	ctxsync "github.com/zate/ctx/internal/sync"
+	"github.com/zate/ctx/internal/usage"
 	"github.com/zate/ctx/internal/view"
 )
 
@@ -20,21 +25,32 @@ var (
 	composeSeed     string
 	composeDepth    int
 	composeProject  string
+	composeOutput   string
+	composeCopy     bool
+	composeAppendTo string
 )
 
 var composeCmd = &cobra.Command{
 	Use:   "compose",
 	Short: "Compose context from query or node IDs",
-	RunE:  runCompose,
+	Long: `Compose context from a query, node IDs or a seed node.
+
+Output goes to stdout unless --output, --copy or --append-to is given. For
+tools without hooks, --append-to CLAUDE.md keeps composed memory (as
+markdown, unless --template is set) between ` + composeBeginMarker + ` and
+` + composeEndMarker + ` markers in the file, replacing that section on
+each run and leaving the rest of the file alone.`,
+	RunE: runCompose,
 }
 
+// Markers around the section --append-to maintains.
+const (
+	composeBeginMarker = "<!-- ctx:memory:begin -->"
+	composeEndMarker   = "<!-- ctx:memory:end -->"
+)
+
 func init() {
-	defaultBudget := 50000
-	if envBudget := os.Getenv("CTX_DEFAULT_BUDGET"); envBudget != "" {
-		if n, err := strconv.Atoi(envBudget); err == nil {
-			defaultBudget = n
-		}
-	}
+	defaultBudget := settings.DefaultBudget
 	composeCmd.Flags().StringVar(&composeQuery, "query", "", "Query expression")
 	composeCmd.Flags().IntVar(&composeBudget, "budget", defaultBudget, "Token budget")
 	composeCmd.Flags().StringVar(&composeIDs, "ids", "", "Comma-separated node IDs to compose (supports short prefixes)")
@@ -43,6 +59,10 @@ func init() {
 	composeCmd.Flags().StringVar(&composeSeed, "seed", "", "Seed node ID for graph traversal")
 	composeCmd.Flags().IntVar(&composeDepth, "depth", 1, "Traversal depth for seed mode")
 	composeCmd.Flags().StringVar(&composeProject, "project", "", "Project scope for filtering")
+	composeCmd.Flags().StringVar(&composeOutput, "output", "", "Write to this file instead of stdout")
+	composeCmd.Flags().BoolVar(&composeCopy, "copy", false, "Copy to the clipboard instead of printing")
+	composeCmd.Flags().StringVar(&composeAppendTo, "append-to", "", "Refresh the ctx section of this file (e.g. CLAUDE.md)")
+	addTimeoutFlag(composeCmd)
 	rootCmd.AddCommand(composeCmd)
 }
 
@@ -61,6 +81,7 @@ func runCompose(cmd *cobra.Command, args []string) error {
 		Depth:        composeDepth,
 		Agent:        agent,
 		Project:      composeProject,
+		Translate:    view.TranslationFor(settings),
 	}
 
 	if composeIDs != "" {
@@ -71,26 +92,111 @@ func runCompose(cmd *cobra.Command, args []string) error {
 		opts.IDs = ids
 	}
 
-	result, err := view.Compose(d, opts)
+	ctx, cancel := queryContext(cmd)
+	defer cancel()
+	result, err := view.ComposeContext(ctx, d, opts)
 	if err != nil {
 		return err
 	}
-
-	// If a template is specified, use template rendering
-	if composeTemplate != "" {
-		fmt.Print(view.RenderTemplate(result, composeTemplate))
-		return nil
+	_ = usage.RecordAccess(d, result.Nodes...)
+	div := ctxsync.CheckDivergence(d, configuredServerURL())
+	result.Unsynced, result.SyncConflicts = div.Unsynced, div.Conflicts
+	if jobs, err := queue.List(d); err == nil {
+		result.QueuedJobs = len(jobs)
 	}
 
-	switch format {
-	case "json":
+	var out string
+	switch {
+	case composeTemplate != "":
+		result.Clock = settings.Clock()
+		out = view.RenderTemplate(result, composeTemplate)
+	case composeAppendTo != "" || format == "markdown":
+		out = view.RenderMarkdown(result)
+	case format == "json":
 		data, _ := json.MarshalIndent(result, "", "  ")
-		fmt.Println(string(data))
-	case "markdown":
-		fmt.Print(view.RenderMarkdown(result))
+		out = string(data) + "\n"
 	default:
-		fmt.Print(view.RenderText(result))
+		out = view.RenderText(result)
 	}
 
+	if composeOutput == "" && !composeCopy && composeAppendTo == "" {
+		fmt.Print(out)
+		return nil
+	}
+	if composeOutput != "" {
+		if err := os.WriteFile(composeOutput, []byte(out), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", composeOutput, err)
+		}
+		fmt.Printf("Wrote %d nodes (%d tokens) to %s\n", result.NodeCount, result.TotalTokens, composeOutput)
+	}
+	if composeAppendTo != "" {
+		existing, err := os.ReadFile(composeAppendTo)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", composeAppendTo, err)
+		}
+		updated := replaceComposeSection(string(existing), out)
+		if err := os.WriteFile(composeAppendTo, []byte(updated), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", composeAppendTo, err)
+		}
+		fmt.Printf("Refreshed the ctx section of %s: %d nodes (%d tokens)\n", composeAppendTo, result.NodeCount, result.TotalTokens)
+	}
+	if composeCopy {
+		if err := copyToClipboard(out); err != nil {
+			return err
+		}
+		fmt.Printf("Copied %d nodes (%d tokens) to the clipboard\n", result.NodeCount, result.TotalTokens)
+	}
 	return nil
 }
+
+// replaceComposeSection swaps the text between the compose markers in doc
+// for body, or appends a marked section when doc has none.
+func replaceComposeSection(doc, body string) string {
+	section := composeBeginMarker + "\n" + strings.TrimRight(body, "\n") + "\n" + composeEndMarker
+	start := strings.Index(doc, composeBeginMarker)
+	if start >= 0 {
+		if end := strings.Index(doc[start:], composeEndMarker); end >= 0 {
+			return doc[:start] + section + doc[start+end+len(composeEndMarker):]
+		}
+	}
+	switch {
+	case doc == "":
+		return section + "\n"
+	case strings.HasSuffix(doc, "\n\n"):
+	case strings.HasSuffix(doc, "\n"):
+		doc += "\n"
+	default:
+		doc += "\n\n"
+	}
+	return doc + section + "\n"
+}
+
+// copyToClipboard puts text on the system clipboard using the platform's
+// clipboard tool.
+func copyToClipboard(text string) error {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip"}}
+	default:
+		candidates = [][]string{
+			{"wl-copy"},
+			{"xclip", "-selection", "clipboard"},
+			{"xsel", "--clipboard", "--input"},
+		}
+	}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(c[0], c[1:]...)
+		cmd.Stdin = bytes.NewBufferString(text)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to copy with %s: %w", c[0], err)
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard tool found (install wl-copy, xclip or xsel)")
+}
@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplaceComposeSection(t *testing.T) {
+	section := func(body string) string {
+		return composeBeginMarker + "\n" + body + "\n" + composeEndMarker
+	}
+
+	// A new file is just the section
+	assert.Equal(t, section("memory")+"\n", replaceComposeSection("", "memory\n"))
+
+	// Without markers the section is appended after a blank line
+	assert.Equal(t, "# Project\n\n"+section("memory")+"\n", replaceComposeSection("# Project", "memory"))
+	assert.Equal(t, "# Project\n\n"+section("memory")+"\n", replaceComposeSection("# Project\n", "memory"))
+
+	// An existing section is replaced in place
+	doc := "# Project\n\n" + section("old") + "\n\n## Notes\nkeep me\n"
+	assert.Equal(t, "# Project\n\n"+section("new")+"\n\n## Notes\nkeep me\n", replaceComposeSection(doc, "new\n"))
+}
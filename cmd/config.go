@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change settings in ~/.ctx/config.yaml",
+	Long: `Show and change ctx settings.
+
+Settings live in ~/.ctx/config.yaml (or the file named by CTX_CONFIG).
+Environment variables override the file, and command-line flags override
+both. Nested keys use dots, e.g. hooks.primer_file.`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting with its effective value",
+	RunE:  runConfigList,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value...>",
+	Short: "Write a setting to the config file",
+	Long: `Write a setting to the config file, keeping other settings and comments.
+
+List settings (redact, tiers.inject) take one argument per item:
+
+  ctx config set tiers.inject pinned working reference`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runConfigSet,
+}
+
+var configProfilesCmd = &cobra.Command{
+	Use:   "profiles",
+	Short: "List configured profiles, marking the active one",
+	Long: `List the profiles defined under the profiles key of the config file.
+
+A profile bundles db, backend, agent and remote settings and keeps its own
+credentials and sync state, so switching never needs a re-auth:
+
+  ctx config set profiles.work.db postgres://ctx@db.internal/ctx
+  ctx config set profiles.work.remote https://ctx.example.com
+  ctx --profile work auth
+  CTX_PROFILE=work ctx compose`,
+	RunE: runConfigProfiles,
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd, configGetCmd, configSetCmd, configProfilesCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigList(cmd *cobra.Command, args []string) error {
+	if err := config.CheckFile(); err != nil {
+		return err
+	}
+	cfg := config.Load()
+
+	switch format {
+	case "json":
+		values := make(map[string]string)
+		for _, key := range config.Keys() {
+			values[key], _ = cfg.Get(key)
+		}
+		data, _ := json.MarshalIndent(values, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("# %s\n", config.Path())
+		for _, key := range config.Keys() {
+			value, _ := cfg.Get(key)
+			fmt.Printf("%-24s %-32s # %s\n", key, value, config.Describe(key))
+		}
+	}
+	return nil
+}
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	value, err := config.Load().Get(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	key, values := args[0], args[1:]
+	path := config.Path()
+	if path == "" {
+		return fmt.Errorf("cannot determine config file path")
+	}
+	if err := config.Save(path, key, values...); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s in %s\n", key, path)
+	return nil
+}
+
+func runConfigProfiles(cmd *cobra.Command, args []string) error {
+	names := settings.ProfileNames()
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(map[string]any{
+			"active":   settings.Profile,
+			"profiles": settings.Profiles,
+		}, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(names) == 0 {
+			fmt.Println("No profiles configured. Use 'ctx config set profiles.<name>.db <path>' to add one.")
+			return nil
+		}
+		for _, name := range names {
+			marker := " "
+			if name == settings.Profile {
+				marker = "*"
+			}
+			p := settings.Profiles[name]
+			fmt.Printf("%s %-12s db=%s remote=%s\n", marker, name, p.DB, p.Remote)
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -86,3 +87,12 @@ func TestOrNA(t *testing.T) {
 	assert.Equal(t, "never", orNA(""))
 	assert.Equal(t, "2025-01-01", orNA("2025-01-01"))
 }
+
+func TestDescribeExpiry(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.Contains(t, describeExpiry("2025-01-31T16:00:00Z", now), "(in 30d 4h)")
+	assert.Contains(t, describeExpiry("2025-01-01T14:30:00Z", now), "(in 2h 30m)")
+	assert.Contains(t, describeExpiry("2024-12-30T12:00:00Z", now), "expired 2d 0h ago")
+	assert.Contains(t, describeExpiry("", now), "unknown")
+}
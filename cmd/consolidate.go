@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/consolidate"
+	"github.com/zate/ctx/internal/llm"
+	"github.com/zate/ctx/internal/token"
+)
+
+var (
+	consolidateProject   string
+	consolidateMinSize   int
+	consolidateMaxSize   int
+	consolidateOlderThan time.Duration
+	consolidateDryRun    bool
+	consolidateYes       bool
+)
+
+var consolidateCmd = &cobra.Command{
+	Use:   "consolidate",
+	Short: "Merge clusters of related nodes into LLM-written summaries",
+	Long: `Group a project's working and reference nodes into clusters of related
+knowledge (joined by edges or a shared topic tag), ask the configured LLM
+to write one summary per cluster, and apply each summary you confirm.
+
+Applying creates a tier:reference summary node DERIVED_FROM the cluster
+and moves the cluster's nodes to tier:off-context, so the project costs
+fewer tokens to load while the originals stay queryable.
+
+The LLM is any command that reads a prompt on stdin and prints the
+reply, set with: ctx config set llm.command 'claude -p'`,
+	RunE: runConsolidate,
+}
+
+func init() {
+	consolidateCmd.Flags().StringVar(&consolidateProject, "project", "", "Project to consolidate (required)")
+	_ = consolidateCmd.MarkFlagRequired("project")
+	consolidateCmd.Flags().IntVar(&consolidateMinSize, "min-size", consolidate.DefaultMinSize, "Smallest cluster worth merging")
+	consolidateCmd.Flags().IntVar(&consolidateMaxSize, "max-size", consolidate.DefaultMaxSize, "Split larger clusters")
+	consolidateCmd.Flags().DurationVar(&consolidateOlderThan, "older-than", 0, "Only consider nodes not updated for this long (e.g. 720h)")
+	consolidateCmd.Flags().BoolVar(&consolidateDryRun, "dry-run", false, "List clusters without calling the LLM")
+	consolidateCmd.Flags().BoolVarP(&consolidateYes, "yes", "y", false, "Apply every proposed summary without asking")
+	rootCmd.AddCommand(consolidateCmd)
+}
+
+// consolidateProposal is one cluster and its proposed summary, as printed
+// with --format json.
+type consolidateProposal struct {
+	Nodes         []string `json:"nodes"`
+	Tags          []string `json:"tags,omitempty"`
+	Tokens        int      `json:"tokens"`
+	Summary       string   `json:"summary,omitempty"`
+	SummaryTokens int      `json:"summary_tokens,omitempty"`
+	Applied       string   `json:"applied,omitempty"` // ID of the summary node
+	Error         string   `json:"error,omitempty"`
+}
+
+func runConsolidate(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	opts := consolidate.Options{
+		Project:   consolidateProject,
+		Agent:     agent,
+		MinSize:   consolidateMinSize,
+		MaxSize:   consolidateMaxSize,
+		OlderThan: consolidateOlderThan,
+	}
+	clusters, err := consolidate.FindClusters(d, opts)
+	if err != nil {
+		return err
+	}
+
+	asJSON := format == "json"
+	if len(clusters) == 0 {
+		if asJSON {
+			fmt.Println("[]")
+		} else {
+			fmt.Printf("No clusters of %d or more related nodes in project %s.\n", consolidateMinSize, consolidateProject)
+		}
+		return nil
+	}
+
+	command := config.Load().LLM.Command
+	if !consolidateDryRun && strings.TrimSpace(command) == "" {
+		return fmt.Errorf("%w, or use --dry-run", llm.ErrNotConfigured)
+	}
+	// JSON output has no one to ask, so it only proposes unless --yes
+	apply := !consolidateDryRun && (consolidateYes || !asJSON)
+	in := bufio.NewReader(os.Stdin)
+
+	var proposals []consolidateProposal
+	saved := 0
+	for i, c := range clusters {
+		p := consolidateProposal{Tags: c.Tags, Tokens: c.Tokens}
+		for _, n := range c.Nodes {
+			p.Nodes = append(p.Nodes, n.ID)
+		}
+		if !asJSON {
+			fmt.Printf("Cluster %d/%d: %d nodes, %d tokens", i+1, len(clusters), len(c.Nodes), c.Tokens)
+			if len(c.Tags) > 0 {
+				fmt.Printf(" (%s)", strings.Join(c.Tags, ", "))
+			}
+			fmt.Println()
+			for _, n := range c.Nodes {
+				preview := strings.ReplaceAll(n.Content, "\n", " ")
+				if len(preview) > 80 {
+					preview = preview[:80] + "..."
+				}
+				fmt.Printf("  [%s] %s: %s\n", n.ID, n.Type, preview)
+			}
+		}
+		if consolidateDryRun {
+			proposals = append(proposals, p)
+			if !asJSON {
+				fmt.Println()
+			}
+			continue
+		}
+
+		summary, err := llm.Run(cmd.Context(), command, consolidate.Prompt(c))
+		if err != nil {
+			p.Error = err.Error()
+			proposals = append(proposals, p)
+			if !asJSON {
+				fmt.Fprintf(os.Stderr, "  skipped: %v\n\n", err)
+			}
+			continue
+		}
+		p.Summary = summary
+		p.SummaryTokens = token.Estimate(summary)
+
+		if !asJSON {
+			fmt.Printf("\nProposed summary (%d tokens, saves %d):\n%s\n\n", p.SummaryTokens, c.Tokens-p.SummaryTokens, summary)
+		}
+		if apply && !consolidateYes {
+			fmt.Print("Apply this summary? [y/N] ")
+			answer, _ := in.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer != "y" && answer != "yes" {
+				fmt.Print("Skipped.\n\n")
+				proposals = append(proposals, p)
+				continue
+			}
+		}
+		if apply {
+			node, err := consolidate.Apply(d, c, opts, summary)
+			if err != nil {
+				return err
+			}
+			p.Applied = node.ID
+			saved += c.Tokens - p.SummaryTokens
+			if !asJSON {
+				fmt.Printf("Created summary %s; archived %d nodes.\n\n", node.ID, len(c.Nodes))
+			}
+		}
+		proposals = append(proposals, p)
+	}
+
+	if asJSON {
+		data, _ := json.MarshalIndent(proposals, "", "  ")
+		fmt.Println(string(data))
+	} else if apply {
+		fmt.Printf("Saved about %d tokens.\n", saved)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/stats"
+)
+
+var (
+	coverageProject string
+	coverageAreas   []string
+)
+
+var coverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Summarize captured knowledge per area",
+	Long: `Count the decisions, patterns and facts stored for each area, with when
+each area was last updated, to guide deliberate knowledge capture.
+
+Areas come from tags: plain tags like "auth" or area: tags like
+"area:auth". Namespaced tags (tier:, project:, agent:, ...) are not areas.
+Areas mentioned only by observations, sources or other node types, and
+any named with --area, are flagged when they have no knowledge at all.`,
+	RunE: runCoverage,
+}
+
+func init() {
+	coverageCmd.Flags().StringVar(&coverageProject, "project", "", "Only count nodes tagged project:<name>")
+	coverageCmd.Flags().StringSliceVar(&coverageAreas, "area", nil, "Areas to report even when nothing mentions them (comma-separated)")
+	rootCmd.AddCommand(coverageCmd)
+}
+
+func runCoverage(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	report, err := stats.Coverage(d, stats.CoverageOptions{
+		Project: coverageProject,
+		Areas:   coverageAreas,
+		Agent:   agent,
+	})
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if report.Project != "" {
+		fmt.Printf("Coverage for project %s:\n", report.Project)
+	} else {
+		fmt.Println("Coverage:")
+	}
+	if len(report.Areas) == 0 {
+		fmt.Println("  (no tagged areas)")
+		return nil
+	}
+
+	header := fmt.Sprintf("  %-24s", "AREA")
+	for _, t := range stats.CoverageTypes {
+		header += fmt.Sprintf(" %9s", strings.ToUpper(t)+"S")
+	}
+	fmt.Println(header + "  LAST UPDATED")
+	for _, a := range report.Areas {
+		line := fmt.Sprintf("  %-24s", a.Area)
+		for _, t := range stats.CoverageTypes {
+			line += fmt.Sprintf(" %9d", a.Counts[t])
+		}
+		if a.LastUpdated != nil {
+			line += fmt.Sprintf("  %s ago", stats.FormatAge(time.Since(*a.LastUpdated)))
+		} else {
+			line += "  never"
+		}
+		fmt.Println(line)
+	}
+
+	if len(report.Empty) > 0 {
+		fmt.Printf("\nNo knowledge yet (%d): %s\n", len(report.Empty), strings.Join(report.Empty, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/decay"
+	hookpkg "github.com/zate/ctx/internal/hook"
+)
+
+var (
+	decayWorkingAfter   time.Duration
+	decayReferenceAfter time.Duration
+	decayDryRun         bool
+)
+
+var decayCmd = &cobra.Command{
+	Use:   "decay",
+	Short: "Demote working and reference nodes nobody has touched in a while",
+	Long: `Move idle nodes down a tier: tier:working nodes idle for
+decay.working_after (14 days by default) to tier:reference, and
+tier:reference nodes idle for decay.reference_after (180 days) to
+tier:off-context, where they stay queryable but are never loaded. A node is
+idle while it is not updated, used in a session or read by recall, compose
+or show. Nodes pinned to the current task are left alone.
+
+Set either rule to 0 to turn it off, and decay.on_session_start to run
+decay at every session start:
+
+  ctx config set decay.working_after 168h
+  ctx config set decay.on_session_start true`,
+	Args: cobra.NoArgs,
+	RunE: runDecay,
+}
+
+func init() {
+	decayCmd.Flags().DurationVar(&decayWorkingAfter, "working-after", 0, "Override decay.working_after for this run (e.g. 168h)")
+	decayCmd.Flags().DurationVar(&decayReferenceAfter, "reference-after", 0, "Override decay.reference_after for this run (e.g. 2160h)")
+	decayCmd.Flags().BoolVar(&decayDryRun, "dry-run", false, "List what would be demoted without changing anything")
+	rootCmd.AddCommand(decayCmd)
+}
+
+func runDecay(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	cfg := settings.Decay
+	if cmd.Flags().Changed("working-after") {
+		cfg.WorkingAfter = decayWorkingAfter
+	}
+	if cmd.Flags().Changed("reference-after") {
+		cfg.ReferenceAfter = decayReferenceAfter
+	}
+	pins, err := hookpkg.TaskPins(d)
+	if err != nil {
+		return err
+	}
+
+	report, err := decay.Run(d, decay.Options{
+		Rules:  decay.Rules(cfg),
+		Agent:  agent,
+		Keep:   pins,
+		DryRun: decayDryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(report.Demotions) == 0 {
+			fmt.Println("Nothing to demote.")
+			return nil
+		}
+		verb := "Demoted"
+		if report.DryRun {
+			verb = "Would demote"
+		}
+		fmt.Printf("%s %d node(s):\n", verb, len(report.Demotions))
+		for _, dm := range report.Demotions {
+			preview := dm.Node.Content
+			if len(preview) > 60 {
+				preview = preview[:60] + "..."
+			}
+			days := int(time.Since(dm.LastTouched).Hours() / 24)
+			fmt.Printf("  %s  %s -> %s  idle %dd  [%s] %s\n", dm.Node.ID[:8], dm.From, dm.To, days, dm.Node.Type, preview)
+		}
+	}
+	return nil
+}
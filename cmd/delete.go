@@ -5,6 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/provenance"
 )
 
 var deleteCmd = &cobra.Command{
@@ -39,10 +40,19 @@ func runDelete(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("node %s is not accessible to the current agent scope", id[:8])
 	}
 
+	// Flag derived knowledge before the node's edges disappear with it
+	stale, err := provenance.MarkStale(d, id)
+	if err != nil {
+		return err
+	}
+
 	if err := d.DeleteNode(id); err != nil {
 		return err
 	}
 
 	fmt.Printf("Deleted: %s\n", id)
+	if len(stale) > 0 {
+		fmt.Printf("Flagged %d derived nodes as %s\n", len(stale), provenance.StaleTag)
+	}
 	return nil
 }
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/demo"
+)
+
+var demoKeep bool
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Run the MCP server against a throwaway store of example memory",
+	Long: `Try ctx without risking or polluting your real memory. ctx demo creates a
+temporary store seeded with example nodes about a made-up web shop, then
+runs the MCP server on stdio against it, as ctx mcp does.
+
+Nothing under ~/.ctx is read or written: the demo runs with its own home
+directory and default settings, so no remote is synced and your
+config.yaml, mcp.yaml and profiles are ignored. The store is deleted when
+the MCP client disconnects, unless --keep is given.
+
+To try it in Claude Desktop, add this to claude_desktop_config.json:
+
+  "mcpServers": {
+    "ctx-demo": {"command": "ctx", "args": ["demo"]}
+  }`,
+	Args: cobra.NoArgs,
+	RunE: runDemo,
+}
+
+func init() {
+	demoCmd.Flags().BoolVar(&demoKeep, "keep", false, "Keep the demo store after the MCP server exits (its path is logged to stderr)")
+	rootCmd.AddCommand(demoCmd)
+}
+
+func runDemo(cmd *cobra.Command, args []string) error {
+	home, err := os.MkdirTemp("", "ctx-demo-")
+	if err != nil {
+		return fmt.Errorf("failed to create the demo directory: %w", err)
+	}
+	if !demoKeep {
+		defer os.RemoveAll(home)
+	}
+	path, err := isolateDemo(home)
+	if err != nil {
+		return err
+	}
+
+	d, err := db.Open(path)
+	if err != nil {
+		return err
+	}
+	n, err := demo.Seed(d)
+	d.Close()
+	if err != nil {
+		return err
+	}
+
+	// stdout carries the MCP protocol, so progress goes to stderr
+	fmt.Fprintf(os.Stderr, "ctx demo: serving %d example nodes (project:%s) from %s\n", n, demo.Project, path)
+	return runMCP(cmd, args)
+}
+
+// isolateDemo points everything that reads ~/.ctx at home instead: the
+// home directory itself, for packages that load the config on their own,
+// and the settings, database and flags this process already loaded. It
+// returns the demo store's path.
+func isolateDemo(home string) (string, error) {
+	for _, env := range []string{"HOME", "USERPROFILE"} {
+		if err := os.Setenv(env, home); err != nil {
+			return "", err
+		}
+	}
+	os.Setenv("CTX_CONFIG", filepath.Join(home, ".ctx", "config.yaml"))
+	os.Unsetenv("CTX_PROFILE")
+	os.Unsetenv("CTX_DB")
+
+	settings = config.Defaults()
+	dbPath, backend, agent, profile = settings.DB, settings.Backend, "", ""
+	return dbPath, nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/config"
+)
+
+func TestIsolateDemo(t *testing.T) {
+	// Restored by t.Setenv when the test ends
+	userHome := t.TempDir()
+	t.Setenv("HOME", userHome)
+	t.Setenv("USERPROFILE", userHome)
+	t.Setenv("CTX_CONFIG", filepath.Join(userHome, "config.yaml"))
+	t.Setenv("CTX_PROFILE", "work")
+	t.Setenv("CTX_DB", filepath.Join(userHome, "store.db"))
+	savedSettings, savedDB, savedBackend := settings, dbPath, backend
+	t.Cleanup(func() { settings, dbPath, backend = savedSettings, savedDB, savedBackend })
+	settings = &config.Config{Remote: "https://ctx.example.com"}
+
+	home := t.TempDir()
+	path, err := isolateDemo(home)
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(home, ".ctx", "store.db"), path)
+	assert.Equal(t, path, dbPath)
+	assert.Equal(t, "sqlite", backend)
+	assert.Empty(t, settings.Remote, "nothing is synced")
+	assert.Equal(t, filepath.Join(home, ".ctx", "config.yaml"), config.Path())
+	assert.Empty(t, os.Getenv("CTX_DB"))
+	assert.Equal(t, filepath.Join(home, ".ctx"), config.Load().StateDir())
+}
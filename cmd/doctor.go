@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/integrity"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the store and suggest fixes",
+	Long: `Check that the database opens, that full-text search is in sync with
+the nodes table, and that the graph has no integrity issues, printing the
+command that fixes each problem. Exits non-zero when a check fails.`,
+	SilenceUsage: true,
+	RunE:         runDoctor,
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// doctorCheck is one ctx doctor finding.
+type doctorCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	var checks []doctorCheck
+
+	st, err := d.Stats()
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "database", Detail: err.Error()})
+	} else {
+		checks = append(checks, doctorCheck{Name: "database", OK: true,
+			Detail: fmt.Sprintf("%d nodes, %d edges, %d tags", st.TotalNodes, st.TotalEdges, st.UniqueTags)})
+	}
+
+	drift, err := integrity.CheckFTSDrift(d)
+	switch {
+	case err != nil:
+		checks = append(checks, doctorCheck{Name: "search index", Detail: err.Error(), Fix: "ctx reindex"})
+	case drift.OK():
+		checks = append(checks, doctorCheck{Name: "search index", OK: true, Detail: "in sync"})
+	default:
+		detail := fmt.Sprintf("%d nodes missing or stale, %d entries for deleted nodes", drift.Missing, drift.Extra)
+		if drift.Corrupt {
+			detail += ", integrity check failed"
+		}
+		checks = append(checks, doctorCheck{Name: "search index", Detail: detail + "; search misses these nodes", Fix: "ctx reindex"})
+	}
+
+	report, err := integrity.Check(d)
+	if err != nil {
+		checks = append(checks, doctorCheck{Name: "integrity", Detail: err.Error()})
+	} else {
+		issues := 0
+		for kind, n := range report.Counts() {
+			if kind != integrity.FTSOutOfSync {
+				issues += n
+			}
+		}
+		if issues == 0 {
+			checks = append(checks, doctorCheck{Name: "integrity", OK: true, Detail: "no issues"})
+		} else {
+			checks = append(checks, doctorCheck{Name: "integrity", Detail: fmt.Sprintf("%d issues (see ctx fsck)", issues), Fix: "ctx fsck --fix"})
+		}
+	}
+
+	failed := 0
+	for _, c := range checks {
+		if !c.OK {
+			failed++
+		}
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(checks, "", "  ")
+		fmt.Println(string(data))
+	default:
+		for _, c := range checks {
+			status := "ok"
+			if !c.OK {
+				status = "FAIL"
+			}
+			fmt.Printf("%-4s  %-13s %s\n", status, c.Name, c.Detail)
+			if c.Fix != "" {
+				fmt.Printf("      %-13s fix: %s\n", "", c.Fix)
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+)
+
+var edgeCmd = &cobra.Command{
+	Use:   "edge",
+	Short: "Fix up edges between nodes",
+}
+
+var edgeFlipCmd = &cobra.Command{
+	Use:   "flip <from-id> <to-id> [type]",
+	Short: "Reverse the direction of an edge",
+	Long: `Replace the edge from <from-id> to <to-id> with one from <to-id> to
+<from-id>, e.g. a DERIVED_FROM edge created pointing from a source to its
+summary instead of the other way round. Without a type, the one edge
+between the two nodes is flipped; name the type when there are several.`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: runEdgeFlip,
+}
+
+func init() {
+	edgeCmd.AddCommand(edgeFlipCmd)
+	rootCmd.AddCommand(edgeCmd)
+}
+
+func runEdgeFlip(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	fromID, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	toID, err := resolveArg(d, args[1])
+	if err != nil {
+		return err
+	}
+	var edgeType string
+	if len(args) == 3 {
+		edgeType = args[2]
+	}
+
+	edges, err := d.GetEdgesFrom(fromID)
+	if err != nil {
+		return err
+	}
+	var match []*db.Edge
+	for _, e := range edges {
+		if e.ToID == toID && (edgeType == "" || e.Type == edgeType) {
+			match = append(match, e)
+		}
+	}
+	switch {
+	case len(match) == 0 && edgeType != "":
+		return fmt.Errorf("no %s edge from %s to %s", edgeType, fromID, toID)
+	case len(match) == 0:
+		return fmt.Errorf("no edge from %s to %s", fromID, toID)
+	case len(match) > 1:
+		return fmt.Errorf("%d edges from %s to %s; name the type to flip", len(match), fromID, toID)
+	}
+	old := match[0]
+
+	if err := d.DeleteEdge(fromID, toID, old.Type); err != nil {
+		return err
+	}
+	edge, err := d.CreateEdge(toID, fromID, old.Type)
+	if err != nil {
+		return fmt.Errorf("removed the %s edge but failed to create the reverse: %w", old.Type, err)
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(edge, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Flipped: %s → %s (%s)\n", toID[:8], fromID[:8], old.Type)
+	}
+	return nil
+}
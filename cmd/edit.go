@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Edit a node's content in $EDITOR",
+	Long: `Open the node's content in $VISUAL or $EDITOR (falling back to vi). When
+the editor exits, the content is saved if it changed: tokens are recalculated
+and the prior version is kept as a revision.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runEdit,
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+}
+
+func runEdit(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	id, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	node, err := d.GetNode(id)
+	if err != nil {
+		return err
+	}
+
+	edited, err := editInEditor(node.Content)
+	if err != nil {
+		return err
+	}
+	edited = db.NormalizeContent(edited)
+	if edited == "" {
+		return fmt.Errorf("content is empty; node %s left unchanged", node.ID)
+	}
+	if edited == node.Content {
+		fmt.Println("No changes.")
+		return nil
+	}
+
+	updated, err := d.UpdateNode(node.ID, db.UpdateNodeInput{Content: &edited})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated: %s (%d tokens, was %d)\n", updated.ID, updated.TokenEstimate, node.TokenEstimate)
+	return nil
+}
+
+// editorCommand returns the user's editor as program and arguments.
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{"vi"}
+}
+
+// editInEditor writes content to a temporary file, opens it in the user's
+// editor and returns the file's content once the editor exits.
+func editInEditor(content string) (string, error) {
+	f, err := os.CreateTemp("", "ctx-edit-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	if _, err := f.WriteString(content + "\n"); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	editor := editorCommand()
+	c := exec.Command(editor[0], append(editor[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor[0], err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %w", err)
+	}
+	return string(data), nil
+}
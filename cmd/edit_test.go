@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditorCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "")
+	assert.Equal(t, []string{"vi"}, editorCommand())
+
+	t.Setenv("EDITOR", "code --wait")
+	assert.Equal(t, []string{"code", "--wait"}, editorCommand())
+
+	t.Setenv("VISUAL", "nano")
+	assert.Equal(t, []string{"nano"}, editorCommand())
+}
+
+func TestEditInEditor(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/before/after/")
+
+	out, err := editInEditor("content before edit")
+	require.NoError(t, err)
+	assert.Equal(t, "content after edit\n", out)
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/embeddings"
+)
+
+var embeddingsCmd = &cobra.Command{
+	Use:   "embeddings",
+	Short: "Index and search node embeddings",
+	Long: `Semantic search finds nodes related by meaning rather than shared words.
+Vectors come from the provider set in embeddings.provider: ollama (a local
+Ollama server) or openai (any OpenAI-compatible embeddings API, keyed by
+the variable named in embeddings.api_key_env).`,
+}
+
+var embeddingsIndexCmd = &cobra.Command{
+	Use:   "index",
+	Short: "Embed nodes that are new or changed since they were last embedded",
+	RunE:  runEmbeddingsIndex,
+}
+
+var embeddingsSearchCmd = &cobra.Command{
+	Use:   "search <text>",
+	Short: "Find the nodes closest in meaning to text",
+	Long: `Find the nodes closest in meaning to text. New and edited nodes are
+embedded first, so results reflect the current store.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEmbeddingsSearch,
+}
+
+var (
+	embeddingsIndexAll bool
+	embeddingsSearchK  int
+)
+
+func init() {
+	embeddingsIndexCmd.Flags().BoolVar(&embeddingsIndexAll, "all", false, "Re-embed every node, not just new or changed ones")
+	embeddingsSearchCmd.Flags().IntVarP(&embeddingsSearchK, "k", "k", 10, "Number of results")
+	embeddingsCmd.AddCommand(embeddingsIndexCmd)
+	embeddingsCmd.AddCommand(embeddingsSearchCmd)
+	rootCmd.AddCommand(embeddingsCmd)
+}
+
+func runEmbeddingsIndex(cmd *cobra.Command, args []string) error {
+	e, err := embeddings.New(settings.Embeddings)
+	if err != nil {
+		return err
+	}
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	res, err := embeddings.Index(context.Background(), d, e, embeddingsIndexAll)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Embedded %d node(s) with %s (%d already current).\n", res.Embedded, res.Model, res.Current)
+	}
+	return nil
+}
+
+func runEmbeddingsSearch(cmd *cobra.Command, args []string) error {
+	e, err := embeddings.New(settings.Embeddings)
+	if err != nil {
+		return err
+	}
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx := context.Background()
+	if _, err := embeddings.Index(ctx, d, e, false); err != nil {
+		return err
+	}
+	hits, err := d.SemanticSearch(e, strings.Join(args, " "), embeddingsSearchK)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(hits, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(hits) == 0 {
+			fmt.Println("No results found.")
+			return nil
+		}
+		for _, h := range hits {
+			preview := h.Content
+			if len(preview) > 80 {
+				preview = preview[:80] + "..."
+			}
+			fmt.Printf("[%s] %s (%.2f): %s\n", h.ID, h.Type, h.Score, preview)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/entity"
+	"github.com/zate/ctx/internal/llm"
+)
+
+var entitiesCmd = &cobra.Command{
+	Use:   "entities",
+	Short: "Extract and browse the people, services and repos in memory",
+	Long: `Find the entities stored knowledge mentions and link them up.
+
+An extraction pass reads nodes for @handles (people), owner/repo paths on
+GitHub, GitLab or Bitbucket (repos), and names like service-foo or
+billing-api (services). Each entity becomes an "entity" node tagged
+entity:<kind>, linked from every node that mentions it by a MENTIONS
+edge. With --llm the configured LLM (llm.command) finds more.`,
+}
+
+var entitiesExtractCmd = &cobra.Command{
+	Use:   "extract",
+	Short: "Extract entities from nodes changed since the last pass",
+	RunE:  runEntitiesExtract,
+}
+
+var entitiesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List entities, most-mentioned first",
+	RunE:  runEntitiesList,
+}
+
+var entitiesShowCmd = &cobra.Command{
+	Use:   "show <name>",
+	Short: "Show everything that mentions an entity",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runEntitiesShow,
+}
+
+var (
+	entitiesAll  bool
+	entitiesLLM  bool
+	entitiesKind string
+)
+
+func init() {
+	entitiesExtractCmd.Flags().BoolVar(&entitiesAll, "all", false, "Re-read every node")
+	entitiesExtractCmd.Flags().BoolVar(&entitiesLLM, "llm", false, "Also ask the configured LLM for entities")
+	entitiesListCmd.Flags().StringVar(&entitiesKind, "kind", "", "Only list entities of this kind ("+strings.Join(entity.Kinds, ", ")+")")
+
+	entitiesCmd.AddCommand(entitiesExtractCmd)
+	entitiesCmd.AddCommand(entitiesListCmd)
+	entitiesCmd.AddCommand(entitiesShowCmd)
+	rootCmd.AddCommand(entitiesCmd)
+}
+
+func runEntitiesExtract(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	opts := entity.Options{All: entitiesAll}
+	if entitiesLLM {
+		opts.LLMCommand = config.Load().LLM.Command
+		if strings.TrimSpace(opts.LLMCommand) == "" {
+			return llm.ErrNotConfigured
+		}
+	}
+	res, err := entity.Run(cmd.Context(), d, opts)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Scanned %d nodes: %d new entities, %d new mentions\n", res.Scanned, res.Created, res.Mentions)
+	}
+	return nil
+}
+
+func runEntitiesList(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	entities, err := entity.List(d, entitiesKind)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(entities, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(entities) == 0 {
+			fmt.Println("No entities (run ctx entities extract)")
+			return nil
+		}
+		for _, e := range entities {
+			fmt.Printf("%6d  %-8s %s [%s]\n", e.Mentions, e.Kind, e.Name, e.ID)
+		}
+	}
+	return nil
+}
+
+func runEntitiesShow(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	node, err := entity.Find(d, args[0])
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("no entity named %q (see ctx entities list)", args[0])
+	}
+	mentions, err := entity.Mentions(d, node.ID)
+	if err != nil {
+		return err
+	}
+	mentions = filterNodesByAgent(mentions)
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(map[string]any{"entity": node, "mentions": mentions}, "", "  ")
+		fmt.Println(string(data))
+	default:
+		e, _ := entity.FromNode(node)
+		fmt.Printf("%s (%s) [%s], mentioned by %d nodes:\n", e.Name, e.Kind, node.ID, len(mentions))
+		for _, n := range mentions {
+			preview := n.Content
+			if len(preview) > 80 {
+				preview = preview[:80] + "..."
+			}
+			fmt.Printf("  [%s] %s: %s\n", n.ID, n.Type, preview)
+		}
+	}
+	return nil
+}
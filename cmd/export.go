@@ -3,36 +3,47 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/spf13/cobra"
+	agentpkg "github.com/zate/ctx/internal/agent"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
 	"github.com/zate/ctx/internal/query"
 )
 
-var exportQuery string
+var (
+	exportQuery  string
+	exportOutput string
+)
 
 var exportCmd = &cobra.Command{
-	Use:   "export",
-	Short: "Export graph to JSON",
-	RunE:  runExport,
+	Use:   "export [dir]",
+	Short: "Export the graph as JSONL or a markdown vault",
+	Long: `Write nodes (with their tags), edges and views as JSONL, one record per
+line, streamed so large stores never sit in memory. The format is the same
+for SQLite and PostgreSQL: export from one backend and ctx import into the
+other to migrate.
+
+With --query only the matching nodes, and the edges between them, are
+exported (views are left out), e.g. to share part of memory with a
+teammate.
+
+With --format obsidian, nodes are written into dir as one markdown note
+each, with YAML frontmatter holding the ID, type, tags and outgoing edges
+as wikilinks, to browse and edit in Obsidian. ctx import --format obsidian
+syncs the edits back.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExport,
 }
 
 func init() {
 	exportCmd.Flags().StringVar(&exportQuery, "query", "", "Filter by query")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
 	rootCmd.AddCommand(exportCmd)
 }
 
-type exportData struct {
-	Nodes []*db.Node `json:"nodes"`
-	Edges []*db.Edge `json:"edges"`
-	Tags  []tagEntry `json:"tags"`
-}
-
-type tagEntry struct {
-	NodeID string `json:"node_id"`
-	Tag    string `json:"tag"`
-}
-
 func runExport(cmd *cobra.Command, args []string) error {
 	d, err := openDB()
 	if err != nil {
@@ -40,49 +51,60 @@ func runExport(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	var nodes []*db.Node
+	var opts export.Options
 	if exportQuery != "" {
-		nodes, err = query.ExecuteQuery(d, exportQuery, true)
-	} else {
-		nodes, err = d.ListNodes(db.ListOptions{IncludeSuperseded: true})
-	}
-	if err != nil {
-		return err
+		opts.Nodes, err = query.ExecuteQuery(d, exportQuery, true)
+		if err != nil {
+			return err
+		}
+		if opts.Nodes == nil {
+			opts.Nodes = []*db.Node{}
+		}
 	}
-
 	// Filter by agent partition
-	nodes = filterNodesByAgent(nodes)
-
-	// Get all edges for exported nodes
-	nodeIDs := map[string]bool{}
-	for _, n := range nodes {
-		nodeIDs[n.ID] = true
+	if agent != "" {
+		opts.Include = func(n *db.Node) bool { return agentpkg.ShouldInclude(n, agent) }
 	}
 
-	var allEdges []*db.Edge
-	for _, n := range nodes {
-		edges, _ := d.GetEdgesFrom(n.ID)
-		for _, e := range edges {
-			if nodeIDs[e.ToID] {
-				allEdges = append(allEdges, e)
-			}
+	if format == "obsidian" {
+		if len(args) == 0 {
+			return fmt.Errorf("--format obsidian needs a vault directory")
 		}
+		res, err := export.WriteVault(d, args[0], opts)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Exported %d notes, %d links to %s\n", res.Nodes, res.Edges, args[0])
+		return nil
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("a directory is only used with --format obsidian; use --output for a file")
 	}
 
-	// Get all tags
-	var tags []tagEntry
-	for _, n := range nodes {
-		for _, t := range n.Tags {
-			tags = append(tags, tagEntry{NodeID: n.ID, Tag: t})
+	var w io.Writer = os.Stdout
+	if exportOutput != "" {
+		f, err := os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", exportOutput, err)
 		}
+		defer f.Close()
+		w = f
 	}
 
-	data, _ := json.MarshalIndent(exportData{
-		Nodes: nodes,
-		Edges: allEdges,
-		Tags:  tags,
-	}, "", "  ")
-	fmt.Println(string(data))
+	res, err := export.Write(d, w, opts)
+	if err != nil {
+		return err
+	}
+	if exportOutput == "" {
+		return nil
+	}
 
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Exported %d nodes, %d edges, %d tags, %d views to %s\n", res.Nodes, res.Edges, res.Tags, res.Views, exportOutput)
+	}
 	return nil
 }
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/integrity"
+)
+
+var fsckFix bool
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the database for orphaned rows and inconsistencies",
+	Long: `Report edges pointing to missing nodes, tags on missing nodes, dangling or
+cyclic supersede references, invalid metadata JSON, and a full-text index out
+of sync with the nodes table.
+
+With --fix, dangling edges and orphan tags are deleted, dangling supersede
+references are cleared, each supersede cycle is broken by reactivating its
+newest node, invalid metadata is reset to {}, and the full-text index is
+rebuilt. Without --fix, exits non-zero when issues are found.`,
+	RunE: runFsck,
+}
+
+func init() {
+	fsckCmd.Flags().BoolVar(&fsckFix, "fix", false, "Repair the issues found")
+	rootCmd.AddCommand(fsckCmd)
+}
+
+func runFsck(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	report, err := integrity.Check(d)
+	if err != nil {
+		return err
+	}
+	if fsckFix {
+		if err := integrity.Fix(d, report); err != nil {
+			return err
+		}
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if report.OK() {
+			fmt.Println("No issues found.")
+			return nil
+		}
+		for _, issue := range report.Issues {
+			subject := issue.NodeID
+			if issue.EdgeID != "" {
+				subject = "edge " + issue.EdgeID
+			}
+			if subject == "" {
+				fmt.Printf("%-20s %s\n", issue.Kind, issue.Detail)
+			} else {
+				fmt.Printf("%-20s %s: %s\n", issue.Kind, subject, issue.Detail)
+			}
+		}
+		if fsckFix {
+			fmt.Printf("\n%d issues found, %d fixed.\n", len(report.Issues), report.Fixed)
+		} else {
+			fmt.Printf("\n%d issues found. Repair with: ctx fsck --fix\n", len(report.Issues))
+		}
+	}
+
+	if !report.OK() && !fsckFix {
+		return fmt.Errorf("%d integrity issues found", len(report.Issues))
+	}
+	return nil
+}
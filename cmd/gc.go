@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/integrity"
+)
+
+var (
+	gcRetention time.Duration
+	gcDryRun    bool
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Prune old superseded nodes and compact the database",
+	Long: `Reclaim space in the store:
+
+  - delete superseded nodes older than --retention, counted from the later
+    of their last update and the creation of the node that replaced them,
+    with their edges, tags and attachments
+  - delete edges and tags that point at missing nodes
+  - rebuild the full-text index
+  - VACUUM and ANALYZE the database
+
+Deleted nodes are gone for good, history included: take a snapshot
+first (ctx snapshot create <name>) if you may want them back. With
+--dry-run nothing is changed and gc lists what it would remove.`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+func init() {
+	gcCmd.Flags().DurationVar(&gcRetention, "retention", integrity.DefaultRetention, "Keep superseded nodes this long (e.g. 720h)")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "List what would be removed without changing anything")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	if gcRetention < 0 {
+		return fmt.Errorf("--retention cannot be negative")
+	}
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	report, err := integrity.GC(d, integrity.GCOptions{Retention: gcRetention, DryRun: gcDryRun})
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		verb := "Pruned"
+		if report.DryRun {
+			verb = "Would prune"
+		}
+		fmt.Printf("%s %d superseded node(s)\n", verb, len(report.Pruned))
+		for _, p := range report.Pruned {
+			fmt.Printf("  %s [%s] %s (superseded by %s)\n", p.ID[:8], p.Type, p.Content, p.SupersededBy[:8])
+		}
+		if report.DryRun {
+			fmt.Printf("Would delete %d dangling edge(s) and %d orphan tag(s)\n", report.DanglingEdges, report.OrphanTags)
+			if !report.FTS.OK() {
+				fmt.Printf("Would rebuild the full-text index (%d missing or stale, %d extra)\n", report.FTS.Missing, report.FTS.Extra)
+			}
+			fmt.Println("Would VACUUM and ANALYZE the database")
+			return nil
+		}
+		fmt.Printf("Deleted %d dangling edge(s) and %d orphan tag(s)\n", report.DanglingEdges, report.OrphanTags)
+		fmt.Printf("Rebuilt the full-text index (%d missing or stale, %d extra entries repaired)\n", report.FTS.Missing, report.FTS.Extra)
+		fmt.Println("Vacuumed and analyzed the database")
+	}
+	return nil
+}
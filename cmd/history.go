@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+)
+
+var historyRestore string
+
+var historyCmd = &cobra.Command{
+	Use:   "history <id>",
+	Short: "Show how a node changed over time",
+	Long: `List a node's revisions, oldest first, then its current state. Every update
+(ctx update, ctx edit, hooks, sync) keeps the node's prior state as a
+revision.
+
+With --restore, the node goes back to a revision's content, type, summary
+and metadata. The state it replaces is kept as a revision in turn, so a
+restore can itself be undone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+func init() {
+	historyCmd.Flags().StringVar(&historyRestore, "restore", "", "Restore the revision with this ID (or ID prefix)")
+	rootCmd.AddCommand(historyCmd)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	id, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	node, err := d.GetNode(id)
+	if err != nil {
+		return err
+	}
+	revs, err := d.ListRevisions(id)
+	if err != nil {
+		return err
+	}
+
+	if historyRestore != "" {
+		rev, err := findRevision(revs, historyRestore)
+		if err != nil {
+			return err
+		}
+		input := db.UpdateNodeInput{Content: &rev.Content, Type: &rev.Type, Metadata: &rev.Metadata, Summary: rev.Summary}
+		restored, err := d.UpdateNode(id, input)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Restored %s to revision %s (%s)\n", restored.ID, rev.ID, settings.Clock().Format(rev.CreatedAt))
+		return nil
+	}
+
+	switch format {
+	case "json":
+		out := map[string]interface{}{
+			"node":      node,
+			"revisions": revs,
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	default:
+		clock := settings.Clock()
+		if len(revs) == 0 {
+			fmt.Printf("%s has no revisions; it is unchanged since %s.\n", node.ID, clock.Format(node.CreatedAt))
+			return nil
+		}
+		for i, rev := range revs {
+			fmt.Printf("Revision %d  %s  (replaced %s)\n", i+1, rev.ID, clock.Format(rev.CreatedAt))
+			printRevisionState(rev.Type, rev.Content)
+		}
+		fmt.Printf("Current  (updated %s)\n", clock.Format(node.UpdatedAt))
+		printRevisionState(node.Type, node.Content)
+	}
+	return nil
+}
+
+func printRevisionState(nodeType, content string) {
+	fmt.Printf("  Type: %s\n", nodeType)
+	for _, line := range strings.Split(content, "\n") {
+		fmt.Printf("  | %s\n", line)
+	}
+	fmt.Println()
+}
+
+// findRevision picks the revision whose ID starts with prefix.
+func findRevision(revs []*db.Revision, prefix string) (*db.Revision, error) {
+	prefix = strings.ToUpper(prefix)
+	var match *db.Revision
+	for _, rev := range revs {
+		if strings.HasPrefix(rev.ID, prefix) {
+			if match != nil {
+				return nil, fmt.Errorf("revision prefix %q is ambiguous", prefix)
+			}
+			match = rev
+		}
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no revision %q for this node (see ctx history)", prefix)
+	}
+	return match, nil
+}
@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+)
+
+func TestFindRevision(t *testing.T) {
+	revs := []*db.Revision{{ID: "01AAA1"}, {ID: "01AAA2"}, {ID: "01BBB1"}}
+
+	rev, err := findRevision(revs, "01bbb")
+	require.NoError(t, err)
+	assert.Equal(t, "01BBB1", rev.ID)
+
+	_, err = findRevision(revs, "01AAA")
+	assert.ErrorContains(t, err, "ambiguous")
+
+	_, err = findRevision(revs, "01CCC")
+	assert.ErrorContains(t, err, "no revision")
+}
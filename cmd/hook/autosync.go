@@ -10,9 +10,9 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
 	ctxsync "github.com/zate/ctx/internal/sync"
-	"gopkg.in/yaml.v3"
 )
 
 // autoSyncConfig checks if auto_sync is enabled and credentials exist.
@@ -25,44 +25,21 @@ type autoSyncConfig struct {
 // loadAutoSyncConfig loads remote + auth config for auto-sync.
 // Returns nil if auto-sync is not possible (missing config, not authenticated).
 func loadAutoSyncConfig() *autoSyncConfig {
-	home, err := os.UserHomeDir()
-	if err != nil {
+	settings := config.Load()
+	if !settings.AutoSync {
 		return nil
 	}
-
-	// Check for auto_sync setting in env or server.yaml
-	if env := os.Getenv("CTX_AUTO_SYNC"); env != "" {
-		if env != "true" && env != "1" {
-			return nil
-		}
-	} else {
-		// Parse server.yaml properly for auto_sync field
-		data, err := os.ReadFile(filepath.Join(home, ".ctx", "server.yaml"))
-		if err != nil {
-			return nil
-		}
-		var serverCfg struct {
-			AutoSync bool `yaml:"auto_sync"`
-		}
-		if yaml.Unmarshal(data, &serverCfg) != nil || !serverCfg.AutoSync {
-			return nil
-		}
-	}
-
-	// Load remote config
-	remoteData, err := os.ReadFile(filepath.Join(home, ".ctx", "remote.json"))
-	if err != nil {
+	dir := settings.StateDir()
+	if dir == "" {
 		return nil
 	}
-	var remote struct {
-		URL string `json:"url"`
-	}
-	if json.Unmarshal(remoteData, &remote) != nil || remote.URL == "" {
+	remoteURL := configuredRemote(settings)
+	if remoteURL == "" {
 		return nil
 	}
 
 	// Load auth config
-	authData, err := os.ReadFile(filepath.Join(home, ".ctx", "auth.json"))
+	authData, err := os.ReadFile(filepath.Join(dir, "auth.json"))
 	if err != nil {
 		return nil
 	}
@@ -75,12 +52,31 @@ func loadAutoSyncConfig() *autoSyncConfig {
 	}
 
 	return &autoSyncConfig{
-		ServerURL: remote.URL,
+		ServerURL: remoteURL,
 		Token:     auth.Token,
 		DeviceID:  auth.DeviceID,
 	}
 }
 
+// configuredRemote returns the remote server URL: the remote setting, or
+// the one saved by ctx remote set. It is empty when no remote is set.
+func configuredRemote(settings *config.Config) string {
+	if settings.Remote != "" {
+		return settings.Remote
+	}
+	data, err := os.ReadFile(filepath.Join(settings.StateDir(), "remote.json"))
+	if err != nil {
+		return ""
+	}
+	var remote struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(data, &remote) != nil {
+		return ""
+	}
+	return remote.URL
+}
+
 // autoSyncPull pulls remote changes on session start.
 // Fails gracefully — errors are logged to stderr, never block the session.
 func autoSyncPull(store db.Store) {
@@ -170,7 +166,15 @@ func autoSyncPush(store db.Store) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		fmt.Fprintf(os.Stderr, "ctx: auto-sync push: server error %d\n", resp.StatusCode)
+		var e struct {
+			Error string `json:"error"`
+		}
+		respBody, _ := io.ReadAll(resp.Body)
+		if json.Unmarshal(respBody, &e) == nil && e.Error != "" {
+			fmt.Fprintf(os.Stderr, "ctx: auto-sync push: %s\n", e.Error)
+		} else {
+			fmt.Fprintf(os.Stderr, "ctx: auto-sync push: server error %d\n", resp.StatusCode)
+		}
 		return
 	}
 
@@ -178,6 +182,11 @@ func autoSyncPush(store db.Store) {
 	state.LastPushAt = time.Now().UTC().Format(time.RFC3339)
 	_ = ctxsync.SaveSyncState(state)
 
+	var pushResp ctxsync.PushResponse
+	if respBody, _ := io.ReadAll(resp.Body); json.Unmarshal(respBody, &pushResp) == nil && pushResp.Conflicts == 0 {
+		ctxsync.ClearConflicts(store)
+	}
+
 	fmt.Fprintf(os.Stderr, "ctx: auto-sync pushed %d change(s)\n", len(changes))
 }
 
@@ -0,0 +1,95 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/queue"
+)
+
+// hookBudget is the time a hook may take before it answers with what it
+// has. Work it runs out of time for goes on the work queue and is finished
+// by a background `ctx hook flush`, so memory never holds up the user's
+// prompt.
+type hookBudget struct {
+	dbPath   string
+	deadline time.Time // zero when the budget is disabled
+	flushing bool
+	deferred int // commands handed to the background flush
+}
+
+func newHookBudget(dbPath string) *hookBudget {
+	b := &hookBudget{dbPath: dbPath}
+	if limit := config.Load().Hooks.Budget; limit > 0 {
+		b.deadline = time.Now().Add(limit)
+	}
+	return b
+}
+
+// context returns a context that expires with the budget.
+func (b *hookBudget) context(parent context.Context) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if b.deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, b.deadline)
+}
+
+// spent reports whether the budget has run out.
+func (b *hookBudget) spent() bool {
+	return !b.deadline.IsZero() && !time.Now().Before(b.deadline)
+}
+
+// execute runs commands until the budget is spent and defers the rest,
+// returning what the commands it ran changed.
+func (b *hookBudget) execute(d db.Store, commands []hookpkg.CtxCommand) ([]hookpkg.Action, []error) {
+	ctx, cancel := b.context(nil)
+	defer cancel()
+	actions, rest, errs := hookpkg.ExecuteCommandsReport(ctx, d, commands)
+	if len(rest) > 0 {
+		if err := hookpkg.DeferCommands(d, rest); err != nil {
+			fmt.Fprintf(os.Stderr, "ctx: failed to defer %d command(s): %v\n", len(rest), err)
+		} else {
+			b.deferred += len(rest)
+			b.flushLater()
+		}
+	}
+	return actions, errs
+}
+
+// deferJob queues a job of kind for the background flush.
+func (b *hookBudget) deferJob(d db.Store, kind string) {
+	if err := queue.Enqueue(d, kind, nil); err != nil {
+		fmt.Fprintf(os.Stderr, "ctx: failed to defer %s: %v\n", kind, err)
+		return
+	}
+	b.flushLater()
+}
+
+// flushLater starts `ctx hook flush` in the background, at most once per
+// hook. The child's output is discarded so the hook can exit straight away.
+func (b *hookBudget) flushLater() {
+	if b.flushing {
+		return
+	}
+	b.flushing = true
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ctx: cannot start background flush: %v\n", err)
+		return
+	}
+	flush := exec.Command(exe, "hook", "flush", "--db", b.dbPath)
+	if err := flush.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "ctx: cannot start background flush: %v\n", err)
+		return
+	}
+	_ = flush.Process.Release()
+}
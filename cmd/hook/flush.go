@@ -0,0 +1,94 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/queue"
+)
+
+// Job kinds for auto-syncs deferred by a hook.
+const (
+	syncPullJob = "sync-pull"
+	syncPushJob = "sync-push"
+)
+
+// jobHandlers runs each kind of queued work.
+var jobHandlers = map[string]queue.Handler{
+	hookpkg.CommandsJob: hookpkg.RunCommandsJob,
+	syncPullJob: func(d db.Store, _ json.RawMessage) error {
+		autoSyncPull(d)
+		return nil
+	},
+	syncPushJob: func(d db.Store, _ json.RawMessage) error {
+		autoSyncPush(d)
+		return nil
+	},
+}
+
+var flushWatch time.Duration
+
+var flushCmd = &cobra.Command{
+	Use:   "flush",
+	Short: "Process the hook work queue",
+	Long: `Run the work hooks deferred to the queue: ctx commands a hook ran out of
+time for (see hooks.budget) and auto-sync pulls and pushes. Hooks start this
+in the background themselves; running it by hand is safe and does nothing if
+the queue is empty.
+
+With --watch, keep running and drain the queue at that interval:
+
+  ctx hook flush --watch 10s`,
+	RunE: runFlush,
+}
+
+func init() {
+	flushCmd.Flags().DurationVar(&flushWatch, "watch", 0, "Keep running, draining the queue at this interval")
+}
+
+func runFlush(cmd *cobra.Command, args []string) error {
+	dbPath := cmd.Root().PersistentFlags().Lookup("db").Value.String()
+
+	d, err := db.Open(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer d.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for {
+		if err := drainQueue(ctx, d); err != nil {
+			return err
+		}
+		if flushWatch <= 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(flushWatch):
+		}
+	}
+}
+
+func drainQueue(ctx context.Context, d db.Store) error {
+	res, err := queue.Drain(ctx, d, jobHandlers, func(job queue.Job, err error) {
+		fmt.Fprintf(os.Stderr, "ctx: %s job %s failed (attempt %d): %v\n", job.Kind, job.ID, job.Attempts+1, err)
+	})
+	if err != nil {
+		return err
+	}
+	if res.Done+res.Retried+res.Dropped > 0 {
+		fmt.Fprintf(os.Stderr, "ctx: flushed %d job(s), %d retrying, %d dropped\n", res.Done, res.Retried, res.Dropped)
+	}
+	return nil
+}
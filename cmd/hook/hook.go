@@ -10,5 +10,5 @@ var HookCmd = &cobra.Command{
 }
 
 func init() {
-	HookCmd.AddCommand(sessionStartCmd, promptSubmitCmd, stopCmd)
+	HookCmd.AddCommand(sessionStartCmd, promptSubmitCmd, stopCmd, flushCmd, statsCmd)
 }
@@ -8,10 +8,12 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/queue"
 )
 
 // hookHarness simulates the full Claude Code hook lifecycle against an isolated DB.
@@ -22,6 +24,7 @@ type hookHarness struct {
 	dbPath string
 	binPath string
 	tmpDir string
+	env    []string // extra environment, applied after the defaults
 }
 
 func newHookHarness(t *testing.T) *hookHarness {
@@ -114,6 +117,9 @@ func (h *hookHarness) appendTranscriptEntries(path string, entries []map[string]
 func (h *hookHarness) run(args []string, stdin string) (string, string) {
 	h.t.Helper()
 	cmd := exec.Command(h.binPath, args...)
+	// Disable the hook time budget so a slow test machine never defers
+	// work to the background flush mid-test.
+	cmd.Env = append(append(os.Environ(), "CTX_HOOK_BUDGET=0"), h.env...)
 	if stdin != "" {
 		cmd.Stdin = strings.NewReader(stdin)
 	}
@@ -733,6 +739,47 @@ func TestIntegration_StopResponseFlag_Workaround(t *testing.T) {
 	assert.Equal(t, 2, h.nodeCount(), "--response flag should work as workaround")
 }
 
+func TestIntegration_StopSummarizesActions(t *testing.T) {
+	h := newHookHarness(t)
+
+	out := h.runStopWithResponse(`<ctx:remember type="fact">Summarized fact.</ctx:remember>`, "")
+
+	nodes := h.listNodes()
+	require.Len(t, nodes, 1)
+	var resp map[string]string
+	require.NoError(t, json.Unmarshal([]byte(out), &resp))
+	assert.Contains(t, resp["systemMessage"], "stored fact "+nodes[0].ID[:8])
+
+	h.env = []string{"CTX_HOOK_QUIET=true"}
+	out = h.runStopWithResponse(`<ctx:remember type="fact">Quiet fact.</ctx:remember>`, "")
+	assert.Equal(t, "{}", strings.TrimSpace(out))
+	assert.Equal(t, 2, h.nodeCount())
+}
+
+func TestIntegration_HookRunsRecorded(t *testing.T) {
+	h := newHookHarness(t)
+
+	h.runSessionStart("test", "")
+	h.runStopWithResponse(`<ctx:remember type="fact">Counted fact.</ctx:remember>
+<ctx:link from="ZZZ" to="ZZZ"/>`, "")
+
+	out, _ := h.run([]string{"hook", "stats", "--db", h.dbPath, "--format", "json"}, "")
+	var report struct {
+		Hooks    []db.HookStat `json:"hooks"`
+		Failures []db.HookRun  `json:"failures"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(out), &report))
+	require.Len(t, report.Hooks, 2)
+	assert.Equal(t, "session-start", report.Hooks[0].Hook)
+	stop := report.Hooks[1]
+	assert.Equal(t, "stop", stop.Hook)
+	assert.Equal(t, 1, stop.Runs)
+	assert.Equal(t, 2, stop.Commands)
+	assert.Equal(t, 1, stop.Errors)
+	require.Len(t, report.Failures, 1)
+	assert.Contains(t, report.Failures[0].Error, "link command failed")
+}
+
 // =============================================================================
 // Integration Test: Auto-tagging project and agent
 // =============================================================================
@@ -778,3 +825,115 @@ func TestIntegration_AutoTagging_NoOverrideExplicit(t *testing.T) {
 	assert.Contains(t, tags, "project:other", "explicit project should be kept")
 	assert.NotContains(t, tags, "project:myproject", "auto-project should NOT override explicit")
 }
+
+func TestIntegration_BudgetDefersCommandsToFlush(t *testing.T) {
+	h := newHookHarness(t)
+	h.env = []string{"CTX_HOOK_BUDGET=1ns"}
+
+	out := h.runStopWithResponse(`<ctx:remember type="fact">Deferred past the budget</ctx:remember>`, "")
+	assert.Equal(t, "{}", strings.TrimSpace(out))
+
+	// The hook hands the command to a background flush. Drain here too so a
+	// slow background start can't fail the test; claiming keeps the two
+	// flushes from running the job twice.
+	h.run([]string{"hook", "flush", "--db", h.dbPath}, "")
+	deadline := time.Now().Add(10 * time.Second)
+	for h.nodeCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	nodes := h.listNodes()
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "Deferred past the budget", nodes[0].Content)
+	d := h.openDB()
+	jobs, err := queue.List(d)
+	d.Close()
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+
+	// Flushing an empty queue is a no-op.
+	h.run([]string{"hook", "flush", "--db", h.dbPath}, "")
+	assert.Equal(t, 1, h.nodeCount())
+}
+
+func TestIntegration_SeveralRecallsInOneTurn(t *testing.T) {
+	h := newHookHarness(t)
+	h.runSessionStart("", "")
+
+	transcript := h.writeTranscriptFile([]map[string]any{
+		userEntry("Hello"),
+		assistantEntry(
+			`<ctx:remember type="fact" tags="tier:reference">Deploys run at noon.</ctx:remember>` + "\n" +
+				`<ctx:remember type="decision" tags="tier:reference">Use Postgres for billing.</ctx:remember>` + "\n" +
+				`<ctx:recall query="type:fact"/>` + "\n" +
+				`<ctx:recall query="type:decision"/>` + "\n" +
+				`<ctx:recall query="type:fact"/>`,
+		),
+	})
+	out := h.runPromptSubmit(transcript, "")
+
+	factAt := strings.Index(out, "Query: `type:fact`")
+	decisionAt := strings.Index(out, "Query: `type:decision`")
+	require.NotEqual(t, -1, factAt, out)
+	require.NotEqual(t, -1, decisionAt, out)
+	assert.Less(t, factAt, decisionAt, "sections follow the order of the recalls")
+	assert.Equal(t, 1, strings.Count(out, "Query: `type:fact`"), "a repeated recall runs once")
+	assert.Contains(t, out[factAt:decisionAt], "Deploys run at noon.")
+	assert.Contains(t, out[decisionAt:], "Use Postgres for billing.")
+	assert.Equal(t, "", h.getPending("recall_queries"), "the recalls are consumed")
+}
+
+func TestIntegration_RecallSkipsInbox(t *testing.T) {
+	h := newHookHarness(t)
+	h.env = []string{"CTX_INBOX=true"}
+	h.runSessionStart("", "")
+
+	d := h.openDB()
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Deploys run at noon.", Tags: []string{"tier:reference"}})
+	require.NoError(t, err)
+	d.Close()
+
+	transcript := h.writeTranscriptFile([]map[string]any{
+		userEntry("Hello"),
+		assistantEntry(
+			`<ctx:remember type="fact" tags="tier:reference">Deploys are frozen on Fridays.</ctx:remember>` + "\n" +
+				`<ctx:recall query="type:fact"/>`,
+		),
+	})
+	out := h.runPromptSubmit(transcript, "")
+
+	// The remember is held for review, so the recall only finds the
+	// accepted node
+	assert.Equal(t, 2, h.nodeCount())
+	assert.Contains(t, out, "Deploys run at noon.")
+	assert.NotContains(t, out, "frozen on Fridays", "a node awaiting review is not recalled")
+}
+
+func TestIntegration_PromptRefRecall(t *testing.T) {
+	h := newHookHarness(t)
+	h.runSessionStart("", "")
+
+	d := h.openDB()
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "zate/memdown#42 was reverted: it broke sync", Tags: []string{"tier:reference"}})
+	require.NoError(t, err)
+	d.Close()
+
+	submit := func(prompt string) string {
+		stdin, err := json.Marshal(map[string]string{"prompt": prompt})
+		require.NoError(t, err)
+		out, _ := h.run([]string{"hook", "prompt-submit", "--db", h.dbPath}, string(stdin))
+		return out
+	}
+
+	out := submit("Can you look at https://github.com/Zate/Memdown/pull/42 again?")
+	assert.Contains(t, out, "Query: `ref:zate/memdown#42`")
+	assert.Contains(t, out, "it broke sync")
+
+	// Nothing stored about the PR: no section, and no missed recall
+	out = submit("What about zate/memdown#43?")
+	assert.NotContains(t, out, "ref:zate/memdown#43")
+	d = h.openDB()
+	defer d.Close()
+	missed, err := d.ListMissedRecalls(10)
+	require.NoError(t, err)
+	assert.Empty(t, missed)
+}
@@ -1,16 +1,22 @@
 package hook
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
 	hookpkg "github.com/zate/ctx/internal/hook"
 	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/refs"
+	"github.com/zate/ctx/internal/usage"
 )
 
 var promptSubmitCmd = &cobra.Command{
@@ -21,6 +27,8 @@ var promptSubmitCmd = &cobra.Command{
 
 func runPromptSubmit(cmd *cobra.Command, args []string) error {
 	dbPath := cmd.Root().PersistentFlags().Lookup("db").Value.String()
+	budget := newHookBudget(dbPath)
+	run := startHookRun("prompt-submit", budget)
 
 	d, err := db.Open(dbPath)
 	if err != nil {
@@ -29,10 +37,11 @@ func runPromptSubmit(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	defer d.Close()
+	defer run.finish(d)
 
 	// Parse ctx commands from transcript (incremental via cursor)
-	transcriptPath, _ := readTranscriptPathFromStdin()
-	if transcriptPath != "" {
+	input, _ := readHookInputFromStdin()
+	if transcriptPath := input.TranscriptPath; transcriptPath != "" {
 		var cursor int64
 		if val, err := d.GetPending("transcript_cursor"); err == nil && val != "" {
 			_, _ = fmt.Sscanf(val, "%d", &cursor)
@@ -40,11 +49,12 @@ func runPromptSubmit(cmd *cobra.Command, args []string) error {
 
 		response, newOffset, err := readAssistantResponsesFromOffset(transcriptPath, cursor)
 		if err == nil && response != "" {
-			commands := hookpkg.ParseCtxCommands(response)
+			commands := parseCommands(response)
+			run.Commands = len(commands)
 			if len(commands) > 0 {
-				errs := hookpkg.ExecuteCommandsWithErrors(d, commands)
+				_, errs := budget.execute(d, commands)
 				for _, e := range errs {
-					fmt.Fprintf(os.Stderr, "ctx: %v\n", e)
+					run.warn("%v", e)
 				}
 
 				// Count successful remembers
@@ -80,31 +90,24 @@ func runPromptSubmit(cmd *cobra.Command, args []string) error {
 
 	var contextParts []string
 
-	// Check for recall query
-	recallQuery, err := d.GetPending("recall_query")
-	if err == nil && recallQuery != "" {
-		nodes, err := query.ExecuteQuery(d, recallQuery, false)
-		if err == nil {
-			// Filter by agent partition
-			nodes = filterNodesByAgent(nodes, currentAgent)
+	// Run every recall queued since the last prompt, each in its own
+	// section labelled with its query
+	recalls, err := hookpkg.TakeRecalls(d)
+	if err != nil {
+		run.warn("%v", err)
+	}
+	for _, q := range recalls {
+		if section := recallSection(cmd.Context(), d, run, budget, q, currentAgent); section != "" {
+			contextParts = append(contextParts, section)
+		}
+	}
 
-			var b strings.Builder
-			fmt.Fprintf(&b, "## Recall Results\n\nQuery: `%s`\n\n", recallQuery)
-			if len(nodes) == 0 {
-				b.WriteString("No matching nodes found.\n")
-			} else {
-				fmt.Fprintf(&b, "Found %d nodes:\n\n", len(nodes))
-				for _, n := range nodes {
-					fmt.Fprintf(&b, "- [%s:%s] %s\n", n.Type, n.ID, n.Content)
-					if len(n.Tags) > 0 {
-						fmt.Fprintf(&b, "  - Tags: %s\n", strings.Join(n.Tags, ", "))
-					}
-				}
-			}
-			b.WriteString("\n---\n")
-			contextParts = append(contextParts, b.String())
+	// Recall what is stored about the issues and pull requests the prompt
+	// mentions, when there is anything
+	for _, ref := range refs.Extract(input.Prompt) {
+		if section := refRecallSection(cmd.Context(), d, run, budget, ref, currentAgent); section != "" {
+			contextParts = append(contextParts, section)
 		}
-		_ = d.DeletePending("recall_query")
 	}
 
 	// Check for recall_results (pre-computed)
@@ -154,8 +157,8 @@ func runPromptSubmit(cmd *cobra.Command, args []string) error {
 	turnCount++
 	_ = d.SetPending("session_turn_count", strconv.Itoa(turnCount))
 
-	// Nudge if 4+ turns with no stores this session
-	if turnCount >= 4 {
+	// Nudge after the configured number of turns with no stores this session
+	if nudgeTurns := config.Load().Hooks.NudgeAfterTurns; nudgeTurns > 0 && turnCount >= nudgeTurns {
 		storeCount := 0
 		if val, err := d.GetPending("session_store_count"); err == nil && val != "" {
 			storeCount, _ = strconv.Atoi(val)
@@ -183,3 +186,83 @@ func runPromptSubmit(cmd *cobra.Command, args []string) error {
 	fmt.Println(string(data))
 	return nil
 }
+
+// recallSection runs a recall query and renders its results, or a note
+// that it timed out, as a context section. It returns "" for a query that
+// failed otherwise.
+func recallSection(parent context.Context, d db.Store, run *hookRun, budget *hookBudget, recallQuery, currentAgent string) string {
+	nodes, err := runRecall(parent, d, run, budget, recallQuery, currentAgent)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Sprintf("## Recall Results\n\nQuery: `%s`\n\nThe query timed out. Try a narrower query.\n\n---\n", recallQuery)
+	}
+	if err != nil {
+		return ""
+	}
+	if len(nodes) == 0 {
+		_ = usage.RecordMissedRecall(d, recallQuery)
+	}
+	return renderRecall(recallQuery, nodes)
+}
+
+// refRecallSection recalls the nodes that mention an issue or pull request
+// reference from the prompt. Unlike an explicit recall it returns "" when
+// nothing matches, and it is not counted as a missed recall.
+func refRecallSection(parent context.Context, d db.Store, run *hookRun, budget *hookBudget, ref, currentAgent string) string {
+	recallQuery := "ref:" + ref
+	nodes, err := runRecall(parent, d, run, budget, recallQuery, currentAgent)
+	if err != nil || len(nodes) == 0 {
+		return ""
+	}
+	return renderRecall(recallQuery, nodes)
+}
+
+// runRecall runs a recall query within the hook's time budget, keeps the
+// nodes visible to currentAgent that aren't awaiting review in the inbox
+// and records them as recalled and read.
+func runRecall(parent context.Context, d db.Store, run *hookRun, budget *hookBudget, recallQuery, currentAgent string) ([]*db.Node, error) {
+	budgetCtx, cancelBudget := budget.context(parent)
+	ctx, cancel := query.WithTimeout(budgetCtx, config.Load().Timeouts.Hook)
+	nodes, err := query.ExecuteQueryContext(ctx, d, recallQuery, false)
+	cancel()
+	cancelBudget()
+	if errors.Is(err, context.DeadlineExceeded) {
+		run.warn("recall query timed out: %s", recallQuery)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter by agent partition, and leave out nodes still in the review
+	// inbox as compose does
+	nodes = filterNodesByAgent(nodes, currentAgent)
+	nodes = slices.DeleteFunc(nodes, func(n *db.Node) bool {
+		return slices.Contains(n.Tags, hookpkg.ReviewPendingTag)
+	})
+
+	recalled := make([]string, len(nodes))
+	for i, n := range nodes {
+		recalled[i] = n.ID
+	}
+	_ = usage.RecordRecalls(d, recalled)
+	_ = usage.RecordAccess(d, nodes...)
+	return nodes, nil
+}
+
+// renderRecall renders the results of a recall query as a context section.
+func renderRecall(recallQuery string, nodes []*db.Node) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Recall Results\n\nQuery: `%s`\n\n", recallQuery)
+	if len(nodes) == 0 {
+		b.WriteString("No matching nodes found.\n")
+	} else {
+		fmt.Fprintf(&b, "Found %d nodes:\n\n", len(nodes))
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "- [%s:%s] %s\n", n.Type, n.ID, n.Content)
+			if len(n.Tags) > 0 {
+				fmt.Fprintf(&b, "  - Tags: %s\n", strings.Join(n.Tags, ", "))
+			}
+		}
+	}
+	b.WriteString("\n---\n")
+	return b.String()
+}
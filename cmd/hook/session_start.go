@@ -7,7 +7,14 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/decay"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/queue"
+	ctxsync "github.com/zate/ctx/internal/sync"
+	"github.com/zate/ctx/internal/usage"
 	"github.com/zate/ctx/internal/view"
 )
 
@@ -31,6 +38,8 @@ func init() {
 
 func runSessionStart(cmd *cobra.Command, args []string) error {
 	dbPath := cmd.Root().PersistentFlags().Lookup("db").Value.String()
+	deadline := newHookBudget(dbPath)
+	run := startHookRun("session-start", deadline)
 
 	d, err := db.Open(dbPath)
 	if err != nil {
@@ -40,9 +49,15 @@ func runSessionStart(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	defer d.Close()
+	defer run.finish(d)
 
-	// Auto-sync pull (if configured) — gracefully fails
-	autoSyncPull(d)
+	// Auto-sync pull (if configured) — gracefully fails, and is left to the
+	// background flush once the budget is spent
+	if deadline.spent() && loadAutoSyncConfig() != nil {
+		deadline.deferJob(d, syncPullJob)
+	} else {
+		autoSyncPull(d)
+	}
 
 	// Read last_session_stores before resetting
 	lastStores := -1
@@ -79,13 +94,24 @@ func runSessionStart(cmd *cobra.Command, args []string) error {
 		_ = d.DeletePending("current_project")
 	}
 
-	// Get default view query
-	var queryStr string
-	var budget int
-	err = d.QueryRow("SELECT query, budget FROM views WHERE name = 'default'").Scan(&queryStr, &budget)
+	// Get the configured session view, falling back to the configured tiers
+	settings := config.Load()
+	sessionView, err := view.Get(d, settings.DefaultView)
+	if err != nil {
+		sessionView = &view.Saved{Query: settings.InjectQuery(), Budget: settings.DefaultBudget}
+	}
+
+	// Nodes pinned to the current task join working context until it ends
+	taskPins, err := hookpkg.TaskPins(d)
 	if err != nil {
-		queryStr = "tag:tier:pinned OR tag:tier:working"
-		budget = 50000
+		run.warn("failed to read task pins: %v", err)
+	}
+
+	// Demote idle nodes before composing, so they drop out of this session
+	if settings.Decay.OnSessionStart {
+		if _, err := decay.Run(d, decay.Options{Rules: decay.Rules(settings.Decay), Agent: effectiveAgent, Keep: taskPins}); err != nil {
+			run.warn("failed to decay idle nodes: %v", err)
+		}
 	}
 
 	// Check for expand_nodes pending
@@ -96,15 +122,21 @@ func runSessionStart(cmd *cobra.Command, args []string) error {
 		_ = d.DeletePending("expand_nodes")
 	}
 
-	result, err := view.Compose(d, view.ComposeOptions{
-		Query:                 queryStr,
-		Budget:                budget,
+	budgetCtx, cancelBudget := deadline.context(cmd.Context())
+	defer cancelBudget()
+	ctx, cancel := query.WithTimeout(budgetCtx, settings.Timeouts.Hook)
+	defer cancel()
+	result, err := view.ComposeContext(ctx, d, view.ComposeOptions{
+		Query:                 sessionView.Query,
+		Budget:                sessionView.Budget,
 		Project:               sessionStartProject,
 		Agent:                 effectiveAgent,
 		IncludeReferenceStats: true,
+		Translate:             view.TranslationFor(settings),
+		TaskPins:              taskPins,
 	})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "ctx: failed to compose context: %v\n", err)
+		run.warn("failed to compose context: %v", err)
 		fmt.Println("{}")
 		return nil
 	}
@@ -133,12 +165,48 @@ func runSessionStart(cmd *cobra.Command, args []string) error {
 	}
 
 	result.LastSessionStores = lastStores
+	result.Layout = sessionView.Layout
+
+	// Surface divergence from the remote so it is noticed early
+	div := ctxsync.CheckDivergence(d, configuredRemote(settings))
+	result.Unsynced, result.SyncConflicts = div.Unsynced, div.Conflicts
+	if jobs, err := queue.List(d); err == nil {
+		result.QueuedJobs = len(jobs)
+	}
+
+	// Ask whether old, untouched knowledge still holds (spaced resurfacing)
+	resurfaced, err := view.Resurface(d, view.ResurfaceOptions{
+		After:   settings.Hooks.ResurfaceAfter,
+		Max:     settings.Hooks.ResurfaceMax,
+		Project: sessionStartProject,
+		Agent:   effectiveAgent,
+	})
+	if err != nil {
+		run.warn("failed to pick nodes to resurface: %v", err)
+	}
+	result.Resurfaced = resurfaced
+
+	// Remember what this session was given, for utilization analytics
+	var injected []string
+	seen := make(map[string]bool)
+	for _, n := range append(result.Nodes, resurfaced...) {
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			injected = append(injected, n.ID)
+		}
+	}
+	_ = usage.StartSession(d, injected)
+	_ = usage.RecordAccess(d, result.Nodes...)
 
 	// Load custom primer if specified, otherwise use built-in
-	if sessionStartPrimerFile != "" {
-		data, err := os.ReadFile(sessionStartPrimerFile)
+	primerFile := sessionStartPrimerFile
+	if primerFile == "" {
+		primerFile = settings.Hooks.PrimerFile
+	}
+	if primerFile != "" {
+		data, err := os.ReadFile(primerFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ctx: failed to read primer file %s: %v\n", sessionStartPrimerFile, err)
+			run.warn("failed to read primer file %s: %v", primerFile, err)
 		} else {
 			result.Primer = string(data)
 		}
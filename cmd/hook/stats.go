@@ -0,0 +1,117 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+)
+
+// hookRun measures one hook invocation and records it in hook_runs, so
+// failures show up in `ctx hook stats` instead of only on stderr.
+type hookRun struct {
+	db.HookRun
+	budget *hookBudget
+}
+
+func startHookRun(hook string, budget *hookBudget) *hookRun {
+	return &hookRun{HookRun: db.HookRun{Hook: hook, StartedAt: time.Now()}, budget: budget}
+}
+
+// warn reports a failure on stderr and counts it against the run.
+func (r *hookRun) warn(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(os.Stderr, "ctx: %s\n", msg)
+	r.Errors++
+	if r.Error == "" {
+		r.Error = msg
+	}
+}
+
+// finish records the run. A hook never fails because its metrics could
+// not be written.
+func (r *hookRun) finish(d db.Store) {
+	r.DurationMs = time.Since(r.StartedAt).Milliseconds()
+	r.Deferred = r.budget.deferred
+	_ = d.RecordHookRun(&r.HookRun)
+}
+
+var (
+	statsSince    time.Duration
+	statsFailures int
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show how the hooks have been running",
+	Long: `Summarize the recorded hook runs per hook: how many ran, how long they
+took, how many ctx commands they parsed and deferred, and how many failed,
+followed by the most recent failures and their first error.
+
+  ctx hook stats --since 24h`,
+	RunE: runStats,
+}
+
+func init() {
+	statsCmd.Flags().DurationVar(&statsSince, "since", 7*24*time.Hour, "Only count runs started within this long")
+	statsCmd.Flags().IntVar(&statsFailures, "failures", 5, "Recent failed runs to list")
+}
+
+type statsReport struct {
+	Since    time.Time      `json:"since"`
+	Hooks    []*db.HookStat `json:"hooks"`
+	Failures []*db.HookRun  `json:"failures"`
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	flags := cmd.Root().PersistentFlags()
+	d, err := db.Open(flags.Lookup("db").Value.String())
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer d.Close()
+
+	report := statsReport{Since: time.Now().Add(-statsSince).UTC()}
+	if report.Hooks, err = d.ListHookStats(report.Since); err != nil {
+		return err
+	}
+	if statsFailures > 0 {
+		if report.Failures, err = d.ListHookRuns(report.Since, true, statsFailures); err != nil {
+			return err
+		}
+	}
+
+	if f := flags.Lookup("format"); f != nil && f.Value.String() == "json" {
+		if report.Hooks == nil {
+			report.Hooks = []*db.HookStat{}
+		}
+		if report.Failures == nil {
+			report.Failures = []*db.HookRun{}
+		}
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	if len(report.Hooks) == 0 {
+		fmt.Printf("No hook runs recorded in the last %s.\n", statsSince)
+		return nil
+	}
+	fmt.Printf("%-14s %6s %7s %9s %8s %8s %8s  %s\n", "HOOK", "RUNS", "FAILED", "COMMANDS", "DEFERRED", "AVG MS", "MAX MS", "LAST RUN")
+	for _, s := range report.Hooks {
+		fmt.Printf("%-14s %6d %6.0f%% %9d %8d %8.1f %8d  %s\n",
+			s.Hook, s.Runs, s.FailureRate()*100, s.Commands, s.Deferred, s.AvgMs(), s.MaxMs,
+			s.LastRunAt.Local().Format("2006-01-02 15:04"))
+	}
+	if len(report.Failures) > 0 {
+		fmt.Println("\nRecent failures:")
+		for _, r := range report.Failures {
+			fmt.Printf("  %s  %-14s %d error(s): %s\n", r.StartedAt.Local().Format("2006-01-02 15:04"), r.Hook, r.Errors, strings.ReplaceAll(r.Error, "\n", " "))
+		}
+	}
+	return nil
+}
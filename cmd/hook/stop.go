@@ -1,13 +1,16 @@
 package hook
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
 	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/usage"
 )
 
 var stopResponse string
@@ -24,6 +27,8 @@ func init() {
 
 func runStop(cmd *cobra.Command, args []string) error {
 	dbPath := cmd.Root().PersistentFlags().Lookup("db").Value.String()
+	budget := newHookBudget(dbPath)
+	run := startHookRun("stop", budget)
 
 	d, err := db.Open(dbPath)
 	if err != nil {
@@ -32,6 +37,7 @@ func runStop(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 	defer d.Close()
+	defer run.finish(d)
 
 	var response string
 
@@ -41,7 +47,7 @@ func runStop(cmd *cobra.Command, args []string) error {
 		// Read stdin for hook input
 		transcriptPath, err := readTranscriptPathFromStdin()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "ctx: failed to read hook input: %v\n", err)
+			run.warn("failed to read hook input: %v", err)
 			fmt.Println("{}")
 			return nil
 		}
@@ -55,7 +61,7 @@ func runStop(cmd *cobra.Command, args []string) error {
 
 			resp, _, err := readAssistantResponsesFromOffset(transcriptPath, cursor)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "ctx: failed to read transcript: %v\n", err)
+				run.warn("failed to read transcript: %v", err)
 				fmt.Println("{}")
 				return nil
 			}
@@ -68,6 +74,9 @@ func runStop(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Count injected nodes the response cites, for utilization analytics
+	_ = usage.RecordReferences(d, response)
+
 	// Ensure current_agent is set from global --agent flag if not already stored
 	// (stop hook may run without a preceding session-start in some contexts)
 	if globalAgent := cmd.Root().PersistentFlags().Lookup("agent"); globalAgent != nil {
@@ -81,18 +90,17 @@ func runStop(cmd *cobra.Command, args []string) error {
 	}
 
 	// Parse ctx commands
-	commands := hookpkg.ParseCtxCommands(response)
+	commands := parseCommands(response)
+	run.Commands = len(commands)
 	if len(commands) == 0 {
 		fmt.Println("{}")
 		return nil
 	}
 
 	// Execute commands and track remember successes
-	errs := hookpkg.ExecuteCommandsWithErrors(d, commands)
-	if len(errs) > 0 {
-		for _, e := range errs {
-			fmt.Fprintf(os.Stderr, "ctx: %v\n", e)
-		}
+	actions, errs := budget.execute(d, commands)
+	for _, e := range errs {
+		run.warn("%v", e)
 	}
 
 	// Count successful remember commands for session tracking
@@ -129,10 +137,25 @@ func runStop(cmd *cobra.Command, args []string) error {
 		_ = d.SetPending("last_session_stores", "0")
 	}
 
-	// Auto-sync push (if configured) — gracefully fails
-	autoSyncPush(d)
+	// Auto-sync push (if configured) — gracefully fails, and is left to the
+	// background flush once the budget is spent
+	if budget.spent() && loadAutoSyncConfig() != nil {
+		budget.deferJob(d, syncPushJob)
+	} else {
+		autoSyncPush(d)
+	}
 
-	fmt.Println("{}")
+	// Tell the user what was remembered, so nothing is stored unseen
+	summary := ""
+	if !config.Load().Hooks.Quiet {
+		summary = hookpkg.SummarizeActions(actions)
+	}
+	if summary == "" {
+		fmt.Println("{}")
+		return nil
+	}
+	data, _ := json.Marshal(map[string]string{"systemMessage": summary})
+	fmt.Println(string(data))
 	return nil
 }
 
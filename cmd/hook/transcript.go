@@ -6,6 +6,9 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"github.com/zate/ctx/internal/config"
+	hookpkg "github.com/zate/ctx/internal/hook"
 )
 
 // readAssistantResponsesFromOffset reads a JSONL transcript file starting at
@@ -70,16 +73,35 @@ func readAssistantResponsesFromOffset(path string, offset int64) (string, int64,
 	return strings.Join(responses, "\n"), newOffset, nil
 }
 
-// readTranscriptPathFromStdin reads the hook input JSON from stdin and returns
-// the transcript_path value.
-func readTranscriptPathFromStdin() (string, error) {
-	var input map[string]any
+// hookInput is the part of the hook input JSON the hooks use. Prompt is
+// only sent to UserPromptSubmit.
+type hookInput struct {
+	TranscriptPath string `json:"transcript_path"`
+	Prompt         string `json:"prompt"`
+}
+
+// readHookInputFromStdin reads the hook input JSON from stdin.
+func readHookInputFromStdin() (hookInput, error) {
+	var input hookInput
 	decoder := json.NewDecoder(os.Stdin)
 	if err := decoder.Decode(&input); err != nil {
-		return "", err
+		return hookInput{}, err
 	}
-	if tp, ok := input["transcript_path"].(string); ok {
-		return tp, nil
+	return input, nil
+}
+
+// readTranscriptPathFromStdin reads the hook input JSON from stdin and returns
+// the transcript_path value.
+func readTranscriptPathFromStdin() (string, error) {
+	input, err := readHookInputFromStdin()
+	return input.TranscriptPath, err
+}
+
+// parseCommands extracts the ctx commands from an assistant response,
+// strictly when hooks.strict is set.
+func parseCommands(response string) []hookpkg.CtxCommand {
+	if config.Load().Hooks.Strict {
+		return hookpkg.ParseCtxCommandsStrict(response)
 	}
-	return "", nil
+	return hookpkg.ParseCtxCommands(response)
 }
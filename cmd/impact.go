@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/provenance"
+)
+
+var impactDepth int
+
+var impactCmd = &cobra.Command{
+	Use:   "impact <id>",
+	Short: "Show everything that transitively depends on a node",
+	Long: `List the nodes that depend on a node, directly or through others, by
+following DEPENDS_ON and DERIVED_FROM edges backwards. Run it before
+superseding a foundational decision to see what would need revisiting:
+the tree shows how each dependent is reached, and the summary counts them
+by depth and type.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runImpact,
+}
+
+func init() {
+	impactCmd.Flags().IntVar(&impactDepth, "depth", 0, "Follow at most this many hops (0 for no limit)")
+	rootCmd.AddCommand(impactCmd)
+}
+
+type impactNode struct {
+	provenance.Dependent
+	Type       string `json:"type"`
+	Content    string `json:"content"`
+	Superseded bool   `json:"superseded,omitempty"`
+}
+
+type impactReport struct {
+	ID       string         `json:"id"`
+	Total    int            `json:"total"`
+	Direct   int            `json:"direct"`
+	MaxDepth int            `json:"max_depth"`
+	ByType   map[string]int `json:"by_type"`
+	Nodes    []impactNode   `json:"nodes"`
+}
+
+func runImpact(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	id, err := resolveArg(d, args[0])
+	if err != nil {
+		return err
+	}
+	root, err := d.GetNode(id)
+	if err != nil {
+		return err
+	}
+
+	deps, err := provenance.Dependents(d, id, impactDepth)
+	if err != nil {
+		return err
+	}
+
+	report := impactReport{ID: id, ByType: map[string]int{}, Nodes: []impactNode{}}
+	for _, dep := range deps {
+		n, err := d.GetNode(dep.ID)
+		if err != nil {
+			continue
+		}
+		report.Nodes = append(report.Nodes, impactNode{Dependent: dep, Type: n.Type, Content: n.Content, Superseded: n.SupersededBy != nil})
+		report.Total++
+		report.ByType[n.Type]++
+		if dep.Depth == 1 {
+			report.Direct++
+		}
+		if dep.Depth > report.MaxDepth {
+			report.MaxDepth = dep.Depth
+		}
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		printImpact(root, &report)
+	}
+	return nil
+}
+
+func printImpact(root *db.Node, report *impactReport) {
+	fmt.Printf("[%s] %s: %s\n", root.ID, root.Type, impactPreview(root.Content))
+	if report.Total == 0 {
+		fmt.Println("Nothing depends on this node.")
+		return
+	}
+
+	children := map[string][]impactNode{}
+	for _, n := range report.Nodes {
+		children[n.Parent] = append(children[n.Parent], n)
+	}
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, n := range children[parent] {
+			marker := ""
+			if n.Superseded {
+				marker = " (superseded)"
+			}
+			fmt.Printf("%s← %s [%s] %s%s: %s\n", strings.Repeat("  ", n.Depth), n.Via, n.ID, n.Type, marker, impactPreview(n.Content))
+			walk(n.ID)
+		}
+	}
+	walk(root.ID)
+
+	types := make([]string, 0, len(report.ByType))
+	for t := range report.ByType {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if report.ByType[types[i]] != report.ByType[types[j]] {
+			return report.ByType[types[i]] > report.ByType[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	var parts []string
+	for _, t := range types {
+		parts = append(parts, fmt.Sprintf("%d %s", report.ByType[t], t))
+	}
+	fmt.Printf("\n%d dependent(s): %d direct, %d transitive, up to %d hop(s) away (%s)\n",
+		report.Total, report.Direct, report.Total-report.Direct, report.MaxDepth, strings.Join(parts, ", "))
+}
+
+func impactPreview(content string) string {
+	content = strings.ReplaceAll(content, "\n", " ")
+	if len(content) > 60 {
+		content = content[:60] + "..."
+	}
+	return content
+}
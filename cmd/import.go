@@ -5,21 +5,35 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/export"
 )
 
 var importMerge bool
 
 var importCmd = &cobra.Command{
-	Use:   "import",
-	Short: "Import graph from JSON (reads stdin)",
-	RunE:  runImport,
+	Use:   "import [file|dir]",
+	Short: "Import a JSONL export (reads stdin without a file) or a markdown vault",
+	Long: `Import what ctx export wrote, from either backend, a record at a time.
+Node IDs are kept, so edges and supersede links survive the trip. The JSON
+document older versions of ctx export wrote is also accepted.
+
+Without --merge, a node that already exists fails the import and imported
+views replace ones with the same name. With --merge, existing nodes and
+views are kept and only what's new is added.
+
+With --format obsidian, dir is a vault written by ctx export --format
+obsidian. Edited notes update their nodes, notes without an ID become new
+nodes (and get one written back), and each note's links replace its
+node's edges to other notes. With --merge, existing nodes are left as they
+are and only new notes and links are added.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runImport,
 }
 
 func init() {
-	importCmd.Flags().BoolVar(&importMerge, "merge", false, "Skip conflicts instead of failing")
+	importCmd.Flags().BoolVar(&importMerge, "merge", false, "Skip nodes and views that already exist instead of failing")
 	rootCmd.AddCommand(importCmd)
 }
 
@@ -30,76 +44,39 @@ func runImport(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	data, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		return fmt.Errorf("failed to read stdin: %w", err)
-	}
-
-	var imp exportData
-	if err := json.Unmarshal(data, &imp); err != nil {
-		return fmt.Errorf("failed to parse JSON: %w", err)
-	}
-
-	nodesImported := 0
-	edgesImported := 0
-	tagsImported := 0
-
-	for _, n := range imp.Nodes {
-		now := time.Now().UTC().Format(time.RFC3339)
-		createdAt := n.CreatedAt.Format(time.RFC3339)
-		updatedAt := n.UpdatedAt.Format(time.RFC3339)
-		metadata := n.Metadata
-		if metadata == "" {
-			metadata = "{}"
-		}
-
-		var summaryVal interface{}
-		if n.Summary != nil {
-			summaryVal = *n.Summary
+	if format == "obsidian" {
+		if len(args) == 0 {
+			return fmt.Errorf("--format obsidian needs a vault directory")
 		}
-
-		var supersededVal interface{}
-		if n.SupersededBy != nil {
-			supersededVal = *n.SupersededBy
-		}
-		_ = now
-
-		insertSQL := "INSERT INTO nodes (id, type, content, summary, token_estimate, superseded_by, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
-		if importMerge {
-			insertSQL = "INSERT OR IGNORE INTO nodes (id, type, content, summary, token_estimate, superseded_by, created_at, updated_at, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)"
-		}
-		_, err := d.Exec(insertSQL, n.ID, n.Type, n.Content, summaryVal, n.TokenEstimate, supersededVal, createdAt, updatedAt, metadata)
+		res, err := export.ImportVault(d, args[0], export.ImportOptions{Merge: importMerge})
 		if err != nil {
-			if !importMerge {
-				return fmt.Errorf("failed to import node %s: %w", n.ID, err)
-			}
-			continue
+			return err
 		}
-		nodesImported++
+		fmt.Printf("Imported: %d nodes created or updated, %d links, %d tags\n", res.Nodes, res.Edges, res.Tags)
+		return nil
 	}
 
-	for _, e := range imp.Edges {
-		_, err := d.Exec("INSERT OR IGNORE INTO edges (id, from_id, to_id, type, created_at, metadata) VALUES (?, ?, ?, ?, ?, ?)",
-			e.ID, e.FromID, e.ToID, e.Type, e.CreatedAt.Format(time.RFC3339), e.Metadata)
+	var r io.Reader = os.Stdin
+	if len(args) == 1 {
+		f, err := os.Open(args[0])
 		if err != nil {
-			if !importMerge {
-				return fmt.Errorf("failed to import edge %s: %w", e.ID, err)
-			}
-			continue
+			return err
 		}
-		edgesImported++
+		defer f.Close()
+		r = f
 	}
 
-	for _, t := range imp.Tags {
-		now := time.Now().UTC().Format(time.RFC3339)
-		_, err := d.Exec("INSERT OR IGNORE INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)",
-			t.NodeID, t.Tag, now)
-		if err != nil {
-			continue
-		}
-		tagsImported++
+	res, err := export.Import(d, r, export.ImportOptions{Merge: importMerge})
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Imported: %d nodes, %d edges, %d tags\n", nodesImported, edgesImported, tagsImported)
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Imported: %d nodes, %d edges, %d tags, %d views\n", res.Nodes, res.Edges, res.Tags, res.Views)
+	}
 	return nil
 }
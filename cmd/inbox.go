@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/provenance"
+)
+
+var inboxCmd = &cobra.Command{
+	Use:   "inbox",
+	Short: "Review nodes created by hooks before they are kept",
+	Long: `Triage nodes that hooks created while the inbox is enabled.
+
+Enable the inbox with CTX_INBOX=true or "inbox: true" in ~/.ctx/config.yaml.
+Hook-created nodes are then tagged ` + hookpkg.ReviewPendingTag + ` until accepted or rejected, and
+are left out of composed context, including the session start, until
+accepted. Accept and reject only take nodes awaiting review.`,
+}
+
+var inboxListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List nodes awaiting review",
+	RunE:  runInboxList,
+}
+
+var inboxAcceptCmd = &cobra.Command{
+	Use:   "accept [id...]",
+	Short: "Accept nodes, keeping them in memory",
+	RunE:  runInboxAccept,
+}
+
+var inboxRejectCmd = &cobra.Command{
+	Use:   "reject [id...]",
+	Short: "Reject nodes, deleting them",
+	RunE:  runInboxReject,
+}
+
+var inboxEditCmd = &cobra.Command{
+	Use:   "edit <id>",
+	Short: "Edit a node's content or type and accept it",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runInboxEdit,
+}
+
+var (
+	inboxAll     bool
+	inboxContent string
+	inboxType    string
+)
+
+func init() {
+	inboxAcceptCmd.Flags().BoolVar(&inboxAll, "all", false, "Accept every pending node")
+	inboxRejectCmd.Flags().BoolVar(&inboxAll, "all", false, "Reject every pending node")
+	inboxEditCmd.Flags().StringVar(&inboxContent, "content", "", "New content")
+	inboxEditCmd.Flags().StringVar(&inboxType, "type", "", "New node type")
+
+	inboxCmd.AddCommand(inboxListCmd)
+	inboxCmd.AddCommand(inboxAcceptCmd)
+	inboxCmd.AddCommand(inboxRejectCmd)
+	inboxCmd.AddCommand(inboxEditCmd)
+	rootCmd.AddCommand(inboxCmd)
+}
+
+func pendingReview(d db.Store) ([]*db.Node, error) {
+	nodes, err := d.GetNodesByTag(hookpkg.ReviewPendingTag)
+	if err != nil {
+		return nil, err
+	}
+	return filterNodesByAgent(nodes), nil
+}
+
+// inboxTargets resolves the IDs given on the command line, or every pending
+// node when --all is set. It refuses IDs of nodes that are not awaiting
+// review, so accept and reject never touch ordinary memory.
+func inboxTargets(d db.Store, args []string) ([]string, error) {
+	if inboxAll {
+		nodes, err := pendingReview(d)
+		if err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(nodes))
+		for i, n := range nodes {
+			ids[i] = n.ID
+		}
+		return ids, nil
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("specify node IDs or --all")
+	}
+
+	ids := make([]string, 0, len(args))
+	for _, arg := range args {
+		id, err := inboxNode(d, arg)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// inboxNode resolves arg to the ID of a node awaiting review, refusing
+// nodes without the review:pending tag.
+func inboxNode(d db.Store, arg string) (string, error) {
+	id, err := resolveArg(d, arg)
+	if err != nil {
+		return "", err
+	}
+	tags, err := d.GetTags(id)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(tags, hookpkg.ReviewPendingTag) {
+		return "", fmt.Errorf("node %s is not in the inbox (no %s tag)", id, hookpkg.ReviewPendingTag)
+	}
+	return id, nil
+}
+
+func runInboxList(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	nodes, err := pendingReview(d)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(nodes, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(nodes) == 0 {
+			fmt.Println("Inbox is empty.")
+			return nil
+		}
+		for _, n := range nodes {
+			fmt.Printf("[%s] %s: %s\n", n.ID, n.Type, db.Preview(n.Content, 80))
+		}
+		fmt.Printf("\n%d pending. Triage with: ctx inbox accept|reject|edit <id>\n", len(nodes))
+	}
+
+	return nil
+}
+
+func runInboxAccept(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ids, err := inboxTargets(d, args)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := d.RemoveTag(id, hookpkg.ReviewPendingTag); err != nil {
+			return err
+		}
+		fmt.Printf("Accepted: %s\n", id)
+	}
+	return nil
+}
+
+func runInboxReject(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ids, err := inboxTargets(d, args)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if _, err := provenance.MarkStale(d, id); err != nil {
+			return err
+		}
+		if err := d.DeleteNode(id); err != nil {
+			return err
+		}
+		fmt.Printf("Rejected: %s\n", id)
+	}
+	return nil
+}
+
+func runInboxEdit(cmd *cobra.Command, args []string) error {
+	if inboxContent == "" && inboxType == "" {
+		return fmt.Errorf("specify --content and/or --type")
+	}
+
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	id, err := inboxNode(d, args[0])
+	if err != nil {
+		return err
+	}
+
+	var input db.UpdateNodeInput
+	if inboxContent != "" {
+		input.Content = &inboxContent
+	}
+	if inboxType != "" {
+		input.Type = &inboxType
+	}
+	if _, err := d.UpdateNode(id, input); err != nil {
+		return err
+	}
+	if err := d.RemoveTag(id, hookpkg.ReviewPendingTag); err != nil {
+		return err
+	}
+
+	fmt.Printf("Edited and accepted: %s\n", id)
+	return nil
+}
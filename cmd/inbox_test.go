@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestInboxTargetsOnlyPending(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	pending, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "From a hook", Tags: []string{hookpkg.ReviewPendingTag}})
+	require.NoError(t, err)
+	curated, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Curated"})
+	require.NoError(t, err)
+
+	ids, err := inboxTargets(d, []string{pending.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{pending.ID}, ids)
+
+	_, err = inboxTargets(d, []string{pending.ID, curated.ID})
+	assert.ErrorContains(t, err, "not in the inbox")
+}
+
+func TestInboxEditOnlyPending(t *testing.T) {
+	setupMCPTest(t)
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	pending, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "From a hook", Tags: []string{hookpkg.ReviewPendingTag}})
+	require.NoError(t, err)
+	curated, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Curated"})
+	require.NoError(t, err)
+	d.Close()
+
+	inboxContent, inboxType = "Rewritten", ""
+	t.Cleanup(func() { inboxContent = "" })
+	assert.ErrorContains(t, runInboxEdit(inboxEditCmd, []string{curated.ID}), "not in the inbox")
+	require.NoError(t, runInboxEdit(inboxEditCmd, []string{pending.ID}))
+
+	d, err = db.Open(dbPath)
+	require.NoError(t, err)
+	defer d.Close()
+	got, err := d.GetNode(curated.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Curated", got.Content, "reviewed memory is left alone")
+	got, err = d.GetNode(pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Rewritten", got.Content)
+	assert.NotContains(t, got.Tags, hookpkg.ReviewPendingTag)
+}
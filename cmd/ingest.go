@@ -1,26 +1,63 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/ingest"
 )
 
-var ingestTags []string
+var (
+	ingestTags          []string
+	ingestChunkTokens   int
+	ingestWatchInterval time.Duration
+)
 
 var ingestCmd = &cobra.Command{
 	Use:   "ingest <file>",
-	Short: "Ingest a file as a source node",
+	Short: "Ingest a file as source nodes",
+	Long: `Split a file into chunks and store each chunk as a source node.
+
+Chunks are deduplicated by content hash: re-ingesting a file reuses the
+existing node for every chunk whose content has not changed, and a chunk
+found in several files is one node that lists them all.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runIngest,
+}
+
+var ingestRefreshCmd = &cobra.Command{
+	Use:   "refresh [dir]",
+	Short: "Re-ingest previously ingested files that have changed",
+	Long: `Re-ingest every previously ingested file (or only those under dir).
+
+Chunks whose content is no longer in the file are superseded, by a new
+chunk where the file gained one, and summaries derived from a superseded
+chunk are relinked to its replacement. Unchanged chunks are kept as they
+are wherever they moved to. A chunk another file still contains stays
+active.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runIngestRefresh,
+}
+
+var ingestWatchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Watch previously ingested files under a directory and refresh on change",
 	Args:  cobra.ExactArgs(1),
-	RunE:  runIngest,
+	RunE:  runIngestWatch,
 }
 
 func init() {
+	ingestCmd.PersistentFlags().IntVar(&ingestChunkTokens, "chunk-tokens", ingest.DefaultChunkTokens, "Maximum tokens per chunk")
 	ingestCmd.Flags().StringArrayVar(&ingestTags, "tag", nil, "Tags (repeatable)")
+	ingestWatchCmd.Flags().DurationVar(&ingestWatchInterval, "interval", 2*time.Second, "Polling interval")
+	ingestCmd.AddCommand(ingestRefreshCmd)
+	ingestCmd.AddCommand(ingestWatchCmd)
 	rootCmd.AddCommand(ingestCmd)
 }
 
@@ -31,34 +68,82 @@ func runIngest(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	content, err := os.ReadFile(args[0])
+	report, err := ingest.File(d, args[0], ingest.Options{
+		Tags:        ingestTags,
+		ChunkTokens: ingestChunkTokens,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+		return err
 	}
 
-	filename := filepath.Base(args[0])
-	metadata, _ := json.Marshal(map[string]string{
-		"source_file": args[0],
-		"filename":    filename,
-	})
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Ingested: %s → %d chunks (%d new, %d changed, %d unchanged)\n",
+			filepath.Base(args[0]), len(report.Chunks), report.New, report.Changed, report.Unchanged)
+		for _, c := range report.Chunks {
+			fmt.Printf("  #%d %s %s (%d tokens)\n", c.Index, c.NodeID, c.Status, c.Tokens)
+		}
+	}
 
-	node, err := d.CreateNode(db.CreateNodeInput{
-		Type:     "source",
-		Content:  string(content),
-		Metadata: string(metadata),
-		Tags:     ingestTags,
-	})
+	return nil
+}
+
+func runIngestRefresh(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	dir := ""
+	if len(args) == 1 {
+		dir = args[0]
+	}
+
+	result, err := ingest.Refresh(d, dir, ingest.Options{ChunkTokens: ingestChunkTokens})
 	if err != nil {
 		return err
 	}
 
+	printRefreshResult(result)
+	return nil
+}
+
+func runIngestWatch(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if format != "json" {
+		fmt.Printf("Watching %s (every %s, Ctrl-C to stop)\n", args[0], ingestWatchInterval)
+	}
+	return ingest.Watch(ctx, d, args[0], ingestWatchInterval,
+		ingest.Options{ChunkTokens: ingestChunkTokens}, printRefreshResult)
+}
+
+func printRefreshResult(result *ingest.RefreshResult) {
 	switch format {
 	case "json":
-		data, _ := json.MarshalIndent(node, "", "  ")
+		data, _ := json.MarshalIndent(result, "", "  ")
 		fmt.Println(string(data))
 	default:
-		fmt.Printf("Ingested: %s → %s (%d tokens)\n", filename, node.ID, node.TokenEstimate)
+		for _, r := range result.Updated {
+			fmt.Printf("Refreshed: %s (%d new, %d changed, %d unchanged, %d relinked)\n",
+				r.File, r.New, r.Changed, r.Unchanged, r.Relinked)
+		}
+		for _, path := range result.Missing {
+			fmt.Printf("Missing: %s\n", path)
+		}
+		if len(result.Updated) == 0 {
+			fmt.Printf("%d files checked, nothing changed\n", result.Checked)
+		}
 	}
-
-	return nil
 }
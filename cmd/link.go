@@ -3,8 +3,10 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/provenance"
 )
 
 var linkType string
@@ -41,6 +43,15 @@ func runLink(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if linkType == "DERIVED_FROM" {
+		from, _ := d.GetNode(fromID)
+		to, _ := d.GetNode(toID)
+		if from != nil && to != nil {
+			if hint := provenance.BackwardsHint(from, to); hint != "" {
+				fmt.Fprintf(os.Stderr, "ctx warning: %s (reverse it with: ctx edge flip %s %s DERIVED_FROM)\n", hint, fromID, toID)
+			}
+		}
+	}
 
 	switch format {
 	case "json":
@@ -4,56 +4,155 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/approval"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/embeddings"
+	"github.com/zate/ctx/internal/gitlink"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/ingest"
+	"github.com/zate/ctx/internal/provenance"
 	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/related"
+	ctxsync "github.com/zate/ctx/internal/sync"
+	"github.com/zate/ctx/internal/usage"
+	"github.com/zate/ctx/internal/validate"
 	"github.com/zate/ctx/internal/view"
 )
 
 var mcpCmd = &cobra.Command{
 	Use:   "mcp",
 	Short: "Run MCP server for Claude Desktop and other MCP clients",
-	RunE:  runMCP,
+	Long: `Run an MCP server on stdio.
+
+Tools can be restricted with enabled_tools / disabled_tools in ~/.ctx/mcp.yaml,
+or with --enable / --disable (names may omit the ctx_ prefix):
+
+  ctx mcp --disable delete,forget,supersede,link,unlink
+  ctx mcp --enable remember,recall,search,show,list,status,compose`,
+	RunE: runMCP,
 }
 
+var (
+	mcpEnable  []string
+	mcpDisable []string
+)
+
 func init() {
+	mcpCmd.Flags().StringSliceVar(&mcpEnable, "enable", nil, "Only expose these tools (comma-separated)")
+	mcpCmd.Flags().StringSliceVar(&mcpDisable, "disable", nil, "Never expose these tools (comma-separated)")
 	rootCmd.AddCommand(mcpCmd)
 }
 
 func runMCP(cmd *cobra.Command, args []string) error {
-	s := server.NewMCPServer(
-		"ctx",
-		"1.0.0",
+	cfg := loadMCPConfig()
+
+	opts := []server.ServerOption{
 		server.WithToolCapabilities(false),
 		server.WithRecovery(),
-	)
+	}
+	if !cfg.DisableTelemetry {
+		opts = append(opts, server.WithToolHandlerMiddleware(recordToolStats))
+	}
+	s := server.NewMCPServer("ctx", "1.0.0", opts...)
 
-	registerTools(s)
+	registerTools(s, mcpTypes())
+
+	cfg.EnabledTools = append(cfg.EnabledTools, mcpEnable...)
+	cfg.DisabledTools = append(cfg.DisabledTools, mcpDisable...)
+	applyToolFilter(s, cfg)
 
 	return server.ServeStdio(s)
 }
 
-func mcpOpenDB() (db.Store, error) {
+// recordToolStats records each tool call's latency and outcome in the local
+// tool_stats table (see `ctx status --tools`). Recording is best-effort.
+func recordToolStats(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, req)
+		failed := err != nil || (result != nil && result.IsError)
+
+		// The call is recorded even when the client cancelled it
+		if d, dbErr := mcpOpenDB(context.WithoutCancel(ctx)); dbErr == nil {
+			_ = d.RecordToolCall(req.Params.Name, time.Since(start), failed)
+			d.Close()
+		}
+		return result, err
+	}
+}
+
+// mcpOpenDB opens the store for one tool call, bound to the call's ctx so
+// its statements stop when the client cancels it.
+func mcpOpenDB(ctx context.Context) (db.Store, error) {
 	path := dbPath
 	if envDB := os.Getenv("CTX_DB"); envDB != "" && path == "" {
 		path = envDB
 	}
-	return db.Open(path)
+	d, err := db.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return d.WithContext(ctx), nil
 }
 
-func registerTools(s *server.MCPServer) {
+// mcpTypes returns the node and edge types the tools offer: the built-in
+// ones and those registered in the store when the server starts.
+func mcpTypes() []*db.TypeDef {
+	d, err := mcpOpenDB(context.Background())
+	if err != nil {
+		return db.BuiltinTypes()
+	}
+	defer d.Close()
+	types, err := d.ListTypes("")
+	if err != nil {
+		return db.BuiltinTypes()
+	}
+	return types
+}
+
+// typeEnum restricts a tool argument to the types of kind.
+func typeEnum(types []*db.TypeDef, kind string) mcp.PropertyOption {
+	var names []string
+	for _, t := range types {
+		if t.Kind == kind {
+			names = append(names, t.Name)
+		}
+	}
+	return mcp.Enum(names...)
+}
+
+// typeDescription describes a type argument, adding what each registered
+// type of kind is for, since only the built-in ones are documented.
+func typeDescription(types []*db.TypeDef, kind, desc string) mcp.PropertyOption {
+	var custom []string
+	for _, t := range types {
+		if t.Kind == kind && !t.Builtin && t.Description != "" {
+			custom = append(custom, t.Name+": "+t.Description)
+		}
+	}
+	if len(custom) > 0 {
+		desc += ". Custom types: " + strings.Join(custom, "; ")
+	}
+	return mcp.Description(desc)
+}
+
+func registerTools(s *server.MCPServer, types []*db.TypeDef) {
 	// Phase 1: Core tools
 	s.AddTool(mcp.NewTool("ctx_remember",
-		mcp.WithDescription("Store a knowledge node in persistent memory"),
+		mcp.WithDescription("Store a knowledge node in persistent memory. The reply lists existing nodes that look related, to connect with ctx_link"),
 		mcp.WithString("type",
 			mcp.Required(),
-			mcp.Description("Node type"),
-			mcp.Enum("fact", "decision", "pattern", "observation", "hypothesis", "task", "summary", "source", "open-question"),
+			typeDescription(types, db.KindNode, "Node type"),
+			typeEnum(types, db.KindNode),
 		),
 		mcp.WithString("content",
 			mcp.Required(),
@@ -68,19 +167,25 @@ func registerTools(s *server.MCPServer) {
 	), handleRemember)
 
 	s.AddTool(mcp.NewTool("ctx_recall",
-		mcp.WithDescription("Query stored knowledge using the ctx query language. Examples: 'type:fact', 'tag:project:X', 'type:decision AND tag:tier:reference'"),
+		mcp.WithDescription("Query stored knowledge using the ctx query language. Examples: 'type:fact', 'tag:project:X', 'type:decision AND tier:reference', 'project:X AND type:decision' (project X's nodes plus project:global and unscoped ones, as composed for X), 'type:decision AND fts:postgres' (full-text), 'content:\"exact phrase\"' (substring), 'meta:confidence>=0.8' (metadata JSON), 'related:<id> depth:2 via:DEPENDS_ON' (nodes within 2 hops over those edges), 'superseded:true AND has:tags' (superseded nodes still tagged; has: also takes summary, edges and metadata), 'lang:de' (nodes detected as German), 'ref:org/repo#123' (nodes mentioning that issue or PR; 'ref:org/repo' for any in the repo), '@name AND tag:project:X' (a query saved with ctx query save). Append 'sort:created|updated|tokens asc|desc', 'limit:N' and 'offset:N' to order and page results, e.g. 'type:decision sort:updated limit:5',, 'budget:N' to cap the results' total tokens at N, pinned then reference then working nodes first, and 'sample:N' for N random matches, e.g. 'tier:reference AND type:fact sample:5'"),
 		mcp.WithString("query",
 			mcp.Required(),
 			mcp.Description("Query expression (e.g. 'type:fact', 'tag:project:X AND type:decision')"),
 		),
+		mcp.WithNumber("budget",
+			mcp.Description("Token budget for the results, as a budget:N modifier (ignored when the query has one)"),
+		),
 	), handleRecall)
 
 	s.AddTool(mcp.NewTool("ctx_status",
-		mcp.WithDescription("Show database statistics: node counts by type, tier breakdown, token usage"),
+		mcp.WithDescription("Show database statistics (node counts by type, tier breakdown, token usage), the current task and the nodes pinned to it, and health: stale working memory, open questions, unsynced changes, pinned token share, and what needs maintenance"),
 	), handleStatus)
 
 	s.AddTool(mcp.NewTool("ctx_compose",
-		mcp.WithDescription("Compose a markdown document from stored knowledge. Supports query, explicit node IDs, or graph traversal from a seed node."),
+		mcp.WithDescription("Compose a markdown document from stored knowledge. Supports a saved view, query, explicit node IDs, or graph traversal from a seed node. With none of these, composes the default view (what session start injects)."),
+		mcp.WithString("view",
+			mcp.Description("Name of a saved view to compose (see ctx view list)"),
+		),
 		mcp.WithString("query",
 			mcp.Description("Query expression to filter nodes"),
 		),
@@ -94,13 +199,13 @@ func registerTools(s *server.MCPServer) {
 			mcp.Description("Traversal depth for seed mode (default: 1)"),
 		),
 		mcp.WithNumber("budget",
-			mcp.Description("Token budget (default: 50000)"),
+			mcp.Description("Token budget (default: the view's budget, else default_budget)"),
 		),
 		mcp.WithString("template",
 			mcp.Description("Render template: 'default' or 'document'"),
 		),
 		mcp.WithBoolean("edges",
-			mcp.Description("Include relationships between composed nodes (default: false)"),
+			mcp.Description("Show each node's relationships to other composed nodes, e.g. 'Derived from: 01HV3K2M' by short ID (default: false)"),
 		),
 	), handleCompose)
 
@@ -134,6 +239,17 @@ func registerTools(s *server.MCPServer) {
 		),
 	), handleSearch)
 
+	s.AddTool(mcp.NewTool("ctx_semantic_search",
+		mcp.WithDescription("Find memories related in meaning to the text, not just ones sharing its words. Needs an embeddings provider (embeddings.provider in ctx config)"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("Text to find related memories for"),
+		),
+		mcp.WithNumber("k",
+			mcp.Description("Max results to return (default: 10)"),
+		),
+	), handleSemanticSearch)
+
 	s.AddTool(mcp.NewTool("ctx_link",
 		mcp.WithDescription("Create a directed edge between two nodes"),
 		mcp.WithString("from",
@@ -145,8 +261,8 @@ func registerTools(s *server.MCPServer) {
 			mcp.Description("Target node ID"),
 		),
 		mcp.WithString("type",
-			mcp.Description("Edge type (default: RELATES_TO)"),
-			mcp.Enum("DERIVED_FROM", "DEPENDS_ON", "SUPERSEDES", "RELATES_TO", "CHILD_OF"),
+			typeDescription(types, db.KindEdge, "Edge type (default: RELATES_TO)"),
+			typeEnum(types, db.KindEdge),
 		),
 	), handleLink)
 
@@ -224,8 +340,24 @@ func registerTools(s *server.MCPServer) {
 		),
 	), handleSupersede)
 
+	s.AddTool(mcp.NewTool("ctx_delete",
+		mcp.WithDescription("Permanently delete a node and its edges"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Node ID (or unique prefix)"),
+		),
+	), handleDelete)
+
+	s.AddTool(mcp.NewTool("ctx_forget",
+		mcp.WithDescription("Stop loading a node into context by archiving it to tier:off-context"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("Node ID (or unique prefix)"),
+		),
+	), handleForget)
+
 	s.AddTool(mcp.NewTool("ctx_task",
-		mcp.WithDescription("Start or end a task context. Starting adds tier:working tag, ending removes it."),
+		mcp.WithDescription("Start or end a task context. Starting adds tier:working tag, ending removes it and unpins the nodes pinned to the task with ctx task pin."),
 		mcp.WithString("name",
 			mcp.Required(),
 			mcp.Description("Task name"),
@@ -263,7 +395,7 @@ func registerTools(s *server.MCPServer) {
 // Phase 1 handlers
 
 func handleRemember(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -273,15 +405,22 @@ func handleRemember(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	if err := d.Types().NodeType(nodeType); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	content, err := req.RequireString("content")
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
+	content = config.Load().Redact(content)
 
 	var tags []string
 	if t := req.GetString("tags", ""); t != "" {
 		tags = splitAndTrim(t)
+		if err := validate.Tags(tags); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 	}
 
 	input := db.CreateNodeInput{
@@ -299,24 +438,79 @@ func handleRemember(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallTool
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to check duplicates: %v", err)), nil
 	}
+	if existing == nil {
+		existing, err = ingest.FindSplit(d, nodeType, content, ingest.MaxNodeTokens())
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to check duplicates: %v", err)), nil
+		}
+	}
 	if existing != nil {
 		// Merge any new tags onto the existing node
-		for _, tag := range tags {
-			_ = d.AddTag(existing.ID, tag)
-		}
+		_ = d.AddTags(existing.ID, tags)
 		return mcp.NewToolResultText(fmt.Sprintf("Node %s already exists (type: %s, %d tokens) — tags merged", existing.ID, existing.Type, existing.TokenEstimate)), nil
 	}
 
-	node, err := d.CreateNode(input)
+	commit, err := gitlink.Stamp(&input, settings.GitLink)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	node, chunks, err := ingest.CreateNode(d, input, ingest.MaxNodeTokens())
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("failed to create node: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Stored node %s (type: %s, %d tokens)", node.ID, node.Type, node.TokenEstimate)), nil
+	var b strings.Builder
+	if chunks > 0 {
+		fmt.Fprintf(&b, "Content exceeded %d tokens: stored summary stub %s with %d CHILD_OF chunks (type: %s)", ingest.MaxNodeTokens(), node.ID, chunks, node.Type)
+	} else {
+		fmt.Fprintf(&b, "Stored node %s (type: %s, %d tokens)", node.ID, node.Type, node.TokenEstimate)
+	}
+	if commit != nil {
+		if _, err := gitlink.Link(d, node.ID, commit); err != nil {
+			fmt.Fprintf(&b, "\nCommit link failed: %v", err)
+		} else {
+			fmt.Fprintf(&b, "\nLinked to commit %s", commit)
+		}
+	}
+	writeRememberLinks(&b, d, node, content)
+	return mcp.NewToolResultText(b.String()), nil
+}
+
+// rememberSuggestions is how many possibly related nodes ctx_remember
+// lists after storing a node.
+const rememberSuggestions = 3
+
+// writeRememberLinks links a remembered node to the nodes its content
+// mentions by ID (with auto_link on) and lists nodes it may relate to, so
+// the caller can connect it with ctx_link.
+func writeRememberLinks(b *strings.Builder, d db.Store, node *db.Node, content string) {
+	if settings.AutoLink {
+		linked, err := related.LinkRefs(d, node.ID, content)
+		if len(linked) > 0 {
+			fmt.Fprintf(b, "\nLinked %s to: %s", related.EdgeType, strings.Join(linked, ", "))
+		}
+		if err != nil {
+			fmt.Fprintf(b, "\nAuto-link failed: %v", err)
+		}
+	}
+
+	suggestions, err := related.Suggest(d, node, rememberSuggestions)
+	if err != nil || len(suggestions) == 0 {
+		return
+	}
+	b.WriteString("\n\nPossibly related (connect with ctx_link):\n")
+	for _, n := range suggestions {
+		preview := n.Content
+		if len(preview) > 80 {
+			preview = preview[:80] + "..."
+		}
+		fmt.Fprintf(b, "- [%s] %s: %s\n", n.ID, n.Type, preview)
+	}
 }
 
 func handleRecall(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -327,14 +521,25 @@ func handleRecall(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	nodes, err := query.ExecuteQuery(d, queryStr, false)
+	run := queryStr
+	if budget := req.GetInt("budget", 0); budget > 0 {
+		if _, mods, err := query.ParseWithModifiers(queryStr); err == nil && mods.Budget == 0 {
+			run += fmt.Sprintf(" budget:%d", budget)
+		}
+	}
+
+	ctx, cancel := query.WithTimeout(ctx, settings.Timeouts.MCP)
+	defer cancel()
+	nodes, err := query.ExecuteQueryContext(ctx, d, run, false)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("query error: %v", err)), nil
 	}
 
 	if len(nodes) == 0 {
+		_ = usage.RecordMissedRecall(d, queryStr)
 		return mcp.NewToolResultText("No nodes found matching query."), nil
 	}
+	_ = usage.RecordAccess(d, nodes...)
 
 	var b strings.Builder
 	fmt.Fprintf(&b, "Found %d node(s):\n\n", len(nodes))
@@ -350,7 +555,7 @@ func handleRecall(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 }
 
 func handleStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -362,6 +567,9 @@ func handleStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 	_ = d.QueryRow("SELECT COUNT(*) FROM edges").Scan(&edgeCount)
 	_ = d.QueryRow("SELECT COUNT(DISTINCT tag) FROM tags").Scan(&tagCount)
 
+	var staleCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM tags t JOIN nodes n ON t.node_id = n.id WHERE t.tag = ? AND n.superseded_by IS NULL", provenance.StaleTag).Scan(&staleCount)
+
 	type typeCount struct {
 		Type  string `json:"type"`
 		Count int    `json:"count"`
@@ -379,11 +587,6 @@ func handleStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 		typeCounts = append(typeCounts, tc)
 	}
 
-	type tierInfo struct {
-		Tier   string `json:"tier"`
-		Nodes  int    `json:"nodes"`
-		Tokens int    `json:"tokens"`
-	}
 	tierRows, err := d.Query(`SELECT t.tag, COUNT(DISTINCT t.node_id), COALESCE(SUM(n.token_estimate), 0)
 		FROM tags t JOIN nodes n ON t.node_id = n.id
 		WHERE t.tag LIKE 'tier:%' AND n.superseded_by IS NULL
@@ -405,22 +608,149 @@ func handleStatus(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 		"total_tokens": totalTokens,
 		"total_edges":  edgeCount,
 		"unique_tags":  tagCount,
+		"stale_nodes":  staleCount,
 		"types":        typeCounts,
 		"tiers":        tiers,
+		"health":       mcpHealth(d, totalTokens, tiers),
+	}
+	if task := mcpTaskStatus(d); task != nil {
+		out["task"] = task
 	}
 	data, _ := json.MarshalIndent(out, "", "  ")
 	return mcp.NewToolResultText(string(data)), nil
 }
 
+// taskStatus is the task in progress and the nodes pinned to it, as
+// ctx_status reports them.
+type taskStatus struct {
+	Name   string       `json:"name"`
+	Pinned []pinnedNode `json:"pinned"`
+}
+
+type pinnedNode struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// mcpTaskStatus returns the task in progress, or nil when there is none.
+// Pins whose node has since been deleted are left out.
+func mcpTaskStatus(d db.Store) *taskStatus {
+	name, err := hookpkg.CurrentTask(d)
+	if err != nil || name == "" {
+		return nil
+	}
+	status := &taskStatus{Name: name, Pinned: []pinnedNode{}}
+	pins, _ := hookpkg.TaskPins(d)
+	for _, id := range pins {
+		n, err := d.GetNode(id)
+		if err != nil {
+			continue
+		}
+		content := n.Content
+		if len(content) > 80 {
+			content = content[:80] + "..."
+		}
+		status.Pinned = append(status.Pinned, pinnedNode{ID: n.ID, Type: n.Type, Content: content})
+	}
+	return status
+}
+
+type tierInfo struct {
+	Tier   string `json:"tier"`
+	Nodes  int    `json:"nodes"`
+	Tokens int    `json:"tokens"`
+}
+
+const (
+	// staleWorkingAfter is how long a working-tier node can go untouched
+	// before status suggests promoting or archiving it.
+	staleWorkingAfter = 14 * 24 * time.Hour
+	// maxPinnedShare is the share of tokens the pinned tier, injected every
+	// session, can hold before status suggests trimming it.
+	maxPinnedShare = 0.5
+)
+
+type statusHealth struct {
+	OldestWorking    *oldestWorking `json:"oldest_working,omitempty"`
+	OpenQuestions    int            `json:"open_questions"`
+	UnsyncedChanges  int            `json:"unsynced_changes"`
+	SyncConflicts    int            `json:"sync_conflicts"`
+	LastSync         string         `json:"last_sync,omitempty"`
+	PinnedTokenShare float64        `json:"pinned_token_share"`
+	NeedsAttention   []string       `json:"needs_attention"`
+}
+
+type oldestWorking struct {
+	ID            string `json:"id"`
+	UpdatedAt     string `json:"updated_at"`
+	DaysUntouched int    `json:"days_untouched"`
+}
+
+// mcpHealth reports whether memory needs maintenance, with a hint for each
+// problem found.
+func mcpHealth(d db.Store, totalTokens int, tiers []tierInfo) statusHealth {
+	h := statusHealth{NeedsAttention: []string{}}
+
+	var id, updatedAt string
+	err := d.QueryRow(`SELECT n.id, n.updated_at FROM nodes n JOIN tags t ON t.node_id = n.id
+		WHERE t.tag = 'tier:working' AND n.superseded_by IS NULL
+		ORDER BY n.updated_at ASC LIMIT 1`).Scan(&id, &updatedAt)
+	if err == nil {
+		ow := &oldestWorking{ID: id, UpdatedAt: updatedAt}
+		if t, err := time.Parse(time.RFC3339, updatedAt); err == nil {
+			untouched := time.Since(t)
+			ow.DaysUntouched = int(untouched.Hours() / 24)
+			if untouched > staleWorkingAfter {
+				h.NeedsAttention = append(h.NeedsAttention, fmt.Sprintf(
+					"working node %s untouched for %d days: promote it to reference or remove it", id, ow.DaysUntouched))
+			}
+		}
+		h.OldestWorking = ow
+	}
+
+	_ = d.QueryRow("SELECT COUNT(*) FROM nodes WHERE type = 'open-question' AND superseded_by IS NULL").Scan(&h.OpenQuestions)
+	if h.OpenQuestions > 0 {
+		h.NeedsAttention = append(h.NeedsAttention, fmt.Sprintf(
+			"%d open question(s): supersede them with the answer once resolved", h.OpenQuestions))
+	}
+
+	serverURL := configuredServerURL()
+	div := ctxsync.CheckDivergence(d, serverURL)
+	h.UnsyncedChanges, h.SyncConflicts = div.Unsynced, div.Conflicts
+	if serverURL != "" {
+		if state, err := ctxsync.LoadSyncState(serverURL); err == nil {
+			h.LastSync = max(state.LastPushAt, state.LastPullAt)
+		}
+	}
+	if h.UnsyncedChanges > 0 {
+		h.NeedsAttention = append(h.NeedsAttention, fmt.Sprintf("%d local change(s) not pushed: ctx sync push", h.UnsyncedChanges))
+	}
+	if h.SyncConflicts > 0 {
+		h.NeedsAttention = append(h.NeedsAttention, fmt.Sprintf("%d sync conflict(s) where the local copy was kept", h.SyncConflicts))
+	}
+
+	for _, ti := range tiers {
+		if ti.Tier == "tier:pinned" && totalTokens > 0 {
+			h.PinnedTokenShare = math.Round(float64(ti.Tokens)/float64(totalTokens)*100) / 100
+		}
+	}
+	if h.PinnedTokenShare > maxPinnedShare {
+		h.NeedsAttention = append(h.NeedsAttention, fmt.Sprintf(
+			"pinned tier holds %.0f%% of tokens and is injected every session: demote what isn't needed each time", h.PinnedTokenShare*100))
+	}
+	return h
+}
+
 func handleCompose(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
 	defer d.Close()
 
+	viewName := req.GetString("view", "")
 	queryStr := req.GetString("query", "")
-	budget := req.GetInt("budget", 50000)
 	idsStr := req.GetString("ids", "")
 	seedID := req.GetString("seed", "")
 	depth := req.GetInt("depth", 1)
@@ -429,12 +759,23 @@ func handleCompose(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 
 	opts := view.ComposeOptions{
 		Query:        queryStr,
-		Budget:       budget,
+		Budget:       settings.DefaultBudget,
 		SeedID:       seedID,
 		Depth:        depth,
 		IncludeEdges: edges,
+		Translate:    view.TranslationFor(settings),
 	}
 
+	// A named view, or the default view when nothing else selects nodes, is
+	// composed (and laid out) the way session start composes it.
+	var layout view.Layout
+	if viewName != "" || (queryStr == "" && idsStr == "" && seedID == "") {
+		if layout, err = applyMCPView(d, viewName, &opts); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+	opts.Budget = req.GetInt("budget", opts.Budget)
+
 	if idsStr != "" {
 		ids := strings.Split(idsStr, ",")
 		for i := range ids {
@@ -443,10 +784,14 @@ func handleCompose(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 		opts.IDs = ids
 	}
 
-	result, err := view.Compose(d, opts)
+	ctx, cancel := query.WithTimeout(ctx, settings.Timeouts.MCP)
+	defer cancel()
+	result, err := view.ComposeContext(ctx, d, opts)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("compose error: %v", err)), nil
 	}
+	_ = usage.RecordAccess(d, result.Nodes...)
+	result.Layout = layout
 
 	if templateName != "" {
 		return mcp.NewToolResultText(view.RenderTemplate(result, templateName)), nil
@@ -455,10 +800,36 @@ func handleCompose(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 	return mcp.NewToolResultText(view.RenderMarkdown(result)), nil
 }
 
+// applyMCPView sets opts' query and budget from a saved view, scoped to the
+// session's project and agent, and returns the view's layout. Without a
+// name it uses the configured default view, falling back to the injected
+// tiers like session start.
+func applyMCPView(d db.Store, name string, opts *view.ComposeOptions) (view.Layout, error) {
+	lookup := name
+	if lookup == "" {
+		lookup = settings.DefaultView
+	}
+	var layout view.Layout
+	saved, err := view.Get(d, lookup)
+	if err != nil {
+		if name != "" {
+			return layout, fmt.Errorf("view %q not found", name)
+		}
+		opts.Query = settings.InjectQuery()
+		opts.Budget = settings.DefaultBudget
+	} else {
+		opts.Query, opts.Budget, layout = saved.Query, saved.Budget, saved.Layout
+	}
+	opts.Project, _ = d.GetPending("current_project")
+	opts.Agent, _ = d.GetPending("current_agent")
+	opts.TaskPins, _ = hookpkg.TaskPins(d)
+	return layout, nil
+}
+
 // Phase 2 handlers
 
 func handleShow(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -468,15 +839,16 @@ func handleShow(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	id, err := d.ResolveID(idArg)
+	id, err := resolveMCPID(d, "ID", idArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve ID %q: %v", idArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	node, err := d.GetNode(id)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("node not found: %v", err)), nil
 	}
+	_ = usage.RecordAccess(d, node)
 
 	out := map[string]interface{}{
 		"id":             node.ID,
@@ -504,7 +876,7 @@ func handleShow(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 }
 
 func handleList(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -543,7 +915,7 @@ func handleList(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 }
 
 func handleSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -576,8 +948,51 @@ func handleSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 	return mcp.NewToolResultText(b.String()), nil
 }
 
+func handleSemanticSearch(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	queryStr, err := req.RequireString("query")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	e, err := embeddings.New(settings.Embeddings)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	d, err := mcpOpenDB(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
+	}
+	defer d.Close()
+
+	ctx, cancel := query.WithTimeout(ctx, settings.Timeouts.MCP)
+	defer cancel()
+	if _, err := embeddings.Index(ctx, d, e, false); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("embedding error: %v", err)), nil
+	}
+	hits, err := d.WithContext(ctx).SemanticSearch(e, queryStr, req.GetInt("k", 10))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("search error: %v", err)), nil
+	}
+
+	if len(hits) == 0 {
+		return mcp.NewToolResultText("No results found."), nil
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Found %d result(s):\n\n", len(hits))
+	for _, h := range hits {
+		fmt.Fprintf(&b, "### [%s] %s (similarity %.2f)\n", h.ID, h.Type, h.Score)
+		if len(h.Tags) > 0 {
+			fmt.Fprintf(&b, "Tags: %s\n", strings.Join(h.Tags, ", "))
+		}
+		fmt.Fprintf(&b, "\n%s\n\n---\n\n", h.Content)
+	}
+
+	return mcp.NewToolResultText(b.String()), nil
+}
+
 func handleLink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -592,16 +1007,19 @@ func handleLink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	fromID, err := d.ResolveID(fromArg)
+	fromID, err := resolveMCPID(d, "from ID", fromArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve from ID %q: %v", fromArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-	toID, err := d.ResolveID(toArg)
+	toID, err := resolveMCPID(d, "to ID", toArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve to ID %q: %v", toArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	edgeType := req.GetString("type", "RELATES_TO")
+	if err := d.Types().EdgeType(edgeType); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	edge, err := d.CreateEdge(fromID, toID, edgeType)
 	if err != nil {
@@ -612,7 +1030,7 @@ func handleLink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 }
 
 func handleUnlink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -627,13 +1045,13 @@ func handleUnlink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	fromID, err := d.ResolveID(fromArg)
+	fromID, err := resolveMCPID(d, "from ID", fromArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve from ID %q: %v", fromArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-	toID, err := d.ResolveID(toArg)
+	toID, err := resolveMCPID(d, "to ID", toArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve to ID %q: %v", toArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	edgeType := req.GetString("type", "")
@@ -646,7 +1064,7 @@ func handleUnlink(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRe
 }
 
 func handleTag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -656,9 +1074,9 @@ func handleTag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResul
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	id, err := d.ResolveID(idArg)
+	id, err := resolveMCPID(d, "ID", idArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve ID %q: %v", idArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 	tagsStr, err := req.RequireString("tags")
 	if err != nil {
@@ -666,17 +1084,18 @@ func handleTag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResul
 	}
 
 	tags := splitAndTrim(tagsStr)
-	for _, tag := range tags {
-		if err := d.AddTag(id, tag); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to add tag %s: %v", tag, err)), nil
-		}
+	if err := validate.Tags(tags); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := d.AddTags(id, tags); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to add tags: %v", err)), nil
 	}
 
 	return mcp.NewToolResultText(fmt.Sprintf("Tagged %s with: %s", id, strings.Join(tags, ", "))), nil
 }
 
 func handleUntag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -686,9 +1105,9 @@ func handleUntag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	id, err := d.ResolveID(idArg)
+	id, err := resolveMCPID(d, "ID", idArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve ID %q: %v", idArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 	tagsStr, err := req.RequireString("tags")
 	if err != nil {
@@ -696,17 +1115,15 @@ func handleUntag(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 	}
 
 	tags := splitAndTrim(tagsStr)
-	for _, tag := range tags {
-		if err := d.RemoveTag(id, tag); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("failed to remove tag %s: %v", tag, err)), nil
-		}
+	if err := d.RemoveTags(id, tags); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("failed to remove tags: %v", err)), nil
 	}
 
 	return mcp.NewToolResultText(fmt.Sprintf("Removed tags from %s: %s", id, strings.Join(tags, ", "))), nil
 }
 
 func handleTags(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -734,7 +1151,7 @@ func handleTags(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 // Phase 3 handlers
 
 func handleSummarize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -754,9 +1171,9 @@ func handleSummarize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 
 	// Resolve short ID prefixes
 	for i, sid := range sourceIDs {
-		resolved, resolveErr := d.ResolveID(sid)
+		resolved, resolveErr := resolveMCPID(d, "node ID", sid)
 		if resolveErr != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("cannot resolve node ID %q: %v", sid, resolveErr)), nil
+			return mcp.NewToolResultError(resolveErr.Error()), nil
 		}
 		sourceIDs[i] = resolved
 	}
@@ -774,10 +1191,7 @@ func handleSummarize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 			return mcp.NewToolResultError(fmt.Sprintf("failed to link to %s: %v", sourceID, err)), nil
 		}
 		if archive {
-			_ = d.RemoveTag(sourceID, "tier:working")
-			_ = d.RemoveTag(sourceID, "tier:reference")
-			_ = d.RemoveTag(sourceID, "tier:pinned")
-			_ = d.AddTag(sourceID, "tier:off-context")
+			_ = d.UpdateTags(sourceID, []string{"tier:off-context"}, []string{"tier:working", "tier:reference", "tier:pinned"})
 		}
 	}
 
@@ -789,7 +1203,7 @@ func handleSummarize(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 }
 
 func handleSupersede(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -804,29 +1218,78 @@ func handleSupersede(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToo
 		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	oldID, err := d.ResolveID(oldArg)
+	oldID, err := resolveMCPID(d, "old ID", oldArg)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	newID, err := resolveMCPID(d, "new ID", newArg)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return applyOrStage(d, approval.OpSupersede, map[string]string{"old": oldID, "new": newID})
+}
+
+func handleDelete(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve old ID %q: %v", oldArg, err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
-	newID, err := d.ResolveID(newArg)
+	defer d.Close()
+
+	idArg, err := req.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	id, err := resolveMCPID(d, "ID", idArg)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	return applyOrStage(d, approval.OpDelete, map[string]string{"id": id})
+}
+
+func handleForget(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve new ID %q: %v", newArg, err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
+	defer d.Close()
 
-	_, execErr := d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newID, oldID)
-	if execErr != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to supersede: %v", execErr)), nil
+	idArg, err := req.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	id, err := resolveMCPID(d, "ID", idArg)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
-	if _, err := d.CreateEdge(newID, oldID, "SUPERSEDES"); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("failed to create SUPERSEDES edge: %v", err)), nil
+	return applyOrStage(d, approval.OpForget, map[string]string{"id": id})
+}
+
+// applyOrStage runs a destructive operation, or stages it for `ctx approve`
+// when approval mode is on and the tool is not auto-approved.
+func applyOrStage(d db.Store, tool string, args map[string]string) (*mcp.CallToolResult, error) {
+	if loadMCPConfig().requiresApproval(tool) {
+		op, err := approval.Stage(d, tool, args)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to stage operation: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf(
+			"Staged operation %s (%s); it will take effect once a human runs: ctx approve %s",
+			op.ID, op.Describe(), op.ID)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Node %s superseded by %s", oldID, newID)), nil
+	result, err := approval.Apply(d, tool, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText(result), nil
 }
 
 func handleTask(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -853,6 +1316,9 @@ func handleTask(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("failed to create task node: %v", err)), nil
 		}
+		if err := hookpkg.StartTask(d, name); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to start task: %v", err)), nil
+		}
 		return mcp.NewToolResultText(fmt.Sprintf("Task '%s' started (node: %s, tagged tier:working)", name, node.ID)), nil
 
 	case "end":
@@ -862,9 +1328,9 @@ func handleTask(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 			return mcp.NewToolResultError(fmt.Sprintf("failed to find task nodes: %v", err)), nil
 		}
 		for _, n := range nodes {
-			_ = d.RemoveTag(n.ID, "tier:working")
-			_ = d.AddTag(n.ID, "tier:reference")
+			_ = d.UpdateTags(n.ID, []string{"tier:reference"}, []string{"tier:working"})
 		}
+		_ = hookpkg.EndTask(d, name)
 		return mcp.NewToolResultText(fmt.Sprintf("Task '%s' ended (%d node(s) moved to tier:reference)", name, len(nodes))), nil
 
 	default:
@@ -873,7 +1339,7 @@ func handleTask(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResu
 }
 
 func handleRelated(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -883,9 +1349,9 @@ func handleRelated(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	id, err := d.ResolveID(idArg)
+	id, err := resolveMCPID(d, "ID", idArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve ID %q: %v", idArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 	depth := req.GetInt("depth", 1)
 
@@ -938,7 +1404,7 @@ func handleRelated(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolR
 }
 
 func handleTrace(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	d, err := mcpOpenDB()
+	d, err := mcpOpenDB(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("database error: %v", err)), nil
 	}
@@ -948,9 +1414,9 @@ func handleTrace(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 	if err != nil {
 		return mcp.NewToolResultError(err.Error()), nil
 	}
-	id, err := d.ResolveID(idArg)
+	id, err := resolveMCPID(d, "ID", idArg)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("cannot resolve ID %q: %v", idArg, err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 	reverse := req.GetBool("reverse", false)
 
@@ -1010,6 +1476,19 @@ func handleTrace(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolRes
 
 // helpers
 
+// resolveMCPID checks that arg looks like an ID before resolving it to a
+// full node ID; what names the argument in errors ("ID", "from ID", ...).
+func resolveMCPID(d db.Store, what, arg string) (string, error) {
+	if err := validate.IDPrefix(arg); err != nil {
+		return "", err
+	}
+	id, err := d.ResolveID(arg)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s %q: %v", what, arg, err)
+	}
+	return id, nil
+}
+
 func splitAndTrim(s string) []string {
 	parts := strings.Split(s, ",")
 	var result []string
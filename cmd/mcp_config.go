@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/server"
+	"gopkg.in/yaml.v3"
+)
+
+// mcpConfig holds MCP server settings from ~/.ctx/mcp.yaml.
+type mcpConfig struct {
+	// ApprovalMode stages destructive tool calls (ctx_delete, ctx_supersede,
+	// ctx_forget) as pending operations for `ctx approve` instead of
+	// applying them. CTX_MCP_APPROVAL overrides it.
+	ApprovalMode bool `yaml:"approval_mode"`
+	// AutoApprove lists destructive tools that still apply immediately in
+	// approval mode.
+	AutoApprove []string `yaml:"auto_approve"`
+	// EnabledTools, when non-empty, is the complete set of tools exposed to
+	// the model. Names may omit the "ctx_" prefix.
+	EnabledTools []string `yaml:"enabled_tools"`
+	// DisabledTools are never exposed, even if listed in EnabledTools.
+	DisabledTools []string `yaml:"disabled_tools"`
+	// DisableTelemetry stops recording per-tool call counts and latencies.
+	DisableTelemetry bool `yaml:"disable_telemetry"`
+}
+
+func mcpConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ctx", "mcp.yaml"), nil
+}
+
+// loadMCPConfig reads ~/.ctx/mcp.yaml. A missing or unreadable file yields
+// the zero config.
+func loadMCPConfig() mcpConfig {
+	var cfg mcpConfig
+	if path, err := mcpConfigPath(); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			_ = yaml.Unmarshal(data, &cfg)
+		}
+	}
+	if env := os.Getenv("CTX_MCP_APPROVAL"); env != "" {
+		cfg.ApprovalMode = env == "true" || env == "1"
+	}
+	return cfg
+}
+
+// toolName normalizes a configured tool name, accepting "delete" for
+// "ctx_delete".
+func toolName(name string) string {
+	name = strings.TrimSpace(name)
+	if !strings.HasPrefix(name, "ctx_") {
+		name = "ctx_" + name
+	}
+	return name
+}
+
+// toolEnabled reports whether a tool should be registered.
+func (c mcpConfig) toolEnabled(tool string) bool {
+	for _, t := range c.DisabledTools {
+		if toolName(t) == tool {
+			return false
+		}
+	}
+	if len(c.EnabledTools) == 0 {
+		return true
+	}
+	for _, t := range c.EnabledTools {
+		if toolName(t) == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// applyToolFilter removes tools the config disables from s. Unknown tool
+// names are reported on stderr so typos don't silently leave a tool exposed.
+func applyToolFilter(s *server.MCPServer, cfg mcpConfig) {
+	registered := s.ListTools()
+	for _, t := range append(append([]string{}, cfg.EnabledTools...), cfg.DisabledTools...) {
+		if _, ok := registered[toolName(t)]; !ok {
+			fmt.Fprintf(os.Stderr, "ctx mcp: warning: unknown tool %q in configuration\n", t)
+		}
+	}
+
+	var disabled []string
+	for name := range registered {
+		if !cfg.toolEnabled(name) {
+			disabled = append(disabled, name)
+		}
+	}
+	if len(disabled) > 0 {
+		s.DeleteTools(disabled...)
+	}
+}
+
+// requiresApproval reports whether a destructive tool call must be staged.
+func (c mcpConfig) requiresApproval(tool string) bool {
+	if !c.ApprovalMode {
+		return false
+	}
+	for _, t := range c.AutoApprove {
+		if t == tool {
+			return false
+		}
+	}
+	return true
+}
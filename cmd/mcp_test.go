@@ -2,17 +2,23 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
 )
 
 func setupMCPTest(t *testing.T) {
 	t.Helper()
+	t.Setenv("HOME", t.TempDir())
 	dbPath = filepath.Join(t.TempDir(), "test.db")
 	// Open once to run migrations
 	d, err := db.Open(dbPath)
@@ -82,6 +88,34 @@ func TestHandleRecall(t *testing.T) {
 	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "Testing recall functionality")
 }
 
+func TestHandleRecall_Budget(t *testing.T) {
+	setupMCPTest(t)
+
+	for _, tier := range []string{"pinned", "reference"} {
+		_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
+			"type":    "fact",
+			"content": tier + " budget fact",
+			"tags":    "tier:" + tier,
+		}))
+	}
+
+	recall := func(args map[string]interface{}) string {
+		t.Helper()
+		result, err := handleRecall(context.Background(), makeReq(args))
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	text := recall(map[string]interface{}{"query": "type:fact", "budget": 5})
+	assert.Contains(t, text, "pinned budget fact")
+	assert.NotContains(t, text, "reference budget fact")
+
+	// A budget in the query wins
+	text = recall(map[string]interface{}{"query": "type:fact budget:1000", "budget": 5})
+	assert.Contains(t, text, "reference budget fact")
+}
+
 func TestHandleRecall_NoResults(t *testing.T) {
 	setupMCPTest(t)
 
@@ -117,13 +151,47 @@ func TestHandleStatus(t *testing.T) {
 	assert.Contains(t, text, "decision")
 }
 
+func TestHandleStatus_Health(t *testing.T) {
+	setupMCPTest(t)
+
+	res, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "observation", "content": "half-finished investigation", "tags": "tier:working",
+	}))
+	workingID := extractNodeID(res.Content[0].(mcp.TextContent).Text)
+	_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "open-question", "content": "why does the build flake?",
+	}))
+	_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "decision", "content": "a long pinned decision that dominates the token budget", "tags": "tier:pinned",
+	}))
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET updated_at = ? WHERE id = ?", time.Now().AddDate(0, 0, -30).UTC().Format(time.RFC3339), workingID)
+	require.NoError(t, err)
+	d.Close()
+
+	result, err := handleStatus(context.Background(), makeReq(map[string]interface{}{}))
+	require.NoError(t, err)
+	var out struct {
+		Health statusHealth `json:"health"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &out))
+	h := out.Health
+	require.NotNil(t, h.OldestWorking)
+	assert.Equal(t, workingID, h.OldestWorking.ID)
+	assert.Equal(t, 30, h.OldestWorking.DaysUntouched)
+	assert.Equal(t, 1, h.OpenQuestions)
+	assert.Greater(t, h.PinnedTokenShare, 0.5)
+	assert.Len(t, h.NeedsAttention, 3)
+}
+
 func TestHandleCompose(t *testing.T) {
 	setupMCPTest(t)
 
 	_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
 		"type":    "fact",
 		"content": "composed fact",
-		"tags":    "tier:reference",
+		"tags":    "tier:pinned",
 	}))
 
 	result, err := handleCompose(context.Background(), makeReq(map[string]interface{}{}))
@@ -132,6 +200,46 @@ func TestHandleCompose(t *testing.T) {
 	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "composed fact")
 }
 
+func TestHandleCompose_Views(t *testing.T) {
+	setupMCPTest(t)
+
+	_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "fact", "content": "pinned fact", "tags": "tier:pinned",
+	}))
+	_, _ = handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "decision", "content": "reference decision", "tags": "tier:reference",
+	}))
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	_, err = d.Exec(`INSERT INTO views (name, query, budget, created_at, updated_at) VALUES ('decisions', 'type:decision', 1000, '', '')`)
+	require.NoError(t, err)
+	d.Close()
+
+	// No selector composes the default view
+	result, err := handleCompose(context.Background(), makeReq(map[string]interface{}{}))
+	require.NoError(t, err)
+	text := result.Content[0].(mcp.TextContent).Text
+	assert.Contains(t, text, "pinned fact")
+	assert.NotContains(t, text, "reference decision")
+
+	result, err = handleCompose(context.Background(), makeReq(map[string]interface{}{"view": "decisions"}))
+	require.NoError(t, err)
+	text = result.Content[0].(mcp.TextContent).Text
+	assert.Contains(t, text, "reference decision")
+	assert.NotContains(t, text, "pinned fact")
+
+	result, err = handleCompose(context.Background(), makeReq(map[string]interface{}{"view": "missing"}))
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	// An explicit query composes everything it matches
+	result, err = handleCompose(context.Background(), makeReq(map[string]interface{}{"query": "type:fact OR type:decision"}))
+	require.NoError(t, err)
+	text = result.Content[0].(mcp.TextContent).Text
+	assert.Contains(t, text, "pinned fact")
+	assert.Contains(t, text, "reference decision")
+}
+
 func TestHandleShow(t *testing.T) {
 	setupMCPTest(t)
 
@@ -153,6 +261,40 @@ func TestHandleShow(t *testing.T) {
 	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "show me")
 }
 
+func TestHandlers_RecordAccess(t *testing.T) {
+	setupMCPTest(t)
+
+	remResult, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type":    "fact",
+		"content": "read me",
+		"tags":    "tier:pinned",
+	}))
+	nodeID := extractNodeID(remResult.Content[0].(mcp.TextContent).Text)
+
+	for _, call := range []func() (*mcp.CallToolResult, error){
+		func() (*mcp.CallToolResult, error) {
+			return handleRecall(context.Background(), makeReq(map[string]interface{}{"query": "type:fact"}))
+		},
+		func() (*mcp.CallToolResult, error) {
+			return handleCompose(context.Background(), makeReq(map[string]interface{}{}))
+		},
+		func() (*mcp.CallToolResult, error) {
+			return handleShow(context.Background(), makeReq(map[string]interface{}{"id": nodeID}))
+		},
+	} {
+		result, err := call()
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+	}
+
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	defer d.Close()
+	access, err := d.GetNodeAccess(nodeID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, access.AccessCount, "recall, compose and show each count")
+}
+
 func TestHandleShow_NotFound(t *testing.T) {
 	setupMCPTest(t)
 
@@ -321,6 +463,59 @@ func TestHandleSupersede(t *testing.T) {
 	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "superseded by")
 }
 
+func TestHandleDelete(t *testing.T) {
+	setupMCPTest(t)
+
+	r, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "fact", "content": "doomed fact",
+	}))
+	id := extractNodeID(r.Content[0].(mcp.TextContent).Text)
+
+	result, err := handleDelete(context.Background(), makeReq(map[string]interface{}{"id": id}))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "Deleted node")
+}
+
+func TestHandleDelete_ApprovalModeStages(t *testing.T) {
+	setupMCPTest(t)
+	t.Setenv("CTX_MCP_APPROVAL", "true")
+
+	r, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "fact", "content": "protected fact",
+	}))
+	id := extractNodeID(r.Content[0].(mcp.TextContent).Text)
+
+	result, err := handleDelete(context.Background(), makeReq(map[string]interface{}{"id": id}))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "ctx approve")
+
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	defer d.Close()
+	_, err = d.GetNode(id)
+	assert.NoError(t, err, "node must survive until approved")
+}
+
+func TestHandleForget_AutoApproved(t *testing.T) {
+	setupMCPTest(t)
+	home := os.Getenv("HOME")
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".ctx"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".ctx", "mcp.yaml"),
+		[]byte("approval_mode: true\nauto_approve: [ctx_forget]\n"), 0644))
+
+	r, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "fact", "content": "stale fact", "tags": "tier:pinned",
+	}))
+	id := extractNodeID(r.Content[0].(mcp.TextContent).Text)
+
+	result, err := handleForget(context.Background(), makeReq(map[string]interface{}{"id": id}))
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "tier:off-context")
+}
+
 func TestHandleTask(t *testing.T) {
 	setupMCPTest(t)
 
@@ -343,6 +538,47 @@ func TestHandleTask(t *testing.T) {
 	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "ended")
 }
 
+func TestHandleStatus_TaskPins(t *testing.T) {
+	setupMCPTest(t)
+
+	res, _ := handleRemember(context.Background(), makeReq(map[string]interface{}{
+		"type": "fact", "content": "the auth spec lives in docs/auth.md", "tags": "tier:reference",
+	}))
+	nodeID := extractNodeID(res.Content[0].(mcp.TextContent).Text)
+	_, err := handleTask(context.Background(), makeReq(map[string]interface{}{"name": "auth", "action": "start"}))
+	require.NoError(t, err)
+
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	_, err = hookpkg.PinToTask(d, nodeID)
+	require.NoError(t, err)
+	d.Close()
+
+	status := func() *taskStatus {
+		result, err := handleStatus(context.Background(), makeReq(map[string]interface{}{}))
+		require.NoError(t, err)
+		var out struct {
+			Task *taskStatus `json:"task"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &out))
+		return out.Task
+	}
+	task := status()
+	require.NotNil(t, task)
+	assert.Equal(t, "auth", task.Name)
+	require.Len(t, task.Pinned, 1)
+	assert.Equal(t, nodeID, task.Pinned[0].ID)
+
+	// The default compose includes the pin though the view leaves out reference
+	result, err := handleCompose(context.Background(), makeReq(map[string]interface{}{}))
+	require.NoError(t, err)
+	assert.Contains(t, result.Content[0].(mcp.TextContent).Text, "docs/auth.md")
+
+	_, err = handleTask(context.Background(), makeReq(map[string]interface{}{"name": "auth", "action": "end"}))
+	require.NoError(t, err)
+	assert.Nil(t, status(), "no task once it ends")
+}
+
 func TestHandleRelated(t *testing.T) {
 	setupMCPTest(t)
 
@@ -421,3 +657,86 @@ func extractNodeID(text string) string {
 	}
 	return text[start:end]
 }
+
+func newTestMCPServer() *server.MCPServer {
+	s := server.NewMCPServer("ctx", "test", server.WithToolCapabilities(false))
+	registerTools(s, db.BuiltinTypes())
+	return s
+}
+
+func TestRegisterTools_CustomTypes(t *testing.T) {
+	setupMCPTest(t)
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	_, err = d.RegisterType(db.KindNode, "incident", "An outage and its fix")
+	require.NoError(t, err)
+	d.Close()
+
+	s := server.NewMCPServer("ctx", "test", server.WithToolCapabilities(false))
+	registerTools(s, mcpTypes())
+	arg := s.GetTool("ctx_remember").Tool.InputSchema.Properties["type"].(map[string]any)
+	assert.Contains(t, arg["enum"], "incident")
+	assert.Contains(t, arg["enum"], "fact")
+	assert.Contains(t, arg["description"], "incident: An outage and its fix")
+
+	arg = s.GetTool("ctx_link").Tool.InputSchema.Properties["type"].(map[string]any)
+	assert.NotContains(t, arg["enum"], "incident")
+}
+
+func TestApplyToolFilter_Disable(t *testing.T) {
+	s := newTestMCPServer()
+
+	applyToolFilter(s, mcpConfig{DisabledTools: []string{"delete", "ctx_link"}})
+
+	assert.Nil(t, s.GetTool("ctx_delete"))
+	assert.Nil(t, s.GetTool("ctx_link"))
+	assert.NotNil(t, s.GetTool("ctx_remember"))
+}
+
+func TestApplyToolFilter_EnableAllowlist(t *testing.T) {
+	s := newTestMCPServer()
+
+	applyToolFilter(s, mcpConfig{
+		EnabledTools:  []string{"remember", "recall", "search"},
+		DisabledTools: []string{"search"},
+	})
+
+	tools := s.ListTools()
+	assert.Len(t, tools, 2)
+	assert.NotNil(t, s.GetTool("ctx_remember"))
+	assert.NotNil(t, s.GetTool("ctx_recall"))
+}
+
+func TestLoadMCPConfig_FromFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".ctx"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".ctx", "mcp.yaml"),
+		[]byte("disabled_tools:\n  - ctx_delete\n  - unlink\n"), 0644))
+
+	cfg := loadMCPConfig()
+
+	assert.False(t, cfg.toolEnabled("ctx_delete"))
+	assert.False(t, cfg.toolEnabled("ctx_unlink"))
+	assert.True(t, cfg.toolEnabled("ctx_show"))
+}
+
+func TestRecordToolStats(t *testing.T) {
+	setupMCPTest(t)
+
+	wrapped := recordToolStats(handleStatus)
+	req := makeReq(nil)
+	req.Params.Name = "ctx_status"
+	_, err := wrapped(context.Background(), req)
+	require.NoError(t, err)
+
+	d, err := db.Open(dbPath)
+	require.NoError(t, err)
+	defer d.Close()
+	stats, err := d.ListToolStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "ctx_status", stats[0].Tool)
+	assert.Equal(t, 1, stats[0].Calls)
+	assert.Equal(t, 0, stats[0].Errors)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/auth"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/server"
+)
+
+var openPrint bool
+
+var openCmd = &cobra.Command{
+	Use:   "open [id]",
+	Short: "Open a node (or the dashboard) in the admin web UI",
+	Long: `Open the admin web UI in the browser: the node page for the given ID, or
+the dashboard without one.
+
+Uses the configured remote server (see "ctx remote set"). Without one, a
+local server is started on 127.0.0.1 against the local database and runs
+until interrupted.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runOpen,
+}
+
+func init() {
+	openCmd.Flags().BoolVar(&openPrint, "print", false, "Print the URL instead of launching a browser")
+	rootCmd.AddCommand(openCmd)
+}
+
+func runOpen(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	id := ""
+	if len(args) > 0 {
+		if id, err = resolveArg(d, args[0]); err != nil {
+			return err
+		}
+	}
+
+	if base := configuredServerURL(); base != "" {
+		launchURL(adminURL(base, id))
+		return nil
+	}
+
+	return serveLocalUI(d, "127.0.0.1:0", id)
+}
+
+// configuredServerURL returns the remote server URL from ~/.ctx/remote.json,
+// falling back to the server recorded at login, or "" if neither is set.
+func configuredServerURL() string {
+	if cfg, err := loadRemoteConfig(); err == nil && cfg.URL != "" {
+		return cfg.URL
+	}
+	if cfg, err := loadAuthConfig(); err == nil && cfg.ServerURL != "" {
+		return cfg.ServerURL
+	}
+	return ""
+}
+
+// adminURL returns the admin UI page for a node, or the dashboard if id is empty.
+func adminURL(base, id string) string {
+	base = strings.TrimRight(base, "/")
+	if id == "" {
+		return base + "/admin"
+	}
+	return base + "/admin/nodes/" + id
+}
+
+func launchURL(url string) {
+	if openPrint {
+		fmt.Println(url)
+		return
+	}
+	fmt.Printf("Opening %s\n", url)
+	openBrowser(url)
+}
+
+// serveLocalUI serves the admin UI for d on addr without a password, opens
+// the page for id, and blocks until interrupted. Only the browser it opens
+// can use the server: the launched URL carries a token made for this run,
+// and requests addressed to any host but the loopback one are refused.
+func serveLocalUI(d db.Store, addr, id string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to start local server: %w", err)
+	}
+
+	cfg := server.DefaultConfig()
+	cfg.Timezone, cfg.RelativeTimes = settings.Display.Timezone, settings.Display.RelativeTimes
+	cfg.LocalToken = auth.GenerateToken()
+	srv := &http.Server{Handler: server.New(d, cfg).Handler()}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "Serving local admin UI on http://%s (Ctrl-C to stop)\n", ln.Addr())
+	launchURL(adminURL("http://"+ln.Addr().String(), id) + "?token=" + cfg.LocalToken)
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
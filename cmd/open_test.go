@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminURL(t *testing.T) {
+	assert.Equal(t, "http://localhost:8377/admin", adminURL("http://localhost:8377/", ""))
+	assert.Equal(t, "https://ctx.example.com/admin/nodes/01ABC", adminURL("https://ctx.example.com", "01ABC"))
+}
+
+func TestConfiguredServerURL(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	assert.Equal(t, "", configuredServerURL())
+
+	require.NoError(t, saveAuthConfig(&authConfig{ServerURL: "http://auth.example"}))
+	assert.Equal(t, "http://auth.example", configuredServerURL())
+}
@@ -1,24 +1,60 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
 	"github.com/zate/ctx/internal/query"
 )
 
-var includeSuperseded bool
+var (
+	includeSuperseded bool
+	queryCountBy      string
+)
 
 var queryCmd = &cobra.Command{
 	Use:   "query <expression>",
 	Short: "Query nodes with structured filters",
+	Long: `Query nodes with structured filters. Saved queries can be used inside
+an expression as @name, e.g. ctx query "@open-decisions AND tag:project:ctx".
+
+With --count-by, count the matching nodes and sum their tokens per type,
+tag (or tags under a prefix, as tag:project), or month created or updated;
+the expression may then be left out to count every node.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runQuery,
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save <name> <expression>",
+	Short: "Save a query expression for use as @name",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runQuerySave,
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved queries",
+	Args:  cobra.NoArgs,
+	RunE:  runQueryList,
+}
+
+var queryDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a saved query",
 	Args:  cobra.ExactArgs(1),
-	RunE:  runQuery,
+	RunE:  runQueryDelete,
 }
 
 func init() {
 	queryCmd.Flags().BoolVar(&includeSuperseded, "include-superseded", false, "Include superseded nodes")
+	queryCmd.Flags().StringVar(&queryCountBy, "count-by", "", "Count matches per type, tag, tag:<prefix>, created or updated")
+	addTimeoutFlag(queryCmd)
+	queryCmd.AddCommand(querySaveCmd, queryListCmd, queryDeleteCmd)
 	rootCmd.AddCommand(queryCmd)
 }
 
@@ -29,7 +65,19 @@ func runQuery(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	nodes, err := query.ExecuteQuery(d, args[0], includeSuperseded)
+	var expr string
+	if len(args) == 1 {
+		expr = args[0]
+	} else if queryCountBy == "" {
+		return fmt.Errorf("requires a query expression")
+	}
+
+	ctx, cancel := queryContext(cmd)
+	defer cancel()
+	if queryCountBy != "" {
+		return runQueryCount(ctx, d, expr)
+	}
+	nodes, err := query.ExecuteQueryContext(ctx, d, expr, includeSuperseded)
 	if err != nil {
 		return err
 	}
@@ -61,3 +109,97 @@ func runQuery(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func runQueryCount(ctx context.Context, d db.Store, expr string) error {
+	groups, err := query.Aggregate(ctx, d, expr, queryCountBy, includeSuperseded)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(groups, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(groups) == 0 {
+			fmt.Println("No nodes found.")
+			return nil
+		}
+		width := len(queryCountBy)
+		for _, g := range groups {
+			width = max(width, len(g.Key))
+		}
+		fmt.Printf("%-*s  %6s  %8s\n", width, strings.ToUpper(queryCountBy), "NODES", "TOKENS")
+		var count, tokens int
+		for _, g := range groups {
+			fmt.Printf("%-*s  %6d  %8d\n", width, g.Key, g.Count, g.Tokens)
+			count += g.Count
+			tokens += g.Tokens
+		}
+		if len(groups) > 1 && (queryCountBy == "type" || queryCountBy == "created" || queryCountBy == "updated") {
+			fmt.Printf("%-*s  %6d  %8d\n", width, "total", count, tokens)
+		}
+	}
+	return nil
+}
+
+func runQuerySave(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	name := strings.TrimPrefix(args[0], "@")
+	if err := query.SaveQuery(d, name, args[1]); err != nil {
+		return err
+	}
+	fmt.Printf("Saved query @%s: %s\n", name, args[1])
+	return nil
+}
+
+func runQueryList(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	saved, err := query.ListSaved(d)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if saved == nil {
+			saved = []*query.Saved{}
+		}
+		data, _ := json.MarshalIndent(saved, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(saved) == 0 {
+			fmt.Println("No saved queries.")
+			return nil
+		}
+		for _, s := range saved {
+			fmt.Printf("@%s: %s\n", s.Name, s.Query)
+		}
+	}
+	return nil
+}
+
+func runQueryDelete(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	name := strings.TrimPrefix(args[0], "@")
+	if err := query.DeleteSaved(d, name); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted query @%s\n", name)
+	return nil
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/ingest"
+)
+
+var quickCmd = &cobra.Command{
+	Use:   "quick [text...]",
+	Short: "Capture a note and print only its ID",
+	Long: `Store a note with no prompts and no output but the node ID, for
+launchers (Alfred, Raycast) and shell aliases. Reads stdin when no text is
+given.
+
+The note is an observation in tier:reference, tagged with the current git
+repository's project (or --project). Tags passed with --tag replace these
+defaults for their namespace. Capturing the same text again prints the
+existing node's ID.`,
+	SilenceUsage: true,
+	RunE:         runQuick,
+}
+
+var (
+	quickType    string
+	quickTags    []string
+	quickTier    string
+	quickProject string
+)
+
+func init() {
+	quickCmd.Flags().StringVar(&quickType, "type", "observation", "Node type")
+	quickCmd.Flags().StringArrayVar(&quickTags, "tag", nil, "Tags (repeatable)")
+	quickCmd.Flags().StringVar(&quickTier, "tier", "reference", "Tier: pinned, reference, working or off-context")
+	quickCmd.Flags().StringVar(&quickProject, "project", "", "Project tag (default: the current git repository's name)")
+	rootCmd.AddCommand(quickCmd)
+}
+
+func runQuick(cmd *cobra.Command, args []string) error {
+	content := strings.TrimSpace(strings.Join(args, " "))
+	if content == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read stdin: %w", err)
+		}
+		content = strings.TrimSpace(string(data))
+	}
+	if content == "" {
+		return fmt.Errorf("nothing to capture: pass text or pipe it on stdin")
+	}
+
+	project := quickProject
+	if project == "" {
+		project = gitProjectName()
+	}
+	tags := quickDefaultTags(quickTags, quickTier, project, agentTag())
+
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	existing, err := d.FindByTypeAndContent(quickType, db.NormalizeContent(content))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := d.AddTags(existing.ID, tags); err != nil {
+			return err
+		}
+		fmt.Println(existing.ID)
+		return nil
+	}
+
+	node, _, err := ingest.CreateNode(d, db.CreateNodeInput{
+		Type:    quickType,
+		Content: content,
+		Tags:    tags,
+	}, ingest.MaxNodeTokens())
+	if err != nil {
+		return err
+	}
+	fmt.Println(node.ID)
+	return nil
+}
+
+// quickDefaultTags adds the tier, project and agent tags to tags unless
+// tags already carry one in that namespace.
+func quickDefaultTags(tags []string, tier, project, agentTag string) []string {
+	out := append([]string(nil), tags...)
+	has := func(prefix string) bool {
+		for _, t := range out {
+			if strings.HasPrefix(t, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	if tier != "" && !has("tier:") {
+		out = append(out, "tier:"+strings.TrimPrefix(tier, "tier:"))
+	}
+	if project != "" && !has("project:") {
+		out = append(out, "project:"+strings.TrimPrefix(project, "project:"))
+	}
+	if agentTag != "" && !has("agent:") {
+		out = append(out, agentTag)
+	}
+	return out
+}
+
+// gitProjectName is the name of the git repository containing the working
+// directory, or "" outside one.
+func gitProjectName() string {
+	out, err := newGitCmd("rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(string(bytes.TrimSpace(out)))
+}
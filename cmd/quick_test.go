@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuickDefaultTags(t *testing.T) {
+	assert.Equal(t,
+		[]string{"idea", "tier:reference", "project:ctx", "agent:bot"},
+		quickDefaultTags([]string{"idea"}, "reference", "ctx", "agent:bot"))
+
+	// Explicit tags win over the defaults for their namespace
+	assert.Equal(t,
+		[]string{"tier:pinned", "project:other"},
+		quickDefaultTags([]string{"tier:pinned", "project:other"}, "reference", "ctx", ""))
+
+	// Outside a repository there is no project tag
+	assert.Equal(t, []string{"tier:working"}, quickDefaultTags(nil, "tier:working", "", ""))
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/integrity"
+)
+
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild the full-text search index",
+	Long: `Rebuild full-text search from the nodes table. If the index drifts (a
+crash between a write and its index trigger, a restored backup), search
+silently misses nodes; ctx doctor reports the drift.
+
+SQLite rebuilds and optimizes nodes_fts. PostgreSQL recomputes search
+vectors that differ from their content and rebuilds the search index.`,
+	RunE: runReindex,
+}
+
+func init() {
+	rootCmd.AddCommand(reindexCmd)
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	drift, err := integrity.Reindex(d)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(drift, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if drift.OK() {
+			fmt.Println("Reindexed (the index was already in sync).")
+		} else {
+			fmt.Printf("Reindexed: %d missing or stale nodes and %d entries for deleted nodes repaired.\n", drift.Missing, drift.Extra)
+		}
+	}
+	return nil
+}
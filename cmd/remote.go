@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/config"
 )
 
 type remoteConfig struct {
@@ -41,10 +42,21 @@ var remoteRemoveCmd = &cobra.Command{
 	RunE:  runRemoteRemove,
 }
 
+var remoteTestCmd = &cobra.Command{
+	Use:   "test [url]",
+	Short: "Check connectivity, authentication and latency to the remote server",
+	Long: `Check the configured remote server (or the given URL): that /health
+answers, that the stored credentials are accepted, and how long a round trip
+takes. Exits non-zero if the server is unreachable or rejects the credentials.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runRemoteTest,
+}
+
 func init() {
 	remoteCmd.AddCommand(remoteSetCmd)
 	remoteCmd.AddCommand(remoteShowCmd)
 	remoteCmd.AddCommand(remoteRemoveCmd)
+	remoteCmd.AddCommand(remoteTestCmd)
 	rootCmd.AddCommand(remoteCmd)
 }
 
@@ -87,6 +99,9 @@ func runRemoteSet(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("Remote set to %s\n", url)
+	if settings.Remote != "" {
+		fmt.Printf("Note: remote %s in %s takes precedence.\n", settings.Remote, config.Path())
+	}
 	return nil
 }
 
@@ -96,6 +111,10 @@ func runRemoteShow(cmd *cobra.Command, args []string) error {
 		fmt.Println("No remote configured. Use 'ctx remote set <url>' to configure.")
 		return nil
 	}
+	if cfg.UpdatedAt == "" {
+		fmt.Printf("URL: %s\nSet: %s\n", cfg.URL, config.Path())
+		return nil
+	}
 	fmt.Printf("URL: %s\nSet: %s\n", cfg.URL, cfg.UpdatedAt)
 	return nil
 }
@@ -112,15 +131,138 @@ func runRemoteRemove(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func remoteConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
+// remoteTestResult is the outcome of `ctx remote test`.
+type remoteTestResult struct {
+	URL       string `json:"url"`
+	Reachable bool   `json:"reachable"`
+	LatencyMS int64  `json:"latency_ms"`
+	Auth      string `json:"auth"` // ok, rejected, missing, not_required, other_server
+	Error     string `json:"error,omitempty"`
+}
+
+func runRemoteTest(cmd *cobra.Command, args []string) error {
+	var url string
+	if len(args) > 0 {
+		url = strings.TrimRight(args[0], "/")
+	} else if cfg, err := loadRemoteConfig(); err == nil {
+		url = cfg.URL
+	} else {
+		return fmt.Errorf("no remote configured. Run 'ctx remote set <url>' first")
+	}
+
+	auth, _ := loadAuthConfig()
+	result := testRemote(url, auth)
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Server:  %s\n", result.URL)
+		if !result.Reachable {
+			fmt.Printf("Health:  FAIL (%s)\n", result.Error)
+		} else {
+			fmt.Printf("Health:  ok (%dms)\n", result.LatencyMS)
+			fmt.Printf("Auth:    %s\n", describeRemoteAuth(result.Auth))
+		}
+	}
+
+	switch {
+	case !result.Reachable:
+		return fmt.Errorf("cannot reach %s", url)
+	case result.Auth == "rejected":
+		return fmt.Errorf("credentials rejected by %s", url)
+	}
+	return nil
+}
+
+// testRemote checks /health, then probes /api/status with the stored token
+// (if it was issued by this server) to see whether the server accepts it.
+func testRemote(url string, auth *authConfig) remoteTestResult {
+	result := remoteTestResult{URL: url}
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	start := time.Now()
+	resp, err := client.Get(url + "/health")
 	if err != nil {
-		return "", err
+		result.Error = err.Error()
+		return result
 	}
-	return filepath.Join(home, ".ctx", "remote.json"), nil
+	resp.Body.Close()
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if resp.StatusCode != http.StatusOK {
+		result.Error = fmt.Sprintf("status %d", resp.StatusCode)
+		return result
+	}
+	result.Reachable = true
+
+	token := ""
+	if auth != nil && auth.Token != "" {
+		if strings.TrimRight(auth.ServerURL, "/") != url {
+			result.Auth = "other_server"
+			return result
+		}
+		token = auth.Token
+	}
+
+	req, err := http.NewRequest("GET", url+"/api/status", nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err = client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK && token != "":
+		result.Auth = "ok"
+	case resp.StatusCode == http.StatusOK:
+		result.Auth = "not_required"
+	case token != "":
+		result.Auth = "rejected"
+	default:
+		result.Auth = "missing"
+	}
+	return result
+}
+
+func describeRemoteAuth(status string) string {
+	switch status {
+	case "ok":
+		return "ok"
+	case "not_required":
+		return "not required by this server"
+	case "rejected":
+		return "FAIL (token rejected; run 'ctx auth' again)"
+	case "missing":
+		return "not authenticated (run 'ctx auth')"
+	case "other_server":
+		return "stored credentials are for a different server (run 'ctx auth')"
+	}
+	return status
 }
 
+func remoteConfigPath() (string, error) {
+	dir := settings.StateDir()
+	if dir == "" {
+		return "", fmt.Errorf("cannot determine home directory")
+	}
+	return filepath.Join(dir, "remote.json"), nil
+}
+
+// loadRemoteConfig returns the remote server configuration. A remote URL in
+// the config file or active profile takes precedence over remote.json.
 func loadRemoteConfig() (*remoteConfig, error) {
+	if settings.Remote != "" {
+		return &remoteConfig{URL: strings.TrimRight(settings.Remote, "/")}, nil
+	}
 	path, err := remoteConfigPath()
 	if err != nil {
 		return nil, err
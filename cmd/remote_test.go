@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTestRemote(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/health":
+			w.WriteHeader(http.StatusOK)
+		case r.Header.Get("Authorization") == "Bearer good":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	result := testRemote(srv.URL, &authConfig{Token: "good", ServerURL: srv.URL})
+	assert.True(t, result.Reachable)
+	assert.Equal(t, "ok", result.Auth)
+
+	assert.Equal(t, "rejected", testRemote(srv.URL, &authConfig{Token: "stale", ServerURL: srv.URL}).Auth)
+	assert.Equal(t, "missing", testRemote(srv.URL, nil).Auth)
+	assert.Equal(t, "other_server", testRemote(srv.URL, &authConfig{Token: "good", ServerURL: "http://elsewhere"}).Auth)
+}
+
+func TestTestRemote_OpenServer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	assert.Equal(t, "not_required", testRemote(srv.URL, nil).Auth)
+}
+
+func TestTestRemote_Unreachable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := srv.URL
+	srv.Close()
+
+	result := testRemote(url, nil)
+	assert.False(t, result.Reachable)
+	assert.NotEmpty(t, result.Error)
+}
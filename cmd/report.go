@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/report"
+)
+
+var (
+	reportSince  string
+	reportLimit  int
+	reportOutput string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Write a markdown digest of recent memory changes",
+	Long: `Summarize how memory changed over a period as a markdown document: new
+decisions, superseded knowledge, the nodes and projects whose token count
+grew most, and the questions still open. It is written for people, to
+commit to a repository or post in chat.
+
+  ctx report                                 # the last 24 hours
+  ctx report --since 7d --output report.md
+
+ctx serve writes one on a schedule when report.interval and report.dir are
+set in ~/.ctx/server.yaml.`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportSince, "since", "24h", "Period to report on (e.g. 24h, 7d, 2w)")
+	reportCmd.Flags().IntVar(&reportLimit, "limit", report.DefaultLimit, "Entries to list per section")
+	reportCmd.Flags().StringVar(&reportOutput, "output", "", "Write to this file instead of stdout")
+	rootCmd.AddCommand(reportCmd)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	period, err := parseDuration(reportSince)
+	if err != nil {
+		return fmt.Errorf("invalid --since %q: %w", reportSince, err)
+	}
+
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	r, err := report.Generate(d, report.Options{
+		Since: time.Now().Add(-period),
+		Limit: reportLimit,
+		Agent: agent,
+	})
+	if err != nil {
+		return err
+	}
+
+	out := report.RenderMarkdown(r)
+	if format == "json" {
+		data, _ := json.MarshalIndent(r, "", "  ")
+		out = string(data) + "\n"
+	}
+	if reportOutput == "" {
+		fmt.Print(out)
+		return nil
+	}
+	if err := os.WriteFile(reportOutput, []byte(out), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", reportOutput, err)
+	}
+	fmt.Printf("Wrote the report since %s to %s\n", r.Since.Local().Format("2006-01-02 15:04"), reportOutput)
+	return nil
+}
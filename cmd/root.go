@@ -1,14 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"time"
 
 	"github.com/spf13/cobra"
-	agentpkg "github.com/zate/ctx/internal/agent"
 	"github.com/zate/ctx/cmd/hook"
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
 )
 
 var (
@@ -16,30 +19,67 @@ var (
 	format  string
 	backend string
 	agent   string
+	profile string
+
+	queryTimeout time.Duration
 )
 
+// settings are the effective ~/.ctx/config.yaml settings, used as flag
+// defaults.
+var settings = config.Load()
+
 var rootCmd = &cobra.Command{
 	Use:   "ctx",
 	Short: "Persistent context management for Claude",
 	Long:  "A CLI tool for managing persistent, structured memory across conversations.",
+
+	PersistentPreRunE: applyProfile,
 }
 
 func init() {
-	home, _ := os.UserHomeDir()
-	defaultDB := filepath.Join(home, ".ctx", "store.db")
-	if envDB := os.Getenv("CTX_DB"); envDB != "" {
-		defaultDB = envDB
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", settings.DB, "Database path (file path for sqlite, connection string for postgres)")
+	rootCmd.PersistentFlags().StringVar(&format, "format", "text", "Output format: text, json, markdown (export and import also take obsidian)")
+	rootCmd.PersistentFlags().StringVar(&backend, "backend", settings.Backend, "Database backend: sqlite, postgres")
+	rootCmd.PersistentFlags().StringVar(&agent, "agent", settings.Agent, "Agent identity for memory partitioning (filters to agent-scoped + global nodes)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Config profile to use (db, remote and credentials); also CTX_PROFILE")
+	rootCmd.AddCommand(hook.HookCmd)
+}
+
+// applyProfile reloads the settings with the --profile profile active and
+// resets the db, backend and agent flags the user did not pass. The profile
+// is exported as CTX_PROFILE so hooks and packages that load the config
+// themselves see the same one.
+func applyProfile(cmd *cobra.Command, args []string) error {
+	cfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return err
 	}
-	defaultBackend := "sqlite"
-	if envBackend := os.Getenv("CTX_BACKEND"); envBackend != "" {
-		defaultBackend = envBackend
+	if profile != "" {
+		os.Setenv("CTX_PROFILE", profile)
 	}
-	rootCmd.PersistentFlags().StringVar(&dbPath, "db", defaultDB, "Database path (file path for sqlite, connection string for postgres)")
-	rootCmd.PersistentFlags().StringVar(&format, "format", "text", "Output format: text, json, markdown")
-	rootCmd.PersistentFlags().StringVar(&backend, "backend", defaultBackend, "Database backend: sqlite, postgres")
-	defaultAgent := os.Getenv("CTX_AGENT")
-	rootCmd.PersistentFlags().StringVar(&agent, "agent", defaultAgent, "Agent identity for memory partitioning (filters to agent-scoped + global nodes)")
-	rootCmd.AddCommand(hook.HookCmd)
+	settings = cfg
+
+	flags := cmd.Root().PersistentFlags()
+	if !flags.Changed("db") {
+		dbPath = cfg.DB
+	}
+	if !flags.Changed("backend") {
+		backend = cfg.Backend
+	}
+	if !flags.Changed("agent") {
+		agent = cfg.Agent
+	}
+	return nil
+}
+
+// addTimeoutFlag registers --timeout on a command that runs queries.
+func addTimeoutFlag(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&queryTimeout, "timeout", settings.Timeouts.CLI, "Abort the query after this long, e.g. 10s (0 disables)")
+}
+
+// queryContext bounds a command's query or compose by --timeout.
+func queryContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return query.WithTimeout(cmd.Context(), queryTimeout)
 }
 
 func Execute() error {
@@ -55,7 +95,8 @@ func openDB() (db.Store, error) {
 		}
 		return d, nil
 	case "sqlite", "":
-		d, err := db.Open(dbPath)
+		// A postgres:// URL selects PostgreSQL without --backend
+		d, err := db.OpenDSN(dbPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open database: %w", err)
 		}
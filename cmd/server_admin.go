@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/server"
+)
+
+var (
+	adminServerURL    string
+	adminPasswordFile string
+)
+
+var serverAdminCmd = &cobra.Command{
+	Use:   "admin",
+	Short: "Manage a ctx server with the admin password",
+	Long: `Manage devices and users on a ctx server from the command line.
+
+Requests authenticate with the server's admin password instead of a device
+token. The password is read from --admin-password-file, or from
+CTX_SERVER_ADMIN_PASSWORD[_FILE] and ~/.ctx/server.yaml as for ctx serve,
+so it works unchanged on the server host. The server defaults to the
+configured remote.
+
+  ctx server admin devices list
+  ctx server admin devices revoke <device-id>
+  ctx server admin users
+  ctx server admin stats`,
+}
+
+var serverAdminDevicesCmd = &cobra.Command{
+	Use:   "devices",
+	Short: "List or revoke devices",
+}
+
+var serverAdminDevicesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List all registered devices",
+	RunE:  runServerAdminDevicesList,
+}
+
+var serverAdminDevicesRevokeCmd = &cobra.Command{
+	Use:   "revoke <device-id>",
+	Short: "Revoke a device's access",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runServerAdminDevicesRevoke,
+}
+
+var serverAdminUsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "List server users and their device counts",
+	RunE:  runServerAdminUsers,
+}
+
+var serverAdminStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show server node, device and sync counts",
+	RunE:  runServerAdminStats,
+}
+
+func init() {
+	serverAdminCmd.PersistentFlags().StringVar(&adminServerURL, "server", "", "Server URL (default: the configured remote)")
+	serverAdminCmd.PersistentFlags().StringVar(&adminPasswordFile, "admin-password-file", "", "Read the admin password from a file")
+	serverAdminDevicesCmd.AddCommand(serverAdminDevicesListCmd, serverAdminDevicesRevokeCmd)
+	serverAdminCmd.AddCommand(serverAdminDevicesCmd, serverAdminUsersCmd, serverAdminStatsCmd)
+	serveCmd.AddCommand(serverAdminCmd)
+}
+
+// adminRequest calls an /api/admin endpoint with the admin password and
+// returns the response body, or an error for a non-200 status.
+func adminRequest(method, path string) ([]byte, error) {
+	base := adminServerURL
+	if base == "" {
+		base = configuredServerURL()
+	}
+	if base == "" {
+		return nil, fmt.Errorf("no server configured. Pass --server or run 'ctx remote set <url>'")
+	}
+
+	cfg := server.LoadConfig()
+	if adminPasswordFile != "" {
+		cfg.AdminPasswordFile = adminPasswordFile
+	}
+	if err := cfg.ReadAdminPasswordFile(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, strings.TrimRight(base, "/")+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.AdminPassword != "" {
+		req.SetBasicAuth("admin", cfg.AdminPassword)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot reach server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("admin password rejected by %s", base)
+	default:
+		return nil, fmt.Errorf("server error (%d): %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+}
+
+func runServerAdminDevicesList(cmd *cobra.Command, args []string) error {
+	body, err := adminRequest("GET", "/api/admin/devices")
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		fmt.Println(string(body))
+		return nil
+	}
+
+	var devices []struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		LastSeen string `json:"last_seen"`
+		LastIP   string `json:"last_ip"`
+		Revoked  bool   `json:"revoked"`
+	}
+	if err := json.Unmarshal(body, &devices); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(devices) == 0 {
+		fmt.Println("No devices registered.")
+		return nil
+	}
+	for _, d := range devices {
+		status := "active"
+		if d.Revoked {
+			status = "REVOKED"
+		}
+		fmt.Printf("%-26s  %-20s  %-8s  %-20s  %s\n", d.ID, d.Name, status, orNA(d.LastSeen), d.LastIP)
+	}
+	return nil
+}
+
+func runServerAdminDevicesRevoke(cmd *cobra.Command, args []string) error {
+	if _, err := adminRequest("POST", "/api/admin/devices/"+args[0]+"/revoke"); err != nil {
+		return err
+	}
+	fmt.Printf("Device %s revoked.\n", args[0])
+	return nil
+}
+
+func runServerAdminUsers(cmd *cobra.Command, args []string) error {
+	body, err := adminRequest("GET", "/api/admin/users")
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		fmt.Println(string(body))
+		return nil
+	}
+
+	var users []struct {
+		ID        string `json:"id"`
+		Username  string `json:"username"`
+		Devices   int    `json:"devices"`
+		CreatedAt string `json:"created_at"`
+	}
+	if err := json.Unmarshal(body, &users); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(users) == 0 {
+		fmt.Println("No users yet (one is created when the first device is approved).")
+		return nil
+	}
+	for _, u := range users {
+		fmt.Printf("%-26s  %-16s  %3d device(s)  since %s\n", u.ID, u.Username, u.Devices, u.CreatedAt)
+	}
+	return nil
+}
+
+func runServerAdminStats(cmd *cobra.Command, args []string) error {
+	body, err := adminRequest("GET", "/api/admin/stats")
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		fmt.Println(string(body))
+		return nil
+	}
+
+	var st struct {
+		TotalNodes    int   `json:"total_nodes"`
+		TotalTokens   int   `json:"total_tokens"`
+		TotalEdges    int   `json:"total_edges"`
+		UniqueTags    int   `json:"unique_tags"`
+		Users         int   `json:"users"`
+		Devices       int   `json:"devices"`
+		ActiveDevices int   `json:"active_devices"`
+		SyncVersion   int64 `json:"sync_version"`
+	}
+	if err := json.Unmarshal(body, &st); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	fmt.Printf("Nodes:        %d (%d tokens)\n", st.TotalNodes, st.TotalTokens)
+	fmt.Printf("Edges:        %d\n", st.TotalEdges)
+	fmt.Printf("Tags:         %d\n", st.UniqueTags)
+	fmt.Printf("Users:        %d\n", st.Users)
+	fmt.Printf("Devices:      %d (%d active)\n", st.Devices, st.ActiveDevices)
+	fmt.Printf("Sync version: %d\n", st.SyncVersion)
+	return nil
+}
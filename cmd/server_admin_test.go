@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminRequest_SendsPassword(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CTX_SERVER_ADMIN_PASSWORD", "")
+	t.Setenv("CTX_SERVER_ADMIN_PASSWORD_FILE", "")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pw, ok := r.BasicAuth(); !ok || pw != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	pwFile := filepath.Join(t.TempDir(), "pw")
+	require.NoError(t, os.WriteFile(pwFile, []byte("s3cret\n"), 0600))
+
+	adminServerURL, adminPasswordFile = srv.URL, pwFile
+	defer func() { adminServerURL, adminPasswordFile = "", "" }()
+
+	body, err := adminRequest("GET", "/api/admin/devices")
+	require.NoError(t, err)
+	assert.Equal(t, "[]", string(body))
+
+	adminPasswordFile = ""
+	t.Setenv("CTX_SERVER_ADMIN_PASSWORD", "wrong")
+	_, err = adminRequest("GET", "/api/admin/devices")
+	assert.ErrorContains(t, err, "admin password rejected")
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/usage"
 )
 
 var showWithEdges bool
@@ -44,6 +45,13 @@ func runShow(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("node %s is not accessible to the current agent scope", id)
 	}
 
+	attachments, err := d.ListAttachments(node.ID)
+	if err != nil {
+		return err
+	}
+	_ = usage.RecordAccess(d, node)
+	access, _ := d.GetNodeAccess(node.ID)
+
 	switch format {
 	case "json":
 		out := map[string]interface{}{
@@ -62,6 +70,13 @@ func runShow(cmd *cobra.Command, args []string) error {
 		if node.SupersededBy != nil {
 			out["superseded_by"] = *node.SupersededBy
 		}
+		if len(attachments) > 0 {
+			out["attachments"] = attachments
+		}
+		if access != nil {
+			out["access_count"] = access.AccessCount
+			out["last_accessed_at"] = access.LastAccessedAt
+		}
 		if showWithEdges {
 			edges, _ := d.GetEdges(node.ID, "both")
 			out["edges"] = edges
@@ -73,8 +88,12 @@ func runShow(cmd *cobra.Command, args []string) error {
 		fmt.Printf("Type:    %s\n", node.Type)
 		fmt.Printf("Content: %s\n", node.Content)
 		fmt.Printf("Tokens:  %d\n", node.TokenEstimate)
-		fmt.Printf("Created: %s\n", node.CreatedAt.Format("2006-01-02 15:04:05"))
-		fmt.Printf("Updated: %s\n", node.UpdatedAt.Format("2006-01-02 15:04:05"))
+		clock := settings.Clock()
+		fmt.Printf("Created: %s\n", clock.Detail(node.CreatedAt))
+		fmt.Printf("Updated: %s\n", clock.Detail(node.UpdatedAt))
+		if access != nil {
+			fmt.Printf("Read:    %d times, last %s\n", access.AccessCount, clock.Detail(access.LastAccessedAt))
+		}
 		if len(node.Tags) > 0 {
 			fmt.Printf("Tags:    %s\n", joinStrings(node.Tags, ", "))
 		}
@@ -84,6 +103,10 @@ func runShow(cmd *cobra.Command, args []string) error {
 		if node.SupersededBy != nil {
 			fmt.Printf("Superseded by: %s\n", *node.SupersededBy)
 		}
+		if len(attachments) > 0 {
+			fmt.Println("Attachments:")
+			printAttachments(attachments, "  ")
+		}
 		if showWithEdges {
 			edges, _ := d.GetEdges(node.ID, "both")
 			if len(edges) > 0 {
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/snapshot"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Save and restore copies of the whole store",
+	Long: `Snapshot the SQLite store before trying something drastic, such as
+aggressive cleanup or consolidation, and roll back if compose quality
+degrades. Snapshots are kept in a snapshots directory next to the database.
+
+Restoring first saves the current state as "` + snapshot.PreRestore + `", so a restore can be
+undone too. Stop other ctx processes (the MCP server, ctx serve) before
+restoring. PostgreSQL stores are not supported; use pg_dump there.`,
+}
+
+var snapshotCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Save the current store as a named snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotCreate,
+}
+
+var snapshotRestoreCmd = &cobra.Command{
+	Use:   "restore <name>",
+	Short: "Replace the store with a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotRestore,
+}
+
+var snapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots, newest first",
+	RunE:  runSnapshotList,
+}
+
+var snapshotDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a snapshot",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runSnapshotDelete,
+}
+
+var snapshotForce bool
+
+func init() {
+	snapshotCreateCmd.Flags().BoolVar(&snapshotForce, "force", false, "Replace an existing snapshot of the same name")
+
+	snapshotCmd.AddCommand(snapshotCreateCmd)
+	snapshotCmd.AddCommand(snapshotRestoreCmd)
+	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotDeleteCmd)
+	rootCmd.AddCommand(snapshotCmd)
+}
+
+// snapshotDBPath returns the SQLite file snapshots are taken of.
+func snapshotDBPath() (string, error) {
+	if backend == "postgres" || backend == "postgresql" || db.IsPostgresDSN(dbPath) {
+		return "", fmt.Errorf("snapshots need the SQLite backend; back up PostgreSQL with pg_dump")
+	}
+	return db.SQLitePath(dbPath), nil
+}
+
+func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	path, err := snapshotDBPath()
+	if err != nil {
+		return err
+	}
+	info, err := snapshot.Create(path, args[0], snapshotForce)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(info, "", "  ")
+		fmt.Println(string(data))
+	default:
+		fmt.Printf("Saved snapshot %s (%d KB) to %s\n", info.Name, info.Size/1024, info.Path)
+	}
+	return nil
+}
+
+func runSnapshotRestore(cmd *cobra.Command, args []string) error {
+	path, err := snapshotDBPath()
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Restore(path, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Restored snapshot %s (previous state saved as %s)\n", args[0], snapshot.PreRestore)
+	return nil
+}
+
+func runSnapshotList(cmd *cobra.Command, args []string) error {
+	path, err := snapshotDBPath()
+	if err != nil {
+		return err
+	}
+	list, err := snapshot.List(path)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		if list == nil {
+			list = []snapshot.Info{}
+		}
+		data, _ := json.MarshalIndent(list, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(list) == 0 {
+			fmt.Println("No snapshots")
+			return nil
+		}
+		clock := settings.Clock()
+		for _, s := range list {
+			fmt.Printf("%-24s %-16s  %8d KB\n", s.Name, clock.Format(s.CreatedAt), s.Size/1024)
+		}
+	}
+	return nil
+}
+
+func runSnapshotDelete(cmd *cobra.Command, args []string) error {
+	path, err := snapshotDBPath()
+	if err != nil {
+		return err
+	}
+	if err := snapshot.Delete(path, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted snapshot %s\n", args[0])
+	return nil
+}
@@ -7,6 +7,9 @@ import (
 
 	"github.com/spf13/cobra"
 	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/provenance"
+	"github.com/zate/ctx/internal/queue"
 )
 
 var statusCmd = &cobra.Command{
@@ -15,7 +18,10 @@ var statusCmd = &cobra.Command{
 	RunE:  runStatus,
 }
 
+var statusTools bool
+
 func init() {
+	statusCmd.Flags().BoolVar(&statusTools, "tools", false, "Show MCP tool usage (calls, latency, error rate)")
 	rootCmd.AddCommand(statusCmd)
 }
 
@@ -26,6 +32,10 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
+	if statusTools {
+		return printToolStats(d)
+	}
+
 	// Get file size
 	info, _ := os.Stat(dbPath)
 	var fileSize int64
@@ -68,6 +78,10 @@ func runStatus(cmd *cobra.Command, args []string) error {
 	var tagCount int
 	_ = d.QueryRow("SELECT COUNT(DISTINCT t.tag) FROM tags t JOIN nodes n ON t.node_id = n.id WHERE n.superseded_by IS NULL" + af).Scan(&tagCount)
 
+	// Nodes derived from superseded or deleted sources
+	var staleCount int
+	_ = d.QueryRow("SELECT COUNT(*) FROM tags t JOIN nodes n ON t.node_id = n.id WHERE t.tag = ? AND n.superseded_by IS NULL"+af, provenance.StaleTag).Scan(&staleCount)
+
 	// Tier breakdown
 	type tierInfo struct {
 		Tier   string `json:"tier"`
@@ -90,6 +104,8 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		tiers = append(tiers, ti)
 	}
 
+	jobs, _ := queue.List(d)
+
 	switch format {
 	case "json":
 		out := map[string]interface{}{
@@ -100,8 +116,10 @@ func runStatus(cmd *cobra.Command, args []string) error {
 			"total_tokens": totalTokens,
 			"total_edges":  edgeCount,
 			"unique_tags":  tagCount,
+			"stale_nodes":  staleCount,
 			"types":        typeCounts,
 			"tiers":        tiers,
+			"queued_jobs":  len(jobs),
 		}
 		data, _ := json.MarshalIndent(out, "", "  ")
 		fmt.Println(string(data))
@@ -120,6 +138,12 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		}
 		fmt.Printf("Edges: %d\n", edgeCount)
 		fmt.Printf("Tags: %d unique\n", tagCount)
+		if staleCount > 0 {
+			fmt.Printf("Stale: %d nodes derived from superseded or deleted sources (review with: ctx query 'tag:%s')\n", staleCount, provenance.StaleTag)
+		}
+		if len(jobs) > 0 {
+			fmt.Printf("Queued jobs: %d (run with: ctx hook flush)\n", len(jobs))
+		}
 		if len(tiers) > 0 {
 			fmt.Println("\nTier breakdown:")
 			for _, ti := range tiers {
@@ -130,3 +154,38 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func printToolStats(d db.Store) error {
+	stats, err := d.ListToolStats()
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		type toolOut struct {
+			*db.ToolStat
+			AvgMs     float64 `json:"avg_ms"`
+			ErrorRate float64 `json:"error_rate"`
+		}
+		out := make([]toolOut, len(stats))
+		for i, s := range stats {
+			out[i] = toolOut{ToolStat: s, AvgMs: s.AvgMs(), ErrorRate: s.ErrorRate()}
+		}
+		data, _ := json.MarshalIndent(out, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(stats) == 0 {
+			fmt.Println("No MCP tool calls recorded yet.")
+			return nil
+		}
+		fmt.Printf("%-16s %7s %7s %9s %9s  %s\n", "TOOL", "CALLS", "ERRORS", "AVG MS", "MAX MS", "LAST CALLED")
+		clock := settings.Clock()
+		for _, s := range stats {
+			fmt.Printf("%-16s %7d %6.0f%% %9.1f %9d  %s\n",
+				s.Tool, s.Calls, s.ErrorRate()*100, s.AvgMs(), s.MaxMs,
+				clock.Format(s.LastCalledAt))
+		}
+	}
+	return nil
+}
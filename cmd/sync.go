@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os/exec"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -89,10 +90,38 @@ func runSyncStatus(cmd *cobra.Command, args []string) error {
 	fmt.Printf("  Last push:        %s\n", orNA(state.LastPushAt))
 	fmt.Printf("  Last pull:        %s\n", orNA(state.LastPullAt))
 	fmt.Printf("  Local changes:    %d node(s) pending push\n", len(changes))
+	if conflicts := ctxsync.PendingConflicts(store); len(conflicts) > 0 {
+		fmt.Printf("  Conflicts:        %d node(s) kept the newer local copy over a pulled edit;\n", len(conflicts))
+		fmt.Printf("                    the next push overwrites the server: %s\n", strings.Join(conflicts, ", "))
+	}
 	fmt.Printf("  Server nodes:     %v\n", serverStatus["total_nodes"])
+	if quota, ok := serverStatus["quota"].(map[string]any); ok {
+		if q, ok := quota["device"].(map[string]any); ok {
+			fmt.Printf("  Device quota:     %s\n", formatQuota(q))
+		}
+		if q, ok := quota["user"].(map[string]any); ok {
+			fmt.Printf("  User quota:       %s\n", formatQuota(q))
+		}
+	}
 	return nil
 }
 
+// formatQuota renders a quota entry from /api/status, e.g.
+// "120/10000 nodes, 48000 tokens (no limit)".
+func formatQuota(q map[string]any) string {
+	part := func(used, limit any, unit string) string {
+		if limit == nil {
+			return fmt.Sprintf("%v %s (no limit)", used, unit)
+		}
+		return fmt.Sprintf("%v/%v %s", used, limit, unit)
+	}
+	out := part(q["nodes"], q["max_nodes"], "nodes") + ", " + part(q["tokens"], q["max_tokens"], "tokens")
+	if q["attachment_bytes"] != nil { // older servers don't report it
+		out += ", " + part(q["attachment_bytes"], q["max_attachment_bytes"], "attachment bytes")
+	}
+	return out
+}
+
 func runSyncPush(cmd *cobra.Command, args []string) error {
 	auth, err := loadAuthConfig()
 	if err != nil {
@@ -149,6 +178,9 @@ func runSyncPush(cmd *cobra.Command, args []string) error {
 	if err := ctxsync.SaveSyncState(state); err != nil {
 		return fmt.Errorf("failed to save sync state: %w", err)
 	}
+	if pushResp.Conflicts == 0 {
+		ctxsync.ClearConflicts(store)
+	}
 
 	fmt.Printf("Pushed %d node(s). Conflicts: %d. Server version: %d\n",
 		pushResp.Accepted, pushResp.Conflicts, pushResp.SyncVersion)
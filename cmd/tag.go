@@ -28,10 +28,8 @@ func runTag(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
-	for _, tag := range args[1:] {
-		if err := d.AddTag(nodeID, tag); err != nil {
-			return fmt.Errorf("failed to add tag %s: %w", tag, err)
-		}
+	if err := d.AddTags(nodeID, args[1:]); err != nil {
+		return fmt.Errorf("failed to add tags: %w", err)
 	}
 
 	fmt.Printf("Tagged: %s with %s\n", nodeID[:8], joinStrings(args[1:], ", "))
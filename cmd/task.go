@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+)
+
+var taskCmd = &cobra.Command{
+	Use:   "task",
+	Short: "Show the current task and the nodes pinned to it",
+	Long: `Show the task in progress, started with <ctx:task action="start"/> or
+the ctx_task MCP tool, and the nodes pinned to it.
+
+Nodes pinned with ctx task pin are composed into working context at every
+session start, whatever their tier and whether or not the session view
+selects them, until the task ends.`,
+	Args: cobra.NoArgs,
+	RunE: runTask,
+}
+
+var taskPinCmd = &cobra.Command{
+	Use:   "pin <id>...",
+	Short: "Keep nodes in working context until the current task ends",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runTaskPin,
+}
+
+var taskUnpinCmd = &cobra.Command{
+	Use:   "unpin <id>...",
+	Short: "Stop keeping nodes in working context for the current task",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runTaskUnpin,
+}
+
+func init() {
+	taskCmd.AddCommand(taskPinCmd, taskUnpinCmd)
+	rootCmd.AddCommand(taskCmd)
+}
+
+func runTask(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	name, err := hookpkg.CurrentTask(d)
+	if err != nil {
+		return err
+	}
+	pins, err := hookpkg.TaskPins(d)
+	if err != nil {
+		return err
+	}
+	return printTask(d, name, pins)
+}
+
+func runTaskPin(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ids, err := resolveArgs(d, args)
+	if err != nil {
+		return err
+	}
+	pins, err := hookpkg.PinToTask(d, ids...)
+	if err != nil {
+		return err
+	}
+	name, _ := hookpkg.CurrentTask(d)
+	if format == "json" {
+		return printTask(d, name, pins)
+	}
+	for _, id := range ids {
+		fmt.Printf("Pinned: %s to task %s\n", id[:8], name)
+	}
+	return nil
+}
+
+func runTaskUnpin(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	ids, err := resolveArgs(d, args)
+	if err != nil {
+		return err
+	}
+	pins, err := hookpkg.UnpinFromTask(d, ids...)
+	if err != nil {
+		return err
+	}
+	if format == "json" {
+		name, _ := hookpkg.CurrentTask(d)
+		return printTask(d, name, pins)
+	}
+	for _, id := range ids {
+		fmt.Printf("Unpinned: %s\n", id[:8])
+	}
+	return nil
+}
+
+// resolveArgs resolves each argument to a full node ID.
+func resolveArgs(d db.Store, args []string) ([]string, error) {
+	ids := make([]string, len(args))
+	for i, arg := range args {
+		id, err := resolveArg(d, arg)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func printTask(d db.Store, name string, pins []string) error {
+	var nodes []*db.Node
+	for _, id := range pins {
+		if n, err := d.GetNode(id); err == nil {
+			nodes = append(nodes, n)
+		}
+	}
+
+	switch format {
+	case "json":
+		if nodes == nil {
+			nodes = []*db.Node{}
+		}
+		data, _ := json.MarshalIndent(map[string]interface{}{
+			"task":   name,
+			"pinned": nodes,
+		}, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if name == "" {
+			fmt.Println("No task in progress.")
+			return nil
+		}
+		fmt.Printf("Task: %s\n", name)
+		if len(nodes) == 0 {
+			fmt.Println("No pinned nodes.")
+			return nil
+		}
+		fmt.Println("Pinned:")
+		for _, n := range nodes {
+			preview := n.Content
+			if len(preview) > 80 {
+				preview = preview[:80] + "..."
+			}
+			fmt.Printf("  %s [%s] %s\n", n.ID[:8], n.Type, preview)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/stats"
+)
+
+var topLimit int
+
+var topCmd = &cobra.Command{
+	Use:     "top",
+	Aliases: []string{"stats"},
+	Short:   "Show the most-accessed, most-linked and largest nodes",
+	Long: `List memory hotspots and bloat: nodes most often referenced or recalled
+across sessions, nodes with the most edges, and nodes with the highest
+token estimate, each with its age.
+
+Also lists the recall queries that most often came back empty: knowledge
+the agent keeps looking for but never finds, and a hint at what to add.`,
+	RunE: runTop,
+}
+
+func init() {
+	topCmd.Flags().IntVar(&topLimit, "limit", stats.DefaultTopLimit, "Nodes to list per category")
+	rootCmd.AddCommand(topCmd)
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	report, err := stats.Top(d, stats.TopOptions{Limit: topLimit, Agent: agent})
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	default:
+		printTopSection("Most accessed", "accesses", report.MostAccessed, func(e stats.TopEntry) int { return e.Accesses })
+		printTopSection("Most linked", "links", report.MostLinked, func(e stats.TopEntry) int { return e.Links })
+		printTopSection("Largest", "tokens", report.Largest, func(e stats.TopEntry) int { return e.Tokens })
+		fmt.Println("Most missed recalls:")
+		if len(report.MissedRecalls) == 0 {
+			fmt.Println("  (none)")
+		}
+		for _, m := range report.MissedRecalls {
+			fmt.Printf("  %6d %-8s %s (last %s ago)\n", m.Misses, "misses", m.Query, stats.FormatAge(time.Since(m.LastMissedAt)))
+		}
+	}
+	return nil
+}
+
+func printTopSection(title, unit string, entries []stats.TopEntry, value func(stats.TopEntry) int) {
+	fmt.Printf("%s:\n", title)
+	if len(entries) == 0 {
+		fmt.Println("  (none)")
+	}
+	for _, e := range entries {
+		fmt.Printf("  %6d %-8s [%s] %s (%s) %s\n", value(e), unit, e.ID, e.Type, e.Age, e.Preview)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/db"
+)
+
+var typesCmd = &cobra.Command{
+	Use:   "types [node|edge]",
+	Short: "List node and edge types, built in and registered",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runTypes,
+}
+
+var typesAddCmd = &cobra.Command{
+	Use:   "add <node|edge> <name> [description]",
+	Short: "Register a custom node or edge type",
+	Long: `Register a node or edge type to use besides the built-in ones, e.g.
+
+  ctx types add node incident "An outage: what broke, the impact and the fix"
+  ctx types add edge CAUSED_BY "From an incident to what caused it"
+
+Node type names are lower-case, like open-question; edge type names are
+upper-case, like DEPENDS_ON. Registering a type again replaces its
+description. The description is shown to agents in the MCP tool schemas,
+which pick up new types when the MCP server next starts.`,
+	Args: cobra.RangeArgs(2, 3),
+	RunE: runTypesAdd,
+}
+
+func init() {
+	typesCmd.AddCommand(typesAddCmd)
+	rootCmd.AddCommand(typesCmd)
+}
+
+func runTypes(cmd *cobra.Command, args []string) error {
+	var kind string
+	if len(args) == 1 {
+		kind = args[0]
+		if kind != db.KindNode && kind != db.KindEdge {
+			return fmt.Errorf("unknown type kind %q (use %s or %s)", kind, db.KindNode, db.KindEdge)
+		}
+	}
+
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	types, err := d.ListTypes(kind)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(types, "", "  ")
+		fmt.Println(string(data))
+	default:
+		printTypes(types)
+	}
+	return nil
+}
+
+// printTypes lists types by kind, one per line, with the registered ones'
+// descriptions.
+func printTypes(types []*db.TypeDef) {
+	kind := ""
+	for _, t := range types {
+		if t.Kind != kind {
+			if kind != "" {
+				fmt.Println()
+			}
+			kind = t.Kind
+			fmt.Printf("%s%s types:\n", strings.ToUpper(kind[:1]), kind[1:])
+		}
+		line := "  " + t.Name
+		if !t.Builtin {
+			line += " (custom)"
+			if t.Description != "" {
+				line += ": " + t.Description
+			}
+		}
+		fmt.Println(line)
+	}
+}
+
+func runTypesAdd(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	var description string
+	if len(args) == 3 {
+		description = strings.TrimSpace(args[2])
+	}
+	t, err := d.RegisterType(args[0], args[1], description)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Registered %s type %s\n", t.Kind, t.Name)
+	return nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	uiPort      int
+	uiNoBrowser bool
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Browse the local database in the admin web UI",
+	Long: `Start the admin web UI on 127.0.0.1 against the local database, with no
+password, and open it in the browser. Runs until interrupted. The URL
+carries a token made for this run; only a browser opened with it can use
+the UI.
+
+Unlike "ctx serve", nothing is exposed beyond this machine and no server
+deployment is needed.`,
+	Args: cobra.NoArgs,
+	RunE: runUI,
+}
+
+func init() {
+	uiCmd.Flags().IntVar(&uiPort, "port", 0, "Listen port (default: a free port)")
+	uiCmd.Flags().BoolVar(&uiNoBrowser, "no-browser", false, "Print the URL instead of launching a browser")
+	rootCmd.AddCommand(uiCmd)
+}
+
+func runUI(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	openPrint = uiNoBrowser
+	return serveLocalUI(d, fmt.Sprintf("127.0.0.1:%d", uiPort), "")
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/usage"
+)
+
+var (
+	usageTag         string
+	usageUnused      bool
+	usageMinSessions int
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Report how often injected nodes are actually used",
+	Long: `Report, per node, how many sessions it was injected into and how many
+sessions referenced it by ID or recalled it. Pinned nodes that are injected
+every session but never used are candidates for tier:reference.`,
+	RunE: runUsage,
+}
+
+func init() {
+	usageCmd.Flags().StringVar(&usageTag, "tag", "tier:pinned", "Only report nodes with this tag (empty for all)")
+	usageCmd.Flags().BoolVar(&usageUnused, "unused", false, "Only show nodes that were injected but never used")
+	usageCmd.Flags().IntVar(&usageMinSessions, "min-sessions", 3, "With --unused, minimum sessions injected before a node counts as unused")
+	rootCmd.AddCommand(usageCmd)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	d, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	entries, err := usage.Report(d, usageTag)
+	if err != nil {
+		return err
+	}
+
+	var rows []usage.Entry
+	for _, e := range entries {
+		if !agentpkg.ShouldInclude(e.Node, agent) {
+			continue
+		}
+		if usageUnused && (e.Usage.Used() || e.Usage.Injected < usageMinSessions) {
+			continue
+		}
+		rows = append(rows, e)
+	}
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(rows, "", "  ")
+		fmt.Println(string(data))
+	default:
+		if len(rows) == 0 {
+			fmt.Println("No matching nodes.")
+			return nil
+		}
+		fmt.Printf("%-26s %8s %10s %8s  %s\n", "ID", "INJECTED", "REFERENCED", "RECALLED", "CONTENT")
+		for _, e := range rows {
+			preview := e.Node.Content
+			if len(preview) > 60 {
+				preview = preview[:60] + "..."
+			}
+			fmt.Printf("%-26s %8d %10d %8d  %s\n", e.Node.ID, e.Usage.Injected, e.Usage.Referenced, e.Usage.Recalled, preview)
+		}
+	}
+	return nil
+}
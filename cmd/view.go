@@ -3,11 +3,9 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"strconv"
-	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/zate/ctx/internal/usage"
 	"github.com/zate/ctx/internal/view"
 )
 
@@ -44,22 +42,28 @@ var viewDeleteCmd = &cobra.Command{
 }
 
 var (
-	viewQuery  string
-	viewBudget int
+	viewQuery    string
+	viewBudget   int
+	viewSections []string
+	viewHeadings map[string]string
+	viewIcons    bool
+	viewIconMap  map[string]string
+	viewNoPrimer bool
 )
 
 func init() {
-	defaultBudget := 50000
-	if envBudget := os.Getenv("CTX_DEFAULT_BUDGET"); envBudget != "" {
-		if n, err := strconv.Atoi(envBudget); err == nil {
-			defaultBudget = n
-		}
-	}
+	defaultBudget := settings.DefaultBudget
 	viewCreateCmd.Flags().StringVar(&viewQuery, "query", "", "Query expression")
 	_ = viewCreateCmd.MarkFlagRequired("query")
 	viewCreateCmd.Flags().IntVar(&viewBudget, "budget", defaultBudget, "Token budget")
+	viewCreateCmd.Flags().StringSliceVar(&viewSections, "sections", nil, "Tier sections to render, in order (pinned,reference,working,other)")
+	viewCreateCmd.Flags().StringToStringVar(&viewHeadings, "heading", nil, "Section heading, e.g. working=\"Current Task\" (repeatable)")
+	viewCreateCmd.Flags().BoolVar(&viewIcons, "icons", false, "Prefix each node with an emoji for its type")
+	viewCreateCmd.Flags().StringToStringVar(&viewIconMap, "icon", nil, "Icon for a node type, e.g. decision=🧭 (repeatable; implies --icons)")
+	viewCreateCmd.Flags().BoolVar(&viewNoPrimer, "no-primer", false, "Leave the usage primer out of the rendered view")
 
 	viewRenderCmd.Flags().IntVar(&viewBudget, "budget", 0, "Override budget")
+	addTimeoutFlag(viewRenderCmd)
 
 	viewCmd.AddCommand(viewCreateCmd, viewListCmd, viewRenderCmd, viewDeleteCmd)
 	rootCmd.AddCommand(viewCmd)
@@ -72,10 +76,18 @@ func runViewCreate(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	_, err = d.Exec(`INSERT OR REPLACE INTO views (name, query, budget, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`,
-		args[0], viewQuery, viewBudget, now, now)
-	if err != nil {
+	layout := view.Layout{Sections: viewSections, Headings: viewHeadings, NoPrimer: viewNoPrimer}
+	if viewIcons || len(viewIconMap) > 0 {
+		layout.Icons = make(map[string]string, len(view.DefaultIcons))
+		for t, icon := range view.DefaultIcons {
+			layout.Icons[t] = icon
+		}
+		for t, icon := range viewIconMap {
+			layout.Icons[t] = icon
+		}
+	}
+
+	if err := view.Save(d, &view.Saved{Name: args[0], Query: viewQuery, Budget: viewBudget, Layout: layout}); err != nil {
 		return fmt.Errorf("failed to create view: %w", err)
 	}
 
@@ -90,24 +102,10 @@ func runViewList(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	rows, err := d.Query("SELECT name, query, budget FROM views ORDER BY name")
+	views, err := view.List(d)
 	if err != nil {
 		return err
 	}
-	defer rows.Close()
-
-	type viewInfo struct {
-		Name   string `json:"name"`
-		Query  string `json:"query"`
-		Budget int    `json:"budget"`
-	}
-
-	var views []viewInfo
-	for rows.Next() {
-		var v viewInfo
-		_ = rows.Scan(&v.Name, &v.Query, &v.Budget)
-		views = append(views, v)
-	}
 
 	switch format {
 	case "json":
@@ -115,7 +113,11 @@ func runViewList(cmd *cobra.Command, args []string) error {
 		fmt.Println(string(data))
 	default:
 		for _, v := range views {
-			fmt.Printf("%s: %s (budget: %d)\n", v.Name, v.Query, v.Budget)
+			fmt.Printf("%s: %s (budget: %d", v.Name, v.Query, v.Budget)
+			if l := v.Layout.String(); l != "" {
+				fmt.Printf("; %s", l)
+			}
+			fmt.Println(")")
 		}
 	}
 
@@ -129,24 +131,28 @@ func runViewRender(cmd *cobra.Command, args []string) error {
 	}
 	defer d.Close()
 
-	var q string
-	var budget int
-	err = d.QueryRow("SELECT query, budget FROM views WHERE name = ?", args[0]).Scan(&q, &budget)
+	saved, err := view.Get(d, args[0])
 	if err != nil {
 		return fmt.Errorf("view not found: %s", args[0])
 	}
 
+	budget := saved.Budget
 	if viewBudget > 0 {
 		budget = viewBudget
 	}
 
-	result, err := view.Compose(d, view.ComposeOptions{
-		Query:  q,
-		Budget: budget,
+	ctx, cancel := queryContext(cmd)
+	defer cancel()
+	result, err := view.ComposeContext(ctx, d, view.ComposeOptions{
+		Query:     saved.Query,
+		Budget:    budget,
+		Translate: view.TranslationFor(settings),
 	})
 	if err != nil {
 		return err
 	}
+	_ = usage.RecordAccess(d, result.Nodes...)
+	result.Layout = saved.Layout
 
 	switch format {
 	case "json":
@@ -0,0 +1,168 @@
+// Package approval stages destructive operations requested by MCP clients
+// so a human can approve them before they touch the store.
+//
+// Staged operations live in the pending table under the "op:" key prefix,
+// one JSON-encoded Op per key.
+package approval
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/provenance"
+)
+
+const keyPrefix = "op:"
+
+// Destructive operation names, matching the MCP tools that request them.
+const (
+	OpDelete    = "ctx_delete"
+	OpSupersede = "ctx_supersede"
+	OpForget    = "ctx_forget"
+)
+
+// Op is a staged destructive operation.
+type Op struct {
+	ID        string            `json:"id"`
+	Tool      string            `json:"tool"`
+	Args      map[string]string `json:"args"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Describe returns a one-line human-readable description of the operation.
+func (o *Op) Describe() string {
+	switch o.Tool {
+	case OpDelete:
+		return fmt.Sprintf("delete node %s", o.Args["id"])
+	case OpSupersede:
+		return fmt.Sprintf("supersede %s with %s", o.Args["old"], o.Args["new"])
+	case OpForget:
+		return fmt.Sprintf("forget node %s (archive to tier:off-context)", o.Args["id"])
+	default:
+		return o.Tool
+	}
+}
+
+// Stage records an operation for later approval and returns it.
+func Stage(d db.Store, tool string, args map[string]string) (*Op, error) {
+	op := &Op{
+		ID:        db.NewID(),
+		Tool:      tool,
+		Args:      args,
+		CreatedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(op)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.SetPending(keyPrefix+op.ID, string(data)); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// List returns all staged operations, oldest first.
+func List(d db.Store) ([]*Op, error) {
+	entries, err := d.ListPending(keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]*Op, 0, len(entries))
+	for key, value := range entries {
+		var op Op
+		if err := json.Unmarshal([]byte(value), &op); err != nil {
+			return nil, fmt.Errorf("corrupt staged operation %s: %w", key, err)
+		}
+		ops = append(ops, &op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].ID < ops[j].ID })
+	return ops, nil
+}
+
+// Get returns the staged operation whose ID starts with prefix.
+func Get(d db.Store, prefix string) (*Op, error) {
+	ops, err := List(d)
+	if err != nil {
+		return nil, err
+	}
+
+	var match *Op
+	for _, op := range ops {
+		if !strings.HasPrefix(op.ID, strings.ToUpper(prefix)) {
+			continue
+		}
+		if match != nil {
+			return nil, fmt.Errorf("ambiguous operation ID %q", prefix)
+		}
+		match = op
+	}
+	if match == nil {
+		return nil, fmt.Errorf("no staged operation %q: %w", prefix, db.ErrNotFound)
+	}
+	return match, nil
+}
+
+// Approve applies a staged operation and removes it from the queue.
+func Approve(d db.Store, op *Op) (string, error) {
+	result, err := Apply(d, op.Tool, op.Args)
+	if err != nil {
+		return "", err
+	}
+	return result, d.DeletePending(keyPrefix + op.ID)
+}
+
+// Discard removes a staged operation without applying it.
+func Discard(d db.Store, op *Op) error {
+	return d.DeletePending(keyPrefix + op.ID)
+}
+
+// Apply performs a destructive operation immediately. Node IDs in args
+// must already be resolved. It returns a human-readable result.
+func Apply(d db.Store, tool string, args map[string]string) (string, error) {
+	switch tool {
+	case OpDelete:
+		id := args["id"]
+		stale, err := provenance.MarkStale(d, id)
+		if err != nil {
+			return "", err
+		}
+		if err := d.DeleteNode(id); err != nil {
+			return "", err
+		}
+		return withStale(fmt.Sprintf("Deleted node %s", id), stale), nil
+
+	case OpSupersede:
+		oldID, newID := args["old"], args["new"]
+		if err := d.Supersede(oldID, newID, false); err != nil {
+			return "", fmt.Errorf("failed to supersede: %w", err)
+		}
+		stale, err := provenance.MarkStale(d, oldID)
+		if err != nil {
+			return "", fmt.Errorf("failed to flag derived nodes: %w", err)
+		}
+		return withStale(fmt.Sprintf("Node %s superseded by %s", oldID, newID), stale), nil
+
+	case OpForget:
+		id := args["id"]
+		remove := []string{"tier:pinned", "tier:reference", "tier:working"}
+		if err := d.UpdateTags(id, []string{"tier:off-context"}, remove); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Forgot node %s (moved to tier:off-context)", id), nil
+
+	default:
+		return "", fmt.Errorf("unknown operation %q", tool)
+	}
+}
+
+func withStale(result string, stale []string) string {
+	if len(stale) > 0 {
+		result += fmt.Sprintf(" (%d derived nodes flagged %s)", len(stale), provenance.StaleTag)
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package approval_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/approval"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestStage_DoesNotApply(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "keep me"})
+
+	op, err := approval.Stage(d, approval.OpDelete, map[string]string{"id": node.ID})
+	require.NoError(t, err)
+
+	_, err = d.GetNode(node.ID)
+	assert.NoError(t, err)
+
+	ops, err := approval.List(d)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, op.ID, ops[0].ID)
+	assert.Equal(t, map[string]string{"id": node.ID}, ops[0].Args)
+}
+
+func TestApprove_AppliesAndClears(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "delete me"})
+
+	op, err := approval.Stage(d, approval.OpDelete, map[string]string{"id": node.ID})
+	require.NoError(t, err)
+
+	got, err := approval.Get(d, op.ID[:10])
+	require.NoError(t, err)
+	_, err = approval.Approve(d, got)
+	require.NoError(t, err)
+
+	_, err = d.GetNode(node.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	ops, _ := approval.List(d)
+	assert.Empty(t, ops)
+}
+
+func TestDiscard_LeavesStoreUntouched(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "keep me", Tags: []string{"tier:pinned"}})
+
+	op, err := approval.Stage(d, approval.OpForget, map[string]string{"id": node.ID})
+	require.NoError(t, err)
+	require.NoError(t, approval.Discard(d, op))
+
+	tags, _ := d.GetTags(node.ID)
+	assert.Equal(t, []string{"tier:pinned"}, tags)
+	ops, _ := approval.List(d)
+	assert.Empty(t, ops)
+}
+
+func TestApply_Forget(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "old news", Tags: []string{"tier:pinned", "project:x"}})
+
+	_, err := approval.Apply(d, approval.OpForget, map[string]string{"id": node.ID})
+	require.NoError(t, err)
+
+	tags, _ := d.GetTags(node.ID)
+	assert.ElementsMatch(t, []string{"tier:off-context", "project:x"}, tags)
+}
+
+func TestApply_Supersede(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	oldNode, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "old"})
+	newNode, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "new"})
+
+	_, err := approval.Apply(d, approval.OpSupersede, map[string]string{"old": oldNode.ID, "new": newNode.ID})
+	require.NoError(t, err)
+
+	got, _ := d.GetNode(oldNode.ID)
+	require.NotNil(t, got.SupersededBy)
+	assert.Equal(t, newNode.ID, *got.SupersededBy)
+}
+
+func TestGet_UnknownOp(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := approval.Get(d, "NOPE")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
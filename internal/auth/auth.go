@@ -4,32 +4,46 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/zate/ctx/internal/db"
 )
 
 // DeviceFlowState tracks an in-progress device authorization flow.
 type DeviceFlowState struct {
-	DeviceCode string
-	UserCode   string
-	ExpiresAt  time.Time
-	Approved   bool
-	Denied     bool
-	DeviceName string
+	DeviceCode string    `json:"device_code"`
+	UserCode   string    `json:"user_code"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Approved   bool      `json:"approved,omitempty"`
+	Denied     bool      `json:"denied,omitempty"`
+	DeviceName string    `json:"device_name"`
 
 	// Set after approval
-	Token        string
-	RefreshToken string
-	DeviceID     string
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	DeviceID     string `json:"device_id,omitempty"`
 }
 
-// DeviceFlowStore manages in-flight device authorization flows in memory.
-// Flows are short-lived (expires in FlowTTL) so persistence isn't needed.
+// DeviceFlowStore manages in-flight device authorization flows. Flows are
+// short-lived (they expire after FlowTTL) and are kept in the pending
+// table, so a flow initiated on one server instance can be approved and
+// polled on another behind the same load balancer.
 type DeviceFlowStore struct {
-	mu    sync.Mutex
-	flows map[string]*DeviceFlowState // keyed by device_code
-	byUC  map[string]string           // user_code -> device_code
+	mu      sync.Mutex // orders updates from this instance
+	backend FlowBackend
+}
+
+// FlowBackend is where a DeviceFlowStore keeps flows; db.Store's pending
+// operations satisfy it.
+type FlowBackend interface {
+	SetPending(key, value string) error
+	GetPending(key string) (string, error)
+	DeletePending(key string) error
+	ListPending(prefix string) (map[string]string, error)
 }
 
 const (
@@ -38,79 +52,76 @@ const (
 	RefreshExpiry = 90 * 24 * time.Hour // 90 days
 )
 
-// NewDeviceFlowStore creates a new in-memory flow store.
+// Pending-table key prefixes: flows by device code, and the device code
+// for each user code.
+const (
+	flowKeyPrefix     = "device_flow:"
+	userCodeKeyPrefix = "device_flow_user:"
+)
+
+// NewDeviceFlowStore creates a flow store that keeps flows in memory, for
+// a single server instance.
 func NewDeviceFlowStore() *DeviceFlowStore {
-	return &DeviceFlowStore{
-		flows: make(map[string]*DeviceFlowState),
-		byUC:  make(map[string]string),
-	}
+	return NewSharedDeviceFlowStore(memoryBackend{})
+}
+
+// NewSharedDeviceFlowStore creates a flow store that keeps flows in b,
+// normally the server's database.
+func NewSharedDeviceFlowStore(b FlowBackend) *DeviceFlowStore {
+	return &DeviceFlowStore{backend: b}
 }
 
-// Initiate creates a new device authorization flow.
-func (s *DeviceFlowStore) Initiate(deviceName string) *DeviceFlowState {
+// Initiate creates a new device authorization flow, clearing out expired
+// ones.
+func (s *DeviceFlowStore) Initiate(deviceName string) (*DeviceFlowState, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.cleanup(false)
 	state := &DeviceFlowState{
 		DeviceCode: generateToken(32),
 		UserCode:   generateUserCode(),
 		ExpiresAt:  time.Now().Add(FlowTTL),
 		DeviceName: deviceName,
 	}
-
-	s.flows[state.DeviceCode] = state
-	s.byUC[state.UserCode] = state.DeviceCode
-
-	return state
+	if err := s.save(state); err != nil {
+		return nil, err
+	}
+	if err := s.backend.SetPending(userCodeKeyPrefix+state.UserCode, state.DeviceCode); err != nil {
+		return nil, fmt.Errorf("failed to save device flow: %w", err)
+	}
+	return state, nil
 }
 
 // GetByDeviceCode retrieves a flow by device code.
 func (s *DeviceFlowStore) GetByDeviceCode(deviceCode string) *DeviceFlowState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-
-	state := s.flows[deviceCode]
-	if state == nil || time.Now().After(state.ExpiresAt) {
-		return nil
-	}
-	return state
+	return s.load(deviceCode)
 }
 
 // GetByUserCode retrieves a flow by user code.
 func (s *DeviceFlowStore) GetByUserCode(userCode string) *DeviceFlowState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-
-	deviceCode, ok := s.byUC[userCode]
-	if !ok {
-		return nil
-	}
-	state := s.flows[deviceCode]
-	if state == nil || time.Now().After(state.ExpiresAt) {
-		return nil
-	}
-	return state
+	return s.loadByUserCode(userCode)
 }
 
-// Approve approves a device flow, setting the token and refresh token.
+// Approve approves a device flow, setting the token and refresh token. It
+// reports false if the flow is unknown or expired, or could not be saved.
 func (s *DeviceFlowStore) Approve(userCode, deviceID, token, refreshToken string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	deviceCode, ok := s.byUC[userCode]
-	if !ok {
-		return false
-	}
-	state := s.flows[deviceCode]
-	if state == nil || time.Now().After(state.ExpiresAt) {
+	state := s.loadByUserCode(userCode)
+	if state == nil {
 		return false
 	}
-
 	state.Approved = true
 	state.DeviceID = deviceID
 	state.Token = token
 	state.RefreshToken = refreshToken
-	return true
+	return s.save(state) == nil
 }
 
 // Deny denies a device flow.
@@ -118,31 +129,116 @@ func (s *DeviceFlowStore) Deny(userCode string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	deviceCode, ok := s.byUC[userCode]
-	if !ok {
-		return false
-	}
-	state := s.flows[deviceCode]
+	state := s.loadByUserCode(userCode)
 	if state == nil {
 		return false
 	}
-
 	state.Denied = true
-	return true
+	return s.save(state) == nil
+}
+
+// Finish removes a flow once its outcome has been delivered to the device,
+// so the tokens don't outlive the poll that collected them.
+func (s *DeviceFlowStore) Finish(state *DeviceFlowState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(state)
 }
 
-// Cleanup removes expired flows.
+// Cleanup removes expired and finished flows.
 func (s *DeviceFlowStore) Cleanup() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.cleanup(true)
+}
 
+// cleanup removes expired flows and, if finished is set, approved and
+// denied ones whose device may not have polled yet.
+func (s *DeviceFlowStore) cleanup(finished bool) {
+	entries, err := s.backend.ListPending(flowKeyPrefix)
+	if err != nil {
+		return
+	}
 	now := time.Now()
-	for dc, state := range s.flows {
-		if now.After(state.ExpiresAt) || state.Approved || state.Denied {
-			delete(s.byUC, state.UserCode)
-			delete(s.flows, dc)
+	for _, value := range entries {
+		var state DeviceFlowState
+		if json.Unmarshal([]byte(value), &state) != nil {
+			continue
+		}
+		if now.After(state.ExpiresAt) || finished && (state.Approved || state.Denied) {
+			s.remove(&state)
+		}
+	}
+}
+
+func (s *DeviceFlowStore) save(state *DeviceFlowState) error {
+	data, _ := json.Marshal(state)
+	if err := s.backend.SetPending(flowKeyPrefix+state.DeviceCode, string(data)); err != nil {
+		return fmt.Errorf("failed to save device flow: %w", err)
+	}
+	return nil
+}
+
+// load returns the unexpired flow with deviceCode, or nil.
+func (s *DeviceFlowStore) load(deviceCode string) *DeviceFlowState {
+	value, err := s.backend.GetPending(flowKeyPrefix + deviceCode)
+	if err != nil {
+		return nil
+	}
+	var state DeviceFlowState
+	if json.Unmarshal([]byte(value), &state) != nil {
+		return nil
+	}
+	if time.Now().After(state.ExpiresAt) {
+		s.remove(&state)
+		return nil
+	}
+	return &state
+}
+
+func (s *DeviceFlowStore) loadByUserCode(userCode string) *DeviceFlowState {
+	deviceCode, err := s.backend.GetPending(userCodeKeyPrefix + userCode)
+	if err != nil {
+		return nil
+	}
+	return s.load(deviceCode)
+}
+
+func (s *DeviceFlowStore) remove(state *DeviceFlowState) {
+	_ = s.backend.DeletePending(flowKeyPrefix + state.DeviceCode)
+	_ = s.backend.DeletePending(userCodeKeyPrefix + state.UserCode)
+}
+
+// memoryBackend keeps flows for NewDeviceFlowStore. DeviceFlowStore's lock
+// guards it.
+type memoryBackend map[string]string
+
+func (m memoryBackend) SetPending(key, value string) error {
+	m[key] = value
+	return nil
+}
+
+func (m memoryBackend) GetPending(key string) (string, error) {
+	value, ok := m[key]
+	if !ok {
+		return "", db.ErrNotFound
+	}
+	return value, nil
+}
+
+func (m memoryBackend) DeletePending(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memoryBackend) ListPending(prefix string) (map[string]string, error) {
+	out := map[string]string{}
+	for k, v := range m {
+		if strings.HasPrefix(k, prefix) {
+			out[k] = v
 		}
 	}
+	return out, nil
 }
 
 // HashToken creates a SHA-256 hash of a token for storage.
@@ -6,11 +6,19 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/testutil"
 )
 
+func initiate(t *testing.T, store *DeviceFlowStore, name string) *DeviceFlowState {
+	t.Helper()
+	state, err := store.Initiate(name)
+	require.NoError(t, err)
+	return state
+}
+
 func TestDeviceFlowStore_Initiate(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test-device")
+	state := initiate(t, store, "test-device")
 
 	assert.NotEmpty(t, state.DeviceCode)
 	assert.NotEmpty(t, state.UserCode)
@@ -22,7 +30,7 @@ func TestDeviceFlowStore_Initiate(t *testing.T) {
 
 func TestDeviceFlowStore_GetByDeviceCode(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test-device")
+	state := initiate(t, store, "test-device")
 
 	found := store.GetByDeviceCode(state.DeviceCode)
 	require.NotNil(t, found)
@@ -34,7 +42,7 @@ func TestDeviceFlowStore_GetByDeviceCode(t *testing.T) {
 
 func TestDeviceFlowStore_GetByUserCode(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test-device")
+	state := initiate(t, store, "test-device")
 
 	found := store.GetByUserCode(state.UserCode)
 	require.NotNil(t, found)
@@ -46,7 +54,7 @@ func TestDeviceFlowStore_GetByUserCode(t *testing.T) {
 
 func TestDeviceFlowStore_Approve(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test-device")
+	state := initiate(t, store, "test-device")
 
 	ok := store.Approve(state.UserCode, "device-123", "token-abc", "refresh-xyz")
 	assert.True(t, ok)
@@ -61,7 +69,7 @@ func TestDeviceFlowStore_Approve(t *testing.T) {
 
 func TestDeviceFlowStore_Deny(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test-device")
+	state := initiate(t, store, "test-device")
 
 	ok := store.Deny(state.UserCode)
 	assert.True(t, ok)
@@ -75,15 +83,15 @@ func TestDeviceFlowStore_Cleanup(t *testing.T) {
 	store := NewDeviceFlowStore()
 
 	// Create and approve a flow (should be cleaned up)
-	s1 := store.Initiate("approved")
+	s1 := initiate(t, store, "approved")
 	store.Approve(s1.UserCode, "d1", "t1", "r1")
 
 	// Create and deny a flow (should be cleaned up)
-	s2 := store.Initiate("denied")
+	s2 := initiate(t, store, "denied")
 	store.Deny(s2.UserCode)
 
 	// Create an active flow (should NOT be cleaned up)
-	s3 := store.Initiate("active")
+	s3 := initiate(t, store, "active")
 
 	store.Cleanup()
 
@@ -92,6 +100,51 @@ func TestDeviceFlowStore_Cleanup(t *testing.T) {
 	assert.NotNil(t, store.GetByDeviceCode(s3.DeviceCode))
 }
 
+func TestDeviceFlowStore_SharedAcrossInstances(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	first := NewSharedDeviceFlowStore(d)
+	second := NewSharedDeviceFlowStore(d)
+
+	state := initiate(t, first, "laptop")
+	found := second.GetByUserCode(state.UserCode)
+	require.NotNil(t, found, "approval can land on another instance")
+	assert.Equal(t, "laptop", found.DeviceName)
+	require.True(t, second.Approve(state.UserCode, "d1", "t1", "r1"))
+
+	polled := first.GetByDeviceCode(state.DeviceCode)
+	require.NotNil(t, polled)
+	assert.True(t, polled.Approved)
+	assert.Equal(t, "t1", polled.Token)
+
+	first.Finish(polled)
+	assert.Nil(t, second.GetByDeviceCode(state.DeviceCode))
+	assert.Nil(t, second.GetByUserCode(state.UserCode))
+}
+
+func TestDeviceFlowStore_Expiry(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	store := NewSharedDeviceFlowStore(d)
+
+	expired := initiate(t, store, "old")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, store.save(expired))
+	approved := initiate(t, store, "approved")
+	require.True(t, store.Approve(approved.UserCode, "d1", "t1", "r1"))
+
+	assert.Nil(t, store.GetByUserCode(expired.UserCode))
+	assert.False(t, store.Approve(expired.UserCode, "d2", "t2", "r2"))
+
+	// Initiating clears expired flows but keeps approved ones until polled
+	expired = initiate(t, store, "old again")
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, store.save(expired))
+	initiate(t, store, "new")
+	flows, err := d.ListPending(flowKeyPrefix)
+	require.NoError(t, err)
+	assert.Len(t, flows, 2)
+	assert.NotNil(t, store.GetByDeviceCode(approved.DeviceCode))
+}
+
 func TestHashToken(t *testing.T) {
 	hash1 := HashToken("test-token")
 	hash2 := HashToken("test-token")
@@ -118,7 +171,7 @@ func TestGenerateRefreshToken(t *testing.T) {
 
 func TestUserCodeFormat(t *testing.T) {
 	store := NewDeviceFlowStore()
-	state := store.Initiate("test")
+	state := initiate(t, store, "test")
 
 	// Should be format XXXX-XXXX
 	assert.Len(t, state.UserCode, 9)
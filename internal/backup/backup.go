@@ -0,0 +1,262 @@
+// Package backup uploads JSONL exports of a store to S3-compatible object
+// storage and prunes the ones past their retention. A backup is what ctx
+// export writes, gzipped, so it restores into either backend with
+// ctx import.
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
+)
+
+// requestTimeout bounds one object storage request, uploads included.
+const requestTimeout = 10 * time.Minute
+
+// Backup object names are namePrefix, the UTC time and nameSuffix.
+const (
+	namePrefix = "ctx-"
+	nameSuffix = ".jsonl.gz"
+	timeLayout = "20060102T150405Z"
+)
+
+// Config selects where backups go and how often they are taken.
+type Config struct {
+	Bucket string `yaml:"bucket"`
+	// Prefix is prepended to object names, as a folder: backups/ or ctx/prod/.
+	Prefix string `yaml:"prefix"`
+	// Endpoint is the storage service URL, e.g. https://minio.internal:9000;
+	// it defaults to AWS S3 in Region.
+	Endpoint string `yaml:"endpoint"`
+	Region   string `yaml:"region"`
+	// PathStyle addresses the bucket in the path (endpoint/bucket) instead
+	// of the host name (bucket.endpoint), as MinIO and most self-hosted
+	// services need.
+	PathStyle bool `yaml:"path_style"`
+	// AccessKeyID and SecretAccessKey default to AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY (with AWS_SESSION_TOKEN, if set).
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// Interval is how often the server takes a backup; 0 leaves backups
+	// to ctx server backup now.
+	Interval time.Duration `yaml:"interval"`
+	// Retention is how long backups are kept; 0 keeps them all. The newest
+	// backup is never pruned.
+	Retention time.Duration `yaml:"retention"`
+}
+
+// Enabled reports whether a bucket is configured.
+func (c Config) Enabled() bool {
+	return c.Bucket != ""
+}
+
+func (c Config) region() string {
+	if c.Region == "" {
+		return "us-east-1"
+	}
+	return c.Region
+}
+
+func (c Config) endpoint() string {
+	if c.Endpoint == "" {
+		return "https://s3." + c.region() + ".amazonaws.com"
+	}
+	return strings.TrimRight(c.Endpoint, "/")
+}
+
+func (c Config) credentials() (keyID, secret, sessionToken string) {
+	if c.AccessKeyID != "" {
+		return c.AccessKeyID, c.SecretAccessKey, ""
+	}
+	return os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+}
+
+// prefix returns Prefix as a folder: empty, or ending in a slash.
+func (c Config) prefix() string {
+	p := strings.TrimLeft(c.Prefix, "/")
+	if p != "" && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
+}
+
+// Location describes where backups go, as s3://bucket/prefix.
+func (c Config) Location() string {
+	return "s3://" + c.Bucket + "/" + c.prefix()
+}
+
+// Backup is one stored backup.
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Report describes a backup Run took.
+type Report struct {
+	Backup
+	Export *export.Result `json:"export"`
+	Pruned int            `json:"pruned"` // old backups deleted
+}
+
+// Target stores and retrieves backups for a Config.
+type Target struct {
+	cfg Config
+	s3  *s3Client
+}
+
+// New returns the Target for cfg, which must name a bucket and credentials.
+func New(cfg Config) (*Target, error) {
+	if !cfg.Enabled() {
+		return nil, errors.New("no backup bucket configured (set backup.bucket in ~/.ctx/server.yaml or CTX_SERVER_BACKUP_BUCKET)")
+	}
+	if keyID, secret, _ := cfg.credentials(); keyID == "" || secret == "" {
+		return nil, errors.New("no backup credentials: set backup.access_key_id and backup.secret_access_key, or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return &Target{cfg: cfg, s3: newS3Client(cfg)}, nil
+}
+
+// Run exports d, uploads it as a new backup and prunes backups past the
+// retention. The export is spooled to a temporary file so its size and
+// checksum are known before the upload starts. A failed prune is returned
+// with the report, as the backup was still taken.
+func (t *Target) Run(ctx context.Context, d db.Store) (*Report, error) {
+	f, err := os.CreateTemp("", "ctx-backup-*"+nameSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	now := t.s3.now().UTC()
+	hash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(f, hash))
+	res, err := export.Write(d, gz, export.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export store: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write backup file: %w", err)
+	}
+	size, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r := &Report{Backup: Backup{Name: namePrefix + now.Format(timeLayout) + nameSuffix, Size: size, CreatedAt: now}, Export: res}
+	if err := t.s3.put(ctx, t.cfg.prefix()+r.Name, f, size, hex.EncodeToString(hash.Sum(nil))); err != nil {
+		return nil, fmt.Errorf("failed to upload backup: %w", err)
+	}
+
+	r.Pruned, err = t.Prune(ctx, now)
+	if err != nil {
+		return r, fmt.Errorf("backup %s taken, but pruning old backups failed: %w", r.Name, err)
+	}
+	return r, nil
+}
+
+// List returns the backups under the prefix, newest first. Other objects
+// sharing the prefix are ignored.
+func (t *Target) List(ctx context.Context) ([]Backup, error) {
+	objects, err := t.s3.list(ctx, t.cfg.prefix())
+	if err != nil {
+		return nil, err
+	}
+	var backups []Backup
+	for _, o := range objects {
+		name := strings.TrimPrefix(o.Key, t.cfg.prefix())
+		if strings.Contains(name, "/") || !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix) {
+			continue
+		}
+		created := o.LastModified
+		if ts, err := time.Parse(timeLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix)); err == nil {
+			created = ts
+		}
+		backups = append(backups, Backup{Name: name, Size: o.Size, CreatedAt: created})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// Latest returns the newest backup, or nil when there is none.
+func (t *Target) Latest(ctx context.Context) (*Backup, error) {
+	backups, err := t.List(ctx)
+	if err != nil || len(backups) == 0 {
+		return nil, err
+	}
+	return &backups[0], nil
+}
+
+// Prune deletes the backups created before now minus the retention,
+// keeping the newest whatever its age, and returns how many it deleted.
+func (t *Target) Prune(ctx context.Context, now time.Time) (int, error) {
+	if t.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	backups, err := t.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.Add(-t.cfg.Retention)
+	pruned := 0
+	for _, b := range backups[min(1, len(backups)):] {
+		if !b.CreatedAt.Before(cutoff) {
+			continue
+		}
+		if err := t.s3.delete(ctx, t.cfg.prefix()+b.Name); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// Open returns the export stored in the named backup, decompressed; the
+// caller closes it. The name "latest" opens the newest backup.
+func (t *Target) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if name == "latest" {
+		b, err := t.Latest(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if b == nil {
+			return nil, fmt.Errorf("no backups in %s", t.cfg.Location())
+		}
+		name = b.Name
+	}
+	body, err := t.s3.get(ctx, t.cfg.prefix()+name)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(body)
+	if err != nil {
+		body.Close()
+		return nil, fmt.Errorf("backup %s is not gzipped: %w", name, err)
+	}
+	return &gzipBody{Reader: gz, body: body}, nil
+}
+
+// gzipBody closes both the decompressor and the response body under it.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
@@ -0,0 +1,148 @@
+package backup_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/backup"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
+	"github.com/zate/ctx/testutil"
+)
+
+// fakeS3 is an in-memory path-style bucket.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		http.Error(w, "<Error><Code>AccessDenied</Code><Message>unsigned</Message></Error>", http.StatusForbidden)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, "/bucket/")
+	switch {
+	case r.Method == http.MethodGet && key == "":
+		type entry struct {
+			Key  string `xml:"Key"`
+			Size int    `xml:"Size"`
+		}
+		var list struct {
+			XMLName  xml.Name `xml:"ListBucketResult"`
+			Contents []entry  `xml:"Contents"`
+		}
+		for k, v := range f.objects {
+			if strings.HasPrefix(k, r.URL.Query().Get("prefix")) {
+				list.Contents = append(list.Contents, entry{Key: k, Size: len(v)})
+			}
+		}
+		sort.Slice(list.Contents, func(i, j int) bool { return list.Contents[i].Key < list.Contents[j].Key })
+		_ = xml.NewEncoder(w).Encode(list)
+	case r.Method == http.MethodPut:
+		f.objects[key], _ = io.ReadAll(r.Body)
+	case r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code><Message>missing</Message></Error>", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func setup(t *testing.T, retention time.Duration) (*backup.Target, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	target, err := backup.New(backup.Config{
+		Bucket: "bucket", Prefix: "ctx", Endpoint: srv.URL, PathStyle: true,
+		AccessKeyID: "key", SecretAccessKey: "secret", Retention: retention,
+	})
+	require.NoError(t, err)
+	return target, fake
+}
+
+func TestRunAndRestore(t *testing.T) {
+	target, fake := setup(t, 0)
+	src := testutil.SetupTestDB(t)
+	n, err := src.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Backups go to S3", Tags: []string{"project:ctx"}})
+	require.NoError(t, err)
+
+	report, err := target.Run(t.Context(), src)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Export.Nodes)
+	assert.Contains(t, fake.objects, "ctx/"+report.Name)
+	assert.Equal(t, int64(len(fake.objects["ctx/"+report.Name])), report.Size)
+
+	backups, err := target.List(t.Context())
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	assert.Equal(t, report.Name, backups[0].Name)
+
+	r, err := target.Open(t.Context(), "latest")
+	require.NoError(t, err)
+	defer r.Close()
+	dst := testutil.SetupTestDB(t)
+	_, err = export.Import(dst, r, export.ImportOptions{})
+	require.NoError(t, err)
+	got, err := dst.GetNode(n.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Backups go to S3", got.Content)
+	assert.Equal(t, []string{"project:ctx"}, got.Tags)
+
+	_, err = target.Open(t.Context(), "ctx-missing.jsonl.gz")
+	assert.ErrorContains(t, err, "NoSuchKey")
+}
+
+func TestPrune(t *testing.T) {
+	target, fake := setup(t, 7*24*time.Hour)
+	now := time.Now().UTC()
+	name := func(age time.Duration) string {
+		return "ctx/ctx-" + now.Add(-age).Format("20060102T150405Z") + ".jsonl.gz"
+	}
+	fake.objects[name(30*24*time.Hour)] = []byte("old")
+	fake.objects[name(10*24*time.Hour)] = []byte("old")
+	fake.objects[name(24*time.Hour)] = []byte("recent")
+	fake.objects["ctx/notes.txt"] = []byte("not a backup")
+
+	pruned, err := target.Prune(t.Context(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+	assert.Len(t, fake.objects, 2)
+	assert.Contains(t, fake.objects, name(24*time.Hour))
+
+	// The newest backup is kept however old it is
+	pruned, err = target.Prune(t.Context(), now.Add(365*24*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, pruned)
+}
+
+func TestNewNeedsBucketAndCredentials(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	_, err := backup.New(backup.Config{})
+	assert.ErrorContains(t, err, "no backup bucket")
+	_, err = backup.New(backup.Config{Bucket: "b"})
+	assert.ErrorContains(t, err, "no backup credentials")
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "id")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	_, err = backup.New(backup.Config{Bucket: "b"})
+	assert.NoError(t, err)
+}
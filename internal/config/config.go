@@ -0,0 +1,580 @@
+// Package config loads ctx settings from ~/.ctx/config.yaml.
+//
+// Values are resolved in order: built-in defaults, the legacy keys still
+// read from ~/.ctx/server.yaml (auto_sync, inbox, max_node_tokens),
+// config.yaml, the active profile, then environment variables. Command-line
+// flags override all of these where a command offers one.
+//
+// A profile is a named set of db, backend, agent and remote settings under
+// the profiles key, selected with ctx --profile, CTX_PROFILE or the profile
+// key. Each profile keeps its own credentials and sync state under
+// ~/.ctx/profiles/<name>, so switching profiles never needs a re-auth.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/timefmt"
+	"gopkg.in/yaml.v3"
+)
+
+// Default values for settings that have one.
+const (
+	DefaultBudget         = 50000
+	DefaultView           = "default"
+	DefaultMaxNodeTokens  = 4000
+	DefaultNudgeTurns     = 4
+	DefaultRememberRate   = 30
+	DefaultHookBudget     = 800 * time.Millisecond
+	DefaultResurfaceMax   = 1
+	DefaultHookTimeout    = 5 * time.Second
+	DefaultMCPTimeout     = 30 * time.Second
+	DefaultDecayWorking   = 14 * 24 * time.Hour
+	DefaultDecayReference = 180 * 24 * time.Hour
+)
+
+// Config holds every ctx setting. Each leaf field's yaml tag is its key
+// (nested with dots, e.g. hooks.primer_file), env names its environment
+// override, and desc is shown by `ctx config list`.
+type Config struct {
+	DB             string     `yaml:"db" env:"CTX_DB" desc:"Database path, sqlite:<path>, or postgres:// URL"`
+	Backend        string     `yaml:"backend" env:"CTX_BACKEND" desc:"Database backend: sqlite or postgres"`
+	Agent          string     `yaml:"agent" env:"CTX_AGENT" desc:"Agent identity for memory partitioning"`
+	DefaultBudget  int        `yaml:"default_budget" env:"CTX_DEFAULT_BUDGET" desc:"Token budget for compose and new views"`
+	DefaultView    string     `yaml:"default_view" env:"CTX_DEFAULT_VIEW" desc:"View composed at session start"`
+	AutoSync       bool       `yaml:"auto_sync" env:"CTX_AUTO_SYNC" desc:"Pull on session start and push on session end"`
+	Inbox          bool       `yaml:"inbox" env:"CTX_INBOX" desc:"Hold hook-created nodes for review in ctx inbox"`
+	MaxNodeTokens  int        `yaml:"max_node_tokens" env:"CTX_MAX_NODE_TOKENS" desc:"Split larger remembers into chunks (0 disables)"`
+	AutoLink       bool       `yaml:"auto_link" env:"CTX_AUTO_LINK" desc:"Link remembered nodes RELATES_TO the nodes whose IDs they mention"`
+	GitLink        bool       `yaml:"git_link" env:"CTX_GIT_LINK" desc:"Record the git branch and HEAD commit on decisions stored inside a repository, and link them to a source node for the commit"`
+	Remote         string     `yaml:"remote" env:"CTX_REMOTE" desc:"Remote server URL (overrides ctx remote set)"`
+	RedactPatterns []string   `yaml:"redact" desc:"Regular expressions replaced with [REDACTED] before storing"`
+	Tiers          Tiers      `yaml:"tiers"`
+	Hooks          Hooks      `yaml:"hooks"`
+	Decay          Decay      `yaml:"decay"`
+	Timeouts       Timeouts   `yaml:"timeouts"`
+	LLM            LLM        `yaml:"llm"`
+	Embeddings     Embeddings `yaml:"embeddings"`
+	Lang           Lang       `yaml:"lang"`
+	Display        Display    `yaml:"display"`
+
+	Profile  string             `yaml:"profile" desc:"Active profile (overridden by --profile and CTX_PROFILE)"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile bundles the settings that differ between contexts such as
+// personal and work. Empty fields keep the top-level value.
+type Profile struct {
+	DB      string `yaml:"db,omitempty" json:"db,omitempty" desc:"Database path or URL for this profile"`
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty" desc:"Database backend for this profile"`
+	Agent   string `yaml:"agent,omitempty" json:"agent,omitempty" desc:"Agent identity for this profile"`
+	Remote  string `yaml:"remote,omitempty" json:"remote,omitempty" desc:"Remote server URL for this profile"`
+}
+
+// Tiers holds tier policies.
+type Tiers struct {
+	Inject []string `yaml:"inject" desc:"Tiers injected at session start when the default view is missing"`
+}
+
+// Hooks holds hook options.
+type Hooks struct {
+	PrimerFile      string `yaml:"primer_file" desc:"Markdown file replacing the built-in session primer"`
+	NudgeAfterTurns int    `yaml:"nudge_after_turns" desc:"Turns without a remember before nudging (0 disables)"`
+	// MaxRemembersPerMinute caps hook remembers; the overflow is held in a
+	// single review node instead of being stored.
+	MaxRemembersPerMinute int `yaml:"max_remembers_per_minute" env:"CTX_MAX_REMEMBERS_PER_MINUTE" desc:"Hook remembers allowed per minute; extras are held for review (0 disables)"`
+	// Budget is how long a hook may run before it answers with what it has
+	// and leaves the remaining work to a background `ctx hook flush`.
+	Budget time.Duration `yaml:"budget" env:"CTX_HOOK_BUDGET" desc:"Time a hook may take before deferring work to the background (0 disables)"`
+	// ResurfaceAfter turns on spaced resurfacing: session start asks about
+	// pinned and reference nodes untouched for this long.
+	ResurfaceAfter time.Duration `yaml:"resurface_after" env:"CTX_RESURFACE_AFTER" desc:"Resurface pinned/reference nodes unused for this long, e.g. 2160h (0 disables)"`
+	ResurfaceMax   int           `yaml:"resurface_max" desc:"Nodes resurfaced per session"`
+	// Strict turns off the parser's tolerance for single-quoted and
+	// unquoted attributes and nested commands.
+	Strict bool `yaml:"strict" env:"CTX_HOOK_STRICT" desc:"Only accept ctx commands with double-quoted attributes, dropping nested ones"`
+	// Quiet stops the Stop hook from telling the user which nodes the
+	// turn's commands stored, linked and superseded.
+	Quiet bool `yaml:"quiet" env:"CTX_HOOK_QUIET" desc:"Don't show a summary of what each turn's ctx commands changed"`
+}
+
+// Decay holds the rules ctx decay demotes idle nodes by. A node is idle
+// once it has gone this long without being updated, used or read.
+type Decay struct {
+	WorkingAfter   time.Duration `yaml:"working_after" env:"CTX_DECAY_WORKING_AFTER" desc:"Demote tier:working nodes idle this long to tier:reference (0 disables)"`
+	ReferenceAfter time.Duration `yaml:"reference_after" env:"CTX_DECAY_REFERENCE_AFTER" desc:"Demote tier:reference nodes idle this long to tier:off-context (0 disables)"`
+	OnSessionStart bool          `yaml:"on_session_start" env:"CTX_DECAY_ON_SESSION_START" desc:"Run ctx decay at every session start"`
+}
+
+// Timeouts bound how long query execution and compose may run, per entry
+// point. A hook that times out injects nothing rather than delaying the
+// prompt. Values are Go durations such as 5s; 0 disables the limit.
+type Timeouts struct {
+	Hook time.Duration `yaml:"hook" env:"CTX_HOOK_TIMEOUT" desc:"Query and compose deadline in hooks (0 disables)"`
+	CLI  time.Duration `yaml:"cli" env:"CTX_QUERY_TIMEOUT" desc:"Query and compose deadline for CLI commands (0 disables)"`
+	MCP  time.Duration `yaml:"mcp" env:"CTX_MCP_TIMEOUT" desc:"Query and compose deadline for MCP tools (0 disables)"`
+}
+
+// LLM configures the language model used by ctx consolidate. ctx doesn't
+// talk to a model API itself: it pipes the prompt to a command.
+type LLM struct {
+	Command string `yaml:"command" env:"CTX_LLM_COMMAND" desc:"Shell command that reads a prompt on stdin and prints the reply, e.g. claude -p"`
+}
+
+// Lang configures translation for stores shared by people who write in
+// different languages. Each node's language is detected when it is stored.
+type Lang struct {
+	Primary   string `yaml:"primary" env:"CTX_LANG_PRIMARY" desc:"Language code composed memory is read in, e.g. en"`
+	Translate bool   `yaml:"translate" env:"CTX_LANG_TRANSLATE" desc:"Translate composed nodes detected in other languages into lang.primary with llm.command"`
+}
+
+// Embeddings configures the vector provider behind semantic search.
+type Embeddings struct {
+	Provider string `yaml:"provider" env:"CTX_EMBEDDINGS_PROVIDER" desc:"Embeddings provider for semantic search: ollama or openai (any OpenAI-compatible API)"`
+	URL      string `yaml:"url" env:"CTX_EMBEDDINGS_URL" desc:"Provider base URL (default http://localhost:11434 for ollama, https://api.openai.com/v1 for openai)"`
+	Model    string `yaml:"model" env:"CTX_EMBEDDINGS_MODEL" desc:"Embedding model (default nomic-embed-text for ollama, text-embedding-3-small for openai)"`
+	// APIKeyEnv names the environment variable holding the API key, so the
+	// key itself stays out of the config file.
+	APIKeyEnv string `yaml:"api_key_env" desc:"Environment variable holding the provider API key (default OPENAI_API_KEY for openai)"`
+}
+
+// Display controls how times are shown in CLI output, the local web UI and
+// composed documents. Stored and exported times are always RFC3339 UTC.
+type Display struct {
+	Timezone      string `yaml:"timezone" env:"CTX_TIMEZONE" desc:"Time zone for displayed times: local, UTC or a name such as Europe/Berlin"`
+	RelativeTimes bool   `yaml:"relative_times" env:"CTX_RELATIVE_TIMES" desc:"Show times in lists as \"3 days ago\" instead of dates"`
+}
+
+// Defaults returns the built-in settings.
+func Defaults() *Config {
+	db := ""
+	if home, err := os.UserHomeDir(); err == nil {
+		db = filepath.Join(home, ".ctx", "store.db")
+	}
+	return &Config{
+		DB:            db,
+		Backend:       "sqlite",
+		DefaultBudget: DefaultBudget,
+		DefaultView:   DefaultView,
+		MaxNodeTokens: DefaultMaxNodeTokens,
+		Tiers:         Tiers{Inject: []string{"pinned", "working"}},
+		Hooks:         Hooks{NudgeAfterTurns: DefaultNudgeTurns, MaxRemembersPerMinute: DefaultRememberRate, Budget: DefaultHookBudget, ResurfaceMax: DefaultResurfaceMax},
+		Decay:         Decay{WorkingAfter: DefaultDecayWorking, ReferenceAfter: DefaultDecayReference},
+		Timeouts:      Timeouts{Hook: DefaultHookTimeout, MCP: DefaultMCPTimeout},
+	}
+}
+
+// Path returns the config file path: CTX_CONFIG, or ~/.ctx/config.yaml.
+func Path() string {
+	if p := os.Getenv("CTX_CONFIG"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ctx", "config.yaml")
+}
+
+// Load returns the effective settings. Missing or malformed files are
+// ignored, as are unparseable environment values and unknown profiles, so
+// callers always get a usable config; `ctx config list` reports file errors.
+func Load() *Config {
+	cfg, _ := LoadProfile("")
+	return cfg
+}
+
+// LoadProfile is Load with the named profile active instead of the one
+// chosen by CTX_PROFILE or the profile key; an empty name keeps that
+// choice. An unknown profile is reported alongside the settings without it.
+func LoadProfile(name string) (*Config, error) {
+	cfg := Defaults()
+	if home, err := os.UserHomeDir(); err == nil {
+		if data, err := os.ReadFile(filepath.Join(home, ".ctx", "server.yaml")); err == nil {
+			var legacy struct {
+				AutoSync      *bool `yaml:"auto_sync"`
+				Inbox         *bool `yaml:"inbox"`
+				MaxNodeTokens *int  `yaml:"max_node_tokens"`
+			}
+			if yaml.Unmarshal(data, &legacy) == nil {
+				if legacy.AutoSync != nil {
+					cfg.AutoSync = *legacy.AutoSync
+				}
+				if legacy.Inbox != nil {
+					cfg.Inbox = *legacy.Inbox
+				}
+				if legacy.MaxNodeTokens != nil {
+					cfg.MaxNodeTokens = *legacy.MaxNodeTokens
+				}
+			}
+		}
+	}
+	_ = cfg.loadFile(Path())
+
+	if name == "" {
+		name = os.Getenv("CTX_PROFILE")
+	}
+	if name == "" {
+		name = cfg.Profile
+	}
+	var err error
+	if name != "" {
+		if p, ok := cfg.Profiles[name]; ok {
+			cfg.useProfile(p)
+			cfg.Profile = name
+		} else {
+			cfg.Profile = ""
+			err = fmt.Errorf("unknown profile %q (see ctx config profiles)", name)
+		}
+	}
+
+	cfg.applyEnv()
+	return cfg, err
+}
+
+// useProfile overlays the non-empty fields of p.
+func (c *Config) useProfile(p Profile) {
+	if p.DB != "" {
+		c.DB = p.DB
+	}
+	if p.Backend != "" {
+		c.Backend = p.Backend
+	}
+	if p.Agent != "" {
+		c.Agent = p.Agent
+	}
+	if p.Remote != "" {
+		c.Remote = p.Remote
+	}
+}
+
+// ProfileNames returns the configured profile names, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StateDir returns the directory holding remote.json, auth.json and
+// sync_state.json: ~/.ctx, or ~/.ctx/profiles/<name> when a profile is
+// active.
+func (c *Config) StateDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	if c.Profile == "" {
+		return filepath.Join(home, ".ctx")
+	}
+	return filepath.Join(home, ".ctx", "profiles", c.Profile)
+}
+
+// loadFile overlays the keys present in the YAML file at path onto c.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) applyEnv() {
+	for _, f := range fields(c) {
+		if f.env == "" {
+			continue
+		}
+		if v, ok := os.LookupEnv(f.env); ok && v != "" {
+			_ = setValue(f.value, []string{v})
+		}
+	}
+}
+
+// CheckFile reports whether the config file, if present, parses.
+func CheckFile() error {
+	return Defaults().loadFile(Path())
+}
+
+// Keys returns every setting key, sorted.
+func Keys() []string {
+	var keys []string
+	for _, f := range fields(Defaults()) {
+		keys = append(keys, f.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Describe returns the help text for key.
+func Describe(key string) string {
+	for _, f := range fields(Defaults()) {
+		if f.key == key {
+			return f.desc
+		}
+	}
+	return ""
+}
+
+// Get returns the value of key; list items are separated by commas.
+func (c *Config) Get(key string) (string, error) {
+	if name, sub, ok := profileKey(key); ok {
+		p := c.Profiles[name]
+		f, err := lookupIn(reflect.ValueOf(&p).Elem(), sub)
+		if err != nil {
+			return "", err
+		}
+		return formatValue(f.value), nil
+	}
+	f, err := lookup(c, key)
+	if err != nil {
+		return "", err
+	}
+	return formatValue(f.value), nil
+}
+
+// Set parses values into key, validating them. List keys take one value
+// per item; other keys take exactly one value. Profile settings use keys
+// of the form profiles.<name>.<setting>.
+func (c *Config) Set(key string, values ...string) error {
+	if name, sub, ok := profileKey(key); ok {
+		p := c.Profiles[name]
+		f, err := lookupIn(reflect.ValueOf(&p).Elem(), sub)
+		if err != nil {
+			return err
+		}
+		if err := setValue(f.value, values); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		if c.Profiles == nil {
+			c.Profiles = make(map[string]Profile)
+		}
+		c.Profiles[name] = p
+		return nil
+	}
+	f, err := lookup(c, key)
+	if err != nil {
+		return err
+	}
+	if err := setValue(f.value, values); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	if key == "display.timezone" {
+		if _, err := timefmt.LoadLocation(c.Display.Timezone); err != nil {
+			return err
+		}
+	}
+	if key == "redact" {
+		for _, p := range c.RedactPatterns {
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("invalid redact pattern %q: %w", p, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Save sets key in the config file at path, keeping every other key and
+// comment in the file as it is.
+func Save(path, key string, values ...string) error {
+	if err := Defaults().Set(key, values...); err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	node := doc.Content[0]
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		node = mappingChild(node, part, yaml.MappingNode)
+	}
+	leaf := mappingChild(node, parts[len(parts)-1], yaml.ScalarNode)
+
+	isList := false
+	if f, err := lookup(Defaults(), key); err == nil {
+		isList = f.value.Kind() == reflect.Slice
+	}
+	if isList {
+		*leaf = yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range values {
+			leaf.Content = append(leaf.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item})
+		}
+	} else {
+		*leaf = yaml.Node{Kind: yaml.ScalarNode, Value: values[0]}
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out, 0644)
+}
+
+// mappingChild returns the value node for key in mapping m, adding one of
+// the given kind if the key is missing.
+func mappingChild(m *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	k := &yaml.Node{Kind: yaml.ScalarNode, Value: key}
+	v := &yaml.Node{Kind: kind}
+	m.Content = append(m.Content, k, v)
+	return v
+}
+
+// Redact replaces every match of the configured redact patterns in content
+// with [REDACTED]. Invalid patterns are skipped.
+func (c *Config) Redact(content string) string {
+	for _, p := range c.RedactPatterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			continue
+		}
+		content = re.ReplaceAllString(content, "[REDACTED]")
+	}
+	return content
+}
+
+// InjectQuery returns the query for the configured session-start tiers.
+func (c *Config) InjectQuery() string {
+	parts := make([]string, 0, len(c.Tiers.Inject))
+	for _, t := range c.Tiers.Inject {
+		parts = append(parts, "tag:tier:"+strings.TrimPrefix(t, "tier:"))
+	}
+	return strings.Join(parts, " OR ")
+}
+
+// Clock returns a timefmt.Clock for the display settings.
+func (c *Config) Clock() *timefmt.Clock {
+	return timefmt.New(c.Display.Timezone, c.Display.RelativeTimes)
+}
+
+// --- reflection over Config fields ---
+
+type field struct {
+	key   string
+	env   string
+	desc  string
+	value reflect.Value
+}
+
+func fields(c *Config) []field {
+	return fieldsOf(reflect.ValueOf(c).Elem(), "")
+}
+
+// fieldsOf lists the leaf settings of struct v. Maps (profiles) are not
+// settings themselves and are skipped.
+func fieldsOf(v reflect.Value, prefix string) []field {
+	var out []field
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := prefix + strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		switch sf.Type.Kind() {
+		case reflect.Struct:
+			out = append(out, fieldsOf(v.Field(i), key+".")...)
+		case reflect.Map:
+		default:
+			out = append(out, field{key: key, env: sf.Tag.Get("env"), desc: sf.Tag.Get("desc"), value: v.Field(i)})
+		}
+	}
+	return out
+}
+
+func lookup(c *Config, key string) (field, error) {
+	return lookupIn(reflect.ValueOf(c).Elem(), key)
+}
+
+func lookupIn(v reflect.Value, key string) (field, error) {
+	for _, f := range fieldsOf(v, "") {
+		if f.key == key {
+			return f, nil
+		}
+	}
+	return field{}, fmt.Errorf("unknown config key %q (see ctx config list)", key)
+}
+
+// profileKey splits profiles.<name>.<setting> into its name and setting.
+func profileKey(key string) (name, sub string, ok bool) {
+	rest, found := strings.CutPrefix(key, "profiles.")
+	if !found {
+		return "", "", false
+	}
+	name, sub, ok = strings.Cut(rest, ".")
+	return name, sub, ok && name != ""
+}
+
+func setValue(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.ValueOf(append([]string{}, values...)))
+		return nil
+	}
+	if len(values) != 1 {
+		return fmt.Errorf("expected one value")
+	}
+	s := values[0]
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return fmt.Errorf("expected a duration such as 5s or 2m")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return fmt.Errorf("expected a non-negative integer")
+		}
+		v.SetInt(int64(n))
+	}
+	return nil
+}
+
+func formatValue(v reflect.Value) string {
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
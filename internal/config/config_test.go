@@ -0,0 +1,200 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/config"
+)
+
+// setHome points HOME at a fresh directory and clears overrides so Load
+// only sees files the test writes.
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CTX_CONFIG", "")
+	for _, env := range []string{"CTX_DB", "CTX_BACKEND", "CTX_AGENT", "CTX_DEFAULT_BUDGET", "CTX_DEFAULT_VIEW", "CTX_AUTO_SYNC", "CTX_INBOX", "CTX_MAX_NODE_TOKENS", "CTX_REMOTE", "CTX_PROFILE", "CTX_MAX_REMEMBERS_PER_MINUTE", "CTX_HOOK_TIMEOUT", "CTX_QUERY_TIMEOUT", "CTX_MCP_TIMEOUT", "CTX_HOOK_BUDGET", "CTX_RESURFACE_AFTER", "CTX_LLM_COMMAND", "CTX_EMBEDDINGS_PROVIDER", "CTX_EMBEDDINGS_URL", "CTX_EMBEDDINGS_MODEL"} {
+		t.Setenv(env, "")
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".ctx"), 0755))
+	return home
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	home := setHome(t)
+
+	cfg := config.Load()
+	assert.Equal(t, filepath.Join(home, ".ctx", "store.db"), cfg.DB)
+	assert.Equal(t, config.DefaultBudget, cfg.DefaultBudget)
+	assert.Equal(t, config.DefaultView, cfg.DefaultView)
+	assert.Equal(t, config.DefaultMaxNodeTokens, cfg.MaxNodeTokens)
+	assert.Equal(t, []string{"pinned", "working"}, cfg.Tiers.Inject)
+	assert.False(t, cfg.AutoSync)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	home := setHome(t)
+	writeFile(t, filepath.Join(home, ".ctx", "server.yaml"), "auto_sync: true\ninbox: true\nmax_node_tokens: 100\n")
+	writeFile(t, filepath.Join(home, ".ctx", "config.yaml"), "inbox: false\ndefault_budget: 9000\nhooks:\n  nudge_after_turns: 0\ntimeouts:\n  hook: 750ms\n")
+	t.Setenv("CTX_DEFAULT_BUDGET", "1234")
+
+	cfg := config.Load()
+	assert.True(t, cfg.AutoSync, "legacy server.yaml key")
+	assert.False(t, cfg.Inbox, "config.yaml overrides server.yaml")
+	assert.Equal(t, 100, cfg.MaxNodeTokens)
+	assert.Equal(t, 1234, cfg.DefaultBudget, "env overrides config.yaml")
+	assert.Equal(t, 0, cfg.Hooks.NudgeAfterTurns)
+	assert.Equal(t, 750*time.Millisecond, cfg.Timeouts.Hook)
+}
+
+func TestLoad_CtxConfigPath(t *testing.T) {
+	setHome(t)
+	path := filepath.Join(t.TempDir(), "custom.yaml")
+	writeFile(t, path, "default_view: onboarding\n")
+	t.Setenv("CTX_CONFIG", path)
+
+	assert.Equal(t, path, config.Path())
+	assert.Equal(t, "onboarding", config.Load().DefaultView)
+}
+
+func TestLoad_MalformedFile(t *testing.T) {
+	home := setHome(t)
+	writeFile(t, filepath.Join(home, ".ctx", "config.yaml"), "default_budget: [oops\n")
+
+	assert.Equal(t, config.DefaultBudget, config.Load().DefaultBudget)
+	assert.Error(t, config.CheckFile())
+}
+
+func TestSet_Validates(t *testing.T) {
+	cfg := config.Defaults()
+
+	require.NoError(t, cfg.Set("default_budget", "2000"))
+	assert.Equal(t, 2000, cfg.DefaultBudget)
+
+	assert.Error(t, cfg.Set("default_budget", "lots"))
+	assert.Error(t, cfg.Set("default_budget", "-1"))
+	assert.Error(t, cfg.Set("auto_sync", "maybe"))
+	assert.Error(t, cfg.Set("default_view", "a", "b"))
+	assert.Error(t, cfg.Set("redact", "("))
+	assert.Error(t, cfg.Set("no_such_key", "x"))
+
+	require.NoError(t, cfg.Set("timeouts.hook", "2s"))
+	assert.Equal(t, 2*time.Second, cfg.Timeouts.Hook)
+	assert.Error(t, cfg.Set("timeouts.hook", "soon"))
+
+	require.NoError(t, cfg.Set("display.timezone", "UTC"))
+	assert.Error(t, cfg.Set("display.timezone", "Mars/Olympus"))
+
+	require.NoError(t, cfg.Set("tiers.inject", "pinned", "reference"))
+	got, err := cfg.Get("tiers.inject")
+	require.NoError(t, err)
+	assert.Equal(t, "pinned,reference", got)
+}
+
+func TestSave_PreservesComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "# my settings\nagent: alice # who I am\nhooks:\n  primer_file: /tmp/primer.md\n")
+
+	require.NoError(t, config.Save(path, "hooks.nudge_after_turns", "8"))
+	require.NoError(t, config.Save(path, "redact", "secret-[0-9]+"))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# my settings")
+	assert.Contains(t, string(data), "# who I am")
+
+	t.Setenv("CTX_CONFIG", path)
+	cfg := config.Load()
+	assert.Equal(t, "alice", cfg.Agent)
+	assert.Equal(t, "/tmp/primer.md", cfg.Hooks.PrimerFile)
+	assert.Equal(t, 8, cfg.Hooks.NudgeAfterTurns)
+	assert.Equal(t, []string{"secret-[0-9]+"}, cfg.RedactPatterns)
+}
+
+func TestSave_RejectsInvalidValue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+
+	assert.Error(t, config.Save(path, "inbox", "sometimes"))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "nothing written on a bad value")
+}
+
+func TestRedact(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.RedactPatterns = []string{`sk-[a-z0-9]+`, `(`}
+
+	assert.Equal(t, "key [REDACTED] here", cfg.Redact("key sk-abc123 here"))
+}
+
+func TestInjectQuery(t *testing.T) {
+	cfg := config.Defaults()
+	assert.Equal(t, "tag:tier:pinned OR tag:tier:working", cfg.InjectQuery())
+
+	cfg.Tiers.Inject = []string{"tier:reference"}
+	assert.Equal(t, "tag:tier:reference", cfg.InjectQuery())
+}
+
+func TestLoadProfile(t *testing.T) {
+	home := setHome(t)
+	writeFile(t, filepath.Join(home, ".ctx", "config.yaml"), `agent: me
+profile: personal
+profiles:
+  personal:
+    db: /tmp/personal.db
+  work:
+    db: postgres://ctx@db/ctx
+    remote: https://ctx.example.com
+`)
+
+	cfg := config.Load()
+	assert.Equal(t, "personal", cfg.Profile)
+	assert.Equal(t, "/tmp/personal.db", cfg.DB)
+	assert.Equal(t, "me", cfg.Agent, "unset profile fields keep the top-level value")
+	assert.Equal(t, filepath.Join(home, ".ctx", "profiles", "personal"), cfg.StateDir())
+
+	t.Setenv("CTX_PROFILE", "work")
+	cfg = config.Load()
+	assert.Equal(t, "postgres://ctx@db/ctx", cfg.DB)
+	assert.Equal(t, "https://ctx.example.com", cfg.Remote)
+
+	cfg, err := config.LoadProfile("personal")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/personal.db", cfg.DB, "explicit name beats CTX_PROFILE")
+	assert.Equal(t, []string{"personal", "work"}, cfg.ProfileNames())
+
+	t.Setenv("CTX_DB", "/tmp/env.db")
+	cfg, _ = config.LoadProfile("work")
+	assert.Equal(t, "/tmp/env.db", cfg.DB, "env overrides the profile")
+
+	cfg, err = config.LoadProfile("demo")
+	assert.Error(t, err)
+	assert.Equal(t, "", cfg.Profile)
+	assert.Equal(t, filepath.Join(home, ".ctx"), cfg.StateDir())
+}
+
+func TestSave_ProfileKey(t *testing.T) {
+	setHome(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CTX_CONFIG", path)
+
+	require.NoError(t, config.Save(path, "profiles.work.db", "/tmp/work.db"))
+	require.NoError(t, config.Save(path, "profiles.work.remote", "https://ctx.example.com"))
+	assert.Error(t, config.Save(path, "profiles.work.token", "x"))
+
+	cfg, err := config.LoadProfile("work")
+	require.NoError(t, err)
+	assert.Equal(t, "/tmp/work.db", cfg.DB)
+	got, err := cfg.Get("profiles.work.remote")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ctx.example.com", got)
+}
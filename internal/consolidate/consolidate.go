@@ -0,0 +1,225 @@
+// Package consolidate merges clusters of related working and reference
+// nodes into summary nodes written by a language model, shrinking the
+// token footprint of old projects. Clusters are found through edges and
+// shared topic tags; the summaries come from the model run by package llm.
+package consolidate
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+)
+
+// Defaults for Options.
+const (
+	DefaultMinSize = 3
+	DefaultMaxSize = 12
+)
+
+// Options controls FindClusters.
+type Options struct {
+	Project   string        // required: only nodes tagged project:<Project>
+	Agent     string        // agent scope, as for other commands
+	MinSize   int           // smallest cluster worth merging; DefaultMinSize when <= 0
+	MaxSize   int           // larger groups are split; DefaultMaxSize when <= 0
+	OlderThan time.Duration // skip nodes updated more recently than this
+	Now       time.Time     // zero means time.Now()
+}
+
+// Cluster is a group of related nodes to merge into one summary.
+type Cluster struct {
+	Nodes  []*db.Node
+	Tags   []string // topic tags shared by every node
+	Tokens int      // combined token estimate of the nodes
+}
+
+// FindClusters groups the project's working and reference nodes. Nodes are
+// related when an edge joins them or they share a topic tag (a tag without
+// a namespace, like "auth"). Groups smaller than MinSize are left alone;
+// groups larger than MaxSize are split in creation order.
+func FindClusters(d db.Store, opts Options) ([]Cluster, error) {
+	if opts.Project == "" {
+		return nil, fmt.Errorf("a project is required")
+	}
+	minSize, maxSize := opts.MinSize, opts.MaxSize
+	if minSize <= 0 {
+		minSize = DefaultMinSize
+	}
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if maxSize < minSize {
+		maxSize = minSize
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	all, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	all = agentpkg.FilterNodes(all, opts.Agent)
+
+	var nodes []*db.Node
+	index := make(map[string]int)
+	for _, n := range all {
+		if n.Type == "summary" || n.Type == "entity" || !candidateTier(n.Tags) || !hasTag(n.Tags, "project:"+opts.Project) {
+			continue
+		}
+		if opts.OlderThan > 0 && now.Sub(n.UpdatedAt) < opts.OlderThan {
+			continue
+		}
+		index[n.ID] = len(nodes)
+		nodes = append(nodes, n)
+	}
+
+	parent := make([]int, len(nodes))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) { parent[find(a)] = find(b) }
+
+	edges, err := d.Query("SELECT from_id, to_id FROM edges")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read edges: %w", err)
+	}
+	for edges.Next() {
+		var from, to string
+		if err := edges.Scan(&from, &to); err != nil {
+			edges.Close()
+			return nil, err
+		}
+		a, okA := index[from]
+		b, okB := index[to]
+		if okA && okB {
+			union(a, b)
+		}
+	}
+	edges.Close()
+	if err := edges.Err(); err != nil {
+		return nil, err
+	}
+
+	byTopic := make(map[string]int)
+	for i, n := range nodes {
+		for _, t := range topicTags(n.Tags) {
+			if j, ok := byTopic[t]; ok {
+				union(i, j)
+			} else {
+				byTopic[t] = i
+			}
+		}
+	}
+
+	groups := make(map[int][]*db.Node)
+	for i, n := range nodes {
+		root := find(i)
+		groups[root] = append(groups[root], n)
+	}
+
+	var clusters []Cluster
+	for _, group := range groups {
+		sort.Slice(group, func(i, j int) bool { return group[i].ID < group[j].ID })
+		for start := 0; start < len(group); start += maxSize {
+			end := min(start+maxSize, len(group))
+			if end-start < minSize {
+				continue
+			}
+			clusters = append(clusters, newCluster(group[start:end]))
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Tokens != clusters[j].Tokens {
+			return clusters[i].Tokens > clusters[j].Tokens
+		}
+		return clusters[i].Nodes[0].ID < clusters[j].Nodes[0].ID
+	})
+	return clusters, nil
+}
+
+func newCluster(nodes []*db.Node) Cluster {
+	c := Cluster{Nodes: nodes}
+	counts := make(map[string]int)
+	for _, n := range nodes {
+		c.Tokens += n.TokenEstimate
+		for _, t := range topicTags(n.Tags) {
+			counts[t]++
+		}
+	}
+	for t, count := range counts {
+		if count == len(nodes) {
+			c.Tags = append(c.Tags, t)
+		}
+	}
+	sort.Strings(c.Tags)
+	return c
+}
+
+// Prompt asks the model for one summary node covering the cluster.
+func Prompt(c Cluster) string {
+	var b strings.Builder
+	b.WriteString("These notes are stored memories from a software project. Merge them into one concise summary ")
+	b.WriteString("that keeps every decision, fact, constraint and pattern still worth knowing, and drops repetition ")
+	b.WriteString("and transient detail. Reply with the summary text only, in Markdown, without a preamble.\n\n")
+	for _, n := range c.Nodes {
+		fmt.Fprintf(&b, "--- [%s:%s] %s\n%s\n\n", n.Type, n.ID, n.CreatedAt.Format("2006-01-02"), strings.TrimSpace(n.Content))
+	}
+	return b.String()
+}
+
+// Apply stores summary as a tier:reference summary node for the project,
+// scoped to opts.Agent like the nodes it was clustered from, DERIVED_FROM
+// each node in the cluster, and moves the cluster's nodes to
+// tier:off-context. It does all of that or, on failure, none of it.
+func Apply(d db.Store, c Cluster, opts Options, summary string) (*db.Node, error) {
+	tags := append([]string{"tier:reference", "project:" + opts.Project}, c.Tags...)
+	if at := agentpkg.Tag(opts.Agent); at != "" {
+		tags = append(tags, at)
+	}
+	ids := make([]string, len(c.Nodes))
+	for i, n := range c.Nodes {
+		ids[i] = n.ID
+	}
+	return d.Summarize(db.CreateNodeInput{
+		Type:    "summary",
+		Content: summary,
+		Tags:    tags,
+	}, ids, true)
+}
+
+func candidateTier(tags []string) bool {
+	return hasTag(tags, "tier:working") || hasTag(tags, "tier:reference")
+}
+
+// topicTags returns the tags without a namespace.
+func topicTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		if t != "" && !strings.Contains(t, ":") {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
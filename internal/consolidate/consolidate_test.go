@@ -0,0 +1,113 @@
+package consolidate_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/consolidate"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func create(t *testing.T, d db.Store, content string, tags ...string) *db.Node {
+	t.Helper()
+	n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: content, Tags: tags})
+	require.NoError(t, err)
+	return n
+}
+
+func TestFindClusters(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// Three auth notes share a topic tag; two storage notes are linked to
+	// a third by edges.
+	a1 := create(t, d, "tokens last an hour", "tier:reference", "project:app", "auth")
+	create(t, d, "refresh tokens rotate", "tier:working", "project:app", "auth")
+	create(t, d, "login uses OIDC", "tier:reference", "project:app", "auth")
+	s1 := create(t, d, "sqlite in WAL mode", "tier:working", "project:app")
+	s2 := create(t, d, "busy timeout 5s", "tier:working", "project:app")
+	s3 := create(t, d, "vacuum weekly", "tier:working", "project:app")
+	_, _ = d.CreateEdge(s1.ID, s2.ID, "RELATES_TO")
+	_, _ = d.CreateEdge(s2.ID, s3.ID, "RELATES_TO")
+
+	// Not candidates: pinned, another project, too small a group.
+	create(t, d, "pinned auth rule", "tier:pinned", "project:app", "auth")
+	create(t, d, "other auth", "tier:reference", "project:other", "auth")
+	create(t, d, "lonely", "tier:working", "project:app", "misc")
+
+	clusters, err := consolidate.FindClusters(d, consolidate.Options{Project: "app"})
+	require.NoError(t, err)
+	require.Len(t, clusters, 2)
+
+	var auth *consolidate.Cluster
+	for i := range clusters {
+		assert.Len(t, clusters[i].Nodes, 3)
+		if len(clusters[i].Tags) > 0 {
+			auth = &clusters[i]
+		}
+	}
+	require.NotNil(t, auth)
+	assert.Equal(t, []string{"auth"}, auth.Tags)
+	assert.Contains(t, consolidate.Prompt(*auth), a1.ID)
+
+	clusters, err = consolidate.FindClusters(d, consolidate.Options{Project: "app", MaxSize: 2, MinSize: 2})
+	require.NoError(t, err)
+	assert.Len(t, clusters, 2, "groups of three split into a pair and a leftover single")
+
+	_, err = consolidate.FindClusters(d, consolidate.Options{})
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	n1 := create(t, d, "first", "tier:working", "project:app", "auth")
+	n2 := create(t, d, "second", "tier:reference", "project:app", "auth")
+	c := consolidate.Cluster{Nodes: []*db.Node{n1, n2}, Tags: []string{"auth"}}
+
+	summary, err := consolidate.Apply(d, c, consolidate.Options{Project: "app"}, "first and second")
+	require.NoError(t, err)
+	assert.Equal(t, "summary", summary.Type)
+	assert.ElementsMatch(t, []string{"tier:reference", "project:app", "auth"}, summary.Tags)
+
+	edges, err := d.GetEdges(summary.ID, "out")
+	require.NoError(t, err)
+	assert.Len(t, edges, 2)
+
+	tags, err := d.GetTags(n2.ID)
+	require.NoError(t, err)
+	assert.Contains(t, tags, "tier:off-context")
+	assert.NotContains(t, tags, "tier:reference")
+}
+
+func TestApply_KeepsAgentScope(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	n1 := create(t, d, "first", "tier:working", "project:app", "agent:bot")
+	n2 := create(t, d, "second", "tier:working", "project:app", "agent:bot")
+	c := consolidate.Cluster{Nodes: []*db.Node{n1, n2}}
+
+	summary, err := consolidate.Apply(d, c, consolidate.Options{Project: "app", Agent: "bot"}, "first and second")
+	require.NoError(t, err)
+	assert.Contains(t, summary.Tags, "agent:bot", "private memory stays private")
+}
+
+func TestApply_AllOrNothing(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	n1 := create(t, d, "first", "tier:working", "project:app")
+	gone := create(t, d, "second", "tier:working", "project:app")
+	require.NoError(t, d.DeleteNode(gone.ID))
+	c := consolidate.Cluster{Nodes: []*db.Node{n1, gone}}
+
+	_, err := consolidate.Apply(d, c, consolidate.Options{Project: "app"}, "first and second")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	summaries, err := d.ListNodes(db.ListOptions{Type: "summary"})
+	require.NoError(t, err)
+	assert.Empty(t, summaries)
+	tags, err := d.GetTags(n1.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"project:app", "tier:working"}, tags, "the first node is not archived")
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// NodeAccess counts how often a node was read: returned by a recall,
+// composed into context or shown. Unlike NodeUsage it counts every read,
+// not once per session, so it tells which memories are read at all.
+type NodeAccess struct {
+	NodeID         string    `json:"node_id"`
+	AccessCount    int       `json:"access_count"`
+	LastAccessedAt time.Time `json:"last_accessed_at"`
+}
+
+func (d *SQLiteStore) RecordAccess(nodeIDs []string) error {
+	return recordAccess(d, nodeIDs)
+}
+
+func (d *SQLiteStore) GetNodeAccess(nodeID string) (*NodeAccess, error) {
+	return getNodeAccess(d, nodeID)
+}
+
+func (d *PostgresStore) RecordAccess(nodeIDs []string) error {
+	return recordAccess(d, nodeIDs)
+}
+
+func (d *PostgresStore) GetNodeAccess(nodeID string) (*NodeAccess, error) {
+	return getNodeAccess(d, nodeID)
+}
+
+// recordAccess implements RecordAccess for both stores, in one
+// transaction. IDs of nodes that no longer exist are skipped, and an ID
+// given twice counts once.
+func recordAccess(d Store, nodeIDs []string) error {
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := d.Rebind(`INSERT INTO node_access (node_id, access_count, last_accessed_at)
+		SELECT id, 1, ? FROM nodes WHERE id = ?
+		ON CONFLICT (node_id) DO UPDATE SET
+			access_count = node_access.access_count + 1,
+			last_accessed_at = excluded.last_accessed_at`)
+	seen := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := tx.Exec(stmt, now, id); err != nil {
+			return fmt.Errorf("failed to record access: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// getNodeAccess implements GetNodeAccess for both stores.
+func getNodeAccess(d Store, nodeID string) (*NodeAccess, error) {
+	a := NodeAccess{NodeID: nodeID}
+	var last string
+	err := d.QueryRow("SELECT access_count, last_accessed_at FROM node_access WHERE node_id = ?", nodeID).Scan(&a.AccessCount, &last)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node access: %w", err)
+	}
+	a.LastAccessedAt, _ = time.Parse(time.RFC3339, last)
+	return &a, nil
+}
@@ -0,0 +1,38 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRecordAccess(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	a := testutil.CreateNode(t, d, "fact", "a")
+	b := testutil.CreateNode(t, d, "fact", "b")
+
+	_, err := d.GetNodeAccess(a.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound, "never read")
+
+	before := time.Now().Add(-time.Second)
+	require.NoError(t, d.RecordAccess([]string{a.ID, b.ID, a.ID}))
+	require.NoError(t, d.RecordAccess([]string{a.ID, db.NewID()}), "a missing node is skipped")
+	require.NoError(t, d.RecordAccess(nil))
+
+	got, err := d.GetNodeAccess(a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.AccessCount, "an ID repeated in one call counts once")
+	assert.True(t, got.LastAccessedAt.After(before))
+	got, err = d.GetNodeAccess(b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.AccessCount)
+
+	// Access goes with the node
+	require.NoError(t, d.DeleteNode(a.ID))
+	_, err = d.GetNodeAccess(a.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+}
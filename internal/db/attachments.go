@@ -0,0 +1,188 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// MaxAttachmentSize caps the size of a single attachment. Attachments live
+// in the database next to the node, so they are meant for screenshots,
+// PDFs and source files rather than datasets.
+const MaxAttachmentSize = 32 << 20
+
+// ErrAttachmentTooLarge is returned by AddAttachment for data over
+// MaxAttachmentSize.
+var ErrAttachmentTooLarge = fmt.Errorf("attachment exceeds %d MiB", MaxAttachmentSize>>20)
+
+// Attachment describes a file kept with a node, such as a screenshot, a
+// PDF or the source a fact was taken from. Its data is loaded separately,
+// by GetAttachment.
+type Attachment struct {
+	ID        string    `json:"id"`
+	NodeID    string    `json:"node_id"`
+	Name      string    `json:"name"`
+	MediaType string    `json:"media_type"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (d *SQLiteStore) AddAttachment(nodeID, name, mediaType string, data []byte) (*Attachment, error) {
+	return addAttachment(d, nodeID, name, mediaType, data)
+}
+
+func (d *SQLiteStore) ListAttachments(nodeID string) ([]*Attachment, error) {
+	return listAttachments(d, nodeID)
+}
+
+func (d *SQLiteStore) GetAttachment(id string) (*Attachment, []byte, error) {
+	return getAttachment(d, id)
+}
+
+func (d *SQLiteStore) DeleteAttachment(id string) error {
+	return deleteAttachment(d, id)
+}
+
+func (d *PostgresStore) AddAttachment(nodeID, name, mediaType string, data []byte) (*Attachment, error) {
+	return addAttachment(d, nodeID, name, mediaType, data)
+}
+
+func (d *PostgresStore) ListAttachments(nodeID string) ([]*Attachment, error) {
+	return listAttachments(d, nodeID)
+}
+
+func (d *PostgresStore) GetAttachment(id string) (*Attachment, []byte, error) {
+	return getAttachment(d, id)
+}
+
+func (d *PostgresStore) DeleteAttachment(id string) error {
+	return deleteAttachment(d, id)
+}
+
+// addAttachment implements AddAttachment for both stores. The name is
+// reduced to its base name; an empty media type is sniffed from the name's
+// extension, then from the data.
+func addAttachment(d Store, nodeID, name, mediaType string, data []byte) (*Attachment, error) {
+	name = path.Base(strings.ReplaceAll(strings.TrimSpace(name), `\`, "/"))
+	if name == "." || name == "/" {
+		return nil, fmt.Errorf("attachment name cannot be empty")
+	}
+	if len(data) > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+	if mediaType == "" {
+		mediaType = AttachmentMediaType(name, data)
+	}
+	if data == nil {
+		data = []byte{} // an empty file, not NULL
+	}
+
+	var one int
+	if err := d.QueryRow("SELECT 1 FROM nodes WHERE id = ?", nodeID).Scan(&one); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to check node: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	a := &Attachment{
+		ID:        NewID(),
+		NodeID:    nodeID,
+		Name:      name,
+		MediaType: mediaType,
+		Size:      int64(len(data)),
+		SHA256:    hex.EncodeToString(sum[:]),
+		CreatedAt: time.Now().UTC(),
+	}
+	_, err := d.Exec(`INSERT INTO attachments (id, node_id, name, media_type, size, sha256, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.NodeID, a.Name, a.MediaType, a.Size, a.SHA256, data, a.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to add attachment: %w", err)
+	}
+	return a, nil
+}
+
+// AttachmentMediaType guesses the media type of a file from its name's
+// extension, falling back to sniffing its content.
+func AttachmentMediaType(name string, data []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return "text/markdown; charset=utf-8"
+	case ".go", ".py", ".rs", ".ts", ".sh", ".sql", ".yaml", ".yml", ".toml", ".txt", ".log":
+		return "text/plain; charset=utf-8"
+	case ".json":
+		return "application/json"
+	case ".svg":
+		return "image/svg+xml"
+	}
+	return http.DetectContentType(data)
+}
+
+// listAttachments implements ListAttachments for both stores, oldest first.
+func listAttachments(d Store, nodeID string) ([]*Attachment, error) {
+	rows, err := d.Query(`SELECT id, node_id, name, media_type, size, sha256, created_at
+		FROM attachments WHERE node_id = ? ORDER BY id`, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list attachments: %w", err)
+	}
+	defer rows.Close()
+	var out []*Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// getAttachment implements GetAttachment for both stores.
+func getAttachment(d Store, id string) (*Attachment, []byte, error) {
+	var data []byte
+	a, err := scanAttachment(func(dest ...any) error {
+		return d.QueryRow(`SELECT id, node_id, name, media_type, size, sha256, created_at, data
+			FROM attachments WHERE id = ?`, id).Scan(append(dest, &data)...)
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, data, nil
+}
+
+// deleteAttachment implements DeleteAttachment for both stores.
+func deleteAttachment(d Store, id string) error {
+	res, err := d.Exec("DELETE FROM attachments WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete attachment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAttachment(scan func(dest ...any) error) (*Attachment, error) {
+	var a Attachment
+	var createdAt string
+	if err := scan(&a.ID, &a.NodeID, &a.Name, &a.MediaType, &a.Size, &a.SHA256, &createdAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan attachment: %w", err)
+	}
+	a.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return &a, nil
+}
@@ -0,0 +1,73 @@
+package db_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestAttachments(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node := testutil.CreateNode(t, d, "fact", "The checkout page renders blank on Safari")
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 64)...)
+	shot, err := d.AddAttachment(node.ID, "/tmp/shots/blank.png", "", png)
+	require.NoError(t, err)
+	assert.Equal(t, "blank.png", shot.Name, "only the base name is kept")
+	assert.Equal(t, "image/png", shot.MediaType)
+	assert.Equal(t, int64(len(png)), shot.Size)
+	assert.Len(t, shot.SHA256, 64)
+
+	notes, err := d.AddAttachment(node.ID, `C:\notes\repro.md`, "", []byte("# Steps"))
+	require.NoError(t, err)
+	assert.Equal(t, "repro.md", notes.Name)
+	assert.Equal(t, "text/markdown; charset=utf-8", notes.MediaType)
+
+	list, err := d.ListAttachments(node.ID)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, shot.ID, list[0].ID)
+	assert.Equal(t, notes.ID, list[1].ID)
+
+	got, data, err := d.GetAttachment(shot.ID)
+	require.NoError(t, err)
+	assert.Equal(t, png, data)
+	assert.Equal(t, shot.SHA256, got.SHA256)
+	assert.Equal(t, node.ID, got.NodeID)
+
+	require.NoError(t, d.DeleteAttachment(notes.ID))
+	assert.ErrorIs(t, d.DeleteAttachment(notes.ID), db.ErrNotFound)
+	_, _, err = d.GetAttachment(notes.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	// Attachments go with the node
+	require.NoError(t, d.DeleteNode(node.ID))
+	list, err = d.ListAttachments(node.ID)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func TestAddAttachment_Invalid(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node := testutil.CreateNode(t, d, "fact", "a")
+
+	_, err := d.AddAttachment(db.NewID(), "a.txt", "", []byte("a"))
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	_, err = d.AddAttachment(node.ID, " ", "", []byte("a"))
+	assert.ErrorContains(t, err, "name cannot be empty")
+
+	_, err = d.AddAttachment(node.ID, "big.bin", "", make([]byte, db.MaxAttachmentSize+1))
+	assert.ErrorIs(t, err, db.ErrAttachmentTooLarge)
+
+	a, err := d.AddAttachment(node.ID, "empty.txt", "text/csv", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "text/csv", a.MediaType, "a given media type is kept")
+	_, data, err := d.GetAttachment(a.ID)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+}
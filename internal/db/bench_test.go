@@ -0,0 +1,78 @@
+package db_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+// seedNodes creates n tagged nodes, as a store that has been in use a while.
+func seedNodes(b *testing.B, d db.Store, n int) {
+	b.Helper()
+	for i := 0; i < n; i++ {
+		_, err := d.CreateNode(db.CreateNodeInput{
+			Type:    "fact",
+			Content: fmt.Sprintf("fact number %d about the system", i),
+			Tags:    []string{"tier:reference", fmt.Sprintf("project:p%d", i%10), "area:bench"},
+		})
+		require.NoError(b, err)
+	}
+}
+
+// BenchmarkListNodes measures listing with tags, which compose and the
+// query executor do on every call. Tags come from one batched query rather
+// than one per node.
+func BenchmarkListNodes(b *testing.B) {
+	for _, n := range []int{100, 2000} {
+		b.Run(fmt.Sprintf("nodes=%d", n), func(b *testing.B) {
+			d := testutil.SetupTestDB(b)
+			seedNodes(b, d, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				nodes, err := d.ListNodes(db.ListOptions{})
+				require.NoError(b, err)
+				require.Len(b, nodes, n)
+			}
+		})
+	}
+}
+
+// BenchmarkGetTags is the per-node lookup GetTagsForNodes replaces, for
+// comparison with BenchmarkGetTagsForNodes.
+func BenchmarkGetTags(b *testing.B) {
+	d := testutil.SetupTestDB(b)
+	seedNodes(b, d, 2000)
+	ids := nodeIDs(b, d)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, id := range ids {
+			_, err := d.GetTags(id)
+			require.NoError(b, err)
+		}
+	}
+}
+
+func BenchmarkGetTagsForNodes(b *testing.B) {
+	d := testutil.SetupTestDB(b)
+	seedNodes(b, d, 2000)
+	ids := nodeIDs(b, d)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := d.GetTagsForNodes(ids)
+		require.NoError(b, err)
+	}
+}
+
+func nodeIDs(b *testing.B, d db.Store) []string {
+	b.Helper()
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(b, err)
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return ids
+}
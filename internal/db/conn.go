@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+)
+
+// conn is a connection pool whose Exec, Query, QueryRow and Begin run with
+// ctx, so the store methods built on them honour the context a store was
+// bound to with WithContext without each taking one.
+type conn struct {
+	*sql.DB
+	ctx context.Context
+}
+
+func (c conn) Exec(query string, args ...any) (sql.Result, error) {
+	return c.DB.ExecContext(c.ctx, query, args...)
+}
+
+func (c conn) Query(query string, args ...any) (*sql.Rows, error) {
+	return c.DB.QueryContext(c.ctx, query, args...)
+}
+
+func (c conn) QueryRow(query string, args ...any) *sql.Row {
+	return c.DB.QueryRowContext(c.ctx, query, args...)
+}
+
+// Begin starts a transaction that is rolled back if ctx is done before it
+// commits.
+func (c conn) Begin() (*sql.Tx, error) {
+	return c.DB.BeginTx(c.ctx, nil)
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -8,17 +9,26 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/zate/ctx/internal/validate"
 	_ "modernc.org/sqlite"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// ErrEmptyTag is returned by the batch tag methods for a blank tag.
+var ErrEmptyTag = errors.New("tag cannot be empty")
+
+// ErrInvalidMetadata is returned when a node's metadata is not valid JSON,
+// which would otherwise break every query reading metadata.
+var ErrInvalidMetadata = errors.New("metadata must be valid JSON")
+
 // DB is a type alias for backward compatibility. Use Store interface in new code.
 type DB = SQLiteStore
 
 // SQLiteStore is the SQLite implementation of the Store interface.
 type SQLiteStore struct {
-	db *sql.DB
+	db    conn
+	types *validate.Registry
 }
 
 // compile-time check that SQLiteStore implements Store.
@@ -48,11 +58,15 @@ func Open(path string) (*SQLiteStore, error) {
 		}
 	}
 
-	d := &SQLiteStore{db: sqlDB}
+	d := &SQLiteStore{db: conn{sqlDB, context.Background()}, types: validate.NewRegistry()}
 	if err := d.migrate(); err != nil {
 		sqlDB.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
+	if err := loadTypes(d); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 
 	return d, nil
 }
@@ -61,16 +75,28 @@ func (d *SQLiteStore) Close() error {
 	return d.db.Close()
 }
 
+func (d *SQLiteStore) WithContext(ctx context.Context) Store {
+	return &SQLiteStore{db: conn{d.db.DB, ctx}, types: d.types}
+}
+
+func (d *SQLiteStore) Types() *validate.Registry {
+	return d.types
+}
+
+func (d *SQLiteStore) Rebind(query string) string {
+	return Rebind(BindQuestion, query)
+}
+
 func (d *SQLiteStore) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return d.db.Exec(query, args...)
+	return d.db.Exec(d.Rebind(query), args...)
 }
 
 func (d *SQLiteStore) QueryRow(query string, args ...interface{}) *sql.Row {
-	return d.db.QueryRow(query, args...)
+	return d.db.QueryRow(d.Rebind(query), args...)
 }
 
 func (d *SQLiteStore) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return d.db.Query(query, args...)
+	return d.db.Query(d.Rebind(query), args...)
 }
 
 func (d *SQLiteStore) Begin() (*sql.Tx, error) {
@@ -211,6 +237,152 @@ var migrations = []struct {
 			created_at TEXT NOT NULL DEFAULT ''
 		)`,
 	}},
+	{5, []string{
+		// Local MCP tool usage telemetry
+		`CREATE TABLE IF NOT EXISTS tool_stats (
+			tool TEXT PRIMARY KEY,
+			calls INTEGER NOT NULL DEFAULT 0,
+			errors INTEGER NOT NULL DEFAULT 0,
+			total_ms INTEGER NOT NULL DEFAULT 0,
+			max_ms INTEGER NOT NULL DEFAULT 0,
+			last_called_at TEXT NOT NULL
+		)`,
+	}},
+	{6, []string{
+		// Per-node session usage: how often a node is injected vs. actually used
+		`CREATE TABLE IF NOT EXISTS node_usage (
+			node_id TEXT PRIMARY KEY,
+			injected INTEGER NOT NULL DEFAULT 0,
+			referenced INTEGER NOT NULL DEFAULT 0,
+			recalled INTEGER NOT NULL DEFAULT 0,
+			last_used_at TEXT,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+	}},
+	{7, []string{
+		// Prior states of edited nodes
+		`CREATE TABLE IF NOT EXISTS node_revisions (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			type TEXT NOT NULL,
+			content TEXT NOT NULL,
+			summary TEXT,
+			token_estimate INTEGER NOT NULL DEFAULT 0,
+			metadata TEXT DEFAULT '{}',
+			created_at TEXT NOT NULL,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_revisions_node ON node_revisions(node_id)`,
+	}},
+	{8, []string{
+		// Deleting a node hands its superseded_by to the nodes it superseded,
+		// instead of failing on (or, with foreign keys off, dangling) the reference.
+		`UPDATE nodes SET superseded_by = NULL
+			WHERE superseded_by IS NOT NULL AND superseded_by NOT IN (SELECT id FROM nodes)`,
+		`CREATE TRIGGER IF NOT EXISTS nodes_supersede_bd BEFORE DELETE ON nodes BEGIN
+			UPDATE nodes SET superseded_by = OLD.superseded_by WHERE superseded_by = OLD.id;
+		END`,
+	}},
+	{9, []string{
+		// Server table indexes matching the PostgreSQL schema, so ctx serve on
+		// SQLite doesn't scan devices on every authenticated request
+		`CREATE INDEX IF NOT EXISTS idx_devices_user ON devices(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_devices_token ON devices(token_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_log_device ON sync_log(device_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_log_version ON sync_log(sync_version)`,
+	}},
+	{10, []string{
+		// Per-device and per-user quota usage is summed by origin device
+		`CREATE INDEX IF NOT EXISTS idx_nodes_origin_device ON nodes(origin_device)`,
+	}},
+	{11, []string{
+		// Recall queries that found nothing: what the agent looks for but lacks
+		`CREATE TABLE IF NOT EXISTS missed_recalls (
+			query TEXT PRIMARY KEY,
+			misses INTEGER NOT NULL DEFAULT 0,
+			first_missed_at TEXT NOT NULL,
+			last_missed_at TEXT NOT NULL
+		)`,
+	}},
+	{12, []string{
+		// Node vectors for semantic search, one per embedding model
+		`CREATE TABLE IF NOT EXISTS embeddings (
+			node_id TEXT NOT NULL,
+			model TEXT NOT NULL,
+			vector BLOB NOT NULL,
+			content_hash TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (node_id, model),
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+	}},
+	{13, []string{
+		// Per-view render layout (section order, headings, icons, primer)
+		`ALTER TABLE views ADD COLUMN layout TEXT NOT NULL DEFAULT '{}'`,
+	}},
+	{14, []string{
+		// Saved query expressions, referenced as @name in other queries
+		`CREATE TABLE IF NOT EXISTS queries (
+			name TEXT PRIMARY KEY,
+			query TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		)`,
+	}},
+	{15, []string{
+		// One row per hook invocation: duration, commands parsed, failures
+		`CREATE TABLE IF NOT EXISTS hook_runs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			hook TEXT NOT NULL,
+			started_at TEXT NOT NULL,
+			duration_ms INTEGER NOT NULL,
+			commands INTEGER NOT NULL DEFAULT 0,
+			errors INTEGER NOT NULL DEFAULT 0,
+			deferred INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_hook_runs_started ON hook_runs(started_at)`,
+	}},
+	{16, []string{
+		// Node and edge types registered on top of the built-in ones
+		`CREATE TABLE IF NOT EXISTS type_registry (
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (kind, name)
+		)`,
+	}},
+	{17, []string{
+		// Files kept with a node: screenshots, PDFs, original sources
+		`CREATE TABLE IF NOT EXISTS attachments (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			media_type TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			sha256 TEXT NOT NULL,
+			data BLOB NOT NULL,
+			created_at TEXT NOT NULL,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_attachments_node ON attachments(node_id)`,
+	}},
+	{18, []string{
+		// Every read of a node, for accessed: queries and pruning
+		`CREATE TABLE IF NOT EXISTS node_access (
+			node_id TEXT PRIMARY KEY,
+			access_count INTEGER NOT NULL DEFAULT 0,
+			last_accessed_at TEXT NOT NULL,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_access_last ON node_access(last_accessed_at)`,
+	}},
+	{19, []string{
+		// A read-only token per device for the digest feed URL
+		`ALTER TABLE devices ADD COLUMN digest_token_hash TEXT`,
+		`CREATE INDEX IF NOT EXISTS idx_devices_digest_token ON devices(digest_token_hash)`,
+	}},
 }
 
 func (d *SQLiteStore) migrate() error {
@@ -1,12 +1,16 @@
 package db_test
 
 import (
+	"context"
+	"errors"
+	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
 )
 
 func TestDatabaseOpen(t *testing.T) {
@@ -36,6 +40,30 @@ func TestDatabaseMigrationIdempotent(t *testing.T) {
 	d2.Close()
 }
 
+func TestWithContext(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Bound stores share data"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bound := d.WithContext(ctx)
+	got, err := bound.GetNode(n.ID)
+	require.NoError(t, err)
+	assert.Equal(t, n.Content, got.Content)
+
+	cancel()
+	_, err = bound.GetNode(n.ID)
+	assert.True(t, errors.Is(err, context.Canceled), "got %v", err)
+	_, err = bound.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Never stored"})
+	assert.Error(t, err)
+	assert.Error(t, bound.AddTags(n.ID, []string{"tier:pinned"}), "transactions stop too")
+
+	// The store it was bound from is unaffected
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+}
+
 func TestDefaultViewCreated(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "test.db")
 	d, err := db.Open(path)
@@ -47,3 +75,51 @@ func TestDefaultViewCreated(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "default", name)
 }
+
+func TestServerTablesMigrated(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	for table, column := range map[string]string{
+		"users":         "password_hash",
+		"devices":       "refresh_token_hash",
+		"repo_mappings": "normalized_url",
+		"sync_log":      "sync_version",
+		"nodes":         "sync_version",
+	} {
+		var n int
+		err := d.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+		require.NoError(t, err)
+		assert.Equal(t, 1, n, "%s.%s missing", table, column)
+	}
+
+	var idx int
+	require.NoError(t, d.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = 'idx_devices_token'").Scan(&idx))
+	assert.Equal(t, 1, idx)
+}
+
+func TestSQLitePath(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	assert.Equal(t, "/tmp/a.db", db.SQLitePath("/tmp/a.db"))
+	assert.Equal(t, "/tmp/a.db", db.SQLitePath("sqlite:/tmp/a.db"))
+	assert.Equal(t, "/tmp/a.db", db.SQLitePath("sqlite:///tmp/a.db"))
+	assert.Equal(t, filepath.Join(home, ".ctx", "store.db"), db.SQLitePath("sqlite:~/.ctx/store.db"))
+}
+
+func TestOpenDSN_SQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsn.db")
+
+	d, err := db.OpenDSN("sqlite:" + path)
+	require.NoError(t, err)
+	defer d.Close()
+
+	_, ok := d.(*db.SQLiteStore)
+	assert.True(t, ok)
+	_, err = os.Stat(path)
+	assert.NoError(t, err)
+
+	assert.True(t, db.IsPostgresDSN("postgres://u@h/db"))
+	assert.True(t, db.IsPostgresDSN("postgresql://u@h/db"))
+	assert.False(t, db.IsPostgresDSN(path))
+}
@@ -12,6 +12,7 @@ var validEdgeTypes = map[string]bool{
 	"SUPERSEDES":   true,
 	"RELATES_TO":   true,
 	"CHILD_OF":     true,
+	"MENTIONS":     true,
 }
 
 type Edge struct {
@@ -24,7 +25,7 @@ type Edge struct {
 }
 
 func (d *SQLiteStore) CreateEdge(fromID, toID, edgeType string) (*Edge, error) {
-	if !validEdgeTypes[edgeType] {
+	if !knownType(d, KindEdge, edgeType) {
 		return nil, fmt.Errorf("invalid edge type: %s", edgeType)
 	}
 
@@ -0,0 +1,158 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Embedding is a node's vector under one embedding model.
+type Embedding struct {
+	NodeID string
+	Model  string
+	Vector []float32
+	// ContentHash identifies the content the vector was computed from, so
+	// edited nodes can be re-embedded.
+	ContentHash string
+	UpdatedAt   time.Time
+}
+
+// Embedder turns text into vectors for semantic search. The Ollama and
+// OpenAI-compatible providers live in internal/embeddings.
+type Embedder interface {
+	// Model names the model, keying the vectors it produced.
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ScoredNode is a semantic search hit with its cosine similarity.
+type ScoredNode struct {
+	*Node
+	Score float64 `json:"score"`
+}
+
+func encodeVector(v []float32) []byte {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	v := make([]float32, len(buf)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return v
+}
+
+// cosine is the cosine similarity of a and b, or 0 when their lengths
+// differ or either is all zeros.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func scanEmbeddings(rows *sql.Rows) ([]*Embedding, error) {
+	var out []*Embedding
+	for rows.Next() {
+		e := &Embedding{}
+		var vector []byte
+		var updatedAt string
+		if err := rows.Scan(&e.NodeID, &e.Model, &vector, &e.ContentHash, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		e.Vector = decodeVector(vector)
+		e.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// semanticSearch embeds text and ranks the active nodes embedded with the
+// same model by cosine similarity. Vectors are compared in memory, which
+// is fast enough for a personal knowledge store and needs no extension.
+// ctx is the one d is bound to, so it bounds the embedding call as well.
+func semanticSearch(ctx context.Context, d Store, e Embedder, text string, k int) ([]*ScoredNode, error) {
+	vectors, err := e.Embed(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedder returned %d vectors for 1 text", len(vectors))
+	}
+	embeddings, err := d.ListEmbeddings(e.Model())
+	if err != nil {
+		return nil, err
+	}
+
+	type hit struct {
+		id    string
+		score float64
+	}
+	hits := make([]hit, 0, len(embeddings))
+	for _, emb := range embeddings {
+		hits = append(hits, hit{emb.NodeID, cosine(vectors[0], emb.Vector)})
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+
+	var out []*ScoredNode
+	for _, h := range hits {
+		if len(out) == k {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		node, err := d.GetNode(h.id)
+		if err != nil || node.SupersededBy != nil {
+			continue
+		}
+		out = append(out, &ScoredNode{Node: node, Score: h.score})
+	}
+	return out, nil
+}
+
+func (d *SQLiteStore) UpsertEmbedding(e *Embedding) error {
+	_, err := d.db.Exec(`INSERT INTO embeddings (node_id, model, vector, content_hash, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (node_id, model) DO UPDATE SET
+			vector = excluded.vector,
+			content_hash = excluded.content_hash,
+			updated_at = excluded.updated_at`,
+		e.NodeID, e.Model, encodeVector(e.Vector), e.ContentHash, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to store embedding: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) ListEmbeddings(model string) ([]*Embedding, error) {
+	rows, err := d.db.Query(`SELECT node_id, model, vector, content_hash, updated_at
+		FROM embeddings WHERE model = ?`, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+	return scanEmbeddings(rows)
+}
+
+func (d *SQLiteStore) SemanticSearch(e Embedder, text string, k int) ([]*ScoredNode, error) {
+	return semanticSearch(d.db.ctx, d, e, text, k)
+}
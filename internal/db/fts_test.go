@@ -64,3 +64,36 @@ func TestFTSSearch_DeletedContent(t *testing.T) {
 	results2, _ := d.Search("deletable")
 	assert.Empty(t, results2)
 }
+
+func TestSearchPrefix(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Authentication uses OIDC tokens"})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Authorization is role based"})
+	old, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Authentication used basic auth"})
+	newer, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "replacement"})
+	_, err := d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newer.ID, old.ID)
+	require.NoError(t, err)
+
+	results, err := d.SearchPrefix([]string{"auth"}, 10)
+	require.NoError(t, err)
+	assert.Len(t, results, 2, "prefix matches both active nodes, not the superseded one")
+
+	results, err = d.SearchPrefix([]string{"authentication", "tok"}, 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Contains(t, results[0].Content, "OIDC")
+
+	results, err = d.SearchPrefix([]string{"auth"}, 1)
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+
+	// FTS syntax in the input is ignored rather than rejected.
+	results, err = d.SearchPrefix([]string{`"auth*`, "OR", "("}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = d.SearchPrefix([]string{"***"}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// maxHookRuns is how many hook runs are kept; older runs are pruned as new
+// ones are recorded.
+const maxHookRuns = 10000
+
+// HookRun records one invocation of a Claude Code hook.
+type HookRun struct {
+	ID         int64     `json:"id"`
+	Hook       string    `json:"hook"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Commands   int       `json:"commands"`        // ctx commands parsed
+	Errors     int       `json:"errors"`          // commands and steps that failed
+	Deferred   int       `json:"deferred"`        // commands left to the background flush
+	Error      string    `json:"error,omitempty"` // first failure, if any
+}
+
+// HookStat aggregates the runs of one hook.
+type HookStat struct {
+	Hook       string    `json:"hook"`
+	Runs       int       `json:"runs"`
+	FailedRuns int       `json:"failed_runs"`
+	Commands   int       `json:"commands"`
+	Errors     int       `json:"errors"`
+	Deferred   int       `json:"deferred"`
+	TotalMs    int64     `json:"total_ms"`
+	MaxMs      int64     `json:"max_ms"`
+	LastRunAt  time.Time `json:"last_run_at"`
+}
+
+// AvgMs returns the mean duration per run in milliseconds.
+func (s *HookStat) AvgMs() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.TotalMs) / float64(s.Runs)
+}
+
+// FailureRate returns the fraction of runs with at least one error.
+func (s *HookStat) FailureRate() float64 {
+	if s.Runs == 0 {
+		return 0
+	}
+	return float64(s.FailedRuns) / float64(s.Runs)
+}
+
+func (d *SQLiteStore) RecordHookRun(run *HookRun) error {
+	res, err := d.db.Exec(`INSERT INTO hook_runs (hook, started_at, duration_ms, commands, errors, deferred, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.Hook, run.StartedAt.UTC().Format(time.RFC3339), run.DurationMs, run.Commands, run.Errors, run.Deferred, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to record hook run: %w", err)
+	}
+	run.ID, _ = res.LastInsertId()
+	_, err = d.db.Exec("DELETE FROM hook_runs WHERE id <= ?", run.ID-maxHookRuns)
+	if err != nil {
+		return fmt.Errorf("failed to prune hook runs: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) ListHookRuns(since time.Time, failedOnly bool, limit int) ([]*HookRun, error) {
+	q := `SELECT id, hook, started_at, duration_ms, commands, errors, deferred, error
+		FROM hook_runs WHERE started_at >= ?`
+	if failedOnly {
+		q += " AND errors > 0"
+	}
+	rows, err := d.db.Query(q+" ORDER BY id DESC LIMIT ?", since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hook runs: %w", err)
+	}
+	defer rows.Close()
+	return scanHookRuns(rows)
+}
+
+func (d *SQLiteStore) ListHookStats(since time.Time) ([]*HookStat, error) {
+	rows, err := d.db.Query(`SELECT hook, COUNT(*), SUM(CASE WHEN errors > 0 THEN 1 ELSE 0 END),
+			SUM(commands), SUM(errors), SUM(deferred), SUM(duration_ms), MAX(duration_ms), MAX(started_at)
+		FROM hook_runs WHERE started_at >= ? GROUP BY hook ORDER BY hook`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hook stats: %w", err)
+	}
+	defer rows.Close()
+	return scanHookStats(rows)
+}
+
+func scanHookRuns(rows *sql.Rows) ([]*HookRun, error) {
+	var runs []*HookRun
+	for rows.Next() {
+		var r HookRun
+		var started string
+		if err := rows.Scan(&r.ID, &r.Hook, &started, &r.DurationMs, &r.Commands, &r.Errors, &r.Deferred, &r.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan hook run: %w", err)
+		}
+		r.StartedAt, _ = time.Parse(time.RFC3339, started)
+		runs = append(runs, &r)
+	}
+	return runs, rows.Err()
+}
+
+func scanHookStats(rows *sql.Rows) ([]*HookStat, error) {
+	var stats []*HookStat
+	for rows.Next() {
+		var s HookStat
+		var last string
+		if err := rows.Scan(&s.Hook, &s.Runs, &s.FailedRuns, &s.Commands, &s.Errors, &s.Deferred, &s.TotalMs, &s.MaxMs, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan hook stat: %w", err)
+		}
+		s.LastRunAt, _ = time.Parse(time.RFC3339, last)
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
@@ -0,0 +1,49 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestHookRuns(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	now := time.Now()
+	old := &db.HookRun{Hook: "stop", StartedAt: now.Add(-48 * time.Hour), DurationMs: 900, Errors: 1, Error: "old failure"}
+	require.NoError(t, d.RecordHookRun(old))
+	require.NoError(t, d.RecordHookRun(&db.HookRun{Hook: "stop", StartedAt: now, DurationMs: 10, Commands: 3}))
+	failed := &db.HookRun{Hook: "stop", StartedAt: now, DurationMs: 30, Commands: 2, Errors: 2, Deferred: 1, Error: "remember command failed"}
+	require.NoError(t, d.RecordHookRun(failed))
+	require.NoError(t, d.RecordHookRun(&db.HookRun{Hook: "session-start", StartedAt: now, DurationMs: 50}))
+	assert.NotZero(t, failed.ID)
+
+	stats, err := d.ListHookStats(now.Add(-time.Hour))
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+	assert.Equal(t, "session-start", stats[0].Hook)
+	stop := stats[1]
+	assert.Equal(t, 2, stop.Runs, "runs before since are left out")
+	assert.Equal(t, 1, stop.FailedRuns)
+	assert.Equal(t, 5, stop.Commands)
+	assert.Equal(t, 2, stop.Errors)
+	assert.Equal(t, 1, stop.Deferred)
+	assert.Equal(t, int64(30), stop.MaxMs)
+	assert.InDelta(t, 20.0, stop.AvgMs(), 0.001)
+	assert.InDelta(t, 0.5, stop.FailureRate(), 0.001)
+
+	runs, err := d.ListHookRuns(now.Add(-72*time.Hour), true, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, failed.ID, runs[0].ID, "newest first")
+	assert.Equal(t, "remember command failed", runs[0].Error)
+	assert.Equal(t, "old failure", runs[1].Error)
+
+	runs, err = d.ListHookRuns(now.Add(-time.Hour), false, 2)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/zate/ctx/internal/lang"
+)
+
+// withLang records the language content is written in under metadata's
+// "lang" key, for lang: queries and compose translation. A lang already
+// set is kept unless replace is, as when the content changed; a node whose
+// language can't be told then loses it. Metadata that isn't a JSON object
+// is returned as it is.
+func withLang(metadata, content string, replace bool) string {
+	fields := map[string]any{}
+	dec := json.NewDecoder(strings.NewReader(metadata))
+	dec.UseNumber() // keep large integers exact when re-encoding
+	if dec.Decode(&fields) != nil || fields == nil {
+		return metadata
+	}
+	if _, ok := fields["lang"]; ok && !replace {
+		return metadata
+	}
+	code := lang.Detect(content)
+	if code == "" {
+		if _, ok := fields["lang"]; !ok {
+			return metadata
+		}
+		delete(fields, "lang")
+	} else {
+		if fields["lang"] == code {
+			return metadata
+		}
+		fields["lang"] = code
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return metadata
+	}
+	return string(data)
+}
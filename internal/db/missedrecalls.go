@@ -0,0 +1,54 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MissedRecall counts how often a recall query came back empty.
+type MissedRecall struct {
+	Query         string    `json:"query"`
+	Misses        int       `json:"misses"`
+	FirstMissedAt time.Time `json:"first_missed_at"`
+	LastMissedAt  time.Time `json:"last_missed_at"`
+}
+
+func (d *SQLiteStore) RecordMissedRecall(query string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := d.db.Exec(`INSERT INTO missed_recalls (query, misses, first_missed_at, last_missed_at)
+		VALUES (?, 1, ?, ?)
+		ON CONFLICT (query) DO UPDATE SET
+			misses = misses + 1,
+			last_missed_at = excluded.last_missed_at`,
+		query, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to record missed recall: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) ListMissedRecalls(limit int) ([]*MissedRecall, error) {
+	rows, err := d.db.Query(`SELECT query, misses, first_missed_at, last_missed_at
+		FROM missed_recalls ORDER BY misses DESC, last_missed_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list missed recalls: %w", err)
+	}
+	defer rows.Close()
+	return scanMissedRecalls(rows)
+}
+
+func scanMissedRecalls(rows *sql.Rows) ([]*MissedRecall, error) {
+	var out []*MissedRecall
+	for rows.Next() {
+		var m MissedRecall
+		var first, last string
+		if err := rows.Scan(&m.Query, &m.Misses, &first, &last); err != nil {
+			return nil, fmt.Errorf("failed to scan missed recall: %w", err)
+		}
+		m.FirstMissedAt, _ = time.Parse(time.RFC3339, first)
+		m.LastMissedAt, _ = time.Parse(time.RFC3339, last)
+		out = append(out, &m)
+	}
+	return out, rows.Err()
+}
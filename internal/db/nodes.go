@@ -3,12 +3,15 @@ package db
 import (
 	"crypto/rand"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/oklog/ulid/v2"
 	"github.com/zate/ctx/internal/token"
+	"github.com/zate/ctx/internal/validate"
 )
 
 var validNodeTypes = map[string]bool{
@@ -21,6 +24,7 @@ var validNodeTypes = map[string]bool{
 	"summary":       true,
 	"source":        true,
 	"open-question": true,
+	"entity":        true,
 }
 
 type Node struct {
@@ -59,17 +63,34 @@ type ListOptions struct {
 	IncludeSuperseded bool
 }
 
+// IDs come from monotonic entropy, so IDs generated within the same
+// millisecond still sort in creation order.
+var (
+	idMu      sync.Mutex
+	idEntropy = ulid.Monotonic(rand.Reader, 0)
+)
+
+// NewID returns a new ULID. IDs sort in the order they were made, which
+// the work queue relies on for job order and ListRevisions for history.
 func NewID() string {
-	return ulid.MustNew(ulid.Timestamp(time.Now()), rand.Reader).String()
+	idMu.Lock()
+	defer idMu.Unlock()
+	return ulid.MustNew(ulid.Timestamp(time.Now()), idEntropy).String()
 }
 
 func (d *SQLiteStore) CreateNode(input CreateNodeInput) (*Node, error) {
-	if !validNodeTypes[input.Type] {
+	if !knownType(d, KindNode, input.Type) {
 		return nil, fmt.Errorf("invalid node type: %s", input.Type)
 	}
-	if strings.TrimSpace(input.Content) == "" {
+	input.Content = NormalizeContent(input.Content)
+	if input.Content == "" {
 		return nil, fmt.Errorf("content cannot be empty")
 	}
+	tags, err := newTags(d, input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = tags
 
 	id := NewID()
 	now := time.Now().UTC()
@@ -78,7 +99,11 @@ func (d *SQLiteStore) CreateNode(input CreateNodeInput) (*Node, error) {
 	metadata := input.Metadata
 	if metadata == "" {
 		metadata = "{}"
+	} else if !json.Valid([]byte(metadata)) {
+		return nil, ErrInvalidMetadata
 	}
+	metadata = withLang(metadata, input.Content, false)
+	metadata = withRefs(metadata, input.Content, false)
 
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -123,9 +148,68 @@ func (d *SQLiteStore) CreateNode(input CreateNodeInput) (*Node, error) {
 	}, nil
 }
 
+// newNode validates input as CreateNode does and returns the node it
+// would store under id, for the store methods that create nodes inside a
+// larger transaction with insertNode.
+func newNode(d Store, id string, input CreateNodeInput) (*Node, error) {
+	if !knownType(d, KindNode, input.Type) {
+		return nil, fmt.Errorf("invalid node type: %s", input.Type)
+	}
+	content := NormalizeContent(input.Content)
+	if content == "" {
+		return nil, fmt.Errorf("content cannot be empty")
+	}
+	tags, err := newTags(d, input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	metadata := input.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	} else if !json.Valid([]byte(metadata)) {
+		return nil, ErrInvalidMetadata
+	}
+
+	now := time.Now().UTC()
+	return &Node{
+		ID:            id,
+		Type:          input.Type,
+		Content:       content,
+		Summary:       input.Summary,
+		TokenEstimate: token.Estimate(content),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Metadata:      withRefs(withLang(metadata, content, false), content, false),
+		Tags:          tags,
+	}, nil
+}
+
+// insertNode stores a node from newNode, with its tags, on tx.
+func insertNode(d Store, tx *sql.Tx, node *Node) error {
+	nowStr := node.CreatedAt.Format(time.RFC3339)
+	var summary sql.NullString
+	if node.Summary != nil {
+		summary = sql.NullString{String: *node.Summary, Valid: true}
+	}
+	_, err := tx.Exec(d.Rebind(`INSERT INTO nodes (id, type, content, summary, token_estimate, created_at, updated_at, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		node.ID, node.Type, node.Content, summary, node.TokenEstimate, nowStr, nowStr, node.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to create node: %w", err)
+	}
+	addTag := d.Rebind(`INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`)
+	for _, tag := range node.Tags {
+		if _, err := tx.Exec(addTag, node.ID, tag, nowStr); err != nil {
+			return fmt.Errorf("failed to add tag %s: %w", tag, err)
+		}
+	}
+	return nil
+}
+
 // FindByTypeAndContent returns an existing active (non-superseded) node with
 // matching type and content, or nil if none exists.
 func (d *SQLiteStore) FindByTypeAndContent(nodeType, content string) (*Node, error) {
+	content = NormalizeContent(content)
 	var id string
 	err := d.db.QueryRow(
 		`SELECT id FROM nodes WHERE type = ? AND content = ? AND superseded_by IS NULL LIMIT 1`,
@@ -144,9 +228,25 @@ func (d *SQLiteStore) FindByTypeAndContent(nodeType, content string) (*Node, err
 // For shorter prefixes, it finds the unique matching node.
 // Returns ErrNotFound if no match, or an error if multiple nodes match the prefix.
 func (d *SQLiteStore) ResolveID(prefix string) (string, error) {
+	return resolveID(d, prefix)
+}
+
+// likeEscaper escapes LIKE's wildcards and its escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// resolveID implements ResolveID for both stores. The prefix must be one
+// validate.IDPrefix accepts, and is matched ignoring case, as IDs are
+// stored in upper case; anything else is rejected with a *validate.Error
+// before it reaches the database, so % and _ cannot act as wildcards.
+func resolveID(d Store, prefix string) (string, error) {
+	if err := validate.IDPrefix(prefix); err != nil {
+		return "", err
+	}
+	prefix = strings.ToUpper(prefix)
+
 	if len(prefix) == 26 {
 		var id string
-		err := d.db.QueryRow("SELECT id FROM nodes WHERE id = ?", prefix).Scan(&id)
+		err := d.QueryRow("SELECT id FROM nodes WHERE id = ?", prefix).Scan(&id)
 		if err == sql.ErrNoRows {
 			return "", ErrNotFound
 		}
@@ -155,11 +255,8 @@ func (d *SQLiteStore) ResolveID(prefix string) (string, error) {
 		}
 		return id, nil
 	}
-	if len(prefix) == 0 {
-		return "", fmt.Errorf("empty ID prefix")
-	}
 
-	rows, err := d.db.Query("SELECT id FROM nodes WHERE id LIKE ? LIMIT 2", prefix+"%")
+	rows, err := d.Query(`SELECT id FROM nodes WHERE id LIKE ? ESCAPE '\' LIMIT 2`, likeEscaper.Replace(prefix)+"%")
 	if err != nil {
 		return "", fmt.Errorf("failed to resolve ID prefix: %w", err)
 	}
@@ -173,6 +270,9 @@ func (d *SQLiteStore) ResolveID(prefix string) (string, error) {
 		}
 		matches = append(matches, id)
 	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to resolve ID prefix: %w", err)
+	}
 
 	switch len(matches) {
 	case 0:
@@ -234,21 +334,32 @@ func (d *SQLiteStore) UpdateNode(id string, input UpdateNodeInput) (*Node, error
 	summary := existing.Summary
 
 	if input.Content != nil {
-		content = *input.Content
+		content = NormalizeContent(*input.Content)
+		if content == "" {
+			return nil, fmt.Errorf("content cannot be empty")
+		}
 	}
 	if input.Type != nil {
-		if !validNodeTypes[*input.Type] {
+		if !knownType(d, KindNode, *input.Type) {
 			return nil, fmt.Errorf("invalid node type: %s", *input.Type)
 		}
 		nodeType = *input.Type
 	}
 	if input.Metadata != nil {
+		if *input.Metadata != "" && !json.Valid([]byte(*input.Metadata)) {
+			return nil, ErrInvalidMetadata
+		}
 		metadata = *input.Metadata
 	}
 	if input.Summary != nil {
 		summary = input.Summary
 	}
 
+	if content != existing.Content && input.Metadata == nil {
+		metadata = withLang(metadata, content, true)
+		metadata = withRefs(metadata, content, true)
+	}
+
 	tokenEst := token.Estimate(content)
 
 	var summaryVal sql.NullString
@@ -256,25 +367,58 @@ func (d *SQLiteStore) UpdateNode(id string, input UpdateNodeInput) (*Node, error
 		summaryVal = sql.NullString{String: *summary, Valid: true}
 	}
 
-	_, err = d.db.Exec(`UPDATE nodes SET type=?, content=?, summary=?, token_estimate=?, updated_at=?, metadata=?
+	changed := content != existing.Content || nodeType != existing.Type || metadata != existing.Metadata ||
+		(summary == nil) != (existing.Summary == nil) || (summary != nil && *summary != *existing.Summary)
+	// The prior state is recorded in the same transaction as the update, so
+	// a failed update leaves no revision behind
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if changed {
+		if err := insertRevision(d, tx, newRevision(existing)); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE nodes SET type=?, content=?, summary=?, token_estimate=?, updated_at=?, metadata=?
 		WHERE id=?`, nodeType, content, summaryVal, tokenEst, nowStr, metadata, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update node: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
 
 	return d.GetNode(id)
 }
 
+// DeleteNode deletes a node. Nodes it superseded inherit its own
+// superseded_by, so a supersede chain stays intact and deleting the current
+// version reactivates the one it replaced.
 func (d *SQLiteStore) DeleteNode(id string) error {
-	result, err := d.db.Exec("DELETE FROM nodes WHERE id = ?", id)
+	tx, err := d.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var supersededBy sql.NullString
+	if err := tx.QueryRow("SELECT superseded_by FROM nodes WHERE id = ?", id).Scan(&supersededBy); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
 		return fmt.Errorf("failed to delete node: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return ErrNotFound
+	if _, err := tx.Exec("UPDATE nodes SET superseded_by = ? WHERE superseded_by = ?", supersededBy, id); err != nil {
+		return fmt.Errorf("failed to release supersede references: %w", err)
 	}
-	return nil
+	if _, err := tx.Exec("DELETE FROM nodes WHERE id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return tx.Commit()
 }
 
 func (d *SQLiteStore) ListNodes(opts ListOptions) ([]*Node, error) {
@@ -336,11 +480,16 @@ func (d *SQLiteStore) ListNodes(opts ListOptions) ([]*Node, error) {
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
-		tags, _ := d.GetTags(node.ID)
-		node.Tags = tags
 		nodes = append(nodes, node)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	rows.Close()
 
+	if err := attachTags(d, nodes); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
 
@@ -376,10 +525,15 @@ func (d *SQLiteStore) Search(query string) ([]*Node, error) {
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
-		tags, _ := d.GetTags(node.ID)
-		node.Tags = tags
 		nodes = append(nodes, node)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	rows.Close()
 
+	if err := attachTags(d, nodes); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
@@ -3,14 +3,26 @@ package db_test
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/validate"
 	"github.com/zate/ctx/testutil"
 )
 
+func TestNewID_Ordered(t *testing.T) {
+	// Many IDs share a millisecond; they must still sort in order made
+	prev := db.NewID()
+	for i := 0; i < 1000; i++ {
+		id := db.NewID()
+		require.Less(t, prev, id)
+		prev = id
+	}
+}
+
 func TestNodeCreate(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -73,6 +85,19 @@ func TestNodeCreate_EmptyContent(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestNodeCreate_InvalidMetadata(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "test", Metadata: `{"source":`})
+	assert.ErrorIs(t, err, db.ErrInvalidMetadata)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "test"})
+	require.NoError(t, err)
+	bad := "not json"
+	_, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Metadata: &bad})
+	assert.ErrorIs(t, err, db.ErrInvalidMetadata)
+}
+
 func TestNodeCreate_WithTags(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -267,10 +292,44 @@ func TestResolveID_Prefix(t *testing.T) {
 func TestResolveID_Prefix_NotFound(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
-	_, err := d.ResolveID("ZZZZZZZZ")
+	_, err := d.ResolveID("7ZZZZZZZ")
 	assert.True(t, errors.Is(err, db.ErrNotFound))
 }
 
+func TestResolveID_LowerCase(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "test content"})
+	require.NoError(t, err)
+
+	for _, id := range []string{strings.ToLower(node.ID), strings.ToLower(node.ID[:8])} {
+		resolved, err := d.ResolveID(id)
+		require.NoError(t, err, id)
+		assert.Equal(t, node.ID, resolved)
+	}
+}
+
+func TestResolveID_Invalid(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "test content"})
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"0%":                          `contains '%'`,
+		"0_":                          `contains '_'`,
+		"%":                           "must start with a digit 0-7",
+		"ZZZZZZZZ":                    "must start with a digit 0-7",
+		"01ARZ3NDEKTSV4RRFFQ69G5FAU":  `contains 'U'`,
+		"01ARZ3NDEKTSV4RRFFQ69G5FAV0": "at most 26 characters",
+		"0 1":                         `contains ' '`,
+	}
+	for id, reason := range cases {
+		_, err := d.ResolveID(id)
+		var invalid *validate.Error
+		require.True(t, errors.As(err, &invalid), "%q: got %v", id, err)
+		assert.Contains(t, err.Error(), reason, id)
+	}
+}
+
 func TestResolveID_EmptyPrefix(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -320,3 +379,101 @@ func TestFindByTypeAndContent_IgnoresSuperseded(t *testing.T) {
 	require.NotNil(t, found)
 	assert.Equal(t, n2.ID, found.ID)
 }
+
+func TestNodeCreate_NormalizesContent(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "line one  \r\n\r\n\r\n\r\n\r\nline two\t\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "line one\n\n\nline two", node.Content)
+
+	// Dedup sees through whitespace differences between clients
+	found, err := d.FindByTypeAndContent("fact", "line one\n\n\n\nline two   ")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, node.ID, found.ID)
+
+	updated, err := d.UpdateNode(node.ID, db.UpdateNodeInput{Content: strPtr("~~~ sh\nls  \n~~~")})
+	require.NoError(t, err)
+	assert.Equal(t, "```sh\nls\n```", updated.Content)
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestNodeCreate_DetectsLanguage(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Wir sollten Postgres für den Server verwenden, weil es mit mehreren Instanzen einfacher ist.",
+		Metadata: `{"source":"hook","count":9007199254740993}`})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"source":"hook","count":9007199254740993,"lang":"de"}`, node.Metadata)
+
+	// A lang set by the caller wins
+	node, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The build is slow and it is getting worse.", Metadata: `{"lang":"fr"}`})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"lang":"fr"}`, node.Metadata)
+
+	// Too short to tell: metadata is left alone
+	node, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Use Postgres"})
+	require.NoError(t, err)
+	assert.Equal(t, "{}", node.Metadata)
+
+	// New content is detected again
+	updated, err := d.UpdateNode(node.ID, db.UpdateNodeInput{Content: strPtr("We use Postgres for the server because it is easier to run.")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"lang":"en"}`, updated.Metadata)
+}
+
+func TestNodeCreate_RecordsRefs(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Fixed in https://github.com/Zate/Memdown/pull/42, see zate/memdown#40"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"refs":["zate/memdown#42","zate/memdown#40"]}`, node.Metadata)
+
+	// New content is scanned again, and refs it no longer mentions go
+	updated, err := d.UpdateNode(node.ID, db.UpdateNodeInput{Content: strPtr("Tracked in https://gitlab.com/acme/infra/deploy/-/issues/7")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"refs":["acme/infra/deploy#7"]}`, updated.Metadata)
+
+	updated, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Content: strPtr("No longer tracked")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, updated.Metadata)
+}
+
+func TestNodeDelete_ReleasesSupersedeReferences(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	v1, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "v1"})
+	v2, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "v2"})
+	v3, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "v3"})
+	_, _ = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", v2.ID, v1.ID)
+	_, _ = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", v3.ID, v2.ID)
+
+	// Deleting the middle of a chain links its predecessor to its successor
+	require.NoError(t, d.DeleteNode(v2.ID))
+	got, err := d.GetNode(v1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.SupersededBy)
+	assert.Equal(t, v3.ID, *got.SupersededBy)
+
+	// Deleting the current version reactivates the one it replaced
+	require.NoError(t, d.DeleteNode(v3.ID))
+	got, err = d.GetNode(v1.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.SupersededBy)
+}
+
+func TestNodeDelete_RawSQLReleasesSupersedeReferences(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "old"})
+	repl, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "new"})
+	_, _ = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", repl.ID, old.ID)
+
+	_, err := d.Exec("DELETE FROM nodes WHERE id = ?", repl.ID)
+	require.NoError(t, err)
+	got, err := d.GetNode(old.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.SupersededBy)
+}
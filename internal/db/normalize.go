@@ -0,0 +1,91 @@
+package db
+
+import (
+	"strings"
+)
+
+// maxBlankLines is the longest run of blank lines kept outside code fences.
+const maxBlankLines = 2
+
+// NormalizeContent canonicalizes node content so that the same text stored
+// by different clients is byte-identical, keeping diffs and dedup stable:
+//
+//   - CRLF and CR line endings become LF
+//   - trailing spaces and tabs are stripped from every line
+//   - runs of more than two blank lines collapse to two (outside code fences)
+//   - fenced code markers use backticks, lose up to three spaces of
+//     indentation, and carry the info string without a separating space
+//   - leading and trailing blank space is trimmed
+//
+// Every node write path applies it.
+func NormalizeContent(content string) string {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\r", "\n")
+
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	blank := 0
+	fence := "" // closing marker of the open fence, if any
+
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+
+		if marker, info, ok := parseFence(line); ok {
+			switch {
+			case fence == "":
+				fence = marker
+				line = marker + info
+			case info == "" && len(marker) >= len(fence):
+				line = fence
+				fence = ""
+			}
+		}
+
+		if line == "" && fence == "" {
+			blank++
+			if blank > maxBlankLines {
+				continue
+			}
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// parseFence reports whether line is a fenced code marker (three or more
+// backticks or tildes, indented at most three spaces) and returns the
+// marker rewritten with backticks plus the trimmed info string.
+func parseFence(line string) (marker, info string, ok bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return "", "", false
+	}
+	ch := trimmed[0]
+	if ch != '`' && ch != '~' {
+		return "", "", false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == ch {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+	info = strings.TrimSpace(trimmed[n:])
+	if ch == '`' && strings.Contains(info, "`") {
+		return "", "", false // inline code span, not a fence
+	}
+	return strings.Repeat("`", n), info, true
+}
+
+// Preview shortens content to its first n characters, marking a cut with
+// "...". It counts runes, not bytes, so it never splits a character.
+func Preview(content string, n int) string {
+	if r := []rune(content); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return content
+}
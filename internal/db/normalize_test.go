@@ -0,0 +1,40 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeContent(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"trailing spaces", "one  \ntwo\t\nthree", "one\ntwo\nthree"},
+		{"crlf", "one\r\ntwo\rthree", "one\ntwo\nthree"},
+		{"collapse blank lines", "a\n\n\n\n\nb", "a\n\n\nb"},
+		{"keeps two blank lines", "a\n\n\nb", "a\n\n\nb"},
+		{"trim ends", "\n\n  text  \n\n", "text"},
+		{"tilde fence", "~~~ go\nx := 1\n~~~", "```go\nx := 1\n```"},
+		{"indented fence", "  ```python  \nprint(1)\n  ```", "```python\nprint(1)\n```"},
+		{"blank lines kept in code", "```\na\n\n\n\n\nb\n```", "```\na\n\n\n\n\nb\n```"},
+		{"long fence", "````md\n```\ninner\n```\n````", "````md\n```\ninner\n```\n````"},
+		{"inline code is not a fence", "```a` b```", "```a` b```"},
+		{"already normal", "# Title\n\nBody text.", "# Title\n\nBody text."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeContent(tt.in))
+			assert.Equal(t, tt.want, NormalizeContent(tt.want), "normalization must be idempotent")
+		})
+	}
+}
+
+func TestPreview(t *testing.T) {
+	assert.Equal(t, "short", Preview("short", 10))
+	assert.Equal(t, "abc...", Preview("abcdef", 3))
+	assert.Equal(t, "ééé...", Preview("éééé", 3), "cut by characters, not bytes")
+	assert.Equal(t, "日本", Preview("日本", 2))
+}
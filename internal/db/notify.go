@@ -0,0 +1,22 @@
+package db
+
+import "context"
+
+// ChangeChannel is the Postgres notification channel that triggers in the
+// schema announce changes on: to nodes, edges, tags, views, saved queries
+// and usage counts. The payload is the name of the changed table.
+const ChangeChannel = "ctx_changes"
+
+// ChangeNotifier is implemented by stores that can report changes made by
+// any process sharing the database, so caches held by one server instance
+// stay correct when several serve the same database.
+type ChangeNotifier interface {
+	// ListenChanges calls changed with the changed table's name until ctx
+	// is done or the connection is lost, and returns why it stopped.
+	// changed is first called with "" once listening has started, since
+	// anything may have changed before then.
+	ListenChanges(ctx context.Context, changed func(table string)) error
+}
+
+// compile-time check that PostgresStore reports changes.
+var _ ChangeNotifier = (*PostgresStore)(nil)
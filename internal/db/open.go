@@ -0,0 +1,35 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsPostgresDSN reports whether dsn is a PostgreSQL connection URL.
+func IsPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// SQLitePath returns the file path for a sqlite:<path> DSN or a plain path,
+// expanding a leading ~/ to the user's home directory.
+func SQLitePath(dsn string) string {
+	path := strings.TrimPrefix(dsn, "sqlite:")
+	path = strings.TrimPrefix(path, "//")
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	return path
+}
+
+// OpenDSN opens a store, picking the backend from the DSN: postgres:// and
+// postgresql:// URLs open PostgreSQL; sqlite:<path> or a plain path opens
+// SQLite.
+func OpenDSN(dsn string) (Store, error) {
+	if IsPostgresDSN(dsn) {
+		return OpenPostgres(dsn)
+	}
+	return Open(SQLitePath(dsn))
+}
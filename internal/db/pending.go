@@ -32,3 +32,21 @@ func (d *SQLiteStore) DeletePending(key string) error {
 	_, err := d.db.Exec("DELETE FROM pending WHERE key = ?", key)
 	return err
 }
+
+func (d *SQLiteStore) ListPending(prefix string) (map[string]string, error) {
+	rows, err := d.db.Query("SELECT key, value FROM pending WHERE key LIKE ?", prefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending %s: %w", prefix, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan pending: %w", err)
+		}
+		out[key] = value
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,22 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestListPending_FiltersByPrefix(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	require.NoError(t, d.SetPending("op:1", "first"))
+	require.NoError(t, d.SetPending("op:2", "second"))
+	require.NoError(t, d.SetPending("recall_query", "other"))
+
+	got, err := d.ListPending("op:")
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"op:1": "first", "op:2": "second"}, got)
+}
@@ -1,19 +1,25 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/zate/ctx/internal/token"
+	"github.com/zate/ctx/internal/validate"
 )
 
 // PostgresStore is the PostgreSQL implementation of the Store interface.
 // Used by the remote server for hosted/shared access.
 type PostgresStore struct {
-	db *sql.DB
+	db    conn
+	types *validate.Registry
 }
 
 // compile-time check that PostgresStore implements Store.
@@ -32,11 +38,15 @@ func OpenPostgres(connStr string) (*PostgresStore, error) {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
 
-	d := &PostgresStore{db: sqlDB}
+	d := &PostgresStore{db: conn{sqlDB, context.Background()}, types: validate.NewRegistry()}
 	if err := d.migrate(); err != nil {
 		sqlDB.Close()
 		return nil, fmt.Errorf("failed to migrate postgres: %w", err)
 	}
+	if err := loadTypes(d); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
 
 	return d, nil
 }
@@ -47,16 +57,28 @@ func (d *PostgresStore) Close() error {
 
 // --- Raw SQL access ---
 
+func (d *PostgresStore) WithContext(ctx context.Context) Store {
+	return &PostgresStore{db: conn{d.db.DB, ctx}, types: d.types}
+}
+
+func (d *PostgresStore) Types() *validate.Registry {
+	return d.types
+}
+
+func (d *PostgresStore) Rebind(query string) string {
+	return Rebind(BindDollar, query)
+}
+
 func (d *PostgresStore) Exec(query string, args ...interface{}) (sql.Result, error) {
-	return d.db.Exec(query, args...)
+	return d.db.Exec(d.Rebind(query), args...)
 }
 
 func (d *PostgresStore) QueryRow(query string, args ...interface{}) *sql.Row {
-	return d.db.QueryRow(query, args...)
+	return d.db.QueryRow(d.Rebind(query), args...)
 }
 
 func (d *PostgresStore) Query(query string, args ...interface{}) (*sql.Rows, error) {
-	return d.db.Query(query, args...)
+	return d.db.Query(d.Rebind(query), args...)
 }
 
 func (d *PostgresStore) Begin() (*sql.Tx, error) {
@@ -66,12 +88,18 @@ func (d *PostgresStore) Begin() (*sql.Tx, error) {
 // --- Node operations ---
 
 func (d *PostgresStore) CreateNode(input CreateNodeInput) (*Node, error) {
-	if !validNodeTypes[input.Type] {
+	if !knownType(d, KindNode, input.Type) {
 		return nil, fmt.Errorf("invalid node type: %s", input.Type)
 	}
-	if strings.TrimSpace(input.Content) == "" {
+	input.Content = NormalizeContent(input.Content)
+	if input.Content == "" {
 		return nil, fmt.Errorf("content cannot be empty")
 	}
+	tags, err := newTags(d, input.Tags)
+	if err != nil {
+		return nil, err
+	}
+	input.Tags = tags
 
 	id := NewID()
 	now := time.Now().UTC()
@@ -80,7 +108,11 @@ func (d *PostgresStore) CreateNode(input CreateNodeInput) (*Node, error) {
 	metadata := input.Metadata
 	if metadata == "" {
 		metadata = "{}"
+	} else if !json.Valid([]byte(metadata)) {
+		return nil, ErrInvalidMetadata
 	}
+	metadata = withLang(metadata, input.Content, false)
+	metadata = withRefs(metadata, input.Content, false)
 
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -126,6 +158,7 @@ func (d *PostgresStore) CreateNode(input CreateNodeInput) (*Node, error) {
 }
 
 func (d *PostgresStore) FindByTypeAndContent(nodeType, content string) (*Node, error) {
+	content = NormalizeContent(content)
 	var id string
 	err := d.db.QueryRow(
 		`SELECT id FROM nodes WHERE type = $1 AND content = $2 AND superseded_by IS NULL LIMIT 1`,
@@ -140,44 +173,7 @@ func (d *PostgresStore) FindByTypeAndContent(nodeType, content string) (*Node, e
 }
 
 func (d *PostgresStore) ResolveID(prefix string) (string, error) {
-	if len(prefix) == 26 {
-		var id string
-		err := d.db.QueryRow("SELECT id FROM nodes WHERE id = $1", prefix).Scan(&id)
-		if err == sql.ErrNoRows {
-			return "", ErrNotFound
-		}
-		if err != nil {
-			return "", fmt.Errorf("failed to resolve ID: %w", err)
-		}
-		return id, nil
-	}
-	if len(prefix) == 0 {
-		return "", fmt.Errorf("empty ID prefix")
-	}
-
-	rows, err := d.db.Query("SELECT id FROM nodes WHERE id LIKE $1 LIMIT 2", prefix+"%")
-	if err != nil {
-		return "", fmt.Errorf("failed to resolve ID prefix: %w", err)
-	}
-	defer rows.Close()
-
-	var matches []string
-	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
-			return "", fmt.Errorf("failed to scan ID: %w", err)
-		}
-		matches = append(matches, id)
-	}
-
-	switch len(matches) {
-	case 0:
-		return "", ErrNotFound
-	case 1:
-		return matches[0], nil
-	default:
-		return "", fmt.Errorf("ambiguous ID prefix %q: matches %s and %s", prefix, matches[0], matches[1])
-	}
+	return resolveID(d, prefix)
 }
 
 func (d *PostgresStore) GetNode(id string) (*Node, error) {
@@ -229,21 +225,32 @@ func (d *PostgresStore) UpdateNode(id string, input UpdateNodeInput) (*Node, err
 	summary := existing.Summary
 
 	if input.Content != nil {
-		content = *input.Content
+		content = NormalizeContent(*input.Content)
+		if content == "" {
+			return nil, fmt.Errorf("content cannot be empty")
+		}
 	}
 	if input.Type != nil {
-		if !validNodeTypes[*input.Type] {
+		if !knownType(d, KindNode, *input.Type) {
 			return nil, fmt.Errorf("invalid node type: %s", *input.Type)
 		}
 		nodeType = *input.Type
 	}
 	if input.Metadata != nil {
+		if *input.Metadata != "" && !json.Valid([]byte(*input.Metadata)) {
+			return nil, ErrInvalidMetadata
+		}
 		metadata = *input.Metadata
 	}
 	if input.Summary != nil {
 		summary = input.Summary
 	}
 
+	if content != existing.Content && input.Metadata == nil {
+		metadata = withLang(metadata, content, true)
+		metadata = withRefs(metadata, content, true)
+	}
+
 	tokenEst := token.Estimate(content)
 
 	var summaryVal sql.NullString
@@ -251,25 +258,55 @@ func (d *PostgresStore) UpdateNode(id string, input UpdateNodeInput) (*Node, err
 		summaryVal = sql.NullString{String: *summary, Valid: true}
 	}
 
-	_, err = d.db.Exec(`UPDATE nodes SET type=$1, content=$2, summary=$3, token_estimate=$4, updated_at=$5, metadata=$6
+	changed := content != existing.Content || nodeType != existing.Type || metadata != existing.Metadata ||
+		(summary == nil) != (existing.Summary == nil) || (summary != nil && *summary != *existing.Summary)
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if changed {
+		if err := insertRevision(d, tx, newRevision(existing)); err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE nodes SET type=$1, content=$2, summary=$3, token_estimate=$4, updated_at=$5, metadata=$6
 		WHERE id=$7`, nodeType, content, summaryVal, tokenEst, nowStr, metadata, id)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update node: %w", err)
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
 
 	return d.GetNode(id)
 }
 
+// DeleteNode deletes a node; see SQLiteStore.DeleteNode for how supersede
+// references to it are handled.
 func (d *PostgresStore) DeleteNode(id string) error {
-	result, err := d.db.Exec("DELETE FROM nodes WHERE id = $1", id)
+	tx, err := d.db.Begin()
 	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var supersededBy sql.NullString
+	if err := tx.QueryRow("SELECT superseded_by FROM nodes WHERE id = $1", id).Scan(&supersededBy); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
 		return fmt.Errorf("failed to delete node: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		return ErrNotFound
+	if _, err := tx.Exec("UPDATE nodes SET superseded_by = $1 WHERE superseded_by = $2", supersededBy, id); err != nil {
+		return fmt.Errorf("failed to release supersede references: %w", err)
 	}
-	return nil
+	if _, err := tx.Exec("DELETE FROM nodes WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete node: %w", err)
+	}
+	return tx.Commit()
 }
 
 func (d *PostgresStore) ListNodes(opts ListOptions) ([]*Node, error) {
@@ -335,11 +372,16 @@ func (d *PostgresStore) ListNodes(opts ListOptions) ([]*Node, error) {
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
-		tags, _ := d.GetTags(node.ID)
-		node.Tags = tags
 		nodes = append(nodes, node)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	rows.Close()
 
+	if err := attachTags(d, nodes); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
 
@@ -375,18 +417,23 @@ func (d *PostgresStore) Search(queryStr string) ([]*Node, error) {
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
-		tags, _ := d.GetTags(node.ID)
-		node.Tags = tags
 		nodes = append(nodes, node)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read nodes: %w", err)
+	}
+	rows.Close()
 
+	if err := attachTags(d, nodes); err != nil {
+		return nil, err
+	}
 	return nodes, nil
 }
 
 // --- Edge operations ---
 
 func (d *PostgresStore) CreateEdge(fromID, toID, edgeType string) (*Edge, error) {
-	if !validEdgeTypes[edgeType] {
+	if !knownType(d, KindEdge, edgeType) {
 		return nil, fmt.Errorf("invalid edge type: %s", edgeType)
 	}
 
@@ -466,8 +513,12 @@ func (d *PostgresStore) GetEdgesTo(nodeID string) ([]*Edge, error) {
 // --- Tag operations ---
 
 func (d *PostgresStore) AddTag(nodeID, tag string) error {
+	tag, err := newTag(d, tag)
+	if err != nil {
+		return err
+	}
 	now := time.Now().UTC().Format(time.RFC3339)
-	_, err := d.db.Exec(`INSERT INTO tags (node_id, tag, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+	_, err = d.db.Exec(`INSERT INTO tags (node_id, tag, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
 		nodeID, tag, now)
 	if err != nil {
 		return fmt.Errorf("failed to add tag: %w", err)
@@ -483,6 +534,29 @@ func (d *PostgresStore) RemoveTag(nodeID, tag string) error {
 	return nil
 }
 
+var postgresTagSQL = tagSQL{
+	exists: "SELECT 1 FROM nodes WHERE id = $1",
+	insert: "INSERT INTO tags (node_id, tag, created_at) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING",
+	remove: "DELETE FROM tags WHERE node_id = $1 AND tag = $2",
+	clear:  "DELETE FROM tags WHERE node_id = $1",
+}
+
+func (d *PostgresStore) AddTags(nodeID string, tags []string) error {
+	return updateTags(d, postgresTagSQL, nodeID, tags, nil, false)
+}
+
+func (d *PostgresStore) RemoveTags(nodeID string, tags []string) error {
+	return updateTags(d, postgresTagSQL, nodeID, nil, tags, false)
+}
+
+func (d *PostgresStore) UpdateTags(nodeID string, add, remove []string) error {
+	return updateTags(d, postgresTagSQL, nodeID, add, remove, false)
+}
+
+func (d *PostgresStore) SetTags(nodeID string, tags []string) error {
+	return updateTags(d, postgresTagSQL, nodeID, tags, nil, true)
+}
+
 func (d *PostgresStore) GetTags(nodeID string) ([]string, error) {
 	rows, err := d.db.Query("SELECT tag FROM tags WHERE node_id = $1 ORDER BY tag", nodeID)
 	if err != nil {
@@ -501,6 +575,10 @@ func (d *PostgresStore) GetTags(nodeID string) ([]string, error) {
 	return tags, nil
 }
 
+func (d *PostgresStore) GetTagsForNodes(ids []string) (map[string][]string, error) {
+	return getTagsForNodes(d, ids)
+}
+
 func (d *PostgresStore) ListAllTags() ([]string, error) {
 	rows, err := d.db.Query("SELECT DISTINCT tag FROM tags ORDER BY tag")
 	if err != nil {
@@ -571,6 +649,249 @@ func (d *PostgresStore) DeletePending(key string) error {
 	return err
 }
 
+func (d *PostgresStore) ListPending(prefix string) (map[string]string, error) {
+	rows, err := d.db.Query("SELECT key, value FROM pending WHERE key LIKE $1", prefix+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending %s: %w", prefix, err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan pending: %w", err)
+		}
+		out[key] = value
+	}
+	return out, rows.Err()
+}
+
+// --- Tool telemetry ---
+
+func (d *PostgresStore) RecordToolCall(tool string, elapsed time.Duration, failed bool) error {
+	ms := elapsed.Milliseconds()
+	errCount := 0
+	if failed {
+		errCount = 1
+	}
+	_, err := d.db.Exec(`INSERT INTO tool_stats (tool, calls, errors, total_ms, max_ms, last_called_at)
+		VALUES ($1, 1, $2, $3, $3, $4)
+		ON CONFLICT (tool) DO UPDATE SET
+			calls = tool_stats.calls + 1,
+			errors = tool_stats.errors + EXCLUDED.errors,
+			total_ms = tool_stats.total_ms + EXCLUDED.total_ms,
+			max_ms = GREATEST(tool_stats.max_ms, EXCLUDED.max_ms),
+			last_called_at = EXCLUDED.last_called_at`,
+		tool, errCount, ms, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record tool call: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresStore) ListToolStats() ([]*ToolStat, error) {
+	rows, err := d.db.Query(`SELECT tool, calls, errors, total_ms, max_ms, last_called_at
+		FROM tool_stats ORDER BY calls DESC, tool`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool stats: %w", err)
+	}
+	defer rows.Close()
+	return scanToolStats(rows)
+}
+
+// --- Missed recalls ---
+
+func (d *PostgresStore) RecordMissedRecall(query string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := d.db.Exec(`INSERT INTO missed_recalls (query, misses, first_missed_at, last_missed_at)
+		VALUES ($1, 1, $2, $2)
+		ON CONFLICT (query) DO UPDATE SET
+			misses = missed_recalls.misses + 1,
+			last_missed_at = EXCLUDED.last_missed_at`,
+		query, now)
+	if err != nil {
+		return fmt.Errorf("failed to record missed recall: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresStore) ListMissedRecalls(limit int) ([]*MissedRecall, error) {
+	rows, err := d.db.Query(`SELECT query, misses, first_missed_at, last_missed_at
+		FROM missed_recalls ORDER BY misses DESC, last_missed_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list missed recalls: %w", err)
+	}
+	defer rows.Close()
+	return scanMissedRecalls(rows)
+}
+
+// --- Change notifications ---
+
+// ListenChanges listens on ChangeChannel on a connection of its own. The
+// connection is discarded afterwards rather than returned to the pool
+// still listening.
+func (d *PostgresStore) ListenChanges(ctx context.Context, changed func(table string)) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection to listen on: %w", err)
+	}
+	defer conn.Close()
+
+	var listenErr error
+	_ = conn.Raw(func(driverConn any) error {
+		listenErr = listen(ctx, driverConn.(*stdlib.Conn).Conn(), changed)
+		return driver.ErrBadConn // discard the connection
+	})
+	return listenErr
+}
+
+func listen(ctx context.Context, conn *pgx.Conn, changed func(table string)) error {
+	if _, err := conn.Exec(ctx, "LISTEN "+ChangeChannel); err != nil {
+		return fmt.Errorf("failed to listen for changes: %w", err)
+	}
+	changed("")
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return fmt.Errorf("stopped listening for changes: %w", err)
+		}
+		changed(n.Payload)
+	}
+}
+
+// --- Hook runs ---
+
+func (d *PostgresStore) RecordHookRun(run *HookRun) error {
+	err := d.db.QueryRow(`INSERT INTO hook_runs (hook, started_at, duration_ms, commands, errors, deferred, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		run.Hook, run.StartedAt.UTC().Format(time.RFC3339), run.DurationMs, run.Commands, run.Errors, run.Deferred, run.Error).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record hook run: %w", err)
+	}
+	_, err = d.db.Exec("DELETE FROM hook_runs WHERE id <= $1", run.ID-maxHookRuns)
+	if err != nil {
+		return fmt.Errorf("failed to prune hook runs: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresStore) ListHookRuns(since time.Time, failedOnly bool, limit int) ([]*HookRun, error) {
+	q := `SELECT id, hook, started_at, duration_ms, commands, errors, deferred, error
+		FROM hook_runs WHERE started_at >= $1`
+	if failedOnly {
+		q += " AND errors > 0"
+	}
+	rows, err := d.db.Query(q+" ORDER BY id DESC LIMIT $2", since.UTC().Format(time.RFC3339), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hook runs: %w", err)
+	}
+	defer rows.Close()
+	return scanHookRuns(rows)
+}
+
+func (d *PostgresStore) ListHookStats(since time.Time) ([]*HookStat, error) {
+	rows, err := d.db.Query(`SELECT hook, COUNT(*), SUM(CASE WHEN errors > 0 THEN 1 ELSE 0 END),
+			SUM(commands), SUM(errors), SUM(deferred), SUM(duration_ms), MAX(duration_ms), MAX(started_at)
+		FROM hook_runs WHERE started_at >= $1 GROUP BY hook ORDER BY hook`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hook stats: %w", err)
+	}
+	defer rows.Close()
+	return scanHookStats(rows)
+}
+
+// --- Embeddings ---
+
+func (d *PostgresStore) UpsertEmbedding(e *Embedding) error {
+	_, err := d.db.Exec(`INSERT INTO embeddings (node_id, model, vector, content_hash, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (node_id, model) DO UPDATE SET
+			vector = EXCLUDED.vector,
+			content_hash = EXCLUDED.content_hash,
+			updated_at = EXCLUDED.updated_at`,
+		e.NodeID, e.Model, encodeVector(e.Vector), e.ContentHash, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to store embedding: %w", err)
+	}
+	return nil
+}
+
+func (d *PostgresStore) ListEmbeddings(model string) ([]*Embedding, error) {
+	rows, err := d.db.Query(`SELECT node_id, model, vector, content_hash, updated_at
+		FROM embeddings WHERE model = $1`, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list embeddings: %w", err)
+	}
+	defer rows.Close()
+	return scanEmbeddings(rows)
+}
+
+func (d *PostgresStore) SemanticSearch(e Embedder, text string, k int) ([]*ScoredNode, error) {
+	return semanticSearch(d.db.ctx, d, e, text, k)
+}
+
+// --- Node usage analytics ---
+
+func (d *PostgresStore) RecordNodeUsage(kind string, nodeIDs []string) error {
+	col, err := usageColumn(kind)
+	if err != nil {
+		return err
+	}
+
+	var lastUsed interface{}
+	if kind != UsageInjected {
+		lastUsed = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := fmt.Sprintf(`INSERT INTO node_usage (node_id, %[1]s, last_used_at) VALUES ($1, 1, $2)
+		ON CONFLICT (node_id) DO UPDATE SET
+			%[1]s = node_usage.%[1]s + 1,
+			last_used_at = COALESCE(EXCLUDED.last_used_at, node_usage.last_used_at)`, col)
+	for _, id := range nodeIDs {
+		if _, err := tx.Exec(stmt, id, lastUsed); err != nil {
+			return fmt.Errorf("failed to record node usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *PostgresStore) GetNodeUsage() (map[string]*NodeUsage, error) {
+	rows, err := d.db.Query("SELECT node_id, injected, referenced, recalled, last_used_at FROM node_usage")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node usage: %w", err)
+	}
+	defer rows.Close()
+	return scanNodeUsage(rows)
+}
+
+func (d *PostgresStore) RecordRevision(node *Node) (*Revision, error) {
+	rev := newRevision(node)
+	_, err := d.db.Exec(`INSERT INTO node_revisions (id, node_id, type, content, summary, token_estimate, metadata, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		rev.ID, rev.NodeID, rev.Type, rev.Content, rev.Summary, rev.TokenEstimate, rev.Metadata, rev.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+	return rev, nil
+}
+
+func (d *PostgresStore) ListRevisions(nodeID string) ([]*Revision, error) {
+	rows, err := d.db.Query(`SELECT id, node_id, type, content, summary, token_estimate, metadata, created_at
+		FROM node_revisions WHERE node_id = $1 ORDER BY id`, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
 // --- Migrations ---
 
 var postgresMigrations = []struct {
@@ -689,6 +1010,187 @@ var postgresMigrations = []struct {
 		ALTER TABLE nodes ADD COLUMN IF NOT EXISTS sync_version BIGINT DEFAULT 0;
 		ALTER TABLE nodes ADD COLUMN IF NOT EXISTS origin_device TEXT;
 	`},
+	{3, `
+		-- Local MCP tool usage telemetry
+		CREATE TABLE IF NOT EXISTS tool_stats (
+			tool TEXT PRIMARY KEY,
+			calls INTEGER NOT NULL DEFAULT 0,
+			errors INTEGER NOT NULL DEFAULT 0,
+			total_ms BIGINT NOT NULL DEFAULT 0,
+			max_ms BIGINT NOT NULL DEFAULT 0,
+			last_called_at TEXT NOT NULL
+		);
+	`},
+	{4, `
+		-- Per-node session usage: how often a node is injected vs. actually used
+		CREATE TABLE IF NOT EXISTS node_usage (
+			node_id TEXT PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+			injected INTEGER NOT NULL DEFAULT 0,
+			referenced INTEGER NOT NULL DEFAULT 0,
+			recalled INTEGER NOT NULL DEFAULT 0,
+			last_used_at TEXT
+		);
+	`},
+	{5, `
+		-- Prior states of edited nodes
+		CREATE TABLE IF NOT EXISTS node_revisions (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+			type TEXT NOT NULL,
+			content TEXT NOT NULL,
+			summary TEXT,
+			token_estimate INTEGER NOT NULL DEFAULT 0,
+			metadata TEXT DEFAULT '{}',
+			created_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_node_revisions_node ON node_revisions(node_id);
+	`},
+	{6, `
+		-- Deleting a node hands its superseded_by to the nodes it superseded,
+		-- instead of failing on (or, via raw SQL, dangling) the reference.
+		UPDATE nodes SET superseded_by = NULL
+			WHERE superseded_by IS NOT NULL AND superseded_by NOT IN (SELECT id FROM nodes);
+
+		CREATE OR REPLACE FUNCTION nodes_inherit_supersede() RETURNS trigger AS $$
+		BEGIN
+			UPDATE nodes SET superseded_by = OLD.superseded_by WHERE superseded_by = OLD.id;
+			RETURN OLD;
+		END
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS nodes_supersede_bd ON nodes;
+		CREATE TRIGGER nodes_supersede_bd BEFORE DELETE ON nodes
+			FOR EACH ROW EXECUTE FUNCTION nodes_inherit_supersede();
+	`},
+	{7, `
+		-- Per-device and per-user quota usage is summed by origin device
+		CREATE INDEX IF NOT EXISTS idx_nodes_origin_device ON nodes(origin_device);
+	`},
+	{8, `
+		-- Recall queries that found nothing: what the agent looks for but lacks
+		CREATE TABLE IF NOT EXISTS missed_recalls (
+			query TEXT PRIMARY KEY,
+			misses INTEGER NOT NULL DEFAULT 0,
+			first_missed_at TEXT NOT NULL,
+			last_missed_at TEXT NOT NULL
+		);
+	`},
+	{9, `
+		-- Node vectors for semantic search, one per embedding model
+		CREATE TABLE IF NOT EXISTS embeddings (
+			node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+			model TEXT NOT NULL,
+			vector BYTEA NOT NULL,
+			content_hash TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+			PRIMARY KEY (node_id, model)
+		);
+	`},
+	{10, `
+		-- Per-view render layout (section order, headings, icons, primer)
+		ALTER TABLE views ADD COLUMN IF NOT EXISTS layout TEXT NOT NULL DEFAULT '{}';
+	`},
+	{11, `
+		-- Saved query expressions, referenced as @name in other queries
+		CREATE TABLE IF NOT EXISTS queries (
+			name TEXT PRIMARY KEY,
+			query TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);
+	`},
+	{12, `
+		-- One row per hook invocation: duration, commands parsed, failures
+		CREATE TABLE IF NOT EXISTS hook_runs (
+			id BIGSERIAL PRIMARY KEY,
+			hook TEXT NOT NULL,
+			started_at TEXT NOT NULL,
+			duration_ms BIGINT NOT NULL,
+			commands INTEGER NOT NULL DEFAULT 0,
+			errors INTEGER NOT NULL DEFAULT 0,
+			deferred INTEGER NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT ''
+		);
+		CREATE INDEX IF NOT EXISTS idx_hook_runs_started ON hook_runs(started_at);
+	`},
+	{13, `
+		-- Announce changes on the ctx_changes channel, so every server
+		-- instance sharing the database can drop its caches. One
+		-- notification per statement, carrying the table name.
+		CREATE OR REPLACE FUNCTION ctx_notify_change() RETURNS trigger AS $$
+		BEGIN
+			PERFORM pg_notify('ctx_changes', TG_TABLE_NAME);
+			RETURN NULL;
+		END
+		$$ LANGUAGE plpgsql;
+
+		DROP TRIGGER IF EXISTS nodes_notify ON nodes;
+		CREATE TRIGGER nodes_notify AFTER INSERT OR UPDATE OR DELETE ON nodes
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS edges_notify ON edges;
+		CREATE TRIGGER edges_notify AFTER INSERT OR UPDATE OR DELETE ON edges
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS tags_notify ON tags;
+		CREATE TRIGGER tags_notify AFTER INSERT OR UPDATE OR DELETE ON tags
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS views_notify ON views;
+		CREATE TRIGGER views_notify AFTER INSERT OR UPDATE OR DELETE ON views
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS queries_notify ON queries;
+		CREATE TRIGGER queries_notify AFTER INSERT OR UPDATE OR DELETE ON queries
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS node_usage_notify ON node_usage;
+		CREATE TRIGGER node_usage_notify AFTER INSERT OR UPDATE OR DELETE ON node_usage
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+		DROP TRIGGER IF EXISTS missed_recalls_notify ON missed_recalls;
+		CREATE TRIGGER missed_recalls_notify AFTER INSERT OR UPDATE OR DELETE ON missed_recalls
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+	`},
+	{14, `
+		-- Node and edge types registered on top of the built-in ones
+		CREATE TABLE IF NOT EXISTS type_registry (
+			kind TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL,
+			PRIMARY KEY (kind, name)
+		);
+	`},
+	{15, `
+		-- Files kept with a node: screenshots, PDFs, original sources
+		CREATE TABLE IF NOT EXISTS attachments (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			media_type TEXT NOT NULL,
+			size BIGINT NOT NULL,
+			sha256 TEXT NOT NULL,
+			data BYTEA NOT NULL,
+			created_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_attachments_node ON attachments(node_id);
+	`},
+	{16, `
+		-- Every read of a node, for accessed: queries and pruning
+		CREATE TABLE IF NOT EXISTS node_access (
+			node_id TEXT PRIMARY KEY REFERENCES nodes(id) ON DELETE CASCADE,
+			access_count INTEGER NOT NULL DEFAULT 0,
+			last_accessed_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_node_access_last ON node_access(last_accessed_at);
+	`},
+	{17, `
+		-- Announce reads too, so cached accessed: results are dropped. The
+		-- server drops only those for node_access, keeping composes cached.
+		DROP TRIGGER IF EXISTS node_access_notify ON node_access;
+		CREATE TRIGGER node_access_notify AFTER INSERT OR UPDATE OR DELETE ON node_access
+			FOR EACH STATEMENT EXECUTE FUNCTION ctx_notify_change();
+	`},
+	{18, `
+		-- A read-only token per device for the digest feed URL
+		ALTER TABLE devices ADD COLUMN IF NOT EXISTS digest_token_hash TEXT;
+		CREATE INDEX IF NOT EXISTS idx_devices_digest_token ON devices(digest_token_hash);
+	`},
 }
 
 func (d *PostgresStore) migrate() error {
@@ -742,3 +1244,91 @@ func (d *PostgresStore) migrate() error {
 
 	return nil
 }
+
+// --- Server tables ---
+
+func (d *PostgresStore) server() serverTables { return serverTables{d.db, BindDollar} }
+
+func (d *PostgresStore) EnsureUser(username, passwordHash string) (string, error) {
+	return d.server().ensureUser(username, passwordHash)
+}
+
+func (d *PostgresStore) CreateDevice(userID, name, tokenHash, refreshHash string) (*Device, error) {
+	return d.server().createDevice(userID, name, tokenHash, refreshHash)
+}
+
+func (d *PostgresStore) GetDeviceByToken(tokenHash string) (*Device, error) {
+	return d.server().getDevice("token_hash = ?", tokenHash)
+}
+
+func (d *PostgresStore) GetDeviceByRefreshToken(id, refreshHash string) (*Device, error) {
+	return d.server().getDevice("id = ? AND refresh_token_hash = ?", id, refreshHash)
+}
+
+func (d *PostgresStore) RotateDeviceTokens(id, tokenHash, refreshHash string) error {
+	return d.server().rotateDeviceTokens(id, tokenHash, refreshHash)
+}
+
+func (d *PostgresStore) TouchDevice(id, ip string) error {
+	return d.server().touchDevice(id, ip)
+}
+
+func (d *PostgresStore) ListDevices() ([]*Device, error) {
+	return d.server().listDevices()
+}
+
+func (d *PostgresStore) RevokeDevice(id string) error {
+	return d.server().execOne("UPDATE devices SET revoked = TRUE WHERE id = ?", id)
+}
+
+func (d *PostgresStore) SetDigestToken(id, tokenHash string) error {
+	return d.server().setDigestToken(id, tokenHash)
+}
+
+func (d *PostgresStore) GetDeviceByDigestToken(tokenHash string) (*Device, error) {
+	return d.server().getDevice("digest_token_hash = ?", tokenHash)
+}
+
+func (d *PostgresStore) RenameDevice(id, name string) error {
+	return d.server().execOne("UPDATE devices SET name = ? WHERE id = ?", name, id)
+}
+
+func (d *PostgresStore) ListUsers() ([]*User, error) {
+	return d.server().listUsers()
+}
+
+func (d *PostgresStore) SetOriginDevice(nodeID, deviceID string) error {
+	return d.server().execOne("UPDATE nodes SET origin_device = ? WHERE id = ?", deviceID, nodeID)
+}
+
+func (d *PostgresStore) DeviceUsage(deviceID string) (*Usage, error) {
+	return d.server().usage("origin_device = ?", deviceID)
+}
+
+func (d *PostgresStore) UserUsage(userID string) (*Usage, error) {
+	return d.server().usage("origin_device IN (SELECT id FROM devices WHERE user_id = ?)", userID)
+}
+
+func (d *PostgresStore) UserNodeIDs(userID string) ([]string, error) {
+	return d.server().userNodeIDs(userID)
+}
+
+func (d *PostgresStore) UpsertRepoMapping(normalizedURL, projectTag string) (*RepoMapping, error) {
+	return d.server().upsertRepoMapping(normalizedURL, projectTag)
+}
+
+func (d *PostgresStore) ListRepoMappings() ([]*RepoMapping, error) {
+	return d.server().listRepoMappings()
+}
+
+func (d *PostgresStore) Stats() (*Stats, error) {
+	return d.server().stats()
+}
+
+func (d *PostgresStore) BumpSyncVersion(nodeID string) error {
+	return d.server().bumpSyncVersion(nodeID)
+}
+
+func (d *PostgresStore) MaxSyncVersion() (int64, error) {
+	return d.server().maxSyncVersion()
+}
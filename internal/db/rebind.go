@@ -0,0 +1,83 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Bindvar is a positional placeholder style.
+type Bindvar int
+
+const (
+	// BindQuestion is SQLite's style: ? or the numbered ?N.
+	BindQuestion Bindvar = iota
+	// BindDollar is PostgreSQL's style: $N.
+	BindDollar
+)
+
+// Rebind rewrites the positional placeholders in query to the given style,
+// so raw SQL can be written once with either ? or $N and run on both
+// backends. Placeholders inside quoted strings and identifiers are left
+// alone.
+//
+// For BindDollar, each ? becomes the next $N; a query that already uses $N
+// is returned unchanged, so PostgreSQL's jsonb ? operator stays usable
+// there. For BindQuestion, $N becomes ?N, which SQLite binds by position
+// even when a placeholder repeats or appears out of order.
+func Rebind(style Bindvar, query string) string {
+	if style == BindDollar && hasDollarPlaceholder(query) {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			b.WriteByte(c)
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"':
+			quote = c
+			b.WriteByte(c)
+		case c == '?' && style == BindDollar:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		case c == '$' && style == BindQuestion && i+1 < len(query) && isDigit(query[i+1]):
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// hasDollarPlaceholder reports whether query contains $N outside quotes.
+func hasDollarPlaceholder(query string) bool {
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			return true
+		}
+	}
+	return false
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
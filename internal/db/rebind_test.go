@@ -0,0 +1,46 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		style db.Bindvar
+		in    string
+		want  string
+	}{
+		{"question to dollar", db.BindDollar, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"dollar unchanged for postgres", db.BindDollar, "SELECT * FROM t WHERE a = $1", "SELECT * FROM t WHERE a = $1"},
+		{"jsonb operator kept with dollar args", db.BindDollar, "SELECT * FROM t WHERE m ? 'k' AND a = $1", "SELECT * FROM t WHERE m ? 'k' AND a = $1"},
+		{"dollar to numbered question", db.BindQuestion, "UPDATE t SET a = $2 WHERE id = $1", "UPDATE t SET a = ?2 WHERE id = ?1"},
+		{"question unchanged for sqlite", db.BindQuestion, "SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+		{"quoted text untouched", db.BindDollar, "SELECT '?', \"a?\" FROM t WHERE a = ?", "SELECT '?', \"a?\" FROM t WHERE a = $1"},
+		{"quoted dollar untouched", db.BindQuestion, "SELECT '$1' FROM t WHERE a = $1", "SELECT '$1' FROM t WHERE a = ?1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, db.Rebind(tt.style, tt.in))
+		})
+	}
+}
+
+func TestExec_DollarPlaceholdersOutOfOrder(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "before"})
+	require.NoError(t, err)
+
+	_, err = d.Exec("UPDATE nodes SET content = $2 WHERE id = $1", node.ID, "after")
+	require.NoError(t, err)
+
+	var content string
+	require.NoError(t, d.QueryRow("SELECT content FROM nodes WHERE id = ?", node.ID).Scan(&content))
+	assert.Equal(t, "after", content)
+}
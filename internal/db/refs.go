@@ -0,0 +1,43 @@
+package db
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/zate/ctx/internal/refs"
+)
+
+// withRefs records the issue and pull request references in content under
+// metadata's "refs" key, normalized to org/repo#123, for ref: queries.
+// Refs already set are kept unless replace is, as when the content
+// changed; content that no longer mentions any then loses them. Metadata
+// that isn't a JSON object is returned as it is.
+func withRefs(metadata, content string, replace bool) string {
+	fields := map[string]any{}
+	dec := json.NewDecoder(strings.NewReader(metadata))
+	dec.UseNumber() // keep large integers exact when re-encoding
+	if dec.Decode(&fields) != nil || fields == nil {
+		return metadata
+	}
+	if _, ok := fields["refs"]; ok && !replace {
+		return metadata
+	}
+	found := refs.Extract(content)
+	if len(found) == 0 {
+		if _, ok := fields["refs"]; !ok {
+			return metadata
+		}
+		delete(fields, "refs")
+	} else {
+		if old, ok := fields["refs"].([]any); ok && slices.EqualFunc(old, found, func(a any, b string) bool { return a == b }) {
+			return metadata
+		}
+		fields["refs"] = found
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return metadata
+	}
+	return string(data)
+}
@@ -0,0 +1,86 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Revision is a prior state of a node. UpdateNode records one before each
+// change, so a node's revisions trace how it evolved.
+type Revision struct {
+	ID            string    `json:"id"`
+	NodeID        string    `json:"node_id"`
+	Type          string    `json:"type"`
+	Content       string    `json:"content"`
+	Summary       *string   `json:"summary,omitempty"`
+	TokenEstimate int       `json:"token_estimate"`
+	Metadata      string    `json:"metadata"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// newRevision snapshots node as a revision. Revision IDs are ULIDs, so
+// ordering by ID is chronological.
+func newRevision(node *Node) *Revision {
+	return &Revision{
+		ID:            NewID(),
+		NodeID:        node.ID,
+		Type:          node.Type,
+		Content:       node.Content,
+		Summary:       node.Summary,
+		TokenEstimate: node.TokenEstimate,
+		Metadata:      node.Metadata,
+		CreatedAt:     time.Now().UTC(),
+	}
+}
+
+// insertRevision stores a revision from newRevision on tx, so UpdateNode
+// records it in the same transaction as the change it precedes.
+func insertRevision(d Store, tx *sql.Tx, rev *Revision) error {
+	_, err := tx.Exec(d.Rebind(`INSERT INTO node_revisions (id, node_id, type, content, summary, token_estimate, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`),
+		rev.ID, rev.NodeID, rev.Type, rev.Content, rev.Summary, rev.TokenEstimate, rev.Metadata, rev.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) RecordRevision(node *Node) (*Revision, error) {
+	rev := newRevision(node)
+	_, err := d.db.Exec(`INSERT INTO node_revisions (id, node_id, type, content, summary, token_estimate, metadata, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		rev.ID, rev.NodeID, rev.Type, rev.Content, rev.Summary, rev.TokenEstimate, rev.Metadata, rev.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to record revision: %w", err)
+	}
+	return rev, nil
+}
+
+func (d *SQLiteStore) ListRevisions(nodeID string) ([]*Revision, error) {
+	rows, err := d.db.Query(`SELECT id, node_id, type, content, summary, token_estimate, metadata, created_at
+		FROM node_revisions WHERE node_id = ? ORDER BY id`, nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revisions: %w", err)
+	}
+	defer rows.Close()
+	return scanRevisions(rows)
+}
+
+func scanRevisions(rows *sql.Rows) ([]*Revision, error) {
+	var revs []*Revision
+	for rows.Next() {
+		var r Revision
+		var summary sql.NullString
+		var createdAt string
+		if err := rows.Scan(&r.ID, &r.NodeID, &r.Type, &r.Content, &summary, &r.TokenEstimate, &r.Metadata, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %w", err)
+		}
+		if summary.Valid {
+			r.Summary = &summary.String
+		}
+		r.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		revs = append(revs, &r)
+	}
+	return revs, rows.Err()
+}
@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRecordRevision(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use sqlite"})
+	require.NoError(t, err)
+
+	// UpdateNode records the prior state itself
+	newContent := "use sqlite locally, postgres on the server"
+	updated, err := d.UpdateNode(node.ID, db.UpdateNodeInput{Content: &newContent})
+	require.NoError(t, err)
+	_, err = d.RecordRevision(updated)
+	require.NoError(t, err)
+
+	revs, err := d.ListRevisions(node.ID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "use sqlite", revs[0].Content)
+	assert.Equal(t, newContent, revs[1].Content)
+	assert.Equal(t, "decision", revs[0].Type)
+	assert.Equal(t, node.TokenEstimate, revs[0].TokenEstimate)
+
+	// Revisions go with the node
+	require.NoError(t, d.DeleteNode(node.ID))
+	revs, err = d.ListRevisions(node.ID)
+	require.NoError(t, err)
+	assert.Empty(t, revs)
+}
+
+func TestUpdateNodeRecordsRevision(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "v1"})
+	require.NoError(t, err)
+
+	v2 := "v2"
+	_, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Content: &v2})
+	require.NoError(t, err)
+	decision := "decision"
+	_, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Type: &decision})
+	require.NoError(t, err)
+
+	// An update that changes nothing records nothing
+	_, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Content: &v2})
+	require.NoError(t, err)
+
+	revs, err := d.ListRevisions(node.ID)
+	require.NoError(t, err)
+	require.Len(t, revs, 2)
+	assert.Equal(t, "v1", revs[0].Content)
+	assert.Equal(t, "fact", revs[0].Type)
+	assert.Equal(t, "v2", revs[1].Content)
+	assert.Equal(t, "fact", revs[1].Type)
+}
+
+func TestUpdateNodeFailureRecordsNoRevision(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "v1"})
+	require.NoError(t, err)
+	_, err = d.Exec(`CREATE TRIGGER fail_update BEFORE UPDATE ON nodes BEGIN SELECT RAISE(ABORT, 'update refused'); END`)
+	require.NoError(t, err)
+
+	v2 := "v2"
+	_, err = d.UpdateNode(node.ID, db.UpdateNodeInput{Content: &v2})
+	require.Error(t, err)
+
+	// The revision was rolled back with the update
+	revs, err := d.ListRevisions(node.ID)
+	require.NoError(t, err)
+	assert.Empty(t, revs)
+}
@@ -0,0 +1,391 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Device is a client authorized against the ctx server via the device flow.
+type Device struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	Name      string     `json:"name"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	LastIP    string     `json:"last_ip,omitempty"`
+	Revoked   bool       `json:"revoked"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// User is a server account that devices are authorized under.
+type User struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Devices   int       `json:"devices"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RepoMapping maps a normalized git remote URL to a project tag.
+type RepoMapping struct {
+	ID            string    `json:"id"`
+	NormalizedURL string    `json:"normalized_url"`
+	ProjectTag    string    `json:"project_tag"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Stats are the headline counts shown by /api/status and the dashboard.
+// Superseded nodes are excluded from the node and token totals.
+type Stats struct {
+	TotalNodes  int `json:"total_nodes"`
+	TotalTokens int `json:"total_tokens"`
+	TotalEdges  int `json:"total_edges"`
+	UniqueTags  int `json:"unique_tags"`
+	Devices     int `json:"devices"`
+}
+
+// Usage is the storage a device or user's nodes take up on the server,
+// superseded nodes included, with the size of the files attached to them.
+type Usage struct {
+	Nodes           int `json:"nodes"`
+	Tokens          int `json:"tokens"`
+	AttachmentBytes int `json:"attachment_bytes"`
+}
+
+// serverTables implements the users, devices, repo_mappings and sync
+// methods once for both backends. The tables have the same shape in each;
+// queries are written with ? and rebound to the backend's style.
+type serverTables struct {
+	db    conn
+	style Bindvar
+}
+
+func (s serverTables) q(query string) string {
+	return Rebind(s.style, query)
+}
+
+const deviceColumns = "id, user_id, name, last_seen, last_ip, revoked, created_at"
+
+func scanDevice(row interface{ Scan(...any) error }) (*Device, error) {
+	dev := &Device{}
+	var lastSeen, lastIP sql.NullString
+	var createdAt string
+	if err := row.Scan(&dev.ID, &dev.UserID, &dev.Name, &lastSeen, &lastIP, &dev.Revoked, &createdAt); err != nil {
+		return nil, err
+	}
+	if lastSeen.Valid && lastSeen.String != "" {
+		if t, err := time.Parse(time.RFC3339, lastSeen.String); err == nil {
+			dev.LastSeen = &t
+		}
+	}
+	dev.LastIP = lastIP.String
+	dev.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return dev, nil
+}
+
+func (s serverTables) ensureUser(username, passwordHash string) (string, error) {
+	var id string
+	err := s.db.QueryRow(s.q("SELECT id FROM users WHERE username = ?"), username).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	id = NewID()
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := s.db.Exec(s.q("INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, ?, ?)"),
+		id, username, passwordHash, now); err != nil {
+		return "", fmt.Errorf("failed to create user: %w", err)
+	}
+	return id, nil
+}
+
+func (s serverTables) createDevice(userID, name, tokenHash, refreshHash string) (*Device, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	dev := &Device{ID: NewID(), UserID: userID, Name: name, LastSeen: &now, CreatedAt: now}
+	stamp := now.Format(time.RFC3339)
+	_, err := s.db.Exec(s.q(`INSERT INTO devices (id, user_id, name, token_hash, refresh_token_hash, last_seen, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		dev.ID, userID, name, tokenHash, refreshHash, stamp, stamp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device: %w", err)
+	}
+	return dev, nil
+}
+
+func (s serverTables) getDevice(where string, args ...any) (*Device, error) {
+	dev, err := scanDevice(s.db.QueryRow(s.q("SELECT "+deviceColumns+" FROM devices WHERE "+where), args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get device: %w", err)
+	}
+	return dev, nil
+}
+
+func (s serverTables) rotateDeviceTokens(id, tokenHash, refreshHash string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return s.execOne("UPDATE devices SET token_hash = ?, refresh_token_hash = ?, last_seen = ? WHERE id = ?",
+		tokenHash, refreshHash, now, id)
+}
+
+// setDigestToken stores the hash of a device's digest feed token,
+// replacing any earlier one; an empty hash revokes it.
+func (s serverTables) setDigestToken(id, tokenHash string) error {
+	var hash any
+	if tokenHash != "" {
+		hash = tokenHash
+	}
+	return s.execOne("UPDATE devices SET digest_token_hash = ? WHERE id = ?", hash, id)
+}
+
+func (s serverTables) touchDevice(id, ip string) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return s.execOne("UPDATE devices SET last_seen = ?, last_ip = ? WHERE id = ?", now, ip, id)
+}
+
+func (s serverTables) listDevices() ([]*Device, error) {
+	rows, err := s.db.Query("SELECT " + deviceColumns + " FROM devices ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer rows.Close()
+
+	var devices []*Device
+	for rows.Next() {
+		dev, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, dev)
+	}
+	return devices, rows.Err()
+}
+
+func (s serverTables) listUsers() ([]*User, error) {
+	rows, err := s.db.Query(`SELECT u.id, u.username, u.created_at, COUNT(d.id)
+		FROM users u LEFT JOIN devices d ON d.user_id = u.id
+		GROUP BY u.id, u.username, u.created_at
+		ORDER BY u.created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		u := &User{}
+		var createdAt string
+		if err := rows.Scan(&u.ID, &u.Username, &createdAt, &u.Devices); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		u.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s serverTables) usage(where string, arg string) (*Usage, error) {
+	u := &Usage{}
+	err := s.db.QueryRow(s.q(`SELECT COUNT(*), COALESCE(SUM(token_estimate), 0),
+		(SELECT COALESCE(SUM(size), 0) FROM attachments WHERE node_id IN (SELECT id FROM nodes WHERE `+where+`))
+		FROM nodes WHERE `+where), arg, arg).
+		Scan(&u.Nodes, &u.Tokens, &u.AttachmentBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute usage: %w", err)
+	}
+	return u, nil
+}
+
+func (s serverTables) userNodeIDs(userID string) ([]string, error) {
+	rows, err := s.db.Query(s.q(`SELECT id FROM nodes
+		WHERE origin_device IN (SELECT id FROM devices WHERE user_id = ?) ORDER BY id`), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user nodes: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan node ID: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// execOne runs an UPDATE that must match exactly one row, returning
+// ErrNotFound if it matched none.
+func (s serverTables) execOne(query string, args ...any) error {
+	result, err := s.db.Exec(s.q(query), args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s serverTables) upsertRepoMapping(normalizedURL, projectTag string) (*RepoMapping, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	_, err := s.db.Exec(s.q(`INSERT INTO repo_mappings (id, normalized_url, project_tag, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (normalized_url) DO UPDATE SET project_tag = excluded.project_tag`),
+		NewID(), normalizedURL, projectTag, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to save repo mapping: %w", err)
+	}
+
+	m := &RepoMapping{}
+	var createdAt string
+	err = s.db.QueryRow(s.q("SELECT id, normalized_url, project_tag, created_at FROM repo_mappings WHERE normalized_url = ?"),
+		normalizedURL).Scan(&m.ID, &m.NormalizedURL, &m.ProjectTag, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repo mapping: %w", err)
+	}
+	m.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return m, nil
+}
+
+func (s serverTables) listRepoMappings() ([]*RepoMapping, error) {
+	rows, err := s.db.Query("SELECT id, normalized_url, project_tag, created_at FROM repo_mappings ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repo mappings: %w", err)
+	}
+	defer rows.Close()
+
+	var mappings []*RepoMapping
+	for rows.Next() {
+		m := &RepoMapping{}
+		var createdAt string
+		if err := rows.Scan(&m.ID, &m.NormalizedURL, &m.ProjectTag, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan repo mapping: %w", err)
+		}
+		m.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		mappings = append(mappings, m)
+	}
+	return mappings, rows.Err()
+}
+
+func (s serverTables) stats() (*Stats, error) {
+	st := &Stats{}
+	counts := []struct {
+		dest  *int
+		query string
+	}{
+		{&st.TotalNodes, "SELECT COUNT(*) FROM nodes WHERE superseded_by IS NULL"},
+		{&st.TotalTokens, "SELECT COALESCE(SUM(token_estimate), 0) FROM nodes WHERE superseded_by IS NULL"},
+		{&st.TotalEdges, "SELECT COUNT(*) FROM edges"},
+		{&st.UniqueTags, "SELECT COUNT(DISTINCT tag) FROM tags"},
+		{&st.Devices, "SELECT COUNT(*) FROM devices"},
+	}
+	for _, c := range counts {
+		if err := s.db.QueryRow(c.query).Scan(c.dest); err != nil {
+			return nil, fmt.Errorf("failed to compute stats: %w", err)
+		}
+	}
+	return st, nil
+}
+
+func (s serverTables) bumpSyncVersion(nodeID string) error {
+	return s.execOne("UPDATE nodes SET sync_version = sync_version + 1 WHERE id = ?", nodeID)
+}
+
+func (s serverTables) maxSyncVersion() (int64, error) {
+	var v int64
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(sync_version), 0) FROM nodes").Scan(&v); err != nil {
+		return 0, fmt.Errorf("failed to read sync version: %w", err)
+	}
+	return v, nil
+}
+
+// --- SQLiteStore ---
+
+func (d *SQLiteStore) server() serverTables { return serverTables{d.db, BindQuestion} }
+
+func (d *SQLiteStore) EnsureUser(username, passwordHash string) (string, error) {
+	return d.server().ensureUser(username, passwordHash)
+}
+
+func (d *SQLiteStore) CreateDevice(userID, name, tokenHash, refreshHash string) (*Device, error) {
+	return d.server().createDevice(userID, name, tokenHash, refreshHash)
+}
+
+func (d *SQLiteStore) GetDeviceByToken(tokenHash string) (*Device, error) {
+	return d.server().getDevice("token_hash = ?", tokenHash)
+}
+
+func (d *SQLiteStore) GetDeviceByRefreshToken(id, refreshHash string) (*Device, error) {
+	return d.server().getDevice("id = ? AND refresh_token_hash = ?", id, refreshHash)
+}
+
+func (d *SQLiteStore) RotateDeviceTokens(id, tokenHash, refreshHash string) error {
+	return d.server().rotateDeviceTokens(id, tokenHash, refreshHash)
+}
+
+func (d *SQLiteStore) TouchDevice(id, ip string) error {
+	return d.server().touchDevice(id, ip)
+}
+
+func (d *SQLiteStore) ListDevices() ([]*Device, error) {
+	return d.server().listDevices()
+}
+
+func (d *SQLiteStore) RevokeDevice(id string) error {
+	return d.server().execOne("UPDATE devices SET revoked = TRUE WHERE id = ?", id)
+}
+
+func (d *SQLiteStore) SetDigestToken(id, tokenHash string) error {
+	return d.server().setDigestToken(id, tokenHash)
+}
+
+func (d *SQLiteStore) GetDeviceByDigestToken(tokenHash string) (*Device, error) {
+	return d.server().getDevice("digest_token_hash = ?", tokenHash)
+}
+
+func (d *SQLiteStore) RenameDevice(id, name string) error {
+	return d.server().execOne("UPDATE devices SET name = ? WHERE id = ?", name, id)
+}
+
+func (d *SQLiteStore) ListUsers() ([]*User, error) {
+	return d.server().listUsers()
+}
+
+func (d *SQLiteStore) SetOriginDevice(nodeID, deviceID string) error {
+	return d.server().execOne("UPDATE nodes SET origin_device = ? WHERE id = ?", deviceID, nodeID)
+}
+
+func (d *SQLiteStore) DeviceUsage(deviceID string) (*Usage, error) {
+	return d.server().usage("origin_device = ?", deviceID)
+}
+
+func (d *SQLiteStore) UserUsage(userID string) (*Usage, error) {
+	return d.server().usage("origin_device IN (SELECT id FROM devices WHERE user_id = ?)", userID)
+}
+
+func (d *SQLiteStore) UserNodeIDs(userID string) ([]string, error) {
+	return d.server().userNodeIDs(userID)
+}
+
+func (d *SQLiteStore) UpsertRepoMapping(normalizedURL, projectTag string) (*RepoMapping, error) {
+	return d.server().upsertRepoMapping(normalizedURL, projectTag)
+}
+
+func (d *SQLiteStore) ListRepoMappings() ([]*RepoMapping, error) {
+	return d.server().listRepoMappings()
+}
+
+func (d *SQLiteStore) Stats() (*Stats, error) {
+	return d.server().stats()
+}
+
+func (d *SQLiteStore) BumpSyncVersion(nodeID string) error {
+	return d.server().bumpSyncVersion(nodeID)
+}
+
+func (d *SQLiteStore) MaxSyncVersion() (int64, error) {
+	return d.server().maxSyncVersion()
+}
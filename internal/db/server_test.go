@@ -0,0 +1,169 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestEnsureUser_Idempotent(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	id1, err := d.EnsureUser("admin", "hash")
+	require.NoError(t, err)
+	id2, err := d.EnsureUser("admin", "other")
+	require.NoError(t, err)
+	assert.Equal(t, id1, id2)
+}
+
+func TestDeviceLifecycle(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	userID, err := d.EnsureUser("admin", "hash")
+	require.NoError(t, err)
+
+	dev, err := d.CreateDevice(userID, "laptop", "tok", "ref")
+	require.NoError(t, err)
+
+	got, err := d.GetDeviceByToken("tok")
+	require.NoError(t, err)
+	assert.Equal(t, dev.ID, got.ID)
+	assert.Equal(t, "laptop", got.Name)
+	assert.False(t, got.Revoked)
+	require.NotNil(t, got.LastSeen)
+
+	_, err = d.GetDeviceByRefreshToken(dev.ID, "wrong")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	require.NoError(t, d.RotateDeviceTokens(dev.ID, "tok2", "ref2"))
+	_, err = d.GetDeviceByToken("tok")
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	_, err = d.GetDeviceByRefreshToken(dev.ID, "ref2")
+	require.NoError(t, err)
+
+	require.NoError(t, d.TouchDevice(dev.ID, "10.0.0.1"))
+	require.NoError(t, d.RevokeDevice(dev.ID))
+
+	devices, err := d.ListDevices()
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.True(t, devices[0].Revoked)
+	assert.Equal(t, "10.0.0.1", devices[0].LastIP)
+
+	assert.ErrorIs(t, d.RevokeDevice("missing"), db.ErrNotFound)
+
+	require.NoError(t, d.RenameDevice(dev.ID, "work-laptop"))
+	got, err = d.GetDeviceByToken("tok2")
+	require.NoError(t, err)
+	assert.Equal(t, "work-laptop", got.Name)
+	assert.ErrorIs(t, d.RenameDevice("missing", "x"), db.ErrNotFound)
+}
+
+func TestListUsers(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	users, err := d.ListUsers()
+	require.NoError(t, err)
+	assert.Empty(t, users)
+
+	adminID, err := d.EnsureUser("admin", "hash")
+	require.NoError(t, err)
+	_, err = d.EnsureUser("ops", "hash")
+	require.NoError(t, err)
+	_, err = d.CreateDevice(adminID, "laptop", "tok1", "ref1")
+	require.NoError(t, err)
+	_, err = d.CreateDevice(adminID, "desktop", "tok2", "ref2")
+	require.NoError(t, err)
+
+	users, err = d.ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	devices := map[string]int{}
+	for _, u := range users {
+		devices[u.Username] = u.Devices
+	}
+	assert.Equal(t, map[string]int{"admin": 2, "ops": 0}, devices)
+}
+
+func TestUsage(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	userID, err := d.EnsureUser("admin", "hash")
+	require.NoError(t, err)
+	laptop, err := d.CreateDevice(userID, "laptop", "tok1", "ref1")
+	require.NoError(t, err)
+	desktop, err := d.CreateDevice(userID, "desktop", "tok2", "ref2")
+	require.NoError(t, err)
+
+	for _, dev := range []*db.Device{laptop, laptop, desktop} {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "12345678"})
+		require.NoError(t, err)
+		require.NoError(t, d.SetOriginDevice(n.ID, dev.ID))
+	}
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "local"})
+	require.NoError(t, err)
+
+	u, err := d.DeviceUsage(laptop.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &db.Usage{Nodes: 2, Tokens: 4}, u)
+
+	u, err = d.UserUsage(userID)
+	require.NoError(t, err)
+	assert.Equal(t, &db.Usage{Nodes: 3, Tokens: 6}, u)
+
+	assert.ErrorIs(t, d.SetOriginDevice("missing", laptop.ID), db.ErrNotFound)
+}
+
+func TestUpsertRepoMapping(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	m1, err := d.UpsertRepoMapping("github.com/zate/ctx", "ctx")
+	require.NoError(t, err)
+	m2, err := d.UpsertRepoMapping("github.com/zate/ctx", "memdown")
+	require.NoError(t, err)
+	assert.Equal(t, m1.ID, m2.ID)
+	assert.Equal(t, "memdown", m2.ProjectTag)
+
+	mappings, err := d.ListRepoMappings()
+	require.NoError(t, err)
+	require.Len(t, mappings, 1)
+	assert.Equal(t, "memdown", mappings[0].ProjectTag)
+}
+
+func TestStats(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "one", Tags: []string{"x"}})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "two", Tags: []string{"x", "y"}})
+	_, err := d.CreateEdge(a.ID, b.ID, "RELATES_TO")
+	require.NoError(t, err)
+
+	st, err := d.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 2, st.TotalNodes)
+	assert.Equal(t, a.TokenEstimate+b.TokenEstimate, st.TotalTokens)
+	assert.Equal(t, 1, st.TotalEdges)
+	assert.Equal(t, 2, st.UniqueTags)
+	assert.Equal(t, 0, st.Devices)
+}
+
+func TestSyncVersion(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "one"})
+
+	v, err := d.MaxSyncVersion()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), v)
+
+	require.NoError(t, d.BumpSyncVersion(node.ID))
+	require.NoError(t, d.BumpSyncVersion(node.ID))
+	v, err = d.MaxSyncVersion()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), v)
+
+	assert.ErrorIs(t, d.BumpSyncVersion("missing"), db.ErrNotFound)
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+func (d *SQLiteStore) CreateSplit(stubID string, stub CreateNodeInput, chunks []CreateNodeInput) (*Node, error) {
+	return createSplit(d, stubID, stub, chunks)
+}
+
+func (d *PostgresStore) CreateSplit(stubID string, stub CreateNodeInput, chunks []CreateNodeInput) (*Node, error) {
+	return createSplit(d, stubID, stub, chunks)
+}
+
+func (d *SQLiteStore) FindByMetadata(nodeType, key, value string) (*Node, error) {
+	return findByMetadata(d, nodeType, key, value)
+}
+
+func (d *PostgresStore) FindByMetadata(nodeType, key, value string) (*Node, error) {
+	return findByMetadata(d, nodeType, key, value)
+}
+
+// createSplit implements CreateSplit for both stores: every node is
+// validated as CreateNode would before the transaction starts.
+func createSplit(d Store, stubID string, stub CreateNodeInput, chunks []CreateNodeInput) (*Node, error) {
+	parent, err := newNode(d, stubID, stub)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]*Node, len(chunks))
+	for i, c := range chunks {
+		if children[i], err = newNode(d, NewID(), c); err != nil {
+			return nil, fmt.Errorf("invalid chunk %d: %w", i, err)
+		}
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertNode(d, tx, parent); err != nil {
+		return nil, err
+	}
+	link := d.Rebind(`INSERT INTO edges (id, from_id, to_id, type, created_at, metadata)
+		VALUES (?, ?, ?, 'CHILD_OF', ?, '{}')`)
+	nowStr := parent.CreatedAt.Format(time.RFC3339)
+	for i, child := range children {
+		if err := insertNode(d, tx, child); err != nil {
+			return nil, fmt.Errorf("failed to create chunk %d: %w", i, err)
+		}
+		if _, err := tx.Exec(link, NewID(), child.ID, parent.ID, nowStr); err != nil {
+			return nil, fmt.Errorf("failed to link chunk %d: %w", i, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return parent, nil
+}
+
+// findByMetadata implements FindByMetadata for both stores. The rows are
+// narrowed with LIKE and their metadata decoded here rather than with the
+// database's JSON functions, which fail on the first malformed row.
+func findByMetadata(d Store, nodeType, key, value string) (*Node, error) {
+	want, _ := json.Marshal(value)
+	rows, err := d.Query(`SELECT id, metadata FROM nodes
+		WHERE type = ? AND superseded_by IS NULL AND metadata LIKE ? ESCAPE '\'
+		ORDER BY id`, nodeType, "%"+likeEscaper.Replace(string(want))+"%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find node: %w", err)
+	}
+	var id string
+	for rows.Next() {
+		var candidate, metadata string
+		if err := rows.Scan(&candidate, &metadata); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan node: %w", err)
+		}
+		var fields map[string]any
+		if json.Unmarshal([]byte(metadata), &fields) == nil && fields[key] == value {
+			id = candidate
+			break
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find node: %w", err)
+	}
+	if id == "" {
+		return nil, nil
+	}
+	return d.GetNode(id)
+}
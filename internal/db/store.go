@@ -1,6 +1,12 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/zate/ctx/internal/validate"
+)
 
 // Store is the interface for all database operations. Both SQLite (local) and
 // PostgreSQL (remote server) backends implement this interface.
@@ -8,6 +14,13 @@ type Store interface {
 	// Close closes the database connection.
 	Close() error
 
+	// WithContext returns the store with every operation bound to ctx, so
+	// that its deadline or cancellation interrupts them: HTTP and MCP
+	// handlers bind each request's context. No method takes a context of
+	// its own; bind one with WithContext instead. The store returned shares the
+	// connections, so closing either closes both.
+	WithContext(ctx context.Context) Store
+
 	// --- Node operations ---
 
 	CreateNode(input CreateNodeInput) (*Node, error)
@@ -16,8 +29,29 @@ type Store interface {
 	DeleteNode(id string) error
 	ListNodes(opts ListOptions) ([]*Node, error)
 	Search(query string) ([]*Node, error)
+	// SearchPrefix is a ranked full-text search for search-as-you-type:
+	// every term must match, the last one as a prefix. Only letters and
+	// digits of the terms are used. Superseded nodes are skipped, at most
+	// limit are returned, and tags are not loaded.
+	SearchPrefix(terms []string, limit int) ([]*Node, error)
 	ResolveID(prefix string) (string, error)
 	FindByTypeAndContent(nodeType, content string) (*Node, error)
+	// CreateSplit creates stub under stubID, from NewID, and each of chunks
+	// CHILD_OF it in one transaction, so the chunks' metadata can name the
+	// stub and a failure part-way stores none of them.
+	CreateSplit(stubID string, stub CreateNodeInput, chunks []CreateNodeInput) (*Node, error)
+	// FindByMetadata returns an active node of nodeType whose metadata sets
+	// the top-level key to the string value, or nil if there is none. Nodes
+	// with malformed metadata are skipped.
+	FindByMetadata(nodeType, key, value string) (*Node, error)
+	// Summarize creates a node DERIVED_FROM each of sourceIDs and, when
+	// archive is set, moves the sources to tier:off-context, all in one
+	// transaction: if any step fails, nothing is stored or archived.
+	Summarize(input CreateNodeInput, sourceIDs []string, archive bool) (*Node, error)
+	// Supersede marks oldID as superseded by newID and adds a SUPERSEDES
+	// edge from newID to it, copying oldID's tags to newID as stored when
+	// copyTags is set, all in one transaction.
+	Supersede(oldID, newID string, copyTags bool) error
 
 	// --- Edge operations ---
 
@@ -31,24 +65,136 @@ type Store interface {
 
 	AddTag(nodeID, tag string) error
 	RemoveTag(nodeID, tag string) error
+	// AddTags, RemoveTags, UpdateTags and SetTags apply all changes in one
+	// transaction and return ErrNotFound if the node does not exist.
+	// UpdateTags removes before adding; SetTags replaces the full set.
+	AddTags(nodeID string, tags []string) error
+	RemoveTags(nodeID string, tags []string) error
+	UpdateTags(nodeID string, add, remove []string) error
+	SetTags(nodeID string, tags []string) error
 	GetTags(nodeID string) ([]string, error)
+	// GetTagsForNodes returns the tags of each of ids that has any, sorted,
+	// in one round trip per few hundred IDs rather than one per node.
+	GetTagsForNodes(ids []string) (map[string][]string, error)
 	ListAllTags() ([]string, error)
 	ListTagsByPrefix(prefix string) ([]string, error)
 	GetNodesByTag(tag string) ([]*Node, error)
 
+	// --- Type registry ---
+	// Node and edge types users registered besides the built-in ones.
+	// RegisterType validates the name and replaces the description of a
+	// type registered before; ListTypes lists the built-in types of kind
+	// ("node" or "edge", or both when empty) and then the registered ones.
+
+	RegisterType(kind, name, description string) (*TypeDef, error)
+	ListTypes(kind string) ([]*TypeDef, error)
+	// Types is the store's own registry of the types it accepts, for
+	// entry points to validate input with before it reaches the store.
+	Types() *validate.Registry
+
 	// --- Pending operations ---
 
 	SetPending(key, value string) error
 	GetPending(key string) (string, error)
 	DeletePending(key string) error
+	ListPending(prefix string) (map[string]string, error)
+
+	// --- Tool telemetry ---
+
+	RecordToolCall(tool string, elapsed time.Duration, failed bool) error
+	ListToolStats() ([]*ToolStat, error)
+
+	// --- Missed recalls ---
+	// Recall queries that returned nothing, most-missed first.
+
+	RecordMissedRecall(query string) error
+	ListMissedRecalls(limit int) ([]*MissedRecall, error)
+
+	// --- Hook runs ---
+	// One row per hook invocation, newest first; stats are per hook since
+	// a time.
+
+	RecordHookRun(run *HookRun) error
+	ListHookRuns(since time.Time, failedOnly bool, limit int) ([]*HookRun, error)
+	ListHookStats(since time.Time) ([]*HookStat, error)
+
+	// --- Embeddings ---
+	// Node vectors keyed by model. SemanticSearch embeds text with e and
+	// returns the k active nodes whose vectors are most similar to it.
+
+	UpsertEmbedding(e *Embedding) error
+	ListEmbeddings(model string) ([]*Embedding, error)
+	SemanticSearch(e Embedder, text string, k int) ([]*ScoredNode, error)
+
+	// --- Node usage analytics ---
+
+	RecordNodeUsage(kind string, nodeIDs []string) error
+	GetNodeUsage() (map[string]*NodeUsage, error)
+
+	// --- Node access ---
+	// Every time a node is returned by a recall, composed or shown.
+	// RecordAccess skips IDs of missing nodes; GetNodeAccess returns
+	// ErrNotFound for a node never read.
+
+	RecordAccess(nodeIDs []string) error
+	GetNodeAccess(nodeID string) (*NodeAccess, error)
+
+	// --- Node revisions ---
+
+	RecordRevision(node *Node) (*Revision, error)
+	ListRevisions(nodeID string) ([]*Revision, error)
+
+	// --- Attachments ---
+	// Files kept with a node, up to MaxAttachmentSize each, deleted with
+	// it. AddAttachment returns ErrNotFound for an unknown node and sniffs
+	// an empty media type; GetAttachment and DeleteAttachment return
+	// ErrNotFound for an unknown attachment.
+
+	AddAttachment(nodeID, name, mediaType string, data []byte) (*Attachment, error)
+	ListAttachments(nodeID string) ([]*Attachment, error)
+	GetAttachment(id string) (*Attachment, []byte, error)
+	DeleteAttachment(id string) error
+
+	// --- Server tables ---
+	// Users, devices, repo mappings and sync versions back ctx serve.
+	// Device lookups and updates return ErrNotFound for unknown devices.
+
+	EnsureUser(username, passwordHash string) (string, error)
+	CreateDevice(userID, name, tokenHash, refreshHash string) (*Device, error)
+	GetDeviceByToken(tokenHash string) (*Device, error)
+	GetDeviceByRefreshToken(id, refreshHash string) (*Device, error)
+	RotateDeviceTokens(id, tokenHash, refreshHash string) error
+	TouchDevice(id, ip string) error
+	ListDevices() ([]*Device, error)
+	RevokeDevice(id string) error
+	RenameDevice(id, name string) error
+	// SetDigestToken replaces the device's read-only digest feed token;
+	// an empty hash revokes it. GetDeviceByDigestToken looks a device up
+	// by that token, never by its access token.
+	SetDigestToken(id, tokenHash string) error
+	GetDeviceByDigestToken(tokenHash string) (*Device, error)
+	ListUsers() ([]*User, error)
+	SetOriginDevice(nodeID, deviceID string) error
+	DeviceUsage(deviceID string) (*Usage, error)
+	UserUsage(userID string) (*Usage, error)
+	// UserNodeIDs returns the IDs of the nodes created from the user's
+	// devices, superseded ones included.
+	UserNodeIDs(userID string) ([]string, error)
+	UpsertRepoMapping(normalizedURL, projectTag string) (*RepoMapping, error)
+	ListRepoMappings() ([]*RepoMapping, error)
+	Stats() (*Stats, error)
+	BumpSyncVersion(nodeID string) error
+	MaxSyncVersion() (int64, error)
 
 	// --- Raw SQL access ---
 	// These are used by consumers that build dynamic queries (query executor,
 	// status commands, import/export, view management). Both SQLite and PostgreSQL
 	// backends implement database/sql, so these work for both.
-	// TODO: Gradually replace raw SQL usage with high-level methods as we add
-	// PostgreSQL support, to handle dialect differences (placeholders, FTS, etc.)
+	// Exec, QueryRow and Query rebind placeholders to the backend's style,
+	// so queries may use either ? or $N. Statements run on a transaction from
+	// Begin must be passed through Rebind by the caller.
 
+	Rebind(query string) string
 	Exec(query string, args ...interface{}) (sql.Result, error)
 	QueryRow(query string, args ...interface{}) *sql.Row
 	Query(query string, args ...interface{}) (*sql.Rows, error)
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// prefixTerms keeps the letters and digits of each term, splitting on
+// anything else, so user input can't inject full-text query syntax.
+func prefixTerms(terms []string) []string {
+	var out []string
+	for _, t := range terms {
+		out = append(out, strings.FieldsFunc(t, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})...)
+	}
+	return out
+}
+
+// SearchPrefix matches every term with FTS5, the last one as a prefix.
+func (d *SQLiteStore) SearchPrefix(terms []string, limit int) ([]*Node, error) {
+	terms = prefixTerms(terms)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	terms[len(terms)-1] += "*"
+
+	rows, err := d.db.Query(`SELECT n.id, n.type, n.content, n.summary, n.token_estimate, n.superseded_by, n.created_at, n.updated_at, n.metadata
+		FROM nodes n
+		JOIN nodes_fts f ON n.rowid = f.rowid
+		WHERE nodes_fts MATCH ? AND n.superseded_by IS NULL
+		ORDER BY rank
+		LIMIT ?`, strings.Join(terms, " "), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	return scanSearchNodes(rows)
+}
+
+// SearchPrefix matches every term with a tsquery, the last one as a prefix.
+func (d *PostgresStore) SearchPrefix(terms []string, limit int) ([]*Node, error) {
+	terms = prefixTerms(terms)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	terms[len(terms)-1] += ":*"
+
+	rows, err := d.db.Query(`SELECT n.id, n.type, n.content, n.summary, n.token_estimate, n.superseded_by, n.created_at, n.updated_at, n.metadata
+		FROM nodes n
+		WHERE n.search_vector @@ to_tsquery('english', $1) AND n.superseded_by IS NULL
+		ORDER BY ts_rank(n.search_vector, to_tsquery('english', $1)) DESC
+		LIMIT $2`, strings.Join(terms, " & "), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+	defer rows.Close()
+	return scanSearchNodes(rows)
+}
+
+// scanSearchNodes reads node rows without their tags.
+func scanSearchNodes(rows *sql.Rows) ([]*Node, error) {
+	var nodes []*Node
+	for rows.Next() {
+		node := &Node{}
+		var summary, supersededBy sql.NullString
+		var createdAt, updatedAt string
+		if err := rows.Scan(&node.ID, &node.Type, &node.Content, &summary, &node.TokenEstimate,
+			&supersededBy, &createdAt, &updatedAt, &node.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan node: %w", err)
+		}
+		if summary.Valid {
+			node.Summary = &summary.String
+		}
+		if supersededBy.Valid {
+			node.SupersededBy = &supersededBy.String
+		}
+		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
+		nodes = append(nodes, node)
+	}
+	return nodes, rows.Err()
+}
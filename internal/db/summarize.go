@@ -0,0 +1,74 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// archivedFrom are the tiers Summarize takes archived sources out of.
+var archivedFrom = []string{"tier:working", "tier:reference", "tier:pinned"}
+
+func (d *SQLiteStore) Summarize(input CreateNodeInput, sourceIDs []string, archive bool) (*Node, error) {
+	return summarize(d, input, sourceIDs, archive)
+}
+
+func (d *PostgresStore) Summarize(input CreateNodeInput, sourceIDs []string, archive bool) (*Node, error) {
+	return summarize(d, input, sourceIDs, archive)
+}
+
+// summarize implements Summarize for both stores, in one transaction: the
+// node is created as CreateNode would, linked DERIVED_FROM each source,
+// and the sources moved to tier:off-context when archive is set.
+func summarize(d Store, input CreateNodeInput, sourceIDs []string, archive bool) (*Node, error) {
+	node, err := newNode(d, NewID(), input)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertNode(d, tx, node); err != nil {
+		return nil, err
+	}
+	nowStr := node.CreatedAt.Format(time.RFC3339)
+	addTag := d.Rebind(`INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`)
+
+	exists := d.Rebind(`SELECT 1 FROM nodes WHERE id = ?`)
+	link := d.Rebind(`INSERT INTO edges (id, from_id, to_id, type, created_at, metadata)
+		VALUES (?, ?, ?, 'DERIVED_FROM', ?, '{}') ON CONFLICT DO NOTHING`)
+	removeTag := d.Rebind(`DELETE FROM tags WHERE node_id = ? AND tag = ?`)
+	for _, id := range sourceIDs {
+		var one int
+		if err := tx.QueryRow(exists, id).Scan(&one); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("source %s: %w", id, ErrNotFound)
+			}
+			return nil, fmt.Errorf("failed to check source %s: %w", id, err)
+		}
+		if _, err := tx.Exec(link, NewID(), node.ID, id, nowStr); err != nil {
+			return nil, fmt.Errorf("failed to link summary to %s: %w", id, err)
+		}
+		if !archive {
+			continue
+		}
+		for _, tag := range archivedFrom {
+			if _, err := tx.Exec(removeTag, id, tag); err != nil {
+				return nil, fmt.Errorf("failed to archive %s: %w", id, err)
+			}
+		}
+		if _, err := tx.Exec(addTag, id, "tier:off-context", nowStr); err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", id, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return node, nil
+}
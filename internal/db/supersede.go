@@ -0,0 +1,58 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+func (d *SQLiteStore) Supersede(oldID, newID string, copyTags bool) error {
+	return supersede(d, oldID, newID, copyTags)
+}
+
+func (d *PostgresStore) Supersede(oldID, newID string, copyTags bool) error {
+	return supersede(d, oldID, newID, copyTags)
+}
+
+// supersede implements Supersede for both stores. Copied tags are inserted
+// directly rather than through AddTag, so tags stored before the tag
+// grammar was enforced carry across unchanged.
+func supersede(d Store, oldID, newID string, copyTags bool) error {
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var count int
+	if err := tx.QueryRow(d.Rebind("SELECT COUNT(*) FROM nodes WHERE id = ?"), newID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to supersede %s: %w", oldID, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("new node %s not found", newID)
+	}
+	res, err := tx.Exec(d.Rebind("UPDATE nodes SET superseded_by = ? WHERE id = ?"), newID, oldID)
+	if err != nil {
+		return fmt.Errorf("failed to supersede %s: %w", oldID, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+
+	nowStr := time.Now().UTC().Format(time.RFC3339)
+	if _, err := tx.Exec(d.Rebind(`INSERT INTO edges (id, from_id, to_id, type, created_at, metadata)
+		VALUES (?, ?, ?, 'SUPERSEDES', ?, '{}') ON CONFLICT DO NOTHING`), NewID(), newID, oldID, nowStr); err != nil {
+		return fmt.Errorf("failed to create SUPERSEDES edge: %w", err)
+	}
+	if copyTags {
+		if _, err := tx.Exec(d.Rebind(`INSERT INTO tags (node_id, tag, created_at)
+			SELECT CAST(? AS TEXT), tag, CAST(? AS TEXT) FROM tags WHERE node_id = ?
+			ON CONFLICT DO NOTHING`), newID, nowStr, oldID); err != nil {
+			return fmt.Errorf("failed to copy tags: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
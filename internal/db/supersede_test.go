@@ -0,0 +1,55 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestSupersede(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "v1", Tags: []string{"tier:reference"}})
+	require.NoError(t, err)
+	_, err = d.Exec("INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)", old.ID, "old tag", "2024-01-01T00:00:00Z")
+	require.NoError(t, err)
+	replacement, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "v2", Tags: []string{"tier:working"}})
+	require.NoError(t, err)
+
+	require.NoError(t, d.Supersede(old.ID, replacement.ID, true))
+
+	got, err := d.GetNode(old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.SupersededBy)
+	assert.Equal(t, replacement.ID, *got.SupersededBy)
+	edges, err := d.GetEdges(replacement.ID, "out")
+	require.NoError(t, err)
+	require.Len(t, edges, 1)
+	assert.Equal(t, "SUPERSEDES", edges[0].Type)
+	assert.Equal(t, old.ID, edges[0].ToID)
+
+	// Tags are copied as stored, legacy ones included
+	tags, err := d.GetTags(replacement.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"old tag", "tier:reference", "tier:working"}, tags)
+}
+
+func TestSupersede_MissingNodeChangesNothing(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "v1"})
+	require.NoError(t, err)
+
+	assert.Error(t, d.Supersede(old.ID, "01HV3K2M0000000000000000ZZ", true))
+	assert.ErrorIs(t, d.Supersede("01HV3K2M0000000000000000ZZ", old.ID, true), db.ErrNotFound)
+
+	got, err := d.GetNode(old.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got.SupersededBy)
+	edges, err := d.GetEdges(old.ID, "both")
+	require.NoError(t, err)
+	assert.Empty(t, edges)
+}
@@ -1,13 +1,152 @@
 package db
 
 import (
+	"database/sql"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/zate/ctx/internal/validate"
 )
 
+// tagSQL holds the dialect-specific statements used by updateTags.
+type tagSQL struct {
+	exists string // (node_id)
+	insert string // (node_id, tag, created_at), ignoring duplicates
+	remove string // (node_id, tag)
+	clear  string // (node_id)
+}
+
+var sqliteTagSQL = tagSQL{
+	exists: "SELECT 1 FROM nodes WHERE id = ?",
+	insert: "INSERT OR IGNORE INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)",
+	remove: "DELETE FROM tags WHERE node_id = ? AND tag = ?",
+	clear:  "DELETE FROM tags WHERE node_id = ?",
+}
+
+// cleanTags trims tags and drops duplicates, rejecting empty ones.
+// Removal uses it as is, so a tag stored before the grammar was enforced
+// can still be removed; additions go through newTags.
+func cleanTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, ErrEmptyTag
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// newTags cleans tags about to be stored and checks each new one against
+// the tag grammar (see validate.Tag), so a blank tag is ErrEmptyTag and a
+// tag with a newline, an unknown tier or more than validate.MaxTagLength
+// characters is a *validate.Error, whichever entry point it came through.
+// A tag some node in d already has is accepted as it is: tags stored
+// before the grammar was enforced can still be set and copied.
+func newTags(d Store, tags []string) ([]string, error) {
+	tags, err := cleanTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tags {
+		if err := validate.Tag(t); err != nil && !storedTag(d, t) {
+			return nil, err
+		}
+	}
+	return tags, nil
+}
+
+// newTag is newTags for a single tag.
+func newTag(d Store, tag string) (string, error) {
+	tags, err := newTags(d, []string{tag})
+	if err != nil {
+		return "", err
+	}
+	return tags[0], nil
+}
+
+// storedTag reports whether any node in d has tag.
+func storedTag(d Store, tag string) bool {
+	var one int
+	return d.QueryRow("SELECT 1 FROM tags WHERE tag = ? LIMIT 1", tag).Scan(&one) == nil
+}
+
+// updateTags changes a node's tags in one transaction: when replace is set
+// all existing tags are dropped first, then remove is applied, then add (so
+// a tag in both ends up present). It returns ErrNotFound if the node does
+// not exist and changes nothing if any step fails.
+func updateTags(d Store, q tagSQL, nodeID string, add, remove []string, replace bool) error {
+	add, err := newTags(d, add)
+	if err != nil {
+		return err
+	}
+	remove, err = cleanTags(remove)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var one int
+	if err := tx.QueryRow(q.exists, nodeID).Scan(&one); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to check node: %w", err)
+	}
+
+	if replace {
+		if _, err := tx.Exec(q.clear, nodeID); err != nil {
+			return fmt.Errorf("failed to clear tags: %w", err)
+		}
+	}
+	for _, tag := range remove {
+		if _, err := tx.Exec(q.remove, nodeID, tag); err != nil {
+			return fmt.Errorf("failed to remove tag %s: %w", tag, err)
+		}
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, tag := range add {
+		if _, err := tx.Exec(q.insert, nodeID, tag, now); err != nil {
+			return fmt.Errorf("failed to add tag %s: %w", tag, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *SQLiteStore) AddTags(nodeID string, tags []string) error {
+	return updateTags(d, sqliteTagSQL, nodeID, tags, nil, false)
+}
+
+func (d *SQLiteStore) RemoveTags(nodeID string, tags []string) error {
+	return updateTags(d, sqliteTagSQL, nodeID, nil, tags, false)
+}
+
+func (d *SQLiteStore) UpdateTags(nodeID string, add, remove []string) error {
+	return updateTags(d, sqliteTagSQL, nodeID, add, remove, false)
+}
+
+func (d *SQLiteStore) SetTags(nodeID string, tags []string) error {
+	return updateTags(d, sqliteTagSQL, nodeID, tags, nil, true)
+}
+
 func (d *SQLiteStore) AddTag(nodeID, tag string) error {
+	tag, err := newTag(d, tag)
+	if err != nil {
+		return err
+	}
 	now := time.Now().UTC().Format(time.RFC3339)
-	_, err := d.db.Exec(`INSERT OR IGNORE INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)`,
+	_, err = d.db.Exec(`INSERT OR IGNORE INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)`,
 		nodeID, tag, now)
 	if err != nil {
 		return fmt.Errorf("failed to add tag: %w", err)
@@ -41,6 +180,62 @@ func (d *SQLiteStore) GetTags(nodeID string) ([]string, error) {
 	return tags, nil
 }
 
+func (d *SQLiteStore) GetTagsForNodes(ids []string) (map[string][]string, error) {
+	return getTagsForNodes(d, ids)
+}
+
+// tagBatchSize caps the IDs bound in one GetTagsForNodes query, well under
+// SQLite's host parameter limit.
+const tagBatchSize = 500
+
+// getTagsForNodes implements GetTagsForNodes for both backends; Query
+// rebinds the placeholders for PostgreSQL.
+func getTagsForNodes(d Store, ids []string) (map[string][]string, error) {
+	tags := make(map[string][]string, len(ids))
+	for start := 0; start < len(ids); start += tagBatchSize {
+		batch := ids[start:min(start+tagBatchSize, len(ids))]
+		args := make([]interface{}, len(batch))
+		for i, id := range batch {
+			args[i] = id
+		}
+		rows, err := d.Query("SELECT node_id, tag FROM tags WHERE node_id IN (?"+
+			strings.Repeat(", ?", len(batch)-1)+") ORDER BY node_id, tag", args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+		for rows.Next() {
+			var nodeID, tag string
+			if err := rows.Scan(&nodeID, &tag); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan tag: %w", err)
+			}
+			tags[nodeID] = append(tags[nodeID], tag)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tags: %w", err)
+		}
+	}
+	return tags, nil
+}
+
+// attachTags sets the Tags of nodes from one GetTagsForNodes call.
+func attachTags(d Store, nodes []*Node) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	tags, err := d.GetTagsForNodes(ids)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		n.Tags = tags[n.ID]
+	}
+	return nil
+}
+
 func (d *SQLiteStore) ListAllTags() ([]string, error) {
 	rows, err := d.db.Query("SELECT DISTINCT tag FROM tags ORDER BY tag")
 	if err != nil {
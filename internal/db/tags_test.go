@@ -1,11 +1,14 @@
 package db_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/validate"
 	"github.com/zate/ctx/testutil"
 )
 
@@ -98,3 +101,157 @@ func TestTagCascadeDelete(t *testing.T) {
 	tags, _ := d.ListAllTags()
 	assert.Empty(t, tags)
 }
+
+func TestAddTags(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a"})
+
+	require.NoError(t, d.AddTags(node.ID, []string{"a", " b ", "a"}))
+
+	tags, _ := d.GetTags(node.ID)
+	assert.ElementsMatch(t, []string{"a", "b"}, tags)
+}
+
+func TestAddTags_MissingNode(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	err := d.AddTags("01NOSUCHNODE0000000000000", []string{"a"})
+	assert.ErrorIs(t, err, db.ErrNotFound)
+
+	tags, _ := d.ListAllTags()
+	assert.Empty(t, tags)
+}
+
+func TestAddTags_EmptyTagChangesNothing(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a"})
+
+	err := d.AddTags(node.ID, []string{"a", ""})
+	assert.ErrorIs(t, err, db.ErrEmptyTag)
+
+	tags, _ := d.GetTags(node.ID)
+	assert.Empty(t, tags)
+}
+
+func TestTags_Grammar(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"ok", "bad\ntag"}})
+	assert.ErrorContains(t, err, `invalid tag "bad\ntag"`)
+	nodes, _ := d.ListNodes(db.ListOptions{})
+	assert.Empty(t, nodes, "a bad tag stores nothing")
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{" padded ", "padded"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"padded"}, node.Tags)
+
+	for _, tag := range []string{strings.Repeat("x", validate.MaxTagLength+1), "tier:hot", "<script>"} {
+		var verr *validate.Error
+		assert.ErrorAs(t, d.AddTag(node.ID, tag), &verr, tag)
+		assert.ErrorAs(t, d.AddTags(node.ID, []string{tag}), &verr, tag)
+		assert.ErrorAs(t, d.SetTags(node.ID, []string{tag}), &verr, tag)
+	}
+	tags, _ := d.GetTags(node.ID)
+	assert.Equal(t, []string{"padded"}, tags)
+
+	// Tags stored before the grammar was enforced can still be removed
+	_, err = d.Exec("INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)", node.ID, "old tag", "2024-01-01T00:00:00Z")
+	require.NoError(t, err)
+	require.NoError(t, d.RemoveTags(node.ID, []string{"old tag"}))
+	tags, _ = d.GetTags(node.ID)
+	assert.Equal(t, []string{"padded"}, tags)
+}
+
+func TestTags_GrammarSparesStoredTags(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// A tag stored before the grammar was enforced
+	legacy, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "legacy"})
+	require.NoError(t, err)
+	_, err = d.Exec("INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?)", legacy.ID, "old tag", "2024-01-01T00:00:00Z")
+	require.NoError(t, err)
+
+	// Setting a legacy node's tags keeps it, and copying it elsewhere works
+	require.NoError(t, d.SetTags(legacy.ID, []string{"old tag", "tier:working"}))
+	tags, _ := d.GetTags(legacy.ID)
+	assert.Equal(t, []string{"old tag", "tier:working"}, tags)
+	other, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "copy", Tags: []string{"old tag"}})
+	require.NoError(t, err)
+	require.NoError(t, d.AddTag(other.ID, "old tag"))
+	assert.Equal(t, []string{"old tag"}, other.Tags)
+
+	// New tags still follow the grammar
+	var verr *validate.Error
+	assert.ErrorAs(t, d.AddTag(other.ID, "new tag"), &verr)
+}
+
+func TestRemoveTags(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"a", "b", "c"}})
+
+	require.NoError(t, d.RemoveTags(node.ID, []string{"a", "c", "missing"}))
+
+	tags, _ := d.GetTags(node.ID)
+	assert.Equal(t, []string{"b"}, tags)
+}
+
+func TestUpdateTags_MovesTier(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"tier:working", "project:x"}})
+
+	require.NoError(t, d.UpdateTags(node.ID, []string{"tier:reference"}, []string{"tier:working"}))
+
+	tags, _ := d.GetTags(node.ID)
+	assert.ElementsMatch(t, []string{"tier:reference", "project:x"}, tags)
+}
+
+func TestSetTags(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"a", "b"}})
+
+	require.NoError(t, d.SetTags(node.ID, []string{"b", "c"}))
+
+	tags, _ := d.GetTags(node.ID)
+	assert.ElementsMatch(t, []string{"b", "c"}, tags)
+
+	require.NoError(t, d.SetTags(node.ID, nil))
+	tags, _ = d.GetTags(node.ID)
+	assert.Empty(t, tags)
+}
+
+func TestGetTagsForNodes(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"z", "a"}})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "b", Tags: []string{"b"}})
+	untagged, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "c"})
+
+	tags, err := d.GetTagsForNodes([]string{a.ID, b.ID, untagged.ID, "01HV3K2M0000000000000MISSING"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{a.ID: {"a", "z"}, b.ID: {"b"}}, tags)
+
+	tags, err = d.GetTagsForNodes(nil)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func TestGetTagsForNodes_ManyIDs(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// More IDs than one query binds
+	var ids []string
+	for i := 0; i < 1200; i++ {
+		ids = append(ids, fmt.Sprintf("missing-%04d", i))
+	}
+	n, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "last", Tags: []string{"t"}})
+	ids = append(ids, n.ID)
+
+	tags, err := d.GetTagsForNodes(ids)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{n.ID: {"t"}}, tags)
+}
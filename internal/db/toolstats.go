@@ -0,0 +1,78 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ToolStat aggregates local usage of a single MCP tool.
+type ToolStat struct {
+	Tool         string    `json:"tool"`
+	Calls        int       `json:"calls"`
+	Errors       int       `json:"errors"`
+	TotalMs      int64     `json:"total_ms"`
+	MaxMs        int64     `json:"max_ms"`
+	LastCalledAt time.Time `json:"last_called_at"`
+}
+
+// AvgMs returns the mean latency per call in milliseconds.
+func (s *ToolStat) AvgMs() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.TotalMs) / float64(s.Calls)
+}
+
+// ErrorRate returns the fraction of calls that failed.
+func (s *ToolStat) ErrorRate() float64 {
+	if s.Calls == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.Calls)
+}
+
+func (d *SQLiteStore) RecordToolCall(tool string, elapsed time.Duration, failed bool) error {
+	ms := elapsed.Milliseconds()
+	errCount := 0
+	if failed {
+		errCount = 1
+	}
+	_, err := d.db.Exec(`INSERT INTO tool_stats (tool, calls, errors, total_ms, max_ms, last_called_at)
+		VALUES (?, 1, ?, ?, ?, ?)
+		ON CONFLICT (tool) DO UPDATE SET
+			calls = calls + 1,
+			errors = errors + excluded.errors,
+			total_ms = total_ms + excluded.total_ms,
+			max_ms = MAX(max_ms, excluded.max_ms),
+			last_called_at = excluded.last_called_at`,
+		tool, errCount, ms, ms, time.Now().UTC().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record tool call: %w", err)
+	}
+	return nil
+}
+
+func (d *SQLiteStore) ListToolStats() ([]*ToolStat, error) {
+	rows, err := d.db.Query(`SELECT tool, calls, errors, total_ms, max_ms, last_called_at
+		FROM tool_stats ORDER BY calls DESC, tool`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tool stats: %w", err)
+	}
+	defer rows.Close()
+	return scanToolStats(rows)
+}
+
+func scanToolStats(rows *sql.Rows) ([]*ToolStat, error) {
+	var stats []*ToolStat
+	for rows.Next() {
+		var s ToolStat
+		var lastCalled string
+		if err := rows.Scan(&s.Tool, &s.Calls, &s.Errors, &s.TotalMs, &s.MaxMs, &lastCalled); err != nil {
+			return nil, fmt.Errorf("failed to scan tool stat: %w", err)
+		}
+		s.LastCalledAt, _ = time.Parse(time.RFC3339, lastCalled)
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
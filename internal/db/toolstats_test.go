@@ -0,0 +1,32 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRecordToolCall_Aggregates(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	require.NoError(t, d.RecordToolCall("ctx_recall", 10*time.Millisecond, false))
+	require.NoError(t, d.RecordToolCall("ctx_recall", 30*time.Millisecond, true))
+	require.NoError(t, d.RecordToolCall("ctx_show", 5*time.Millisecond, false))
+
+	stats, err := d.ListToolStats()
+	require.NoError(t, err)
+	require.Len(t, stats, 2)
+
+	recall := stats[0]
+	assert.Equal(t, "ctx_recall", recall.Tool)
+	assert.Equal(t, 2, recall.Calls)
+	assert.Equal(t, 1, recall.Errors)
+	assert.Equal(t, int64(40), recall.TotalMs)
+	assert.Equal(t, int64(30), recall.MaxMs)
+	assert.InDelta(t, 20.0, recall.AvgMs(), 0.001)
+	assert.InDelta(t, 0.5, recall.ErrorRate(), 0.001)
+	assert.False(t, recall.LastCalledAt.IsZero())
+}
@@ -0,0 +1,165 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zate/ctx/internal/validate"
+)
+
+// Kinds of type in the type registry.
+const (
+	KindNode = "node"
+	KindEdge = "edge"
+)
+
+// TypeDef is a node or edge type: one of the built-in types, or one a user
+// registered with a description of what it is for.
+type TypeDef struct {
+	Kind        string    `json:"kind"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Builtin     bool      `json:"builtin"`
+	CreatedAt   time.Time `json:"created_at,omitzero"`
+}
+
+// BuiltinTypes returns the built-in node types, then the edge types.
+func BuiltinTypes() []*TypeDef {
+	var out []*TypeDef
+	for _, name := range validate.NodeTypes {
+		out = append(out, &TypeDef{Kind: KindNode, Name: name, Builtin: true})
+	}
+	for _, name := range validate.EdgeTypes {
+		out = append(out, &TypeDef{Kind: KindEdge, Name: name, Builtin: true})
+	}
+	return out
+}
+
+func (d *SQLiteStore) RegisterType(kind, name, description string) (*TypeDef, error) {
+	return registerType(d, kind, name, description)
+}
+
+func (d *SQLiteStore) ListTypes(kind string) ([]*TypeDef, error) {
+	return listTypes(d, kind)
+}
+
+func (d *PostgresStore) RegisterType(kind, name, description string) (*TypeDef, error) {
+	return registerType(d, kind, name, description)
+}
+
+func (d *PostgresStore) ListTypes(kind string) ([]*TypeDef, error) {
+	return listTypes(d, kind)
+}
+
+// registerType implements RegisterType for both stores. Registering a type
+// again replaces its description.
+func registerType(d Store, kind, name, description string) (*TypeDef, error) {
+	var err error
+	switch kind {
+	case KindNode:
+		err = validate.NodeTypeName(name)
+		if err == nil && validNodeTypes[name] {
+			err = fmt.Errorf("%s is a built-in node type", name)
+		}
+	case KindEdge:
+		err = validate.EdgeTypeName(name)
+		if err == nil && validEdgeTypes[name] {
+			err = fmt.Errorf("%s is a built-in edge type", name)
+		}
+	default:
+		err = fmt.Errorf("unknown type kind %q (use %s or %s)", kind, KindNode, KindEdge)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	_, err = d.Exec(`INSERT INTO type_registry (kind, name, description, created_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (kind, name) DO UPDATE SET description = excluded.description`,
+		kind, name, description, now.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register %s type: %w", kind, err)
+	}
+	addValidTypes(d, kind, name)
+
+	def := &TypeDef{Kind: kind, Name: name, Description: description}
+	var createdAt string
+	if err := d.QueryRow("SELECT created_at FROM type_registry WHERE kind = ? AND name = ?", kind, name).Scan(&createdAt); err != nil {
+		return nil, fmt.Errorf("failed to read %s type: %w", kind, err)
+	}
+	def.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+	return def, nil
+}
+
+// listTypes implements ListTypes for both stores: the built-in types of
+// kind, or of both kinds when it is empty, then the registered ones by
+// name.
+func listTypes(d Store, kind string) ([]*TypeDef, error) {
+	var out []*TypeDef
+	for _, t := range BuiltinTypes() {
+		if kind == "" || t.Kind == kind {
+			out = append(out, t)
+		}
+	}
+
+	rows, err := d.Query(`SELECT kind, name, description, created_at FROM type_registry
+		WHERE ? = '' OR kind = ? ORDER BY kind DESC, name`, kind, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list types: %w", err)
+	}
+	defer rows.Close()
+	var custom []*TypeDef
+	for rows.Next() {
+		t := &TypeDef{}
+		var createdAt string
+		if err := rows.Scan(&t.Kind, &t.Name, &t.Description, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan type: %w", err)
+		}
+		t.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		custom = append(custom, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list types: %w", err)
+	}
+
+	// Built-in node types come before edge types, and so do custom ones
+	return append(out, custom...), nil
+}
+
+// loadTypes adds the types in d's registry table to d.Types(), which the
+// entry points check types against.
+func loadTypes(d Store) error {
+	types, err := listTypes(d, "")
+	if err != nil {
+		return err
+	}
+	for _, t := range types {
+		if !t.Builtin {
+			addValidTypes(d, t.Kind, t.Name)
+		}
+	}
+	return nil
+}
+
+func addValidTypes(d Store, kind, name string) {
+	if kind == KindNode {
+		d.Types().AddNodeTypes(name)
+	} else {
+		d.Types().AddEdgeTypes(name)
+	}
+}
+
+// knownType reports whether name is a built-in type of kind or one
+// registered in d, perhaps by another process since this one started.
+func knownType(d Store, kind, name string) bool {
+	if (kind == KindNode && validNodeTypes[name]) || (kind == KindEdge && validEdgeTypes[name]) {
+		return true
+	}
+	var one int
+	if d.QueryRow("SELECT 1 FROM type_registry WHERE kind = ? AND name = ?", kind, name).Scan(&one) != nil {
+		return false
+	}
+	addValidTypes(d, kind, name)
+	return true
+}
@@ -0,0 +1,98 @@
+package db_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/validate"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRegisterType(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "incident", Content: "The database went down"})
+	assert.ErrorContains(t, err, "invalid node type")
+
+	def, err := d.RegisterType(db.KindNode, "incident", "An outage and its fix")
+	require.NoError(t, err)
+	assert.Equal(t, "incident", def.Name)
+	assert.False(t, def.CreatedAt.IsZero())
+	assert.NoError(t, d.Types().NodeType("incident"), "entry points accept it too")
+	other := testutil.SetupTestDB(t)
+	assert.Error(t, other.Types().NodeType("incident"), "but not those of another store")
+	_, err = other.CreateNode(db.CreateNodeInput{Type: "incident", Content: "Elsewhere"})
+	assert.ErrorContains(t, err, "invalid node type")
+
+	incident, err := d.CreateNode(db.CreateNodeInput{Type: "incident", Content: "The database went down"})
+	require.NoError(t, err)
+	cause, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The disk filled up"})
+	require.NoError(t, err)
+	_, err = d.CreateEdge(incident.ID, cause.ID, "CAUSED_BY")
+	assert.ErrorContains(t, err, "invalid edge type")
+	_, err = d.RegisterType(db.KindEdge, "CAUSED_BY", "")
+	require.NoError(t, err)
+	_, err = d.CreateEdge(incident.ID, cause.ID, "CAUSED_BY")
+	require.NoError(t, err)
+
+	// Registering again replaces the description
+	_, err = d.RegisterType(db.KindNode, "incident", "An outage: impact and fix")
+	require.NoError(t, err)
+	types, err := d.ListTypes(db.KindNode)
+	require.NoError(t, err)
+	require.Len(t, types, len(validate.NodeTypes)+1)
+	assert.True(t, types[0].Builtin)
+	last := types[len(types)-1]
+	assert.Equal(t, "incident", last.Name)
+	assert.Equal(t, "An outage: impact and fix", last.Description)
+	assert.False(t, last.Builtin)
+
+	all, err := d.ListTypes("")
+	require.NoError(t, err)
+	assert.Len(t, all, len(validate.NodeTypes)+len(validate.EdgeTypes)+2)
+}
+
+func TestRegisterType_Invalid(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	for _, tc := range []struct{ kind, name, want string }{
+		{db.KindNode, "fact", "fact is a built-in node type"},
+		{db.KindEdge, "DEPENDS_ON", "DEPENDS_ON is a built-in edge type"},
+		{db.KindNode, "Incident", "must start with a letter a-z"},
+		{db.KindEdge, "caused_by", "must start with a letter A-Z"},
+		{"tag", "x", `unknown type kind "tag"`},
+	} {
+		_, err := d.RegisterType(tc.kind, tc.name, "")
+		assert.ErrorContains(t, err, tc.want, tc.name)
+	}
+	types, err := d.ListTypes("")
+	require.NoError(t, err)
+	assert.Len(t, types, len(validate.NodeTypes)+len(validate.EdgeTypes))
+}
+
+func TestOpen_LoadsRegisteredTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	d, err := db.Open(path)
+	require.NoError(t, err)
+	// Stands in for types registered by another process
+	_, err = d.Exec(`INSERT INTO type_registry (kind, name, created_at) VALUES
+		('node', 'loaded-on-open', '2025-01-01T00:00:00Z'),
+		('node', 'loaded-on-use', '2025-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+	assert.Error(t, d.Types().NodeType("loaded-on-open"))
+
+	// A running process learns of them when it first uses one...
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "loaded-on-use", Content: "Accepted without a restart"})
+	require.NoError(t, err)
+	assert.NoError(t, d.Types().NodeType("loaded-on-use"))
+	require.NoError(t, d.Close())
+
+	// ...and of all of them when it opens the store
+	d, err = db.Open(path)
+	require.NoError(t, err)
+	defer d.Close()
+	assert.NoError(t, d.Types().NodeType("loaded-on-open"))
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Node usage kinds recorded by RecordNodeUsage.
+const (
+	UsageInjected   = "injected"   // composed into a session's context
+	UsageReferenced = "referenced" // cited by ID in an assistant response
+	UsageRecalled   = "recalled"   // returned by a recall query
+)
+
+// NodeUsage counts the sessions in which a node was injected or used.
+type NodeUsage struct {
+	NodeID     string     `json:"node_id"`
+	Injected   int        `json:"injected"`
+	Referenced int        `json:"referenced"`
+	Recalled   int        `json:"recalled"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Used reports whether the node was ever referenced or recalled.
+func (u *NodeUsage) Used() bool {
+	return u.Referenced > 0 || u.Recalled > 0
+}
+
+// usageColumn maps a usage kind to its counter column. Returning the column
+// from a fixed set keeps kind out of the SQL text.
+func usageColumn(kind string) (string, error) {
+	switch kind {
+	case UsageInjected:
+		return "injected", nil
+	case UsageReferenced:
+		return "referenced", nil
+	case UsageRecalled:
+		return "recalled", nil
+	default:
+		return "", fmt.Errorf("invalid usage kind: %s", kind)
+	}
+}
+
+func (d *SQLiteStore) RecordNodeUsage(kind string, nodeIDs []string) error {
+	col, err := usageColumn(kind)
+	if err != nil {
+		return err
+	}
+
+	var lastUsed interface{}
+	if kind != UsageInjected {
+		lastUsed = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt := fmt.Sprintf(`INSERT INTO node_usage (node_id, %[1]s, last_used_at) VALUES (?, 1, ?)
+		ON CONFLICT (node_id) DO UPDATE SET
+			%[1]s = %[1]s + 1,
+			last_used_at = COALESCE(excluded.last_used_at, last_used_at)`, col)
+	for _, id := range nodeIDs {
+		if _, err := tx.Exec(stmt, id, lastUsed); err != nil {
+			return fmt.Errorf("failed to record node usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (d *SQLiteStore) GetNodeUsage() (map[string]*NodeUsage, error) {
+	rows, err := d.db.Query("SELECT node_id, injected, referenced, recalled, last_used_at FROM node_usage")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node usage: %w", err)
+	}
+	defer rows.Close()
+	return scanNodeUsage(rows)
+}
+
+func scanNodeUsage(rows *sql.Rows) (map[string]*NodeUsage, error) {
+	out := make(map[string]*NodeUsage)
+	for rows.Next() {
+		var u NodeUsage
+		var lastUsed sql.NullString
+		if err := rows.Scan(&u.NodeID, &u.Injected, &u.Referenced, &u.Recalled, &lastUsed); err != nil {
+			return nil, fmt.Errorf("failed to scan node usage: %w", err)
+		}
+		if lastUsed.Valid {
+			if t, err := time.Parse(time.RFC3339, lastUsed.String); err == nil {
+				u.LastUsedAt = &t
+			}
+		}
+		out[u.NodeID] = &u
+	}
+	return out, rows.Err()
+}
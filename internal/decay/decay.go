@@ -0,0 +1,125 @@
+// Package decay demotes knowledge nobody has touched in a while, one tier
+// at a time: working notes left behind by finished tasks drop to
+// tier:reference, and reference nodes that are never recalled drop to
+// tier:off-context, so the tiers keep meaning what they say without
+// anyone pruning them by hand.
+package decay
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+)
+
+// Rule demotes nodes in tier From untouched for After to tier To.
+type Rule struct {
+	From  string        `json:"from"`
+	To    string        `json:"to"`
+	After time.Duration `json:"after"`
+}
+
+// Rules returns the rules configured under decay, leaving out disabled
+// ones.
+func Rules(cfg config.Decay) []Rule {
+	var rules []Rule
+	if cfg.WorkingAfter > 0 {
+		rules = append(rules, Rule{From: "working", To: "reference", After: cfg.WorkingAfter})
+	}
+	if cfg.ReferenceAfter > 0 {
+		rules = append(rules, Rule{From: "reference", To: "off-context", After: cfg.ReferenceAfter})
+	}
+	return rules
+}
+
+// Options controls Run.
+type Options struct {
+	Rules  []Rule
+	Agent  string    // if set, only demote this agent's and global nodes
+	Keep   []string  // IDs never demoted, such as the current task's pins
+	DryRun bool      // report what would be demoted without changing anything
+	Now    time.Time // zero means time.Now()
+}
+
+// Demotion is a node Run demoted, or would demote in a dry run.
+type Demotion struct {
+	Node        *db.Node  `json:"node"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	LastTouched time.Time `json:"last_touched"`
+}
+
+// Report lists the demotions of one Run, longest untouched first.
+type Report struct {
+	DryRun    bool        `json:"dry_run"`
+	Demotions []*Demotion `json:"demotions"`
+}
+
+// Run demotes the nodes each rule matches. A node counts as touched when
+// it is updated, used in a session or read (see db.NodeAccess). Nodes are
+// picked before any is demoted, so one run moves a node down one tier at
+// most.
+func Run(d db.Store, opts Options) (*Report, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report := &Report{DryRun: opts.DryRun, Demotions: []*Demotion{}}
+	if len(opts.Rules) == 0 {
+		return report, nil
+	}
+
+	usage, err := d.GetNodeUsage()
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range opts.Rules {
+		nodes, err := d.GetNodesByTag("tier:" + rule.From)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tier:%s nodes: %w", rule.From, err)
+		}
+		for _, n := range agentpkg.FilterNodes(nodes, opts.Agent) {
+			if slices.Contains(opts.Keep, n.ID) {
+				continue
+			}
+			touched := n.UpdatedAt
+			if u := usage[n.ID]; u != nil && u.LastUsedAt != nil && u.LastUsedAt.After(touched) {
+				touched = *u.LastUsedAt
+			}
+			if now.Sub(touched) < rule.After {
+				continue
+			}
+			// Reads are checked last, only for nodes idle by every other
+			// measure
+			access, err := d.GetNodeAccess(n.ID)
+			if err != nil && !errors.Is(err, db.ErrNotFound) {
+				return nil, err
+			}
+			if access != nil && access.LastAccessedAt.After(touched) {
+				touched = access.LastAccessedAt
+				if now.Sub(touched) < rule.After {
+					continue
+				}
+			}
+			report.Demotions = append(report.Demotions, &Demotion{Node: n, From: rule.From, To: rule.To, LastTouched: touched})
+		}
+	}
+	sort.SliceStable(report.Demotions, func(i, j int) bool {
+		return report.Demotions[i].LastTouched.Before(report.Demotions[j].LastTouched)
+	})
+
+	if opts.DryRun {
+		return report, nil
+	}
+	for _, dm := range report.Demotions {
+		if err := d.UpdateTags(dm.Node.ID, []string{"tier:" + dm.To}, []string{"tier:" + dm.From}); err != nil {
+			return nil, fmt.Errorf("failed to demote %s: %w", dm.Node.ID, err)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,82 @@
+package decay_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/decay"
+	"github.com/zate/ctx/testutil"
+)
+
+func createNode(t *testing.T, d db.Store, content string, idle time.Duration, tags ...string) *db.Node {
+	t.Helper()
+	n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: content, Tags: tags})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET updated_at = ? WHERE id = ?", time.Now().Add(-idle).UTC().Format(time.RFC3339), n.ID)
+	require.NoError(t, err)
+	return n
+}
+
+func tiers(t *testing.T, d db.Store, id string) []string {
+	t.Helper()
+	n, err := d.GetNode(id)
+	require.NoError(t, err)
+	var out []string
+	for _, tag := range n.Tags {
+		if strings.HasPrefix(tag, "tier:") {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	day := 24 * time.Hour
+
+	oldWorking := createNode(t, d, "old working note", 20*day, "tier:working")
+	freshWorking := createNode(t, d, "fresh working note", 2*day, "tier:working")
+	readWorking := createNode(t, d, "old but recently read", 20*day, "tier:working")
+	pinnedToTask := createNode(t, d, "pinned to the task", 20*day, "tier:working")
+	oldRef := createNode(t, d, "old reference", 200*day, "tier:reference")
+	otherAgent := createNode(t, d, "another agent's note", 20*day, "tier:working", "agent:other")
+	pinned := createNode(t, d, "pinned forever", 400*day, "tier:pinned")
+	require.NoError(t, d.RecordAccess([]string{readWorking.ID}))
+
+	opts := decay.Options{
+		Rules:  decay.Rules(config.Defaults().Decay),
+		Agent:  "me",
+		Keep:   []string{pinnedToTask.ID},
+		DryRun: true,
+	}
+	report, err := decay.Run(d, opts)
+	require.NoError(t, err)
+	require.Len(t, report.Demotions, 2)
+	assert.Equal(t, oldRef.ID, report.Demotions[0].Node.ID, "longest idle first")
+	assert.Equal(t, "off-context", report.Demotions[0].To)
+	assert.Equal(t, oldWorking.ID, report.Demotions[1].Node.ID)
+	assert.Equal(t, "reference", report.Demotions[1].To)
+	assert.Equal(t, []string{"tier:working"}, tiers(t, d, oldWorking.ID), "a dry run changes nothing")
+
+	opts.DryRun = false
+	report, err = decay.Run(d, opts)
+	require.NoError(t, err)
+	require.Len(t, report.Demotions, 2)
+	assert.Equal(t, []string{"tier:reference"}, tiers(t, d, oldWorking.ID), "demoted one tier only")
+	assert.Equal(t, []string{"tier:off-context"}, tiers(t, d, oldRef.ID))
+	for _, n := range []*db.Node{freshWorking, readWorking, pinnedToTask, otherAgent} {
+		assert.Equal(t, []string{"tier:working"}, tiers(t, d, n.ID), n.Content)
+	}
+	assert.Equal(t, []string{"tier:pinned"}, tiers(t, d, pinned.ID))
+}
+
+func TestRules(t *testing.T) {
+	assert.Empty(t, decay.Rules(config.Decay{}))
+	assert.Equal(t, []decay.Rule{{From: "reference", To: "off-context", After: time.Hour}},
+		decay.Rules(config.Decay{ReferenceAfter: time.Hour}))
+}
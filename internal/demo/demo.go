@@ -0,0 +1,78 @@
+// Package demo seeds a store with example memory for ctx demo, so ctx can
+// be tried out with an MCP client without touching real memory. The
+// examples describe a made-up web shop and use every tier, most node
+// types and each kind of edge.
+package demo
+
+import (
+	"fmt"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// Project is the project tag the examples are scoped to.
+const Project = "acme-shop"
+
+type seedNode struct {
+	key     string // how edges refer to the node
+	typ     string
+	tier    string
+	content string
+	tags    []string
+}
+
+type seedEdge struct {
+	from, to, typ string
+}
+
+var nodes = []seedNode{
+	{"stack", "fact", "pinned", "acme-shop is a Go monolith (chi router, sqlc) serving a React storefront; PostgreSQL 16 is the only datastore.", nil},
+	{"money", "pattern", "pinned", "Store money as integer cents with an ISO 4217 currency code; never use floats for prices, totals or tax.", nil},
+	{"mysql", "decision", "reference", "Use MySQL for orders, since the team knows it best.", nil},
+	{"postgres", "decision", "pinned", "Use PostgreSQL for orders: we need transactional DDL for zero-downtime migrations and JSONB for cart snapshots.", nil},
+	{"migrations", "pattern", "reference", "Migrations are expand/contract: add the new column, backfill, deploy readers, then drop the old column in a later release.", nil},
+	{"payments", "fact", "reference", "Payments go through Stripe PaymentIntents; webhooks land on /hooks/stripe and are deduplicated by event ID in the stripe_events table.", []string{"area:payments"}},
+	{"idempotency", "decision", "reference", "Every POST from the checkout takes an Idempotency-Key header, stored for 24h, so retried requests never charge twice.", []string{"area:payments"}},
+	{"flaky", "observation", "working", "The checkout e2e test fails about 1 in 20 runs on CI: the Stripe mock sometimes answers before the webhook handler is registered.", []string{"area:payments"}},
+	{"fix-flaky", "task", "working", "Make the Stripe mock wait for the webhook handler's readiness probe before the checkout e2e test starts.", nil},
+	{"currency", "open-question", "working", "Do we show prices in the visitor's currency, or only convert at checkout?", nil},
+	{"cache", "hypothesis", "reference", "Product pages are slow because category counts are computed per request; caching them for a minute should cut p95 latency in half.", []string{"area:performance"}},
+	{"deploy", "fact", "reference", "Deploys go out from main through GitHub Actions to Fly.io, at most twice a day, never on Fridays after 14:00.", nil},
+	{"stripe", "entity", "reference", "Stripe: payment provider for cards, Apple Pay and SEPA debits.", nil},
+}
+
+var edges = []seedEdge{
+	{"postgres", "mysql", "SUPERSEDES"},
+	{"migrations", "postgres", "DEPENDS_ON"},
+	{"idempotency", "payments", "RELATES_TO"},
+	{"fix-flaky", "flaky", "DERIVED_FROM"},
+	{"payments", "stripe", "MENTIONS"},
+	{"flaky", "stripe", "MENTIONS"},
+	{"currency", "money", "RELATES_TO"},
+}
+
+// Seed adds the example nodes, their tags and edges to d, and returns how
+// many nodes it created. The superseded MySQL decision shows how replaced
+// knowledge is kept but left out of recall.
+func Seed(d db.Store) (int, error) {
+	ids := make(map[string]string, len(nodes))
+	for _, n := range nodes {
+		tags := append([]string{"tier:" + n.tier, "project:" + Project}, n.tags...)
+		node, err := d.CreateNode(db.CreateNodeInput{Type: n.typ, Content: n.content, Tags: tags})
+		if err != nil {
+			return 0, fmt.Errorf("failed to seed %s: %w", n.key, err)
+		}
+		ids[n.key] = node.ID
+	}
+	for _, e := range edges {
+		if _, err := d.CreateEdge(ids[e.from], ids[e.to], e.typ); err != nil {
+			return 0, fmt.Errorf("failed to seed edge %s -> %s: %w", e.from, e.to, err)
+		}
+		if e.typ == "SUPERSEDES" {
+			if _, err := d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", ids[e.from], ids[e.to]); err != nil {
+				return 0, fmt.Errorf("failed to seed %s: %w", e.to, err)
+			}
+		}
+	}
+	return len(nodes), nil
+}
@@ -0,0 +1,40 @@
+package demo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/demo"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestSeed(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	n, err := demo.Seed(d)
+	require.NoError(t, err)
+	all, err := d.ListNodes(db.ListOptions{IncludeSuperseded: true})
+	require.NoError(t, err)
+	assert.Len(t, all, n)
+	for _, node := range all {
+		assert.Contains(t, node.Tags, "project:"+demo.Project)
+	}
+
+	// The superseded MySQL decision is kept but left out of recall
+	decisions, err := query.ExecuteQuery(d, "type:decision AND content:mysql", false)
+	require.NoError(t, err)
+	assert.Empty(t, decisions)
+	superseded, err := query.ExecuteQuery(d, "superseded:true", false)
+	require.NoError(t, err)
+	require.Len(t, superseded, 1)
+	assert.Contains(t, superseded[0].Content, "MySQL")
+
+	for _, q := range []string{"tier:pinned", "tier:working", "type:open-question", "has:edges"} {
+		got, err := query.ExecuteQuery(d, q, false)
+		require.NoError(t, err)
+		assert.NotEmpty(t, got, q)
+	}
+}
@@ -0,0 +1,219 @@
+// Package embeddings computes node vectors for semantic search. Providers
+// implement db.Embedder over HTTP: Ollama, or any OpenAI-compatible
+// embeddings API. Index keeps the stored vectors in step with node content;
+// the store's SemanticSearch ranks nodes against them.
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+)
+
+// ErrNotConfigured is returned when no embeddings provider is set.
+var ErrNotConfigured = errors.New("no embeddings provider configured (set embeddings.provider to ollama or openai: ctx config set embeddings.provider ollama)")
+
+// Provider defaults.
+const (
+	DefaultOllamaURL   = "http://localhost:11434"
+	DefaultOllamaModel = "nomic-embed-text"
+	DefaultOpenAIURL   = "https://api.openai.com/v1"
+	DefaultOpenAIModel = "text-embedding-3-small"
+	DefaultAPIKeyEnv   = "OPENAI_API_KEY"
+)
+
+// batchSize is how many texts Index sends per request.
+const batchSize = 32
+
+// requestTimeout bounds one provider request.
+const requestTimeout = 60 * time.Second
+
+// New returns the provider cfg selects.
+func New(cfg config.Embeddings) (db.Embedder, error) {
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "":
+		return nil, ErrNotConfigured
+	case "ollama":
+		return &Ollama{URL: orDefault(cfg.URL, DefaultOllamaURL), ModelName: orDefault(cfg.Model, DefaultOllamaModel)}, nil
+	case "openai":
+		keyEnv := orDefault(cfg.APIKeyEnv, DefaultAPIKeyEnv)
+		return &OpenAI{
+			URL:       orDefault(cfg.URL, DefaultOpenAIURL),
+			ModelName: orDefault(cfg.Model, DefaultOpenAIModel),
+			APIKey:    os.Getenv(keyEnv),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown embeddings provider %q: use ollama or openai", cfg.Provider)
+	}
+}
+
+func orDefault(v, def string) string {
+	if strings.TrimSpace(v) == "" {
+		return def
+	}
+	return strings.TrimRight(v, "/")
+}
+
+// Ollama embeds with a local Ollama server's /api/embed endpoint.
+type Ollama struct {
+	URL       string
+	ModelName string
+}
+
+func (o *Ollama) Model() string { return "ollama/" + o.ModelName }
+
+func (o *Ollama) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	req := map[string]any{"model": o.ModelName, "input": texts}
+	if err := postJSON(ctx, o.URL+"/api/embed", "", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama returned %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
+
+// OpenAI embeds with an OpenAI-compatible /embeddings endpoint.
+type OpenAI struct {
+	URL       string
+	ModelName string
+	APIKey    string
+}
+
+func (o *OpenAI) Model() string { return "openai/" + o.ModelName }
+
+func (o *OpenAI) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	var resp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	req := map[string]any{"model": o.ModelName, "input": texts}
+	if err := postJSON(ctx, o.URL+"/embeddings", o.APIKey, req, &resp); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings API returned out-of-range index %d", d.Index)
+		}
+		out[d.Index] = d.Embedding
+	}
+	for i, v := range out {
+		if v == nil {
+			return nil, fmt.Errorf("embeddings API returned no vector for input %d", i)
+		}
+	}
+	return out, nil
+}
+
+func postJSON(ctx context.Context, url, apiKey string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("embeddings request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("embeddings request failed (%d): %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("invalid embeddings response: %w", err)
+	}
+	return nil
+}
+
+// ContentHash identifies the content a vector was computed from.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:16])
+}
+
+// IndexResult summarizes an Index pass.
+type IndexResult struct {
+	Model    string `json:"model"`
+	Embedded int    `json:"embedded"` // nodes (re)embedded
+	Current  int    `json:"current"`  // nodes whose vector was already up to date
+}
+
+// Index embeds the active nodes that have no vector under e's model, or
+// whose content changed since theirs was computed. With all, every active
+// node is re-embedded.
+func Index(ctx context.Context, d db.Store, e db.Embedder, all bool) (*IndexResult, error) {
+	res := &IndexResult{Model: e.Model()}
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	existing, err := d.ListEmbeddings(e.Model())
+	if err != nil {
+		return nil, err
+	}
+	hashes := make(map[string]string, len(existing))
+	for _, emb := range existing {
+		hashes[emb.NodeID] = emb.ContentHash
+	}
+
+	var todo []*db.Node
+	for _, n := range nodes {
+		if !all && hashes[n.ID] == ContentHash(n.Content) {
+			res.Current++
+			continue
+		}
+		todo = append(todo, n)
+	}
+
+	for start := 0; start < len(todo); start += batchSize {
+		batch := todo[start:min(start+batchSize, len(todo))]
+		texts := make([]string, len(batch))
+		for i, n := range batch {
+			texts[i] = n.Content
+		}
+		vectors, err := e.Embed(ctx, texts)
+		if err != nil {
+			return res, err
+		}
+		for i, n := range batch {
+			if err := d.UpsertEmbedding(&db.Embedding{
+				NodeID:      n.ID,
+				Model:       e.Model(),
+				Vector:      vectors[i],
+				ContentHash: ContentHash(n.Content),
+			}); err != nil {
+				return res, err
+			}
+			res.Embedded++
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,148 @@
+package embeddings_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/embeddings"
+	"github.com/zate/ctx/testutil"
+)
+
+// wordEmbedder maps text onto a few concept axes, so "car" and "vehicle"
+// land close together without sharing a word.
+type wordEmbedder struct{}
+
+var concepts = [][]string{
+	{"car", "vehicle", "truck", "drive"},
+	{"database", "postgres", "sqlite", "sql"},
+	{"cat", "dog", "pet"},
+}
+
+func (w *wordEmbedder) Model() string { return "test/words" }
+
+func (w *wordEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, len(concepts))
+		for _, word := range strings.Fields(strings.ToLower(text)) {
+			for axis, words := range concepts {
+				for _, cw := range words {
+					if strings.Trim(word, ".,") == cw {
+						v[axis]++
+					}
+				}
+			}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func TestIndexAndSemanticSearch(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	e := &wordEmbedder{}
+	cars, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The fleet uses electric truck models"})
+	dbs, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use postgres on the server and sqlite locally"})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The office dog is called Rex"})
+
+	res, err := embeddings.Index(context.Background(), d, e, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Embedded)
+	assert.Equal(t, 0, res.Current)
+
+	hits, err := d.SemanticSearch(e, "which vehicle do we drive", 2)
+	require.NoError(t, err)
+	require.Len(t, hits, 2)
+	assert.Equal(t, cars.ID, hits[0].ID, "related by concept, not by shared words")
+	assert.InDelta(t, 1.0, hits[0].Score, 0.001)
+
+	// Unchanged nodes are skipped; an edited node is re-embedded
+	content := "Use postgres everywhere"
+	_, err = d.UpdateNode(dbs.ID, db.UpdateNodeInput{Content: &content})
+	require.NoError(t, err)
+	res, err = embeddings.Index(context.Background(), d, e, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Embedded)
+	assert.Equal(t, 2, res.Current)
+
+	// Superseded nodes drop out of results
+	newer, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The fleet moved to hydrogen truck models"})
+	_, err = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newer.ID, cars.ID)
+	require.NoError(t, err)
+	_, err = embeddings.Index(context.Background(), d, e, false)
+	require.NoError(t, err)
+	hits, err = d.SemanticSearch(e, "vehicle", 1)
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, newer.ID, hits[0].ID)
+}
+
+func TestNew(t *testing.T) {
+	_, err := embeddings.New(config.Embeddings{})
+	assert.ErrorIs(t, err, embeddings.ErrNotConfigured)
+
+	_, err = embeddings.New(config.Embeddings{Provider: "bogus"})
+	assert.Error(t, err)
+
+	e, err := embeddings.New(config.Embeddings{Provider: "ollama"})
+	require.NoError(t, err)
+	assert.Equal(t, "ollama/"+embeddings.DefaultOllamaModel, e.Model())
+}
+
+func TestOllama(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/embed", r.URL.Path)
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "nomic-embed-text", req.Model)
+		vectors := make([][]float32, len(req.Input))
+		for i := range req.Input {
+			vectors[i] = []float32{float32(i), 1}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": vectors})
+	}))
+	defer srv.Close()
+
+	e, err := embeddings.New(config.Embeddings{Provider: "ollama", URL: srv.URL + "/"})
+	require.NoError(t, err)
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{0, 1}, {1, 1}}, vectors)
+}
+
+func TestOpenAI(t *testing.T) {
+	t.Setenv("TEST_EMBED_KEY", "sk-test")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/embeddings", r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		// Out of order on purpose: results are placed by index
+		_, _ = w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+	}))
+	defer srv.Close()
+
+	e, err := embeddings.New(config.Embeddings{Provider: "openai", URL: srv.URL + "/v1", Model: "m", APIKeyEnv: "TEST_EMBED_KEY"})
+	require.NoError(t, err)
+	assert.Equal(t, "openai/m", e.Model())
+	vectors, err := e.Embed(context.Background(), []string{"a", "b"})
+	require.NoError(t, err)
+	assert.Equal(t, [][]float32{{1, 0}, {0, 1}}, vectors)
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer fail.Close()
+	e, _ = embeddings.New(config.Embeddings{Provider: "openai", URL: fail.URL})
+	_, err = e.Embed(context.Background(), []string{"a"})
+	assert.ErrorContains(t, err, "401")
+}
@@ -0,0 +1,344 @@
+// Package entity finds the people, services and repositories that stored
+// knowledge mentions. Each entity is an "entity" node tagged entity:<kind>,
+// and every node that mentions it links to it with a MENTIONS edge, so
+// "everything about service-foo" is one hop from the entity node.
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/llm"
+)
+
+// Entity kinds.
+const (
+	Person  = "person"
+	Service = "service"
+	Repo    = "repo"
+)
+
+// Kinds lists the entity kinds in display order.
+var Kinds = []string{Person, Service, Repo}
+
+// NodeType is the node type of entity nodes, and EdgeType the edge from a
+// mentioning node to an entity.
+const (
+	NodeType = "entity"
+	EdgeType = "MENTIONS"
+)
+
+// extractedKey is the pending key holding when the last extraction pass
+// started, so the next pass only reads nodes changed since.
+const extractedKey = "entity_extracted_at"
+
+// Entity is a named thing mentioned in stored content.
+type Entity struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+var (
+	// @handle, not part of an email address
+	personRe = regexp.MustCompile(`(?:^|[^\w.@])@([A-Za-z][A-Za-z0-9-]{1,38})\b`)
+	// owner/repo on a code host
+	repoRe = regexp.MustCompile(`\b(?:github\.com|gitlab\.com|bitbucket\.org)[/:]([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+?)(?:\.git)?(?:[/#?\s)"'` + "`" + `]|$)`)
+	// service-foo, foo-service, billing-api, auth-svc ...
+	serviceRe = regexp.MustCompile(`\b(service-[a-z0-9]+(?:-[a-z0-9]+)*|[a-z0-9]+(?:-[a-z0-9]+)*-(?:service|svc|api|worker|gateway))\b`)
+)
+
+// Extract finds entities in text with simple rules: @handles are people,
+// owner/repo paths on GitHub, GitLab or Bitbucket are repos, and
+// hyphenated names starting with service- or ending in -service, -svc,
+// -api, -worker or -gateway are services. Names are lowercased.
+func Extract(text string) []Entity {
+	var out []Entity
+	for _, m := range personRe.FindAllStringSubmatch(text, -1) {
+		out = append(out, Entity{Kind: Person, Name: m[1]})
+	}
+	for _, m := range repoRe.FindAllStringSubmatch(text, -1) {
+		out = append(out, Entity{Kind: Repo, Name: m[1]})
+	}
+	for _, m := range serviceRe.FindAllStringSubmatch(text, -1) {
+		out = append(out, Entity{Kind: Service, Name: m[1]})
+	}
+	return normalize(out)
+}
+
+// ExtractLLM asks the model run by command for the entities in text.
+func ExtractLLM(ctx context.Context, command, text string) ([]Entity, error) {
+	prompt := "List the people, services and code repositories mentioned in the text below. " +
+		"Reply with a JSON array only, like [{\"kind\":\"service\",\"name\":\"billing-api\"}], " +
+		"where kind is person, service or repo. Reply [] if there are none.\n\n" + text
+	reply, err := llm.Run(ctx, command, prompt)
+	if err != nil {
+		return nil, err
+	}
+	// Tolerate prose or a code fence around the array
+	if start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]"); start >= 0 && end > start {
+		reply = reply[start : end+1]
+	}
+	var found []Entity
+	if err := json.Unmarshal([]byte(reply), &found); err != nil {
+		return nil, fmt.Errorf("LLM reply is not a JSON entity list: %w", err)
+	}
+	return normalize(found), nil
+}
+
+// normalize lowercases names, drops unknown kinds and removes duplicates.
+func normalize(entities []Entity) []Entity {
+	var out []Entity
+	seen := make(map[Entity]bool)
+	for _, e := range entities {
+		e.Kind = strings.ToLower(strings.TrimSpace(e.Kind))
+		e.Name = strings.ToLower(strings.Trim(strings.TrimSpace(e.Name), "@"))
+		if e.Name == "" || !validKind(e.Kind) || seen[e] {
+			continue
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	return out
+}
+
+func validKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Options controls Run.
+type Options struct {
+	All        bool   // re-read every node, not just those changed since the last pass
+	LLMCommand string // also ask this model; rules only when empty
+}
+
+// Result summarizes an extraction pass.
+type Result struct {
+	Scanned  int `json:"scanned"`  // nodes read
+	Created  int `json:"created"`  // new entity nodes
+	Mentions int `json:"mentions"` // new MENTIONS edges
+}
+
+// Run extracts entities from active nodes, creating entity nodes as needed
+// and linking each mention. Without opts.All only nodes changed since the
+// previous pass are read.
+func Run(ctx context.Context, d db.Store, opts Options) (*Result, error) {
+	started := time.Now().UTC()
+	var since *time.Time
+	if !opts.All {
+		if raw, err := d.GetPending(extractedKey); err == nil && raw != "" {
+			if t, err := time.Parse(time.RFC3339, raw); err == nil {
+				since = &t
+			}
+		}
+	}
+
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	index, err := newIndex(d)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for _, n := range nodes {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if n.Type == NodeType || (since != nil && n.UpdatedAt.Before(*since)) {
+			continue
+		}
+		res.Scanned++
+		found := Extract(n.Content)
+		if opts.LLMCommand != "" {
+			more, err := ExtractLLM(ctx, opts.LLMCommand, n.Content)
+			if err != nil {
+				return res, fmt.Errorf("node %s: %w", n.ID, err)
+			}
+			found = normalize(append(found, more...))
+		}
+		for _, e := range found {
+			created, linked, err := index.link(n.ID, e)
+			if err != nil {
+				return res, err
+			}
+			if created {
+				res.Created++
+			}
+			if linked {
+				res.Mentions++
+			}
+		}
+	}
+
+	if err := d.SetPending(extractedKey, started.Format(time.RFC3339)); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// index maps entities to their nodes and tracks existing mentions.
+type index struct {
+	d        db.Store
+	nodes    map[Entity]string
+	mentions map[string]bool // "<from>|<entity node>"
+}
+
+func newIndex(d db.Store) (*index, error) {
+	idx := &index{d: d, nodes: make(map[Entity]string), mentions: make(map[string]bool)}
+	entities, err := d.ListNodes(db.ListOptions{Type: NodeType})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range entities {
+		if e, ok := FromNode(n); ok {
+			idx.nodes[e] = n.ID
+		}
+	}
+	rows, err := d.Query("SELECT from_id, to_id FROM edges WHERE type = ?", EdgeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mentions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var from, to string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		idx.mentions[from+"|"+to] = true
+	}
+	return idx, rows.Err()
+}
+
+// link records that node mentions e, creating the entity node if needed.
+func (idx *index) link(nodeID string, e Entity) (created, linked bool, err error) {
+	entityID, ok := idx.nodes[e]
+	if !ok {
+		node, err := idx.d.CreateNode(db.CreateNodeInput{
+			Type:    NodeType,
+			Content: e.Name,
+			Tags:    []string{"entity:" + e.Kind, "tier:reference"},
+		})
+		if err != nil {
+			return false, false, fmt.Errorf("failed to create entity %s: %w", e.Name, err)
+		}
+		entityID = node.ID
+		idx.nodes[e] = entityID
+		created = true
+	}
+	key := nodeID + "|" + entityID
+	if idx.mentions[key] {
+		return created, false, nil
+	}
+	if _, err := idx.d.CreateEdge(nodeID, entityID, EdgeType); err != nil {
+		return created, false, fmt.Errorf("failed to link %s to %s: %w", nodeID, e.Name, err)
+	}
+	idx.mentions[key] = true
+	return created, true, nil
+}
+
+// FromNode returns the entity an entity node stands for.
+func FromNode(n *db.Node) (Entity, bool) {
+	if n.Type != NodeType {
+		return Entity{}, false
+	}
+	for _, t := range n.Tags {
+		if kind, ok := strings.CutPrefix(t, "entity:"); ok {
+			return Entity{Kind: kind, Name: strings.ToLower(strings.TrimSpace(n.Content))}, true
+		}
+	}
+	return Entity{}, false
+}
+
+// Summary is an entity with its mention count.
+type Summary struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Mentions int    `json:"mentions"`
+}
+
+// List returns the known entities, optionally of one kind, most-mentioned
+// first.
+func List(d db.Store, kind string) ([]Summary, error) {
+	opts := db.ListOptions{Type: NodeType}
+	if kind != "" {
+		opts.Tag = "entity:" + kind
+	}
+	nodes, err := d.ListNodes(opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []Summary
+	for _, n := range nodes {
+		e, ok := FromNode(n)
+		if !ok {
+			continue
+		}
+		edges, err := d.GetEdgesTo(n.ID)
+		if err != nil {
+			return nil, err
+		}
+		s := Summary{ID: n.ID, Kind: e.Kind, Name: e.Name}
+		for _, edge := range edges {
+			if edge.Type == EdgeType {
+				s.Mentions++
+			}
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Mentions != out[j].Mentions {
+			return out[i].Mentions > out[j].Mentions
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out, nil
+}
+
+// Find returns the entity node named name (of any kind), or nil.
+func Find(d db.Store, name string) (*db.Node, error) {
+	name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "@"))
+	nodes, err := d.ListNodes(db.ListOptions{Type: NodeType})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes {
+		if e, ok := FromNode(n); ok && e.Name == name {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// Mentions returns the active nodes that mention the entity node id.
+func Mentions(d db.Store, id string) ([]*db.Node, error) {
+	edges, err := d.GetEdgesTo(id)
+	if err != nil {
+		return nil, err
+	}
+	var out []*db.Node
+	for _, e := range edges {
+		if e.Type != EdgeType {
+			continue
+		}
+		n, err := d.GetNode(e.FromID)
+		if err != nil || n.SupersededBy != nil {
+			continue
+		}
+		out = append(out, n)
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/entity"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestExtract(t *testing.T) {
+	text := "Per @alice, billing-api calls service-foo; see https://github.com/acme/payments.git " +
+		"and mail bob@example.com. Billing-API again. The `git@github.com:acme/infra` repo too."
+	assert.ElementsMatch(t, []entity.Entity{
+		{Kind: entity.Person, Name: "alice"},
+		{Kind: entity.Service, Name: "billing-api"},
+		{Kind: entity.Service, Name: "service-foo"},
+		{Kind: entity.Repo, Name: "acme/payments"},
+		{Kind: entity.Repo, Name: "acme/infra"},
+	}, entity.Extract(text))
+
+	assert.Empty(t, entity.Extract("plain prose with a well-known phrase"))
+}
+
+func TestExtractLLM(t *testing.T) {
+	found, err := entity.ExtractLLM(context.Background(),
+		`cat >/dev/null; echo 'Here you go: [{"kind":"Person","name":"Carol"},{"kind":"planet","name":"mars"}]'`, "text")
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Entity{{Kind: entity.Person, Name: "carol"}}, found)
+
+	_, err = entity.ExtractLLM(context.Background(), "cat >/dev/null; echo nope", "text")
+	assert.Error(t, err)
+}
+
+func TestRun(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	ctx := context.Background()
+
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "service-foo owns billing"})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "@dave maintains service-foo"})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "nothing here"})
+
+	res, err := entity.Run(ctx, d, entity.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Scanned)
+	assert.Equal(t, 2, res.Created)
+	assert.Equal(t, 3, res.Mentions)
+
+	node, err := entity.Find(d, "Service-Foo")
+	require.NoError(t, err)
+	require.NotNil(t, node)
+	mentions, err := entity.Mentions(d, node.ID)
+	require.NoError(t, err)
+	var ids []string
+	for _, n := range mentions {
+		ids = append(ids, n.ID)
+	}
+	assert.ElementsMatch(t, []string{a.ID, b.ID}, ids)
+
+	// A full re-run links nothing new.
+	res, err = entity.Run(ctx, d, entity.Options{All: true})
+	require.NoError(t, err)
+	assert.Zero(t, res.Created)
+	assert.Zero(t, res.Mentions)
+
+	list, err := entity.List(d, "")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "service-foo", list[0].Name)
+	assert.Equal(t, 2, list[0].Mentions)
+
+	people, err := entity.List(d, entity.Person)
+	require.NoError(t, err)
+	require.Len(t, people, 1)
+	assert.Equal(t, "dave", people[0].Name)
+}
@@ -0,0 +1,520 @@
+// Package export streams a store's knowledge graph to and from JSONL: one
+// record per line, so neither side holds the whole graph in memory. The
+// format is the same for SQLite and PostgreSQL, which makes it the way to
+// migrate between backends or hand a subset of memory to someone else.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/token"
+	"github.com/zate/ctx/internal/validate"
+)
+
+// Version is the format version written in the header record.
+const Version = 1
+
+// Record kinds, in the order Write emits them.
+const (
+	KindHeader = "header"
+	KindView   = "view"
+	KindNode   = "node"
+	KindEdge   = "edge"
+)
+
+// pageSize is how many rows Write reads per query.
+const pageSize = 500
+
+// Record is one line of an export. Nodes carry their tags.
+type Record struct {
+	Kind       string   `json:"kind"`
+	Version    int      `json:"version,omitempty"`
+	ExportedAt string   `json:"exported_at,omitempty"`
+	View       *View    `json:"view,omitempty"`
+	Node       *db.Node `json:"node,omitempty"`
+	Edge       *db.Edge `json:"edge,omitempty"`
+}
+
+// View is a saved view.
+type View struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Budget int    `json:"budget"`
+	// Layout is the view's render layout as stored (JSON), if customized.
+	Layout json.RawMessage `json:"layout,omitempty"`
+}
+
+// Options selects what Write exports.
+type Options struct {
+	// Nodes, if set, exports just these nodes (and edges between them)
+	// instead of the whole store. Views are left out of subsets.
+	Nodes []*db.Node
+	// Include, if set, filters the nodes exported.
+	Include func(*db.Node) bool
+	// NoViews leaves views out, as they belong to the whole store.
+	NoViews bool
+}
+
+// Result counts what was written or imported.
+type Result struct {
+	Views int `json:"views"`
+	Nodes int `json:"nodes"`
+	Edges int `json:"edges"`
+	Tags  int `json:"tags"`
+}
+
+// Write streams the store to w as JSONL: a header, then views, nodes and
+// edges. An edge is written only when both of its nodes are.
+func Write(d db.Store, w io.Writer, opts Options) (*Result, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	res := &Result{}
+
+	if err := enc.Encode(Record{Kind: KindHeader, Version: Version, ExportedAt: time.Now().UTC().Format(time.RFC3339)}); err != nil {
+		return nil, err
+	}
+
+	// With a subset or a filter, edges are checked against the nodes written
+	var written map[string]bool
+	if opts.Nodes != nil || opts.Include != nil {
+		written = map[string]bool{}
+	}
+	writeNode := func(n *db.Node) error {
+		if opts.Include != nil && !opts.Include(n) {
+			return nil
+		}
+		if err := enc.Encode(Record{Kind: KindNode, Node: n}); err != nil {
+			return err
+		}
+		if written != nil {
+			written[n.ID] = true
+		}
+		res.Nodes++
+		res.Tags += len(n.Tags)
+		return nil
+	}
+
+	if opts.Nodes != nil {
+		for _, n := range opts.Nodes {
+			if err := writeNode(n); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if !opts.NoViews {
+			views, err := listViews(d)
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range views {
+				if err := enc.Encode(Record{Kind: KindView, View: v}); err != nil {
+					return nil, err
+				}
+				res.Views++
+			}
+		}
+		if err := eachNode(d, writeNode); err != nil {
+			return nil, err
+		}
+	}
+
+	err := eachEdge(d, func(e *db.Edge) error {
+		if written != nil && (!written[e.FromID] || !written[e.ToID]) {
+			return nil
+		}
+		res.Edges++
+		return enc.Encode(Record{Kind: KindEdge, Edge: e})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, bw.Flush()
+}
+
+func listViews(d db.Store) ([]*View, error) {
+	rows, err := d.Query("SELECT name, query, budget, layout FROM views ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+	defer rows.Close()
+	var views []*View
+	for rows.Next() {
+		v := &View{}
+		var layout string
+		if err := rows.Scan(&v.Name, &v.Query, &v.Budget, &layout); err != nil {
+			return nil, fmt.Errorf("failed to scan view: %w", err)
+		}
+		if layout != "" && layout != "{}" {
+			v.Layout = json.RawMessage(layout)
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// eachNode calls fn for every node, superseded ones included, a page of
+// IDs at a time.
+func eachNode(d db.Store, fn func(*db.Node) error) error {
+	after := ""
+	for {
+		ids, err := pageIDs(d, "SELECT id FROM nodes WHERE id > ? ORDER BY id LIMIT ?", after)
+		if err != nil {
+			return fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, id := range ids {
+			n, err := d.GetNode(id)
+			if errors.Is(err, db.ErrNotFound) {
+				continue // deleted while exporting
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+		if len(ids) < pageSize {
+			return nil
+		}
+		after = ids[len(ids)-1]
+	}
+}
+
+func pageIDs(d db.Store, query, after string) ([]string, error) {
+	rows, err := d.Query(query, after, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// eachEdge calls fn for every edge, a page at a time.
+func eachEdge(d db.Store, fn func(*db.Edge) error) error {
+	after := ""
+	for {
+		edges, err := pageEdges(d, after)
+		if err != nil {
+			return fmt.Errorf("failed to list edges: %w", err)
+		}
+		for _, e := range edges {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		if len(edges) < pageSize {
+			return nil
+		}
+		after = edges[len(edges)-1].ID
+	}
+}
+
+func pageEdges(d db.Store, after string) ([]*db.Edge, error) {
+	rows, err := d.Query(`SELECT id, from_id, to_id, type, created_at, metadata
+		FROM edges WHERE id > ? ORDER BY id LIMIT ?`, after, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var edges []*db.Edge
+	for rows.Next() {
+		e := &db.Edge{}
+		var createdAt string
+		if err := rows.Scan(&e.ID, &e.FromID, &e.ToID, &e.Type, &createdAt, &e.Metadata); err != nil {
+			return nil, err
+		}
+		e.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
+		edges = append(edges, e)
+	}
+	return edges, rows.Err()
+}
+
+// ImportOptions controls Import.
+type ImportOptions struct {
+	// Merge keeps nodes and views already in the store instead of failing
+	// on (nodes) or replacing (views) them.
+	Merge bool
+}
+
+// legacyExport is the single JSON document older versions of ctx export
+// wrote.
+type legacyExport struct {
+	Nodes []*db.Node `json:"nodes"`
+	Edges []*db.Edge `json:"edges"`
+	Tags  []struct {
+		NodeID string `json:"node_id"`
+		Tag    string `json:"tag"`
+	} `json:"tags"`
+}
+
+// Import reads an export from r into d, one record at a time, in a single
+// transaction: an error anywhere leaves the store as it was, so a failed
+// import can be fixed and run again. Supersede links are applied once
+// every node is in, since a node can be superseded by one later in the
+// stream. The JSON document older versions wrote is also accepted.
+func Import(d db.Store, r io.Reader, opts ImportOptions) (*Result, error) {
+	br := bufio.NewReader(r)
+	first, err := br.ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var header Record
+	if json.Unmarshal(first, &header) != nil || header.Kind != KindHeader {
+		return importLegacy(d, io.MultiReader(bytes.NewReader(first), br), opts)
+	}
+	if header.Version > Version {
+		return nil, fmt.Errorf("export format version %d is newer than this ctx supports (%d)", header.Version, Version)
+	}
+
+	im, err := newImporter(d, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer im.rollback()
+	dec := json.NewDecoder(br)
+	for line := 2; ; line++ {
+		var rec Record
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+		switch {
+		case rec.Kind == KindView && rec.View != nil:
+			err = im.view(rec.View)
+		case rec.Kind == KindNode && rec.Node != nil:
+			err = im.node(rec.Node)
+		case rec.Kind == KindEdge && rec.Edge != nil:
+			err = im.edge(rec.Edge)
+		default:
+			err = fmt.Errorf("unknown record kind %q", rec.Kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("record %d: %w", line, err)
+		}
+	}
+	return im.finish()
+}
+
+func importLegacy(d db.Store, r io.Reader, opts ImportOptions) (*Result, error) {
+	var doc legacyExport
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse export: %w", err)
+	}
+	// Tags were listed separately; fold them into their nodes
+	tags := map[string][]string{}
+	for _, t := range doc.Tags {
+		tags[t.NodeID] = append(tags[t.NodeID], t.Tag)
+	}
+	im, err := newImporter(d, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer im.rollback()
+	for _, n := range doc.Nodes {
+		n.Tags = tags[n.ID]
+		if err := im.node(n); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range doc.Edges {
+		if err := im.edge(e); err != nil {
+			return nil, err
+		}
+	}
+	return im.finish()
+}
+
+type importer struct {
+	d          db.Store
+	tx         *sql.Tx // nil when records are written straight to d
+	opts       ImportOptions
+	res        Result
+	supersedes map[string]string // node ID -> superseding node ID
+}
+
+// newImporter starts the transaction the import runs in; finish commits
+// it and rollback, deferred, undoes it if finish was not reached.
+func newImporter(d db.Store, opts ImportOptions) (*importer, error) {
+	tx, err := d.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &importer{d: d, tx: tx, opts: opts, supersedes: map[string]string{}}, nil
+}
+
+func (im *importer) exec(query string, args ...interface{}) (sql.Result, error) {
+	if im.tx == nil {
+		return im.d.Exec(query, args...)
+	}
+	return im.tx.Exec(im.d.Rebind(query), args...)
+}
+
+func (im *importer) queryRow(query string, args ...interface{}) *sql.Row {
+	if im.tx == nil {
+		return im.d.QueryRow(query, args...)
+	}
+	return im.tx.QueryRow(im.d.Rebind(query), args...)
+}
+
+func (im *importer) rollback() {
+	if im.tx != nil {
+		_ = im.tx.Rollback()
+	}
+}
+
+func (im *importer) view(v *View) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	conflict := "ON CONFLICT (name) DO UPDATE SET query = excluded.query, budget = excluded.budget, layout = excluded.layout, updated_at = excluded.updated_at"
+	if im.opts.Merge {
+		conflict = "ON CONFLICT DO NOTHING"
+	}
+	layout := "{}"
+	if len(v.Layout) > 0 {
+		layout = string(v.Layout)
+	}
+	res, err := im.exec(`INSERT INTO views (name, query, budget, layout, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) `+conflict,
+		v.Name, v.Query, v.Budget, layout, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to import view %s: %w", v.Name, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		im.res.Views++
+	}
+	return nil
+}
+
+func (im *importer) node(n *db.Node) error {
+	if err := checkNode(im.d.Types(), n); err != nil {
+		return err
+	}
+	metadata := n.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
+	var summary interface{}
+	if n.Summary != nil {
+		summary = *n.Summary
+	}
+	content := db.NormalizeContent(n.Content)
+
+	stmt := `INSERT INTO nodes (id, type, content, summary, token_estimate, created_at, updated_at, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if im.opts.Merge {
+		stmt += " ON CONFLICT DO NOTHING"
+	}
+	res, err := im.exec(stmt, n.ID, n.Type, content, summary, token.Estimate(content),
+		n.CreatedAt.UTC().Format(time.RFC3339), n.UpdatedAt.UTC().Format(time.RFC3339), metadata)
+	if err != nil {
+		return fmt.Errorf("failed to import node %s (use --merge to skip nodes that already exist): %w", n.ID, err)
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return nil // already present
+	}
+	im.res.Nodes++
+
+	for _, tag := range n.Tags {
+		res, err := im.exec("INSERT INTO tags (node_id, tag, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+			n.ID, strings.TrimSpace(tag), n.CreatedAt.UTC().Format(time.RFC3339))
+		if err != nil {
+			return fmt.Errorf("failed to import tag %s on %s: %w", tag, n.ID, err)
+		}
+		if rows, _ := res.RowsAffected(); rows > 0 {
+			im.res.Tags++
+		}
+	}
+	if n.SupersededBy != nil {
+		im.supersedes[n.ID] = *n.SupersededBy
+	}
+	return nil
+}
+
+// checkNode applies the rules nodes created through the store follow, as
+// the importer writes nodes with their IDs directly: a ULID, a known type,
+// valid tags and metadata that is valid JSON.
+func checkNode(types *validate.Registry, n *db.Node) error {
+	if err := validate.ID(n.ID); err != nil {
+		return err
+	}
+	if err := types.NodeType(n.Type); err != nil {
+		return fmt.Errorf("node %s: %w (custom types must be added with ctx types add first)", n.ID, err)
+	}
+	for _, tag := range n.Tags {
+		if err := validate.Tag(tag); err != nil {
+			return fmt.Errorf("node %s: %w", n.ID, err)
+		}
+	}
+	if n.Metadata != "" && !json.Valid([]byte(n.Metadata)) {
+		return fmt.Errorf("node %s: %w", n.ID, db.ErrInvalidMetadata)
+	}
+	if n.SupersededBy != nil {
+		if err := validate.ID(*n.SupersededBy); err != nil {
+			return fmt.Errorf("node %s superseded by: %w", n.ID, err)
+		}
+	}
+	return nil
+}
+
+// edge imports e, skipping duplicates and edges to nodes the store lacks.
+func (im *importer) edge(e *db.Edge) error {
+	var count int
+	if err := im.queryRow("SELECT COUNT(*) FROM nodes WHERE id IN (?, ?)", e.FromID, e.ToID).Scan(&count); err != nil {
+		return err
+	}
+	want := 2
+	if e.FromID == e.ToID {
+		want = 1
+	}
+	if count < want {
+		return nil
+	}
+	metadata := e.Metadata
+	if metadata == "" {
+		metadata = "{}"
+	}
+	res, err := im.exec(`INSERT INTO edges (id, from_id, to_id, type, created_at, metadata)
+		VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		e.ID, e.FromID, e.ToID, e.Type, e.CreatedAt.UTC().Format(time.RFC3339), metadata)
+	if err != nil {
+		return fmt.Errorf("failed to import edge %s: %w", e.ID, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		im.res.Edges++
+	}
+	return nil
+}
+
+// finish links superseded nodes to their replacements, where the
+// replacement made it into the store, and commits the import's
+// transaction if it has one.
+func (im *importer) finish() (*Result, error) {
+	for id, by := range im.supersedes {
+		_, err := im.exec("UPDATE nodes SET superseded_by = ? WHERE id = ? AND EXISTS (SELECT 1 FROM nodes WHERE id = ?)", by, id, by)
+		if err != nil {
+			return nil, fmt.Errorf("failed to link superseded node %s: %w", id, err)
+		}
+	}
+	if im.tx != nil {
+		if err := im.tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit import: %w", err)
+		}
+	}
+	return &im.res, nil
+}
@@ -0,0 +1,167 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
+	"github.com/zate/ctx/testutil"
+)
+
+func seed(t *testing.T, d db.Store) (old, newer, other *db.Node) {
+	t.Helper()
+	var err error
+	old, err = d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use MySQL", Tags: []string{"project:ctx", "tier:reference"}})
+	require.NoError(t, err)
+	newer, err = d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres", Tags: []string{"project:ctx"}})
+	require.NoError(t, err)
+	other, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Unrelated", Summary: testutil.Ptr("short")})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newer.ID, old.ID)
+	require.NoError(t, err)
+	_, err = d.CreateEdge(newer.ID, old.ID, "SUPERSEDES")
+	require.NoError(t, err)
+	_, err = d.CreateEdge(other.ID, newer.ID, "RELATES_TO")
+	require.NoError(t, err)
+	_, err = d.Exec(`INSERT INTO views (name, query, budget, created_at, updated_at) VALUES ('decisions', 'type:decision', 2000, '', '')`)
+	require.NoError(t, err)
+	return old, newer, other
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := testutil.SetupTestDB(t)
+	old, newer, other := seed(t, src)
+
+	var buf bytes.Buffer
+	res, err := export.Write(src, &buf, export.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Views: 2, Nodes: 3, Edges: 2, Tags: 3}, res)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1+2+3+2)
+	var header export.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, export.KindHeader, header.Kind)
+	assert.Equal(t, export.Version, header.Version)
+
+	dst := testutil.SetupTestDB(t)
+	res, err = export.Import(dst, &buf, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Views: 2, Nodes: 3, Edges: 2, Tags: 3}, res)
+
+	got, err := dst.GetNode(old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.SupersededBy, "supersede links to a node later in the stream")
+	assert.Equal(t, newer.ID, *got.SupersededBy)
+	assert.ElementsMatch(t, []string{"project:ctx", "tier:reference"}, got.Tags)
+	assert.Equal(t, old.CreatedAt.Unix(), got.CreatedAt.Unix())
+
+	got, err = dst.GetNode(other.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.Summary)
+	assert.Equal(t, "short", *got.Summary)
+
+	edges, err := dst.GetEdges(newer.ID, "both")
+	require.NoError(t, err)
+	assert.Len(t, edges, 2)
+
+	var budget int
+	require.NoError(t, dst.QueryRow("SELECT budget FROM views WHERE name = 'decisions'").Scan(&budget))
+	assert.Equal(t, 2000, budget)
+}
+
+func TestWriteSubset(t *testing.T) {
+	src := testutil.SetupTestDB(t)
+	_, newer, other := seed(t, src)
+
+	var buf bytes.Buffer
+	res, err := export.Write(src, &buf, export.Options{Nodes: []*db.Node{newer, other}})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Nodes: 2, Edges: 1, Tags: 1}, res, "no views, and only the edge between exported nodes")
+
+	dst := testutil.SetupTestDB(t)
+	_, err = export.Import(dst, &buf, export.ImportOptions{})
+	require.NoError(t, err)
+	edges, err := dst.GetEdges(other.ID, "out")
+	require.NoError(t, err)
+	require.Len(t, edges, 1)
+	assert.Equal(t, newer.ID, edges[0].ToID)
+}
+
+func TestImportMerge(t *testing.T) {
+	src := testutil.SetupTestDB(t)
+	seed(t, src)
+	var buf bytes.Buffer
+	_, err := export.Write(src, &buf, export.Options{})
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	// Importing into the same store fails on the existing nodes...
+	_, err = export.Import(src, bytes.NewReader(data), export.ImportOptions{})
+	assert.ErrorContains(t, err, "--merge")
+
+	// ...and with --merge adds nothing
+	res, err := export.Import(src, bytes.NewReader(data), export.ImportOptions{Merge: true})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{}, res)
+}
+
+func TestImportLegacyJSON(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	legacy := `{
+  "nodes": [
+    {"id": "01AAAAAAAAAAAAAAAAAAAAAAAA", "type": "fact", "content": "legacy fact", "created_at": "2025-01-01T00:00:00Z", "updated_at": "2025-01-01T00:00:00Z", "metadata": "{}"},
+    {"id": "01BBBBBBBBBBBBBBBBBBBBBBBB", "type": "fact", "content": "another", "created_at": "2025-01-01T00:00:00Z", "updated_at": "2025-01-01T00:00:00Z", "metadata": "{}"}
+  ],
+  "edges": [
+    {"id": "01CCCCCCCCCCCCCCCCCCCCCCCC", "from_id": "01AAAAAAAAAAAAAAAAAAAAAAAA", "to_id": "01BBBBBBBBBBBBBBBBBBBBBBBB", "type": "RELATES_TO", "created_at": "2025-01-01T00:00:00Z", "metadata": "{}"}
+  ],
+  "tags": [{"node_id": "01AAAAAAAAAAAAAAAAAAAAAAAA", "tag": "tier:pinned"}]
+}`
+	res, err := export.Import(d, strings.NewReader(legacy), export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Nodes: 2, Edges: 1, Tags: 1}, res)
+
+	n, err := d.GetNode("01AAAAAAAAAAAAAAAAAAAAAAAA")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tier:pinned"}, n.Tags)
+}
+
+func TestImportErrors(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := export.Import(d, strings.NewReader(`{"kind":"header","version":99}`+"\n"), export.ImportOptions{})
+	assert.ErrorContains(t, err, "newer")
+
+	_, err = export.Import(d, strings.NewReader(`{"kind":"header","version":1}`+"\n"+`{"kind":"bogus"}`+"\n"), export.ImportOptions{})
+	assert.ErrorContains(t, err, "record 2")
+
+	// Nodes follow the store's rules for IDs, types and tags
+	for _, tc := range []struct{ node, want string }{
+		{`{"id":"my-note","type":"fact","content":"x"}`, `invalid ID "my-note"`},
+		{`{"id":"01AAAAAAAAAAAAAAAAAAAAAAAA","type":"bogus","content":"x"}`, `invalid type "bogus"`},
+		{`{"id":"01AAAAAAAAAAAAAAAAAAAAAAAA","type":"fact","content":"x","tags":["Bad Tag!"]}`, `invalid tag "Bad Tag!"`},
+		{`{"id":"01AAAAAAAAAAAAAAAAAAAAAAAA","type":"fact","content":"x","tags":["tier:nonsense"]}`, `invalid tag "tier:nonsense"`},
+		{`{"id":"01AAAAAAAAAAAAAAAAAAAAAAAA","type":"fact","content":"x","metadata":"{\"source\":"}`, "metadata must be valid JSON"},
+	} {
+		stream := `{"kind":"header","version":1}` + "\n" + `{"kind":"node","node":` + tc.node + "}\n"
+		_, err = export.Import(d, strings.NewReader(stream), export.ImportOptions{})
+		assert.ErrorContains(t, err, "record 2", tc.node)
+		assert.ErrorContains(t, err, tc.want, tc.node)
+	}
+
+	// A bad record part-way through leaves nothing behind
+	stream := `{"kind":"header","version":1}` + "\n" +
+		`{"kind":"node","node":{"id":"01AAAAAAAAAAAAAAAAAAAAAAAA","type":"fact","content":"x"}}` + "\n" +
+		`{"kind":"node","node":{"id":"01BBBBBBBBBBBBBBBBBBBBBBBB","type":"bogus","content":"y"}}` + "\n"
+	_, err = export.Import(d, strings.NewReader(stream), export.ImportOptions{})
+	assert.ErrorContains(t, err, "record 3")
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+}
@@ -0,0 +1,462 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// titleLimit caps the title part of a note's file name, in runes.
+const titleLimit = 60
+
+// frontmatter is the YAML header of a vault note. Links hold the node's
+// outgoing edges as wikilinks, keyed by edge type.
+type frontmatter struct {
+	ID           string              `yaml:"id,omitempty"`
+	Type         string              `yaml:"type,omitempty"`
+	Summary      string              `yaml:"summary,omitempty"`
+	Tags         []string            `yaml:"tags,omitempty"`
+	Links        map[string][]string `yaml:"links,omitempty"`
+	SupersededBy string              `yaml:"superseded_by,omitempty"`
+	Created      string              `yaml:"created,omitempty"`
+	Updated      string              `yaml:"updated,omitempty"`
+}
+
+// note is a markdown file in a vault.
+type note struct {
+	path string // relative to the vault
+	fm   frontmatter
+	body string
+}
+
+// name is the note's file name without extension, which is what wikilinks
+// point at.
+func (n *note) name() string {
+	return strings.TrimSuffix(filepath.Base(n.path), ".md")
+}
+
+// WriteVault writes one markdown note per node into dir, for browsing and
+// editing in Obsidian: YAML frontmatter with the node's ID, type, tags and
+// outgoing edges as wikilinks, then its content. Notes already in dir are
+// rewritten where they are, so renamed or moved notes keep their place;
+// new ones are named after the first line of their content. Views are not
+// written.
+func WriteVault(d db.Store, dir string, opts Options) (*Result, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	existing, err := readVault(dir)
+	if err != nil {
+		return nil, err
+	}
+	paths := map[string]string{}
+	for _, n := range existing {
+		if n.fm.ID != "" {
+			paths[n.fm.ID] = n.path
+		}
+	}
+
+	each := func(fn func(*db.Node) error) error {
+		visit := func(n *db.Node) error {
+			if opts.Include != nil && !opts.Include(n) {
+				return nil
+			}
+			return fn(n)
+		}
+		if opts.Nodes == nil {
+			return eachNode(d, visit)
+		}
+		for _, n := range opts.Nodes {
+			if err := visit(n); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// First pass names every note, so links can point at them
+	taken := map[string]bool{}
+	for _, p := range paths {
+		taken[strings.ToLower(p)] = true
+	}
+	names := map[string]string{}
+	err = each(func(n *db.Node) error {
+		p, ok := paths[n.ID]
+		if !ok {
+			p = noteFile(n, taken)
+			paths[n.ID] = p
+		}
+		names[n.ID] = strings.TrimSuffix(filepath.Base(p), ".md")
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	err = each(func(n *db.Node) error {
+		edges, err := d.GetEdgesFrom(n.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load edges of %s: %w", n.ID, err)
+		}
+		fm := frontmatter{
+			ID:      n.ID,
+			Type:    n.Type,
+			Tags:    n.Tags,
+			Created: n.CreatedAt.UTC().Format(time.RFC3339),
+			Updated: n.UpdatedAt.UTC().Format(time.RFC3339),
+		}
+		if n.Summary != nil {
+			fm.Summary = *n.Summary
+		}
+		if n.SupersededBy != nil {
+			fm.SupersededBy = *n.SupersededBy
+		}
+		for _, e := range edges {
+			target, ok := names[e.ToID]
+			if !ok {
+				continue
+			}
+			if fm.Links == nil {
+				fm.Links = map[string][]string{}
+			}
+			fm.Links[e.Type] = append(fm.Links[e.Type], "[["+target+"]]")
+			res.Edges++
+		}
+
+		data, err := renderNote(fm, n.Content)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, paths[n.ID])
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		res.Nodes++
+		res.Tags += len(n.Tags)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ImportVault syncs a vault written by WriteVault, and edited since, back
+// into the store. Notes with a known ID update their node's content, type,
+// summary and tags; notes without one (or with an ID the store lacks)
+// become nodes, and new nodes get their ID written into the note. Each
+// note's links replace the node's outgoing edges to other notes in the
+// vault; edges to nodes outside the vault are left alone.
+//
+// With Merge, nodes already in the store are not updated; only new notes
+// and links are added.
+func ImportVault(d db.Store, dir string, opts ImportOptions) (*Result, error) {
+	notes, err := readVault(dir)
+	if err != nil {
+		return nil, err
+	}
+	// Notes go through the store's own methods, so they are not imported
+	// in one transaction; with Merge a failed import can simply be rerun
+	im := &importer{d: d, opts: ImportOptions{Merge: true}, supersedes: map[string]string{}}
+
+	ids := map[string]string{} // note name -> node ID
+	for _, n := range notes {
+		id, err := im.note(dir, n, opts.Merge)
+		if err != nil {
+			return nil, err
+		}
+		ids[strings.ToLower(n.name())] = id
+	}
+	inVault := map[string]bool{}
+	for _, id := range ids {
+		inVault[id] = true
+	}
+
+	for _, n := range notes {
+		if err := im.links(n, ids, inVault, opts.Merge); err != nil {
+			return nil, err
+		}
+	}
+	return im.finish()
+}
+
+// note imports one note and returns its node's ID.
+func (im *importer) note(dir string, n *note, merge bool) (string, error) {
+	content := db.NormalizeContent(n.body)
+	nodeType := n.fm.Type
+	if nodeType == "" {
+		nodeType = "fact"
+	}
+	var summary *string
+	if n.fm.Summary != "" {
+		summary = &n.fm.Summary
+	}
+
+	if n.fm.ID == "" {
+		created, err := im.d.CreateNode(db.CreateNodeInput{Type: nodeType, Content: content, Summary: summary, Tags: n.fm.Tags})
+		if err != nil {
+			return "", fmt.Errorf("failed to import %s: %w", n.path, err)
+		}
+		im.res.Nodes++
+		im.res.Tags += len(n.fm.Tags)
+		n.fm.ID = created.ID
+		data, err := renderNote(n.fm, n.body)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(filepath.Join(dir, n.path), data, 0o644); err != nil {
+			return "", fmt.Errorf("failed to record ID in %s: %w", n.path, err)
+		}
+		return created.ID, nil
+	}
+
+	existing, err := im.d.GetNode(n.fm.ID)
+	if errors.Is(err, db.ErrNotFound) {
+		node := &db.Node{ID: n.fm.ID, Type: nodeType, Content: content, Summary: summary, Tags: n.fm.Tags}
+		node.CreatedAt, _ = time.Parse(time.RFC3339, n.fm.Created)
+		if node.CreatedAt.IsZero() {
+			node.CreatedAt = time.Now()
+		}
+		node.UpdatedAt, _ = time.Parse(time.RFC3339, n.fm.Updated)
+		if node.UpdatedAt.IsZero() {
+			node.UpdatedAt = node.CreatedAt
+		}
+		if n.fm.SupersededBy != "" {
+			node.SupersededBy = &n.fm.SupersededBy
+		}
+		if err := im.node(node); err != nil {
+			return "", fmt.Errorf("failed to import %s: %w", n.path, err)
+		}
+		return n.fm.ID, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if merge {
+		return existing.ID, nil
+	}
+
+	var update db.UpdateNodeInput
+	if content != existing.Content {
+		update.Content = &content
+	}
+	if nodeType != existing.Type {
+		update.Type = &nodeType
+	}
+	if n.fm.Summary != stringValue(existing.Summary) {
+		update.Summary = &n.fm.Summary
+	}
+	changed := update.Content != nil || update.Type != nil || update.Summary != nil
+	if changed {
+		if _, err := im.d.UpdateNode(existing.ID, update); err != nil {
+			return "", fmt.Errorf("failed to update %s from %s: %w", existing.ID, n.path, err)
+		}
+	}
+	if added := tagsAdded(existing.Tags, n.fm.Tags); added > 0 || len(n.fm.Tags) != len(existing.Tags) {
+		if err := im.d.SetTags(existing.ID, n.fm.Tags); err != nil {
+			return "", fmt.Errorf("failed to update tags of %s: %w", existing.ID, err)
+		}
+		im.res.Tags += added
+		changed = true
+	}
+	if changed {
+		im.res.Nodes++
+	}
+	return existing.ID, nil
+}
+
+// links syncs a note's links to its node's outgoing edges.
+func (im *importer) links(n *note, ids map[string]string, inVault map[string]bool, merge bool) error {
+	from := ids[strings.ToLower(n.name())]
+	want := map[[2]string]bool{} // {type, to}
+	for edgeType, targets := range n.fm.Links {
+		for _, link := range targets {
+			if to, ok := ids[strings.ToLower(linkTarget(link))]; ok {
+				want[[2]string{edgeType, to}] = true
+			}
+		}
+	}
+
+	edges, err := im.d.GetEdgesFrom(from)
+	if err != nil {
+		return fmt.Errorf("failed to load edges of %s: %w", from, err)
+	}
+	for _, e := range edges {
+		key := [2]string{e.Type, e.ToID}
+		if want[key] {
+			delete(want, key)
+			continue
+		}
+		if merge || !inVault[e.ToID] {
+			continue
+		}
+		if err := im.d.DeleteEdge(e.FromID, e.ToID, e.Type); err != nil {
+			return fmt.Errorf("failed to remove %s edge from %s: %w", e.Type, from, err)
+		}
+	}
+
+	keys := make([][2]string, 0, len(want))
+	for key := range want {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i][0]+keys[i][1] < keys[j][0]+keys[j][1] })
+	for _, key := range keys {
+		if _, err := im.d.CreateEdge(from, key[1], key[0]); err != nil {
+			return fmt.Errorf("failed to link %s in %s: %w", key[0], n.path, err)
+		}
+		im.res.Edges++
+	}
+	return nil
+}
+
+// readVault parses every markdown note under dir, skipping hidden
+// directories such as .obsidian.
+func readVault(dir string) ([]*note, error) {
+	var notes []*note
+	err := filepath.WalkDir(dir, func(path string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if e.IsDir() {
+			if path != dir && strings.HasPrefix(e.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		n, err := parseNote(data)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		n.path, _ = filepath.Rel(dir, path)
+		notes = append(notes, n)
+		return nil
+	})
+	return notes, err
+}
+
+// parseNote splits a note into its frontmatter and body. A note without
+// frontmatter is all body.
+func parseNote(data []byte) (*note, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	n := &note{body: text}
+	if !strings.HasPrefix(text, "---\n") {
+		return n, nil
+	}
+	rest := text[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return n, nil
+	}
+	if err := yaml.Unmarshal([]byte(rest[:end]), &n.fm); err != nil {
+		return nil, fmt.Errorf("invalid frontmatter: %w", err)
+	}
+	body := rest[end+len("\n---"):]
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		body = body[i+1:]
+	} else {
+		body = ""
+	}
+	n.body = strings.TrimPrefix(body, "\n")
+	return n, nil
+}
+
+func renderNote(fm frontmatter, content string) ([]byte, error) {
+	header, err := yaml.Marshal(fm)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(header)
+	buf.WriteString("---\n\n")
+	buf.WriteString(strings.TrimRight(content, "\n"))
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
+
+// noteFile names a new note "<title> <ID>.md", the title taken from the
+// first line of the node's content, avoiding names already taken.
+func noteFile(n *db.Node, taken map[string]bool) string {
+	line := strings.TrimSpace(strings.SplitN(n.Content, "\n", 2)[0])
+	line = strings.TrimLeft(line, "#> -*")
+	title := strings.Join(strings.FieldsFunc(line, func(r rune) bool {
+		return strings.ContainsRune(`[]#^|\/:*?"<>`, r) || r == ' ' || r == '\t'
+	}), " ")
+	if utf8.RuneCountInString(title) > titleLimit {
+		title = string([]rune(title)[:titleLimit])
+		if i := strings.LastIndexByte(title, ' '); i > titleLimit/2 {
+			title = title[:i]
+		}
+	}
+	name := n.ID
+	if title != "" {
+		name = title + " " + n.ID
+	}
+	file := name + ".md"
+	for i := 2; taken[strings.ToLower(file)]; i++ {
+		file = fmt.Sprintf("%s %d.md", name, i)
+	}
+	taken[strings.ToLower(file)] = true
+	return file
+}
+
+// linkTarget returns the note a wikilink points at: "[[dir/Note#Heading|alias]]"
+// is "Note".
+func linkTarget(link string) string {
+	link = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(link), "[["), "]]")
+	if i := strings.IndexAny(link, "|#"); i >= 0 {
+		link = link[:i]
+	}
+	link = strings.TrimSuffix(link, ".md")
+	if i := strings.LastIndexByte(link, '/'); i >= 0 {
+		link = link[i+1:]
+	}
+	return strings.TrimSpace(link)
+}
+
+// tagsAdded counts the tags in next that are not in prev.
+func tagsAdded(prev, next []string) int {
+	have := map[string]bool{}
+	for _, t := range prev {
+		have[t] = true
+	}
+	added := 0
+	for _, t := range next {
+		if !have[t] {
+			added++
+		}
+	}
+	return added
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,134 @@
+package export_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/export"
+	"github.com/zate/ctx/testutil"
+)
+
+func notePath(t *testing.T, dir, id string) string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+id+".md"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1, "one note for %s", id)
+	return matches[0]
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	src := testutil.SetupTestDB(t)
+	old, newer, other := seed(t, src)
+	dir := t.TempDir()
+
+	res, err := export.WriteVault(src, dir, export.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Nodes: 3, Edges: 2, Tags: 3}, res)
+
+	path := notePath(t, dir, newer.ID)
+	assert.Equal(t, "Use Postgres "+newer.ID+".md", filepath.Base(path))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	text := string(data)
+	assert.True(t, strings.HasPrefix(text, "---\nid: "+newer.ID+"\ntype: decision\n"))
+	assert.Contains(t, text, "SUPERSEDES:\n        - '[[Use MySQL "+old.ID+"]]'")
+	assert.True(t, strings.HasSuffix(text, "---\n\nUse Postgres\n"))
+
+	dst := testutil.SetupTestDB(t)
+	res, err = export.ImportVault(dst, dir, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Nodes: 3, Edges: 2, Tags: 3}, res)
+
+	got, err := dst.GetNode(old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.SupersededBy)
+	assert.Equal(t, newer.ID, *got.SupersededBy)
+	assert.ElementsMatch(t, []string{"project:ctx", "tier:reference"}, got.Tags)
+	got, err = dst.GetNode(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "short", *got.Summary)
+	edges, err := dst.GetEdgesFrom(other.ID)
+	require.NoError(t, err)
+	require.Len(t, edges, 1)
+	assert.Equal(t, newer.ID, edges[0].ToID)
+
+	// Importing an unedited vault changes nothing
+	res, err = export.ImportVault(dst, dir, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{}, res)
+}
+
+func TestVaultSyncsEdits(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	_, newer, other := seed(t, d)
+	dir := t.TempDir()
+	_, err := export.WriteVault(d, dir, export.Options{})
+	require.NoError(t, err)
+
+	// Edit a note's content and tags and drop its link, then add a new note
+	// linking to it.
+	path := notePath(t, dir, other.ID)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	text := string(data)
+	text = strings.Replace(text, "type: fact", "type: observation", 1)
+	text = strings.Replace(text, "links:\n    RELATES_TO:\n        - '[[Use Postgres "+newer.ID+"]]'\n", "tags:\n    - edited\n", 1)
+	text = strings.Replace(text, "Unrelated\n", "Unrelated, edited in Obsidian\n", 1)
+	require.NoError(t, os.WriteFile(path, []byte(text), 0o644))
+
+	fresh := filepath.Join(dir, "notes", "Fresh idea.md")
+	require.NoError(t, os.MkdirAll(filepath.Dir(fresh), 0o755))
+	require.NoError(t, os.WriteFile(fresh, []byte("---\ntype: hypothesis\nlinks:\n  DERIVED_FROM: [\"[[notes/"+strings.TrimSuffix(filepath.Base(path), ".md")+"|source]]\"]\n---\n\nFresh idea\n"), 0o644))
+
+	res, err := export.ImportVault(d, dir, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Nodes)
+	assert.Equal(t, 1, res.Edges)
+
+	got, err := d.GetNode(other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "observation", got.Type)
+	assert.Equal(t, "Unrelated, edited in Obsidian", got.Content)
+	assert.Equal(t, []string{"edited"}, got.Tags)
+	edges, err := d.GetEdgesFrom(other.ID)
+	require.NoError(t, err)
+	assert.Empty(t, edges, "removed link drops the edge")
+
+	// The new note got a node, and its ID was written back
+	data, err = os.ReadFile(fresh)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), "---\nid: "))
+	id := strings.TrimSpace(strings.SplitN(string(data)[len("---\nid: "):], "\n", 2)[0])
+	created, err := d.GetNode(id)
+	require.NoError(t, err)
+	assert.Equal(t, "hypothesis", created.Type)
+	edges, err = d.GetEdgesFrom(id)
+	require.NoError(t, err)
+	require.Len(t, edges, 1)
+	assert.Equal(t, other.ID, edges[0].ToID)
+
+	res, err = export.ImportVault(d, dir, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{}, res, "re-import does not duplicate the new note")
+
+	// Re-exporting keeps notes where they are
+	_, err = export.WriteVault(d, dir, export.Options{})
+	require.NoError(t, err)
+	data, err = os.ReadFile(fresh)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "id: "+id)
+}
+
+func TestVaultRejectsInvalidNotes(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "My note.md"), []byte("---\nid: my-note\ntype: fact\n---\n\nHello\n"), 0o644))
+
+	_, err := export.ImportVault(d, dir, export.ImportOptions{})
+	assert.ErrorContains(t, err, "My note.md")
+	assert.ErrorContains(t, err, `invalid ID "my-note"`)
+}
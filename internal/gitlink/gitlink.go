@@ -0,0 +1,171 @@
+// Package gitlink ties decisions to the code they were made against. With
+// git_link on, a decision stored inside a git repository records the
+// branch and HEAD commit in its metadata (git_branch, git_commit) and is
+// DERIVED_FROM a source node for the commit, shared by every decision made
+// at that commit, so `ctx trace` and `meta:git_commit=` queries lead from a
+// decision to the code state behind it.
+package gitlink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+)
+
+// NodeType is the node type linked to commits.
+const NodeType = "decision"
+
+// EdgeType is the edge Link creates, from the decision to the commit.
+const EdgeType = "DERIVED_FROM"
+
+// gitTimeout bounds each git command, so a slow repository can't hold up
+// a hook.
+const gitTimeout = 2 * time.Second
+
+// Commit is the commit checked out in a repository.
+type Commit struct {
+	Repo    string // top-level directory of the repository
+	Branch  string // "" when HEAD is detached
+	SHA     string
+	Subject string
+}
+
+// Head returns the commit checked out in the repository containing dir, or
+// nil when dir is not in a git repository with commits or git is missing:
+// linkage is best effort and never blocks storing a decision.
+func Head(dir string) *Commit {
+	repo, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil
+	}
+	head, err := git(dir, "log", "-1", "--format=%H%n%s")
+	if err != nil {
+		return nil
+	}
+	sha, subject, _ := strings.Cut(head, "\n")
+	// symbolic-ref fails quietly on a detached HEAD
+	branch, _ := git(dir, "symbolic-ref", "--short", "-q", "HEAD")
+	return &Commit{Repo: repo, Branch: branch, SHA: sha, Subject: subject}
+}
+
+func git(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(out)), nil
+}
+
+// AddMetadata merges the commit's branch and SHA into a node's metadata
+// JSON, which may be empty.
+func (c *Commit) AddMetadata(metadata string) (string, error) {
+	fields := map[string]any{}
+	if metadata != "" && metadata != "{}" {
+		if err := json.Unmarshal([]byte(metadata), &fields); err != nil {
+			return "", fmt.Errorf("metadata must be a JSON object: %w", err)
+		}
+	}
+	fields["git_commit"] = c.SHA
+	if c.Branch != "" {
+		fields["git_branch"] = c.Branch
+	}
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+// Stamp prepares input for linking. When enabled (the git_link setting)
+// and input is a decision stored inside a git repository, it merges the
+// branch and HEAD commit into input's metadata and returns the commit to
+// Link once the node exists; otherwise it returns nil and leaves input be.
+// The repository is the project's: $CLAUDE_PROJECT_DIR when a hook or the
+// MCP server runs under Claude Code, else the working directory.
+func Stamp(input *db.CreateNodeInput, enabled bool) (*Commit, error) {
+	if !enabled || input.Type != NodeType {
+		return nil, nil
+	}
+	dir := os.Getenv("CLAUDE_PROJECT_DIR")
+	if dir == "" {
+		dir = "."
+	}
+	c := Head(dir)
+	if c == nil {
+		return nil, nil
+	}
+	metadata, err := c.AddMetadata(input.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	input.Metadata = metadata
+	return c, nil
+}
+
+// Link makes nodeID DERIVED_FROM the source node for the commit, creating
+// that node the first time the commit is linked. It returns the source
+// node's ID. A nil commit, from a node Stamp left alone, links nothing.
+func Link(d db.Store, nodeID string, c *Commit) (string, error) {
+	if c == nil {
+		return "", nil
+	}
+	sources, err := query.ExecuteQuery(d, "type:source AND meta:git_commit="+c.SHA, false)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the source node for commit %s: %w", short(c.SHA), err)
+	}
+
+	var sourceID string
+	if len(sources) > 0 {
+		sourceID = sources[0].ID
+	} else {
+		meta, _ := json.Marshal(map[string]string{"git_commit": c.SHA, "git_repo": c.Repo})
+		source, err := d.CreateNode(db.CreateNodeInput{
+			Type:     "source",
+			Content:  c.describe(),
+			Metadata: string(meta),
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to store commit %s: %w", short(c.SHA), err)
+		}
+		sourceID = source.ID
+	}
+
+	if _, err := d.CreateEdge(nodeID, sourceID, EdgeType); err != nil {
+		return "", fmt.Errorf("failed to link commit %s: %w", short(c.SHA), err)
+	}
+	return sourceID, nil
+}
+
+// String renders the commit as its short SHA and branch, e.g.
+// "3f2a9c1 (main)".
+func (c *Commit) String() string {
+	if c.Branch == "" {
+		return short(c.SHA) + " (detached)"
+	}
+	return fmt.Sprintf("%s (%s)", short(c.SHA), c.Branch)
+}
+
+// describe is the content of a commit's source node, e.g.
+// "Git commit 3f2a9c1 (main) in ctx: Add task pins".
+func (c *Commit) describe() string {
+	s := fmt.Sprintf("Git commit %s in %s", c, filepath.Base(c.Repo))
+	if c.Subject != "" {
+		s += ": " + c.Subject
+	}
+	return s
+}
+
+func short(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
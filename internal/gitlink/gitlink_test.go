@@ -0,0 +1,125 @@
+package gitlink_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/gitlink"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/testutil"
+)
+
+// setupRepo creates a git repository with one commit on branch main.
+func setupRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	run("init", "-q", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644))
+	run("add", "a.txt")
+	run("commit", "-q", "-m", "Add a")
+	return dir
+}
+
+func TestHead(t *testing.T) {
+	dir := setupRepo(t)
+
+	c := gitlink.Head(dir)
+	require.NotNil(t, c)
+	assert.Equal(t, "main", c.Branch)
+	assert.Len(t, c.SHA, 40)
+	assert.Equal(t, "Add a", c.Subject)
+	assert.Equal(t, c.SHA[:7]+" (main)", c.String())
+
+	assert.Nil(t, gitlink.Head(t.TempDir()), "not a repository")
+}
+
+func TestAddMetadata(t *testing.T) {
+	c := &gitlink.Commit{SHA: "3f2a9c1d", Branch: "main"}
+
+	meta, err := c.AddMetadata(`{"source":"review"}`)
+	require.NoError(t, err)
+	var fields map[string]string
+	require.NoError(t, json.Unmarshal([]byte(meta), &fields))
+	assert.Equal(t, map[string]string{"source": "review", "git_commit": "3f2a9c1d", "git_branch": "main"}, fields)
+
+	_, err = c.AddMetadata("[1]")
+	assert.Error(t, err)
+}
+
+func TestStamp(t *testing.T) {
+	dir := setupRepo(t)
+	t.Setenv("CLAUDE_PROJECT_DIR", dir)
+
+	input := db.CreateNodeInput{Type: "decision", Content: "use JWT", Metadata: `{"source":"review"}`}
+	c, err := gitlink.Stamp(&input, true)
+	require.NoError(t, err)
+	require.NotNil(t, c, "the project directory's repository, not the working directory's")
+	assert.Equal(t, gitlink.Head(dir).SHA, c.SHA)
+	var fields map[string]string
+	require.NoError(t, json.Unmarshal([]byte(input.Metadata), &fields))
+	assert.Equal(t, map[string]string{"source": "review", "git_commit": c.SHA, "git_branch": "main"}, fields)
+
+	fact := db.CreateNodeInput{Type: "fact", Content: "JWT expires hourly"}
+	c, err = gitlink.Stamp(&fact, true)
+	require.NoError(t, err)
+	assert.Nil(t, c, "only decisions are linked")
+	assert.Empty(t, fact.Metadata)
+
+	off := db.CreateNodeInput{Type: "decision", Content: "use Postgres"}
+	c, err = gitlink.Stamp(&off, false)
+	require.NoError(t, err)
+	assert.Nil(t, c, "git_link is off")
+
+	t.Setenv("CLAUDE_PROJECT_DIR", t.TempDir())
+	c, err = gitlink.Stamp(&off, true)
+	require.NoError(t, err)
+	assert.Nil(t, c, "not a repository")
+
+	d := testutil.SetupTestDB(t)
+	sourceID, err := gitlink.Link(d, "01ARZ3NDEKTSV4RRFFQ69G5FAV", nil)
+	require.NoError(t, err)
+	assert.Empty(t, sourceID, "a nil commit links nothing")
+}
+
+func TestLink(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	c := gitlink.Head(setupRepo(t))
+	require.NotNil(t, c)
+
+	first, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use JWT"})
+	require.NoError(t, err)
+	second, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use Postgres"})
+	require.NoError(t, err)
+
+	sourceID, err := gitlink.Link(d, first.ID, c)
+	require.NoError(t, err)
+	again, err := gitlink.Link(d, second.ID, c)
+	require.NoError(t, err)
+	assert.Equal(t, sourceID, again, "decisions at one commit share its source node")
+
+	source, err := d.GetNode(sourceID)
+	require.NoError(t, err)
+	assert.Equal(t, "source", source.Type)
+	assert.Contains(t, source.Content, "Git commit "+c.SHA[:7]+" (main)")
+	assert.Contains(t, source.Content, "Add a")
+
+	derived, err := query.ExecuteQuery(d, "related:"+sourceID+" via:DERIVED_FROM", false)
+	require.NoError(t, err)
+	assert.Len(t, derived, 2)
+}
@@ -0,0 +1,83 @@
+package hook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Ops an Action may report.
+const (
+	ActionStored     = "stored"
+	ActionMerged     = "merged"
+	ActionLinked     = "linked"
+	ActionSuperseded = "superseded"
+	ActionSummarized = "summarized"
+	ActionConfirmed  = "confirmed"
+	ActionHeld       = "held"
+)
+
+// Action is one change a ctx command made to the store.
+type Action struct {
+	Op     string `json:"op"`
+	ID     string `json:"id,omitempty"`     // node stored, merged into, confirmed or created; link source
+	Type   string `json:"type,omitempty"`   // node type, or edge type for links
+	Target string `json:"target,omitempty"` // link target, superseded node, or comma-separated summarized nodes
+}
+
+// SummarizeActions describes actions in one line, short IDs first, so the
+// user sees what was remembered and can undo it. It returns "" when there
+// is nothing to report.
+func SummarizeActions(actions []Action) string {
+	var stored, merged, linked, superseded, summarized, confirmed []string
+	held := 0
+	for _, a := range actions {
+		switch a.Op {
+		case ActionStored:
+			stored = append(stored, a.Type+" "+shortID(a.ID))
+		case ActionMerged:
+			merged = append(merged, a.Type+" "+shortID(a.ID))
+		case ActionLinked:
+			linked = append(linked, fmt.Sprintf("%s → %s (%s)", shortID(a.ID), shortID(a.Target), a.Type))
+		case ActionSuperseded:
+			superseded = append(superseded, shortID(a.Target)+" by "+shortID(a.ID))
+		case ActionSummarized:
+			summarized = append(summarized, fmt.Sprintf("%d node(s) into %s", strings.Count(a.Target, ",")+1, shortID(a.ID)))
+		case ActionConfirmed:
+			confirmed = append(confirmed, shortID(a.ID))
+		case ActionHeld:
+			held++
+		}
+	}
+
+	var parts []string
+	add := func(label string, items []string) {
+		if len(items) > 0 {
+			parts = append(parts, label+" "+strings.Join(items, ", "))
+		}
+	}
+	add("stored", stored)
+	add("merged into", merged)
+	add("linked", linked)
+	add("superseded", superseded)
+	add("summarized", summarized)
+	add("confirmed", confirmed)
+	if held > 0 {
+		parts = append(parts, fmt.Sprintf("held %d remember(s) for review (rate limit)", held))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+
+	summary := "ctx: " + strings.Join(parts, "; ")
+	if len(stored) > 0 || len(summarized) > 0 {
+		summary += " — undo with ctx delete <id>"
+	}
+	return summary
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
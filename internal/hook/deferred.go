@@ -0,0 +1,36 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/queue"
+)
+
+// CommandsJob is the queue job kind holding ctx commands a hook ran out of
+// time for.
+const CommandsJob = "commands"
+
+// DeferCommands queues commands for `ctx hook flush`.
+func DeferCommands(d db.Store, commands []CtxCommand) error {
+	if len(commands) == 0 {
+		return nil
+	}
+	return queue.Enqueue(d, CommandsJob, commands)
+}
+
+// RunCommandsJob executes a deferred commands job. Command errors are
+// reported rather than returned: most are permanent (an unknown ID), and a
+// retry would repeat the commands that succeeded.
+func RunCommandsJob(d db.Store, payload json.RawMessage) error {
+	var commands []CtxCommand
+	if err := json.Unmarshal(payload, &commands); err != nil {
+		return fmt.Errorf("invalid commands job: %w", err)
+	}
+	for _, err := range ExecuteCommandsWithErrors(d, commands) {
+		fmt.Fprintf(os.Stderr, "ctx: %v\n", err)
+	}
+	return nil
+}
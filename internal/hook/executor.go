@@ -1,79 +1,147 @@
 package hook
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/gitlink"
+	"github.com/zate/ctx/internal/ingest"
+	"github.com/zate/ctx/internal/provenance"
+	"github.com/zate/ctx/internal/related"
+	"github.com/zate/ctx/internal/validate"
 )
 
 // ExecuteCommands processes parsed ctx commands against the database.
+// Remembers, and supersedes with replacement content, over the per-minute
+// rate limit are held back for review.
 func ExecuteCommands(d db.Store, commands []CtxCommand) error {
+	limiter := newRememberLimiter(d)
 	for _, cmd := range commands {
-		if err := executeCommand(d, cmd); err != nil {
+		if storesContent(cmd) && !limiter.allow() {
+			limiter.hold(cmd)
+			continue
+		}
+		if _, err := executeCommand(d, cmd); err != nil {
 			fmt.Fprintf(os.Stderr, "ctx warning: failed to execute %s command: %v\n", cmd.Type, err)
 		}
 	}
+	if err := limiter.finish(); err != nil {
+		fmt.Fprintf(os.Stderr, "ctx warning: %v\n", err)
+	}
 	return nil
 }
 
 // ExecuteCommandsWithErrors processes parsed ctx commands and returns errors.
+// Remembers over the per-minute rate limit are held back for review.
 func ExecuteCommandsWithErrors(d db.Store, commands []CtxCommand) []error {
+	_, errs := ExecuteCommandsContext(context.Background(), d, commands)
+	return errs
+}
+
+// ExecuteCommandsContext is ExecuteCommandsWithErrors that stops once ctx is
+// done. It returns the commands it did not reach so the caller can defer
+// them (see DeferCommands).
+func ExecuteCommandsContext(ctx context.Context, d db.Store, commands []CtxCommand) ([]CtxCommand, []error) {
+	_, rest, errs := ExecuteCommandsReport(ctx, d, commands)
+	return rest, errs
+}
+
+// ExecuteCommandsReport is ExecuteCommandsContext that also reports what
+// the commands changed in the store, for the Stop hook's summary.
+func ExecuteCommandsReport(ctx context.Context, d db.Store, commands []CtxCommand) ([]Action, []CtxCommand, []error) {
+	limiter := newRememberLimiter(d)
+	var actions []Action
+	var rest []CtxCommand
 	var errs []error
-	for _, cmd := range commands {
-		if err := executeCommand(d, cmd); err != nil {
+	for i, cmd := range commands {
+		if ctx.Err() != nil {
+			rest = commands[i:]
+			break
+		}
+		if storesContent(cmd) && !limiter.allow() {
+			limiter.hold(cmd)
+			actions = append(actions, Action{Op: ActionHeld, Type: cmd.Attrs["type"]})
+			continue
+		}
+		action, err := executeCommand(d, cmd)
+		if err != nil {
 			errs = append(errs, fmt.Errorf("%s command failed: %w", cmd.Type, err))
+			continue
+		}
+		if action != nil {
+			actions = append(actions, *action)
 		}
 	}
-	return errs
+	if err := limiter.finish(); err != nil {
+		errs = append(errs, err)
+	}
+	return actions, rest, errs
 }
 
-func executeCommand(d db.Store, cmd CtxCommand) error {
+// executeCommand runs one command, returning what it changed in the store,
+// or nil for commands that only set hook state.
+func executeCommand(d db.Store, cmd CtxCommand) (*Action, error) {
 	switch cmd.Type {
 	case "remember":
 		return executeRemember(d, cmd)
 	case "recall":
-		return executeRecall(d, cmd)
+		return nil, executeRecall(d, cmd)
 	case "summarize":
 		return executeSummarize(d, cmd)
 	case "link":
 		return executeLink(d, cmd)
 	case "status":
-		return executeStatus(d)
+		return nil, executeStatus(d)
 	case "task":
-		return executeTask(d, cmd)
+		return nil, executeTask(d, cmd)
 	case "expand":
-		return executeExpand(d, cmd)
+		return nil, executeExpand(d, cmd)
 	case "supersede":
 		return executeSupersede(d, cmd)
+	case "confirm":
+		return executeConfirm(d, cmd)
 	default:
-		return fmt.Errorf("unknown command type: %s", cmd.Type)
+		return nil, fmt.Errorf("unknown command type: %s", cmd.Type)
 	}
 }
 
-func executeRemember(d db.Store, cmd CtxCommand) error {
+func executeRemember(d db.Store, cmd CtxCommand) (*Action, error) {
 	nodeType := cmd.Attrs["type"]
 	if nodeType == "" {
-		return fmt.Errorf("remember: type attribute is required")
+		return nil, fmt.Errorf("remember: type attribute is required")
+	}
+	if err := d.Types().NodeType(nodeType); err != nil {
+		return nil, fmt.Errorf("remember: %w", err)
 	}
 	content := strings.TrimSpace(cmd.Content)
 	if content == "" {
-		return fmt.Errorf("remember: content is required")
+		return nil, fmt.Errorf("remember: content is required")
 	}
+	content = config.Load().Redact(content)
 
+	// The tags come from model output, so they may not claim tags ctx sets
+	// itself or file the node under another agent
+	currentAgent, agentErr := d.GetPending("current_agent")
 	var tags []string
 	if tagStr, ok := cmd.Attrs["tags"]; ok && tagStr != "" {
 		tags = strings.Split(tagStr, ",")
 		for i := range tags {
 			tags[i] = strings.TrimSpace(tags[i])
 		}
+		if err := validate.CommandTags(tags, currentAgent); err != nil {
+			return nil, fmt.Errorf("remember: %w", err)
+		}
 	}
 
 	// Auto-add current task tag if working tier
-	currentTask, err := d.GetPending("current_task")
+	currentTask, err := CurrentTask(d)
 	if err == nil && currentTask != "" {
 		hasWorking := false
 		for _, t := range tags {
@@ -88,7 +156,6 @@ func executeRemember(d db.Store, cmd CtxCommand) error {
 	}
 
 	// Auto-add agent tag from current session
-	currentAgent, agentErr := d.GetPending("current_agent")
 	if agentErr == nil && currentAgent != "" {
 		hasAgentTag := false
 		for _, t := range tags {
@@ -120,22 +187,68 @@ func executeRemember(d db.Store, cmd CtxCommand) error {
 	// Check for existing node with same type and content to avoid duplicates
 	existing, err := d.FindByTypeAndContent(nodeType, content)
 	if err != nil {
-		return fmt.Errorf("remember: failed to check for duplicates: %w", err)
+		return nil, fmt.Errorf("remember: failed to check for duplicates: %w", err)
+	}
+	if existing == nil {
+		existing, err = ingest.FindSplit(d, nodeType, content, ingest.MaxNodeTokens())
+		if err != nil {
+			return nil, fmt.Errorf("remember: failed to check for duplicates: %w", err)
+		}
 	}
 	if existing != nil {
 		// Node already exists — merge any new tags
-		for _, tag := range tags {
-			_ = d.AddTag(existing.ID, tag)
+		_ = d.AddTags(existing.ID, tags)
+		return &Action{Op: ActionMerged, ID: existing.ID, Type: nodeType}, nil
+	}
+
+	node, err := storeNode(d, db.CreateNodeInput{Type: nodeType, Content: content, Tags: tags})
+	if err != nil {
+		return nil, fmt.Errorf("remember: %w", err)
+	}
+	if config.Load().AutoLink {
+		if _, err := related.LinkRefs(d, node.ID, content); err != nil {
+			return nil, fmt.Errorf("remember: failed to link mentioned nodes: %w", err)
 		}
-		return nil
 	}
+	return &Action{Op: ActionStored, ID: node.ID, Type: nodeType}, nil
+}
 
-	_, err = d.CreateNode(db.CreateNodeInput{
-		Type:    nodeType,
-		Content: content,
-		Tags:    tags,
-	})
-	return err
+// storeNode stores a node written by a hook command: tagged for review in
+// inbox mode, stamped with the HEAD commit when it is a decision (see
+// gitlink.Stamp), and split into chunks under a summary stub when oversized.
+func storeNode(d db.Store, input db.CreateNodeInput) (*db.Node, error) {
+	if InboxEnabled() {
+		input.Tags = append(input.Tags, ReviewPendingTag)
+	}
+	commit, err := gitlink.Stamp(&input, config.Load().GitLink)
+	if err != nil {
+		return nil, err
+	}
+	node, _, err := ingest.CreateNode(d, input, ingest.MaxNodeTokens())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gitlink.Link(d, node.ID, commit); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// discardNode deletes a node storeNode created, with its chunks if it was
+// split.
+func discardNode(d db.Store, id string) error {
+	edges, err := d.GetEdgesTo(id)
+	if err != nil {
+		return err
+	}
+	for _, e := range edges {
+		if e.Type == "CHILD_OF" {
+			if err := d.DeleteNode(e.FromID); err != nil {
+				return err
+			}
+		}
+	}
+	return d.DeleteNode(id)
 }
 
 func executeRecall(d db.Store, cmd CtxCommand) error {
@@ -144,20 +257,18 @@ func executeRecall(d db.Store, cmd CtxCommand) error {
 		return fmt.Errorf("recall: query attribute is required")
 	}
 
-	// Import query package dynamically to avoid circular deps
-	// Instead, we'll use the db directly with a simple approach
-	// Store query for later execution by prompt-submit hook
-	return d.SetPending("recall_query", queryStr)
+	// The prompt-submit hook runs the query and injects the results
+	return queueRecall(d, queryStr)
 }
 
-func executeSummarize(d db.Store, cmd CtxCommand) error {
+func executeSummarize(d db.Store, cmd CtxCommand) (*Action, error) {
 	nodesStr := cmd.Attrs["nodes"]
 	if nodesStr == "" {
-		return fmt.Errorf("summarize: nodes attribute is required")
+		return nil, fmt.Errorf("summarize: nodes attribute is required")
 	}
 	content := strings.TrimSpace(cmd.Content)
 	if content == "" {
-		return fmt.Errorf("summarize: content is required")
+		return nil, fmt.Errorf("summarize: content is required")
 	}
 
 	nodeIDs := strings.Split(nodesStr, ",")
@@ -167,61 +278,97 @@ func executeSummarize(d db.Store, cmd CtxCommand) error {
 
 	// Resolve short ID prefixes
 	for i, id := range nodeIDs {
-		resolved, err := d.ResolveID(id)
+		resolved, err := resolveID(d, id)
 		if err != nil {
-			return fmt.Errorf("summarize: failed to resolve node ID %q: %w", id, err)
+			return nil, fmt.Errorf("summarize: failed to resolve node ID %q: %w", id, err)
 		}
 		nodeIDs[i] = resolved
 	}
 
 	archive := cmd.Attrs["archive"] == "true"
 
+	var tags []string
+	if InboxEnabled() {
+		tags = append(tags, ReviewPendingTag)
+	}
+
 	summary, err := d.CreateNode(db.CreateNodeInput{
 		Type:    "summary",
 		Content: content,
+		Tags:    tags,
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, sourceID := range nodeIDs {
 		if _, err := d.CreateEdge(summary.ID, sourceID, "DERIVED_FROM"); err != nil {
-			return fmt.Errorf("summarize: failed to create edge: %w", err)
+			return nil, fmt.Errorf("summarize: failed to create edge: %w", err)
 		}
 		if archive {
-			_ = d.RemoveTag(sourceID, "tier:working")
-			_ = d.RemoveTag(sourceID, "tier:reference")
-			_ = d.RemoveTag(sourceID, "tier:pinned")
-			_ = d.AddTag(sourceID, "tier:off-context")
+			_ = d.UpdateTags(sourceID, []string{"tier:off-context"}, []string{"tier:working", "tier:reference", "tier:pinned"})
 		}
 	}
 
-	return nil
+	return &Action{Op: ActionSummarized, ID: summary.ID, Type: "summary", Target: strings.Join(nodeIDs, ",")}, nil
 }
 
-func executeLink(d db.Store, cmd CtxCommand) error {
+func executeLink(d db.Store, cmd CtxCommand) (*Action, error) {
 	fromID := cmd.Attrs["from"]
 	toID := cmd.Attrs["to"]
 	edgeType := cmd.Attrs["type"]
 	if fromID == "" || toID == "" {
-		return fmt.Errorf("link: from and to attributes are required")
+		return nil, fmt.Errorf("link: from and to attributes are required")
 	}
 	if edgeType == "" {
 		edgeType = "RELATES_TO"
 	}
+	if err := d.Types().EdgeType(edgeType); err != nil {
+		return nil, fmt.Errorf("link: %w", err)
+	}
 
 	// Resolve short ID prefixes
-	resolvedFrom, err := d.ResolveID(fromID)
+	resolvedFrom, err := resolveID(d, fromID)
 	if err != nil {
-		return fmt.Errorf("link: failed to resolve from ID %q: %w", fromID, err)
+		return nil, fmt.Errorf("link: failed to resolve from ID %q: %w", fromID, err)
 	}
-	resolvedTo, err := d.ResolveID(toID)
+	resolvedTo, err := resolveID(d, toID)
 	if err != nil {
-		return fmt.Errorf("link: failed to resolve to ID %q: %w", toID, err)
+		return nil, fmt.Errorf("link: failed to resolve to ID %q: %w", toID, err)
+	}
+
+	if _, err := d.CreateEdge(resolvedFrom, resolvedTo, edgeType); err != nil {
+		return nil, err
+	}
+	if edgeType == "DERIVED_FROM" {
+		warnBackwards(d, resolvedFrom, resolvedTo)
 	}
+	return &Action{Op: ActionLinked, ID: resolvedFrom, Type: edgeType, Target: resolvedTo}, nil
+}
 
-	_, err = d.CreateEdge(resolvedFrom, resolvedTo, edgeType)
-	return err
+// resolveID checks that arg looks like an ID before resolving it, so a
+// malformed attribute says what an ID should look like.
+func resolveID(d db.Store, arg string) (string, error) {
+	if err := validate.IDPrefix(arg); err != nil {
+		return "", err
+	}
+	return d.ResolveID(arg)
+}
+
+// warnBackwards warns when a new DERIVED_FROM edge looks reversed, with
+// the command that fixes it. The edge is kept either way.
+func warnBackwards(d db.Store, fromID, toID string) {
+	from, err := d.GetNode(fromID)
+	if err != nil {
+		return
+	}
+	to, err := d.GetNode(toID)
+	if err != nil {
+		return
+	}
+	if hint := provenance.BackwardsHint(from, to); hint != "" {
+		fmt.Fprintf(os.Stderr, "ctx warning: link: %s (reverse it with: ctx edge flip %s %s DERIVED_FROM)\n", hint, fromID, toID)
+	}
 }
 
 func executeStatus(d db.Store) error {
@@ -265,7 +412,7 @@ func executeTask(d db.Store, cmd CtxCommand) error {
 
 	switch action {
 	case "start":
-		return d.SetPending("current_task", name)
+		return StartTask(d, name)
 
 	case "end":
 		// Archive working nodes for this task
@@ -292,16 +439,14 @@ func executeTask(d db.Store, cmd CtxCommand) error {
 			}
 			if node.Type == "decision" {
 				// Promote to reference
-				_ = d.RemoveTag(id, "tier:working")
-				_ = d.AddTag(id, "tier:reference")
+				_ = d.UpdateTags(id, []string{"tier:reference"}, []string{"tier:working"})
 			} else {
 				// Archive
-				_ = d.RemoveTag(id, "tier:working")
-				_ = d.AddTag(id, "tier:off-context")
+				_ = d.UpdateTags(id, []string{"tier:off-context"}, []string{"tier:working"})
 			}
 		}
 
-		_ = d.DeletePending("current_task")
+		_ = EndTask(d, name)
 		return nil
 
 	default:
@@ -316,7 +461,7 @@ func executeExpand(d db.Store, cmd CtxCommand) error {
 	}
 
 	// Resolve short ID prefix
-	resolvedID, err := d.ResolveID(nodeID)
+	resolvedID, err := resolveID(d, nodeID)
 	if err != nil {
 		return fmt.Errorf("expand: failed to resolve node ID %q: %w", nodeID, err)
 	}
@@ -343,32 +488,112 @@ func executeExpand(d db.Store, cmd CtxCommand) error {
 	return d.SetPending("expand_nodes", string(data))
 }
 
-func executeSupersede(d db.Store, cmd CtxCommand) error {
+func executeSupersede(d db.Store, cmd CtxCommand) (*Action, error) {
 	oldID := cmd.Attrs["old"]
 	newID := cmd.Attrs["new"]
-	if oldID == "" || newID == "" {
-		return fmt.Errorf("supersede: old and new attributes are required")
+	content := strings.TrimSpace(cmd.Content)
+	if oldID == "" || (newID == "" && content == "") {
+		return nil, fmt.Errorf("supersede: old and either new or replacement content are required")
 	}
 
 	// Resolve short ID prefixes
-	resolvedOld, err := d.ResolveID(oldID)
+	resolvedOld, err := resolveID(d, oldID)
 	if err != nil {
-		return fmt.Errorf("supersede: failed to resolve old ID %q: %w", oldID, err)
-	}
-	resolvedNew, err := d.ResolveID(newID)
-	if err != nil {
-		return fmt.Errorf("supersede: failed to resolve new ID %q: %w", newID, err)
+		return nil, fmt.Errorf("supersede: failed to resolve old ID %q: %w", oldID, err)
 	}
 	oldID = resolvedOld
-	newID = resolvedNew
+	if newID != "" {
+		resolvedNew, err := resolveID(d, newID)
+		if err != nil {
+			return nil, fmt.Errorf("supersede: failed to resolve new ID %q: %w", newID, err)
+		}
+		newID = resolvedNew
+		// Mark old as superseded, with a SUPERSEDES edge
+		if err := d.Supersede(oldID, newID, false); err != nil {
+			return nil, fmt.Errorf("supersede: %w", err)
+		}
+	} else {
+		// Replacement content is stored as a remember would be, with the old
+		// node's type and tags. It doesn't inherit a pinned or reference
+		// tier, so model output can't rewrite those tiers unreviewed.
+		old, err := d.GetNode(oldID)
+		if err != nil {
+			return nil, fmt.Errorf("supersede: %w", err)
+		}
+		node, err := storeNode(d, db.CreateNodeInput{
+			Type:    old.Type,
+			Content: config.Load().Redact(content),
+			Tags:    replacementTags(old.Tags),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("supersede: %w", err)
+		}
+		// Superseding is separate from storing the (possibly split) node, so
+		// on failure the replacement is removed rather than left live
+		if err := d.Supersede(oldID, node.ID, false); err != nil {
+			if derr := discardNode(d, node.ID); derr != nil {
+				return nil, fmt.Errorf("supersede: %w (and failed to remove replacement %s: %v)", err, node.ID, derr)
+			}
+			return nil, fmt.Errorf("supersede: %w", err)
+		}
+		newID = node.ID
+	}
+
+	// Flag knowledge derived from the old node for review
+	if _, err := provenance.MarkStale(d, oldID); err != nil {
+		return nil, err
+	}
+	return &Action{Op: ActionSuperseded, ID: newID, Target: oldID}, nil
+}
 
-	// Mark old as superseded
-	_, err = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newID, oldID)
+// replacementTags are the tags a replacement node takes from the node it
+// supersedes: all but stale:true and review:pending, with a pinned or
+// reference tier lowered to tier:working.
+func replacementTags(old []string) []string {
+	var tags []string
+	working := false
+	for _, t := range old {
+		switch t {
+		case provenance.StaleTag, ReviewPendingTag:
+		case "tier:pinned", "tier:reference", "tier:working":
+			working = true
+		default:
+			tags = append(tags, t)
+		}
+	}
+	if working {
+		tags = append(tags, "tier:working")
+	}
+	return tags
+}
+
+// executeConfirm records that a node is still accurate. Updating the node
+// resets its age, so resurfacing won't ask about it again for a while.
+func executeConfirm(d db.Store, cmd CtxCommand) (*Action, error) {
+	id := cmd.Attrs["id"]
+	if id == "" {
+		return nil, fmt.Errorf("confirm: id attribute is required")
+	}
+	resolved, err := resolveID(d, id)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("confirm: failed to resolve ID %q: %w", id, err)
+	}
+	node, err := d.GetNode(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("confirm: %w", err)
 	}
 
-	// Create SUPERSEDES edge
-	_, err = d.CreateEdge(newID, oldID, "SUPERSEDES")
-	return err
+	fields := map[string]any{}
+	if node.Metadata != "" && node.Metadata != "{}" {
+		if err := json.Unmarshal([]byte(node.Metadata), &fields); err != nil {
+			return nil, fmt.Errorf("confirm: node metadata is not a JSON object: %w", err)
+		}
+	}
+	fields["confirmed_at"] = time.Now().UTC().Format(time.RFC3339)
+	data, _ := json.Marshal(fields)
+	metadata := string(data)
+	if _, err := d.UpdateNode(resolved, db.UpdateNodeInput{Metadata: &metadata}); err != nil {
+		return nil, err
+	}
+	return &Action{Op: ActionConfirmed, ID: resolved, Type: node.Type}, nil
 }
@@ -1,12 +1,16 @@
 package hook_test
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
 	"github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/queue"
 	"github.com/zate/ctx/testutil"
 )
 
@@ -132,6 +136,29 @@ func TestExecuteRemember_AutoProjectTag(t *testing.T) {
 	assert.Contains(t, tags, "project:memdown")
 }
 
+func TestExecuteRemember_ReservedTags(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	require.NoError(t, d.SetPending("current_agent", "alice"))
+
+	for _, tags := range []string{"review:pending", "tier:pinned,stale:true", "rate-limited", "agent:bob", "tier:pinned,agent:alice\nproject:x"} {
+		cmds := []hook.CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact", "tags": tags}, Content: "Spoofed " + tags}}
+		errs := hook.ExecuteCommandsWithErrors(d, cmds)
+		require.Len(t, errs, 1, tags)
+		assert.ErrorContains(t, errs[0], "remember: invalid tag", tags)
+	}
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	cmds := []hook.CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact", "tags": "agent:alice"}, Content: "Own agent"}}
+	assert.Empty(t, hook.ExecuteCommandsWithErrors(d, cmds))
+	nodes, err = d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	tags, _ := d.GetTags(nodes[0].ID)
+	assert.Equal(t, []string{"agent:alice"}, tags)
+}
+
 func TestExecuteRemember_NoAutoProjectTagWhenExplicit(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -255,6 +282,135 @@ func TestExecuteSupersede_ShortID(t *testing.T) {
 	assert.Equal(t, n2.ID, *node.SupersededBy)
 }
 
+func TestExecuteSupersede_FlagsDerivedNodesStale(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	oldNode, err := d.CreateNode(db.CreateNodeInput{Type: "source", Content: "old doc"})
+	require.NoError(t, err)
+	newNode, err := d.CreateNode(db.CreateNodeInput{Type: "source", Content: "new doc"})
+	require.NoError(t, err)
+	summary, err := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "summary of old doc"})
+	require.NoError(t, err)
+	_, err = d.CreateEdge(summary.ID, oldNode.ID, "DERIVED_FROM")
+	require.NoError(t, err)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "supersede", Attrs: map[string]string{"old": oldNode.ID, "new": newNode.ID}},
+	})
+	assert.Empty(t, errs)
+
+	tags, err := d.GetTags(summary.ID)
+	require.NoError(t, err)
+	assert.Contains(t, tags, "stale:true")
+}
+
+func TestExecuteSupersede_ReplacementContent(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use MySQL", Tags: []string{"tier:reference", "project:app", "stale:true"}})
+	require.NoError(t, err)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "supersede", Attrs: map[string]string{"old": old.ID}, Content: "use Postgres"},
+	})
+	assert.Empty(t, errs)
+
+	node, err := d.GetNode(old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, node.SupersededBy)
+	replacement, err := d.GetNode(*node.SupersededBy)
+	require.NoError(t, err)
+	assert.Equal(t, "decision", replacement.Type)
+	assert.Equal(t, "use Postgres", replacement.Content)
+	assert.ElementsMatch(t, []string{"tier:working", "project:app"}, replacement.Tags, "a reference tier is not inherited")
+}
+
+func TestExecuteSupersede_ReplacementInbox(t *testing.T) {
+	t.Setenv("CTX_INBOX", "true")
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "deploys run at noon", Tags: []string{"tier:pinned"}})
+	require.NoError(t, err)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "supersede", Attrs: map[string]string{"old": old.ID}, Content: "deploys run at midnight"},
+	})
+	require.Empty(t, errs)
+
+	// The replacement waits for review like a remember, outside the pinned tier
+	pending, err := d.GetNodesByTag(hook.ReviewPendingTag)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, "deploys run at midnight", pending[0].Content)
+	assert.ElementsMatch(t, []string{"tier:working", hook.ReviewPendingTag}, pending[0].Tags)
+	pinned, err := d.GetNodesByTag("tier:pinned")
+	require.NoError(t, err)
+	assert.Empty(t, pinned)
+}
+
+func TestExecuteSupersede_ReplacementRateLimited(t *testing.T) {
+	t.Setenv("CTX_MAX_REMEMBERS_PER_MINUTE", "1")
+	t.Setenv("CTX_INBOX", "false")
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "deploys run at noon", Tags: []string{"tier:pinned"}})
+	require.NoError(t, err)
+
+	cmds := append(rememberBatch(1, "first"), hook.CtxCommand{
+		Type: "supersede", Attrs: map[string]string{"old": old.ID}, Content: "deploys run at midnight",
+	})
+	require.Empty(t, hook.ExecuteCommandsWithErrors(d, cmds))
+
+	// The supersede is over the limit, so it is held and the old node stays
+	node, err := d.GetNode(old.ID)
+	require.NoError(t, err)
+	assert.Nil(t, node.SupersededBy)
+	held, err := d.GetNodesByTag(hook.RateLimitedTag)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Contains(t, held[0].Content, "[supersede "+old.ID+"] deploys run at midnight")
+}
+
+func TestExecuteSupersede_ReplacementRemovedOnFailure(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "deploys run at noon"})
+	require.NoError(t, err)
+	_, err = d.Exec(`CREATE TRIGGER fail_supersede BEFORE UPDATE OF superseded_by ON nodes BEGIN SELECT RAISE(ABORT, 'supersede refused'); END`)
+	require.NoError(t, err)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "supersede", Attrs: map[string]string{"old": old.ID}, Content: "deploys run at midnight"},
+	})
+	require.Len(t, errs, 1)
+
+	// No replacement is left live next to the old node
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, old.ID, nodes[0].ID)
+}
+
+func TestExecuteConfirm(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "still true", Metadata: `{"source":"notes"}`})
+	require.NoError(t, err)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "confirm", Attrs: map[string]string{"id": node.ID}},
+	})
+	assert.Empty(t, errs)
+
+	got, err := d.GetNode(node.ID)
+	require.NoError(t, err)
+	assert.Contains(t, got.Metadata, `"confirmed_at"`)
+	assert.Contains(t, got.Metadata, `"source":"notes"`)
+
+	errs = hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{{Type: "confirm"}})
+	assert.Len(t, errs, 1)
+}
+
 func TestExecuteLink_ShortID(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -281,6 +437,24 @@ func TestExecuteLink_ShortID(t *testing.T) {
 	assert.Equal(t, n2.ID, edges[0].ToID)
 }
 
+func TestExecuteCommands_RejectsInvalidInput(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "remember", Attrs: map[string]string{"type": "facts"}, Content: "bad type"},
+		{Type: "remember", Attrs: map[string]string{"type": "fact", "tags": "tier:hot"}, Content: "bad tier"},
+		{Type: "link", Attrs: map[string]string{"from": "node-a", "to": "01HV3K2M"}},
+	})
+	require.Len(t, errs, 3)
+	assert.ErrorContains(t, errs[0], `remember: invalid type "facts": must be one of fact,`)
+	assert.ErrorContains(t, errs[1], `remember: invalid tag "tier:hot": tier must be one of pinned,`)
+	assert.ErrorContains(t, errs[2], `invalid ID "node-a": must start with a digit 0-7`)
+
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+}
+
 func TestExecuteExpand_ShortID(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -340,3 +514,259 @@ func TestExecuteSummarize_ShortID(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, edges, 2)
 }
+
+func TestExecuteRemember_InboxTagsPendingReview(t *testing.T) {
+	t.Setenv("CTX_INBOX", "true")
+	d := testutil.SetupTestDB(t)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "needs review"},
+	})
+	require.Empty(t, errs)
+
+	nodes, err := d.GetNodesByTag(hook.ReviewPendingTag)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "needs review", nodes[0].Content)
+}
+
+func TestExecuteRemember_InboxDisabled(t *testing.T) {
+	t.Setenv("CTX_INBOX", "false")
+	d := testutil.SetupTestDB(t)
+
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "trusted"},
+	})
+	require.Empty(t, errs)
+
+	nodes, err := d.GetNodesByTag(hook.ReviewPendingTag)
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+}
+
+func TestExecuteRemember_SplitsOversizedContent(t *testing.T) {
+	t.Setenv("CTX_MAX_NODE_TOKENS", "100")
+	d := testutil.SetupTestDB(t)
+
+	cmds := []hook.CtxCommand{
+		{
+			Type:    "remember",
+			Attrs:   map[string]string{"type": "fact", "tags": "tier:pinned"},
+			Content: strings.Repeat("A long line of notes about the build.\n\n", 40),
+		},
+	}
+	require.Empty(t, hook.ExecuteCommandsWithErrors(d, cmds))
+
+	pinned, err := d.GetNodesByTag("tier:pinned")
+	require.NoError(t, err)
+	require.Len(t, pinned, 1)
+	assert.Contains(t, pinned[0].Content, "Split into")
+
+	before, err := d.ListNodes(db.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	assert.Greater(t, len(before), 2)
+
+	// Remembering the same content again is deduplicated against the stub
+	require.Empty(t, hook.ExecuteCommandsWithErrors(d, cmds))
+	after, err := d.ListNodes(db.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	assert.Len(t, after, len(before))
+}
+
+func rememberBatch(n int, prefix string) []hook.CtxCommand {
+	cmds := make([]hook.CtxCommand, n)
+	for i := range cmds {
+		cmds[i] = hook.CtxCommand{
+			Type:    "remember",
+			Attrs:   map[string]string{"type": "fact"},
+			Content: fmt.Sprintf("%s loop fact %d", prefix, i),
+		}
+	}
+	return cmds
+}
+
+func TestExecuteRemember_RateLimitHoldsOverflow(t *testing.T) {
+	t.Setenv("CTX_MAX_REMEMBERS_PER_MINUTE", "2")
+	t.Setenv("CTX_INBOX", "false")
+	d := testutil.SetupTestDB(t)
+
+	errs := hook.ExecuteCommandsWithErrors(d, rememberBatch(5, "first"))
+	require.Empty(t, errs)
+
+	facts, err := d.ListNodes(db.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	assert.Len(t, facts, 2)
+
+	held, err := d.GetNodesByTag(hook.RateLimitedTag)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Contains(t, held[0].Tags, hook.ReviewPendingTag)
+	assert.Contains(t, held[0].Content, "Held back 3 remembers")
+	assert.Contains(t, held[0].Content, "first loop fact 4")
+
+	// The window is still full, so the next batch appends to the same node.
+	errs = hook.ExecuteCommandsWithErrors(d, rememberBatch(2, "second"))
+	require.Empty(t, errs)
+
+	held, err = d.GetNodesByTag(hook.RateLimitedTag)
+	require.NoError(t, err)
+	require.Len(t, held, 1)
+	assert.Contains(t, held[0].Content, "Held back 5 remembers")
+	assert.Contains(t, held[0].Content, "first loop fact 2")
+	assert.Contains(t, held[0].Content, "second loop fact 1")
+}
+
+func TestExecuteRemember_RateLimitDisabled(t *testing.T) {
+	t.Setenv("CTX_MAX_REMEMBERS_PER_MINUTE", "0")
+	d := testutil.SetupTestDB(t)
+
+	errs := hook.ExecuteCommandsWithErrors(d, rememberBatch(40, "many"))
+	require.Empty(t, errs)
+
+	facts, err := d.ListNodes(db.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	assert.Len(t, facts, 40)
+
+	held, err := d.GetNodesByTag(hook.RateLimitedTag)
+	require.NoError(t, err)
+	assert.Empty(t, held)
+}
+
+func TestExecuteCommandsContext_DefersRest(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rest, errs := hook.ExecuteCommandsContext(ctx, d, rememberBatch(3, "late"))
+	assert.Empty(t, errs)
+	require.Len(t, rest, 3)
+
+	require.NoError(t, hook.DeferCommands(d, rest))
+	jobs, err := queue.List(d)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, hook.CommandsJob, jobs[0].Kind)
+
+	require.NoError(t, hook.RunCommandsJob(d, jobs[0].Payload))
+	facts, err := d.ListNodes(db.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	assert.Len(t, facts, 3)
+}
+
+func TestExecuteCommandsReport(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use MySQL"})
+	require.NoError(t, err)
+
+	actions, rest, errs := hook.ExecuteCommandsReport(context.Background(), d, []hook.CtxCommand{
+		{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "Reported fact"},
+		{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "Reported fact"},
+		{Type: "recall", Attrs: map[string]string{"query": "type:fact"}},
+		{Type: "supersede", Attrs: map[string]string{"old": old.ID[:10]}, Content: "Use Postgres"},
+		{Type: "link", Attrs: map[string]string{"from": "ZZZ", "to": old.ID}},
+	})
+	assert.Empty(t, rest)
+	require.Len(t, errs, 1, "the bad link is reported as an error, not an action")
+	require.Len(t, actions, 3)
+
+	assert.Equal(t, hook.ActionStored, actions[0].Op)
+	assert.Equal(t, "fact", actions[0].Type)
+	assert.Equal(t, hook.Action{Op: hook.ActionMerged, ID: actions[0].ID, Type: "fact"}, actions[1])
+	assert.Equal(t, hook.ActionSuperseded, actions[2].Op)
+	assert.Equal(t, old.ID, actions[2].Target)
+	replacement, err := d.GetNode(actions[2].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Use Postgres", replacement.Content)
+
+	summary := hook.SummarizeActions(actions)
+	assert.Equal(t, fmt.Sprintf("ctx: stored fact %s; merged into fact %s; superseded %s by %s — undo with ctx delete <id>",
+		actions[0].ID[:8], actions[0].ID[:8], old.ID[:8], actions[2].ID[:8]), summary)
+}
+
+func TestSummarizeActions(t *testing.T) {
+	assert.Empty(t, hook.SummarizeActions(nil))
+	assert.Equal(t, "ctx: linked 01AAAAAA → 01BBBBBB (DEPENDS_ON); confirmed 01CCCCCC; held 2 remember(s) for review (rate limit)",
+		hook.SummarizeActions([]hook.Action{
+			{Op: hook.ActionLinked, ID: "01AAAAAAAAAAAAAAAAAAAAAAAA", Type: "DEPENDS_ON", Target: "01BBBBBBBBBBBBBBBBBBBBBBBB"},
+			{Op: hook.ActionConfirmed, ID: "01CCCCCCCCCCCCCCCCCCCCCCCC", Type: "fact"},
+			{Op: hook.ActionHeld, Type: "fact"},
+			{Op: hook.ActionHeld, Type: "fact"},
+		}))
+	assert.Equal(t, "ctx: summarized 2 node(s) into 01DDDDDD — undo with ctx delete <id>",
+		hook.SummarizeActions([]hook.Action{{Op: hook.ActionSummarized, ID: "01DDDDDDDDDDDDDDDDDDDDDDDD", Target: "01A,01B"}}))
+}
+
+func TestExecuteRecall_Queues(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// A recall queued by an older version runs first
+	require.NoError(t, d.SetPending("recall_query", "tag:old"))
+	errs := hook.ExecuteCommandsWithErrors(d, []hook.CtxCommand{
+		{Type: "recall", Attrs: map[string]string{"query": "type:fact"}},
+		{Type: "recall", Attrs: map[string]string{"query": "type:decision"}},
+		{Type: "recall", Attrs: map[string]string{"query": "type:fact"}},
+		{Type: "recall"},
+	})
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "query attribute is required")
+
+	queries, err := hook.TakeRecalls(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tag:old", "type:fact", "type:decision"}, queries)
+
+	queries, err = hook.TakeRecalls(d)
+	require.NoError(t, err)
+	assert.Empty(t, queries, "taking the recalls clears them")
+
+	require.NoError(t, d.SetPending("recall_queries", "not json"))
+	_, err = hook.TakeRecalls(d)
+	assert.ErrorContains(t, err, "corrupt recall_queries")
+	queries, err = hook.TakeRecalls(d)
+	require.NoError(t, err)
+	assert.Empty(t, queries, "a corrupt list is dropped")
+}
+
+func TestTaskPins(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	a, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"tier:reference"}})
+	require.NoError(t, err)
+	b, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "b"})
+	require.NoError(t, err)
+
+	_, err = hook.PinToTask(d, a.ID)
+	assert.ErrorIs(t, err, hook.ErrNoTask)
+
+	require.NoError(t, hook.ExecuteCommands(d, []hook.CtxCommand{
+		{Type: "task", Attrs: map[string]string{"name": "auth", "action": "start"}},
+	}))
+	pins, err := hook.PinToTask(d, a.ID, b.ID, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID, b.ID}, pins)
+
+	pins, err = hook.UnpinFromTask(d, b.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, pins)
+
+	// Restarting the same task keeps its pins
+	require.NoError(t, hook.StartTask(d, "auth"))
+	pins, err = hook.TaskPins(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, pins)
+
+	// Ending another task leaves them
+	require.NoError(t, hook.EndTask(d, "billing"))
+	pins, err = hook.TaskPins(d)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, pins)
+
+	require.NoError(t, hook.ExecuteCommands(d, []hook.CtxCommand{
+		{Type: "task", Attrs: map[string]string{"name": "auth", "action": "end"}},
+	}))
+	name, err := hook.CurrentTask(d)
+	require.NoError(t, err)
+	assert.Empty(t, name)
+	pins, err = hook.TaskPins(d)
+	require.NoError(t, err)
+	assert.Empty(t, pins, "ending the task unpins its nodes")
+}
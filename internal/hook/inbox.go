@@ -0,0 +1,13 @@
+package hook
+
+import "github.com/zate/ctx/internal/config"
+
+// ReviewPendingTag marks hook-created nodes awaiting human review in the
+// inbox (see `ctx inbox`).
+const ReviewPendingTag = "review:pending"
+
+// InboxEnabled reports whether nodes created by hooks should land in the
+// review inbox. Set CTX_INBOX=true, or `inbox: true` in ~/.ctx/config.yaml.
+func InboxEnabled() bool {
+	return config.Load().Inbox
+}
@@ -1,7 +1,11 @@
 package hook
 
 import (
+	"encoding/json"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -16,18 +20,50 @@ var (
 	// Match opening tags: <ctx:command attr="value" ...> or self-closing <ctx:command attr="value" .../>
 	openTagRe = regexp.MustCompile(`<ctx:(\w+)((?:\s+\w+="[^"]*")*)\s*/?>`)
 	attrRe    = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+	// The lenient forms also accept single-quoted and unquoted values and
+	// whitespace around '='.
+	lenientOpenTagRe = regexp.MustCompile(`<ctx:(\w+)((?:\s+\w+\s*=\s*(?:"[^"]*"|'[^']*'|[^\s"'<>]+))*)\s*/?>`)
+	lenientAttrRe    = regexp.MustCompile(`(\w+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'<>]+))`)
 )
 
-// ParseCtxCommands parses <ctx:*> commands from Claude's response.
-// Commands inside code blocks (fenced or inline) are ignored.
+// ParseCtxCommands parses <ctx:*> commands from Claude's response, and
+// JSON commands from fenced blocks tagged ctx (see parseJSONCommands).
+// Other commands inside code blocks (fenced or inline) are ignored.
+//
+// Attributes may be double-quoted, single-quoted or unquoted, in any order,
+// with whitespace around '='. A command of the same type nested inside
+// another is taken as part of the outer command's content.
 func ParseCtxCommands(response string) []CtxCommand {
+	return parseCtxCommands(response, false)
+}
+
+// ParseCtxCommandsStrict is ParseCtxCommands accepting only the documented
+// syntax: attributes must be double-quoted with nothing around '=', and a
+// command with another of its type nested inside is dropped as ambiguous.
+func ParseCtxCommandsStrict(response string) []CtxCommand {
+	return parseCtxCommands(response, true)
+}
+
+func parseCtxCommands(response string, strict bool) []CtxCommand {
+	tagRe := lenientOpenTagRe
+	if strict {
+		tagRe = openTagRe
+	}
+
 	// Find code block regions to exclude
 	codeRegions := findCodeRegions(response)
 
 	var commands []CtxCommand
+	var positions []int
+
+	// bodyEnd is where the last command of each type ended, so tags nested
+	// in it are not parsed again on their own
+	bodyEnd := map[string]int{}
 
 	// Find all opening tags
-	matches := openTagRe.FindAllStringIndex(response, -1)
+	matches := tagRe.FindAllStringSubmatchIndex(response, -1)
+	tags := indexTags(response, matches)
 	for _, match := range matches {
 		start := match[0]
 
@@ -37,18 +73,21 @@ func ParseCtxCommands(response string) []CtxCommand {
 		}
 
 		fullMatch := response[match[0]:match[1]]
-		tagMatch := openTagRe.FindStringSubmatch(fullMatch)
-		if tagMatch == nil {
+		cmdType := response[match[2]:match[3]]
+		attrStr := response[match[4]:match[5]]
+		if !strict && start < bodyEnd[cmdType] {
 			continue
 		}
 
-		cmdType := tagMatch[1]
-		attrStr := tagMatch[2]
-
-		attrs := parseAttrs(attrStr)
+		// Check if self-closing. An unquoted last value swallows the
+		// slash: <ctx:recall query=x/>
+		selfClosing := strings.HasSuffix(fullMatch, "/>")
+		if selfClosing {
+			attrStr = strings.TrimSuffix(attrStr, "/")
+		}
+		attrs := parseAttrs(attrStr, strict)
 
-		// Check if self-closing
-		if strings.HasSuffix(fullMatch, "/>") {
+		if selfClosing {
 			cmd := CtxCommand{
 				Type:  cmdType,
 				Attrs: attrs,
@@ -57,18 +96,28 @@ func ParseCtxCommands(response string) []CtxCommand {
 				cmd.Attrs = nil
 			}
 			commands = append(commands, cmd)
+			positions = append(positions, start)
 			continue
 		}
 
 		// Find closing tag
-		closePattern := "</ctx:" + cmdType + ">"
-		closeIdx := strings.Index(response[match[1]:], closePattern)
-		if closeIdx == -1 {
+		closeStart := -1
+		if strict {
+			closeStart = tags.firstClose(cmdType, match[1])
+			if closeStart != -1 && tags.opensBetween(cmdType, match[1], closeStart) {
+				// Nested command of the same type, skip the outer one
+				continue
+			}
+		} else if end, ok := tags.pairs[start]; ok {
+			closeStart = end
+		}
+		if closeStart == -1 {
 			// Unclosed tag, skip
 			continue
 		}
+		bodyEnd[cmdType] = closeStart + len("</ctx:"+cmdType+">")
 
-		content := strings.TrimSpace(response[match[1] : match[1]+closeIdx])
+		content := strings.TrimSpace(response[match[1]:closeStart])
 
 		cmd := CtxCommand{
 			Type:    cmdType,
@@ -79,6 +128,16 @@ func ParseCtxCommands(response string) []CtxCommand {
 			cmd.Attrs = nil
 		}
 		commands = append(commands, cmd)
+		positions = append(positions, start)
+	}
+
+	// Commands in ```ctx JSON blocks run in order with the XML ones
+	for _, block := range findCtxBlocks(response, codeRegions) {
+		for _, cmd := range parseJSONCommands(block.body) {
+			i := sort.SearchInts(positions, block.start+1)
+			commands = slices.Insert(commands, i, cmd)
+			positions = slices.Insert(positions, i, block.start)
+		}
 	}
 
 	if commands == nil {
@@ -87,11 +146,96 @@ func ParseCtxCommands(response string) []CtxCommand {
 	return commands
 }
 
-func parseAttrs(s string) map[string]string {
-	attrs := map[string]string{}
-	matches := attrRe.FindAllStringSubmatch(s, -1)
+// tagIndex records where each command type's opening and closing tags are
+// in a response, so finding a command's closing tag is a lookup rather than
+// a rescan of everything after it, which made unbalanced tags in model
+// output quadratic.
+type tagIndex struct {
+	opens  map[string][]int // starts of opening, not self-closing, tags
+	closes map[string][]int // starts of closing tags
+	pairs  map[int]int      // opening tag start to its closing tag start
+}
+
+var closeTagRe = regexp.MustCompile(`</ctx:(\w+)>`)
+
+// indexTags indexes the opening tags in matches and the closing tags in
+// response. A closing tag inside an opening one, in an attribute value, is
+// not a tag. Each opening tag is paired with its closing tag, skipping over
+// nested commands of the same type; unclosed tags have no pair.
+func indexTags(response string, matches [][]int) tagIndex {
+	idx := tagIndex{opens: map[string][]int{}, closes: map[string][]int{}, pairs: map[int]int{}}
+
+	type event struct {
+		pos     int
+		cmdType string
+		open    bool
+	}
+	var events []event
 	for _, m := range matches {
-		attrs[m[1]] = m[2]
+		if !strings.HasSuffix(response[m[0]:m[1]], "/>") {
+			events = append(events, event{m[0], response[m[2]:m[3]], true})
+		}
+	}
+	var closes []event
+	next := 0
+	for _, m := range closeTagRe.FindAllStringSubmatchIndex(response, -1) {
+		for next < len(matches) && matches[next][1] <= m[0] {
+			next++
+		}
+		if next < len(matches) && matches[next][0] < m[0] {
+			continue
+		}
+		closes = append(closes, event{m[0], response[m[2]:m[3]], false})
+	}
+	events = append(events, closes...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].pos < events[j].pos })
+
+	stacks := map[string][]int{}
+	for _, e := range events {
+		if e.open {
+			idx.opens[e.cmdType] = append(idx.opens[e.cmdType], e.pos)
+			stacks[e.cmdType] = append(stacks[e.cmdType], e.pos)
+			continue
+		}
+		idx.closes[e.cmdType] = append(idx.closes[e.cmdType], e.pos)
+		if stack := stacks[e.cmdType]; len(stack) > 0 {
+			idx.pairs[stack[len(stack)-1]] = e.pos
+			stacks[e.cmdType] = stack[:len(stack)-1]
+		}
+	}
+	return idx
+}
+
+// firstClose returns the start of the first cmdType closing tag at or
+// after pos, or -1 if there is none.
+func (idx tagIndex) firstClose(cmdType string, pos int) int {
+	closes := idx.closes[cmdType]
+	i := sort.SearchInts(closes, pos)
+	if i == len(closes) {
+		return -1
+	}
+	return closes[i]
+}
+
+// opensBetween reports whether a cmdType opening tag starts in [from, to).
+func (idx tagIndex) opensBetween(cmdType string, from, to int) bool {
+	opens := idx.opens[cmdType]
+	i := sort.SearchInts(opens, from)
+	return i < len(opens) && opens[i] < to
+}
+
+func parseAttrs(s string, strict bool) map[string]string {
+	attrs := map[string]string{}
+	if strict {
+		for _, m := range attrRe.FindAllStringSubmatch(s, -1) {
+			attrs[m[1]] = m[2]
+		}
+	} else {
+		for _, m := range lenientAttrRe.FindAllStringSubmatch(s, -1) {
+			// Exactly one of the double-quoted, single-quoted and
+			// unquoted groups matched
+			attrs[m[1]] = m[2] + m[3] + m[4]
+		}
 	}
 	if len(attrs) == 0 {
 		return nil
@@ -99,6 +243,114 @@ func parseAttrs(s string) map[string]string {
 	return attrs
 }
 
+// ctxBlock is the body of a fenced code block tagged ctx.
+type ctxBlock struct {
+	start int
+	body  string
+}
+
+// findCtxBlocks returns the closed fenced blocks among regions whose info
+// string is ctx (```ctx or ```ctx json).
+func findCtxBlocks(text string, regions []codeRegion) []ctxBlock {
+	var blocks []ctxBlock
+	for _, r := range regions {
+		block := text[r.start:r.end]
+		if !strings.HasPrefix(block, "```") || len(block) < 6 || !strings.HasSuffix(block, "```") {
+			continue
+		}
+		info, body, ok := strings.Cut(block[3:len(block)-3], "\n")
+		if !ok {
+			continue
+		}
+		if fields := strings.Fields(info); len(fields) == 0 || fields[0] != "ctx" {
+			continue
+		}
+		blocks = append(blocks, ctxBlock{start: r.start, body: body})
+	}
+	return blocks
+}
+
+// parseJSONCommands reads the commands in a ```ctx block: JSON objects, one
+// after another or in an array, each naming its command in "op", e.g.
+// {"op":"remember","type":"fact","tags":["tier:reference"],"content":"..."}.
+// "content" becomes the command's content and the other fields its
+// attributes, with lists joined by commas. Reading stops at the first value
+// that is not valid JSON.
+func parseJSONCommands(body string) []CtxCommand {
+	var commands []CtxCommand
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	for {
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return commands
+		}
+		objs, ok := v.([]any)
+		if !ok {
+			objs = []any{v}
+		}
+		for _, o := range objs {
+			if cmd, ok := jsonCommand(o); ok {
+				commands = append(commands, cmd)
+			}
+		}
+	}
+}
+
+func jsonCommand(v any) (CtxCommand, bool) {
+	obj, ok := v.(map[string]any)
+	if !ok {
+		return CtxCommand{}, false
+	}
+	op, ok := obj["op"].(string)
+	if !ok || op == "" {
+		return CtxCommand{}, false
+	}
+	cmd := CtxCommand{Type: op}
+	for k, val := range obj {
+		if k == "op" {
+			continue
+		}
+		s, ok := jsonAttr(val)
+		if !ok {
+			continue
+		}
+		if k == "content" {
+			cmd.Content = strings.TrimSpace(s)
+			continue
+		}
+		if cmd.Attrs == nil {
+			cmd.Attrs = map[string]string{}
+		}
+		cmd.Attrs[k] = s
+	}
+	return cmd, true
+}
+
+// jsonAttr renders a JSON value as an attribute string: strings as is,
+// numbers and booleans as written, lists of those joined by commas.
+func jsonAttr(v any) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := jsonAttr(item)
+			if !ok {
+				return "", false
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), true
+	}
+	return "", false
+}
+
 type codeRegion struct {
 	start, end int
 }
@@ -127,11 +379,14 @@ func findCodeRegions(text string) []codeRegion {
 	}
 
 	// Find inline code (`...`)
+	fenced := len(regions)
+	next := 0 // the next fenced block
 	i = 0
 	for i < len(text) {
 		// Skip already-found fenced blocks
-		if isInCodeRegion(i, regions) {
-			i++
+		if next < fenced && i >= regions[next].start {
+			i = max(i, regions[next].end)
+			next++
 			continue
 		}
 
@@ -152,14 +407,15 @@ func findCodeRegions(text string) []codeRegion {
 		}
 	}
 
+	sort.SliceStable(regions, func(i, j int) bool { return regions[i].start < regions[j].start })
 	return regions
 }
 
+// isInCodeRegion reports whether pos is in one of regions, which
+// findCodeRegions sorted by start. Inline code can run into a fenced block
+// but never past it, so only the last region starting at or before pos
+// can hold it.
 func isInCodeRegion(pos int, regions []codeRegion) bool {
-	for _, r := range regions {
-		if pos >= r.start && pos < r.end {
-			return true
-		}
-	}
-	return false
+	i := sort.Search(len(regions), func(i int) bool { return regions[i].start > pos })
+	return i > 0 && pos < regions[i-1].end
 }
@@ -0,0 +1,34 @@
+package hook
+
+import (
+	"testing"
+)
+
+func FuzzParseCtxCommands(f *testing.F) {
+	f.Add(`<ctx:remember type="fact" tags="project:x">The API uses OAuth.</ctx:remember>`)
+	f.Add(`<ctx:recall query="type:decision"/>`)
+	f.Add(`<ctx:recall query=type:fact/>`)
+	f.Add(`<ctx:remember type='fact'>single quotes</ctx:remember>`)
+	f.Add(`<ctx:summarize nodes="01A,01B">outer <ctx:summarize nodes="01C">inner</ctx:summarize></ctx:summarize>`)
+	f.Add(`<ctx:link from="01A" to="01B" type="DEPENDS_ON"/>`)
+	f.Add("```\n<ctx:remember type=\"fact\">in a fence</ctx:remember>\n```")
+	f.Add("`<ctx:recall query=\"x\"/>` inline")
+	f.Add("```ctx\n{\"type\":\"remember\",\"attrs\":{\"type\":\"fact\"},\"content\":\"json\"}\n```")
+	f.Add("```ctx\n[{\"type\":\"recall\",\"attrs\":{\"query\":\"x\"}}, 1, null]\n```")
+	f.Add("```ctx\n{")
+	f.Add(`<ctx:remember type="fact">unclosed`)
+	f.Add(`<ctx:remember type="unterminated>x</ctx:remember>`)
+	f.Add(`</ctx:remember>`)
+	f.Add("```")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, input string) {
+		// Model output is untrusted: parsing must never panic, and always
+		// returns a list, even when it holds no commands
+		for _, parse := range []func(string) []CtxCommand{ParseCtxCommands, ParseCtxCommandsStrict} {
+			if parse(input) == nil {
+				t.Fatalf("nil commands for %q", input)
+			}
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package hook
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -207,6 +208,169 @@ The auth system uses OIDC with custom claims.
 		t.Run(tc.name, func(t *testing.T) {
 			got := ParseCtxCommands(tc.input)
 			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.want, ParseCtxCommandsStrict(tc.input), "strict mode")
 		})
 	}
 }
+
+func TestParseCtxCommands_Lenient(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		want   []CtxCommand
+		strict []CtxCommand
+	}{
+		{
+			name:   "single-quoted attributes",
+			input:  `<ctx:remember type='fact' tags='tier:reference'>Uses OAuth</ctx:remember>`,
+			want:   []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact", "tags": "tier:reference"}, Content: "Uses OAuth"}},
+			strict: []CtxCommand{},
+		},
+		{
+			name:   "unquoted attributes",
+			input:  `<ctx:link from=01HQ1234 to=01HQ5678 type=DEPENDS_ON/>`,
+			want:   []CtxCommand{{Type: "link", Attrs: map[string]string{"from": "01HQ1234", "to": "01HQ5678", "type": "DEPENDS_ON"}}},
+			strict: []CtxCommand{},
+		},
+		{
+			name:   "whitespace around equals and between attributes",
+			input:  "<ctx:recall\n  query = \"type:fact AND tag:auth\"\n/>",
+			want:   []CtxCommand{{Type: "recall", Attrs: map[string]string{"query": "type:fact AND tag:auth"}}},
+			strict: []CtxCommand{},
+		},
+		{
+			name:  "mixed quoting keeps quotes of the other kind",
+			input: `<ctx:remember tags="project:x" type='decision' note='say "hi"'>Chose Go</ctx:remember>`,
+			want: []CtxCommand{{Type: "remember", Attrs: map[string]string{"tags": "project:x", "type": "decision", "note": `say "hi"`},
+				Content: "Chose Go"}},
+			strict: []CtxCommand{},
+		},
+		{
+			name:  "nested command of the same type",
+			input: `<ctx:remember type="pattern">Wrap examples: <ctx:remember type="fact">x</ctx:remember> stays literal</ctx:remember>`,
+			want: []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "pattern"},
+				Content: `Wrap examples: <ctx:remember type="fact">x</ctx:remember> stays literal`}},
+			strict: []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "x"}},
+		},
+		{
+			name:  "nested command of another type is still run",
+			input: `<ctx:remember type="fact">See <ctx:recall query="tag:auth"/> for more</ctx:remember>`,
+			want: []CtxCommand{
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: `See <ctx:recall query="tag:auth"/> for more`},
+				{Type: "recall", Attrs: map[string]string{"query": "tag:auth"}},
+			},
+		},
+		{
+			name:  "commands after a nested one",
+			input: `<ctx:remember type="fact">a <ctx:remember type="fact">b</ctx:remember></ctx:remember> <ctx:remember type="fact">c</ctx:remember>`,
+			want: []CtxCommand{
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: `a <ctx:remember type="fact">b</ctx:remember>`},
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "c"},
+			},
+			strict: []CtxCommand{
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "b"},
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "c"},
+			},
+		},
+		{
+			name:   "nested command left unclosed",
+			input:  `<ctx:remember type="fact">a <ctx:remember type="fact">b</ctx:remember>`,
+			want:   []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "b"}},
+			strict: []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "b"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseCtxCommands(tc.input))
+			strict := tc.strict
+			if strict == nil {
+				strict = tc.want
+			}
+			assert.Equal(t, strict, ParseCtxCommandsStrict(tc.input))
+		})
+	}
+}
+
+func TestParseCtxCommands_JSONBlocks(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  []CtxCommand
+	}{
+		{
+			name:  "single object",
+			input: "Storing this.\n```ctx\n{\"op\":\"remember\",\"type\":\"fact\",\"tags\":\"tier:reference\",\"content\":\"Uses <OAuth> & \\\"PKCE\\\"\"}\n```",
+			want: []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact", "tags": "tier:reference"},
+				Content: `Uses <OAuth> & "PKCE"`}},
+		},
+		{
+			name:  "array with list, bool and number values",
+			input: "```ctx json\n[{\"op\":\"summarize\",\"nodes\":[\"01HQ1234\",\"01HQ5678\"],\"archive\":true,\"content\":\"Summary\"},\n {\"op\":\"recall\",\"query\":\"type:fact limit:5\",\"depth\":2}]\n```",
+			want: []CtxCommand{
+				{Type: "summarize", Attrs: map[string]string{"nodes": "01HQ1234,01HQ5678", "archive": "true"}, Content: "Summary"},
+				{Type: "recall", Attrs: map[string]string{"query": "type:fact limit:5", "depth": "2"}},
+			},
+		},
+		{
+			name: "objects one after another, in order with XML commands",
+			input: "<ctx:status/>\n```ctx\n{\"op\":\"link\",\"from\":\"01HQ1234\",\"to\":\"01HQ5678\"}\n{\"op\":\"status\"}\n```\n" +
+				`<ctx:remember type="fact">after</ctx:remember>`,
+			want: []CtxCommand{
+				{Type: "status"},
+				{Type: "link", Attrs: map[string]string{"from": "01HQ1234", "to": "01HQ5678"}},
+				{Type: "status"},
+				{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "after"},
+			},
+		},
+		{
+			name:  "invalid JSON stops the block",
+			input: "```ctx\n{\"op\":\"status\"}\n{\"op\": remember}\n{\"op\":\"status\"}\n```",
+			want:  []CtxCommand{{Type: "status"}},
+		},
+		{
+			name:  "objects without op are skipped",
+			input: "```ctx\n[{\"type\":\"fact\"}, {\"op\":\"status\"}]\n```",
+			want:  []CtxCommand{{Type: "status"}},
+		},
+		{
+			name:  "other fenced blocks are ignored",
+			input: "```json\n{\"op\":\"status\"}\n```\n```ctxfoo\n{\"op\":\"status\"}\n```",
+			want:  []CtxCommand{},
+		},
+		{
+			name:  "unclosed block is ignored",
+			input: "```ctx\n{\"op\":\"status\"}\n",
+			want:  []CtxCommand{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseCtxCommands(tc.input))
+			assert.Equal(t, tc.want, ParseCtxCommandsStrict(tc.input), "strict mode")
+		})
+	}
+}
+
+func TestParseCtxCommands_Adversarial(t *testing.T) {
+	t.Run("close tag in an attribute", func(t *testing.T) {
+		input := `<ctx:remember type="fact" note="</ctx:remember>">body</ctx:remember>`
+		want := []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact", "note": "</ctx:remember>"}, Content: "body"}}
+		assert.Equal(t, want, ParseCtxCommands(input))
+		assert.Equal(t, want, ParseCtxCommandsStrict(input), "strict mode")
+	})
+
+	// Thousands of unclosed tags or backticks once took seconds to parse
+	t.Run("unbalanced tags", func(t *testing.T) {
+		input := strings.Repeat(`<ctx:remember type="fact">`, 5000) + "body</ctx:remember>"
+		want := []CtxCommand{{Type: "remember", Attrs: map[string]string{"type": "fact"}, Content: "body"}}
+		assert.Equal(t, want, ParseCtxCommands(input))
+		assert.Equal(t, want, ParseCtxCommandsStrict(input), "strict mode")
+	})
+
+	t.Run("many code spans", func(t *testing.T) {
+		input := strings.Repeat("`<ctx:status/>` ```\n<ctx:status/>\n```\n", 5000) + "<ctx:status/>"
+		assert.Equal(t, []CtxCommand{{Type: "status"}}, ParseCtxCommands(input))
+	})
+}
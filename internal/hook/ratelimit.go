@@ -0,0 +1,175 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+)
+
+// RateLimitedTag marks the node holding remembers that the hook rate limit
+// held back.
+const RateLimitedTag = "rate-limited"
+
+// rememberRateKey is the pending key holding the limiter state. Each hook
+// runs in its own process, so the window lives in the database.
+const rememberRateKey = "hook_remember_rate"
+
+// maxHeldLines caps how many held-back remembers are listed in the overflow
+// node; beyond that only the count grows.
+const maxHeldLines = 500
+
+const moreLinePrefix = "- … and "
+
+type rateState struct {
+	WindowStart  time.Time `json:"window_start"`
+	Count        int       `json:"count"`
+	Held         int       `json:"held"`
+	OverflowNode string    `json:"overflow_node,omitempty"`
+}
+
+// rememberLimiter allows at most limit hook remembers per minute. Remembers
+// over the limit are held back and summarized into one review node, so a
+// model stuck in a loop can't flood the store.
+type rememberLimiter struct {
+	d     db.Store
+	limit int
+	state rateState
+	held  []CtxCommand
+}
+
+func newRememberLimiter(d db.Store) *rememberLimiter {
+	l := &rememberLimiter{d: d, limit: config.Load().Hooks.MaxRemembersPerMinute}
+	if l.limit <= 0 {
+		return l
+	}
+	if raw, err := d.GetPending(rememberRateKey); err == nil {
+		_ = json.Unmarshal([]byte(raw), &l.state)
+	}
+	if now := time.Now(); now.Sub(l.state.WindowStart) >= time.Minute {
+		l.state.WindowStart = now
+		l.state.Count = 0
+	}
+	return l
+}
+
+// allow counts a remember against the current window, reporting false once
+// the limit is reached.
+func (l *rememberLimiter) allow() bool {
+	if l.limit <= 0 {
+		return true
+	}
+	if l.state.Count >= l.limit {
+		return false
+	}
+	l.state.Count++
+	return true
+}
+
+// storesContent reports whether cmd stores new content and so counts
+// against the limit: a remember, or a supersede with replacement content.
+func storesContent(cmd CtxCommand) bool {
+	return cmd.Type == "remember" || (cmd.Type == "supersede" && cmd.Attrs["new"] == "")
+}
+
+func (l *rememberLimiter) hold(cmd CtxCommand) {
+	l.held = append(l.held, cmd)
+}
+
+// finish saves the window and writes any held-back remembers to the
+// overflow node, reusing it while it is still awaiting review.
+func (l *rememberLimiter) finish() error {
+	if l.limit <= 0 {
+		return nil
+	}
+	if len(l.held) > 0 {
+		if err := l.writeOverflow(); err != nil {
+			return fmt.Errorf("rate limit: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "ctx: hook rate limit reached (%d remembers/minute); held back %d in node %s for review (ctx inbox)\n",
+			l.limit, len(l.held), l.state.OverflowNode)
+	}
+	data, _ := json.Marshal(l.state)
+	return l.d.SetPending(rememberRateKey, string(data))
+}
+
+func (l *rememberLimiter) writeOverflow() error {
+	var existing *db.Node
+	if l.state.OverflowNode != "" {
+		if n, err := l.d.GetNode(l.state.OverflowNode); err == nil && hasTag(n.Tags, ReviewPendingTag) {
+			existing = n
+		}
+	}
+
+	var lines []string
+	if existing != nil {
+		if _, body, ok := strings.Cut(existing.Content, "\n\n"); ok {
+			lines = strings.Split(body, "\n")
+			if last := len(lines) - 1; strings.HasPrefix(lines[last], moreLinePrefix) {
+				lines = lines[:last]
+			}
+		}
+	} else {
+		l.state.Held = 0
+	}
+
+	redact := config.Load().Redact
+	for _, cmd := range l.held {
+		l.state.Held++
+		if len(lines) < maxHeldLines {
+			lines = append(lines, heldLine(cmd, redact))
+		}
+	}
+	if more := l.state.Held - len(lines); more > 0 {
+		lines = append(lines, fmt.Sprintf("%s%d more", moreLinePrefix, more))
+	}
+
+	content := fmt.Sprintf("Held back %d remembers over the hook rate limit (%d per minute). "+
+		"Re-add anything worth keeping, then reject this node with ctx inbox.\n\n%s",
+		l.state.Held, l.limit, strings.Join(lines, "\n"))
+
+	if existing != nil {
+		_, err := l.d.UpdateNode(existing.ID, db.UpdateNodeInput{Content: &content})
+		return err
+	}
+	node, err := l.d.CreateNode(db.CreateNodeInput{
+		Type:    "observation",
+		Content: content,
+		Tags:    []string{"tier:off-context", RateLimitedTag, ReviewPendingTag},
+	})
+	if err != nil {
+		return err
+	}
+	l.state.OverflowNode = node.ID
+	return nil
+}
+
+// heldLine renders a held-back remember as a one-line list item.
+func heldLine(cmd CtxCommand, redact func(string) string) string {
+	first, _, _ := strings.Cut(strings.TrimSpace(redact(cmd.Content)), "\n")
+	if r := []rune(first); len(r) > 200 {
+		first = string(r[:200]) + "…"
+	}
+	label := cmd.Attrs["type"]
+	if cmd.Type == "supersede" {
+		label = "supersede " + cmd.Attrs["old"]
+	}
+	line := fmt.Sprintf("- [%s] %s", label, first)
+	if tags := cmd.Attrs["tags"]; tags != "" {
+		line += " (" + tags + ")"
+	}
+	return line
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// recallQueriesKey holds the recall queries waiting for the next prompt,
+// as a JSON list in the order they were written.
+const recallQueriesKey = "recall_queries"
+
+// legacyRecallKey held the single waiting recall query of older versions;
+// TakeRecalls still picks it up so a recall queued before an upgrade runs.
+const legacyRecallKey = "recall_query"
+
+// queueRecall adds q to the recall queries for prompt-submit to run. A
+// query already waiting is not queued twice.
+func queueRecall(d db.Store, q string) error {
+	queries, err := pendingRecalls(d)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(queries, q) {
+		return nil
+	}
+	data, _ := json.Marshal(append(queries, q))
+	return d.SetPending(recallQueriesKey, string(data))
+}
+
+// TakeRecalls returns the waiting recall queries, oldest first, and clears
+// them, even when they cannot be read.
+func TakeRecalls(d db.Store) ([]string, error) {
+	queries, err := pendingRecalls(d)
+	_ = d.DeletePending(recallQueriesKey)
+	if err != nil {
+		return nil, err
+	}
+	if legacy, err := d.GetPending(legacyRecallKey); err == nil && legacy != "" {
+		if !slices.Contains(queries, legacy) {
+			queries = append([]string{legacy}, queries...)
+		}
+		_ = d.DeletePending(legacyRecallKey)
+	}
+	return queries, nil
+}
+
+func pendingRecalls(d db.Store) ([]string, error) {
+	val, err := d.GetPending(recallQueriesKey)
+	if errors.Is(err, db.ErrNotFound) || val == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var queries []string
+	if err := json.Unmarshal([]byte(val), &queries); err != nil {
+		return nil, fmt.Errorf("recall: corrupt %s: %w", recallQueriesKey, err)
+	}
+	return queries, nil
+}
@@ -0,0 +1,115 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// currentTaskKey holds the name of the task in progress.
+const currentTaskKey = "current_task"
+
+// taskPinsKey holds the IDs of the nodes pinned to the current task, as a
+// JSON list in the order they were pinned.
+const taskPinsKey = "task_pins"
+
+// ErrNoTask is returned when nodes are pinned without a task in progress.
+var ErrNoTask = errors.New("no task in progress (start one with <ctx:task name=\"...\" action=\"start\"/> or ctx_task)")
+
+// CurrentTask returns the name of the task in progress, or "" when there
+// is none.
+func CurrentTask(d db.Store) (string, error) {
+	name, err := d.GetPending(currentTaskKey)
+	if errors.Is(err, db.ErrNotFound) {
+		return "", nil
+	}
+	return name, err
+}
+
+// StartTask makes name the task in progress. Switching to another task
+// drops the previous task's pins; restarting the same one keeps them.
+func StartTask(d db.Store, name string) error {
+	current, err := CurrentTask(d)
+	if err != nil {
+		return err
+	}
+	if current != name {
+		_ = d.DeletePending(taskPinsKey)
+	}
+	return d.SetPending(currentTaskKey, name)
+}
+
+// EndTask ends the task named name, if it is the one in progress, and
+// unpins its nodes.
+func EndTask(d db.Store, name string) error {
+	current, err := CurrentTask(d)
+	if err != nil || current != name {
+		return err
+	}
+	_ = d.DeletePending(taskPinsKey)
+	return d.DeletePending(currentTaskKey)
+}
+
+// PinToTask pins nodes to the task in progress, so they are composed into
+// working context at every session start until it ends. It returns the
+// pinned IDs, including ones pinned before.
+func PinToTask(d db.Store, ids ...string) ([]string, error) {
+	current, err := CurrentTask(d)
+	if err != nil {
+		return nil, err
+	}
+	if current == "" {
+		return nil, ErrNoTask
+	}
+	pins, err := TaskPins(d)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if !slices.Contains(pins, id) {
+			pins = append(pins, id)
+		}
+	}
+	return pins, setTaskPins(d, pins)
+}
+
+// UnpinFromTask removes nodes from the current task's pins and returns the
+// IDs still pinned.
+func UnpinFromTask(d db.Store, ids ...string) ([]string, error) {
+	pins, err := TaskPins(d)
+	if err != nil {
+		return nil, err
+	}
+	pins = slices.DeleteFunc(pins, func(id string) bool {
+		return slices.Contains(ids, id)
+	})
+	return pins, setTaskPins(d, pins)
+}
+
+// TaskPins returns the IDs of the nodes pinned to the current task, oldest
+// first.
+func TaskPins(d db.Store) ([]string, error) {
+	val, err := d.GetPending(taskPinsKey)
+	if errors.Is(err, db.ErrNotFound) || val == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pins []string
+	if err := json.Unmarshal([]byte(val), &pins); err != nil {
+		return nil, fmt.Errorf("task: corrupt %s: %w", taskPinsKey, err)
+	}
+	return pins, nil
+}
+
+func setTaskPins(d db.Store, pins []string) error {
+	if len(pins) == 0 {
+		return d.DeletePending(taskPinsKey)
+	}
+	data, _ := json.Marshal(pins)
+	return d.SetPending(taskPinsKey, string(data))
+}
@@ -0,0 +1,434 @@
+// Package ingest splits files into chunks and stores them as source nodes.
+//
+// Chunks are content-addressed: each one carries a SHA-256 hash of its
+// content in node metadata, so re-ingesting a file reuses the existing
+// node for any chunk whose content has not changed.
+package ingest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/provenance"
+	"github.com/zate/ctx/internal/token"
+)
+
+// DefaultChunkTokens is the target maximum size of a chunk.
+const DefaultChunkTokens = 1000
+
+// Chunk statuses reported by Ingest.
+const (
+	StatusNew       = "new"
+	StatusChanged   = "changed"
+	StatusUnchanged = "unchanged"
+)
+
+// Options controls how a file is ingested.
+type Options struct {
+	Tags        []string
+	ChunkTokens int
+}
+
+// SourceMeta is the metadata stored on every ingested source chunk.
+type SourceMeta struct {
+	SourceFile  string `json:"source_file"`
+	Filename    string `json:"filename"`
+	ChunkIndex  int    `json:"chunk_index"`
+	ChunkCount  int    `json:"chunk_count"`
+	ContentHash string `json:"content_hash"`
+	// SharedWith lists the other files that contain the chunk. They reuse
+	// this node rather than storing a copy of it.
+	SharedWith []FileRef `json:"shared_with,omitempty"`
+}
+
+// FileRef places a shared chunk in one of the files containing it.
+type FileRef struct {
+	File       string `json:"file"`
+	ChunkIndex int    `json:"chunk_index"`
+	ChunkCount int    `json:"chunk_count"`
+}
+
+// Files lists every file containing the chunk, SourceFile first.
+func (m SourceMeta) Files() []FileRef {
+	refs := []FileRef{{File: m.SourceFile, ChunkIndex: m.ChunkIndex, ChunkCount: m.ChunkCount}}
+	return append(refs, m.SharedWith...)
+}
+
+// ChunkResult describes what happened to a single chunk.
+type ChunkResult struct {
+	Index  int    `json:"index"`
+	NodeID string `json:"node_id"`
+	Hash   string `json:"hash"`
+	Tokens int    `json:"tokens"`
+	Status string `json:"status"`
+}
+
+// Report summarizes an ingest run.
+type Report struct {
+	File      string        `json:"file"`
+	Chunks    []ChunkResult `json:"chunks"`
+	New       int           `json:"new"`
+	Changed   int           `json:"changed"`
+	Unchanged int           `json:"unchanged"`
+	Relinked  int           `json:"relinked"`
+}
+
+// Hash returns the hex-encoded SHA-256 of a chunk's content.
+func Hash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+// ParseMeta decodes source chunk metadata. Nodes ingested before chunking
+// existed have no content hash; ok is false for those.
+func ParseMeta(metadata string) (SourceMeta, bool) {
+	var m SourceMeta
+	if metadata == "" || json.Unmarshal([]byte(metadata), &m) != nil {
+		return m, false
+	}
+	return m, m.ContentHash != ""
+}
+
+// File reads path from disk and ingests it.
+func File(d db.Store, path string, opts Options) (*Report, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	return Ingest(d, abs, string(content), opts)
+}
+
+// Ingest chunks content and stores each chunk as a source node, reusing any
+// existing source node with the same content hash and recording the file
+// on it. The file's previous chunks whose content is no longer in it are
+// superseded, unless another file still contains them.
+func Ingest(d db.Store, path, content string, opts Options) (*Report, error) {
+	// Hash normalized chunks so hashes match the content the store keeps
+	chunks := Chunk(db.NormalizeContent(content), opts.ChunkTokens)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("nothing to ingest: %s is empty", path)
+	}
+
+	idx, err := loadIndex(d)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{File: path}
+	reused := make(map[string]bool)    // node IDs kept by this run
+	newHashes := make(map[string]bool) // hashes of the new chunk set
+	var created []int                  // indexes in report.Chunks of new nodes
+	for i, chunk := range chunks {
+		hash := Hash(chunk)
+		result := ChunkResult{Index: i, Hash: hash, Tokens: token.Estimate(chunk)}
+		newHashes[hash] = true
+
+		if nodeID, ok := idx.byHash[hash]; ok {
+			reused[nodeID] = true
+			if err := idx.share(d, nodeID, FileRef{File: path, ChunkIndex: i, ChunkCount: len(chunks)}); err != nil {
+				return nil, err
+			}
+			for _, tag := range opts.Tags {
+				if err := d.AddTag(nodeID, tag); err != nil {
+					return nil, err
+				}
+			}
+			result.NodeID = nodeID
+			result.Status = StatusUnchanged
+			report.Unchanged++
+			report.Chunks = append(report.Chunks, result)
+			continue
+		}
+
+		meta := SourceMeta{
+			SourceFile:  path,
+			Filename:    filepath.Base(path),
+			ChunkIndex:  i,
+			ChunkCount:  len(chunks),
+			ContentHash: hash,
+		}
+		data, _ := json.Marshal(meta)
+		node, err := d.CreateNode(db.CreateNodeInput{
+			Type:     "source",
+			Content:  chunk,
+			Metadata: string(data),
+			Tags:     opts.Tags,
+		})
+		if err != nil {
+			return nil, err
+		}
+		idx.byHash[hash] = node.ID
+		idx.nodes[node.ID] = &indexedNode{meta: meta, raw: string(data)}
+
+		result.NodeID = node.ID
+		result.Status = StatusNew
+		report.New++
+		created = append(created, len(report.Chunks))
+		report.Chunks = append(report.Chunks, result)
+	}
+
+	// Supersede the file's previous chunks that are no longer part of it.
+	// Each is replaced by a chunk created in this run, in file order, so
+	// those are reported as changed; once they run out, as when the file
+	// shrank, by the chunk now nearest its old position. A chunk another
+	// file still contains stays active and only forgets this file.
+	var stale []sourceChunk
+	for _, c := range idx.byFile[path] {
+		if newHashes[c.hash] || reused[c.id] {
+			continue
+		}
+		shared, err := idx.unshare(d, c.id, path)
+		if err != nil {
+			return nil, err
+		}
+		if !shared {
+			stale = append(stale, c)
+		}
+	}
+	for i, c := range stale {
+		var newID string
+		if i < len(created) {
+			r := &report.Chunks[created[i]]
+			r.Status = StatusChanged
+			report.New--
+			report.Changed++
+			newID = r.NodeID
+		} else {
+			newID = report.Chunks[min(c.index, len(report.Chunks)-1)].NodeID
+		}
+		relinked, err := supersede(d, c.id, newID)
+		if err != nil {
+			return nil, err
+		}
+		report.Relinked += relinked
+	}
+
+	return report, nil
+}
+
+// supersede marks oldID as superseded by newID, carries its tags across,
+// flags anything derived from the old chunk as stale, and points those
+// derived nodes at the new chunk. It returns the number of DERIVED_FROM
+// edges relinked.
+func supersede(d db.Store, oldID, newID string) (int, error) {
+	if err := d.Supersede(oldID, newID, true); err != nil {
+		return 0, fmt.Errorf("failed to supersede %s: %w", oldID, err)
+	}
+	if _, err := provenance.MarkStale(d, oldID); err != nil {
+		return 0, err
+	}
+
+	edges, err := d.GetEdgesTo(oldID)
+	if err != nil {
+		return 0, err
+	}
+	relinked := 0
+	for _, e := range edges {
+		if e.Type != "DERIVED_FROM" {
+			continue
+		}
+		if _, err := d.CreateEdge(e.FromID, newID, "DERIVED_FROM"); err != nil {
+			return relinked, err
+		}
+		relinked++
+	}
+	return relinked, nil
+}
+
+// sourceChunk is an active source node as it was recorded for a file.
+type sourceChunk struct {
+	id    string
+	index int
+	hash  string
+}
+
+type sourceIndex struct {
+	byHash map[string]string        // content hash -> node ID
+	byFile map[string][]sourceChunk // file -> its chunks, in file order
+	nodes  map[string]*indexedNode  // node ID -> its metadata
+}
+
+// indexedNode is a source node's parsed metadata, and the JSON it came
+// from, which may hold other fields.
+type indexedNode struct {
+	meta SourceMeta
+	raw  string
+}
+
+// share records that file contains the chunk nodeID.
+func (idx *sourceIndex) share(d db.Store, nodeID string, file FileRef) error {
+	n := idx.nodes[nodeID]
+	for _, ref := range n.meta.Files() {
+		if ref.File == file.File {
+			return nil
+		}
+	}
+	n.meta.SharedWith = append(n.meta.SharedWith, file)
+	return idx.save(d, nodeID)
+}
+
+// unshare removes file from the files containing the chunk nodeID. It
+// reports whether any other file still contains it; if none does the
+// metadata is left alone, for the chunk is about to be superseded.
+func (idx *sourceIndex) unshare(d db.Store, nodeID, file string) (bool, error) {
+	n := idx.nodes[nodeID]
+	var rest []FileRef
+	for _, ref := range n.meta.Files() {
+		if ref.File != file {
+			rest = append(rest, ref)
+		}
+	}
+	if len(rest) == 0 {
+		return false, nil
+	}
+	n.meta.SourceFile, n.meta.Filename = rest[0].File, filepath.Base(rest[0].File)
+	n.meta.ChunkIndex, n.meta.ChunkCount = rest[0].ChunkIndex, rest[0].ChunkCount
+	n.meta.SharedWith = rest[1:]
+	return true, idx.save(d, nodeID)
+}
+
+// save writes a node's source metadata over the fields it had, keeping
+// any others.
+func (idx *sourceIndex) save(d db.Store, nodeID string) error {
+	n := idx.nodes[nodeID]
+	fields := map[string]any{}
+	_ = json.Unmarshal([]byte(n.raw), &fields)
+	delete(fields, "shared_with")
+	data, _ := json.Marshal(n.meta)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	metadata := string(data)
+	if _, err := d.UpdateNode(nodeID, db.UpdateNodeInput{Metadata: &metadata}); err != nil {
+		return fmt.Errorf("failed to record the files sharing %s: %w", nodeID, err)
+	}
+	n.raw = metadata
+	return nil
+}
+
+// loadIndex builds hash and file lookups over all active source nodes.
+func loadIndex(d db.Store) (*sourceIndex, error) {
+	nodes, err := d.ListNodes(db.ListOptions{Type: "source"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source nodes: %w", err)
+	}
+
+	idx := &sourceIndex{
+		byHash: make(map[string]string),
+		byFile: make(map[string][]sourceChunk),
+		nodes:  make(map[string]*indexedNode),
+	}
+	for _, n := range nodes {
+		meta, ok := ParseMeta(n.Metadata)
+		if !ok {
+			continue
+		}
+		if _, exists := idx.byHash[meta.ContentHash]; !exists {
+			idx.byHash[meta.ContentHash] = n.ID
+		}
+		idx.nodes[n.ID] = &indexedNode{meta: meta, raw: n.Metadata}
+		for _, ref := range meta.Files() {
+			idx.byFile[ref.File] = append(idx.byFile[ref.File], sourceChunk{n.ID, ref.ChunkIndex, meta.ContentHash})
+		}
+	}
+	for _, chunks := range idx.byFile {
+		sort.SliceStable(chunks, func(i, j int) bool { return chunks[i].index < chunks[j].index })
+	}
+	return idx, nil
+}
+
+// Chunk splits content into chunks of at most maxTokens estimated tokens.
+// Paragraphs are kept together where possible; oversized paragraphs are
+// split on line boundaries, and oversized lines are split on rune
+// boundaries. A non-positive maxTokens uses DefaultChunkTokens.
+func Chunk(content string, maxTokens int) []string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	var chunks []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+	}
+
+	for _, para := range strings.Split(content, "\n\n") {
+		para = strings.Trim(para, "\n")
+		if strings.TrimSpace(para) == "" {
+			continue
+		}
+		for _, piece := range splitOversized(para, maxTokens) {
+			if cur.Len() > 0 && token.Estimate(cur.String()+"\n\n"+piece) > maxTokens {
+				flush()
+			}
+			if cur.Len() > 0 {
+				cur.WriteString("\n\n")
+			}
+			cur.WriteString(piece)
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+func splitOversized(para string, maxTokens int) []string {
+	if token.Estimate(para) <= maxTokens {
+		return []string{para}
+	}
+
+	var pieces []string
+	var cur strings.Builder
+	for _, line := range strings.Split(para, "\n") {
+		for _, part := range splitLine(line, maxTokens*4) {
+			if cur.Len() > 0 && token.Estimate(cur.String()+"\n"+part) > maxTokens {
+				pieces = append(pieces, cur.String())
+				cur.Reset()
+			}
+			if cur.Len() > 0 {
+				cur.WriteString("\n")
+			}
+			cur.WriteString(part)
+		}
+	}
+	if cur.Len() > 0 {
+		pieces = append(pieces, cur.String())
+	}
+	return pieces
+}
+
+func splitLine(line string, maxBytes int) []string {
+	var parts []string
+	for len(line) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = maxBytes
+		}
+		parts = append(parts, line[:cut])
+		line = line[cut:]
+	}
+	return append(parts, line)
+}
@@ -0,0 +1,344 @@
+package ingest_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/ingest"
+	"github.com/zate/ctx/testutil"
+)
+
+func paragraphs(n int) string {
+	var parts []string
+	for i := 0; i < n; i++ {
+		parts = append(parts, strings.Repeat(string(rune('a'+i)), 40))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func TestChunk_KeepsParagraphsTogether(t *testing.T) {
+	chunks := ingest.Chunk(paragraphs(4), 25)
+
+	require.Len(t, chunks, 2)
+	assert.Equal(t, strings.Repeat("a", 40)+"\n\n"+strings.Repeat("b", 40), chunks[0])
+}
+
+func TestChunk_SplitsOversizedParagraph(t *testing.T) {
+	chunks := ingest.Chunk(strings.Repeat("x", 100), 10)
+
+	require.Len(t, chunks, 3)
+	for _, c := range chunks {
+		assert.LessOrEqual(t, len(c), 40)
+	}
+}
+
+func TestChunk_Empty(t *testing.T) {
+	assert.Empty(t, ingest.Chunk("\n\n  \n", 10))
+}
+
+func TestIngest_ReusesUnchangedChunks(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	first, err := ingest.Ingest(d, "/notes.md", paragraphs(4), ingest.Options{ChunkTokens: 25})
+	require.NoError(t, err)
+	assert.Equal(t, 2, first.New)
+	assert.Equal(t, 0, first.Unchanged)
+
+	second, err := ingest.Ingest(d, "/notes.md", paragraphs(4), ingest.Options{ChunkTokens: 25})
+	require.NoError(t, err)
+	assert.Equal(t, 0, second.New)
+	assert.Equal(t, 2, second.Unchanged)
+	assert.Equal(t, first.Chunks[0].NodeID, second.Chunks[0].NodeID)
+
+	nodes, err := d.ListNodes(db.ListOptions{Type: "source"})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestIngest_ReportsChangedChunks(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := ingest.Ingest(d, "/notes.md", paragraphs(4), ingest.Options{ChunkTokens: 25})
+	require.NoError(t, err)
+
+	edited := paragraphs(2) + "\n\n" + strings.Repeat("z", 40) + "\n\n" + strings.Repeat("d", 40)
+	report, err := ingest.Ingest(d, "/notes.md", edited, ingest.Options{ChunkTokens: 25})
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.Unchanged)
+	assert.Equal(t, 1, report.Changed)
+	assert.Equal(t, 0, report.New)
+}
+
+func TestIngest_SharesChunksAcrossFiles(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	a, err := ingest.Ingest(d, "/a.md", "shared paragraph", ingest.Options{})
+	require.NoError(t, err)
+	b, err := ingest.Ingest(d, "/b.md", "shared paragraph", ingest.Options{Tags: []string{"project:b"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, a.Chunks[0].NodeID, b.Chunks[0].NodeID)
+	assert.Equal(t, ingest.StatusUnchanged, b.Chunks[0].Status)
+
+	tags, err := d.GetTags(a.Chunks[0].NodeID)
+	require.NoError(t, err)
+	assert.Contains(t, tags, "project:b")
+}
+
+func TestIngest_StoresMetadata(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	report, err := ingest.Ingest(d, "/docs/readme.md", "hello world", ingest.Options{})
+	require.NoError(t, err)
+
+	node, err := d.GetNode(report.Chunks[0].NodeID)
+	require.NoError(t, err)
+	meta, ok := ingest.ParseMeta(node.Metadata)
+	require.True(t, ok)
+	assert.Equal(t, "/docs/readme.md", meta.SourceFile)
+	assert.Equal(t, "readme.md", meta.Filename)
+	assert.Equal(t, 0, meta.ChunkIndex)
+	assert.Equal(t, 1, meta.ChunkCount)
+	assert.Equal(t, ingest.Hash("hello world"), meta.ContentHash)
+}
+
+func TestIngest_EmptyContent(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := ingest.Ingest(d, "/empty.md", "", ingest.Options{})
+	assert.Error(t, err)
+}
+
+func TestIngest_SupersedesChangedChunks(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	first, err := ingest.Ingest(d, "/notes.md", "original text", ingest.Options{Tags: []string{"project:x"}})
+	require.NoError(t, err)
+	oldID := first.Chunks[0].NodeID
+
+	summary, err := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "summary of notes"})
+	require.NoError(t, err)
+	_, err = d.CreateEdge(summary.ID, oldID, "DERIVED_FROM")
+	require.NoError(t, err)
+
+	second, err := ingest.Ingest(d, "/notes.md", "revised text", ingest.Options{})
+	require.NoError(t, err)
+	require.Equal(t, 1, second.Changed)
+	assert.Equal(t, 1, second.Relinked)
+	newID := second.Chunks[0].NodeID
+
+	old, err := d.GetNode(oldID)
+	require.NoError(t, err)
+	require.NotNil(t, old.SupersededBy)
+	assert.Equal(t, newID, *old.SupersededBy)
+
+	tags, err := d.GetTags(newID)
+	require.NoError(t, err)
+	assert.Contains(t, tags, "project:x")
+
+	edges, err := d.GetEdgesFrom(summary.ID)
+	require.NoError(t, err)
+	var targets []string
+	for _, e := range edges {
+		targets = append(targets, e.ToID)
+	}
+	assert.Contains(t, targets, newID)
+}
+
+func TestCreateNode_SplitsOversizedContent(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	content := strings.Repeat("alpha beta gamma delta.\n\n", 200)
+	parent, chunks, err := ingest.CreateNode(d, db.CreateNodeInput{
+		Type:    "fact",
+		Content: content,
+		Tags:    []string{"tier:pinned", "project:x"},
+	}, 500)
+	require.NoError(t, err)
+	require.Greater(t, chunks, 1)
+
+	assert.Contains(t, parent.Content, "Split into")
+	assert.LessOrEqual(t, parent.TokenEstimate, 500)
+	assert.Contains(t, parent.Tags, "tier:pinned")
+
+	edges, err := d.GetEdgesTo(parent.ID)
+	require.NoError(t, err)
+	assert.Len(t, edges, chunks)
+	for _, e := range edges {
+		assert.Equal(t, "CHILD_OF", e.Type)
+		child, err := d.GetNode(e.FromID)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, child.TokenEstimate, 500)
+		assert.Contains(t, child.Tags, "tier:reference")
+		assert.Contains(t, child.Tags, "project:x")
+		assert.NotContains(t, child.Tags, "tier:pinned")
+	}
+
+	found, err := ingest.FindSplit(d, "fact", content, 500)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, parent.ID, found.ID)
+
+	found, err = ingest.FindSplit(d, "decision", content, 500)
+	require.NoError(t, err)
+	assert.Nil(t, found, "a stub of another type is not a duplicate")
+	found, err = ingest.FindSplit(d, "fact", content, 0)
+	require.NoError(t, err)
+	assert.Nil(t, found, "content that is not split has no stub")
+}
+
+func TestCreateNode_SplitIsAllOrNothing(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	content := strings.Repeat("alpha beta gamma delta.\n\n", 200)
+	_, _, err := ingest.CreateNode(d, db.CreateNodeInput{Type: "fact", Content: content, Tags: []string{"bad tag"}}, 500)
+	require.Error(t, err)
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes, "no stub is left without its chunks")
+}
+
+func TestFindSplit_SkipsMalformedMetadata(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	content := strings.Repeat("alpha beta gamma delta.\n\n", 200)
+	parent, _, err := ingest.CreateNode(d, db.CreateNodeInput{Type: "fact", Content: content}, 500)
+	require.NoError(t, err)
+	bad, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "bad"})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET metadata = ? WHERE id = ?", `{"split_hash":`, bad.ID)
+	require.NoError(t, err)
+
+	found, err := ingest.FindSplit(d, "fact", content, 500)
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, parent.ID, found.ID)
+}
+
+func TestCreateNode_SmallContentUnchanged(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	node, chunks, err := ingest.CreateNode(d, db.CreateNodeInput{Type: "fact", Content: "short"}, 500)
+	require.NoError(t, err)
+	assert.Equal(t, 0, chunks)
+	assert.Equal(t, "short", node.Content)
+
+	node, chunks, err = ingest.CreateNode(d, db.CreateNodeInput{Type: "fact", Content: strings.Repeat("x", 10000)}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, chunks)
+	assert.Equal(t, 2500, node.TokenEstimate)
+}
+
+func TestMaxNodeTokens(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CTX_MAX_NODE_TOKENS", "")
+	assert.Equal(t, ingest.DefaultMaxNodeTokens, ingest.MaxNodeTokens())
+
+	t.Setenv("CTX_MAX_NODE_TOKENS", "0")
+	assert.Equal(t, 0, ingest.MaxNodeTokens())
+
+	t.Setenv("CTX_MAX_NODE_TOKENS", "1200")
+	assert.Equal(t, 1200, ingest.MaxNodeTokens())
+}
+
+func para(c rune) string { return strings.Repeat(string(c), 40) }
+
+func TestIngest_SupersedesByContentNotPosition(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	opts := ingest.Options{ChunkTokens: 10}
+
+	first, err := ingest.Ingest(d, "/notes.md", para('a')+"\n\n"+para('b'), opts)
+	require.NoError(t, err)
+	require.Len(t, first.Chunks, 2)
+	aID, bID := first.Chunks[0].NodeID, first.Chunks[1].NodeID
+
+	second, err := ingest.Ingest(d, "/notes.md", para('b')+"\n\n"+para('c'), opts)
+	require.NoError(t, err)
+	require.Len(t, second.Chunks, 2)
+	assert.Equal(t, bID, second.Chunks[0].NodeID)
+	assert.Equal(t, ingest.StatusUnchanged, second.Chunks[0].Status)
+	assert.Equal(t, ingest.StatusChanged, second.Chunks[1].Status)
+	assert.Equal(t, 1, second.Changed)
+	assert.Equal(t, 0, second.New)
+
+	b, err := d.GetNode(bID)
+	require.NoError(t, err)
+	assert.Nil(t, b.SupersededBy, "the kept chunk stays active")
+	a, err := d.GetNode(aID)
+	require.NoError(t, err)
+	require.NotNil(t, a.SupersededBy, "the removed chunk is superseded")
+	assert.Equal(t, second.Chunks[1].NodeID, *a.SupersededBy)
+}
+
+func TestIngest_SupersedesTrailingChunksWhenFileShrinks(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	opts := ingest.Options{ChunkTokens: 10}
+
+	first, err := ingest.Ingest(d, "/notes.md", paragraphs(3), opts)
+	require.NoError(t, err)
+	require.Len(t, first.Chunks, 3)
+
+	second, err := ingest.Ingest(d, "/notes.md", paragraphs(2), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Unchanged)
+	assert.Equal(t, 0, second.Changed+second.New)
+
+	last, err := d.GetNode(first.Chunks[2].NodeID)
+	require.NoError(t, err)
+	require.NotNil(t, last.SupersededBy)
+	assert.Equal(t, first.Chunks[1].NodeID, *last.SupersededBy)
+
+	nodes, err := d.ListNodes(db.ListOptions{Type: "source"})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+}
+
+func TestIngest_KeepsChunksOtherFilesContain(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	opts := ingest.Options{ChunkTokens: 10}
+
+	a, err := ingest.Ingest(d, "/a.md", para('a')+"\n\n"+para('s'), opts)
+	require.NoError(t, err)
+	sharedID := a.Chunks[1].NodeID
+	b, err := ingest.Ingest(d, "/b.md", para('b')+"\n\n"+para('s'), opts)
+	require.NoError(t, err)
+	require.Equal(t, sharedID, b.Chunks[1].NodeID)
+	_, err = ingest.Ingest(d, "/c.md", para('s'), opts)
+	require.NoError(t, err)
+
+	files, err := ingest.TrackedFiles(d, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/a.md", "/b.md", "/c.md"}, files, "files made only of shared chunks are tracked")
+
+	note, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "From the shared chunk"})
+	require.NoError(t, err)
+	_, err = d.CreateEdge(note.ID, sharedID, "DERIVED_FROM")
+	require.NoError(t, err)
+
+	// a.md drops the chunk; b.md and c.md still contain it
+	edited, err := ingest.Ingest(d, "/a.md", para('a')+"\n\n"+para('z'), opts)
+	require.NoError(t, err)
+	assert.Equal(t, 0, edited.Relinked)
+	shared, err := d.GetNode(sharedID)
+	require.NoError(t, err)
+	assert.Nil(t, shared.SupersededBy, "the chunk stays active for the other files")
+	meta, _ := ingest.ParseMeta(shared.Metadata)
+	assert.ElementsMatch(t, []string{"/b.md", "/c.md"}, []string{meta.Files()[0].File, meta.Files()[1].File})
+	assert.Len(t, meta.Files(), 2)
+	edges, err := d.GetEdgesFrom(note.ID)
+	require.NoError(t, err)
+	require.Len(t, edges, 1)
+	assert.Equal(t, sharedID, edges[0].ToID, "dependents stay on the shared chunk")
+
+	// Once no file contains it, it is superseded
+	_, err = ingest.Ingest(d, "/b.md", para('b'), opts)
+	require.NoError(t, err)
+	_, err = ingest.Ingest(d, "/c.md", para('c'), opts)
+	require.NoError(t, err)
+	shared, err = d.GetNode(sharedID)
+	require.NoError(t, err)
+	assert.NotNil(t, shared.SupersededBy)
+}
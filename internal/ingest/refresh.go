@@ -0,0 +1,119 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// RefreshResult summarizes a refresh over previously ingested files.
+type RefreshResult struct {
+	Checked int       `json:"checked"`
+	Updated []*Report `json:"updated"`
+	Missing []string  `json:"missing"`
+}
+
+// TrackedFiles returns the files containing any active ingested chunk,
+// including those whose chunks are all shared with other files, limited to those under dir when dir is non-empty.
+func TrackedFiles(d db.Store, dir string) ([]string, error) {
+	nodes, err := d.ListNodes(db.ListOptions{Type: "source"})
+	if err != nil {
+		return nil, err
+	}
+
+	if dir != "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, n := range nodes {
+		meta, ok := ParseMeta(n.Metadata)
+		if !ok {
+			continue
+		}
+		for _, ref := range meta.Files() {
+			if seen[ref.File] || dir != "" && !withinDir(ref.File, dir) {
+				continue
+			}
+			seen[ref.File] = true
+			files = append(files, ref.File)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Refresh re-ingests every tracked file under dir (all tracked files when
+// dir is empty). Changed chunks supersede the chunks they replace, and
+// DERIVED_FROM edges pointing at replaced chunks are relinked. Files that
+// no longer exist are reported as missing and left untouched.
+func Refresh(d db.Store, dir string, opts Options) (*RefreshResult, error) {
+	files, err := TrackedFiles(d, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefreshResult{}
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			result.Missing = append(result.Missing, path)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Checked++
+
+		report, err := Ingest(d, path, string(content), opts)
+		if err != nil {
+			return nil, err
+		}
+		if report.New > 0 || report.Changed > 0 {
+			result.Updated = append(result.Updated, report)
+		}
+	}
+	return result, nil
+}
+
+// Watch polls tracked files under dir every interval and refreshes them,
+// calling onRefresh whenever a pass updates at least one file. It returns
+// when ctx is cancelled or a refresh fails.
+func Watch(ctx context.Context, d db.Store, dir string, interval time.Duration, opts Options, onRefresh func(*RefreshResult)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := Refresh(d, dir, opts)
+		if err != nil {
+			return err
+		}
+		if len(result.Updated) > 0 && onRefresh != nil {
+			onRefresh(result)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
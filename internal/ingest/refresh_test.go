@@ -0,0 +1,66 @@
+package ingest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/ingest"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestRefresh_DetectsChangedFiles(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.md")
+	b := filepath.Join(dir, "b.md")
+	require.NoError(t, os.WriteFile(a, []byte("alpha"), 0o644))
+	require.NoError(t, os.WriteFile(b, []byte("beta"), 0o644))
+
+	_, err := ingest.File(d, a, ingest.Options{})
+	require.NoError(t, err)
+	_, err = ingest.File(d, b, ingest.Options{})
+	require.NoError(t, err)
+
+	result, err := ingest.Refresh(d, dir, ingest.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Checked)
+	assert.Empty(t, result.Updated)
+
+	require.NoError(t, os.WriteFile(a, []byte("alpha revised"), 0o644))
+	result, err = ingest.Refresh(d, dir, ingest.Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Updated, 1)
+	assert.Equal(t, a, result.Updated[0].File)
+	assert.Equal(t, 1, result.Updated[0].Changed)
+}
+
+func TestRefresh_ReportsMissingFiles(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gone.md")
+	require.NoError(t, os.WriteFile(path, []byte("soon gone"), 0o644))
+
+	_, err := ingest.File(d, path, ingest.Options{})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(path))
+
+	result, err := ingest.Refresh(d, "", ingest.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{path}, result.Missing)
+}
+
+func TestTrackedFiles_LimitsToDir(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, err := ingest.Ingest(d, "/docs/a.md", "a", ingest.Options{})
+	require.NoError(t, err)
+	_, err = ingest.Ingest(d, "/other/b.md", "b", ingest.Options{})
+	require.NoError(t, err)
+
+	files, err := ingest.TrackedFiles(d, "/docs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/docs/a.md"}, files)
+}
@@ -0,0 +1,130 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/token"
+)
+
+// DefaultMaxNodeTokens is the node size above which remembered content is
+// split into chunks.
+const DefaultMaxNodeTokens = config.DefaultMaxNodeTokens
+
+// stubPreviewChars is how much of the original content the parent stub keeps.
+const stubPreviewChars = 300
+
+// SplitMeta is the metadata recorded on the parent stub of a split node.
+type SplitMeta struct {
+	SplitHash  string `json:"split_hash"`
+	ChunkCount int    `json:"chunk_count"`
+}
+
+// ChunkMeta is the metadata recorded on each chunk of a split node.
+type ChunkMeta struct {
+	Parent     string `json:"parent"`
+	ChunkIndex int    `json:"chunk_index"`
+	ChunkCount int    `json:"chunk_count"`
+}
+
+// MaxNodeTokens returns the configured maximum node size in tokens. Set
+// CTX_MAX_NODE_TOKENS, or `max_node_tokens` in ~/.ctx/config.yaml; 0
+// disables splitting.
+func MaxNodeTokens() int {
+	return config.Load().MaxNodeTokens
+}
+
+// CreateNode creates a node, splitting content larger than maxTokens into
+// chunks linked CHILD_OF a parent summary stub. The stub keeps the input's
+// type and tags; chunks drop tier tags in favour of tier:reference so they
+// are reachable by recall without being injected. The stub and its chunks
+// are stored together or not at all. It returns the created node (the stub
+// when split) and the number of chunks (0 when not split). A non-positive
+// maxTokens never splits.
+func CreateNode(d db.Store, input db.CreateNodeInput, maxTokens int) (*db.Node, int, error) {
+	input.Content = db.NormalizeContent(config.Load().Redact(input.Content))
+	if maxTokens <= 0 || token.Estimate(input.Content) <= maxTokens {
+		node, err := d.CreateNode(input)
+		return node, 0, err
+	}
+
+	chunks := Chunk(input.Content, maxTokens)
+	meta, err := splitMetadata(input.Metadata, SplitMeta{SplitHash: Hash(input.Content), ChunkCount: len(chunks)})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	chunkTags := []string{"tier:reference"}
+	for _, t := range input.Tags {
+		if !strings.HasPrefix(t, "tier:") {
+			chunkTags = append(chunkTags, t)
+		}
+	}
+
+	stubID := db.NewID()
+	parts := make([]db.CreateNodeInput, len(chunks))
+	for i, c := range chunks {
+		data, _ := json.Marshal(ChunkMeta{Parent: stubID, ChunkIndex: i, ChunkCount: len(chunks)})
+		parts[i] = db.CreateNodeInput{
+			Type:     input.Type,
+			Content:  c,
+			Metadata: string(data),
+			Tags:     chunkTags,
+		}
+	}
+
+	parent, err := d.CreateSplit(stubID, db.CreateNodeInput{
+		Type:     input.Type,
+		Content:  stubContent(input.Content, len(chunks)),
+		Summary:  input.Summary,
+		Metadata: meta,
+		Tags:     input.Tags,
+	}, parts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return parent, len(chunks), nil
+}
+
+// FindSplit returns the active parent stub of a previously split node with
+// the given type and original content, or nil if there is none. It lets
+// duplicate checks see through splitting. Content that maxTokens would not
+// split has no stub, so it is not looked for.
+func FindSplit(d db.Store, nodeType, content string, maxTokens int) (*db.Node, error) {
+	content = db.NormalizeContent(content)
+	if maxTokens <= 0 || token.Estimate(content) <= maxTokens {
+		return nil, nil
+	}
+	return d.FindByMetadata(nodeType, "split_hash", Hash(content))
+}
+
+// splitMetadata merges SplitMeta into the caller's metadata object, if any.
+func splitMetadata(existing string, meta SplitMeta) (string, error) {
+	fields := map[string]any{}
+	if existing != "" && existing != "{}" {
+		if err := json.Unmarshal([]byte(existing), &fields); err != nil {
+			return "", fmt.Errorf("metadata must be a JSON object: %w", err)
+		}
+	}
+	fields["split_hash"] = meta.SplitHash
+	fields["chunk_count"] = meta.ChunkCount
+	data, err := json.Marshal(fields)
+	return string(data), err
+}
+
+func stubContent(content string, chunks int) string {
+	preview := strings.TrimSpace(content)
+	if len(preview) > stubPreviewChars {
+		cut := stubPreviewChars
+		for cut > 0 && !utf8.RuneStart(preview[cut]) {
+			cut--
+		}
+		preview = strings.TrimSpace(preview[:cut]) + "..."
+	}
+	return fmt.Sprintf("%s\n\n[Split into %d chunks linked CHILD_OF this node; expand them to read the full content]", preview, chunks)
+}
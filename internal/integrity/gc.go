@@ -0,0 +1,130 @@
+package integrity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// DefaultRetention is how long GC keeps superseded nodes by default.
+const DefaultRetention = 90 * 24 * time.Hour
+
+// GCOptions controls GC.
+type GCOptions struct {
+	// Retention is how long a superseded node is kept, counted from the
+	// later of its last update and the creation of the node that replaced
+	// it.
+	Retention time.Duration
+	DryRun    bool      // report what would be removed without changing anything
+	Now       time.Time // zero means time.Now()
+}
+
+// PrunedNode is a superseded node GC deleted, or would delete.
+type PrunedNode struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	SupersededBy string `json:"superseded_by"`
+	Content      string `json:"content"`
+}
+
+// GCReport is the result of GC.
+type GCReport struct {
+	DryRun        bool         `json:"dry_run"`
+	Pruned        []PrunedNode `json:"pruned"`
+	DanglingEdges int          `json:"dangling_edges"`
+	OrphanTags    int          `json:"orphan_tags"`
+	FTS           *FTSDrift    `json:"fts"`
+	Vacuumed      bool         `json:"vacuumed"`
+}
+
+// GC reclaims space: it deletes superseded nodes older than the
+// retention window (their edges, tags and attachments go with them),
+// deletes edges and tags left pointing at missing nodes, rebuilds the
+// full-text index, and vacuums and analyzes the database. Nodes derived
+// from a pruned node were flagged stale when it was superseded, so they
+// are not flagged again.
+//
+// With DryRun, nothing is changed and FTS reports the index drift a
+// rebuild would repair.
+func GC(d db.Store, opts GCOptions) (*GCReport, error) {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	report := &GCReport{DryRun: opts.DryRun, Pruned: []PrunedNode{}}
+
+	cutoff := now.Add(-opts.Retention).UTC().Format(time.RFC3339)
+	rows, err := d.Query(`SELECT o.id, o.type, o.superseded_by, o.content
+		FROM nodes o JOIN nodes n ON n.id = o.superseded_by
+		WHERE o.updated_at < ? AND n.created_at < ?
+		ORDER BY o.id`, cutoff, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find superseded nodes: %w", err)
+	}
+	for rows.Next() {
+		var p PrunedNode
+		if err := rows.Scan(&p.ID, &p.Type, &p.SupersededBy, &p.Content); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.Content = db.Preview(p.Content, 80)
+		report.Pruned = append(report.Pruned, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if !opts.DryRun {
+		for _, p := range report.Pruned {
+			if err := d.DeleteNode(p.ID); err != nil {
+				return nil, fmt.Errorf("failed to prune %s: %w", p.ID, err)
+			}
+		}
+	}
+
+	orphans := &Report{}
+	if err := checkEdges(d, orphans); err != nil {
+		return nil, err
+	}
+	if err := checkTags(d, orphans); err != nil {
+		return nil, err
+	}
+	counts := orphans.Counts()
+	report.DanglingEdges, report.OrphanTags = counts[DanglingEdge], counts[OrphanTag]
+
+	if opts.DryRun {
+		if report.FTS, err = CheckFTSDrift(d); err != nil {
+			return nil, err
+		}
+		return report, nil
+	}
+
+	if err := Fix(d, orphans); err != nil {
+		return nil, err
+	}
+	if report.FTS, err = Reindex(d); err != nil {
+		return nil, err
+	}
+	if err := vacuum(d); err != nil {
+		return nil, err
+	}
+	report.Vacuumed = true
+	return report, nil
+}
+
+// vacuum reclaims the space freed by deletions and refreshes the query
+// planner's statistics.
+func vacuum(d db.Store) error {
+	stmts := []string{"VACUUM ANALYZE"}
+	if _, ok := d.(*db.SQLiteStore); ok {
+		stmts = []string{"VACUUM", "ANALYZE"}
+	}
+	for _, stmt := range stmts {
+		if _, err := d.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to run %s: %w", stmt, err)
+		}
+	}
+	return nil
+}
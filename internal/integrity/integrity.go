@@ -0,0 +1,264 @@
+// Package integrity checks the knowledge graph for orphaned rows and other
+// inconsistencies that foreign keys and triggers do not prevent, and repairs
+// them on request (`ctx fsck`, GET /api/integrity).
+package integrity
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// Issue kinds.
+const (
+	DanglingEdge      = "dangling_edge"      // edge endpoint is not a node
+	OrphanTag         = "orphan_tag"         // tag on a missing node
+	DanglingSupersede = "dangling_supersede" // superseded_by names a missing node
+	SupersedeCycle    = "supersede_cycle"    // superseded_by chain loops
+	InvalidMetadata   = "invalid_metadata"   // metadata is not valid JSON
+	FTSOutOfSync      = "fts_out_of_sync"    // SQLite full-text index disagrees with nodes
+)
+
+// Issue is a single integrity problem.
+type Issue struct {
+	Kind   string `json:"kind"`
+	NodeID string `json:"node_id,omitempty"`
+	EdgeID string `json:"edge_id,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Detail string `json:"detail"`
+}
+
+// Report is the result of Check.
+type Report struct {
+	Issues []Issue `json:"issues"`
+	Fixed  int     `json:"fixed,omitempty"`
+}
+
+// OK reports whether no issues were found.
+func (r *Report) OK() bool { return len(r.Issues) == 0 }
+
+// Counts returns the number of issues per kind.
+func (r *Report) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, i := range r.Issues {
+		counts[i.Kind]++
+	}
+	return counts
+}
+
+// Check inspects the store and returns every issue found.
+func Check(d db.Store) (*Report, error) {
+	r := &Report{Issues: []Issue{}}
+	for _, check := range []func(db.Store, *Report) error{
+		checkEdges,
+		checkTags,
+		checkSupersede,
+		checkMetadata,
+		checkFTS,
+	} {
+		if err := check(d, r); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Fix repairs every issue in r and records how many were fixed:
+// dangling edges and orphan tags are deleted, dangling supersede references
+// are cleared, each cycle is broken by reactivating its newest node,
+// invalid metadata is reset to {}, and the full-text index is rebuilt.
+func Fix(d db.Store, r *Report) error {
+	rebuilt := false
+	for _, issue := range r.Issues {
+		var err error
+		switch issue.Kind {
+		case DanglingEdge:
+			_, err = d.Exec("DELETE FROM edges WHERE id = $1", issue.EdgeID)
+		case OrphanTag:
+			_, err = d.Exec("DELETE FROM tags WHERE node_id = $1 AND tag = $2", issue.NodeID, issue.Tag)
+		case DanglingSupersede, SupersedeCycle:
+			_, err = d.Exec("UPDATE nodes SET superseded_by = NULL WHERE id = $1", issue.NodeID)
+		case InvalidMetadata:
+			_, err = d.Exec("UPDATE nodes SET metadata = '{}' WHERE id = $1", issue.NodeID)
+		case FTSOutOfSync:
+			if rebuilt {
+				continue
+			}
+			_, err = Reindex(d)
+			rebuilt = true
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fix %s: %w", issue.Kind, err)
+		}
+		r.Fixed++
+	}
+	return nil
+}
+
+func checkEdges(d db.Store, r *Report) error {
+	rows, err := d.Query(`SELECT id, from_id, to_id, type FROM edges
+		WHERE from_id NOT IN (SELECT id FROM nodes) OR to_id NOT IN (SELECT id FROM nodes)
+		ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to check edges: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, from, to, typ string
+		if err := rows.Scan(&id, &from, &to, &typ); err != nil {
+			return err
+		}
+		r.Issues = append(r.Issues, Issue{
+			Kind:   DanglingEdge,
+			EdgeID: id,
+			Detail: fmt.Sprintf("%s -[%s]-> %s references a missing node", from, typ, to),
+		})
+	}
+	return rows.Err()
+}
+
+func checkTags(d db.Store, r *Report) error {
+	rows, err := d.Query(`SELECT node_id, tag FROM tags
+		WHERE node_id NOT IN (SELECT id FROM nodes) ORDER BY node_id, tag`)
+	if err != nil {
+		return fmt.Errorf("failed to check tags: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var nodeID, tag string
+		if err := rows.Scan(&nodeID, &tag); err != nil {
+			return err
+		}
+		r.Issues = append(r.Issues, Issue{
+			Kind:   OrphanTag,
+			NodeID: nodeID,
+			Tag:    tag,
+			Detail: fmt.Sprintf("tag %q on missing node", tag),
+		})
+	}
+	return rows.Err()
+}
+
+func checkSupersede(d db.Store, r *Report) error {
+	rows, err := d.Query("SELECT id, superseded_by FROM nodes WHERE superseded_by IS NOT NULL")
+	if err != nil {
+		return fmt.Errorf("failed to check supersede references: %w", err)
+	}
+	next := make(map[string]string)
+	for rows.Next() {
+		var id, by string
+		if err := rows.Scan(&id, &by); err != nil {
+			rows.Close()
+			return err
+		}
+		next[id] = by
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(next))
+	for id := range next {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		by := next[id]
+		if _, ok := next[by]; ok {
+			continue
+		}
+		var exists int
+		if err := d.QueryRow("SELECT COUNT(*) FROM nodes WHERE id = $1", by).Scan(&exists); err != nil {
+			return err
+		}
+		if exists == 0 {
+			r.Issues = append(r.Issues, Issue{
+				Kind:   DanglingSupersede,
+				NodeID: id,
+				Detail: fmt.Sprintf("superseded by missing node %s", by),
+			})
+		}
+	}
+
+	// Follow each chain; a chain that revisits a node is a cycle. Report each
+	// cycle once, against its newest (highest ULID) node.
+	seen := make(map[string]bool)
+	for _, start := range ids {
+		if seen[start] {
+			continue
+		}
+		path := map[string]int{}
+		var order []string
+		for cur := start; cur != ""; cur = next[cur] {
+			if i, ok := path[cur]; ok {
+				cycle := order[i:]
+				newest := cycle[0]
+				for _, c := range cycle {
+					if c > newest {
+						newest = c
+					}
+				}
+				r.Issues = append(r.Issues, Issue{
+					Kind:   SupersedeCycle,
+					NodeID: newest,
+					Detail: fmt.Sprintf("supersede cycle through %d nodes", len(cycle)),
+				})
+				break
+			}
+			if seen[cur] {
+				break
+			}
+			path[cur] = len(order)
+			order = append(order, cur)
+		}
+		for _, id := range order {
+			seen[id] = true
+		}
+	}
+	return nil
+}
+
+func checkMetadata(d db.Store, r *Report) error {
+	rows, err := d.Query("SELECT id, COALESCE(metadata, '') FROM nodes ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to check metadata: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, meta string
+		if err := rows.Scan(&id, &meta); err != nil {
+			return err
+		}
+		if !json.Valid([]byte(meta)) {
+			r.Issues = append(r.Issues, Issue{
+				Kind:   InvalidMetadata,
+				NodeID: id,
+				Detail: "metadata is not valid JSON",
+			})
+		}
+	}
+	return rows.Err()
+}
+
+// checkFTS reports a full-text index that has drifted from the nodes table
+// (see CheckFTSDrift) as a single issue.
+func checkFTS(d db.Store, r *Report) error {
+	drift, err := CheckFTSDrift(d)
+	if err != nil {
+		return err
+	}
+	if !drift.OK() {
+		detail := fmt.Sprintf("full-text index does not match nodes: %d nodes missing or stale, %d entries for deleted nodes", drift.Missing, drift.Extra)
+		if drift.Corrupt {
+			detail += ", integrity check failed"
+		}
+		r.Issues = append(r.Issues, Issue{Kind: FTSOutOfSync, Detail: detail})
+	}
+	return nil
+}
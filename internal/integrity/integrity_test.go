@@ -0,0 +1,178 @@
+package integrity_test
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/integrity"
+	_ "modernc.org/sqlite"
+)
+
+// setup returns a store plus a raw connection with foreign keys off, so the
+// test can plant the damage the checks look for.
+func setup(t *testing.T) (db.Store, *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	d, err := db.Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { d.Close() })
+
+	raw, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	raw.SetMaxOpenConns(1)
+	_, err = raw.Exec("PRAGMA foreign_keys=OFF")
+	require.NoError(t, err)
+	t.Cleanup(func() { raw.Close() })
+	return d, raw
+}
+
+func TestCheck_Clean(t *testing.T) {
+	d, _ := setup(t)
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"x"}})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "b"})
+	_, _ = d.CreateEdge(a.ID, b.ID, "RELATES_TO")
+
+	r, err := integrity.Check(d)
+	require.NoError(t, err)
+	assert.True(t, r.OK(), "%v", r.Issues)
+}
+
+func TestCheckAndFix(t *testing.T) {
+	d, raw := setup(t)
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "alpha"})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "beta"})
+	c, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "gamma"})
+
+	exec := func(q string, args ...any) {
+		_, err := raw.Exec(q, args...)
+		require.NoError(t, err)
+	}
+	exec("INSERT INTO edges (id, from_id, to_id, type, created_at) VALUES ('E1', ?, 'GONE', 'RELATES_TO', '2025-01-01T00:00:00Z')", a.ID)
+	exec("INSERT INTO tags (node_id, tag, created_at) VALUES ('GONE', 'tier:pinned', '2025-01-01T00:00:00Z')")
+	exec("UPDATE nodes SET superseded_by = 'GONE' WHERE id = ?", a.ID)
+	exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", c.ID, b.ID)
+	exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", b.ID, c.ID)
+	exec("UPDATE nodes SET metadata = '{broken' WHERE id = ?", a.ID)
+	exec("INSERT INTO nodes_fts(nodes_fts, rowid, content) SELECT 'delete', rowid, content FROM nodes WHERE id = ?", a.ID)
+
+	r, err := integrity.Check(d)
+	require.NoError(t, err)
+	counts := r.Counts()
+	assert.Equal(t, 1, counts[integrity.DanglingEdge])
+	assert.Equal(t, 1, counts[integrity.OrphanTag])
+	assert.Equal(t, 1, counts[integrity.DanglingSupersede])
+	assert.Equal(t, 1, counts[integrity.SupersedeCycle])
+	assert.Equal(t, 1, counts[integrity.InvalidMetadata])
+	assert.Equal(t, 1, counts[integrity.FTSOutOfSync])
+
+	// Nodes created in the same millisecond don't sort by creation order,
+	// so compare against the highest ID rather than assuming c.
+	newest := b.ID
+	if c.ID > newest {
+		newest = c.ID
+	}
+	for _, issue := range r.Issues {
+		if issue.Kind == integrity.SupersedeCycle {
+			assert.Equal(t, newest, issue.NodeID, "cycle is reported against its newest node")
+		}
+	}
+
+	require.NoError(t, integrity.Fix(d, r))
+	assert.Equal(t, 6, r.Fixed)
+
+	after, err := integrity.Check(d)
+	require.NoError(t, err)
+	assert.True(t, after.OK(), "%v", after.Issues)
+
+	results, err := d.Search("alpha")
+	require.NoError(t, err)
+	assert.Len(t, results, 1)
+}
+
+func TestReindex(t *testing.T) {
+	d, raw := setup(t)
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "searchable alpha"})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "searchable beta"})
+
+	// Simulate a crash between the node write and its index trigger
+	_, err := raw.Exec("INSERT INTO nodes_fts(nodes_fts, rowid, content) SELECT 'delete', rowid, content FROM nodes WHERE id = ?", a.ID)
+	require.NoError(t, err)
+	found, err := d.Search("alpha")
+	require.NoError(t, err)
+	assert.Empty(t, found, "search misses the unindexed node")
+
+	drift, err := integrity.CheckFTSDrift(d)
+	require.NoError(t, err)
+	assert.Equal(t, 1, drift.Missing)
+	assert.False(t, drift.OK())
+
+	drift, err = integrity.Reindex(d)
+	require.NoError(t, err)
+	assert.Equal(t, 1, drift.Missing, "Reindex reports the drift it repaired")
+
+	found, err = d.Search("alpha")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, a.ID, found[0].ID)
+
+	drift, err = integrity.CheckFTSDrift(d)
+	require.NoError(t, err)
+	assert.True(t, drift.OK(), "%+v", drift)
+}
+
+func TestGC(t *testing.T) {
+	d, raw := setup(t)
+	exec := func(q string, args ...any) {
+		_, err := raw.Exec(q, args...)
+		require.NoError(t, err)
+	}
+	old := "2025-01-01T00:00:00Z"
+
+	oldA, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "old version " + strings.Repeat("é", 100), Tags: []string{"x"}})
+	newA, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "new version"})
+	recentB, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "recently superseded"})
+	newB, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "its replacement"})
+	live, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "never superseded"})
+	_, err := d.CreateEdge(oldA.ID, live.ID, "RELATES_TO")
+	require.NoError(t, err)
+	exec("UPDATE nodes SET superseded_by = ?, updated_at = ? WHERE id = ?", newA.ID, old, oldA.ID)
+	exec("UPDATE nodes SET created_at = ? WHERE id IN (?, ?)", old, newA.ID, live.ID)
+	exec("UPDATE nodes SET superseded_by = ?, updated_at = ? WHERE id = ?", newB.ID, old, recentB.ID)
+	exec("INSERT INTO tags (node_id, tag, created_at) VALUES ('GONE', 'tier:pinned', ?)", old)
+
+	opts := integrity.GCOptions{Retention: integrity.DefaultRetention, DryRun: true}
+	r, err := integrity.GC(d, opts)
+	require.NoError(t, err)
+	require.Len(t, r.Pruned, 1, "the replacement of the other is too recent")
+	assert.Equal(t, oldA.ID, r.Pruned[0].ID)
+	assert.Equal(t, newA.ID, r.Pruned[0].SupersededBy)
+	assert.Equal(t, "old version "+strings.Repeat("é", 68)+"...", r.Pruned[0].Content, "cut at 80 characters, not bytes")
+	assert.Equal(t, 1, r.OrphanTags)
+	assert.False(t, r.Vacuumed)
+	_, err = d.GetNode(oldA.ID)
+	require.NoError(t, err, "a dry run deletes nothing")
+
+	opts.DryRun = false
+	r, err = integrity.GC(d, opts)
+	require.NoError(t, err)
+	require.Len(t, r.Pruned, 1)
+	assert.True(t, r.Vacuumed)
+	_, err = d.GetNode(oldA.ID)
+	assert.ErrorIs(t, err, db.ErrNotFound)
+	for _, id := range []string{newA.ID, recentB.ID, newB.ID, live.ID} {
+		_, err = d.GetNode(id)
+		assert.NoError(t, err)
+	}
+	edges, err := d.GetEdgesTo(live.ID)
+	require.NoError(t, err)
+	assert.Empty(t, edges, "the pruned node's edges go with it")
+
+	after, err := integrity.Check(d)
+	require.NoError(t, err)
+	assert.True(t, after.OK(), "%v", after.Issues)
+}
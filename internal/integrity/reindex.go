@@ -0,0 +1,79 @@
+package integrity
+
+import (
+	"fmt"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// FTSDrift measures how far the full-text index has drifted from the nodes
+// table. Search silently misses Missing nodes.
+type FTSDrift struct {
+	Missing int  `json:"missing"`           // nodes absent from (or stale in) the index
+	Extra   int  `json:"extra"`             // index entries for nodes that no longer exist
+	Corrupt bool `json:"corrupt,omitempty"` // SQLite integrity-check failed (e.g. stale tokens)
+}
+
+// OK reports whether the index matches the nodes table.
+func (f *FTSDrift) OK() bool { return f.Missing == 0 && f.Extra == 0 && !f.Corrupt }
+
+// CheckFTSDrift compares the full-text index with the nodes table. For
+// SQLite it counts nodes_fts documents against node rows and runs the FTS5
+// integrity check; for PostgreSQL it counts rows whose stored search_vector
+// differs from a freshly computed one (after a text search config change,
+// say).
+func CheckFTSDrift(d db.Store) (*FTSDrift, error) {
+	drift := &FTSDrift{}
+	if _, ok := d.(*db.SQLiteStore); !ok {
+		err := d.QueryRow(`SELECT COUNT(*) FROM nodes
+			WHERE search_vector IS DISTINCT FROM to_tsvector('english', content)`).Scan(&drift.Missing)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check search vectors: %w", err)
+		}
+		return drift, nil
+	}
+
+	if err := d.QueryRow(`SELECT COUNT(*) FROM nodes
+		WHERE rowid NOT IN (SELECT id FROM nodes_fts_docsize)`).Scan(&drift.Missing); err != nil {
+		return nil, fmt.Errorf("failed to check full-text index: %w", err)
+	}
+	if err := d.QueryRow(`SELECT COUNT(*) FROM nodes_fts_docsize
+		WHERE id NOT IN (SELECT rowid FROM nodes)`).Scan(&drift.Extra); err != nil {
+		return nil, fmt.Errorf("failed to check full-text index: %w", err)
+	}
+	if _, err := d.Exec("INSERT INTO nodes_fts(nodes_fts, rank) VALUES('integrity-check', 1)"); err != nil {
+		drift.Corrupt = true
+	}
+	return drift, nil
+}
+
+// Reindex rebuilds the full-text index from the nodes table and returns the
+// drift it found beforehand. SQLite's nodes_fts is rebuilt and optimized;
+// PostgreSQL's search vectors are recomputed where they differ and the GIN
+// index rebuilt.
+func Reindex(d db.Store) (*FTSDrift, error) {
+	drift, err := CheckFTSDrift(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := d.(*db.SQLiteStore); ok {
+		if _, err := d.Exec("INSERT INTO nodes_fts(nodes_fts) VALUES('rebuild')"); err != nil {
+			return nil, fmt.Errorf("failed to rebuild full-text index: %w", err)
+		}
+		if _, err := d.Exec("INSERT INTO nodes_fts(nodes_fts) VALUES('optimize')"); err != nil {
+			return nil, fmt.Errorf("failed to optimize full-text index: %w", err)
+		}
+		return drift, nil
+	}
+
+	// search_vector is a stored generated column: rewriting content
+	// recomputes it
+	if _, err := d.Exec(`UPDATE nodes SET content = content
+		WHERE search_vector IS DISTINCT FROM to_tsvector('english', content)`); err != nil {
+		return nil, fmt.Errorf("failed to refresh search vectors: %w", err)
+	}
+	if _, err := d.Exec("REINDEX INDEX idx_nodes_search"); err != nil {
+		return nil, fmt.Errorf("failed to rebuild search index: %w", err)
+	}
+	return drift, nil
+}
@@ -0,0 +1,132 @@
+// Package lang guesses the natural language a node is written in, so
+// nodes can be filtered with lang: queries and translated during compose.
+// Detection is deliberately simple: the script decides for non-Latin
+// alphabets, and common function words decide between Latin-script
+// languages. Text too short or too mixed to call returns "".
+package lang
+
+import (
+	"strings"
+	"unicode"
+)
+
+// minHits is how many function words a Latin-script language needs before
+// Detect names it, so identifiers and short notes stay undetermined.
+const minHits = 3
+
+// stopwords are frequent function words that are rare in the other
+// languages listed.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "are", "was", "of", "to", "in", "that", "it", "for", "with", "this", "not", "be", "on", "we", "should", "when", "use", "from", "have"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "auf", "für", "wir", "sich", "auch", "dem", "den", "wird", "werden", "zu", "von", "bei", "wenn"},
+	"fr": {"le", "la", "les", "et", "est", "une", "des", "du", "dans", "pour", "pas", "que", "qui", "sur", "avec", "nous", "ce", "sont", "au", "aux", "il", "ne"},
+	"es": {"el", "los", "las", "y", "es", "una", "del", "en", "para", "por", "que", "con", "no", "se", "lo", "como", "está", "son", "al", "pero", "hay", "usar"},
+	"it": {"il", "lo", "gli", "e", "è", "una", "della", "di", "per", "che", "non", "con", "sono", "nel", "alla", "questo", "anche", "si", "come", "ma", "usare", "dei"},
+	"pt": {"o", "os", "as", "e", "é", "uma", "do", "da", "em", "para", "que", "não", "com", "se", "um", "são", "na", "no", "mas", "ao", "está", "usar"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "op", "voor", "met", "dat", "zijn", "wordt", "ook", "bij", "wij", "we", "naar", "als", "dit", "er", "worden"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopwords))
+	for code, words := range stopwords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[code] = set
+	}
+	return sets
+}()
+
+// scripts maps non-Latin alphabets to the language they most likely mean.
+var scripts = []struct {
+	table *unicode.RangeTable
+	code  string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Han, "zh"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Arabic, "ar"},
+	{unicode.Hebrew, "he"},
+	{unicode.Greek, "el"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Thai, "th"},
+}
+
+// Detect returns the ISO 639-1 code of the language text is written in,
+// or "" when it can't tell.
+func Detect(text string) string {
+	if code := detectScript(text); code != "" {
+		return code
+	}
+	return detectLatin(text)
+}
+
+// detectScript names the language of a text mostly written in a non-Latin
+// alphabet. Japanese mixes kana with Han, so any kana means Japanese.
+func detectScript(text string) string {
+	counts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, s := range scripts {
+			if unicode.Is(s.table, r) {
+				counts[s.code]++
+				break
+			}
+		}
+	}
+	if counts["ja"] > 0 && counts["ja"]+counts["zh"] >= letters/3 {
+		return "ja"
+	}
+	if counts["ru"] > 0 && strings.ContainsAny(text, "іїєґІЇЄҐ") {
+		counts["uk"], counts["ru"] = counts["ru"], 0
+	}
+	best, bestCount := "", 0
+	for code, n := range counts {
+		if n > bestCount || (n == bestCount && code < best) {
+			best, bestCount = code, n
+		}
+	}
+	if bestCount == 0 || bestCount < letters/3 {
+		return ""
+	}
+	return best
+}
+
+// detectLatin scores the text's words against each language's function
+// words and returns the clear winner.
+func detectLatin(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	scores := map[string]int{}
+	for _, w := range words {
+		for code, set := range stopwordSets {
+			if set[w] {
+				scores[code]++
+			}
+		}
+	}
+	best, second := "", 0
+	for code, n := range scores {
+		switch {
+		case best == "" || n > scores[best] || (n == scores[best] && code < best):
+			if best != "" {
+				second = max(second, scores[best])
+			}
+			best = code
+		default:
+			second = max(second, n)
+		}
+	}
+	if best == "" || scores[best] < minHits || scores[best] <= second {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,32 @@
+package lang_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zate/ctx/internal/lang"
+)
+
+func TestDetect(t *testing.T) {
+	cases := map[string]string{
+		"We should use Postgres for the sync server because it is easier to run with several instances.":               "en",
+		"Wir sollten Postgres für den Sync-Server verwenden, weil es mit mehreren Instanzen einfacher ist.":            "de",
+		"Nous utilisons Postgres pour le serveur de synchronisation, car il est plus simple avec plusieurs instances.": "fr",
+		"Usamos Postgres para el servidor de sincronización porque es más fácil con varias instancias.":                "es",
+		"Usiamo Postgres per il server di sincronizzazione perché è più semplice con più istanze e non serve altro.":   "it",
+		"Usamos o Postgres para o servidor de sincronização porque é mais simples com várias instâncias.":              "pt",
+		"We gebruiken Postgres voor de sync-server, omdat het met meerdere instanties eenvoudiger is.":                 "nl",
+		"Мы используем Postgres для сервера синхронизации.":                                                            "ru",
+		"Ми використовуємо Postgres для сервера синхронізації.":                                                        "uk",
+		"同期サーバーにはPostgresを使います。":                                                                                       "ja",
+		"我们在同步服务器上使用Postgres。":                                                                                         "zh",
+		"동기화 서버에는 Postgres를 사용합니다.":                                                                                    "ko",
+		"Χρησιμοποιούμε Postgres για τον διακομιστή.":                                                                  "el",
+		"func (s *Server) handleExport(w http.ResponseWriter)":                                                         "",
+		"Use Postgres": "",
+		"":             "",
+	}
+	for text, want := range cases {
+		assert.Equal(t, want, lang.Detect(text), text)
+	}
+}
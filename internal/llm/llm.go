@@ -0,0 +1,39 @@
+// Package llm runs the language model configured by llm.command. ctx
+// doesn't talk to a model API itself: it pipes the prompt to a command
+// such as `claude -p` and reads the reply from its stdout.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// ErrNotConfigured explains how to set up a model when none is configured.
+var ErrNotConfigured = fmt.Errorf("no LLM configured (set llm.command, e.g. ctx config set llm.command 'claude -p')")
+
+// Run runs command through the shell with prompt on stdin and returns its
+// trimmed output.
+func Run(ctx context.Context, command, prompt string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", ErrNotConfigured
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(prompt)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("LLM command failed: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("LLM command failed: %w", err)
+	}
+	out := strings.TrimSpace(stdout.String())
+	if out == "" {
+		return "", fmt.Errorf("LLM command returned nothing")
+	}
+	return out, nil
+}
@@ -0,0 +1,25 @@
+package llm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/llm"
+)
+
+func TestRun(t *testing.T) {
+	out, err := llm.Run(context.Background(), "tr a-z A-Z", "merged notes\n")
+	require.NoError(t, err)
+	assert.Equal(t, "MERGED NOTES", out)
+
+	_, err = llm.Run(context.Background(), "", "x")
+	assert.ErrorIs(t, err, llm.ErrNotConfigured)
+
+	_, err = llm.Run(context.Background(), "echo boom >&2; exit 1", "x")
+	assert.ErrorContains(t, err, "boom")
+
+	_, err = llm.Run(context.Background(), "true", "x")
+	assert.ErrorContains(t, err, "returned nothing")
+}
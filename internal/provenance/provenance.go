@@ -0,0 +1,122 @@
+// Package provenance tracks knowledge derived from other nodes and flags it
+// for review when the nodes it was derived from go out of date.
+package provenance
+
+import (
+	"fmt"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// StaleTag marks a node derived (directly or transitively) from a node that
+// has since been superseded or deleted.
+const StaleTag = "stale:true"
+
+// Downstream returns every node reachable from nodeID by following
+// DERIVED_FROM edges backwards, i.e. everything derived from nodeID.
+func Downstream(d db.Store, nodeID string) ([]string, error) {
+	visited := map[string]bool{nodeID: true}
+	var out []string
+	frontier := []string{nodeID}
+
+	for len(frontier) > 0 {
+		var next []string
+		for _, id := range frontier {
+			edges, err := d.GetEdgesTo(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range edges {
+				if e.Type != "DERIVED_FROM" || visited[e.FromID] {
+					continue
+				}
+				visited[e.FromID] = true
+				out = append(out, e.FromID)
+				next = append(next, e.FromID)
+			}
+		}
+		frontier = next
+	}
+
+	return out, nil
+}
+
+// MarkStale tags everything derived from nodeID as stale. Call it when
+// nodeID is superseded, or before it is deleted (deleting a node removes
+// its edges). It returns the IDs that were flagged.
+func MarkStale(d db.Store, nodeID string) ([]string, error) {
+	ids, err := Downstream(d, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := d.AddTag(id, StaleTag); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// IsStale reports whether a node carries the stale tag.
+func IsStale(n *db.Node) bool {
+	for _, t := range n.Tags {
+		if t == StaleTag {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependent is a node that transitively depends on another: Depth hops
+// away, reached from Parent over an edge of type Via.
+type Dependent struct {
+	ID     string `json:"id"`
+	Depth  int    `json:"depth"`
+	Via    string `json:"via"`
+	Parent string `json:"parent"`
+}
+
+// Dependents returns every node that depends on nodeID, directly or
+// transitively, by following DEPENDS_ON and DERIVED_FROM edges backwards,
+// nearest first. maxDepth limits how many hops are followed (0 for no
+// limit).
+func Dependents(d db.Store, nodeID string, maxDepth int) ([]Dependent, error) {
+	visited := map[string]bool{nodeID: true}
+	var out []Dependent
+	frontier := []string{nodeID}
+
+	for depth := 1; len(frontier) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		var next []string
+		for _, id := range frontier {
+			edges, err := d.GetEdgesTo(id)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range edges {
+				if (e.Type != "DEPENDS_ON" && e.Type != "DERIVED_FROM") || visited[e.FromID] {
+					continue
+				}
+				visited[e.FromID] = true
+				out = append(out, Dependent{ID: e.FromID, Depth: depth, Via: e.Type, Parent: id})
+				next = append(next, e.FromID)
+			}
+		}
+		frontier = next
+	}
+
+	return out, nil
+}
+
+// BackwardsHint explains why a DERIVED_FROM edge from one node to another
+// looks reversed, or returns "" when it looks right. A DERIVED_FROM edge
+// runs from the derived node to its source, so a summary belongs on the
+// from side and a source on the to side.
+func BackwardsHint(from, to *db.Node) string {
+	switch {
+	case to.Type == "summary" && from.Type != "summary":
+		return fmt.Sprintf("DERIVED_FROM points at summary %s from %s %s; summaries derive from what they summarize", to.ID, from.Type, from.ID)
+	case from.Type == "source" && to.Type != "source":
+		return fmt.Sprintf("DERIVED_FROM runs from source %s to %s %s; knowledge derives from sources, not the other way round", from.ID, to.Type, to.ID)
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package provenance_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/provenance"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestMarkStale_FollowsDerivedFromTransitively(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	source, _ := d.CreateNode(db.CreateNodeInput{Type: "source", Content: "doc"})
+	summary, _ := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "summary"})
+	digest, _ := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "digest"})
+	related, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "related"})
+	_, _ = d.CreateEdge(summary.ID, source.ID, "DERIVED_FROM")
+	_, _ = d.CreateEdge(digest.ID, summary.ID, "DERIVED_FROM")
+	_, _ = d.CreateEdge(related.ID, source.ID, "RELATES_TO")
+
+	flagged, err := provenance.MarkStale(d, source.ID)
+
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{summary.ID, digest.ID}, flagged)
+
+	n, _ := d.GetNode(digest.ID)
+	assert.True(t, provenance.IsStale(n))
+	n, _ = d.GetNode(related.ID)
+	assert.False(t, provenance.IsStale(n))
+}
+
+func TestDownstream_HandlesCycles(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	a, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a"})
+	b, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "b"})
+	_, _ = d.CreateEdge(a.ID, b.ID, "DERIVED_FROM")
+	_, _ = d.CreateEdge(b.ID, a.ID, "DERIVED_FROM")
+
+	ids, err := provenance.Downstream(d, a.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.ID}, ids)
+}
+
+func TestDependents_DepthAndEdgeTypes(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	root, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use postgres"})
+	svc, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "billing on postgres"})
+	note, _ := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "db summary"})
+	task, _ := d.CreateNode(db.CreateNodeInput{Type: "task", Content: "migrate billing"})
+	other, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "unrelated"})
+	_, _ = d.CreateEdge(svc.ID, root.ID, "DEPENDS_ON")
+	_, _ = d.CreateEdge(note.ID, root.ID, "DERIVED_FROM")
+	_, _ = d.CreateEdge(task.ID, svc.ID, "DEPENDS_ON")
+	_, _ = d.CreateEdge(root.ID, task.ID, "DEPENDS_ON") // cycle back to root
+	_, _ = d.CreateEdge(other.ID, root.ID, "RELATES_TO")
+
+	deps, err := provenance.Dependents(d, root.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, deps, 3)
+	assert.ElementsMatch(t, []provenance.Dependent{
+		{ID: svc.ID, Depth: 1, Via: "DEPENDS_ON", Parent: root.ID},
+		{ID: note.ID, Depth: 1, Via: "DERIVED_FROM", Parent: root.ID},
+	}, deps[:2])
+	assert.Equal(t, provenance.Dependent{ID: task.ID, Depth: 2, Via: "DEPENDS_ON", Parent: svc.ID}, deps[2])
+
+	deps, err = provenance.Dependents(d, root.ID, 1)
+	require.NoError(t, err)
+	assert.Len(t, deps, 2)
+}
+
+func TestBackwardsHint(t *testing.T) {
+	source := &db.Node{ID: "S", Type: "source"}
+	summary := &db.Node{ID: "M", Type: "summary"}
+	fact := &db.Node{ID: "F", Type: "fact"}
+
+	assert.Empty(t, provenance.BackwardsHint(summary, source))
+	assert.Empty(t, provenance.BackwardsHint(fact, source))
+	assert.Empty(t, provenance.BackwardsHint(summary, fact))
+	assert.Contains(t, provenance.BackwardsHint(fact, summary), "points at summary M")
+	assert.Contains(t, provenance.BackwardsHint(source, fact), "runs from source S")
+}
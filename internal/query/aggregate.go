@@ -0,0 +1,94 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// Group is one row of an aggregate: the nodes sharing a key, how many there
+// are and their token total.
+type Group struct {
+	Key    string `json:"key"`
+	Count  int    `json:"count"`
+	Tokens int    `json:"tokens"`
+}
+
+// AggregateFields lists what Aggregate can group by. tag:<prefix> groups by
+// the tags under a prefix, e.g. tag:project or tag:tier.
+var AggregateFields = []string{"type", "tag", "tag:<prefix>", "created", "updated"}
+
+// Aggregate runs queryStr and groups the matching nodes by the field by:
+// type; tag, or tag:<prefix> for only the tags under a prefix (a node counts
+// once for each of its tags, and untagged nodes are left out); or created
+// or updated, by month (2024-03). Groups come largest first. The query's
+// limit and offset page the groups; sort does not apply.
+func Aggregate(ctx context.Context, d db.Store, queryStr, by string, includeSuperseded bool) ([]Group, error) {
+	var key, join string
+	var joinArgs []interface{}
+	switch {
+	case by == "type":
+		key = "n.type"
+	case by == "created", by == "updated":
+		key = "substr(n." + by + "_at, 1, 7)"
+	case by == "tag":
+		key, join = "gt.tag", "JOIN tags gt ON gt.node_id = n.id"
+	case strings.HasPrefix(by, "tag:") && len(by) > len("tag:"):
+		prefix := strings.TrimSuffix(strings.TrimPrefix(by, "tag:"), ":") + ":"
+		key, join = "gt.tag", `JOIN tags gt ON gt.node_id = n.id AND gt.tag LIKE ? ESCAPE '\'`
+		joinArgs = []interface{}{likeEscaper.Replace(prefix) + "%"}
+	default:
+		return nil, fmt.Errorf("cannot group by %q (use %s)", by, strings.Join(AggregateFields, ", "))
+	}
+
+	ast, mods, err := ParseWithModifiers(queryStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse query: %w", err)
+	}
+	if mods.Sort != "" {
+		return nil, fmt.Errorf("sort does not apply to aggregates, which come largest first")
+	}
+	if mods.Budget > 0 || mods.Sample > 0 {
+		return nil, fmt.Errorf("budget and sample do not apply to aggregates")
+	}
+	d = d.WithContext(ctx)
+
+	where, args, joins, err := buildFilter(d, ast, includeSuperseded)
+	if err != nil {
+		return nil, err
+	}
+
+	inner := "SELECT DISTINCT n.id, " + key + " AS gkey, n.token_estimate FROM nodes n"
+	if join != "" {
+		inner += " " + join
+	}
+	if joins != "" {
+		inner += " " + joins
+	}
+	if where != "" {
+		inner += " WHERE " + where
+	}
+	sql := "SELECT g.gkey, COUNT(*), COALESCE(SUM(g.token_estimate), 0) FROM (" + inner + ") g GROUP BY g.gkey ORDER BY COUNT(*) DESC, g.gkey"
+	sql += page(mods)
+
+	rows, err := d.Query(sql, append(joinArgs, args...)...)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
+	defer rows.Close()
+
+	groups := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.Key, &g.Count, &g.Tokens); err != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", err)
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,71 @@
+package query_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestAggregate(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	create := func(typ, content string, tags ...string) *db.Node {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: typ, Content: content, Tags: tags})
+		require.NoError(t, err)
+		return n
+	}
+	a := create("decision", "Use Postgres for storage", "project:ctx", "tier:reference")
+	b := create("decision", "Use Go", "project:ctx", "tier:pinned")
+	c := create("fact", "Postgres runs on port 5432", "project:ctx", "project:infra")
+	create("fact", "Unrelated fact")
+	old := create("fact", "Superseded fact", "project:ctx")
+	_, err := d.Exec("UPDATE nodes SET superseded_by = ?, created_at = ? WHERE id = ?", c.ID, "2024-03-05T10:00:00Z", old.ID)
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET created_at = ? WHERE id = ?", "2024-03-20T10:00:00Z", a.ID)
+	require.NoError(t, err)
+
+	run := func(q, by string, superseded bool) []query.Group {
+		t.Helper()
+		groups, err := query.Aggregate(context.Background(), d, q, by, superseded)
+		require.NoError(t, err)
+		return groups
+	}
+
+	assert.Equal(t, []query.Group{
+		{Key: "decision", Count: 2, Tokens: a.TokenEstimate + b.TokenEstimate},
+		{Key: "fact", Count: 1, Tokens: c.TokenEstimate},
+	}, run("tag:project:ctx", "type", false))
+
+	assert.Equal(t, []query.Group{
+		{Key: "decision", Count: 2, Tokens: a.TokenEstimate + b.TokenEstimate},
+		{Key: "fact", Count: 2, Tokens: c.TokenEstimate + old.TokenEstimate},
+	}, run("tag:project:ctx", "type", true), "superseded nodes counted on request, ties by key")
+
+	assert.Equal(t, []query.Group{
+		{Key: "project:ctx", Count: 3, Tokens: a.TokenEstimate + b.TokenEstimate + c.TokenEstimate},
+		{Key: "project:infra", Count: 1, Tokens: c.TokenEstimate},
+	}, run("", "tag:project", false))
+
+	tiers := run("", "tag:tier:", false)
+	require.Len(t, tiers, 2)
+	assert.Equal(t, "tier:pinned", tiers[0].Key, "ties break by key")
+
+	assert.Len(t, run("", "tag", false), 4)
+	assert.Equal(t, []query.Group{{Key: "project:infra", Count: 1, Tokens: c.TokenEstimate}},
+		run("limit:1 offset:1", "tag:project", false))
+
+	months := run("", "created", true)
+	require.Len(t, months, 2)
+	assert.Equal(t, query.Group{Key: "2024-03", Count: 2, Tokens: a.TokenEstimate + old.TokenEstimate}, months[1])
+
+	_, err = query.Aggregate(context.Background(), d, "", "content", false)
+	assert.ErrorContains(t, err, `cannot group by "content"`)
+	_, err = query.Aggregate(context.Background(), d, "type:fact sort:tokens", "type", false)
+	assert.ErrorContains(t, err, "sort does not apply")
+	_, err = query.Aggregate(context.Background(), d, "type:fact budget:100", "type", false)
+	assert.ErrorContains(t, err, "budget and sample do not apply")
+}
@@ -1,36 +1,60 @@
 package query
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/refs"
+	"github.com/zate/ctx/internal/validate"
 )
 
+// WithTimeout returns a context bounded by timeout, or one that is only
+// cancellable when timeout is 0. A nil parent means context.Background(),
+// as for commands run outside cobra's Execute.
+func WithTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
 // ExecuteQuery parses and executes a query against the database.
 func ExecuteQuery(d db.Store, queryStr string, includeSuperseded bool) ([]*db.Node, error) {
-	ast, err := Parse(queryStr)
+	return ExecuteQueryContext(context.Background(), d, queryStr, includeSuperseded)
+}
+
+// ExecuteQueryContext is ExecuteQuery bounded by ctx. When the deadline
+// passes or ctx is cancelled the running statement is interrupted and the
+// returned error wraps ctx.Err(), so callers can test for
+// context.DeadlineExceeded.
+func ExecuteQueryContext(ctx context.Context, d db.Store, queryStr string, includeSuperseded bool) ([]*db.Node, error) {
+	ast, mods, err := ParseWithModifiers(queryStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse query: %w", err)
 	}
+	// Bound, the saved query lookups and tag loading stop with ctx too
+	d = d.WithContext(ctx)
 
-	if ast == nil {
-		return d.ListNodes(db.ListOptions{IncludeSuperseded: includeSuperseded})
+	if ast == nil && mods.IsZero() {
+		nodes, err := d.ListNodes(db.ListOptions{IncludeSuperseded: includeSuperseded})
+		if err != nil {
+			return nil, queryError(ctx, err)
+		}
+		return nodes, nil
 	}
-
-	where, args, joins, err := buildSQL(ast)
+	where, args, joins, err := buildFilter(d, ast, includeSuperseded)
 	if err != nil {
-		return nil, fmt.Errorf("failed to build query: %w", err)
-	}
-
-	if !includeSuperseded {
-		if where != "" {
-			where = "(" + where + ") AND n.superseded_by IS NULL"
-		} else {
-			where = "n.superseded_by IS NULL"
-		}
+		return nil, err
 	}
 
 	sql := "SELECT DISTINCT n.id, n.type, n.content, n.summary, n.token_estimate, n.superseded_by, n.created_at, n.updated_at, n.metadata FROM nodes n"
@@ -40,15 +64,24 @@ func ExecuteQuery(d db.Store, queryStr string, includeSuperseded bool) ([]*db.No
 	if where != "" {
 		sql += " WHERE " + where
 	}
-	sql += " ORDER BY n.created_at DESC"
+	// PostgreSQL only orders a SELECT DISTINCT by selected columns, so a
+	// sample is drawn, and matches ranked by tier, from outside
+	switch {
+	case mods.Sample > 0:
+		sql = fmt.Sprintf("SELECT * FROM (SELECT * FROM (%s) s ORDER BY RANDOM() LIMIT %d) n", sql, mods.Sample)
+	case mods.Budget > 0 && mods.Sort == "":
+		sql = "SELECT * FROM (" + sql + ") n"
+	}
+	sql += orderBy(mods)
 
 	rows, err := d.Query(sql, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute query: %w", err)
+		return nil, queryError(ctx, err)
 	}
 	defer rows.Close()
 
 	var nodes []*db.Node
+	tokens := 0
 	for rows.Next() {
 		node := &db.Node{}
 		var summary, supersededBy interface{}
@@ -69,30 +102,194 @@ func ExecuteQuery(d db.Store, queryStr string, includeSuperseded bool) ([]*db.No
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
+		// Stop at the first node past the budget rather than skipping it,
+		// so a smaller, lower-ranked node never displaces it
+		if mods.Budget > 0 && tokens+node.TokenEstimate > mods.Budget {
+			break
+		}
+		tokens += node.TokenEstimate
 		nodes = append(nodes, node)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
 
-	// Load tags for each node
+	rows.Close()
+	if err := ctx.Err(); err != nil {
+		return nil, queryError(ctx, err)
+	}
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.ID
+	}
+	tags, err := d.GetTagsForNodes(ids)
+	if err != nil {
+		return nil, queryError(ctx, err)
+	}
 	for _, node := range nodes {
-		tags, _ := d.GetTags(node.ID)
-		node.Tags = tags
+		node.Tags = tags[node.ID]
 	}
 
 	return nodes, nil
 }
 
-func buildSQL(ast *QueryAST) (string, []interface{}, string, error) {
+// buildFilter expands the saved queries in ast and renders it as a WHERE
+// clause, leaving out superseded nodes unless includeSuperseded is set or
+// the query asks about supersession itself with a superseded: predicate.
+func buildFilter(d db.Store, ast *QueryAST, includeSuperseded bool) (string, []interface{}, string, error) {
+	ast, err := expandRefs(d, ast, nil)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if err := checkVia(d.Types(), ast); err != nil {
+		return "", nil, "", err
+	}
+
+	_, postgres := d.(*db.PostgresStore)
+	where, args, joins, err := buildSQL(ast, postgres)
+	if err != nil {
+		return "", nil, "", fmt.Errorf("failed to build query: %w", err)
+	}
+
+	if !includeSuperseded && !mentionsKey(ast, "superseded") {
+		if where != "" {
+			where = "(" + where + ") AND n.superseded_by IS NULL"
+		} else {
+			where = "n.superseded_by IS NULL"
+		}
+	}
+	return where, args, joins, nil
+}
+
+// checkVia checks the via: edge types of the related: predicates in ast
+// against the types the store knows.
+func checkVia(types *validate.Registry, ast *QueryAST) error {
+	if ast == nil {
+		return nil
+	}
+	for _, t := range ast.Via {
+		if err := types.EdgeType(t); err != nil {
+			return err
+		}
+	}
+	for _, child := range []*QueryAST{ast.Left, ast.Right, ast.Child} {
+		if err := checkVia(types, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mentions reports whether queryStr may filter on key: it has a key:
+// predicate, or refers to a saved query, which might. A query that
+// doesn't parse mentions nothing.
+func Mentions(queryStr, key string) bool {
+	ast, err := Parse(queryStr)
+	if err != nil {
+		return false
+	}
+	var walk func(*QueryAST) bool
+	walk = func(a *QueryAST) bool {
+		if a == nil {
+			return false
+		}
+		if a.Type == "ref" {
+			return true
+		}
+		if a.Type == "predicate" {
+			return a.Key == key
+		}
+		return walk(a.Left) || walk(a.Right) || walk(a.Child)
+	}
+	return walk(ast)
+}
+
+// mentionsKey reports whether any predicate in ast uses key.
+func mentionsKey(ast *QueryAST, key string) bool {
+	if ast == nil {
+		return false
+	}
+	if ast.Type == "predicate" {
+		return ast.Key == key
+	}
+	return mentionsKey(ast.Left, key) || mentionsKey(ast.Right, key) || mentionsKey(ast.Child, key)
+}
+
+// sortColumns maps sort fields to the columns they order by.
+var sortColumns = map[string]string{
+	"created": "n.created_at",
+	"updated": "n.updated_at",
+	"tokens":  "n.token_estimate",
+}
+
+// tierRank orders nodes the way compose loads them: pinned, reference,
+// working, then the rest.
+const tierRank = `CASE
+	WHEN EXISTS (SELECT 1 FROM tags WHERE node_id = n.id AND tag = 'tier:pinned') THEN 0
+	WHEN EXISTS (SELECT 1 FROM tags WHERE node_id = n.id AND tag = 'tier:reference') THEN 1
+	WHEN EXISTS (SELECT 1 FROM tags WHERE node_id = n.id AND tag = 'tier:working') THEN 2
+	ELSE 3 END`
+
+// orderBy renders the ORDER BY, LIMIT and OFFSET clauses for mods. Ties
+// are broken by ID so pages don't overlap. A budget without a sort ranks
+// by tier first, newest first within a tier.
+func orderBy(mods Modifiers) string {
+	if mods.Budget > 0 && mods.Sort == "" {
+		return " ORDER BY " + tierRank + ", n.created_at DESC, n.id DESC" + page(mods)
+	}
+	column := sortColumns[mods.Sort]
+	if column == "" {
+		column = sortColumns["created"]
+	}
+	dir := "DESC"
+	if mods.Asc {
+		dir = "ASC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, n.id %s", column, dir, dir) + page(mods)
+}
+
+// page renders the LIMIT and OFFSET clauses for mods.
+func page(mods Modifiers) string {
+	var clause string
+	limit := mods.Limit
+	if limit == 0 && mods.Offset > 0 {
+		limit = math.MaxInt32 // SQLite needs a LIMIT before OFFSET
+	}
+	if limit > 0 {
+		clause += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	if mods.Offset > 0 {
+		clause += fmt.Sprintf(" OFFSET %d", mods.Offset)
+	}
+	return clause
+}
+
+// queryError reports a query interrupted by ctx as a context error; drivers
+// otherwise surface it as their own "interrupted" error.
+func queryError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			return fmt.Errorf("query timed out: %w", ctxErr)
+		}
+		return fmt.Errorf("query cancelled: %w", ctxErr)
+	}
+	return fmt.Errorf("failed to execute query: %w", err)
+}
+
+// buildSQL renders ast as a WHERE clause; postgres selects PostgreSQL's
+// full-text search over SQLite's FTS5 for fts: predicates.
+func buildSQL(ast *QueryAST, postgres bool) (string, []interface{}, string, error) {
 	if ast == nil {
 		return "", nil, "", nil
 	}
 
 	switch ast.Type {
 	case "and":
-		lWhere, lArgs, lJoins, err := buildSQL(ast.Left)
+		lWhere, lArgs, lJoins, err := buildSQL(ast.Left, postgres)
 		if err != nil {
 			return "", nil, "", err
 		}
-		rWhere, rArgs, rJoins, err := buildSQL(ast.Right)
+		rWhere, rArgs, rJoins, err := buildSQL(ast.Right, postgres)
 		if err != nil {
 			return "", nil, "", err
 		}
@@ -100,11 +297,11 @@ func buildSQL(ast *QueryAST) (string, []interface{}, string, error) {
 		return "(" + lWhere + " AND " + rWhere + ")", append(lArgs, rArgs...), joins, nil
 
 	case "or":
-		lWhere, lArgs, lJoins, err := buildSQL(ast.Left)
+		lWhere, lArgs, lJoins, err := buildSQL(ast.Left, postgres)
 		if err != nil {
 			return "", nil, "", err
 		}
-		rWhere, rArgs, rJoins, err := buildSQL(ast.Right)
+		rWhere, rArgs, rJoins, err := buildSQL(ast.Right, postgres)
 		if err != nil {
 			return "", nil, "", err
 		}
@@ -112,34 +309,68 @@ func buildSQL(ast *QueryAST) (string, []interface{}, string, error) {
 		return "(" + lWhere + " OR " + rWhere + ")", append(lArgs, rArgs...), joins, nil
 
 	case "not":
-		cWhere, cArgs, cJoins, err := buildSQL(ast.Child)
+		cWhere, cArgs, cJoins, err := buildSQL(ast.Child, postgres)
 		if err != nil {
 			return "", nil, "", err
 		}
 		return "NOT (" + cWhere + ")", cArgs, cJoins, nil
 
 	case "predicate":
-		return buildPredicate(ast)
+		return buildPredicate(ast, postgres)
+
+	case "ref":
+		return "", nil, "", fmt.Errorf("saved query @%s was not expanded", ast.Value)
 
 	default:
 		return "", nil, "", fmt.Errorf("unknown AST type: %s", ast.Type)
 	}
 }
 
-func buildPredicate(ast *QueryAST) (string, []interface{}, string, error) {
+func buildPredicate(ast *QueryAST, postgres bool) (string, []interface{}, string, error) {
 	switch ast.Key {
 	case "type":
 		return "n.type = ?", []interface{}{ast.Value}, "", nil
 
 	case "tag":
+		if strings.Contains(ast.Value, "*") {
+			// Glob: tag:project:* matches every project tag
+			pattern := strings.ReplaceAll(likeEscaper.Replace(ast.Value), "*", "%")
+			return `n.id IN (SELECT node_id FROM tags WHERE tag LIKE ? ESCAPE '\')`, []interface{}{pattern}, "", nil
+		}
 		return "n.id IN (SELECT node_id FROM tags WHERE tag = ?)", []interface{}{ast.Value}, "", nil
 
+	case "tier":
+		return buildPredicate(&QueryAST{Type: "predicate", Key: "tag", Value: "tier:" + ast.Value}, postgres)
+
+	case "project":
+		return buildProjectFilter(ast.Value, postgres)
+
+	case "lang":
+		if !langRe.MatchString(ast.Value) {
+			return "", nil, "", fmt.Errorf("invalid lang: value %q (use a language code such as en or de)", ast.Value)
+		}
+		// COALESCE so NOT lang:xx keeps the nodes without a lang
+		where, args, joins, err := buildMetaFilter("lang="+ast.Value, postgres)
+		return "COALESCE(" + where + ", FALSE)", args, joins, err
+
+	case "ref":
+		return buildRefFilter(ast.Value, postgres)
+
 	case "created":
 		return buildTimeFilter("n.created_at", ast.Operator, ast.Value)
 
 	case "updated":
 		return buildTimeFilter("n.updated_at", ast.Operator, ast.Value)
 
+	case "accessed":
+		// accessed:>7d is read in the last week; NOT accessed:>90d also
+		// matches nodes never read at all
+		where, args, joins, err := buildTimeFilter("last_accessed_at", ast.Operator, ast.Value)
+		if err != nil {
+			return "", nil, "", err
+		}
+		return "n.id IN (SELECT node_id FROM node_access WHERE " + where + ")", args, joins, nil
+
 	case "tokens":
 		op := ast.Operator
 		if op == "" {
@@ -157,46 +388,291 @@ func buildPredicate(ast *QueryAST) (string, []interface{}, string, error) {
 			return "n.summary IS NOT NULL", nil, "", nil
 		case "edges":
 			return "(EXISTS (SELECT 1 FROM edges WHERE from_id = n.id) OR EXISTS (SELECT 1 FROM edges WHERE to_id = n.id))", nil, "", nil
+		case "tags":
+			return "EXISTS (SELECT 1 FROM tags WHERE node_id = n.id)", nil, "", nil
+		case "metadata":
+			return "COALESCE(n.metadata, '') NOT IN ('', '{}', 'null')", nil, "", nil
 		default:
 			return "", nil, "", fmt.Errorf("unknown has value: %s", ast.Value)
 		}
 
+	case "superseded":
+		switch ast.Value {
+		case "true":
+			return "n.superseded_by IS NOT NULL", nil, "", nil
+		case "false":
+			return "n.superseded_by IS NULL", nil, "", nil
+		default:
+			return "", nil, "", fmt.Errorf("invalid superseded value: %s (use true or false)", ast.Value)
+		}
+
 	case "from":
 		return "n.id IN (SELECT to_id FROM edges WHERE from_id = ?)", []interface{}{ast.Value}, "", nil
 
 	case "to":
 		return "n.id IN (SELECT from_id FROM edges WHERE to_id = ?)", []interface{}{ast.Value}, "", nil
 
+	case "related":
+		return buildRelatedFilter(ast)
+
+	case "content":
+		if ast.Value == "" {
+			return "", nil, "", fmt.Errorf("empty content: value")
+		}
+		// Case-insensitive substring; % and _ in the value match literally
+		pattern := "%" + likeEscaper.Replace(strings.ToLower(ast.Value)) + "%"
+		return `LOWER(n.content) LIKE ? ESCAPE '\'`, []interface{}{pattern}, "", nil
+
+	case "meta":
+		return buildMetaFilter(ast.Value, postgres)
+
+	case "fts":
+		if strings.TrimSpace(ast.Value) == "" {
+			return "", nil, "", fmt.Errorf("empty fts: value")
+		}
+		if postgres {
+			return "n.search_vector @@ plainto_tsquery('english', ?)", []interface{}{ast.Value}, "", nil
+		}
+		return "n.rowid IN (SELECT rowid FROM nodes_fts WHERE nodes_fts MATCH ?)", []interface{}{ftsMatch(ast.Value)}, "", nil
+
 	default:
 		return "", nil, "", fmt.Errorf("unknown key: %s", ast.Key)
 	}
 }
 
+// metaRe splits a meta: value into a dotted key path, an optional
+// comparison and the value compared against.
+var metaRe = regexp.MustCompile(`^([A-Za-z0-9_-]+(?:\.[A-Za-z0-9_-]+)*)(?:(!=|>=|<=|=|>|<)(.*))?$`)
+
+// langRe matches the language codes lang: accepts, such as en or pt-BR.
+var langRe = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// buildProjectFilter matches the nodes that load in project the way
+// compose decides it: nodes tagged with the project (in any case) or with
+// project:global, and nodes without any project tag. A glob such as
+// project:* matches project tags only, like tag:project:*.
+func buildProjectFilter(project string, postgres bool) (string, []interface{}, string, error) {
+	if project == "" {
+		return "", nil, "", fmt.Errorf("empty project: value")
+	}
+	if strings.Contains(project, "*") {
+		return buildPredicate(&QueryAST{Type: "predicate", Key: "tag", Value: "project:" + project}, postgres)
+	}
+	where := `(n.id IN (SELECT node_id FROM tags WHERE LOWER(tag) = ? OR tag = 'project:global')` +
+		` OR NOT EXISTS (SELECT 1 FROM tags WHERE node_id = n.id AND tag LIKE 'project:%'))`
+	return where, []interface{}{"project:" + strings.ToLower(project)}, "", nil
+}
+
+// validMetadata is n.metadata for SQLite's JSON functions, NULL when the
+// stored text is not valid JSON so one malformed row reads as having no
+// keys instead of failing the whole query.
+const validMetadata = "(CASE WHEN json_valid(n.metadata) THEN n.metadata END)"
+
+// buildMetaFilter matches a key in the metadata JSON: meta:key for keys
+// that are set, meta:key=value and meta:key!=value comparing as text
+// (true and false match JSON booleans), and >, <, >=, <= comparing numbers.
+// Dotted keys reach into nested objects.
+func buildMetaFilter(value string, postgres bool) (string, []interface{}, string, error) {
+	m := metaRe.FindStringSubmatch(value)
+	if m == nil {
+		return "", nil, "", fmt.Errorf("invalid meta predicate: %s (use meta:key, meta:key=value or meta:key>number)", value)
+	}
+	path, op, want := strings.Split(m[1], "."), m[2], m[3]
+
+	// field renders the key's value as text, or NULL when it is missing
+	var field string
+	var pathArg interface{}
+	if postgres {
+		field = "(n.metadata::jsonb #>> ?::text[])"
+		pathArg = "{" + strings.Join(path, ",") + "}"
+	} else {
+		field = "CAST(json_extract(" + validMetadata + ", ?) AS TEXT)"
+		pathArg = "$." + strings.Join(path, ".")
+	}
+
+	switch op {
+	case "":
+		return field + " IS NOT NULL", []interface{}{pathArg}, "", nil
+	case "=", "!=":
+		if !postgres && (want == "true" || want == "false") {
+			// json_extract returns JSON booleans as 1 and 0
+			want = map[string]string{"true": "1", "false": "0"}[want]
+		}
+		if op == "!=" {
+			return "COALESCE(" + field + " <> ?, TRUE)", []interface{}{pathArg, want}, "", nil
+		}
+		return field + " = ?", []interface{}{pathArg, want}, "", nil
+	default:
+		num, err := strconv.ParseFloat(want, 64)
+		if err != nil {
+			return "", nil, "", fmt.Errorf("invalid meta predicate: %s (%s needs a number)", value, op)
+		}
+		if postgres {
+			// Only numeric values compare; casting anything else would fail
+			return fmt.Sprintf("(CASE WHEN jsonb_typeof(n.metadata::jsonb #> ?::text[]) = 'number' THEN %s::numeric END) %s ?", field, op),
+				[]interface{}{pathArg, pathArg, num}, "", nil
+		}
+		return fmt.Sprintf("(CASE WHEN json_type(%[1]s, ?) IN ('integer', 'real') THEN json_extract(%[1]s, ?) END) %[2]s ?", validMetadata, op),
+			[]interface{}{pathArg, pathArg, num}, "", nil
+	}
+}
+
+// buildRefFilter matches the nodes whose "refs" metadata holds an issue or
+// pull request reference: ref:org/repo#123 for that one, ref:org/repo for
+// any in the repository.
+func buildRefFilter(value string, postgres bool) (string, []interface{}, string, error) {
+	// Refs set by hand to something other than a list, like malformed
+	// metadata, match nothing
+	elems := `json_each(CASE WHEN json_type(` + validMetadata + `, '$.refs') = 'array'
+		THEN json_extract(` + validMetadata + `, '$.refs') ELSE '[]' END) r`
+	if postgres {
+		elems = `jsonb_array_elements_text(CASE WHEN jsonb_typeof(n.metadata::jsonb -> 'refs') = 'array'
+			THEN n.metadata::jsonb -> 'refs' ELSE '[]'::jsonb END) AS r(value)`
+	}
+	value = strings.ToLower(value)
+	if !strings.Contains(value, "#") {
+		repo := strings.TrimSuffix(value, ".git")
+		if !refs.Valid(repo, true) {
+			return "", nil, "", fmt.Errorf("invalid ref: value %q (use org/repo#123 or org/repo)", value)
+		}
+		return "EXISTS (SELECT 1 FROM " + elems + ` WHERE r.value LIKE ? ESCAPE '\')`,
+			[]interface{}{likeEscaper.Replace(repo) + "#%"}, "", nil
+	}
+	ref := refs.Normalize(value)
+	if !refs.Valid(ref, false) {
+		return "", nil, "", fmt.Errorf("invalid ref: value %q (use org/repo#123 or org/repo)", value)
+	}
+	return "EXISTS (SELECT 1 FROM " + elems + " WHERE r.value = ?)", []interface{}{ref}, "", nil
+}
+
+// buildRelatedFilter matches the nodes within ast.Depth hops of the node
+// ast.Value, following edges either way as ctx_related does, restricted to
+// the ast.Via edge types when given. The node itself is left out.
+func buildRelatedFilter(ast *QueryAST) (string, []interface{}, string, error) {
+	if ast.Value == "" {
+		return "", nil, "", fmt.Errorf("empty related: value")
+	}
+	depth := ast.Depth
+	if depth == 0 {
+		depth = 1
+	}
+	args := []interface{}{ast.Value, depth}
+	via := ""
+	if len(ast.Via) > 0 {
+		via = " AND e.type IN (?" + strings.Repeat(", ?", len(ast.Via)-1) + ")"
+		for _, t := range ast.Via {
+			args = append(args, t)
+		}
+	}
+	args = append(args, ast.Value)
+	return `n.id IN (WITH RECURSIVE reach(id, depth) AS (
+		SELECT CAST(? AS TEXT), 0
+		UNION
+		SELECT CASE WHEN e.from_id = r.id THEN e.to_id ELSE e.from_id END, r.depth + 1
+		FROM reach r JOIN edges e ON e.from_id = r.id OR e.to_id = r.id
+		WHERE r.depth < ?` + via + `
+	) SELECT id FROM reach WHERE id <> ?)`, args, "", nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// ftsMatch turns an fts: value into an FTS5 query matching its words in
+// order (a phrase), quoted so punctuation is literal. A trailing * keeps
+// prefix matching: fts:postgre* matches "postgres".
+func ftsMatch(value string) string {
+	prefix := strings.HasSuffix(value, "*")
+	value = strings.TrimSuffix(value, "*")
+	match := `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	if prefix {
+		match += "*"
+	}
+	return match
+}
+
 func buildTimeFilter(column, op, value string) (string, []interface{}, string, error) {
+	if from, to, ok := strings.Cut(value, ".."); ok {
+		return buildTimeRange(column, op, from, to)
+	}
 	if op == "" {
 		op = ">"
 	}
 
-	// Check if it's an absolute date
-	if strings.Contains(value, "-") {
-		// Absolute date like 2024-01-01
-		t, err := time.Parse("2006-01-02", value)
+	// An absolute date like 2024-01-01, or a relative duration like 24h,
+	// 7d or 1w: created:>24h means "created in the last 24 hours", so
+	// created_at > now-24h
+	t, err := parseTimeBound(value)
+	if err != nil {
+		return "", nil, "", err
+	}
+	return fmt.Sprintf("%s %s ?", column, op), []interface{}{t.Format(time.RFC3339)}, "", nil
+}
+
+// buildTimeRange matches times from the start of from up to the end of to:
+// created:2024-03-01..2024-03-31 covers all of March, and either end may be
+// left open (created:2024-03-01.., created:..30d). Relative bounds count
+// back from now, so created:30d..7d is between 30 and 7 days ago.
+func buildTimeRange(column, op, from, to string) (string, []interface{}, string, error) {
+	if op != "" {
+		return "", nil, "", fmt.Errorf("a date range takes no operator: %s%s..%s", op, from, to)
+	}
+	if from == "" && to == "" {
+		return "", nil, "", fmt.Errorf("empty date range: give at least one end, e.g. 2024-01-01..")
+	}
+
+	var conds []string
+	var args []interface{}
+	var start, end time.Time
+	if from != "" {
+		t, err := parseTimeBound(from)
 		if err != nil {
-			return "", nil, "", fmt.Errorf("invalid date: %s", value)
+			return "", nil, "", err
 		}
-		// Flip operator: created:>2024-01-01 means "created after this date"
-		return fmt.Sprintf("%s %s ?", column, op), []interface{}{t.UTC().Format(time.RFC3339)}, "", nil
+		start = t
+		conds = append(conds, column+" >= ?")
+		args = append(args, t.Format(time.RFC3339))
 	}
+	if to != "" {
+		t, err := parseTimeBound(to)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if isDate(to) {
+			t = t.AddDate(0, 0, 1) // the whole end day
+			end = t
+			conds = append(conds, column+" < ?")
+		} else {
+			end = t
+			conds = append(conds, column+" <= ?")
+		}
+		args = append(args, t.Format(time.RFC3339))
+	}
+	if from != "" && to != "" && !start.Before(end) {
+		return "", nil, "", fmt.Errorf("empty date range: %s is not before %s", from, to)
+	}
+	return "(" + strings.Join(conds, " AND ") + ")", args, "", nil
+}
 
-	// Relative duration like 24h, 7d, 1w
+// parseTimeBound resolves a time filter value: an absolute date like
+// 2024-01-01 (midnight UTC), or a duration like 24h, 7d or 1w before now.
+func parseTimeBound(value string) (time.Time, error) {
+	if isDate(value) {
+		t, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date: %s", value)
+		}
+		return t.UTC(), nil
+	}
 	dur, err := parseDuration(value)
 	if err != nil {
-		return "", nil, "", fmt.Errorf("invalid duration: %s", value)
+		return time.Time{}, fmt.Errorf("invalid duration: %s", value)
 	}
+	return time.Now().Add(-dur).UTC(), nil
+}
 
-	threshold := time.Now().Add(-dur).UTC().Format(time.RFC3339)
-	// For relative: created:>24h means "created in the last 24 hours", so created_at > threshold
-	return fmt.Sprintf("%s %s ?", column, op), []interface{}{threshold}, "", nil
+// isDate reports whether a time filter value is an absolute date rather
+// than a duration.
+func isDate(value string) bool {
+	return strings.Contains(value, "-")
 }
 
 func parseDuration(s string) (time.Duration, error) {
@@ -0,0 +1,535 @@
+package query_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/testutil"
+)
+
+func ids(nodes []*db.Node) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.ID)
+	}
+	return out
+}
+
+func TestExecuteQuery_Modifiers(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// Three facts created a day apart, the oldest the largest and the
+	// newest the least recently updated
+	base := time.Now().Add(-7 * 24 * time.Hour).UTC()
+	contents := []string{"oldest fact with quite a lot more content than the others", "middle fact", "newest"}
+	var nodes []*db.Node
+	for i, content := range contents {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: content})
+		require.NoError(t, err)
+		created := base.Add(time.Duration(i) * 24 * time.Hour)
+		updated := base.Add(time.Duration(6-i) * 24 * time.Hour)
+		_, err = d.Exec("UPDATE nodes SET created_at = ?, updated_at = ? WHERE id = ?",
+			created.Format(time.RFC3339), updated.Format(time.RFC3339), n.ID)
+		require.NoError(t, err)
+		nodes = append(nodes, n)
+	}
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "not a fact"})
+	require.NoError(t, err)
+	oldest, middle, newest := nodes[0].ID, nodes[1].ID, nodes[2].ID
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"type:fact", []string{newest, middle, oldest}},
+		{"type:fact sort:created asc", []string{oldest, middle, newest}},
+		{"type:fact sort:updated", []string{oldest, middle, newest}},
+		{"type:fact sort:tokens desc limit:1", []string{oldest}},
+		{"type:fact limit:2", []string{newest, middle}},
+		{"type:fact limit:2 offset:2", []string{oldest}},
+		{"type:fact offset:1", []string{middle, oldest}},
+		{"type:fact offset:5", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ids(got))
+		})
+	}
+
+	// Modifiers alone page through every node
+	got, err := query.ExecuteQuery(d, "sort:created asc limit:2", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{oldest, middle}, ids(got))
+}
+
+func TestExecuteQuery_ContentAndFTS(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	pg, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres for billing"})
+	require.NoError(t, err)
+	fact, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Postgres upgrades run on Sundays, 100% of the time"})
+	require.NoError(t, err)
+	react, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use React for the frontend"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"type:decision AND fts:postgres", []string{pg.ID}},
+		{"fts:postgres", []string{fact.ID, pg.ID}},
+		{"fts:postgre*", []string{fact.ID, pg.ID}},
+		{`fts:"upgrades run"`, []string{fact.ID}},
+		{`content:"postgres for"`, []string{pg.ID}},
+		{`content:"USE POSTGRES"`, []string{pg.ID}},
+		{`content:"100%"`, []string{fact.ID}},
+		{`content:"0% of"`, []string{fact.ID}},
+		{`content:"1_0"`, nil},
+		{"NOT fts:postgres AND type:decision", []string{react.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ids(got))
+		})
+	}
+
+	_, err = query.ExecuteQuery(d, `content:""`, false)
+	assert.ErrorContains(t, err, "empty content")
+}
+
+func TestExecuteQuery_TagWildcard(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	a, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Tags: []string{"project:ctx"}})
+	require.NoError(t, err)
+	b, err := d.CreateNode(db.CreateNodeInput{Type: "task", Content: "b", Tags: []string{"project:web", "task:login"}})
+	require.NoError(t, err)
+	c, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "c", Tags: []string{"projects_old", "task:login_v2"}})
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"tag:project:*", []string{b.ID, a.ID}},
+		{"tag:task:*", []string{c.ID, b.ID}},
+		{"tag:task:login_*", []string{c.ID}},
+		{"tag:*:login", []string{b.ID}},
+		{"tag:project:* AND NOT tag:task:*", []string{a.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+}
+
+func TestExecuteQuery_Meta(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	a, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a",
+		Metadata: `{"source":"cmd/add.go","confidence":0.9,"author":"Jane Doe","reviewed":true,"origin":{"tool":"hook"}}`})
+	require.NoError(t, err)
+	b, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "b",
+		Metadata: `{"source":"cmd/link.go","confidence":0.5,"reviewed":false}`})
+	require.NoError(t, err)
+	c, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "c", Metadata: `{"confidence":"high"}`})
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"meta:source=cmd/add.go", []string{a.ID}},
+		{"meta:source", []string{a.ID, b.ID}},
+		{"meta:source!=cmd/add.go", []string{b.ID, c.ID}},
+		{"meta:confidence>=0.8", []string{a.ID}},
+		{"meta:confidence<1", []string{a.ID, b.ID}},
+		{"meta:confidence=high", []string{c.ID}},
+		{`meta:author="Jane Doe"`, []string{a.ID}},
+		{"meta:reviewed=true", []string{a.ID}},
+		{"meta:reviewed=false", []string{b.ID}},
+		{"meta:origin.tool=hook AND type:fact", []string{a.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	_, err = query.ExecuteQuery(d, "meta:confidence>high", false)
+	assert.ErrorContains(t, err, "needs a number")
+	_, err = query.ExecuteQuery(d, "meta:bad$key=1", false)
+	assert.ErrorContains(t, err, "invalid meta predicate")
+}
+
+func TestExecuteQuery_MetaMalformed(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	a, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "a", Metadata: `{"confidence":0.9}`})
+	require.NoError(t, err)
+	bad, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "bad"})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET metadata = ? WHERE id = ?", `{"confidence":`, bad.ID)
+	require.NoError(t, err)
+
+	// One malformed row reads as having no keys rather than failing the query
+	for _, q := range []string{"meta:confidence", "meta:confidence=0.9", "meta:confidence>0.5"} {
+		got, err := query.ExecuteQuery(d, q, false)
+		require.NoError(t, err, q)
+		assert.Equal(t, []string{a.ID}, ids(got), q)
+	}
+	got, err := query.ExecuteQuery(d, "type:fact AND NOT meta:confidence", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{bad.ID}, ids(got))
+}
+
+func TestExecuteQuery_Related(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	create := func(typ, content string) string {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: typ, Content: content})
+		require.NoError(t, err)
+		return n.ID
+	}
+	link := func(from, to, typ string) {
+		_, err := d.CreateEdge(from, to, typ)
+		require.NoError(t, err)
+	}
+
+	// api -> schema -> root <- note, with old -> api a step further out
+	root := create("decision", "Use Postgres")
+	schema := create("decision", "Schema per tenant")
+	api := create("pattern", "Tenant-scoped API handlers")
+	old := create("fact", "Legacy handler list")
+	note := create("observation", "Postgres chosen after load tests")
+	link(schema, root, "DEPENDS_ON")
+	link(api, schema, "DEPENDS_ON")
+	link(old, api, "DERIVED_FROM")
+	link(note, root, "RELATES_TO")
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"related:" + root, []string{schema, note}},
+		{"related:" + root + " depth:2 via:DEPENDS_ON", []string{schema, api}},
+		{"related:" + root + " depth:3", []string{schema, api, old, note}},
+		{"related:" + root + " depth:3 via:DEPENDS_ON", []string{schema, api}},
+		{"related:" + root + " depth:3 via:depends_on,derived_from AND NOT type:decision", []string{api, old}},
+		{"related:" + api + " via:DEPENDS_ON", []string{schema}},
+		{"related:" + api + " via:DEPENDS_ON via:DERIVED_FROM", []string{schema, old}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	// via: takes the edge types this store knows, registered ones included
+	_, err := query.ExecuteQuery(d, "related:"+root+" via:BLOCKS", false)
+	assert.ErrorContains(t, err, `invalid edge type "BLOCKS"`)
+	_, err = d.RegisterType(db.KindEdge, "BLOCKS", "")
+	require.NoError(t, err)
+	_, err = query.ExecuteQuery(d, "related:"+root+" via:BLOCKS", false)
+	assert.NoError(t, err)
+}
+
+func TestExecuteQuery_DateRange(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	byDate := map[string]string{}
+	for _, day := range []string{"2024-02-28", "2024-03-01", "2024-03-31", "2024-04-01"} {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "made on " + day})
+		require.NoError(t, err)
+		_, err = d.Exec("UPDATE nodes SET created_at = ? WHERE id = ?", day+"T12:00:00Z", n.ID)
+		require.NoError(t, err)
+		byDate[day] = n.ID
+	}
+	recent, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "made today"})
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"created:2024-03-01..2024-03-31", []string{byDate["2024-03-01"], byDate["2024-03-31"]}},
+		{"created:2024-03-31..", []string{byDate["2024-03-31"], byDate["2024-04-01"], recent.ID}},
+		{"created:..2024-02-28", []string{byDate["2024-02-28"]}},
+		{"created:..1d AND type:decision", []string{byDate["2024-02-28"], byDate["2024-03-01"], byDate["2024-03-31"], byDate["2024-04-01"]}},
+		{"created:2d..", []string{recent.ID}},
+		{"updated:2024-03-01..2024-03-31", nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	for q, want := range map[string]string{
+		"created:2024-04-01..2024-03-01": "is not before",
+		"created:..":                     "give at least one end",
+		"created:>2024-01-01..":          "takes no operator",
+		"created:2024-13-01..":           "invalid date",
+	} {
+		_, err := query.ExecuteQuery(d, q, false)
+		assert.ErrorContains(t, err, want, q)
+	}
+}
+
+func TestExecuteQuery_Accessed(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	recent := testutil.CreateNode(t, d, "fact", "read today")
+	stale := testutil.CreateNode(t, d, "fact", "read last month")
+	never := testutil.CreateNode(t, d, "fact", "never read")
+	require.NoError(t, d.RecordAccess([]string{recent.ID, stale.ID}))
+	_, err := d.Exec("UPDATE node_access SET last_accessed_at = ? WHERE node_id = ?",
+		time.Now().Add(-30*24*time.Hour).UTC().Format(time.RFC3339), stale.ID)
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"accessed:>7d", []string{recent.ID}},
+		{"accessed:<7d", []string{stale.ID}},
+		{"accessed:60d..7d", []string{stale.ID}},
+		{"NOT accessed:>7d", []string{stale.ID, never.ID}},
+		{"type:fact AND NOT accessed:>90d", []string{never.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	_, err = query.ExecuteQuery(d, "accessed:>soon", false)
+	assert.ErrorContains(t, err, "invalid duration")
+}
+
+func TestExecuteQuery_StatePredicates(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	old, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "old", Metadata: `{"source":"hook"}`})
+	require.NoError(t, err)
+	require.NoError(t, d.AddTag(old.ID, "tier:pinned"))
+	current, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "current"})
+	require.NoError(t, err)
+	require.NoError(t, d.AddTag(current.ID, "tier:pinned"))
+	bare, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "bare", Metadata: "{}"})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", current.ID, old.ID)
+	require.NoError(t, err)
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"superseded:true", []string{old.ID}},
+		{"superseded:true AND tag:tier:pinned", []string{old.ID}},
+		{"superseded:false", []string{current.ID, bare.ID}},
+		{"NOT superseded:true", []string{current.ID, bare.ID}},
+		{"has:tags", []string{current.ID}},
+		{"has:metadata", nil},
+		{"has:metadata OR superseded:true", []string{old.ID}},
+		{"NOT has:tags", []string{bare.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	got, err := query.ExecuteQuery(d, "has:metadata", true)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{old.ID}, ids(got))
+
+	_, err = query.ExecuteQuery(d, "superseded:maybe", false)
+	assert.ErrorContains(t, err, "invalid superseded value")
+}
+
+func TestExecuteQuery_TierAndProject(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	create := func(tags ...string) string {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "x", Tags: tags})
+		require.NoError(t, err)
+		return n.ID
+	}
+	pinnedCtx := create("tier:pinned", "project:ctx")
+	refOther := create("tier:reference", "project:other")
+	global := create("tier:pinned", "project:global")
+	unscoped := create("tier:working")
+	mixedCase := create("project:CTX")
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"tier:pinned", []string{pinnedCtx, global}},
+		{"tier:*", []string{pinnedCtx, refOther, global, unscoped}},
+		{"project:ctx", []string{pinnedCtx, global, unscoped, mixedCase}},
+		{"project:other AND tier:reference", []string{refOther}},
+		{"project:ctx AND NOT tier:pinned", []string{unscoped, mixedCase}},
+		{"project:global", []string{global, unscoped}},
+		{"project:*", []string{pinnedCtx, refOther, global, mixedCase}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+}
+
+func TestExecuteQuery_Budget(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	create := func(tokens int, tags ...string) string {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "x", Tags: tags})
+		require.NoError(t, err)
+		_, err = d.Exec("UPDATE nodes SET token_estimate = ? WHERE id = ?", tokens, n.ID)
+		require.NoError(t, err)
+		return n.ID
+	}
+	working := create(100, "tier:working")
+	pinned := create(300, "tier:pinned")
+	untiered := create(50)
+	reference := create(200, "tier:reference")
+	newerPinned := create(100, "tier:pinned")
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"budget:10000", []string{newerPinned, pinned, reference, working, untiered}},
+		{"budget:600", []string{newerPinned, pinned, reference}},
+		// Stops at the working node rather than skipping to the smaller one
+		{"budget:650", []string{newerPinned, pinned, reference}},
+		{"budget:50", nil},
+		{"budget:700 limit:2", []string{newerPinned, pinned}},
+		{"budget:200 offset:2", []string{reference}},
+		{"NOT tier:pinned budget:300", []string{reference, working}},
+		{"sort:tokens asc budget:150", []string{untiered, working}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ids(got))
+		})
+	}
+}
+
+func TestExecuteQuery_Sample(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	var facts []string
+	for i := 0; i < 20; i++ {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: fmt.Sprintf("fact %d", i)})
+		require.NoError(t, err)
+		facts = append(facts, n.ID)
+	}
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "not a fact"})
+	require.NoError(t, err)
+
+	seen := map[string]bool{}
+	for i := 0; i < 10; i++ {
+		got, err := query.ExecuteQuery(d, "type:fact sample:3 sort:created asc", false)
+		require.NoError(t, err)
+		require.Len(t, got, 3)
+		assert.Subset(t, facts, ids(got))
+		assert.True(t, got[0].ID < got[1].ID && got[1].ID < got[2].ID, "the sample is sorted")
+		for _, n := range got {
+			seen[n.ID] = true
+		}
+	}
+	assert.Greater(t, len(seen), 3, "samples differ between runs")
+
+	got, err := query.ExecuteQuery(d, "type:fact sample:50", false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, facts, ids(got), "a sample larger than the matches returns them all")
+}
+
+func TestExecuteQuery_Lang(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	de, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Wir sollten den Cache nicht für die Sitzung verwenden, weil er auf dem Server ist."})
+	require.NoError(t, err)
+	en, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "We should not use the cache for the session, because it is on the server."})
+	require.NoError(t, err)
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "cache"})
+	require.NoError(t, err)
+
+	got, err := query.ExecuteQuery(d, "lang:de", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{de.ID}, ids(got))
+	got, err = query.ExecuteQuery(d, "type:fact AND NOT lang:de", false)
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Contains(t, ids(got), en.ID)
+
+	// A node with malformed metadata has no lang and breaks nothing
+	bad, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Wir bleiben bei der Sitzung, weil der Server sie hat."})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET metadata = ? WHERE id = ?", `{"lang":"de"`, bad.ID)
+	require.NoError(t, err)
+	got, err = query.ExecuteQuery(d, "lang:de", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{de.ID}, ids(got))
+
+	_, err = query.ExecuteQuery(d, `lang:"en us"`, false)
+	assert.ErrorContains(t, err, "invalid lang")
+}
+
+func TestExecuteQuery_Ref(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	pr, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Reverted https://github.com/Zate/Memdown/pull/42 because it broke sync"})
+	require.NoError(t, err)
+	issue, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "zate/memdown#7 tracks the flaky hook test"})
+	require.NoError(t, err)
+	other, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "See zate/memdown-ui#42"})
+	require.NoError(t, err)
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "PR 42 is unrelated", Metadata: `{"refs":"zate/memdown#42"}`})
+	require.NoError(t, err)
+
+	got, err := query.ExecuteQuery(d, "ref:Zate/Memdown#42", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{pr.ID}, ids(got))
+	got, err = query.ExecuteQuery(d, "ref:zate/memdown", false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{pr.ID, issue.ID}, ids(got))
+	got, err = query.ExecuteQuery(d, "ref:zate/memdown-ui#42 OR type:decision", false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{pr.ID, other.ID}, ids(got))
+
+	bad, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "zate/memdown#42 again"})
+	require.NoError(t, err)
+	_, err = d.Exec("UPDATE nodes SET metadata = ? WHERE id = ?", `{"refs":["zate/memdown#42"]`, bad.ID)
+	require.NoError(t, err)
+	got, err = query.ExecuteQuery(d, "ref:zate/memdown#42", false)
+	require.NoError(t, err, "one malformed row does not break ref recall")
+	assert.Equal(t, []string{pr.ID}, ids(got))
+
+	_, err = query.ExecuteQuery(d, "ref:memdown#42", false)
+	assert.ErrorContains(t, err, "invalid ref")
+}
+
+func TestMentions(t *testing.T) {
+	assert.True(t, query.Mentions("type:fact AND NOT accessed:>7d", "accessed"))
+	assert.True(t, query.Mentions("@stale OR type:fact", "accessed"), "a saved query might")
+	assert.False(t, query.Mentions("type:fact limit:5", "accessed"))
+	assert.False(t, query.Mentions("", "accessed"))
+	assert.False(t, query.Mentions("type:(", "accessed"))
+}
@@ -2,30 +2,83 @@ package query
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"unicode"
+
+	"github.com/zate/ctx/internal/validate"
 )
 
 // QueryAST represents a parsed query expression.
 type QueryAST struct {
-	Type     string    `json:"type"` // "predicate", "and", "or", "not"
-	Key      string    `json:"key,omitempty"`
-	Operator string    `json:"operator,omitempty"`
-	Value    string    `json:"value,omitempty"`
-	Left     *QueryAST `json:"left,omitempty"`
-	Right    *QueryAST `json:"right,omitempty"`
-	Child    *QueryAST `json:"child,omitempty"`
+	Type     string `json:"type"` // "predicate", "and", "or", "not"
+	Key      string `json:"key,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	Value    string `json:"value,omitempty"`
+	// Depth and Via qualify a related: predicate: how many hops to follow
+	// (1 when unset) and which edge types to follow (any when empty).
+	Depth int       `json:"depth,omitempty"`
+	Via   []string  `json:"via,omitempty"`
+	Left  *QueryAST `json:"left,omitempty"`
+	Right *QueryAST `json:"right,omitempty"`
+	Child *QueryAST `json:"child,omitempty"`
 }
 
 var validKeys = map[string]bool{
-	"type":    true,
-	"tag":     true,
+	"type":       true,
+	"tag":        true,
+	"created":    true,
+	"updated":    true,
+	"accessed":   true,
+	"tokens":     true,
+	"has":        true,
+	"superseded": true,
+	"tier":       true,
+	"project":    true,
+	"lang":       true,
+	"ref":        true,
+	"from":       true,
+	"to":         true,
+	"content":    true,
+	"fts":        true,
+	"meta":       true,
+	"related":    true,
+}
+
+// maxRelatedDepth bounds related: traversals.
+const maxRelatedDepth = 10
+
+// Modifiers order, page and bound a query's results. They follow the
+// expression, e.g. "type:decision sort:updated asc limit:10 offset:20".
+type Modifiers struct {
+	// Sort is the field results are ordered by: created (the default),
+	// updated or tokens.
+	Sort string `json:"sort,omitempty"`
+	// Asc orders smallest/oldest first; by default results are
+	// largest/newest first.
+	Asc bool `json:"asc,omitempty"`
+	// Limit caps the results returned; 0 returns all of them.
+	Limit int `json:"limit,omitempty"`
+	// Offset skips results before the first one returned.
+	Offset int `json:"offset,omitempty"`
+	// Budget caps the summed token estimate of the results; 0 leaves it
+	// unbounded. Without a Sort, a budgeted query returns pinned, then
+	// reference, then working nodes first, as compose does.
+	Budget int `json:"budget,omitempty"`
+	// Sample picks this many matches at random, which Sort and Budget
+	// then apply to; 0 returns every match.
+	Sample int `json:"sample,omitempty"`
+}
+
+// IsZero reports whether m leaves results in the default order, unpaged.
+func (m Modifiers) IsZero() bool {
+	return m == Modifiers{}
+}
+
+var sortFields = map[string]bool{
 	"created": true,
 	"updated": true,
 	"tokens":  true,
-	"has":     true,
-	"from":    true,
-	"to":      true,
 }
 
 type parser struct {
@@ -40,7 +93,8 @@ const (
 	tokenColon
 	tokenLParen
 	tokenRParen
-	tokenOp // >, <, >=, <=
+	tokenOp     // >, <, >=, <=
+	tokenString // "quoted text", without the quotes
 	tokenEOF
 )
 
@@ -49,29 +103,44 @@ type token struct {
 	value string
 }
 
-// Parse parses a query string into an AST.
+// Parse parses a query string into an AST, ignoring any modifiers.
 func Parse(input string) (*QueryAST, error) {
+	ast, _, err := ParseWithModifiers(input)
+	return ast, err
+}
+
+// ParseWithModifiers parses a query string into an AST and the modifiers
+// that follow it. The expression may be left out ("sort:tokens limit:5"),
+// in which case the AST is nil.
+func ParseWithModifiers(input string) (*QueryAST, Modifiers, error) {
+	var mods Modifiers
 	input = strings.TrimSpace(input)
 	if input == "" {
-		return nil, nil
+		return nil, mods, nil
 	}
 
 	tokens, err := tokenize(input)
 	if err != nil {
-		return nil, err
+		return nil, mods, err
 	}
 
 	p := &parser{tokens: tokens}
-	ast, err := p.parseExpr()
-	if err != nil {
-		return nil, err
+	var ast *QueryAST
+	if !p.atModifier() {
+		ast, err = p.parseExpr()
+		if err != nil {
+			return nil, mods, err
+		}
+	}
+	if err := p.parseModifiers(&mods); err != nil {
+		return nil, mods, err
 	}
 
 	if p.pos < len(p.tokens) && p.tokens[p.pos].typ != tokenEOF {
-		return nil, fmt.Errorf("unexpected token at position %d: %s", p.pos, p.tokens[p.pos].value)
+		return nil, mods, fmt.Errorf("unexpected token at position %d: %s", p.pos, p.tokens[p.pos].value)
 	}
 
-	return ast, nil
+	return ast, mods, nil
 }
 
 func tokenize(input string) ([]token, error) {
@@ -101,6 +170,15 @@ func tokenize(input string) ([]token, error) {
 			i++
 			continue
 		}
+		if ch == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote at position %d", i)
+			}
+			tokens = append(tokens, token{tokenString, input[i+1 : i+1+end]})
+			i += end + 2
+			continue
+		}
 		if ch == '>' || ch == '<' {
 			if i+1 < len(input) && input[i+1] == '=' {
 				tokens = append(tokens, token{tokenOp, string(input[i : i+2])})
@@ -116,7 +194,7 @@ func tokenize(input string) ([]token, error) {
 		start := i
 		for i < len(input) {
 			c := rune(input[i])
-			if unicode.IsSpace(c) || c == '(' || c == ')' || c == ':' {
+			if unicode.IsSpace(c) || c == '(' || c == ')' || c == ':' || c == '"' {
 				break
 			}
 			// Allow > and < only at start of word context (operators), not mid-word
@@ -150,6 +228,78 @@ func (p *parser) next() token {
 	return t
 }
 
+// atModifier reports whether the next tokens start a sort, limit, offset,
+// budget or sample modifier.
+func (p *parser) atModifier() bool {
+	if p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].typ != tokenColon {
+		return false
+	}
+	switch p.tokens[p.pos].value {
+	case "sort", "limit", "offset", "budget", "sample":
+		return p.tokens[p.pos].typ == tokenWord
+	}
+	return false
+}
+
+func (p *parser) parseModifiers(mods *Modifiers) error {
+	seen := map[string]bool{}
+	for p.atModifier() {
+		key := p.next().value
+		p.next() // colon
+		if seen[key] {
+			return fmt.Errorf("%s: given twice", key)
+		}
+		seen[key] = true
+
+		value := p.next()
+		if value.typ != tokenWord {
+			return fmt.Errorf("expected value after %s:", key)
+		}
+		switch key {
+		case "sort":
+			if !sortFields[value.value] {
+				return fmt.Errorf("unknown sort field: %s (use created, updated or tokens)", value.value)
+			}
+			mods.Sort = value.value
+			if t := p.peek(); t.typ == tokenWord {
+				switch strings.ToLower(t.value) {
+				case "asc":
+					mods.Asc = true
+					p.next()
+				case "desc":
+					p.next()
+				}
+			}
+		case "limit", "offset":
+			n, err := strconv.Atoi(value.value)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s: %s", key, value.value)
+			}
+			if key == "limit" {
+				mods.Limit = n
+			} else {
+				mods.Offset = n
+			}
+		case "budget":
+			n, err := strconv.Atoi(value.value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid budget: %s (use a positive token count)", value.value)
+			}
+			mods.Budget = n
+		case "sample":
+			n, err := strconv.Atoi(value.value)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid sample: %s (use a positive node count)", value.value)
+			}
+			mods.Sample = n
+		}
+	}
+	if mods.Sample > 0 && (mods.Limit > 0 || mods.Offset > 0) {
+		return fmt.Errorf("sample: cannot be combined with limit: or offset:, since a sample is already its own size")
+	}
+	return nil
+}
+
 func (p *parser) parseExpr() (*QueryAST, error) {
 	left, err := p.parseTerm()
 	if err != nil {
@@ -209,6 +359,16 @@ func (p *parser) parseFactor() (*QueryAST, error) {
 		return expr, nil
 	}
 
+	// @name refers to a saved query, expanded when the query runs
+	if t.typ == tokenWord && strings.HasPrefix(t.value, "@") {
+		p.next()
+		name := t.value[1:]
+		if !savedNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid saved query reference: %s", t.value)
+		}
+		return &QueryAST{Type: "ref", Value: name}, nil
+	}
+
 	return p.parsePredicate()
 }
 
@@ -218,6 +378,9 @@ func (p *parser) parsePredicate() (*QueryAST, error) {
 		return nil, fmt.Errorf("expected key, got %q", key.value)
 	}
 
+	if key.value == "depth" || key.value == "via" {
+		return nil, fmt.Errorf("%s: qualifies a related: predicate, e.g. related:<id> depth:2 via:DEPENDS_ON", key.value)
+	}
 	if !validKeys[key.value] {
 		return nil, fmt.Errorf("unknown key: %s", key.value)
 	}
@@ -239,12 +402,78 @@ func (p *parser) parsePredicate() (*QueryAST, error) {
 		return nil, fmt.Errorf("expected value after %s:", key.value)
 	}
 
-	return &QueryAST{
+	// meta: keeps its comparison in the value: meta:confidence>=0.8 and
+	// meta:author="Jane Doe" tokenize as several tokens
+	if key.value == "meta" {
+		switch t := p.peek(); {
+		case t.typ == tokenOp:
+			op := p.next().value
+			rest, err := p.readValue()
+			if err != nil {
+				return nil, fmt.Errorf("expected value after meta:%s%s", value, op)
+			}
+			value += op + rest
+		case t.typ == tokenString && strings.HasSuffix(value, "="):
+			value += p.next().value
+		}
+	}
+
+	ast := &QueryAST{
 		Type:     "predicate",
 		Key:      key.value,
 		Operator: operator,
 		Value:    value,
-	}, nil
+	}
+	if key.value == "related" {
+		if err := p.parseRelated(ast); err != nil {
+			return nil, err
+		}
+	}
+	return ast, nil
+}
+
+// atQualifier reports whether the next tokens start a depth: or via:
+// qualifier.
+func (p *parser) atQualifier() bool {
+	if p.pos+1 >= len(p.tokens) || p.tokens[p.pos+1].typ != tokenColon {
+		return false
+	}
+	t := p.tokens[p.pos]
+	return t.typ == tokenWord && (t.value == "depth" || t.value == "via")
+}
+
+// parseRelated reads the depth: and via: qualifiers that may follow
+// related:<id>. via: takes a comma-separated list and may be repeated.
+func (p *parser) parseRelated(ast *QueryAST) error {
+	for p.atQualifier() {
+		key := p.next().value
+		p.next() // colon
+		value := p.next()
+		if value.typ != tokenWord {
+			return fmt.Errorf("expected value after %s:", key)
+		}
+		switch key {
+		case "depth":
+			if ast.Depth != 0 {
+				return fmt.Errorf("depth: given twice")
+			}
+			n, err := strconv.Atoi(value.value)
+			if err != nil || n < 1 || n > maxRelatedDepth {
+				return fmt.Errorf("invalid depth: %s (use 1 to %d)", value.value, maxRelatedDepth)
+			}
+			ast.Depth = n
+		case "via":
+			for _, t := range strings.Split(value.value, ",") {
+				t = strings.ToUpper(strings.TrimSpace(t))
+				// Whether the store knows the type is checked when it runs
+				if err := validate.EdgeTypeName(t); err != nil {
+					return err
+				}
+				ast.Via = append(ast.Via, t)
+			}
+		}
+	}
+	return nil
 }
 
 func (p *parser) readValue() (string, error) {
@@ -252,10 +481,13 @@ func (p *parser) readValue() (string, error) {
 	if t.typ == tokenEOF {
 		return "", fmt.Errorf("unexpected end of input")
 	}
-	if t.typ != tokenWord {
+	if t.typ != tokenWord && t.typ != tokenString {
 		return "", fmt.Errorf("unexpected token: %q", t.value)
 	}
 
+	if t.typ == tokenString {
+		return t.value, nil
+	}
 	value := t.value
 
 	// Continue reading colon-separated parts for tag values like project:ctx
@@ -12,8 +12,25 @@ func FuzzQueryParser(f *testing.F) {
 	f.Add("type:fact AND type:fact AND type:fact")
 	f.Add("((((type:fact))))")
 	f.Add("created:>24h")
+	f.Add("created:2024-01-01..2024-02-01 OR updated:..7d")
 	f.Add("tokens:<1000")
 	f.Add("has:summary")
+	f.Add("superseded:true AND has:tags AND NOT has:metadata")
+	f.Add("tier:pinned AND project:ctx")
+	f.Add("type:fact sort:updated asc limit:10 offset:20")
+	f.Add("tier:reference budget:2000")
+	f.Add("type:fact sample:5 sort:tokens asc")
+	f.Add(`type:decision AND (fts:postgres OR content:"use x")`)
+	f.Add("related:01HV3K2M depth:2 via:DEPENDS_ON AND NOT type:fact")
+	f.Add("AND")
+	f.Add("NOT NOT OR")
+	f.Add("()")
+	f.Add(")(")
+	f.Add(`content:"unterminated`)
+	f.Add(`"`)
+	f.Add("type:")
+	f.Add(":")
+	f.Add("limit:-1 offset:99999999999999999999")
 
 	f.Fuzz(func(t *testing.T, input string) {
 		// Should never panic, regardless of input
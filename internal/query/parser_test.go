@@ -126,6 +126,86 @@ func TestQueryParser(t *testing.T) {
 				Value: "tier:reference",
 			},
 		},
+		{
+			name:  "tag wildcard",
+			input: "tag:project:*",
+			wantAST: &QueryAST{
+				Type:  "predicate",
+				Key:   "tag",
+				Value: "project:*",
+			},
+		},
+		{
+			name:  "meta comparison",
+			input: "meta:confidence>=0.8 AND meta:author=\"Jane Doe\"",
+			wantAST: &QueryAST{
+				Type:  "and",
+				Left:  &QueryAST{Type: "predicate", Key: "meta", Value: "confidence>=0.8"},
+				Right: &QueryAST{Type: "predicate", Key: "meta", Value: "author=Jane Doe"},
+			},
+		},
+		{
+			name:  "quoted content phrase",
+			input: `content:"use postgres: not mysql"`,
+			wantAST: &QueryAST{
+				Type:  "predicate",
+				Key:   "content",
+				Value: "use postgres: not mysql",
+			},
+		},
+		{
+			name:  "fts with structured filter",
+			input: "type:decision AND fts:postgres",
+			wantAST: &QueryAST{
+				Type:  "and",
+				Left:  &QueryAST{Type: "predicate", Key: "type", Value: "decision"},
+				Right: &QueryAST{Type: "predicate", Key: "fts", Value: "postgres"},
+			},
+		},
+		{
+			name:  "related with depth and via",
+			input: "related:01HV3K2M depth:2 via:DEPENDS_ON,derived_from AND type:decision",
+			wantAST: &QueryAST{
+				Type: "and",
+				Left: &QueryAST{Type: "predicate", Key: "related", Value: "01HV3K2M", Depth: 2,
+					Via: []string{"DEPENDS_ON", "DERIVED_FROM"}},
+				Right: &QueryAST{Type: "predicate", Key: "type", Value: "decision"},
+			},
+		},
+		{
+			name:    "related depth out of range",
+			input:   "related:01HV3K2M depth:11",
+			wantErr: true,
+		},
+		{
+			name:    "related via invalid edge type",
+			input:   "related:01HV3K2M via:BLOCKS-ON",
+			wantErr: true,
+		},
+		{
+			name:    "depth without related",
+			input:   "type:fact AND depth:2",
+			wantErr: true,
+		},
+		{
+			name:  "saved query reference",
+			input: "@open-decisions AND NOT tag:archived",
+			wantAST: &QueryAST{
+				Type:  "and",
+				Left:  &QueryAST{Type: "ref", Value: "open-decisions"},
+				Right: &QueryAST{Type: "not", Child: &QueryAST{Type: "predicate", Key: "tag", Value: "archived"}},
+			},
+		},
+		{
+			name:    "invalid saved query reference",
+			input:   "@ AND type:fact",
+			wantErr: true,
+		},
+		{
+			name:    "malformed - unterminated quote",
+			input:   `content:"no end`,
+			wantErr: true,
+		},
 		{
 			name:  "complex query",
 			input: "type:fact AND (tag:tier:reference OR tag:tier:working)",
@@ -152,3 +232,43 @@ func TestQueryParser(t *testing.T) {
 		})
 	}
 }
+
+func TestParseWithModifiers(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		wantAST  bool
+		wantMods Modifiers
+		wantErr  string
+	}{
+		{name: "no modifiers", input: "type:fact", wantAST: true},
+		{name: "sort defaults to descending", input: "type:fact sort:updated", wantAST: true, wantMods: Modifiers{Sort: "updated"}},
+		{name: "all modifiers", input: "type:fact AND tag:x sort:tokens asc limit:10 offset:20", wantAST: true,
+			wantMods: Modifiers{Sort: "tokens", Asc: true, Limit: 10, Offset: 20}},
+		{name: "explicit desc", input: "type:fact sort:created DESC limit:5", wantAST: true, wantMods: Modifiers{Sort: "created", Limit: 5}},
+		{name: "modifiers only", input: "limit:3 sort:created asc", wantMods: Modifiers{Sort: "created", Asc: true, Limit: 3}},
+		{name: "unknown sort field", input: "type:fact sort:content", wantErr: "unknown sort field"},
+		{name: "negative limit", input: "type:fact limit:-1", wantErr: "invalid limit"},
+		{name: "bad offset", input: "offset:ten", wantErr: "invalid offset"},
+		{name: "repeated modifier", input: "limit:1 limit:2", wantErr: "given twice"},
+		{name: "modifier before expression", input: "limit:1 type:fact", wantErr: "unexpected token"},
+		{name: "budget", input: "tier:reference budget:2000 limit:5", wantAST: true, wantMods: Modifiers{Budget: 2000, Limit: 5}},
+		{name: "zero budget", input: "budget:0", wantErr: "invalid budget"},
+		{name: "sample", input: "tier:reference sample:5 sort:updated", wantAST: true, wantMods: Modifiers{Sample: 5, Sort: "updated"}},
+		{name: "bad sample", input: "sample:-2", wantErr: "invalid sample"},
+		{name: "sample with limit", input: "sample:5 limit:2", wantErr: "cannot be combined"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, mods, err := ParseWithModifiers(tc.input)
+			if tc.wantErr != "" {
+				assert.ErrorContains(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAST, ast != nil)
+			assert.Equal(t, tc.wantMods, mods)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package query
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// Saved is a named query expression stored in the queries table. Other
+// queries use it as @name, e.g. "@open-decisions AND tag:project:ctx".
+type Saved struct {
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ErrNoSavedQuery is returned by GetSaved when no query has the name.
+var ErrNoSavedQuery = errors.New("saved query not found")
+
+// maxRefDepth bounds how deeply saved queries may refer to each other.
+const maxRefDepth = 8
+
+var savedNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+
+// SaveQuery stores expr as name, replacing any query of that name. expr
+// must parse, and every @name it uses must exist without referring back
+// to name; modifiers such as sort and limit belong where the query is used.
+func SaveQuery(d db.Store, name, expr string) error {
+	if !savedNameRe.MatchString(name) {
+		return fmt.Errorf("invalid query name %q: use letters, digits, - and _, starting with a letter", name)
+	}
+	ast, mods, err := ParseWithModifiers(expr)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	if ast == nil {
+		return fmt.Errorf("invalid query: empty expression")
+	}
+	if !mods.IsZero() {
+		return fmt.Errorf("invalid query: saved queries hold expressions only; add modifiers such as sort and limit where @%s is used", name)
+	}
+	if _, err := expandRefs(d, ast, []string{name}); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = d.Exec(`INSERT INTO queries (name, query, created_at, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET query = excluded.query, updated_at = excluded.updated_at`,
+		name, expr, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save query: %w", err)
+	}
+	return nil
+}
+
+// GetSaved loads the saved query called name.
+func GetSaved(d db.Store, name string) (*Saved, error) {
+	s := &Saved{Name: name}
+	var created, updated string
+	err := d.QueryRow("SELECT query, created_at, updated_at FROM queries WHERE name = ?", name).Scan(&s.Query, &created, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: @%s", ErrNoSavedQuery, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load saved query %s: %w", name, err)
+	}
+	s.CreatedAt, _ = time.Parse(time.RFC3339, created)
+	s.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+	return s, nil
+}
+
+// ListSaved returns every saved query, by name.
+func ListSaved(d db.Store) ([]*Saved, error) {
+	rows, err := d.Query("SELECT name, query, created_at, updated_at FROM queries ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list saved queries: %w", err)
+	}
+	defer rows.Close()
+	var out []*Saved
+	for rows.Next() {
+		s := &Saved{}
+		var created, updated string
+		if err := rows.Scan(&s.Name, &s.Query, &created, &updated); err != nil {
+			return nil, fmt.Errorf("failed to scan saved query: %w", err)
+		}
+		s.CreatedAt, _ = time.Parse(time.RFC3339, created)
+		s.UpdatedAt, _ = time.Parse(time.RFC3339, updated)
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSaved removes the saved query called name.
+func DeleteSaved(d db.Store, name string) error {
+	res, err := d.Exec("DELETE FROM queries WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved query: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: @%s", ErrNoSavedQuery, name)
+	}
+	return nil
+}
+
+// expandRefs replaces each @name in ast with the saved query's expression.
+// stack holds the names being expanded, to reject a query that refers to
+// itself.
+func expandRefs(d db.Store, ast *QueryAST, stack []string) (*QueryAST, error) {
+	if ast == nil {
+		return nil, nil
+	}
+	switch ast.Type {
+	case "ref":
+		for _, name := range stack {
+			if name == ast.Value {
+				return nil, fmt.Errorf("@%s refers to itself", ast.Value)
+			}
+		}
+		if len(stack) >= maxRefDepth {
+			return nil, fmt.Errorf("@%s: saved queries nested more than %d deep", ast.Value, maxRefDepth)
+		}
+		saved, err := GetSaved(d, ast.Value)
+		if err != nil {
+			return nil, err
+		}
+		ref, err := Parse(saved.Query)
+		if err != nil {
+			return nil, fmt.Errorf("saved query @%s: %w", ast.Value, err)
+		}
+		return expandRefs(d, ref, append(stack, ast.Value))
+	case "and", "or":
+		left, err := expandRefs(d, ast.Left, stack)
+		if err != nil {
+			return nil, err
+		}
+		right, err := expandRefs(d, ast.Right, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &QueryAST{Type: ast.Type, Left: left, Right: right}, nil
+	case "not":
+		child, err := expandRefs(d, ast.Child, stack)
+		if err != nil {
+			return nil, err
+		}
+		return &QueryAST{Type: "not", Child: child}, nil
+	}
+	return ast, nil
+}
@@ -0,0 +1,81 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestSavedQueries(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	dec, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres", Tags: []string{"project:ctx"}})
+	require.NoError(t, err)
+	fact, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Postgres 16 is deployed", Tags: []string{"project:ctx"}})
+	require.NoError(t, err)
+	other, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use React"})
+	require.NoError(t, err)
+
+	require.NoError(t, query.SaveQuery(d, "decisions", "type:decision"))
+	require.NoError(t, query.SaveQuery(d, "ctx-knowledge", "tag:project:ctx AND (@decisions OR type:fact)"))
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"@decisions", []string{dec.ID, other.ID}},
+		{"@decisions AND NOT tag:project:ctx", []string{other.ID}},
+		{"@ctx-knowledge", []string{dec.ID, fact.ID}},
+		{"@ctx-knowledge sort:created asc limit:1", []string{dec.ID}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			got, err := query.ExecuteQuery(d, tc.query, false)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.want, ids(got))
+		})
+	}
+
+	// Saving again replaces the expression
+	require.NoError(t, query.SaveQuery(d, "decisions", "type:decision AND fts:react"))
+	got, err := query.ExecuteQuery(d, "@decisions", false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{other.ID}, ids(got))
+
+	saved, err := query.ListSaved(d)
+	require.NoError(t, err)
+	require.Len(t, saved, 2)
+	assert.Equal(t, "ctx-knowledge", saved[0].Name)
+	assert.Equal(t, "type:decision AND fts:react", saved[1].Query)
+
+	require.NoError(t, query.DeleteSaved(d, "decisions"))
+	_, err = query.ExecuteQuery(d, "@ctx-knowledge", false)
+	assert.ErrorIs(t, err, query.ErrNoSavedQuery)
+	assert.ErrorIs(t, query.DeleteSaved(d, "decisions"), query.ErrNoSavedQuery)
+}
+
+func TestSaveQueryRejects(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	require.NoError(t, query.SaveQuery(d, "a", "type:fact"))
+	require.NoError(t, query.SaveQuery(d, "b", "@a OR type:decision"))
+
+	for name, expr := range map[string]string{
+		"1st":   "type:fact",
+		"loop":  "@loop AND type:fact",
+		"a":     "@b",
+		"paged": "type:fact limit:5",
+		"bad":   "type:",
+		"ghost": "@missing",
+		"empty": "",
+	} {
+		assert.Error(t, query.SaveQuery(d, name, expr), name)
+	}
+	assert.ErrorContains(t, query.SaveQuery(d, "a", "@b"), "@a refers to itself")
+
+	got, err := query.GetSaved(d, "a")
+	require.NoError(t, err)
+	assert.Equal(t, "type:fact", got.Query, "rejected saves change nothing")
+}
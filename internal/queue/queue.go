@@ -0,0 +1,154 @@
+// Package queue is a small work queue kept in the pending table. Hooks
+// enqueue heavy work (deferred commands, sync) so their synchronous path
+// stays fast; `ctx hook flush` drains the queue, once or as a daemon.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// keyPrefix namespaces job keys in the pending table. Keys end in a ULID,
+// so sorting them gives enqueue order.
+const keyPrefix = "queue:"
+
+// MaxAttempts is how many times a failing job runs before it is dropped.
+const MaxAttempts = 3
+
+// Job is one unit of queued work.
+type Job struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	Attempts   int             `json:"attempts,omitempty"`
+	LastError  string          `json:"last_error,omitempty"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+}
+
+// Handler runs a job of one kind.
+type Handler func(d db.Store, payload json.RawMessage) error
+
+// Result summarizes a Drain.
+type Result struct {
+	Done    int // jobs that succeeded
+	Retried int // jobs that failed and were requeued
+	Dropped int // jobs that failed MaxAttempts times or have no handler
+}
+
+// Enqueue adds a job of kind with payload marshalled to JSON.
+func Enqueue(d db.Store, kind string, payload any) error {
+	job := Job{ID: db.NewID(), Kind: kind, EnqueuedAt: time.Now().UTC()}
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s job: %w", kind, err)
+		}
+		job.Payload = data
+	}
+	return save(d, job)
+}
+
+// List returns the queued jobs in enqueue order. Entries that don't parse
+// are skipped.
+func List(d db.Store) ([]Job, error) {
+	raw, err := d.ListPending(keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	jobs := make([]Job, 0, len(keys))
+	for _, key := range keys {
+		var job Job
+		if json.Unmarshal([]byte(raw[key]), &job) != nil {
+			continue
+		}
+		job.ID = strings.TrimPrefix(key, keyPrefix)
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Drain runs every queued job with the handler for its kind, stopping early
+// once ctx is done. Each job is claimed by deleting its row first, so two
+// concurrent drains never run the same job. A failing job is requeued until
+// it has run MaxAttempts times; report, if set, is told about each failure.
+func Drain(ctx context.Context, d db.Store, handlers map[string]Handler, report func(Job, error)) (Result, error) {
+	var res Result
+	jobs, err := List(d)
+	if err != nil {
+		return res, err
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		claimed, err := claim(d, job.ID)
+		if err != nil {
+			return res, err
+		}
+		if !claimed {
+			continue
+		}
+
+		handler, ok := handlers[job.Kind]
+		if !ok {
+			res.Dropped++
+			if report != nil {
+				report(job, fmt.Errorf("no handler for job kind %q", job.Kind))
+			}
+			continue
+		}
+		runErr := handler(d, job.Payload)
+		if runErr == nil {
+			res.Done++
+			continue
+		}
+
+		if report != nil {
+			report(job, runErr)
+		}
+		job.Attempts++
+		job.LastError = runErr.Error()
+		if job.Attempts >= MaxAttempts {
+			res.Dropped++
+			continue
+		}
+		if err := save(d, job); err != nil {
+			return res, err
+		}
+		res.Retried++
+	}
+	return res, nil
+}
+
+func save(d db.Store, job Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return d.SetPending(keyPrefix+job.ID, string(data))
+}
+
+// claim deletes the job's row, reporting whether this caller removed it.
+func claim(d db.Store, id string) (bool, error) {
+	res, err := d.Exec("DELETE FROM pending WHERE key = ?", keyPrefix+id)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job %s: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,86 @@
+package queue_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/queue"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestDrain_RunsJobsInOrder(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	require.NoError(t, queue.Enqueue(d, "echo", "first"))
+	require.NoError(t, queue.Enqueue(d, "echo", "second"))
+	require.NoError(t, queue.Enqueue(d, "unknown", nil))
+
+	var seen []string
+	handlers := map[string]queue.Handler{
+		"echo": func(_ db.Store, payload json.RawMessage) error {
+			var s string
+			require.NoError(t, json.Unmarshal(payload, &s))
+			seen = append(seen, s)
+			return nil
+		},
+	}
+	res, err := queue.Drain(context.Background(), d, handlers, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, seen)
+	assert.Equal(t, queue.Result{Done: 2, Dropped: 1}, res)
+
+	jobs, err := queue.List(d)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestDrain_RetriesThenDrops(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	require.NoError(t, queue.Enqueue(d, "flaky", nil))
+
+	handlers := map[string]queue.Handler{
+		"flaky": func(db.Store, json.RawMessage) error { return errors.New("server down") },
+	}
+	var reported int
+	report := func(queue.Job, error) { reported++ }
+
+	for i := 1; i < queue.MaxAttempts; i++ {
+		res, err := queue.Drain(context.Background(), d, handlers, report)
+		require.NoError(t, err)
+		assert.Equal(t, 1, res.Retried)
+
+		jobs, err := queue.List(d)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.Equal(t, i, jobs[0].Attempts)
+		assert.Equal(t, "server down", jobs[0].LastError)
+	}
+
+	res, err := queue.Drain(context.Background(), d, handlers, report)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Dropped)
+	assert.Equal(t, queue.MaxAttempts, reported)
+
+	jobs, err := queue.List(d)
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestDrain_StopsWhenCancelled(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	require.NoError(t, queue.Enqueue(d, "echo", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res, err := queue.Drain(ctx, d, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, queue.Result{}, res)
+
+	jobs, err := queue.List(d)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1, "the job stays queued")
+}
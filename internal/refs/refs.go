@@ -0,0 +1,69 @@
+// Package refs finds references to GitHub and GitLab issues, pull requests
+// and merge requests in text and normalizes them to org/repo#123, the form
+// stored in a node's "refs" metadata and matched by ref: queries.
+package refs
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// githubRe matches issue and pull request URLs on github.com.
+	githubRe = regexp.MustCompile(`https?://(?:www\.)?github\.com/([A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+)/(?:issues|pull|pulls)/(\d+)`)
+	// gitlabRe matches issue and merge request URLs on gitlab.com or a
+	// self-hosted GitLab, whose project paths may nest subgroups.
+	gitlabRe = regexp.MustCompile(`https?://[A-Za-z0-9.-]+(?::\d+)?/((?:[A-Za-z0-9_.-]+/)+[A-Za-z0-9_.-]+)/-/(?:issues|merge_requests)/(\d+)`)
+	// shortRe matches references already written as org/repo#123.
+	shortRe = regexp.MustCompile(`(?:^|[\s(\[])((?:[A-Za-z0-9_.-]+/)+[A-Za-z0-9_.-]+)#(\d+)\b`)
+	// refRe matches a normalized reference, or with repo set just the
+	// org/repo part of one.
+	refRe  = regexp.MustCompile(`^(?:[a-z0-9_.-]+/)+[a-z0-9_.-]+#[0-9]+$`)
+	repoRe = regexp.MustCompile(`^(?:[a-z0-9_.-]+/)+[a-z0-9_.-]+$`)
+)
+
+// Extract returns the issue, pull request and merge request references in
+// text, normalized and lowercased, without duplicates and in order of first
+// mention. Both URLs and org/repo#123 shorthand are recognized.
+func Extract(text string) []string {
+	type match struct {
+		at  int
+		ref string
+	}
+	var found []match
+	for _, re := range []*regexp.Regexp{githubRe, gitlabRe, shortRe} {
+		for _, m := range re.FindAllStringSubmatchIndex(text, -1) {
+			ref := Normalize(text[m[2]:m[3]] + "#" + text[m[4]:m[5]])
+			found = append(found, match{m[2], ref})
+		}
+	}
+
+	// Each pattern was matched separately; restore the order of mention
+	sort.SliceStable(found, func(i, j int) bool { return found[i].at < found[j].at })
+	var out []string
+	seen := map[string]bool{}
+	for _, f := range found {
+		if !seen[f.ref] {
+			seen[f.ref] = true
+			out = append(out, f.ref)
+		}
+	}
+	return out
+}
+
+// Normalize lowercases a reference and drops a trailing .git from its
+// repository, as hosts treat both as the same project.
+func Normalize(ref string) string {
+	repo, num, _ := strings.Cut(strings.ToLower(ref), "#")
+	return strings.TrimSuffix(repo, ".git") + "#" + num
+}
+
+// Valid reports whether ref is a normalized reference such as
+// zate/memdown#42, or, with repoOnly, a repository such as zate/memdown.
+func Valid(ref string, repoOnly bool) bool {
+	if repoOnly {
+		return repoRe.MatchString(ref)
+	}
+	return refRe.MatchString(ref)
+}
@@ -0,0 +1,39 @@
+package refs_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zate/ctx/internal/refs"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"github pull", "Merged https://github.com/Zate/Memdown/pull/42 today", []string{"zate/memdown#42"}},
+		{"github issue", "see https://www.github.com/zate/memdown/issues/7#issuecomment-1", []string{"zate/memdown#7"}},
+		{"gitlab merge request", "https://gitlab.com/acme/infra/deploy/-/merge_requests/12", []string{"acme/infra/deploy#12"}},
+		{"self-hosted gitlab", "https://git.example.com:8443/team/app/-/issues/3", []string{"team/app#3"}},
+		{"shorthand", "Fixes zate/memdown#9 (and [org/repo.go#10])", []string{"zate/memdown#9", "org/repo.go#10"}},
+		{"order and duplicates", "org/b#2, https://github.com/org/a/pull/1 and Org/B#2 again", []string{"org/b#2", "org/a#1"}},
+		{"dot git", "https://github.com/org/repo.git/pull/5", []string{"org/repo#5"}},
+		{"not a reference", "path/to/file#L20 is fine but a#1 and https://github.com/org/repo are not", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, refs.Extract(tt.text))
+		})
+	}
+}
+
+func TestValid(t *testing.T) {
+	assert.True(t, refs.Valid("zate/memdown#42", false))
+	assert.True(t, refs.Valid("acme/infra/deploy#1", false))
+	assert.False(t, refs.Valid("memdown#42", false))
+	assert.False(t, refs.Valid("zate/memdown", false))
+	assert.True(t, refs.Valid("zate/memdown", true))
+	assert.False(t, refs.Valid("Zate/Memdown", true), "refs are lowercase")
+}
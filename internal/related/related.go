@@ -0,0 +1,160 @@
+// Package related connects new knowledge to the rest of the graph: it finds
+// the nodes a node's content refers to by ID, and suggests nodes about the
+// same things, so remembering builds edges instead of isolated nodes.
+package related
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// EdgeType is the edge LinkRefs creates.
+const EdgeType = "RELATES_TO"
+
+// maxTerms is how many content terms Suggest searches for.
+const maxTerms = 6
+
+// idRe matches ULID-like strings of 8 to 26 characters (8 being the short
+// IDs compose and the CLI print): Crockford base32, uppercase, starting
+// with a timestamp digit. Candidates are checked against the store.
+var idRe = regexp.MustCompile(`\b[0-7][0-9A-HJKMNP-TV-Z]{7,25}\b`)
+
+// RefIDs returns the IDs of existing nodes that content mentions, by full
+// ID or by a unique prefix of at least 8 characters, in order of first
+// mention.
+func RefIDs(d db.Store, content string) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, ref := range idRe.FindAllString(content, -1) {
+		if !strings.ContainsAny(ref, "0123456789") || !strings.ContainsFunc(ref, unicode.IsLetter) {
+			continue
+		}
+		id, err := d.ResolveID(ref)
+		if err != nil {
+			continue // no such node, or a prefix several nodes share
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// LinkRefs creates a RELATES_TO edge from the node fromID to each other
+// node content mentions by ID, and returns the IDs it linked.
+func LinkRefs(d db.Store, fromID, content string) ([]string, error) {
+	ids := RefIDs(d, content)
+	existing, err := d.GetEdgesFrom(fromID)
+	if err != nil {
+		return nil, err
+	}
+	linked := map[string]bool{}
+	for _, e := range existing {
+		if e.Type == EdgeType {
+			linked[e.ToID] = true
+		}
+	}
+
+	var out []string
+	for _, id := range ids {
+		if id == fromID || linked[id] {
+			continue
+		}
+		if _, err := d.CreateEdge(fromID, id, EdgeType); err != nil {
+			return out, err
+		}
+		out = append(out, id)
+	}
+	return out, nil
+}
+
+// Suggest returns up to k current nodes that share the most distinctive
+// terms with n's content, best first, leaving out n and the nodes it
+// already links to. Each term is searched separately, so it works the same
+// on both backends' full-text search.
+func Suggest(d db.Store, n *db.Node, k int) ([]*db.Node, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	skip := map[string]bool{n.ID: true}
+	edges, err := d.GetEdgesFrom(n.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range edges {
+		skip[e.ToID] = true
+	}
+
+	scores := map[string]int{}
+	nodes := map[string]*db.Node{}
+	for _, term := range Terms(n.Content, maxTerms) {
+		hits, err := d.Search(`"` + term + `"`)
+		if err != nil {
+			return nil, err
+		}
+		for _, hit := range hits {
+			if skip[hit.ID] || hit.SupersededBy != nil {
+				continue
+			}
+			scores[hit.ID]++
+			nodes[hit.ID] = hit
+		}
+	}
+
+	out := make([]*db.Node, 0, len(nodes))
+	for _, hit := range nodes {
+		out = append(out, hit)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if scores[out[i].ID] != scores[out[j].ID] {
+			return scores[out[i].ID] > scores[out[j].ID]
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	if len(out) > k {
+		out = out[:k]
+	}
+	return out, nil
+}
+
+// Terms returns up to max distinctive words of content: lowercased,
+// at least four letters, not common English words, longest first.
+func Terms(content string, max int) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) < 4 || stopwords[w] || seen[w] || idRe.MatchString(strings.ToUpper(w)) {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	if len(terms) > max {
+		terms = terms[:max]
+	}
+	return terms
+}
+
+var stopwords = map[string]bool{
+	"about": true, "after": true, "also": true, "because": true, "been": true,
+	"before": true, "being": true, "between": true, "both": true, "could": true,
+	"does": true, "each": true, "even": true, "from": true, "have": true,
+	"here": true, "into": true, "just": true, "like": true, "made": true,
+	"make": true, "many": true, "more": true, "most": true, "much": true,
+	"must": true, "need": true, "only": true, "other": true, "over": true,
+	"same": true, "should": true, "since": true, "some": true, "still": true,
+	"such": true, "than": true, "that": true, "their": true, "them": true,
+	"then": true, "there": true, "these": true, "they": true, "this": true,
+	"those": true, "through": true, "under": true, "until": true,
+	"used": true, "uses": true, "using": true, "very": true, "want": true,
+	"were": true, "what": true, "when": true, "where": true, "which": true,
+	"while": true, "will": true, "with": true, "would": true, "your": true,
+}
@@ -0,0 +1,76 @@
+package related_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/related"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestLinkRefs(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	// IDs minted in the same millisecond share all but their last
+	// characters, so space the nodes out to give b a unique prefix
+	a, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres for the billing store"})
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+	b, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Billing traffic doubled"})
+	require.NoError(t, err)
+	time.Sleep(2 * time.Millisecond)
+
+	content := "Follows from " + a.ID + " and [fact:" + b.ID[:16] + "]; not 01ZZZZZZZZZZ or DEADBEEF01"
+	n, err := d.CreateNode(db.CreateNodeInput{Type: "observation", Content: content})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{a.ID, b.ID}, related.RefIDs(d, content))
+
+	linked, err := related.LinkRefs(d, n.ID, content+" "+n.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID, b.ID}, linked, "self references are skipped")
+
+	edges, err := d.GetEdgesFrom(n.ID)
+	require.NoError(t, err)
+	require.Len(t, edges, 2)
+	assert.Equal(t, related.EdgeType, edges[0].Type)
+
+	linked, err = related.LinkRefs(d, n.ID, content)
+	require.NoError(t, err)
+	assert.Empty(t, linked, "existing links are not duplicated")
+}
+
+func TestSuggest(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	best, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Billing service moves to Postgres replicas"})
+	require.NoError(t, err)
+	some, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Postgres upgrades happen on Sundays"})
+	require.NoError(t, err)
+	linked, err := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Billing replicas lag under load"})
+	require.NoError(t, err)
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Frontend uses React"})
+	require.NoError(t, err)
+
+	n, err := d.CreateNode(db.CreateNodeInput{Type: "observation", Content: "Billing on Postgres replicas needs tuning"})
+	require.NoError(t, err)
+	_, err = d.CreateEdge(n.ID, linked.ID, "RELATES_TO")
+	require.NoError(t, err)
+
+	got, err := related.Suggest(d, n, 5)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, best.ID, got[0].ID, "most shared terms first")
+	assert.Equal(t, some.ID, got[1].ID)
+
+	got, err = related.Suggest(d, n, 1)
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, []string{"postgres", "billing", "store"},
+		related.Terms("Use Postgres for the billing store, which is what we want", 5))
+	assert.Len(t, related.Terms("alpha bravo charlie delta", 2), 2)
+}
@@ -0,0 +1,304 @@
+// Package report builds the memory report: a markdown digest of how the
+// store changed over a period (new decisions, superseded knowledge, where
+// tokens grew) and the questions still open, for people who don't read
+// the graph directly. ctx report writes one on demand and ctx serve can
+// write one on a schedule.
+package report
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/stats"
+)
+
+// DefaultLimit is the number of entries listed per section.
+const DefaultLimit = 10
+
+// DefaultPeriod is how far back a report looks when Options.Since is
+// unset: a nightly report covers the day before it.
+const DefaultPeriod = 24 * time.Hour
+
+// Options controls Generate.
+type Options struct {
+	Since     time.Time // start of the period; DefaultPeriod ago when zero
+	Limit     int       // entries per section; DefaultLimit when <= 0
+	Agent     string    // agent scope, as for other commands
+	AllAgents bool      // ignore agent scoping (server-side view)
+}
+
+// Entry is one node listed in a report.
+type Entry struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Preview   string    `json:"preview"`
+	Tokens    int       `json:"tokens"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Supersession is a node replaced during the period.
+type Supersession struct {
+	Old Entry `json:"old"`
+	New Entry `json:"new"`
+}
+
+// Growth is how many tokens a node or project gained during the period.
+// A node created in the period gained all of its tokens.
+type Growth struct {
+	Entry
+	Growth int `json:"growth"`
+}
+
+// ProjectGrowth sums Growth over the nodes tagged with a project.
+type ProjectGrowth struct {
+	Project string `json:"project"`
+	Nodes   int    `json:"nodes"`
+	Growth  int    `json:"growth"`
+}
+
+// Report is a digest of the store's changes since Since.
+type Report struct {
+	Since       time.Time `json:"since"`
+	GeneratedAt time.Time `json:"generated_at"`
+	// Nodes and Tokens count the active (not superseded) store.
+	Nodes  int `json:"nodes"`
+	Tokens int `json:"tokens"`
+	// NewNodes counts active nodes created in the period.
+	NewNodes   int             `json:"new_nodes"`
+	Decisions  []Entry         `json:"decisions"`
+	Superseded []Supersession  `json:"superseded"`
+	Growth     []Growth        `json:"growth"`
+	Projects   []ProjectGrowth `json:"projects"`
+	// OpenQuestions are all unresolved open-question nodes, oldest first,
+	// up to the limit; OpenQuestionCount counts every one of them.
+	OpenQuestions     []Entry `json:"open_questions"`
+	OpenQuestionCount int     `json:"open_question_count"`
+}
+
+// Generate builds a report of the changes to d since opts.Since.
+func Generate(d db.Store, opts Options) (*Report, error) {
+	now := time.Now().UTC()
+	since := opts.Since
+	if since.IsZero() {
+		since = now.Add(-DefaultPeriod)
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	nodes, err := d.ListNodes(db.ListOptions{IncludeSuperseded: true})
+	if err != nil {
+		return nil, err
+	}
+	if !opts.AllAgents {
+		nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+	}
+	startTokens, err := tokensAtStart(d, since)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Report{Since: since.UTC(), GeneratedAt: now}
+	byID := make(map[string]*db.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	projects := map[string]*ProjectGrowth{}
+	for _, n := range nodes {
+		isNew := !n.CreatedAt.Before(since)
+		if n.SupersededBy != nil {
+			if next := byID[*n.SupersededBy]; next != nil && !next.CreatedAt.Before(since) {
+				r.Superseded = append(r.Superseded, Supersession{Old: entry(n), New: entry(next)})
+			}
+			continue
+		}
+
+		r.Nodes++
+		r.Tokens += n.TokenEstimate
+		if isNew {
+			r.NewNodes++
+			if n.Type == "decision" {
+				r.Decisions = append(r.Decisions, entry(n))
+			}
+		}
+		if n.Type == "open-question" {
+			r.OpenQuestions = append(r.OpenQuestions, entry(n))
+		}
+
+		growth := 0
+		if isNew {
+			growth = n.TokenEstimate
+		} else if start, ok := startTokens[n.ID]; ok {
+			growth = n.TokenEstimate - start
+		}
+		if growth <= 0 {
+			continue
+		}
+		r.Growth = append(r.Growth, Growth{Entry: entry(n), Growth: growth})
+		for _, project := range projectsOf(n) {
+			p := projects[project]
+			if p == nil {
+				p = &ProjectGrowth{Project: project}
+				projects[project] = p
+			}
+			p.Nodes++
+			p.Growth += growth
+		}
+	}
+
+	sort.SliceStable(r.Decisions, func(i, j int) bool { return r.Decisions[i].ID > r.Decisions[j].ID })
+	sort.SliceStable(r.Superseded, func(i, j int) bool { return r.Superseded[i].New.ID > r.Superseded[j].New.ID })
+	sort.SliceStable(r.Growth, func(i, j int) bool {
+		if r.Growth[i].Growth != r.Growth[j].Growth {
+			return r.Growth[i].Growth > r.Growth[j].Growth
+		}
+		return r.Growth[i].ID < r.Growth[j].ID
+	})
+	for _, p := range projects {
+		r.Projects = append(r.Projects, *p)
+	}
+	sort.Slice(r.Projects, func(i, j int) bool {
+		if r.Projects[i].Growth != r.Projects[j].Growth {
+			return r.Projects[i].Growth > r.Projects[j].Growth
+		}
+		return r.Projects[i].Project < r.Projects[j].Project
+	})
+	sort.SliceStable(r.OpenQuestions, func(i, j int) bool { return r.OpenQuestions[i].ID < r.OpenQuestions[j].ID })
+	r.OpenQuestionCount = len(r.OpenQuestions)
+
+	r.Decisions = truncate(r.Decisions, limit)
+	r.Superseded = truncate(r.Superseded, limit)
+	r.Growth = truncate(r.Growth, limit)
+	r.Projects = truncate(r.Projects, limit)
+	r.OpenQuestions = truncate(r.OpenQuestions, limit)
+	return r, nil
+}
+
+// tokensAtStart returns the token estimate each node edited since since
+// had before its first edit in the period: revisions hold a node's prior
+// state, so that is its oldest revision recorded since then.
+func tokensAtStart(d db.Store, since time.Time) (map[string]int, error) {
+	rows, err := d.Query(`SELECT node_id, token_estimate FROM node_revisions
+		WHERE created_at >= ? ORDER BY id`, since.UTC().Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revisions: %w", err)
+	}
+	defer rows.Close()
+
+	start := map[string]int{}
+	for rows.Next() {
+		var nodeID string
+		var tokens int
+		if err := rows.Scan(&nodeID, &tokens); err != nil {
+			return nil, fmt.Errorf("failed to read revisions: %w", err)
+		}
+		if _, ok := start[nodeID]; !ok {
+			start[nodeID] = tokens
+		}
+	}
+	return start, rows.Err()
+}
+
+// projectsOf returns the projects n is tagged with, or "(none)".
+func projectsOf(n *db.Node) []string {
+	var projects []string
+	for _, t := range n.Tags {
+		if p, ok := strings.CutPrefix(t, "project:"); ok {
+			projects = append(projects, strings.ToLower(p))
+		}
+	}
+	if len(projects) == 0 {
+		return []string{"(none)"}
+	}
+	return projects
+}
+
+func entry(n *db.Node) Entry {
+	preview := strings.Join(strings.Fields(n.Content), " ")
+	if n.Summary != nil && *n.Summary != "" {
+		preview = *n.Summary
+	}
+	if len(preview) > 80 {
+		preview = preview[:80] + "..."
+	}
+	return Entry{ID: n.ID, Type: n.Type, Preview: preview, Tokens: n.TokenEstimate, CreatedAt: n.CreatedAt}
+}
+
+func truncate[T any](s []T, limit int) []T {
+	if len(s) > limit {
+		return s[:limit]
+	}
+	return s
+}
+
+// RenderMarkdown renders r as a markdown document, suitable for committing
+// to a repository or posting in chat.
+func RenderMarkdown(r *Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Memory report: %s to %s\n\n", r.Since.Format("2006-01-02 15:04"), r.GeneratedAt.Format("2006-01-02 15:04 MST"))
+	fmt.Fprintf(&b, "%d new nodes in this period. The store holds %d active nodes, %d tokens in all.\n",
+		r.NewNodes, r.Nodes, r.Tokens)
+
+	b.WriteString("\n## New decisions\n\n")
+	if len(r.Decisions) == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, e := range r.Decisions {
+		fmt.Fprintf(&b, "- `%s` %s\n", shortID(e.ID), e.Preview)
+	}
+
+	b.WriteString("\n## Superseded knowledge\n\n")
+	if len(r.Superseded) == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, s := range r.Superseded {
+		fmt.Fprintf(&b, "- `%s` ~~%s~~\n  → `%s` %s\n", shortID(s.Old.ID), s.Old.Preview, shortID(s.New.ID), s.New.Preview)
+	}
+
+	b.WriteString("\n## Biggest token growth\n\n")
+	if len(r.Growth) == 0 {
+		b.WriteString("None.\n")
+	} else {
+		b.WriteString("| Node | Type | Tokens | Growth | Content |\n|---|---|---:|---:|---|\n")
+		for _, g := range r.Growth {
+			fmt.Fprintf(&b, "| `%s` | %s | %d | +%d | %s |\n", shortID(g.ID), g.Type, g.Tokens, g.Growth, escapeCell(g.Preview))
+		}
+		b.WriteString("\nBy project: ")
+		for i, p := range r.Projects {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "%s +%d (%d nodes)", p.Project, p.Growth, p.Nodes)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\n## Unresolved questions\n\n")
+	if r.OpenQuestionCount == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, e := range r.OpenQuestions {
+		fmt.Fprintf(&b, "- `%s` %s (open %s)\n", shortID(e.ID), e.Preview, stats.FormatAge(r.GeneratedAt.Sub(e.CreatedAt)))
+	}
+	if more := r.OpenQuestionCount - len(r.OpenQuestions); more > 0 {
+		fmt.Fprintf(&b, "- and %d more (`ctx query type:open-question`)\n", more)
+	}
+	return b.String()
+}
+
+// shortID abbreviates a node ID the way compose does; ctx show accepts it.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,100 @@
+package report_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/report"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestGenerate(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	create := func(nodeType, content string, tags ...string) *db.Node {
+		n, err := d.CreateNode(db.CreateNodeInput{Type: nodeType, Content: content, Tags: tags})
+		require.NoError(t, err)
+		return n
+	}
+	backdate := func(n *db.Node, age time.Duration) {
+		_, err := d.Exec("UPDATE nodes SET created_at = ? WHERE id = ?", time.Now().Add(-age).UTC().Format(time.RFC3339), n.ID)
+		require.NoError(t, err)
+	}
+
+	oldDecision := create("decision", "Use MySQL for billing", "project:billing")
+	backdate(oldDecision, 30*24*time.Hour)
+	newDecision := create("decision", "Use Postgres for billing", "project:billing")
+	_, err := d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newDecision.ID, oldDecision.ID)
+	require.NoError(t, err)
+
+	grown := create("fact", "Deploys run at noon", "project:ctx")
+	backdate(grown, 10*24*time.Hour)
+	longer := "Deploys run at noon on weekdays, and never on Fridays after the freeze starts, because on-call is thin"
+	_, err = d.UpdateNode(grown.ID, db.UpdateNodeInput{Content: &longer})
+	require.NoError(t, err)
+	grown, err = d.GetNode(grown.ID)
+	require.NoError(t, err)
+
+	unchanged := create("fact", "Old and untouched")
+	backdate(unchanged, 10*24*time.Hour)
+	question := create("open-question", "Should reports go to chat?")
+	backdate(question, 3*24*time.Hour)
+
+	r, err := report.Generate(d, report.Options{Since: time.Now().Add(-24 * time.Hour)})
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, r.Nodes)
+	assert.Equal(t, 1, r.NewNodes)
+	require.Len(t, r.Decisions, 1)
+	assert.Equal(t, newDecision.ID, r.Decisions[0].ID)
+
+	require.Len(t, r.Superseded, 1)
+	assert.Equal(t, oldDecision.ID, r.Superseded[0].Old.ID)
+	assert.Equal(t, newDecision.ID, r.Superseded[0].New.ID)
+
+	require.Len(t, r.Growth, 2)
+	growth := map[string]int{}
+	for _, g := range r.Growth {
+		growth[g.ID] = g.Growth
+	}
+	assert.Equal(t, newDecision.TokenEstimate, growth[newDecision.ID], "a new node gained all its tokens")
+	assert.Positive(t, growth[grown.ID])
+	assert.Less(t, growth[grown.ID], grown.TokenEstimate, "an edited node gained only the difference")
+	assert.NotContains(t, growth, unchanged.ID)
+	projects := map[string]int{}
+	for _, p := range r.Projects {
+		projects[p.Project] = p.Growth
+	}
+	assert.Equal(t, map[string]int{"billing": growth[newDecision.ID], "ctx": growth[grown.ID]}, projects)
+
+	require.Len(t, r.OpenQuestions, 1)
+	assert.Equal(t, question.ID, r.OpenQuestions[0].ID)
+	assert.Equal(t, 1, r.OpenQuestionCount)
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	_, err := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Pipes | in content"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := d.CreateNode(db.CreateNodeInput{Type: "open-question", Content: "Question?"})
+		require.NoError(t, err)
+	}
+
+	r, err := report.Generate(d, report.Options{Limit: 2})
+	require.NoError(t, err)
+	out := report.RenderMarkdown(r)
+
+	assert.True(t, strings.HasPrefix(out, "# Memory report: "))
+	for _, section := range []string{"## New decisions", "## Superseded knowledge", "## Biggest token growth", "## Unresolved questions"} {
+		assert.Contains(t, out, section)
+	}
+	assert.Contains(t, out, "Pipes | in content", "list items keep pipes")
+	assert.Contains(t, out, `Pipes \| in content |`, "table cells escape them")
+	assert.Contains(t, out, "- and 1 more")
+	_, superseded, _ := strings.Cut(out, "## Superseded knowledge\n\n")
+	assert.True(t, strings.HasPrefix(superseded, "None.\n"))
+}
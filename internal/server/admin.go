@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+)
+
+// registerAdminAPIRoutes adds the operator API used by `ctx server admin`.
+// It authenticates with the admin password (HTTP Basic, any username)
+// rather than a device token, so operators can manage devices without
+// authorizing one first.
+func (s *Server) registerAdminAPIRoutes() {
+	s.mux.HandleFunc("GET /api/admin/devices", s.requireAdminAPI(s.handleListDevices))
+	s.mux.HandleFunc("POST /api/admin/devices/{id}/revoke", s.requireAdminAPI(s.handleRevokeDevice))
+	s.mux.HandleFunc("GET /api/admin/users", s.requireAdminAPI(s.handleListUsers))
+	s.mux.HandleFunc("GET /api/admin/stats", s.requireAdminAPI(s.handleAdminStats))
+	// Repairs change the whole store, so only the admin may run them
+	s.mux.HandleFunc("POST /api/admin/integrity/fix", s.requireAdminAPI(s.handleIntegrity))
+}
+
+// requireAdminAPI checks the admin password sent as HTTP Basic auth.
+func (s *Server) requireAdminAPI(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.config.AdminPassword == "" {
+			next(w, r)
+			return
+		}
+		_, password, ok := r.BasicAuth()
+		if !ok || !s.verifyAdminPassword(password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ctx admin"`)
+			writeError(w, http.StatusUnauthorized, "invalid admin password")
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.storeFor(r).ListUsers()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// adminStats extends the public status counts with operator detail.
+type adminStats struct {
+	TotalNodes    int   `json:"total_nodes"`
+	TotalTokens   int   `json:"total_tokens"`
+	TotalEdges    int   `json:"total_edges"`
+	UniqueTags    int   `json:"unique_tags"`
+	Users         int   `json:"users"`
+	Devices       int   `json:"devices"`
+	ActiveDevices int   `json:"active_devices"`
+	SyncVersion   int64 `json:"sync_version"`
+}
+
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	st, err := s.storeFor(r).Stats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	users, err := s.storeFor(r).ListUsers()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	devices, err := s.storeFor(r).ListDevices()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	version, err := s.storeFor(r).MaxSyncVersion()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	out := adminStats{
+		TotalNodes:  st.TotalNodes,
+		TotalTokens: st.TotalTokens,
+		TotalEdges:  st.TotalEdges,
+		UniqueTags:  st.UniqueTags,
+		Users:       len(users),
+		Devices:     st.Devices,
+		SyncVersion: version,
+	}
+	for _, d := range devices {
+		if !d.Revoked {
+			out.ActiveDevices++
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+)
+
+func adminRequest(t *testing.T, srv *Server, method, path, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if password != "" {
+		req.SetBasicAuth("admin", password)
+	}
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestAdminAPI_RequiresPassword(t *testing.T) {
+	srv, store := setupAuthTestServer(t, "secret123")
+	insertTestDevice(t, store, "laptop", "device-token", "refresh", false)
+
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, srv, "GET", "/api/admin/devices", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, adminRequest(t, srv, "GET", "/api/admin/devices", "wrong").Code)
+
+	// A device token is not an admin credential
+	req := httptest.NewRequest("GET", "/api/admin/devices", nil)
+	req.Header.Set("Authorization", "Bearer device-token")
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Nor can a device run store-wide repairs, though it can check
+	req = httptest.NewRequest("POST", "/api/admin/integrity/fix", nil)
+	req.Header.Set("Authorization", "Bearer device-token")
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	req = httptest.NewRequest("GET", "/api/integrity", nil)
+	req.Header.Set("Authorization", "Bearer device-token")
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusOK, adminRequest(t, srv, "POST", "/api/admin/integrity/fix", "secret123").Code)
+}
+
+func TestAdminAPI_Devices(t *testing.T) {
+	srv, store := setupAuthTestServer(t, "secret123")
+	id := insertTestDevice(t, store, "laptop", "device-token", "refresh", false)
+
+	w := adminRequest(t, srv, "GET", "/api/admin/devices", "secret123")
+	require.Equal(t, http.StatusOK, w.Code)
+	var devices []db.Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+	require.Len(t, devices, 1)
+	assert.Equal(t, "laptop", devices[0].Name)
+
+	w = adminRequest(t, srv, "POST", "/api/admin/devices/"+id+"/revoke", "secret123")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(t, srv, "POST", "/api/admin/devices/missing/revoke", "secret123").Code)
+
+	dev, err := store.ListDevices()
+	require.NoError(t, err)
+	assert.True(t, dev[0].Revoked)
+}
+
+func TestAdminAPI_UsersAndStats(t *testing.T) {
+	srv, store := setupAuthTestServer(t, "secret123")
+	insertTestDevice(t, store, "laptop", "tok1", "ref1", false)
+	insertTestDevice(t, store, "old", "tok2", "ref2", true)
+
+	w := adminRequest(t, srv, "GET", "/api/admin/users", "secret123")
+	require.Equal(t, http.StatusOK, w.Code)
+	var users []db.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &users))
+	require.Len(t, users, 1)
+	assert.Equal(t, "admin", users[0].Username)
+	assert.Equal(t, 2, users[0].Devices)
+
+	w = adminRequest(t, srv, "GET", "/api/admin/stats", "secret123")
+	require.Equal(t, http.StatusOK, w.Code)
+	var st adminStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &st))
+	assert.Equal(t, 1, st.Users)
+	assert.Equal(t, 2, st.Devices)
+	assert.Equal(t, 1, st.ActiveDevices)
+}
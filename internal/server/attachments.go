@@ -0,0 +1,124 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/validate"
+)
+
+// handleListAttachments lists the files attached to a node, without their
+// data.
+func (s *Server) handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	id, err := s.resolvePathID(r, r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	attachments, err := s.storeFor(r).ListAttachments(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if attachments == nil {
+		attachments = []*db.Attachment{}
+	}
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// handleAddAttachment stores the request body as a file attached to a
+// node. The name comes from the name query parameter and the media type
+// from Content-Type; without one, or for application/octet-stream, it is
+// guessed.
+func (s *Server) handleAddAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := s.resolvePathID(r, r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		writeError(w, http.StatusBadRequest, "missing name parameter")
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, db.MaxAttachmentSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, db.ErrAttachmentTooLarge.Error())
+			return
+		}
+		writeError(w, http.StatusBadRequest, "failed to read body: "+err.Error())
+		return
+	}
+	if err := s.checkQuota(r, db.Usage{AttachmentBytes: len(data)}); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	mediaType := r.Header.Get("Content-Type")
+	if mediaType == "application/octet-stream" {
+		mediaType = ""
+	}
+
+	a, err := s.storeFor(r).AddAttachment(id, name, mediaType, data)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeJSON(w, http.StatusCreated, a)
+	}
+}
+
+// handleGetAttachment downloads an attachment. It is always served as a
+// download, never rendered, so an uploaded HTML or SVG file cannot run
+// script on the server's origin.
+func (s *Server) handleGetAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := validate.ID(id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	a, data, err := s.storeFor(r).GetAttachment(id)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, "attachment not found")
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", a.MediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := validate.ID(id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	switch err := s.storeFor(r).DeleteAttachment(id); {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, "attachment not found")
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"deleted": id})
+	}
+}
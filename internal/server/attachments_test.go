@@ -0,0 +1,81 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestAttachmentEndpoints(t *testing.T) {
+	srv, store := setupTestServer(t)
+	node := testutil.CreateNode(t, store, "fact", "The invoice layout")
+
+	upload := func(name, contentType string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/nodes/"+node.ID[:10]+"/attachments?name="+name, bytes.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("invoice.html", "application/octet-stream", []byte("<html><script>alert(1)</script></html>"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var a db.Attachment
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&a))
+	assert.Equal(t, node.ID, a.NodeID)
+	assert.Equal(t, "text/html; charset=utf-8", a.MediaType, "octet-stream is sniffed")
+
+	w = doRequest(t, srv, "GET", "/api/nodes/"+node.ID+"/attachments", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list []*db.Attachment
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&list))
+	require.Len(t, list, 1)
+	assert.Equal(t, a.ID, list[0].ID)
+
+	w = doRequest(t, srv, "GET", "/api/attachments/"+a.ID, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "<html><script>alert(1)</script></html>", w.Body.String())
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename=invoice.html`, w.Header().Get("Content-Disposition"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+
+	w = doRequest(t, srv, "DELETE", "/api/attachments/"+a.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(t, srv, "GET", "/api/attachments/"+a.ID, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = doRequest(t, srv, "GET", "/api/nodes/"+node.ID+"/attachments", nil)
+	assert.Equal(t, "[]", strings.TrimSpace(w.Body.String()))
+}
+
+func TestAddAttachment_Errors(t *testing.T) {
+	srv, store := setupTestServer(t)
+	node := testutil.CreateNode(t, store, "fact", "a")
+
+	for name, tc := range map[string]struct {
+		path string
+		body []byte
+		want int
+	}{
+		"no name":      {"/api/nodes/" + node.ID + "/attachments", []byte("a"), http.StatusBadRequest},
+		"unknown node": {"/api/nodes/" + db.NewID() + "/attachments?name=a.txt", []byte("a"), http.StatusNotFound},
+		"too large":    {"/api/nodes/" + node.ID + "/attachments?name=a.bin", make([]byte, db.MaxAttachmentSize+1), http.StatusRequestEntityTooLarge},
+	} {
+		req := httptest.NewRequest("POST", tc.path, bytes.NewReader(tc.body))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Code, name)
+	}
+
+	w := doRequest(t, srv, "GET", "/api/attachments/not-an-id", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
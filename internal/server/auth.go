@@ -1,12 +1,11 @@
 package server
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/zate/ctx/internal/auth"
 	"github.com/zate/ctx/internal/db"
@@ -18,6 +17,7 @@ func (s *Server) registerAuthRoutes() {
 	s.mux.HandleFunc("POST /api/auth/device", s.handleDeviceInit)
 	s.mux.HandleFunc("POST /api/auth/token", s.handleDeviceToken)
 	s.mux.HandleFunc("POST /api/auth/refresh", s.handleTokenRefresh)
+	s.mux.HandleFunc("GET /api/auth/whoami", s.handleWhoami)
 
 	// Approval web page (admin-only via password)
 	s.mux.HandleFunc("GET /device/authorize", s.handleApprovalPage)
@@ -26,6 +26,7 @@ func (s *Server) registerAuthRoutes() {
 	// Device management (authenticated)
 	s.mux.HandleFunc("GET /api/devices", s.requireAuth(s.handleListDevices))
 	s.mux.HandleFunc("POST /api/devices/{id}/revoke", s.requireAuth(s.handleRevokeDevice))
+	s.mux.HandleFunc("POST /api/devices/{id}/rename", s.requireAuth(s.handleRenameDevice))
 }
 
 // --- Device flow initiation ---
@@ -52,18 +53,16 @@ func (s *Server) handleDeviceInit(w http.ResponseWriter, r *http.Request) {
 		req.DeviceName = "unnamed-device"
 	}
 
-	state := s.flows.Initiate(req.DeviceName)
-
-	scheme := "http"
-	if s.config.HasTLS() {
-		scheme = "https"
+	state, err := s.flows.Initiate(req.DeviceName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
-	host := r.Host
 
 	writeJSON(w, http.StatusOK, deviceInitResponse{
 		DeviceCode:      state.DeviceCode,
 		UserCode:        state.UserCode,
-		VerificationURI: fmt.Sprintf("%s://%s/device/authorize", scheme, host),
+		VerificationURI: s.baseURL(r) + "/device/authorize",
 		ExpiresIn:       int(auth.FlowTTL.Seconds()),
 		Interval:        5,
 	})
@@ -97,6 +96,7 @@ func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if state.Denied {
+		s.flows.Finish(state)
 		writeError(w, http.StatusForbidden, "access_denied")
 		return
 	}
@@ -106,6 +106,7 @@ func (s *Server) handleDeviceToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.flows.Finish(state)
 	writeJSON(w, http.StatusOK, deviceTokenResponse{
 		AccessToken:  state.Token,
 		RefreshToken: state.RefreshToken,
@@ -132,30 +133,22 @@ func (s *Server) handleTokenRefresh(w http.ResponseWriter, r *http.Request) {
 	refreshHash := auth.HashToken(req.RefreshToken)
 
 	// Verify refresh token belongs to this device
-	var deviceID string
-	var revoked bool
-	err := s.store.QueryRow(
-		"SELECT id, revoked FROM devices WHERE id = $1 AND refresh_token_hash = $2",
-		req.DeviceID, refreshHash,
-	).Scan(&deviceID, &revoked)
+	device, err := s.storeFor(r).GetDeviceByRefreshToken(req.DeviceID, refreshHash)
 	if err != nil {
 		writeError(w, http.StatusUnauthorized, "invalid_refresh_token")
 		return
 	}
-	if revoked {
+	if device.Revoked {
 		writeError(w, http.StatusForbidden, "device_revoked")
 		return
 	}
+	deviceID := device.ID
 
 	// Generate new tokens
 	newToken := auth.GenerateToken()
 	newRefresh := auth.GenerateRefreshToken()
-	now := time.Now().UTC().Format(time.RFC3339)
 
-	_, err = s.store.Exec(
-		"UPDATE devices SET token_hash = $1, refresh_token_hash = $2, last_seen = $3 WHERE id = $4",
-		auth.HashToken(newToken), auth.HashToken(newRefresh), now, deviceID,
-	)
+	err = s.storeFor(r).RotateDeviceTokens(deviceID, auth.HashToken(newToken), auth.HashToken(newRefresh))
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "failed to update tokens")
 		return
@@ -283,17 +276,17 @@ func (s *Server) handleApprovalSubmit(w http.ResponseWriter, r *http.Request) {
 	// Approve: create device record and tokens
 	token := auth.GenerateToken()
 	refreshToken := auth.GenerateRefreshToken()
-	deviceID := db.NewID()
-	now := time.Now().UTC().Format(time.RFC3339)
 
 	// Ensure admin user exists
-	userID := s.ensureAdminUser()
-
-	_, err := s.store.Exec(
-		`INSERT INTO devices (id, user_id, name, token_hash, refresh_token_hash, last_seen, created_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		deviceID, userID, state.DeviceName, auth.HashToken(token), auth.HashToken(refreshToken), now, now,
-	)
+	userID, err := s.storeFor(r).EnsureUser("admin", auth.HashToken(s.config.AdminPassword))
+	if err == nil {
+		var device *db.Device
+		device, err = s.storeFor(r).CreateDevice(userID, state.DeviceName, auth.HashToken(token), auth.HashToken(refreshToken))
+		if err == nil && !s.flows.Approve(userCode, device.ID, token, refreshToken) {
+			_ = s.storeFor(r).RevokeDevice(device.ID)
+			err = fmt.Errorf("the request expired or was denied")
+		}
+	}
 	if err != nil {
 		data.Error = fmt.Sprintf("Failed to create device: %v", err)
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -301,7 +294,6 @@ func (s *Server) handleApprovalSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.flows.Approve(userCode, deviceID, token, refreshToken)
 	data.Success = fmt.Sprintf("Device '%s' approved! You can close this page.", state.DeviceName)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	_ = approvalPageTmpl.Execute(w, data)
@@ -317,94 +309,101 @@ func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		authHeader := r.Header.Get("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			writeError(w, http.StatusUnauthorized, "missing or invalid Authorization header")
+		if s.authenticate(w, r) == nil {
 			return
 		}
-
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		tokenHash := auth.HashToken(token)
-
-		var deviceID string
-		var revoked bool
-		err := s.store.QueryRow(
-			"SELECT id, revoked FROM devices WHERE token_hash = $1", tokenHash,
-		).Scan(&deviceID, &revoked)
-		if err != nil {
-			writeError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-		if revoked {
-			writeError(w, http.StatusForbidden, "device has been revoked")
-			return
-		}
-
-		// Update last_seen
-		now := time.Now().UTC().Format(time.RFC3339)
-		ip := r.RemoteAddr
-		_, _ = s.store.Exec("UPDATE devices SET last_seen = $1, last_ip = $2 WHERE id = $3", now, ip, deviceID)
-
-		// Store device ID in context via header (simple approach without context.Context changes)
-		r.Header.Set("X-Device-ID", deviceID)
 		next(w, r)
 	}
 }
 
-// --- Device management ---
+// authenticate validates the request's bearer token against the devices
+// table, writing an error response and returning nil if it is missing,
+// unknown or revoked. On success it records the device's last_seen, passes
+// its ID and user on in the X-Device-ID and X-User-ID headers (a simple
+// approach without context.Context changes) and returns the device.
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) *db.Device {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "missing or invalid Authorization header")
+		return nil
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	device, err := s.storeFor(r).GetDeviceByToken(auth.HashToken(token))
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "invalid token")
+		return nil
+	}
+	if device.Revoked {
+		writeError(w, http.StatusForbidden, "device has been revoked")
+		return nil
+	}
 
-type deviceInfo struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	LastSeen  string `json:"last_seen,omitempty"`
-	LastIP    string `json:"last_ip,omitempty"`
-	Revoked   bool   `json:"revoked"`
-	CreatedAt string `json:"created_at"`
+	_ = s.storeFor(r).TouchDevice(device.ID, r.RemoteAddr)
+	r.Header.Set("X-Device-ID", device.ID)
+	r.Header.Set("X-User-ID", device.UserID)
+	return device
 }
 
+// --- Device management ---
+
 func (s *Server) handleListDevices(w http.ResponseWriter, r *http.Request) {
-	rows, err := s.store.Query(
-		"SELECT id, name, last_seen, last_ip, revoked, created_at FROM devices ORDER BY created_at DESC",
-	)
+	devices, err := s.storeFor(r).ListDevices()
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	var devices []deviceInfo
-	for rows.Next() {
-		var d deviceInfo
-		var lastSeen, lastIP sql.NullString
-		if err := rows.Scan(&d.ID, &d.Name, &lastSeen, &lastIP, &d.Revoked, &d.CreatedAt); err != nil {
-			writeError(w, http.StatusInternalServerError, err.Error())
+	writeJSON(w, http.StatusOK, devices)
+}
+
+func (s *Server) handleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.storeFor(r).RevokeDevice(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "device not found")
 			return
 		}
-		if lastSeen.Valid {
-			d.LastSeen = lastSeen.String
-		}
-		if lastIP.Valid {
-			d.LastIP = lastIP.String
-		}
-		devices = append(devices, d)
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked", "device_id": id})
+}
 
-	writeJSON(w, http.StatusOK, devices)
+type renameDeviceRequest struct {
+	Name string `json:"name"`
 }
 
-func (s *Server) handleRevokeDevice(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleRenameDevice(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	result, err := s.store.Exec("UPDATE devices SET revoked = true WHERE id = $1", id)
-	if err != nil {
+	var req renameDeviceRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+	if err := s.storeFor(r).RenameDevice(id, req.Name); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "device not found")
+			return
+		}
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-	rows, _ := result.RowsAffected()
-	if rows == 0 {
-		writeError(w, http.StatusNotFound, "device not found")
+	writeJSON(w, http.StatusOK, map[string]string{"status": "renamed", "device_id": id, "name": req.Name})
+}
+
+// handleWhoami returns the device record for the request's bearer token.
+// It always checks the token, even when no admin password is configured.
+func (s *Server) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	device := s.authenticate(w, r)
+	if device == nil {
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked", "device_id": id})
+	writeJSON(w, http.StatusOK, device)
 }
 
 // --- Helpers ---
@@ -416,29 +415,16 @@ func (s *Server) verifyAdminPassword(password string) bool {
 	return password == s.config.AdminPassword
 }
 
-func (s *Server) ensureAdminUser() string {
-	var id string
-	err := s.store.QueryRow("SELECT id FROM users WHERE username = 'admin'").Scan(&id)
-	if err == nil {
-		return id
-	}
-
-	id = db.NewID()
-	now := time.Now().UTC().Format(time.RFC3339)
-	_, _ = s.store.Exec(
-		"INSERT INTO users (id, username, password_hash, created_at) VALUES ($1, 'admin', $2, $3)",
-		id, auth.HashToken(s.config.AdminPassword), now,
-	)
-	return id
-}
-
 // authMiddleware wraps all /api/ routes (except auth endpoints) with token validation.
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Skip auth for health, auth endpoints, device approval page, and admin UI
+		// Skip auth for health, auth endpoints, device approval page, the
+		// admin UI and API (which check the admin password themselves) and
+		// the digest feed (which takes its token as a query parameter)
 		path := r.URL.Path
-		if path == "/health" ||
+		if path == "/health" || path == "/digest.atom" ||
 			strings.HasPrefix(path, "/api/auth/") ||
+			strings.HasPrefix(path, "/api/admin/") ||
 			strings.HasPrefix(path, "/device/") ||
 			strings.HasPrefix(path, "/admin") {
 			next.ServeHTTP(w, r)
@@ -451,35 +437,9 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		authHeader := r.Header.Get("Authorization")
-		if !strings.HasPrefix(authHeader, "Bearer ") {
-			writeError(w, http.StatusUnauthorized, "missing or invalid Authorization header")
+		if s.authenticate(w, r) == nil {
 			return
 		}
-
-		token := strings.TrimPrefix(authHeader, "Bearer ")
-		tokenHash := auth.HashToken(token)
-
-		var deviceID string
-		var revoked bool
-		err := s.store.QueryRow(
-			"SELECT id, revoked FROM devices WHERE token_hash = $1", tokenHash,
-		).Scan(&deviceID, &revoked)
-		if err != nil {
-			writeError(w, http.StatusUnauthorized, "invalid token")
-			return
-		}
-		if revoked {
-			writeError(w, http.StatusForbidden, "device has been revoked")
-			return
-		}
-
-		// Update last_seen
-		now := time.Now().UTC().Format(time.RFC3339)
-		ip := r.RemoteAddr
-		_, _ = s.store.Exec("UPDATE devices SET last_seen = $1, last_ip = $2 WHERE id = $3", now, ip, deviceID)
-
-		r.Header.Set("X-Device-ID", deviceID)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -16,44 +16,9 @@ import (
 	"github.com/zate/ctx/testutil"
 )
 
-// createAuthTables creates the users, devices, repo_mappings tables in SQLite
-// so that auth-related server endpoints can be tested without Postgres.
-func createAuthTables(t *testing.T, store db.Store) {
-	t.Helper()
-	for _, ddl := range []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id TEXT PRIMARY KEY,
-			username TEXT UNIQUE NOT NULL,
-			password_hash TEXT NOT NULL,
-			created_at TEXT NOT NULL DEFAULT ''
-		)`,
-		`CREATE TABLE IF NOT EXISTS devices (
-			id TEXT PRIMARY KEY,
-			user_id TEXT NOT NULL REFERENCES users(id),
-			name TEXT NOT NULL,
-			token_hash TEXT NOT NULL,
-			refresh_token_hash TEXT,
-			last_seen TEXT,
-			last_ip TEXT,
-			revoked BOOLEAN NOT NULL DEFAULT FALSE,
-			created_at TEXT NOT NULL DEFAULT ''
-		)`,
-		`CREATE TABLE IF NOT EXISTS repo_mappings (
-			id TEXT PRIMARY KEY,
-			normalized_url TEXT UNIQUE NOT NULL,
-			project_tag TEXT NOT NULL,
-			created_at TEXT NOT NULL DEFAULT ''
-		)`,
-	} {
-		_, err := store.Exec(ddl)
-		require.NoError(t, err)
-	}
-}
-
 func setupAuthTestServer(t *testing.T, adminPassword string) (*Server, db.Store) {
 	t.Helper()
 	store := testutil.SetupTestDB(t)
-	createAuthTables(t, store)
 	cfg := DefaultConfig()
 	cfg.AdminPassword = adminPassword
 	srv := New(store, cfg)
@@ -279,6 +244,12 @@ func TestDeviceToken_Approved(t *testing.T) {
 	assert.Equal(t, "refresh-xyz", tokenResp.RefreshToken)
 	assert.Equal(t, "Bearer", tokenResp.TokenType)
 	assert.Equal(t, "device-123", tokenResp.DeviceID)
+
+	// The tokens are handed out once, then the flow is gone
+	w = doRequest(t, srv, "POST", "/api/auth/token", deviceTokenRequest{
+		DeviceCode: initResp.DeviceCode,
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
 // --- Full Device Approval Flow via Web UI ---
@@ -370,6 +341,73 @@ func TestApprovalSubmit_Approve(t *testing.T) {
 	assert.Equal(t, "Bearer", tokenResp.TokenType)
 }
 
+// TestDeviceFlow_SQLite runs the whole device flow against the SQLite
+// schema alone: approval, an authenticated call, refresh, revocation.
+func TestDeviceFlow_SQLite(t *testing.T) {
+	srv, _ := setupAuthTestServer(t, "secret123")
+
+	w := doRequest(t, srv, "POST", "/api/auth/device", deviceInitRequest{DeviceName: "sqlite-box"})
+	var initResp deviceInitResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &initResp))
+
+	form := url.Values{
+		"user_code":      {initResp.UserCode},
+		"admin_password": {"secret123"},
+		"action":         {"approve"},
+	}
+	req := httptest.NewRequest("POST", "/device/authorize", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	require.Contains(t, rec.Body.String(), "approved")
+
+	w = doRequest(t, srv, "POST", "/api/auth/token", deviceTokenRequest{DeviceCode: initResp.DeviceCode})
+	require.Equal(t, http.StatusOK, w.Code)
+	var tokens deviceTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tokens))
+
+	authed := func(method, path string, body any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if body != nil {
+			data, _ := json.Marshal(body)
+			req = httptest.NewRequest(method, path, strings.NewReader(string(data)))
+		}
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		rec := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(rec, req)
+		return rec
+	}
+
+	w = authed("POST", "/api/repo-mappings", map[string]string{"normalized_url": "github.com/a/b", "project_tag": "b"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = authed("GET", "/api/devices", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var devices []db.Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
+	require.Len(t, devices, 1)
+	assert.Equal(t, "sqlite-box", devices[0].Name)
+	assert.NotEmpty(t, devices[0].LastIP)
+
+	w = doRequest(t, srv, "POST", "/api/auth/refresh", refreshRequest{
+		RefreshToken: tokens.RefreshToken,
+		DeviceID:     tokens.DeviceID,
+	})
+	require.Equal(t, http.StatusOK, w.Code)
+	var refreshed deviceTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+
+	// The old access token no longer works after a refresh
+	w = authed("GET", "/api/devices", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	tokens = refreshed
+
+	w = authed("POST", "/api/devices/"+tokens.DeviceID+"/revoke", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = authed("GET", "/api/status", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
 func TestApprovalSubmit_Deny(t *testing.T) {
 	srv, _ := setupAuthTestServer(t, "secret123")
 
@@ -481,7 +519,7 @@ func TestListDevices(t *testing.T) {
 
 	require.Equal(t, http.StatusOK, w.Code)
 
-	var devices []deviceInfo
+	var devices []db.Device
 	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &devices))
 	assert.Len(t, devices, 2)
 }
@@ -526,6 +564,63 @@ func TestRevokeDevice_NotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
+func TestRenameDevice(t *testing.T) {
+	srv, store := setupAuthTestServer(t, "secret123")
+
+	token := "laptop-token"
+	id := insertTestDevice(t, store, "laptop", token, "refresh", false)
+
+	req := httptest.NewRequest("POST", "/api/devices/"+id+"/rename", strings.NewReader(`{"name":"work-laptop"}`))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	dev, err := store.GetDeviceByToken(auth.HashToken(token))
+	require.NoError(t, err)
+	assert.Equal(t, "work-laptop", dev.Name)
+
+	for path, body := range map[string]string{
+		"/api/devices/" + id + "/rename": `{"name":"  "}`,
+		"/api/devices/missing/rename":    `{"name":"x"}`,
+	} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		assert.NotEqual(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestWhoami(t *testing.T) {
+	// No admin password: whoami still checks the token
+	srv, store := setupAuthTestServer(t, "")
+
+	token := "laptop-token"
+	id := insertTestDevice(t, store, "laptop", token, "refresh", false)
+
+	req := httptest.NewRequest("GET", "/api/auth/whoami", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var dev db.Device
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &dev))
+	assert.Equal(t, id, dev.ID)
+	assert.Equal(t, "laptop", dev.Name)
+
+	w = doRequest(t, srv, "GET", "/api/auth/whoami", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	insertTestDevice(t, store, "old", "revoked-token", "refresh-2", true)
+	req = httptest.NewRequest("GET", "/api/auth/whoami", nil)
+	req.Header.Set("Authorization", "Bearer revoked-token")
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
 // --- Server-Level Sync Tests ---
 
 func TestSyncPush_Conflict_ServerNewer(t *testing.T) {
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/zate/ctx/internal/backup"
+)
+
+// scheduleBackups takes a backup every Backup.Interval until ctx is done.
+// The first is due an interval after the newest backup already stored, so
+// restarting the server neither skips nor repeats one.
+func (s *Server) scheduleBackups(ctx context.Context, target *backup.Target) {
+	interval := s.config.Backup.Interval
+	var wait time.Duration
+	latest, err := target.Latest(ctx)
+	switch {
+	case err != nil:
+		log.Printf("ctx server: cannot list backups, taking one now: %v", err)
+	case latest != nil:
+		wait = time.Until(latest.CreatedAt.Add(interval))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(max(wait, 0)):
+		}
+		report, err := target.Run(ctx, s.store)
+		if report != nil {
+			log.Printf("ctx server: backed up %d nodes to %s%s (%d bytes, %d old backups pruned)",
+				report.Export.Nodes, s.config.Backup.Location(), report.Name, report.Size, report.Pruned)
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ctx server: backup failed: %v", err)
+		}
+		wait = interval
+	}
+}
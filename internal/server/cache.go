@@ -0,0 +1,237 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// maxCachedResponses bounds the response cache; it is emptied when full.
+const maxCachedResponses = 256
+
+// maxCacheAge is how long a response is served from the cache. Responses
+// show times relative to now (stats/top ages, how long ago a resurfaced
+// node was stored), so even without changes they go stale.
+const maxCacheAge = time.Minute
+
+// changeRetryDelay is how long to wait before listening for changes again
+// after the connection is lost.
+const changeRetryDelay = 5 * time.Second
+
+// readOnlyPosts are the POST routes that only read, so they leave the
+// response cache alone.
+var readOnlyPosts = map[string]bool{
+	"/api/query":           true,
+	"/api/query/aggregate": true,
+	"/api/compose":         true,
+}
+
+// responseCache holds responses of the expensive read routes (stats/top
+// and compose). It is only used while the store reports every change made
+// by any process sharing it, so instances behind a load balancer never
+// serve each other's stale results: a change empties it, and it is empty
+// and off whenever the server is not listening.
+type responseCache struct {
+	mu      sync.Mutex
+	enabled bool
+	gen     uint64 // bumped on every invalidation
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	contentType string
+	body        []byte
+	note        cacheNote
+	expires     time.Time
+}
+
+// cacheNote is what a cached handler tells the cache about its response,
+// through noteResponse.
+type cacheNote struct {
+	// reads are the nodes the response shows, recorded as read again on
+	// every hit, as the handler would have
+	reads []string
+	// readDependent responses depend on when nodes were read (accessed:
+	// queries), so they are dropped when node_access changes
+	readDependent bool
+}
+
+type cacheNoteKey struct{}
+
+// noteResponse tells the cache, if r is being cached, which nodes the
+// response shows and whether it depends on when nodes were read.
+func noteResponse(r *http.Request, reads []string, readDependent bool) {
+	if note, ok := r.Context().Value(cacheNoteKey{}).(*cacheNote); ok {
+		note.reads, note.readDependent = reads, readDependent
+	}
+}
+
+func newResponseCache() *responseCache {
+	return &responseCache{entries: map[string]cachedResponse{}}
+}
+
+// lookup returns the response cached under key, and the generation a
+// response computed now should be stored with.
+func (c *responseCache) lookup(key string) (cachedResponse, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return cachedResponse{}, c.gen, false
+	}
+	resp, ok := c.entries[key]
+	if ok && !time.Now().Before(resp.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	return resp, c.gen, ok
+}
+
+// store caches resp under key unless the cache was invalidated since gen
+// was looked up, as resp may predate the change.
+func (c *responseCache) store(key string, gen uint64, resp cachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled || gen != c.gen {
+		return
+	}
+	if len(c.entries) >= maxCachedResponses {
+		clear(c.entries)
+	}
+	resp.expires = time.Now().Add(maxCacheAge)
+	c.entries[key] = resp
+}
+
+// reset empties the cache and turns it on or off.
+func (c *responseCache) reset(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	c.gen++
+	clear(c.entries)
+}
+
+func (c *responseCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	clear(c.entries)
+}
+
+// invalidateReads drops the responses that depend on when nodes were
+// read. Reads change on every compose, so the rest are kept.
+func (c *responseCache) invalidateReads() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	for key, resp := range c.entries {
+		if resp.note.readDependent {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// cached serves next's successful responses from the cache, keyed by the
+// request's path, query string and body. The reads next noted are
+// recorded on every hit.
+func (s *Server) cached(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.cache == nil {
+			next(w, r)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		key := r.Method + " " + r.URL.RequestURI() + "\n" + string(body)
+
+		resp, gen, ok := s.cache.lookup(key)
+		if ok {
+			if len(resp.note.reads) > 0 {
+				_ = s.storeFor(r).RecordAccess(resp.note.reads)
+			}
+			w.Header().Set("Content-Type", resp.contentType)
+			w.Header().Set("X-Ctx-Cache", "hit")
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(resp.body)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		w.Header().Set("X-Ctx-Cache", "miss")
+		note := &cacheNote{}
+		next(rec, r.WithContext(context.WithValue(r.Context(), cacheNoteKey{}, note)))
+		if rec.status == http.StatusOK {
+			s.cache.store(key, gen, cachedResponse{contentType: w.Header().Get("Content-Type"), body: rec.body.Bytes(), note: *note})
+		}
+	}
+}
+
+// recordingWriter passes a response through while keeping a copy.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+	rw.status = code
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// invalidateOnWrite empties the cache after every request that may have
+// changed the store, so a client reads its own writes without waiting for
+// the change notification to come back round.
+func (s *Server) invalidateOnWrite(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if !readOnlyPosts[r.URL.Path] {
+				s.cache.invalidate()
+			}
+		}
+	})
+}
+
+// watchChanges keeps the cache in step with the store's change
+// notifications until ctx is done, listening again after a lost
+// connection. The cache is off while the server is not listening.
+func (s *Server) watchChanges(ctx context.Context, n db.ChangeNotifier) {
+	for {
+		err := n.ListenChanges(ctx, func(table string) {
+			switch table {
+			case "":
+				s.cache.reset(true)
+			case "node_access":
+				s.cache.invalidateReads()
+			default:
+				s.cache.invalidate()
+			}
+		})
+		s.cache.reset(false)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("ctx server: response cache off until change notifications resume: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(changeRetryDelay):
+		}
+	}
+}
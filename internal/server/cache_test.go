@@ -0,0 +1,213 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+// notifyingStore stands in for Postgres: the test announces changes, as
+// another instance's writes would be, and can drop the connection.
+type notifyingStore struct {
+	db.Store
+	listening chan struct{}
+	changes   chan string
+	acks      chan struct{}
+	lost      chan struct{}
+}
+
+func (n *notifyingStore) ListenChanges(ctx context.Context, changed func(table string)) error {
+	changed("")
+	n.listening <- struct{}{}
+	for {
+		select {
+		case table := <-n.changes:
+			changed(table)
+			n.acks <- struct{}{}
+		case <-n.lost:
+			return errors.New("connection lost")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (n *notifyingStore) notify(table string) {
+	n.changes <- table
+	<-n.acks
+}
+
+func setupCachingServer(t *testing.T) (*Server, *notifyingStore) {
+	t.Helper()
+	store := &notifyingStore{
+		Store:     testutil.SetupTestDB(t),
+		listening: make(chan struct{}),
+		changes:   make(chan string),
+		acks:      make(chan struct{}),
+		lost:      make(chan struct{}),
+	}
+	srv := New(store, DefaultConfig())
+	require.NotNil(t, srv.cache)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go srv.watchChanges(ctx, store)
+	<-store.listening
+	return srv, store
+}
+
+func composeCount(t *testing.T, srv *Server) (int, string) {
+	t.Helper()
+	w := doRequest(t, srv, "POST", "/api/compose", composeRequest{Query: "type:fact"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return int(resp["node_count"].(float64)), w.Header().Get("X-Ctx-Cache")
+}
+
+func TestResponseCache_InvalidatedByNotification(t *testing.T) {
+	srv, store := setupCachingServer(t)
+
+	_, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "First fact"})
+	require.NoError(t, err)
+
+	n, cache := composeCount(t, srv)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "miss", cache)
+	n, cache = composeCount(t, srv)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, "hit", cache)
+
+	// Another instance writes; only the notification tells this one
+	_, err = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Second fact"})
+	require.NoError(t, err)
+	n, _ = composeCount(t, srv)
+	assert.Equal(t, 1, n, "served from cache until notified")
+
+	store.notify("nodes")
+	n, cache = composeCount(t, srv)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, "miss", cache)
+}
+
+func TestResponseCache_InvalidatedByLocalWrite(t *testing.T) {
+	srv, _ := setupCachingServer(t)
+
+	w := doRequest(t, srv, "GET", "/api/stats/top", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(t, srv, "GET", "/api/stats/top", nil)
+	assert.Equal(t, "hit", w.Header().Get("X-Ctx-Cache"))
+
+	// Read-only POSTs keep the cache
+	composeCount(t, srv)
+	w = doRequest(t, srv, "GET", "/api/stats/top", nil)
+	assert.Equal(t, "hit", w.Header().Get("X-Ctx-Cache"))
+
+	w = doRequest(t, srv, "POST", "/api/nodes", createNodeRequest{Type: "fact", Content: "Written here"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	n, cache := composeCount(t, srv)
+	assert.Equal(t, 1, n, "a client reads its own writes")
+	assert.Equal(t, "miss", cache)
+}
+
+func TestResponseCache_Expires(t *testing.T) {
+	srv, _ := setupCachingServer(t)
+
+	w := doRequest(t, srv, "GET", "/api/stats/top", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(t, srv, "GET", "/api/stats/top", nil)
+	require.Equal(t, "hit", w.Header().Get("X-Ctx-Cache"))
+
+	// Ages in the response are relative to now, so an old entry is
+	// recomputed even though nothing changed
+	srv.cache.mu.Lock()
+	for key, resp := range srv.cache.entries {
+		resp.expires = time.Now().Add(-time.Second)
+		srv.cache.entries[key] = resp
+	}
+	srv.cache.mu.Unlock()
+	w = doRequest(t, srv, "GET", "/api/stats/top", nil)
+	assert.Equal(t, "miss", w.Header().Get("X-Ctx-Cache"))
+	w = doRequest(t, srv, "GET", "/api/stats/top", nil)
+	assert.Equal(t, "hit", w.Header().Get("X-Ctx-Cache"))
+}
+
+func TestResponseCache_OffWhileNotListening(t *testing.T) {
+	srv, store := setupCachingServer(t)
+
+	composeCount(t, srv)
+	_, cache := composeCount(t, srv)
+	require.Equal(t, "hit", cache)
+
+	close(store.lost)
+	require.Eventually(t, func() bool {
+		srv.cache.mu.Lock()
+		defer srv.cache.mu.Unlock()
+		return !srv.cache.enabled
+	}, time.Second, 10*time.Millisecond)
+
+	_, cache = composeCount(t, srv)
+	assert.Equal(t, "miss", cache)
+	_, cache = composeCount(t, srv)
+	assert.Equal(t, "miss", cache, "nothing is cached without notifications")
+}
+
+func TestResponseCache_OnlyWithNotifyingStore(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	assert.Nil(t, srv.cache)
+
+	cfg := DefaultConfig()
+	cfg.DisableCache = true
+	srv = New(&notifyingStore{Store: testutil.SetupTestDB(t)}, cfg)
+	assert.Nil(t, srv.cache)
+}
+
+func TestResponseCache_RecordsReadsOnHits(t *testing.T) {
+	srv, store := setupCachingServer(t)
+	n, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Composed often"})
+	require.NoError(t, err)
+
+	for range 3 {
+		composeCount(t, srv)
+	}
+	_, cache := composeCount(t, srv)
+	require.Equal(t, "hit", cache)
+	access, err := store.GetNodeAccess(n.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, access.AccessCount, "cache hits count as reads")
+}
+
+func TestResponseCache_ReadsDropOnlyReadDependentResponses(t *testing.T) {
+	srv, store := setupCachingServer(t)
+	_, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Fact"})
+	require.NoError(t, err)
+
+	accessed := func() (int, string) {
+		w := doRequest(t, srv, "POST", "/api/compose", composeRequest{Query: "type:fact AND NOT accessed:>1d"})
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return int(resp["node_count"].(float64)), w.Header().Get("X-Ctx-Cache")
+	}
+	n, _ := accessed()
+	assert.Equal(t, 1, n, "never read yet")
+	composeCount(t, srv) // reads the fact
+	n, cache := accessed()
+	require.Equal(t, "hit", cache)
+	assert.Equal(t, 1, n, "stale until notified")
+
+	store.notify("node_access")
+	n, cache = accessed()
+	assert.Equal(t, "miss", cache)
+	assert.Equal(t, 0, n)
+	_, cache = composeCount(t, srv)
+	assert.Equal(t, "hit", cache, "other responses stay cached")
+}
@@ -2,10 +2,14 @@ package server
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/zate/ctx/internal/backup"
 	"gopkg.in/yaml.v3"
 )
 
@@ -17,19 +21,74 @@ type Config struct {
 	TLSCert       string `yaml:"tls_cert"`
 	TLSKey        string `yaml:"tls_key"`
 	AdminPassword string `yaml:"admin_password"`
+	// AdminPasswordFile, if set, holds the admin password (trailing
+	// whitespace trimmed) and takes precedence over AdminPassword.
+	AdminPasswordFile string `yaml:"admin_password_file"`
+	// Quota limits what each device and user may store. Quotas apply only
+	// when auth is enabled, since devices are otherwise anonymous.
+	Quota QuotaConfig `yaml:"quota"`
+	// QueryTimeout bounds query and compose requests; 0 disables it.
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+	// EditorOrigins are the browser origins allowed to call /api/editor/
+	// through CORS. A trailing * matches any suffix; "*" allows all.
+	EditorOrigins []string `yaml:"editor_origins"`
+	// Timezone is the zone admin UI times are shown in: local, UTC or an
+	// IANA name. RelativeTimes shows "3 days ago" in tables instead.
+	Timezone      string `yaml:"timezone"`
+	RelativeTimes bool   `yaml:"relative_times"`
+	// DisableCache turns off the stats and compose response cache, which
+	// is otherwise used with Postgres, where change notifications keep it
+	// valid across every instance sharing the database.
+	DisableCache bool `yaml:"disable_cache"`
+	// Backup uploads exports of the store to S3-compatible storage, every
+	// Backup.Interval and on ctx server backup now.
+	Backup backup.Config `yaml:"backup"`
+	// Report writes a memory report of the store to Report.Dir every
+	// Report.Interval, covering the time since the previous one.
+	Report ReportConfig `yaml:"report"`
+	// LocalToken, set by ctx open and ctx ui, locks a server without an
+	// admin password to the browser they launch: requests must address
+	// it by a loopback name and carry the token, from the launched URL's
+	// token parameter or the cookie that sets.
+	LocalToken string `yaml:"-"`
+}
+
+// ReportConfig schedules memory reports.
+type ReportConfig struct {
+	Interval time.Duration `yaml:"interval"`
+	Dir      string        `yaml:"dir"`
+}
+
+// QuotaConfig holds the per-device and per-user storage limits.
+type QuotaConfig struct {
+	Device QuotaLimits `yaml:"device"`
+	User   QuotaLimits `yaml:"user"`
+}
+
+// QuotaLimits caps node count, total token estimate and the bytes of
+// files attached to the nodes; 0 means unlimited.
+type QuotaLimits struct {
+	MaxNodes           int `yaml:"max_nodes" json:"max_nodes,omitempty"`
+	MaxTokens          int `yaml:"max_tokens" json:"max_tokens,omitempty"`
+	MaxAttachmentBytes int `yaml:"max_attachment_bytes" json:"max_attachment_bytes,omitempty"`
 }
 
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		Port: 8377,
-		Bind: "0.0.0.0",
+		Port:          8377,
+		Bind:          "0.0.0.0",
+		QueryTimeout:  30 * time.Second,
+		EditorOrigins: []string{"vscode-webview://*"},
 	}
 }
 
 // LoadConfig loads server config from ~/.ctx/server.yaml, falling back to defaults.
 // Environment variables override file values: CTX_SERVER_PORT, CTX_SERVER_BIND,
-// CTX_SERVER_DB_URL, CTX_SERVER_TLS_CERT, CTX_SERVER_TLS_KEY.
+// CTX_SERVER_DB_URL, CTX_SERVER_TLS_CERT, CTX_SERVER_TLS_KEY,
+// CTX_SERVER_QUERY_TIMEOUT, CTX_SERVER_EDITOR_ORIGINS (comma-separated), CTX_SERVER_TIMEZONE, CTX_SERVER_DISABLE_CACHE, CTX_SERVER_{DEVICE,USER}_MAX_{NODES,TOKENS}
+// for quotas, and CTX_SERVER_BACKUP_{BUCKET,PREFIX,ENDPOINT,REGION,PATH_STYLE,INTERVAL,RETENTION}
+// for backups, and CTX_SERVER_REPORT_{INTERVAL,DIR} for memory reports.
 func LoadConfig() Config {
 	cfg := DefaultConfig()
 
@@ -63,13 +122,119 @@ func LoadConfig() Config {
 	if v := os.Getenv("CTX_SERVER_ADMIN_PASSWORD"); v != "" {
 		cfg.AdminPassword = v
 	}
+	if v := os.Getenv("CTX_SERVER_ADMIN_PASSWORD_FILE"); v != "" {
+		cfg.AdminPasswordFile = v
+	}
+	if v := os.Getenv("CTX_SERVER_QUERY_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.QueryTimeout = d
+		}
+	}
+	if v := os.Getenv("CTX_SERVER_EDITOR_ORIGINS"); v != "" {
+		cfg.EditorOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				cfg.EditorOrigins = append(cfg.EditorOrigins, origin)
+			}
+		}
+	}
+	if v := os.Getenv("CTX_SERVER_TIMEZONE"); v != "" {
+		cfg.Timezone = v
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CTX_SERVER_DISABLE_CACHE")); err == nil {
+		cfg.DisableCache = v
+	}
+	for env, dest := range map[string]*string{
+		"CTX_SERVER_BACKUP_BUCKET":   &cfg.Backup.Bucket,
+		"CTX_SERVER_BACKUP_PREFIX":   &cfg.Backup.Prefix,
+		"CTX_SERVER_BACKUP_ENDPOINT": &cfg.Backup.Endpoint,
+		"CTX_SERVER_BACKUP_REGION":   &cfg.Backup.Region,
+		"CTX_SERVER_REPORT_DIR":      &cfg.Report.Dir,
+	} {
+		if v := os.Getenv(env); v != "" {
+			*dest = v
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CTX_SERVER_BACKUP_PATH_STYLE")); err == nil {
+		cfg.Backup.PathStyle = v
+	}
+	for env, dest := range map[string]*time.Duration{
+		"CTX_SERVER_BACKUP_INTERVAL":  &cfg.Backup.Interval,
+		"CTX_SERVER_BACKUP_RETENTION": &cfg.Backup.Retention,
+		"CTX_SERVER_REPORT_INTERVAL":  &cfg.Report.Interval,
+	} {
+		if d, err := time.ParseDuration(os.Getenv(env)); err == nil {
+			*dest = d
+		}
+	}
+	for env, dest := range map[string]*int{
+		"CTX_SERVER_DEVICE_MAX_NODES":            &cfg.Quota.Device.MaxNodes,
+		"CTX_SERVER_DEVICE_MAX_TOKENS":           &cfg.Quota.Device.MaxTokens,
+		"CTX_SERVER_DEVICE_MAX_ATTACHMENT_BYTES": &cfg.Quota.Device.MaxAttachmentBytes,
+		"CTX_SERVER_USER_MAX_NODES":              &cfg.Quota.User.MaxNodes,
+		"CTX_SERVER_USER_MAX_TOKENS":             &cfg.Quota.User.MaxTokens,
+		"CTX_SERVER_USER_MAX_ATTACHMENT_BYTES":   &cfg.Quota.User.MaxAttachmentBytes,
+	} {
+		if n, err := strconv.Atoi(os.Getenv(env)); err == nil {
+			*dest = n
+		}
+	}
 
 	return cfg
 }
 
+// ReadAdminPasswordFile loads AdminPassword from AdminPasswordFile, if set.
+func (c *Config) ReadAdminPasswordFile() error {
+	if c.AdminPasswordFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.AdminPasswordFile)
+	if err != nil {
+		return fmt.Errorf("failed to read admin password file: %w", err)
+	}
+	password := strings.TrimSpace(string(data))
+	if password == "" {
+		return fmt.Errorf("admin password file %s is empty", c.AdminPasswordFile)
+	}
+	c.AdminPassword = password
+	return nil
+}
+
+// SetAddr sets Bind and Port from a "host:port" address. An empty host
+// binds all interfaces.
+func (c *Config) SetAddr(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port in address %q", addr)
+	}
+	if host == "" {
+		host = "0.0.0.0"
+	}
+	c.Bind, c.Port = host, n
+	return nil
+}
+
 // Addr returns the listen address as "bind:port".
 func (c Config) Addr() string {
-	return fmt.Sprintf("%s:%d", c.Bind, c.Port)
+	return net.JoinHostPort(c.Bind, strconv.Itoa(c.Port))
+}
+
+// allowsOrigin reports whether EditorOrigins admits a browser origin.
+func (c Config) allowsOrigin(origin string) bool {
+	for _, allowed := range c.EditorOrigins {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(origin, prefix) {
+				return true
+			}
+		} else if origin == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 // HasTLS returns true if both TLS cert and key are configured.
@@ -0,0 +1,200 @@
+package server
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/zate/ctx/internal/auth"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+)
+
+const (
+	// defaultDigestLimit and maxDigestLimit bound the entries in a feed.
+	defaultDigestLimit = 50
+	maxDigestLimit     = 200
+)
+
+// Atom feed elements (RFC 4287), just the parts the digest uses.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Link       atomLink       `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Summary    *atomText      `xml:"summary,omitempty"`
+	Content    atomText       `xml:"content"`
+}
+
+// handleDigest serves GET /digest.atom?query=...: an Atom feed of the
+// newest nodes matching a query, for subscribing from a feed reader. Feed
+// readers can't send an Authorization header, so when auth is enabled a
+// digest token (see handleDigestToken) comes in ?token= instead. Device
+// access tokens are not accepted: feed URLs end up in logs and readers.
+func (s *Server) handleDigest(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if s.config.AdminPassword != "" {
+		token := q.Get("token")
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "missing digest token")
+			return
+		}
+		device, err := s.storeFor(r).GetDeviceByDigestToken(auth.HashToken(token))
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, "invalid digest token")
+			return
+		}
+		if device.Revoked {
+			writeError(w, http.StatusForbidden, "device has been revoked")
+			return
+		}
+	}
+
+	limit := defaultDigestLimit
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxDigestLimit)
+	}
+
+	ctx, cancel := query.WithTimeout(r.Context(), s.config.QueryTimeout)
+	defer cancel()
+	nodes, err := query.ExecuteQueryContext(ctx, s.storeFor(r), q.Get("query"), false)
+	if err != nil {
+		writeError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	_, _ = w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	_ = enc.Encode(s.digestFeed(r, q.Get("query"), nodes))
+}
+
+type digestTokenResponse struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// handleDigestToken serves POST /api/digest/token: it issues the calling
+// device a token for the digest feed, replacing the one it had. The token
+// only reads the feed and, unlike the device's access token, neither
+// expires nor changes on refresh; DELETE revokes it, as does revoking the
+// device.
+func (s *Server) handleDigestToken(w http.ResponseWriter, r *http.Request) {
+	deviceID, _ := s.requestDevice(r)
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "digest tokens are only needed when the server requires auth")
+		return
+	}
+	token := auth.GenerateToken()
+	if err := s.storeFor(r).SetDigestToken(deviceID, auth.HashToken(token)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, digestTokenResponse{
+		Token: token,
+		URL:   s.baseURL(r) + "/digest.atom?token=" + url.QueryEscape(token),
+	})
+}
+
+// handleRevokeDigestToken serves DELETE /api/digest/token, revoking the
+// calling device's digest token.
+func (s *Server) handleRevokeDigestToken(w http.ResponseWriter, r *http.Request) {
+	deviceID, _ := s.requestDevice(r)
+	if deviceID == "" {
+		writeError(w, http.StatusBadRequest, "digest tokens are only needed when the server requires auth")
+		return
+	}
+	if err := s.storeFor(r).SetDigestToken(deviceID, ""); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "revoked", "device_id": deviceID})
+}
+
+// digestFeed builds the feed. Its ID and self link leave out the token, so
+// they are stable and safe to show.
+func (s *Server) digestFeed(r *http.Request, queryStr string, nodes []*db.Node) atomFeed {
+	base := s.baseURL(r)
+	self := base + "/digest.atom"
+	title := "ctx digest"
+	if queryStr != "" {
+		self += "?query=" + url.QueryEscape(queryStr)
+		title += ": " + queryStr
+	}
+
+	feed := atomFeed{
+		ID:      self,
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links:   []atomLink{{Href: self, Rel: "self"}, {Href: base + "/admin/nodes"}},
+		Author:  atomAuthor{Name: "ctx"},
+		Entries: []atomEntry{},
+	}
+	var newest time.Time
+	for _, n := range nodes {
+		if n.UpdatedAt.After(newest) {
+			newest = n.UpdatedAt
+		}
+		link := base + "/admin/nodes/" + n.ID
+		entry := atomEntry{
+			ID:        link,
+			Title:     n.Type + ": " + suggestTitle(n.Content),
+			Updated:   n.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: n.CreatedAt.UTC().Format(time.RFC3339),
+			Link:      atomLink{Href: link},
+			Content:   atomText{Type: "text", Body: n.Content},
+		}
+		for _, tag := range n.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if n.Summary != nil {
+			entry.Summary = &atomText{Type: "text", Body: *n.Summary}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if !newest.IsZero() {
+		feed.Updated = newest.UTC().Format(time.RFC3339)
+	}
+	return feed
+}
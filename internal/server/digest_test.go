@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/auth"
+	"github.com/zate/ctx/internal/db"
+)
+
+func TestDigestFeed(t *testing.T) {
+	srv, store := setupTestServer(t)
+	dec, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres for the server", Tags: []string{"project:ctx"}})
+	_, _ = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Unrelated fact"})
+
+	w := doRequest(t, srv, "GET", "/digest.atom?query=type:decision", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/atom+xml; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var feed atomFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &feed))
+	assert.Equal(t, "ctx digest: type:decision", feed.Title)
+	require.Len(t, feed.Entries, 1)
+	entry := feed.Entries[0]
+	assert.Equal(t, "http://example.com/admin/nodes/"+dec.ID, entry.ID)
+	assert.Equal(t, "decision: Use Postgres for the server", entry.Title)
+	assert.Equal(t, "Use Postgres for the server", entry.Content.Body)
+	assert.Equal(t, []atomCategory{{Term: "project:ctx"}}, entry.Categories)
+
+	w = doRequest(t, srv, "GET", "/digest.atom?limit=1", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var limited atomFeed
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &limited))
+	assert.Len(t, limited.Entries, 1)
+
+	w = doRequest(t, srv, "GET", "/digest.atom?query=bogus:(", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestDigestFeedToken(t *testing.T) {
+	srv, store := setupAuthTestServer(t, "secret")
+	id := insertTestDevice(t, store, "reader", "device-token", "device-refresh", false)
+
+	authed := func(method, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/digest/token", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	w := doRequest(t, srv, "GET", "/digest.atom", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = doRequest(t, srv, "GET", "/digest.atom?token=device-token", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "the device's access token is not a feed token")
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, srv, "POST", "/api/digest/token", nil).Code)
+
+	w = authed("POST", "device-token")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var issued digestTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &issued))
+	assert.Equal(t, "http://example.com/digest.atom?token="+issued.Token, issued.URL)
+
+	w = doRequest(t, srv, "GET", "/digest.atom?token="+issued.Token, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), issued.Token)
+
+	// The feed token is read-only and outlives the device token's rotation
+	req := httptest.NewRequest("GET", "/api/nodes/"+id, nil)
+	req.Header.Set("Authorization", "Bearer "+issued.Token)
+	w = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	require.NoError(t, store.RotateDeviceTokens(id, auth.HashToken("rotated-token"), auth.HashToken("rotated-refresh")))
+	assert.Equal(t, http.StatusOK, doRequest(t, srv, "GET", "/digest.atom?token="+issued.Token, nil).Code)
+
+	require.Equal(t, http.StatusOK, authed("DELETE", "rotated-token").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(t, srv, "GET", "/digest.atom?token="+issued.Token, nil).Code)
+}
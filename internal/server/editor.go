@@ -0,0 +1,318 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+	ctxsync "github.com/zate/ctx/internal/sync"
+	"github.com/zate/ctx/internal/token"
+	"github.com/zate/ctx/internal/usage"
+	"github.com/zate/ctx/internal/validate"
+)
+
+const (
+	// defaultEditorLimit and maxEditorLimit bound the nodes each editor
+	// listing returns.
+	defaultEditorLimit = 20
+	maxEditorLimit     = 100
+)
+
+// registerEditorRoutes adds the compact API an editor sidebar extension
+// uses. Requests name the open repository by its git remote (?repo= or
+// "repo" in the body), which maps to a project tag through the repo
+// mappings registered with `ctx sync register-repo`. Browser-based callers
+// such as VS Code webviews are allowed through CORS (see corsMiddleware).
+func (s *Server) registerEditorRoutes() {
+	s.mux.HandleFunc("GET /api/editor/recent", s.handleEditorRecent)
+	s.mux.HandleFunc("POST /api/editor/remember", s.handleEditorRemember)
+	s.mux.HandleFunc("POST /api/editor/recall", s.handleEditorRecall)
+	s.mux.HandleFunc("GET /api/editor/nodes/{id}", s.handleEditorNode)
+}
+
+// editorNode is the compact node form the editor endpoints return. URL
+// deep-links to the node in the admin UI.
+type editorNode struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Title     string    `json:"title"`
+	Summary   string    `json:"summary,omitempty"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+}
+
+func (s *Server) editorNode(r *http.Request, n *db.Node) editorNode {
+	en := editorNode{
+		ID:        n.ID,
+		Type:      n.Type,
+		Title:     suggestTitle(n.Content),
+		Tags:      n.Tags,
+		UpdatedAt: n.UpdatedAt,
+		URL:       s.baseURL(r) + "/admin/nodes/" + n.ID,
+	}
+	if n.Summary != nil {
+		en.Summary = *n.Summary
+	}
+	if en.Tags == nil {
+		en.Tags = []string{}
+	}
+	return en
+}
+
+// repoProject returns the project tag ("project:<name>") mapped to a git
+// remote URL in any form, or "" when the repo is unknown.
+func (s *Server) repoProject(r *http.Request, repo string) (string, error) {
+	repo = strings.TrimSpace(repo)
+	if repo == "" {
+		return "", nil
+	}
+	normalized := ctxsync.NormalizeGitURL(repo)
+	mappings, err := s.storeFor(r).ListRepoMappings()
+	if err != nil {
+		return "", err
+	}
+	for _, m := range mappings {
+		if m.NormalizedURL == normalized {
+			if strings.HasPrefix(m.ProjectTag, "project:") {
+				return m.ProjectTag, nil
+			}
+			return "project:" + m.ProjectTag, nil
+		}
+	}
+	return "", nil
+}
+
+// editorLimit parses a limit, defaulting and capping it.
+func editorLimit(raw string, def int) (int, error) {
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("limit must be a positive integer")
+	}
+	return min(n, maxEditorLimit), nil
+}
+
+// handleEditorRecent lists the repo's most recently updated nodes.
+func (s *Server) handleEditorRecent(w http.ResponseWriter, r *http.Request) {
+	limit, err := editorLimit(r.URL.Query().Get("limit"), defaultEditorLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	project, err := s.repoProject(r, r.URL.Query().Get("repo"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if project == "" {
+		writeError(w, http.StatusNotFound, "repo is not mapped to a project (run ctx sync register-repo)")
+		return
+	}
+
+	nodes, err := s.storeFor(r).ListNodes(db.ListOptions{Tag: project})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].UpdatedAt.After(nodes[j].UpdatedAt)
+	})
+	if len(nodes) > limit {
+		nodes = nodes[:limit]
+	}
+
+	out := make([]editorNode, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, s.editorNode(r, n))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"project": project, "nodes": out})
+}
+
+type editorRememberRequest struct {
+	Type    string   `json:"type,omitempty"` // fact when empty
+	Content string   `json:"content"`
+	Tags    []string `json:"tags,omitempty"`
+	Repo    string   `json:"repo,omitempty"`
+}
+
+// handleEditorRemember stores a node, tagged with the repo's project unless
+// the request names one. Remembering the same type and content again merges
+// the tags into the existing node instead of creating a duplicate.
+func (s *Server) handleEditorRemember(w http.ResponseWriter, r *http.Request) {
+	var req editorRememberRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Type == "" {
+		req.Type = "fact"
+	}
+	if err := s.storeFor(r).Types().NodeType(req.Type); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validate.Tags(req.Tags); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	project, err := s.repoProject(r, req.Repo)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	tags := req.Tags
+	if project != "" && !hasProjectTag(tags) {
+		tags = append(tags, project)
+	}
+
+	existing, err := s.storeFor(r).FindByTypeAndContent(req.Type, db.NormalizeContent(req.Content))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if existing != nil {
+		if err := s.storeFor(r).AddTags(existing.ID, tags); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		node, err := s.storeFor(r).GetNode(existing.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, s.editorNode(r, node))
+		return
+	}
+
+	if err := s.checkQuota(r, db.Usage{Nodes: 1, Tokens: token.Estimate(req.Content)}); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	node, err := s.storeFor(r).CreateNode(db.CreateNodeInput{Type: req.Type, Content: req.Content, Tags: tags})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if deviceID, _ := s.requestDevice(r); deviceID != "" {
+		_ = s.storeFor(r).SetOriginDevice(node.ID, deviceID)
+	}
+	writeJSON(w, http.StatusCreated, s.editorNode(r, node))
+}
+
+func hasProjectTag(tags []string) bool {
+	for _, t := range tags {
+		if strings.HasPrefix(t, "project:") {
+			return true
+		}
+	}
+	return false
+}
+
+type editorRecallRequest struct {
+	Text  string `json:"text,omitempty"`  // full-text words, the last matched as a prefix
+	Query string `json:"query,omitempty"` // or a query-language expression
+	Repo  string `json:"repo,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+// handleEditorRecall finds nodes by text or query. With a mapped repo, it
+// leaves out nodes belonging to other projects; nodes without a project
+// tag are shared knowledge and always included.
+func (s *Server) handleEditorRecall(w http.ResponseWriter, r *http.Request) {
+	var req editorRecallRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (req.Text == "") == (req.Query == "") {
+		writeError(w, http.StatusBadRequest, "exactly one of text or query is required")
+		return
+	}
+	limit := defaultEditorLimit
+	if req.Limit > 0 {
+		limit = min(req.Limit, maxEditorLimit)
+	}
+	project, err := s.repoProject(r, req.Repo)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := query.WithTimeout(r.Context(), s.config.QueryTimeout)
+	defer cancel()
+	var nodes []*db.Node
+	if req.Query != "" {
+		nodes, err = query.ExecuteQueryContext(ctx, s.storeFor(r), req.Query, false)
+	} else {
+		// Fetch extra so project filtering still leaves enough
+		nodes, err = s.storeFor(r).WithContext(ctx).SearchPrefix(strings.Fields(req.Text), maxEditorLimit)
+	}
+	if err != nil {
+		writeError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+
+	out := []editorNode{}
+	var ids []string
+	for _, n := range nodes {
+		if len(out) == limit {
+			break
+		}
+		if n.Tags == nil {
+			if n.Tags, err = s.storeFor(r).GetTags(n.ID); err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		if project != "" && hasProjectTag(n.Tags) && !containsTag(n.Tags, project) {
+			continue
+		}
+		out = append(out, s.editorNode(r, n))
+		ids = append(ids, n.ID)
+	}
+
+	if len(ids) == 0 {
+		_ = usage.RecordMissedRecall(s.storeFor(r), req.Text+req.Query)
+	} else {
+		_ = usage.RecordRecalls(s.storeFor(r), ids)
+		_ = s.storeFor(r).RecordAccess(ids)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"project": project, "nodes": out})
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// handleEditorNode returns a node (short ID prefixes allowed) with its full
+// content and deep link, for opening it in the editor.
+func (s *Server) handleEditorNode(w http.ResponseWriter, r *http.Request) {
+	id, err := s.resolvePathID(r, r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	node, err := s.storeFor(r).GetNode(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	_ = usage.RecordAccess(s.storeFor(r), node)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"node":    s.editorNode(r, node),
+		"content": node.Content,
+	})
+}
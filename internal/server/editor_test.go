@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+)
+
+type editorResponse struct {
+	Project string       `json:"project"`
+	Nodes   []editorNode `json:"nodes"`
+}
+
+func decodeEditor(t *testing.T, w *httptest.ResponseRecorder) editorResponse {
+	t.Helper()
+	var resp editorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return resp
+}
+
+func TestEditorRecent(t *testing.T) {
+	srv, store := setupTestServer(t)
+	_, err := store.UpsertRepoMapping("github.com/acme/widgets", "widgets")
+	require.NoError(t, err)
+	first, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Widgets use gRPC", Tags: []string{"project:widgets"}})
+	second, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Widgets ship weekly", Tags: []string{"project:widgets"}})
+	_, _ = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Gadgets use REST", Tags: []string{"project:gadgets"}})
+	// Updating the older node makes it the most recent
+	_, err = store.Exec("UPDATE nodes SET updated_at = ? WHERE id = ?", "2099-01-01T00:00:00Z", first.ID)
+	require.NoError(t, err)
+
+	w := doRequest(t, srv, "GET", "/api/editor/recent?repo=git@github.com:acme/widgets.git", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp := decodeEditor(t, w)
+	assert.Equal(t, "project:widgets", resp.Project)
+	require.Len(t, resp.Nodes, 2)
+	assert.Equal(t, first.ID, resp.Nodes[0].ID)
+	assert.Equal(t, second.ID, resp.Nodes[1].ID)
+	assert.Equal(t, "http://example.com/admin/nodes/"+first.ID, resp.Nodes[0].URL)
+
+	w = doRequest(t, srv, "GET", "/api/editor/recent?repo=github.com/acme/unknown", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestEditorRemember(t *testing.T) {
+	srv, store := setupTestServer(t)
+	_, err := store.UpsertRepoMapping("github.com/acme/widgets", "widgets")
+	require.NoError(t, err)
+
+	body := map[string]any{"content": "Run make lint before pushing", "tags": []string{"ci"}, "repo": "https://github.com/acme/widgets"}
+	w := doRequest(t, srv, "POST", "/api/editor/remember", body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created editorNode
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "fact", created.Type)
+	assert.ElementsMatch(t, []string{"ci", "project:widgets"}, created.Tags)
+
+	// The same content again merges tags rather than duplicating
+	body["tags"] = []string{"tooling"}
+	w = doRequest(t, srv, "POST", "/api/editor/remember", body)
+	require.Equal(t, http.StatusOK, w.Code)
+	var merged editorNode
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &merged))
+	assert.Equal(t, created.ID, merged.ID)
+	assert.ElementsMatch(t, []string{"ci", "tooling", "project:widgets"}, merged.Tags)
+}
+
+func TestEditorRemember_Validates(t *testing.T) {
+	srv, store := setupTestServer(t)
+	existing, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Run make lint before pushing"})
+	require.NoError(t, err)
+
+	// An unknown type or a malformed tag is a bad request, whether the
+	// content is new or would merge into an existing node
+	for _, body := range []map[string]any{
+		{"content": "Widgets ship weekly", "type": "bogus"},
+		{"content": "Widgets ship weekly", "tags": []string{"bad tag"}},
+		{"content": "Run make lint before pushing", "tags": []string{"bad tag"}},
+	} {
+		w := doRequest(t, srv, "POST", "/api/editor/remember", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	nodes, err := store.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Empty(t, nodes[0].Tags)
+	assert.Equal(t, existing.ID, nodes[0].ID)
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/zate/ctx/internal/auth"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
+	"github.com/zate/ctx/internal/provenance"
+)
+
+// eraseKeyPrefix keys each user's pending delete-all confirmation in the
+// pending table, so any instance can confirm it.
+const eraseKeyPrefix = "erase:"
+
+// eraseConfirmTTL is how long a delete-all confirmation token is valid.
+const eraseConfirmTTL = 10 * time.Minute
+
+// pendingErase is a delete-all waiting for its confirmation token.
+type pendingErase struct {
+	TokenHash string    `json:"token_hash"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// requestUser returns the user that authenticated r, writing an error and
+// returning "" when there is none: with auth disabled nodes belong to no
+// one, so there is nothing to scope an export or delete-all to.
+func (s *Server) requestUser(w http.ResponseWriter, r *http.Request) string {
+	_, userID := s.requestDevice(r)
+	if userID == "" {
+		writeError(w, http.StatusForbidden, "export and delete-all are per user and need auth enabled on the server")
+	}
+	return userID
+}
+
+// userNodeSet returns the IDs of the nodes created from userID's devices.
+func (s *Server) userNodeSet(r *http.Request, userID string) (map[string]bool, error) {
+	ids, err := s.storeFor(r).UserNodeIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+// handleExport streams every node created from the authenticated user's
+// devices, with their tags and the edges between them, as ctx export
+// JSONL. Views are shared by all users and left out.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	userID := s.requestUser(w, r)
+	if userID == "" {
+		return
+	}
+	if f := r.URL.Query().Get("format"); f != "" && f != "jsonl" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported export format %q (use jsonl)", f))
+		return
+	}
+	owned, err := s.userNodeSet(r, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="ctx-export-%s.jsonl"`, time.Now().UTC().Format("20060102")))
+	_, err = export.Write(s.storeFor(r), w, export.Options{
+		Include: func(n *db.Node) bool { return owned[n.ID] },
+		NoViews: true,
+	})
+	if err != nil {
+		// The status is already sent; a truncated stream fails to import
+		log.Printf("ctx server: export for user %s failed: %v", userID, err)
+	}
+}
+
+// handleExportDelete deletes every node created from the authenticated
+// user's devices, in two steps: a request without a token returns what
+// would be deleted and a confirmation token, and repeating it with
+// {"confirm": token} within eraseConfirmTTL deletes. Devices are kept.
+func (s *Server) handleExportDelete(w http.ResponseWriter, r *http.Request) {
+	userID := s.requestUser(w, r)
+	if userID == "" {
+		return
+	}
+	var req struct {
+		Confirm string `json:"confirm"`
+	}
+	if err := readJSON(r, &req); err != nil && !errors.Is(err, errEmptyBody) {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	key := eraseKeyPrefix + userID
+
+	if req.Confirm == "" {
+		usage, err := s.storeFor(r).UserUsage(userID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		token := auth.GenerateToken()
+		pending := pendingErase{TokenHash: auth.HashToken(token), ExpiresAt: time.Now().Add(eraseConfirmTTL).UTC()}
+		data, _ := json.Marshal(pending)
+		if err := s.storeFor(r).SetPending(key, string(data)); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{
+			"nodes":      usage.Nodes,
+			"tokens":     usage.Tokens,
+			"confirm":    token,
+			"expires_at": pending.ExpiresAt,
+			"message":    "nothing deleted yet: repeat the request with {\"confirm\": token} to delete these nodes",
+		})
+		return
+	}
+
+	var pending pendingErase
+	value, err := s.storeFor(r).GetPending(key)
+	if err == nil {
+		err = json.Unmarshal([]byte(value), &pending)
+	}
+	if err != nil || time.Now().After(pending.ExpiresAt) ||
+		subtle.ConstantTimeCompare([]byte(auth.HashToken(req.Confirm)), []byte(pending.TokenHash)) != 1 {
+		writeError(w, http.StatusForbidden, "invalid or expired confirmation token; request a new one")
+		return
+	}
+	_ = s.storeFor(r).DeletePending(key)
+
+	ids, err := s.storeFor(r).UserNodeIDs(userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	deleted := 0
+	for _, id := range ids {
+		if _, err := provenance.MarkStale(s.storeFor(r), id); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if err := s.storeFor(r).DeleteNode(id); err != nil && !errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusInternalServerError, fmt.Sprintf("deleted %d of %d nodes: %v", deleted, len(ids), err))
+			return
+		}
+		deleted++
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"deleted": deleted})
+}
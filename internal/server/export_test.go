@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/auth"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/export"
+	"github.com/zate/ctx/testutil"
+)
+
+// setupExportServer returns an auth-enabled server with nodes from two
+// users: alice owns two linked nodes, bob one linked to hers.
+func setupExportServer(t *testing.T) (srv *Server, store db.Store, alice, bob []string) {
+	t.Helper()
+	srv, store = setupQuotaServer(t, QuotaConfig{})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+	bobID, err := store.EnsureUser("bob", auth.HashToken("pw"))
+	require.NoError(t, err)
+	_, err = store.CreateDevice(bobID, "phone", auth.HashToken("bobtok"), auth.HashToken("bobref"))
+	require.NoError(t, err)
+
+	create := func(token, content string) string {
+		w := deviceRequest(t, srv, token, "POST", "/api/nodes", map[string]any{"type": "fact", "content": content, "tags": []string{"project:ctx"}})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var n db.Node
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &n))
+		return n.ID
+	}
+	alice = []string{create("tok", "alice one"), create("tok", "alice two")}
+	bob = []string{create("bobtok", "bob one")}
+	_, err = store.CreateEdge(alice[0], alice[1], "RELATES_TO")
+	require.NoError(t, err)
+	_, err = store.CreateEdge(bob[0], alice[0], "RELATES_TO")
+	require.NoError(t, err)
+	return srv, store, alice, bob
+}
+
+func TestExport_OnlyTheUsersNodes(t *testing.T) {
+	srv, _, alice, _ := setupExportServer(t)
+
+	w := deviceRequest(t, srv, "tok", "GET", "/api/export?format=jsonl", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	dst := testutil.SetupTestDB(t)
+	res, err := export.Import(dst, w.Body, export.ImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Nodes)
+	assert.Equal(t, 1, res.Edges, "the edge to bob's node is left out")
+	assert.Equal(t, 2, res.Tags)
+	for _, id := range alice {
+		_, err := dst.GetNode(id)
+		assert.NoError(t, err)
+	}
+
+	w = deviceRequest(t, srv, "tok", "GET", "/api/export?format=csv", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = deviceRequest(t, srv, "wrong", "GET", "/api/export", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestExport_NeedsAuth(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	w := doRequest(t, srv, "GET", "/api/export", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestExportDelete_Confirmation(t *testing.T) {
+	srv, store, alice, bob := setupExportServer(t)
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/export/delete", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var step1 struct {
+		Nodes   int    `json:"nodes"`
+		Confirm string `json:"confirm"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &step1))
+	assert.Equal(t, 2, step1.Nodes)
+	require.NotEmpty(t, step1.Confirm)
+	_, err := store.GetNode(alice[0])
+	require.NoError(t, err, "nothing is deleted before confirming")
+
+	// Another user's token cannot confirm it
+	w = deviceRequest(t, srv, "bobtok", "POST", "/api/export/delete", map[string]string{"confirm": step1.Confirm})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = deviceRequest(t, srv, "tok", "POST", "/api/export/delete", map[string]string{"confirm": "wrong"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = deviceRequest(t, srv, "tok", "POST", "/api/export/delete", map[string]string{"confirm": step1.Confirm})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"deleted": 2}`, w.Body.String())
+	for _, id := range alice {
+		_, err := store.GetNode(id)
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	}
+	_, err = store.GetNode(bob[0])
+	assert.NoError(t, err)
+
+	// The token is single-use
+	w = deviceRequest(t, srv, "tok", "POST", "/api/export/delete", map[string]string{"confirm": step1.Confirm})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
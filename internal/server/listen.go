@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/zate/ctx/internal/backup"
+	"github.com/zate/ctx/internal/db"
+)
+
+// sdListenFDsStart is the first file descriptor systemd passes to a
+// socket-activated service (SD_LISTEN_FDS_START).
+const sdListenFDsStart = 3
+
+// activatedFDs returns how many sockets systemd passed to this process, or
+// 0 if it was not socket-activated.
+func activatedFDs() int {
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return 0
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// SystemdListener returns the first socket passed by systemd socket
+// activation, or nil if the process was not socket-activated. It clears
+// the LISTEN_* variables so child processes don't inherit them.
+func SystemdListener() (net.Listener, error) {
+	n := activatedFDs()
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if n == 0 {
+		return nil, nil
+	}
+
+	f := os.NewFile(uintptr(sdListenFDsStart), "LISTEN_FD_3")
+	ln, err := net.FileListener(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to use systemd socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve serves on ln, using TLS if configured. With a store that reports
+// changes, it also listens for them to keep the response cache valid, and
+// with a backup or report interval configured it takes backups or writes
+// memory reports on schedule.
+func (s *Server) Serve(ln net.Listener) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if n, ok := s.store.(db.ChangeNotifier); ok && s.cache != nil {
+		go s.watchChanges(ctx, n)
+	}
+	if s.config.Backup.Interval > 0 {
+		target, err := backup.New(s.config.Backup)
+		if err != nil {
+			return err
+		}
+		go s.scheduleBackups(ctx, target)
+	}
+	if s.config.Report.Interval > 0 {
+		if s.config.Report.Dir == "" {
+			return fmt.Errorf("report.interval needs report.dir, the directory reports are written to")
+		}
+		if err := os.MkdirAll(s.config.Report.Dir, 0755); err != nil {
+			return fmt.Errorf("failed to create report directory: %w", err)
+		}
+		go s.scheduleReports(ctx)
+	}
+	srv := &http.Server{Handler: s.Handler()}
+	if s.config.HasTLS() {
+		log.Printf("ctx server listening on https://%s", ln.Addr())
+		return srv.ServeTLS(ln, s.config.TLSCert, s.config.TLSKey)
+	}
+	log.Printf("ctx server listening on http://%s", ln.Addr())
+	return srv.Serve(ln)
+}
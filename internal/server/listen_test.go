@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestActivatedFDs(t *testing.T) {
+	t.Setenv("LISTEN_FDS", "1")
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	assert.Equal(t, 0, activatedFDs(), "sockets meant for another process")
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	assert.Equal(t, 1, activatedFDs())
+}
+
+func TestServe(t *testing.T) {
+	srv := New(testutil.SetupTestDB(t), DefaultConfig())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(ln) }()
+	defer ln.Close()
+
+	resp, err := http.Get("http://" + ln.Addr().String() + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestConfigSetAddr(t *testing.T) {
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.SetAddr("127.0.0.1:9000"))
+	assert.Equal(t, "127.0.0.1:9000", cfg.Addr())
+
+	require.NoError(t, cfg.SetAddr(":9001"))
+	assert.Equal(t, "0.0.0.0:9001", cfg.Addr())
+
+	assert.Error(t, cfg.SetAddr("localhost"))
+	assert.Error(t, cfg.SetAddr("localhost:http"))
+}
+
+func TestConfigReadAdminPasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0600))
+
+	cfg := DefaultConfig()
+	cfg.AdminPassword = "ignored"
+	cfg.AdminPasswordFile = path
+	require.NoError(t, cfg.ReadAdminPasswordFile())
+	assert.Equal(t, "s3cret", cfg.AdminPassword)
+
+	require.NoError(t, os.WriteFile(path, []byte("\n"), 0600))
+	assert.Error(t, cfg.ReadAdminPasswordFile())
+}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"crypto/subtle"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
@@ -25,3 +29,100 @@ func (sw *statusWriter) WriteHeader(code int) {
 	sw.status = code
 	sw.ResponseWriter.WriteHeader(code)
 }
+
+// corsMiddleware lets the browser-based callers in Config.EditorOrigins
+// (VS Code webviews by default) use the /api/editor/ routes. Preflight
+// requests are answered here, before auth, since browsers send them
+// without credentials.
+func (s *Server) corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !strings.HasPrefix(r.URL.Path, "/api/editor/") || !s.config.allowsOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sameOriginMiddleware refuses state-changing requests a browser sent from
+// another site, so a page the user visits can't post forms to the admin UI
+// or API, above all on the unauthenticated local server ctx ui and ctx open
+// run. The request's Origin, or its Referer when there is none, must name
+// this server's host; the editor origins CORS admits are allowed on their
+// routes. Requests carrying neither, as from the CLI, are not browser form
+// posts and pass.
+func (s *Server) sameOriginMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			origin = r.Header.Get("Referer")
+		}
+		if origin == "" || strings.HasPrefix(r.URL.Path, "/api/editor/") && s.config.allowsOrigin(origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if u, err := url.Parse(origin); err != nil || !strings.EqualFold(u.Host, r.Host) {
+			writeError(w, http.StatusForbidden, "cross-origin request refused")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// localMiddleware guards a server started by ctx open or ctx ui, which has
+// no password. Requests must name a loopback host, and the port the
+// server listens on, so a page rebinding its own domain to 127.0.0.1
+// can't reach it; and they must carry the run's token, so other local
+// pages can't either. The token comes in the launched URL and is kept in
+// a cookie for the rest of the session.
+func (s *Server) localMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, port, err := net.SplitHostPort(r.Host)
+		if err != nil || (host != "localhost" && host != "127.0.0.1" && host != "::1") {
+			writeError(w, http.StatusForbidden, "unexpected host")
+			return
+		}
+		if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+			if _, bound, err := net.SplitHostPort(addr.String()); err == nil && bound != port {
+				writeError(w, http.StatusForbidden, "unexpected host")
+				return
+			}
+		}
+
+		valid := func(token string) bool {
+			return subtle.ConstantTimeCompare([]byte(token), []byte(s.config.LocalToken)) == 1
+		}
+		// Cookies are shared across ports, so each run names its own
+		cookie := "ctx_local_" + port
+		if token := r.URL.Query().Get("token"); valid(token) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     cookie,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				SameSite: http.SameSiteStrictMode,
+			})
+		} else if c, err := r.Cookie(cookie); err != nil || !valid(c.Value) {
+			writeError(w, http.StatusUnauthorized, "open the UI from the link ctx printed")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
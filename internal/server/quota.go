@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// quotaUsage is one scope's usage alongside its limits, as reported by
+// /api/status.
+type quotaUsage struct {
+	db.Usage
+	QuotaLimits
+}
+
+// requestDevice returns the device and user that authenticated r, or empty
+// strings when auth is disabled (the headers are only trusted after
+// authenticate has set them).
+func (s *Server) requestDevice(r *http.Request) (deviceID, userID string) {
+	if s.config.AdminPassword == "" {
+		return "", ""
+	}
+	return r.Header.Get("X-Device-ID"), r.Header.Get("X-User-ID")
+}
+
+// checkQuota returns an error if storing add more (nodes, tokens and
+// attachment bytes) would take the requesting device or its user over
+// quota.
+func (s *Server) checkQuota(r *http.Request, add db.Usage) error {
+	deviceID, userID := s.requestDevice(r)
+	if deviceID == "" {
+		return nil
+	}
+	if err := s.checkScope("device", s.config.Quota.Device, deviceID, s.storeFor(r).DeviceUsage, add); err != nil {
+		return err
+	}
+	return s.checkScope("user", s.config.Quota.User, userID, s.storeFor(r).UserUsage, add)
+}
+
+func (s *Server) checkScope(scope string, limits QuotaLimits, id string, usage func(string) (*db.Usage, error), add db.Usage) error {
+	if limits == (QuotaLimits{}) {
+		return nil
+	}
+	u, err := usage(id)
+	if err != nil {
+		return err
+	}
+	if limits.MaxNodes > 0 && add.Nodes > 0 && u.Nodes+add.Nodes > limits.MaxNodes {
+		return fmt.Errorf("quota exceeded: this %s has %d nodes and the limit is %d (adding %d)",
+			scope, u.Nodes, limits.MaxNodes, add.Nodes)
+	}
+	if limits.MaxTokens > 0 && add.Tokens > 0 && u.Tokens+add.Tokens > limits.MaxTokens {
+		return fmt.Errorf("quota exceeded: this %s stores %d tokens and the limit is %d (adding %d)",
+			scope, u.Tokens, limits.MaxTokens, add.Tokens)
+	}
+	if limits.MaxAttachmentBytes > 0 && add.AttachmentBytes > 0 && u.AttachmentBytes+add.AttachmentBytes > limits.MaxAttachmentBytes {
+		return fmt.Errorf("quota exceeded: this %s stores %d bytes of attachments and the limit is %d (adding %d)",
+			scope, u.AttachmentBytes, limits.MaxAttachmentBytes, add.AttachmentBytes)
+	}
+	return nil
+}
+
+// quotaStatus returns the requesting device's and user's usage and limits,
+// or nil when the request is not tied to a device.
+func (s *Server) quotaStatus(r *http.Request) map[string]quotaUsage {
+	deviceID, userID := s.requestDevice(r)
+	if deviceID == "" {
+		return nil
+	}
+	out := make(map[string]quotaUsage)
+	if u, err := s.storeFor(r).DeviceUsage(deviceID); err == nil {
+		out["device"] = quotaUsage{*u, s.config.Quota.Device}
+	}
+	if u, err := s.storeFor(r).UserUsage(userID); err == nil {
+		out["user"] = quotaUsage{*u, s.config.Quota.User}
+	}
+	return out
+}
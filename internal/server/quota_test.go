@@ -0,0 +1,187 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/testutil"
+)
+
+func setupQuotaServer(t *testing.T, quota QuotaConfig) (*Server, db.Store) {
+	t.Helper()
+	store := testutil.SetupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.AdminPassword = "secret123"
+	cfg.Quota = quota
+	return New(store, cfg), store
+}
+
+func deviceRequest(t *testing.T, srv *Server, token, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		require.NoError(t, json.NewEncoder(&buf).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestQuota_CreateNode(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{Device: QuotaLimits{MaxNodes: 2}})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+	insertTestDevice(t, store, "desktop", "tok2", "ref2", false)
+
+	for i := 0; i < 2; i++ {
+		w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "x"})
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "x"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "quota exceeded")
+
+	// Another device has its own allowance
+	w = deviceRequest(t, srv, "tok2", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "x"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestQuota_UserTokens(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{User: QuotaLimits{MaxTokens: 10}})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+	insertTestDevice(t, store, "desktop", "tok2", "ref2", false)
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": strings.Repeat("a", 32)})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// Both devices belong to the admin user, so they share the user quota
+	w = deviceRequest(t, srv, "tok2", "POST", "/api/nodes", map[string]any{"type": "fact", "content": strings.Repeat("b", 16)})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "this user")
+}
+
+func TestQuota_SyncPushIsAllOrNothing(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{Device: QuotaLimits{MaxNodes: 1}})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+
+	push := map[string]any{
+		"device_id": "laptop",
+		"changes": []map[string]any{
+			{"node": map[string]any{"id": db.NewID(), "type": "fact", "content": "one"}},
+			{"node": map[string]any{"id": db.NewID(), "type": "fact", "content": "two"}},
+		},
+	}
+	w := deviceRequest(t, srv, "tok", "POST", "/api/sync/push", push)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "quota exceeded")
+
+	st, err := store.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, 0, st.TotalNodes, "a rejected push stores nothing")
+}
+
+func TestQuota_Updates(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{Device: QuotaLimits{MaxTokens: 10}})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "12345678"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var node db.Node
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &node))
+
+	// Growing the node past the quota is refused; staying within it is not
+	w = deviceRequest(t, srv, "tok", "PATCH", "/api/nodes/"+node.ID, map[string]any{"content": strings.Repeat("a", 48)})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "quota exceeded")
+	w = deviceRequest(t, srv, "tok", "PATCH", "/api/nodes/"+node.ID, map[string]any{"content": strings.Repeat("a", 40)})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	push := map[string]any{
+		"device_id": "laptop",
+		"changes": []map[string]any{
+			{"node": map[string]any{"id": node.ID, "type": "fact", "content": strings.Repeat("b", 80), "updated_at": time.Now().Add(time.Hour)}},
+		},
+	}
+	w = deviceRequest(t, srv, "tok", "POST", "/api/sync/push", push)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	got, err := store.GetNode(node.ID)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("a", 40), got.Content)
+
+	// Shrinking is always allowed
+	push["changes"].([]map[string]any)[0]["node"].(map[string]any)["content"] = "short"
+	w = deviceRequest(t, srv, "tok", "POST", "/api/sync/push", push)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestQuota_Attachments(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{Device: QuotaLimits{MaxAttachmentBytes: 100}})
+	deviceID := insertTestDevice(t, store, "laptop", "tok", "ref", false)
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "x"})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var node db.Node
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &node))
+
+	attach := func(size int) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/nodes/"+node.ID+"/attachments?name=a.bin", bytes.NewReader(make([]byte, size)))
+		req.Header.Set("Authorization", "Bearer tok")
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusCreated, attach(60).Code)
+	w = attach(60)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "bytes of attachments")
+	assert.Equal(t, http.StatusCreated, attach(40).Code)
+
+	u, err := store.DeviceUsage(deviceID)
+	require.NoError(t, err)
+	assert.Equal(t, 100, u.AttachmentBytes)
+}
+
+func TestQuota_Status(t *testing.T) {
+	srv, store := setupQuotaServer(t, QuotaConfig{Device: QuotaLimits{MaxNodes: 5}})
+	insertTestDevice(t, store, "laptop", "tok", "ref", false)
+
+	w := deviceRequest(t, srv, "tok", "POST", "/api/nodes", map[string]any{"type": "fact", "content": "12345678"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = deviceRequest(t, srv, "tok", "GET", "/api/status", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Quota map[string]quotaUsage `json:"quota"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Quota["device"].Nodes)
+	assert.Equal(t, 2, resp.Quota["device"].Tokens)
+	assert.Equal(t, 5, resp.Quota["device"].MaxNodes)
+	assert.Equal(t, 0, resp.Quota["user"].MaxNodes)
+}
+
+func TestQuota_IgnoredWithoutAuth(t *testing.T) {
+	store := testutil.SetupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.Quota.Device.MaxNodes = 1
+	srv := New(store, cfg)
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest("POST", "/api/nodes", strings.NewReader(`{"type":"fact","content":"x"}`))
+		req.Header.Set("X-Device-ID", "spoofed")
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/report"
+)
+
+// reportTimeFormat stamps report file names, e.g. ctx-report-20250301T020000Z.md,
+// so they sort by time and the newest tells when the next is due.
+const reportTimeFormat = "20060102T150405Z"
+
+// reportName returns the file name of the report written at t.
+func reportName(t time.Time) string {
+	return "ctx-report-" + t.UTC().Format(reportTimeFormat) + ".md"
+}
+
+// latestReport returns when the newest report in dir was written, or the
+// zero time when there is none.
+func latestReport(dir string) time.Time {
+	var latest time.Time
+	matches, _ := filepath.Glob(filepath.Join(dir, "ctx-report-*.md"))
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), "ctx-report-"), ".md")
+		if t, err := time.Parse(reportTimeFormat, stamp); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// scheduleReports writes a memory report to Report.Dir every
+// Report.Interval until ctx is done. Each covers the time since the
+// previous one, and the first is due an interval after the newest report
+// already written, so restarting the server neither skips nor repeats one.
+func (s *Server) scheduleReports(ctx context.Context) {
+	interval := s.config.Report.Interval
+	since := latestReport(s.config.Report.Dir)
+	var wait time.Duration
+	if !since.IsZero() {
+		wait = time.Until(since.Add(interval))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(max(wait, 0)):
+		}
+		now := time.Now().UTC()
+		if since.IsZero() {
+			since = now.Add(-interval)
+		}
+		path, err := s.writeReport(since, now)
+		if err != nil {
+			log.Printf("ctx server: memory report failed: %v", err)
+		} else {
+			log.Printf("ctx server: wrote memory report %s", path)
+			since = now
+		}
+		wait = interval
+	}
+}
+
+// writeReport writes the report on the store's changes since since to
+// Report.Dir, named for now.
+func (s *Server) writeReport(since, now time.Time) (string, error) {
+	r, err := report.Generate(s.store, report.Options{Since: since, AllAgents: true})
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(s.config.Report.Dir, reportName(now))
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(report.RenderMarkdown(r)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+)
+
+func TestWriteReport(t *testing.T) {
+	srv, store := setupTestServer(t)
+	srv.config.Report = ReportConfig{Interval: time.Hour, Dir: t.TempDir()}
+	_, err := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use Postgres for the server"})
+	require.NoError(t, err)
+
+	assert.True(t, latestReport(srv.config.Report.Dir).IsZero())
+
+	now := time.Date(2025, 3, 1, 2, 0, 0, 0, time.UTC)
+	path, err := srv.writeReport(now.Add(-time.Hour), now)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(srv.config.Report.Dir, "ctx-report-20250301T020000Z.md"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# Memory report: 2025-03-01 01:00"))
+
+	_, err = srv.writeReport(now, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(time.Hour), latestReport(srv.config.Report.Dir))
+}
+
+func TestScheduleReports(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.config.Report = ReportConfig{Interval: time.Hour, Dir: t.TempDir()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.scheduleReports(ctx)
+
+	// With no report yet, the first is written straight away
+	require.Eventually(t, func() bool {
+		return !latestReport(srv.config.Report.Dir).IsZero()
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServe_ReportNeedsDir(t *testing.T) {
+	srv, _ := setupTestServer(t)
+	srv.config.Report.Interval = time.Hour
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	assert.ErrorContains(t, srv.Serve(ln), "report.interval needs report.dir")
+}
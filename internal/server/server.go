@@ -1,18 +1,27 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/zate/ctx/internal/auth"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/integrity"
+	"github.com/zate/ctx/internal/provenance"
 	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/stats"
 	ctxsync "github.com/zate/ctx/internal/sync"
+	"github.com/zate/ctx/internal/timefmt"
+	"github.com/zate/ctx/internal/token"
+	"github.com/zate/ctx/internal/usage"
+	"github.com/zate/ctx/internal/validate"
 	"github.com/zate/ctx/internal/view"
 )
 
@@ -22,6 +31,8 @@ type Server struct {
 	mux    *http.ServeMux
 	config Config
 	flows  *auth.DeviceFlowStore
+	clock  *timefmt.Clock
+	cache  *responseCache // nil unless the store reports changes
 }
 
 // New creates a new Server with the given store and config.
@@ -30,10 +41,16 @@ func New(store db.Store, cfg Config) *Server {
 		store:  store,
 		mux:    http.NewServeMux(),
 		config: cfg,
-		flows:  auth.NewDeviceFlowStore(),
+		flows:  auth.NewSharedDeviceFlowStore(store),
+		clock:  timefmt.New(cfg.Timezone, cfg.RelativeTimes),
+	}
+	if _, ok := store.(db.ChangeNotifier); ok && !cfg.DisableCache {
+		s.cache = newResponseCache()
 	}
 	s.registerRoutes()
 	s.registerAuthRoutes()
+	s.registerAdminAPIRoutes()
+	s.registerEditorRoutes()
 	s.registerWebUIRoutes()
 	return s
 }
@@ -44,26 +61,37 @@ func (s *Server) Handler() http.Handler {
 	if s.config.AdminPassword != "" {
 		handler = s.authMiddleware(handler)
 	}
+	if s.cache != nil {
+		handler = s.invalidateOnWrite(handler)
+	}
+	handler = s.sameOriginMiddleware(handler)
+	if s.config.LocalToken != "" {
+		handler = s.localMiddleware(handler)
+	}
+	handler = s.corsMiddleware(handler)
 	return loggingMiddleware(handler)
 }
 
-// ListenAndServe starts the server. Uses TLS if configured.
+// ListenAndServe starts the server on the systemd-activated socket if there
+// is one, otherwise on the configured address. Uses TLS if configured.
 func (s *Server) ListenAndServe() error {
-	addr := s.config.Addr()
-	handler := s.Handler()
-
-	if s.config.HasTLS() {
-		log.Printf("ctx server listening on https://%s", addr)
-		return http.ListenAndServeTLS(addr, s.config.TLSCert, s.config.TLSKey, handler)
+	ln, err := SystemdListener()
+	if err != nil {
+		return err
 	}
-
-	log.Printf("ctx server listening on http://%s", addr)
-	return http.ListenAndServe(addr, handler)
+	if ln == nil {
+		if ln, err = net.Listen("tcp", s.config.Addr()); err != nil {
+			return err
+		}
+	}
+	return s.Serve(ln)
 }
 
 func (s *Server) registerRoutes() {
 	s.mux.HandleFunc("GET /health", s.handleHealth)
 	s.mux.HandleFunc("GET /api/status", s.handleStatus)
+	s.mux.HandleFunc("GET /api/stats/top", s.cached(s.handleStatsTop))
+	s.mux.HandleFunc("GET /api/integrity", s.handleIntegrity)
 
 	// Node CRUD
 	s.mux.HandleFunc("POST /api/nodes", s.handleCreateNode)
@@ -80,9 +108,20 @@ func (s *Server) registerRoutes() {
 	s.mux.HandleFunc("POST /api/nodes/{id}/tags", s.handleAddTags)
 	s.mux.HandleFunc("DELETE /api/nodes/{id}/tags", s.handleRemoveTags)
 
+	// Attachments
+	s.mux.HandleFunc("GET /api/nodes/{id}/attachments", s.handleListAttachments)
+	s.mux.HandleFunc("POST /api/nodes/{id}/attachments", s.handleAddAttachment)
+	s.mux.HandleFunc("GET /api/attachments/{id}", s.handleGetAttachment)
+	s.mux.HandleFunc("DELETE /api/attachments/{id}", s.handleDeleteAttachment)
+
 	// Query and compose
 	s.mux.HandleFunc("POST /api/query", s.handleQuery)
-	s.mux.HandleFunc("POST /api/compose", s.handleCompose)
+	s.mux.HandleFunc("POST /api/query/aggregate", s.handleQueryAggregate)
+	s.mux.HandleFunc("POST /api/compose", s.cached(s.handleCompose))
+	s.mux.HandleFunc("GET /api/suggest", s.handleSuggest)
+	s.mux.HandleFunc("GET /digest.atom", s.handleDigest)
+	s.mux.HandleFunc("POST /api/digest/token", s.handleDigestToken)
+	s.mux.HandleFunc("DELETE /api/digest/token", s.handleRevokeDigestToken)
 
 	// Sync
 	s.mux.HandleFunc("POST /api/sync/push", s.handleSyncPush)
@@ -90,6 +129,10 @@ func (s *Server) registerRoutes() {
 
 	// Repo mappings
 	s.mux.HandleFunc("POST /api/repo-mappings", s.handleCreateRepoMapping)
+
+	// The authenticated user's own data
+	s.mux.HandleFunc("GET /api/export", s.handleExport)
+	s.mux.HandleFunc("POST /api/export/delete", s.handleExportDelete)
 }
 
 // --- Health ---
@@ -101,18 +144,58 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 // --- Status ---
 
 func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
-	var totalNodes, totalTokens, edgeCount, tagCount int
-	_ = s.store.QueryRow("SELECT COUNT(*) FROM nodes WHERE superseded_by IS NULL").Scan(&totalNodes)
-	_ = s.store.QueryRow("SELECT COALESCE(SUM(token_estimate), 0) FROM nodes WHERE superseded_by IS NULL").Scan(&totalTokens)
-	_ = s.store.QueryRow("SELECT COUNT(*) FROM edges").Scan(&edgeCount)
-	_ = s.store.QueryRow("SELECT COUNT(DISTINCT tag) FROM tags").Scan(&tagCount)
+	st, err := s.storeFor(r).Stats()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
-		"total_nodes":  totalNodes,
-		"total_tokens": totalTokens,
-		"total_edges":  edgeCount,
-		"unique_tags":  tagCount,
-	})
+	status := map[string]any{
+		"total_nodes":  st.TotalNodes,
+		"total_tokens": st.TotalTokens,
+		"total_edges":  st.TotalEdges,
+		"unique_tags":  st.UniqueTags,
+	}
+	if quota := s.quotaStatus(r); quota != nil {
+		status["quota"] = quota
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleStatsTop(w http.ResponseWriter, r *http.Request) {
+	limit := stats.DefaultTopLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	report, err := stats.Top(s.storeFor(r), stats.TopOptions{Limit: limit, AllAgents: true})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleIntegrity reports integrity issues; POST /api/admin/integrity/fix
+// also repairs them.
+func (s *Server) handleIntegrity(w http.ResponseWriter, r *http.Request) {
+	report, err := integrity.Check(s.storeFor(r))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if r.Method == http.MethodPost {
+		if err := integrity.Fix(s.storeFor(r), report); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, report)
 }
 
 // --- Node CRUD ---
@@ -131,6 +214,14 @@ func (s *Server) handleCreateNode(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := s.storeFor(r).Types().NodeType(req.Type); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validate.Tags(req.Tags); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	input := db.CreateNodeInput{
 		Type:     req.Type,
@@ -140,27 +231,36 @@ func (s *Server) handleCreateNode(w http.ResponseWriter, r *http.Request) {
 		Tags:     req.Tags,
 	}
 
-	node, err := s.store.CreateNode(input)
+	if err := s.checkQuota(r, db.Usage{Nodes: 1, Tokens: token.Estimate(req.Content)}); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	node, err := s.storeFor(r).CreateNode(input)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if deviceID, _ := s.requestDevice(r); deviceID != "" {
+		_ = s.storeFor(r).SetOriginDevice(node.ID, deviceID)
+	}
 
 	writeJSON(w, http.StatusCreated, node)
 }
 
 func (s *Server) handleGetNode(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	node, err := s.store.GetNode(id)
+	node, err := s.storeFor(r).GetNode(id)
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	_ = usage.RecordAccess(s.storeFor(r), node)
 
 	writeJSON(w, http.StatusOK, node)
 }
@@ -173,7 +273,7 @@ type updateNodeRequest struct {
 }
 
 func (s *Server) handleUpdateNode(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -184,8 +284,27 @@ func (s *Server) handleUpdateNode(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if req.Type != nil {
+		if err := s.storeFor(r).Types().NodeType(*req.Type); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.Content != nil {
+		existing, err := s.storeFor(r).GetNode(id)
+		if err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		// Growing a node counts against the token quota as a new one would
+		grown := token.Estimate(db.NormalizeContent(*req.Content)) - existing.TokenEstimate
+		if err := s.checkQuota(r, db.Usage{Tokens: grown}); err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
 
-	node, err := s.store.UpdateNode(id, db.UpdateNodeInput{
+	node, err := s.storeFor(r).UpdateNode(id, db.UpdateNodeInput{
 		Content:  req.Content,
 		Type:     req.Type,
 		Summary:  req.Summary,
@@ -200,13 +319,18 @@ func (s *Server) handleUpdateNode(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
 
-	if err := s.store.DeleteNode(id); err != nil {
+	if _, err := provenance.MarkStale(s.storeFor(r), id); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if err := s.storeFor(r).DeleteNode(id); err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
@@ -217,7 +341,7 @@ func (s *Server) handleDeleteNode(w http.ResponseWriter, r *http.Request) {
 // --- Edges ---
 
 func (s *Server) handleGetEdges(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -228,15 +352,86 @@ func (s *Server) handleGetEdges(w http.ResponseWriter, r *http.Request) {
 		direction = "both"
 	}
 
-	edges, err := s.store.GetEdges(id, direction)
+	edges, err := s.storeFor(r).GetEdges(id, direction)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if r.URL.Query().Get("hydrate") == "true" {
+		writeJSON(w, http.StatusOK, s.hydrateEdges(r, id, edges))
+		return
+	}
 	writeJSON(w, http.StatusOK, edges)
 }
 
+// previewLength caps the peer preview in hydrated edges, in runes.
+const previewLength = 120
+
+// edgePeer is the node on the other end of an edge.
+type edgePeer struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"`
+	Summary *string  `json:"summary,omitempty"`
+	Preview string   `json:"preview"`
+	Tags    []string `json:"tags"`
+}
+
+// hydratedEdge is an edge plus its direction relative to the requested node
+// and the peer node, so clients don't need a GET per edge. Peer is nil if
+// the peer node no longer exists.
+type hydratedEdge struct {
+	*db.Edge
+	Direction string    `json:"direction"`
+	Peer      *edgePeer `json:"peer"`
+}
+
+func (s *Server) hydrateEdges(r *http.Request, id string, edges []*db.Edge) []hydratedEdge {
+	peers := make(map[string]*edgePeer)
+	out := make([]hydratedEdge, 0, len(edges))
+	for _, e := range edges {
+		h := hydratedEdge{Edge: e, Direction: "out"}
+		peerID := e.ToID
+		if e.ToID == id && e.FromID != id {
+			h.Direction = "in"
+			peerID = e.FromID
+		}
+
+		peer, seen := peers[peerID]
+		if !seen {
+			if n, err := s.storeFor(r).GetNode(peerID); err == nil {
+				peer = &edgePeer{
+					ID:      n.ID,
+					Type:    n.Type,
+					Summary: n.Summary,
+					Preview: nodePreview(n),
+					Tags:    n.Tags,
+				}
+			}
+			peers[peerID] = peer
+		}
+		h.Peer = peer
+		out = append(out, h)
+	}
+	return out
+}
+
+// nodePreview returns the node's summary, or the first line of its content,
+// truncated to previewLength runes.
+func nodePreview(n *db.Node) string {
+	text := n.Content
+	if n.Summary != nil && *n.Summary != "" {
+		text = *n.Summary
+	}
+	if i := strings.IndexByte(text, '\n'); i >= 0 {
+		text = text[:i]
+	}
+	if r := []rune(text); len(r) > previewLength {
+		text = string(r[:previewLength]) + "…"
+	}
+	return text
+}
+
 type createEdgeRequest struct {
 	FromID string `json:"from_id"`
 	ToID   string `json:"to_id"`
@@ -249,19 +444,23 @@ func (s *Server) handleCreateEdge(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := s.storeFor(r).Types().EdgeType(req.Type); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	fromID, err := s.resolvePathID(req.FromID)
+	fromID, err := s.resolvePathID(r, req.FromID)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot resolve from_id: %v", err))
 		return
 	}
-	toID, err := s.resolvePathID(req.ToID)
+	toID, err := s.resolvePathID(r, req.ToID)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot resolve to_id: %v", err))
 		return
 	}
 
-	edge, err := s.store.CreateEdge(fromID, toID, req.Type)
+	edge, err := s.storeFor(r).CreateEdge(fromID, toID, req.Type)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
@@ -283,18 +482,18 @@ func (s *Server) handleDeleteEdge(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	fromID, err := s.resolvePathID(req.FromID)
+	fromID, err := s.resolvePathID(r, req.FromID)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot resolve from_id: %v", err))
 		return
 	}
-	toID, err := s.resolvePathID(req.ToID)
+	toID, err := s.resolvePathID(r, req.ToID)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("cannot resolve to_id: %v", err))
 		return
 	}
 
-	if err := s.store.DeleteEdge(fromID, toID, req.Type); err != nil {
+	if err := s.storeFor(r).DeleteEdge(fromID, toID, req.Type); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -309,7 +508,7 @@ type tagsRequest struct {
 }
 
 func (s *Server) handleAddTags(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -320,20 +519,22 @@ func (s *Server) handleAddTags(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := validate.Tags(req.Tags); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	for _, tag := range req.Tags {
-		if err := s.store.AddTag(id, tag); err != nil {
-			writeError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+	if err := s.storeFor(r).AddTags(id, req.Tags); err != nil {
+		writeTagError(w, err)
+		return
 	}
 
-	tags, _ := s.store.GetTags(id)
+	tags, _ := s.storeFor(r).GetTags(id)
 	writeJSON(w, http.StatusOK, map[string]any{"id": id, "tags": tags})
 }
 
 func (s *Server) handleRemoveTags(w http.ResponseWriter, r *http.Request) {
-	id, err := s.resolvePathID(r.PathValue("id"))
+	id, err := s.resolvePathID(r, r.PathValue("id"))
 	if err != nil {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
@@ -345,17 +546,29 @@ func (s *Server) handleRemoveTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	for _, tag := range req.Tags {
-		if err := s.store.RemoveTag(id, tag); err != nil {
-			writeError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
+	if err := s.storeFor(r).RemoveTags(id, req.Tags); err != nil {
+		writeTagError(w, err)
+		return
 	}
 
-	tags, _ := s.store.GetTags(id)
+	tags, _ := s.storeFor(r).GetTags(id)
 	writeJSON(w, http.StatusOK, map[string]any{"id": id, "tags": tags})
 }
 
+// writeTagError maps a batch tag failure to a status: a missing node is a
+// 404, an empty or malformed tag a 400, anything else a 500.
+func writeTagError(w http.ResponseWriter, err error) {
+	var verr *validate.Error
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, db.ErrEmptyTag), errors.As(err, &verr):
+		writeError(w, http.StatusBadRequest, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
 // --- Query ---
 
 type queryRequest struct {
@@ -370,9 +583,11 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	nodes, err := query.ExecuteQuery(s.store, req.Query, req.IncludeSuperseded)
+	ctx, cancel := query.WithTimeout(r.Context(), s.config.QueryTimeout)
+	defer cancel()
+	nodes, err := query.ExecuteQueryContext(ctx, s.storeFor(r), req.Query, req.IncludeSuperseded)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, queryErrorStatus(err), err.Error())
 		return
 	}
 
@@ -382,6 +597,46 @@ func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+type aggregateRequest struct {
+	Query             string `json:"query"`
+	By                string `json:"by"`
+	IncludeSuperseded bool   `json:"include_superseded"`
+}
+
+func (s *Server) handleQueryAggregate(w http.ResponseWriter, r *http.Request) {
+	var req aggregateRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.By == "" {
+		writeError(w, http.StatusBadRequest, "by is required")
+		return
+	}
+
+	ctx, cancel := query.WithTimeout(r.Context(), s.config.QueryTimeout)
+	defer cancel()
+	groups, err := query.Aggregate(ctx, s.storeFor(r), req.Query, req.By, req.IncludeSuperseded)
+	if err != nil {
+		writeError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"by":     req.By,
+		"groups": groups,
+	})
+}
+
+// queryErrorStatus maps a query or compose error to a status: 503 when it
+// ran past the query timeout, 400 otherwise.
+func queryErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusBadRequest
+}
+
 // --- Compose ---
 
 type composeRequest struct {
@@ -419,11 +674,19 @@ func (s *Server) handleCompose(w http.ResponseWriter, r *http.Request) {
 		IncludeEdges: req.Edges,
 	}
 
-	result, err := view.Compose(s.store, opts)
+	ctx, cancel := query.WithTimeout(r.Context(), s.config.QueryTimeout)
+	defer cancel()
+	result, err := view.ComposeContext(ctx, s.storeFor(r), opts)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		writeError(w, queryErrorStatus(err), err.Error())
 		return
 	}
+	_ = usage.RecordAccess(s.storeFor(r), result.Nodes...)
+	reads := make([]string, len(result.Nodes))
+	for i, n := range result.Nodes {
+		reads[i] = n.ID
+	}
+	noteResponse(r, reads, query.Mentions(req.Query, "accessed"))
 
 	if req.Template != "" {
 		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
@@ -450,8 +713,29 @@ func (s *Server) handleSyncPush(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check quota for the whole push up front, so a rejected push changes
+	// nothing and the client can retry it as-is after cleaning up. Updates
+	// count by how much they grow (or shrink) the nodes they overwrite.
+	var newNodes, newTokens int
+	for _, change := range req.Changes {
+		if change.Node == nil || change.Deleted {
+			continue
+		}
+		existing, err := s.storeFor(r).GetNode(change.Node.ID)
+		if err != nil {
+			newNodes++
+			newTokens += token.Estimate(change.Node.Content)
+		} else if !existing.UpdatedAt.After(change.Node.UpdatedAt) {
+			newTokens += token.Estimate(db.NormalizeContent(change.Node.Content)) - existing.TokenEstimate
+		}
+	}
+	if err := s.checkQuota(r, db.Usage{Nodes: newNodes, Tokens: newTokens}); err != nil {
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	deviceID, _ := s.requestDevice(r)
+
 	var accepted, conflicts int
-	var serverVersion int64
 
 	for _, change := range req.Changes {
 		if change.Node == nil {
@@ -459,16 +743,16 @@ func (s *Server) handleSyncPush(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if change.Deleted {
-			_ = s.store.DeleteNode(change.Node.ID)
+			_ = s.storeFor(r).DeleteNode(change.Node.ID)
 			accepted++
 			continue
 		}
 
 		// Check if node exists on server
-		existing, err := s.store.GetNode(change.Node.ID)
+		existing, err := s.storeFor(r).GetNode(change.Node.ID)
 		if err != nil {
 			// Node doesn't exist on server — create it
-			node, createErr := s.store.CreateNode(db.CreateNodeInput{
+			node, createErr := s.storeFor(r).CreateNode(db.CreateNodeInput{
 				Type:     change.Node.Type,
 				Content:  change.Node.Content,
 				Summary:  change.Node.Summary,
@@ -480,7 +764,10 @@ func (s *Server) handleSyncPush(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 			// Update sync_version on the newly created node
-			_, _ = s.store.Exec("UPDATE nodes SET sync_version = sync_version + 1 WHERE id = $1", node.ID)
+			_ = s.storeFor(r).BumpSyncVersion(node.ID)
+			if deviceID != "" {
+				_ = s.storeFor(r).SetOriginDevice(node.ID, deviceID)
+			}
 			accepted++
 			continue
 		}
@@ -493,17 +780,17 @@ func (s *Server) handleSyncPush(w http.ResponseWriter, r *http.Request) {
 
 		content := change.Node.Content
 		nodeType := change.Node.Type
-		_, _ = s.store.UpdateNode(change.Node.ID, db.UpdateNodeInput{
+		_, _ = s.storeFor(r).UpdateNode(change.Node.ID, db.UpdateNodeInput{
 			Content: &content,
 			Type:    &nodeType,
 			Summary: change.Node.Summary,
 		})
-		_, _ = s.store.Exec("UPDATE nodes SET sync_version = sync_version + 1 WHERE id = $1", change.Node.ID)
+		_ = s.storeFor(r).BumpSyncVersion(change.Node.ID)
 		accepted++
 	}
 
 	// Get current max sync version
-	_ = s.store.QueryRow("SELECT COALESCE(MAX(sync_version), 0) FROM nodes").Scan(&serverVersion)
+	serverVersion, _ := s.storeFor(r).MaxSyncVersion()
 
 	writeJSON(w, http.StatusOK, ctxsync.PushResponse{
 		Accepted:    accepted,
@@ -519,7 +806,7 @@ func (s *Server) handleSyncPull(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	changes, maxVersion, err := ctxsync.GetLocalChanges(s.store, req.SyncVersion)
+	changes, maxVersion, err := ctxsync.GetLocalChanges(s.storeFor(r), req.SyncVersion)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
@@ -541,14 +828,7 @@ func (s *Server) handleCreateRepoMapping(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	id := db.NewID()
-	now := fmt.Sprintf("%s", time.Now().UTC().Format(time.RFC3339))
-	_, err := s.store.Exec(
-		`INSERT INTO repo_mappings (id, normalized_url, project_tag, created_at) VALUES ($1, $2, $3, $4)
-		 ON CONFLICT (normalized_url) DO UPDATE SET project_tag = $3`,
-		id, req.NormalizedURL, req.ProjectTag, now,
-	)
-	if err != nil {
+	if _, err := s.storeFor(r).UpsertRepoMapping(req.NormalizedURL, req.ProjectTag); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -561,21 +841,43 @@ func (s *Server) handleCreateRepoMapping(w http.ResponseWriter, r *http.Request)
 
 // --- Helpers ---
 
-func (s *Server) resolvePathID(raw string) (string, error) {
+// storeFor returns the store bound to r's context, so a request's
+// statements stop when its client goes away.
+func (s *Server) storeFor(r *http.Request) db.Store {
+	return s.store.WithContext(r.Context())
+}
+
+func (s *Server) resolvePathID(r *http.Request, raw string) (string, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
 		return "", fmt.Errorf("missing id")
 	}
-	return s.store.ResolveID(raw)
+	if err := validate.IDPrefix(raw); err != nil {
+		return "", err
+	}
+	return s.storeFor(r).ResolveID(raw)
+}
+
+// baseURL is the scheme and host clients reached this server at, for
+// building links back to it.
+func (s *Server) baseURL(r *http.Request) string {
+	scheme := "http"
+	if s.config.HasTLS() {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
 }
 
+// errEmptyBody is returned by readJSON for a request without a body.
+var errEmptyBody = errors.New("empty request body")
+
 func readJSON(r *http.Request, v any) error {
 	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20)) // 1MB limit
 	if err != nil {
 		return fmt.Errorf("failed to read body: %w", err)
 	}
 	if len(body) == 0 {
-		return fmt.Errorf("empty request body")
+		return errEmptyBody
 	}
 	return json.Unmarshal(body, v)
 }
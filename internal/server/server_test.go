@@ -2,14 +2,20 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
 	"github.com/zate/ctx/testutil"
 )
 
@@ -55,6 +61,54 @@ func TestStatusEndpoint(t *testing.T) {
 	assert.Contains(t, resp, "total_tokens")
 }
 
+func TestStatsTopEndpoint(t *testing.T) {
+	srv, store := setupTestServer(t)
+	a, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "small"})
+	b, _ := store.CreateNode(db.CreateNodeInput{Type: "source", Content: "a much larger node body"})
+	_, _ = store.CreateEdge(a.ID, b.ID, "RELATES_TO")
+
+	w := doRequest(t, srv, "GET", "/api/stats/top?limit=1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		MostLinked []map[string]any `json:"most_linked"`
+		Largest    []map[string]any `json:"largest"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.MostLinked, 1)
+	require.Len(t, resp.Largest, 1)
+	assert.Equal(t, b.ID, resp.Largest[0]["id"])
+
+	w = doRequest(t, srv, "GET", "/api/stats/top?limit=zero", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIntegrityEndpoint(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "checked"})
+	_, err := store.Exec("UPDATE nodes SET metadata = 'not json' WHERE id = ?", n.ID)
+	require.NoError(t, err)
+
+	w := doRequest(t, srv, "GET", "/api/integrity", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Issues []map[string]any `json:"issues"`
+		Fixed  int              `json:"fixed"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Issues, 1)
+	assert.Equal(t, "invalid_metadata", resp.Issues[0]["kind"])
+
+	w = doRequest(t, srv, "POST", "/api/admin/integrity/fix", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 1, resp.Fixed)
+
+	w = doRequest(t, srv, "GET", "/api/integrity", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.Issues)
+}
+
 func TestNodeCRUD(t *testing.T) {
 	srv, _ := setupTestServer(t)
 
@@ -138,6 +192,34 @@ func TestEdges(t *testing.T) {
 	require.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestEdges_Hydrate(t *testing.T) {
+	srv, store := setupTestServer(t)
+
+	n1, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Node one"})
+	n2, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Node two\nsecond line", Tags: []string{"project:x"}})
+	_, err := store.CreateEdge(n1.ID, n2.ID, "RELATES_TO")
+	require.NoError(t, err)
+
+	w := doRequest(t, srv, "GET", "/api/edges/"+n1.ID+"?hydrate=true", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var edges []hydratedEdge
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &edges))
+	require.Len(t, edges, 1)
+	assert.Equal(t, "out", edges[0].Direction)
+	require.NotNil(t, edges[0].Peer)
+	assert.Equal(t, n2.ID, edges[0].Peer.ID)
+	assert.Equal(t, "decision", edges[0].Peer.Type)
+	assert.Equal(t, "Node two", edges[0].Peer.Preview)
+	assert.Equal(t, []string{"project:x"}, edges[0].Peer.Tags)
+
+	w = doRequest(t, srv, "GET", "/api/edges/"+n2.ID+"?hydrate=true", nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &edges))
+	require.Len(t, edges, 1)
+	assert.Equal(t, "in", edges[0].Direction)
+	assert.Equal(t, n1.ID, edges[0].Peer.ID)
+}
+
 func TestTags(t *testing.T) {
 	srv, store := setupTestServer(t)
 
@@ -166,6 +248,22 @@ func TestTags(t *testing.T) {
 	assert.Len(t, tags, 1)
 }
 
+func TestTags_EmptyTagIsAtomic(t *testing.T) {
+	srv, store := setupTestServer(t)
+
+	node, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Taggable node"})
+	require.NoError(t, err)
+
+	w := doRequest(t, srv, "POST", "/api/nodes/"+node.ID+"/tags", tagsRequest{
+		Tags: []string{"foo", "  ", "bar"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	tags, err := store.GetTags(node.ID)
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
 func TestQuery(t *testing.T) {
 	srv, store := setupTestServer(t)
 
@@ -193,6 +291,34 @@ func TestQuery(t *testing.T) {
 	assert.Equal(t, float64(1), resp["count"])
 }
 
+func TestQueryAggregate(t *testing.T) {
+	srv, store := setupTestServer(t)
+
+	for _, typ := range []string{"fact", "fact", "decision"} {
+		_, err := store.CreateNode(db.CreateNodeInput{Type: typ, Content: "A " + typ, Tags: []string{"project:ctx"}})
+		require.NoError(t, err)
+	}
+
+	w := doRequest(t, srv, "POST", "/api/query/aggregate", aggregateRequest{Query: "tag:project:ctx", By: "type"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		By     string        `json:"by"`
+		Groups []query.Group `json:"groups"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "type", resp.By)
+	require.Len(t, resp.Groups, 2)
+	assert.Equal(t, "fact", resp.Groups[0].Key)
+	assert.Equal(t, 2, resp.Groups[0].Count)
+
+	w = doRequest(t, srv, "POST", "/api/query/aggregate", aggregateRequest{Query: "type:fact"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(t, srv, "POST", "/api/query/aggregate", aggregateRequest{By: "content"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestCompose(t *testing.T) {
 	srv, store := setupTestServer(t)
 
@@ -288,6 +414,122 @@ func TestNodeBrowser(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Browsable fact")
 }
 
+func TestNodeBrowserRelativeTimes(t *testing.T) {
+	store := testutil.SetupTestDB(t)
+	cfg := DefaultConfig()
+	cfg.Timezone, cfg.RelativeTimes = "UTC", true
+	srv := New(store, cfg)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Recent fact"})
+
+	w := doRequest(t, srv, "GET", "/admin/nodes", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `<time datetime="`+n.CreatedAt.UTC().Format(time.RFC3339)+`"`)
+	assert.Contains(t, body, "UTC (just now)")
+	assert.Contains(t, body, ">just now</time>")
+}
+
+func TestAdminStaticAssets(t *testing.T) {
+	srv, _ := setupTestServer(t)
+
+	w := doRequest(t, srv, "GET", "/admin/static/style.css", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/css")
+	assert.Contains(t, w.Body.String(), "prefers-color-scheme: dark")
+
+	w = doRequest(t, srv, "GET", "/admin", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `href="/admin/static/style.css"`)
+	assert.Contains(t, w.Body.String(), "<title>ctx — Dashboard</title>")
+}
+
+func TestAdminLoginPageHasNoNav(t *testing.T) {
+	srv := New(testutil.SetupTestDB(t), Config{AdminPassword: "secret"})
+
+	w := doRequest(t, srv, "GET", "/admin/nodes", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "Enter admin password")
+	assert.NotContains(t, body, "<nav>")
+}
+
+func TestNodeDetail(t *testing.T) {
+	srv, store := setupTestServer(t)
+	a, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Detailed fact", Tags: []string{"tier:pinned"}})
+	b, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Related decision"})
+	_, _ = store.CreateEdge(a.ID, b.ID, "RELATES_TO")
+
+	w := doRequest(t, srv, "GET", "/admin/nodes/"+a.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "Detailed fact")
+	assert.Contains(t, body, "tier:pinned")
+	assert.Contains(t, body, "/admin/nodes/"+b.ID)
+
+	w = doRequest(t, srv, "GET", "/admin/nodes/NOSUCHNODE", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTopicsPage(t *testing.T) {
+	srv, store := setupTestServer(t)
+
+	w := doRequest(t, srv, "GET", "/admin/topics", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "No tagged nodes yet")
+
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Topical fact", Tags: []string{"billing"}})
+	w = doRequest(t, srv, "GET", "/admin/topics", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "billing")
+	assert.Contains(t, body, "/admin/nodes/"+n.ID)
+	assert.Contains(t, body, `href="/admin/topics"`)
+}
+
+func TestSuggest(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "# Billing\nBilling runs nightly", Tags: []string{"billing", "project:billing-api"}})
+	_, _ = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Unrelated", Tags: []string{"billing"}})
+
+	w := doRequest(t, srv, "GET", "/api/suggest?q=bill", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp suggestResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Nodes, 1)
+	assert.Equal(t, n.ID, resp.Nodes[0].ID)
+	assert.Equal(t, "Billing", resp.Nodes[0].Title)
+	require.Len(t, resp.Tags, 2)
+	assert.Equal(t, suggestTag{Tag: "billing", Count: 2}, resp.Tags[0])
+	assert.Equal(t, "project:billing-api", resp.Tags[1].Tag)
+
+	w = doRequest(t, srv, "GET", "/api/suggest?q=", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"nodes":[],"tags":[]}`, w.Body.String())
+
+	w = doRequest(t, srv, "GET", "/api/suggest?q=x&limit=0", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(t, srv, "GET", "/admin/suggest?q=bill", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandlersStopWithRequest(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Fetched only while the client waits"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/api/nodes/"+n.ID, nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), context.Canceled.Error())
+
+	w = doRequest(t, srv, "GET", "/api/nodes/"+n.ID, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestHealthEndpointResponse(t *testing.T) {
 	srv, _ := setupTestServer(t)
 	w := doRequest(t, srv, "GET", "/health", nil)
@@ -307,6 +549,22 @@ func TestCreateNodeValidation(t *testing.T) {
 		Content: "test",
 	})
 	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `invalid type \"invalid\": must be one of fact, decision`)
+
+	// Unknown tier and bad tag characters
+	w = doRequest(t, srv, "POST", "/api/nodes", createNodeRequest{
+		Type:    "fact",
+		Content: "test",
+		Tags:    []string{"tier:hot"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "tier must be one of pinned")
+	w = doRequest(t, srv, "POST", "/api/nodes", createNodeRequest{
+		Type:    "fact",
+		Content: "test",
+		Tags:    []string{"two words"},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Empty content
 	w = doRequest(t, srv, "POST", "/api/nodes", createNodeRequest{
@@ -321,3 +579,172 @@ func TestGetNodeNotFound(t *testing.T) {
 	w := doRequest(t, srv, "GET", "/api/nodes/nonexistent", nil)
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
+
+func postForm(t *testing.T, srv *Server, path string, form url.Values, htmx bool) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if htmx {
+		req.Header.Set("HX-Request", "true")
+	}
+	w := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(w, req)
+	return w
+}
+
+func TestNodeBrowserInlineTagActions(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Curated fact", Tags: []string{"old"}})
+
+	w := postForm(t, srv, "/admin/nodes/"+n.ID+"/tags", url.Values{"tag": {"project:ctx"}}, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<tr id="node-`+n.ID+`">`)
+	assert.Contains(t, w.Body.String(), "project:ctx")
+	assert.NotContains(t, w.Body.String(), "<html")
+
+	w = postForm(t, srv, "/admin/nodes/"+n.ID+"/untag", url.Values{"tag": {"old"}}, false)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+
+	tags, _ := store.GetTags(n.ID)
+	assert.ElementsMatch(t, []string{"project:ctx"}, tags)
+
+	w = postForm(t, srv, "/admin/nodes/"+n.ID+"/tags", url.Values{"tag": {" "}}, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCrossOriginPostsRefused(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Curated fact"})
+
+	post := func(path string, header ...string) int {
+		req := httptest.NewRequest("POST", path, strings.NewReader(url.Values{"tag": {"pwned"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w.Code
+	}
+
+	path := "/admin/nodes/" + n.ID + "/tags"
+	assert.Equal(t, http.StatusForbidden, post(path, "Origin", "https://evil.example"))
+	assert.Equal(t, http.StatusForbidden, post(path, "Referer", "https://evil.example/page"))
+	assert.Equal(t, http.StatusForbidden, post(path, "Origin", "null"))
+	assert.Equal(t, http.StatusForbidden, post("/api/nodes", "Origin", "https://evil.example"))
+	tags, _ := store.GetTags(n.ID)
+	assert.Empty(t, tags)
+
+	// The UI's own forms, and clients that are not browsers, still work
+	assert.Equal(t, http.StatusSeeOther, post(path, "Origin", "http://example.com"))
+	assert.Equal(t, http.StatusSeeOther, post(path, "Referer", "http://example.com/admin/nodes"))
+	assert.Equal(t, http.StatusSeeOther, post(path))
+}
+
+func TestLocalServerLocked(t *testing.T) {
+	store := testutil.SetupTestDB(t)
+	store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Private fact"})
+	cfg := DefaultConfig()
+	cfg.LocalToken = "run-token"
+	srv := New(store, cfg)
+
+	send := func(method, host, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(`{"type":"fact","content":"Injected"}`))
+		req.Host = host
+		req.Header.Set("Content-Type", "application/json")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		addr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 4321}
+		req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, addr))
+		w := httptest.NewRecorder()
+		srv.Handler().ServeHTTP(w, req)
+		return w
+	}
+
+	// A rebound domain resolves to the server but names its own host
+	assert.Equal(t, http.StatusForbidden, send("GET", "evil.example:4321", "/api/status?token=run-token").Code)
+	assert.Equal(t, http.StatusForbidden, send("POST", "evil.example:4321", "/api/nodes?token=run-token").Code)
+	assert.Equal(t, http.StatusForbidden, send("GET", "127.0.0.1:9999", "/api/status?token=run-token").Code)
+
+	// Loopback requests still need the run's token
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "127.0.0.1:4321", "/api/status").Code)
+	assert.Equal(t, http.StatusUnauthorized, send("POST", "localhost:4321", "/api/nodes?token=guess").Code)
+	nodes, _ := store.ListNodes(db.ListOptions{})
+	assert.Len(t, nodes, 1)
+
+	w := send("GET", "127.0.0.1:4321", "/api/status?token=run-token")
+	assert.Equal(t, http.StatusOK, w.Code)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, http.StatusOK, send("GET", "localhost:4321", "/api/status", cookies...).Code)
+	assert.Equal(t, http.StatusCreated, send("POST", "127.0.0.1:4321", "/api/nodes", cookies...).Code)
+}
+
+func TestNodeBrowserInlineTier(t *testing.T) {
+	srv, store := setupTestServer(t)
+	n, _ := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Tiered", Tags: []string{"tier:working", "keep"}})
+
+	w := postForm(t, srv, "/admin/nodes/"+n.ID+"/tier", url.Values{"tier": {"tier:pinned"}}, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `value="tier:pinned" selected`)
+
+	tags, _ := store.GetTags(n.ID)
+	assert.ElementsMatch(t, []string{"tier:pinned", "keep"}, tags)
+
+	w = postForm(t, srv, "/admin/nodes/"+n.ID+"/tier", url.Values{"tier": {"tier:bogus"}}, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestNodeBrowserInlineSupersede(t *testing.T) {
+	srv, store := setupTestServer(t)
+	old, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use REST"})
+	repl, _ := store.CreateNode(db.CreateNodeInput{Type: "decision", Content: "Use gRPC"})
+
+	w := postForm(t, srv, "/admin/nodes/"+old.ID+"/supersede", url.Values{"by": {old.ID}}, true)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postForm(t, srv, "/admin/nodes/"+old.ID+"/supersede", url.Values{"by": {repl.ID}}, true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	got, err := store.GetNode(old.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.SupersededBy)
+	assert.Equal(t, repl.ID, *got.SupersededBy)
+}
+
+func TestNodeBrowserFullTextSearch(t *testing.T) {
+	srv, store := setupTestServer(t)
+	_, _ = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "The deploy pipeline uses <b>blue-green</b> releases"})
+	_, _ = store.CreateNode(db.CreateNodeInput{Type: "fact", Content: "Unrelated note about lunch"})
+
+	w := doRequest(t, srv, "GET", "/admin/nodes?q=pipeline", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "<mark>pipeline</mark>")
+	assert.Contains(t, body, "&lt;b&gt;blue-green&lt;/b&gt;")
+	assert.NotContains(t, body, "lunch")
+
+	// Punctuation is passed through literally rather than as FTS syntax
+	w = doRequest(t, srv, "GET", "/admin/nodes?q=blue-green", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "Full-text search failed")
+	assert.Contains(t, w.Body.String(), "<mark>blue-green</mark>")
+
+	// Exact substring toggle uses LIKE matching
+	w = doRequest(t, srv, "GET", "/admin/nodes?q=pipel&exact=1", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "deploy pipeline")
+}
+
+func TestSnippet(t *testing.T) {
+	content := strings.Repeat("padding ", 30) + "the Needle is here " + strings.Repeat("tail ", 30)
+	got := string(snippet(content, []string{"needle"}))
+	assert.True(t, strings.HasPrefix(got, "…"))
+	assert.True(t, strings.HasSuffix(got, "…"))
+	assert.Contains(t, got, "<mark>Needle</mark>")
+
+	assert.Equal(t, "short &amp; sweet", string(snippet("short & sweet", []string{"absent"})))
+	assert.Equal(t, `"a" "b-c"`, ftsQuery(`a "b-c"`))
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/query"
+)
+
+const (
+	// defaultSuggestLimit and maxSuggestLimit bound the nodes and the tags
+	// each suggest response lists.
+	defaultSuggestLimit = 8
+	maxSuggestLimit     = 50
+
+	// suggestTimeout caps a suggest request: a late suggestion is useless
+	// to someone still typing, so it gives up well before the query timeout.
+	suggestTimeout = 500 * time.Millisecond
+
+	// suggestTitleLen is the length a node's first line is cut to.
+	suggestTitleLen = 80
+)
+
+type suggestNode struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+}
+
+type suggestTag struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type suggestResponse struct {
+	Nodes []suggestNode `json:"nodes"`
+	Tags  []suggestTag  `json:"tags"`
+}
+
+// handleSuggest serves search-as-you-type: GET /api/suggest?q=...&limit=N
+// returns the best full-text matches (the last word matched as a prefix)
+// and the most-used tags starting with, or namespaced to, the last word.
+func (s *Server) handleSuggest(w http.ResponseWriter, r *http.Request) {
+	limit := defaultSuggestLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxSuggestLimit)
+	}
+
+	resp := suggestResponse{Nodes: []suggestNode{}, Tags: []suggestTag{}}
+	terms := strings.Fields(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	timeout := suggestTimeout
+	if s.config.QueryTimeout > 0 {
+		timeout = min(timeout, s.config.QueryTimeout)
+	}
+	ctx, cancel := query.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	nodes, err := s.storeFor(r).WithContext(ctx).SearchPrefix(terms, limit)
+	if err != nil {
+		writeError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	for _, n := range nodes {
+		sn := suggestNode{ID: n.ID, Type: n.Type, Title: suggestTitle(n.Content)}
+		if n.Summary != nil {
+			sn.Summary = *n.Summary
+		}
+		resp.Nodes = append(resp.Nodes, sn)
+	}
+
+	tags, err := s.suggestTags(ctx, terms[len(terms)-1], limit)
+	if err != nil {
+		writeError(w, queryErrorStatus(err), err.Error())
+		return
+	}
+	resp.Tags = tags
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// suggestTags returns the most-used tags that start with prefix or whose
+// value after a namespace does ("auth" finds project:authz).
+func (s *Server) suggestTags(ctx context.Context, prefix string, limit int) ([]suggestTag, error) {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(prefix))
+	rows, err := s.store.WithContext(ctx).Query(`SELECT tag, COUNT(*) AS uses FROM tags
+		WHERE LOWER(tag) LIKE ? ESCAPE '\' OR LOWER(tag) LIKE ? ESCAPE '\'
+		GROUP BY tag ORDER BY uses DESC, tag LIMIT ?`,
+		escaped+"%", "%:"+escaped+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []suggestTag{}
+	for rows.Next() {
+		var t suggestTag
+		if err := rows.Scan(&t.Tag, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// suggestTitle is a node's first non-empty line, shortened.
+func suggestTitle(content string) string {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > suggestTitleLen {
+			line = string(r[:suggestTitleLen]) + "…"
+		}
+		return line
+	}
+	return ""
+}
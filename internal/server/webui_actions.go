@@ -0,0 +1,175 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/approval"
+	"github.com/zate/ctx/internal/timefmt"
+	"github.com/zate/ctx/internal/validate"
+)
+
+// Inline node-browser actions. Each handler mutates the node through the
+// store, then answers an htmx request with the re-rendered row (or nothing,
+// once the row no longer belongs in the browser) and a plain form post with
+// a redirect back to the page it came from.
+//
+// These live under /admin rather than reusing /api because /api requires a
+// device bearer token, while the browser only holds an admin session.
+
+// tierNames are the tiers offered by the node browser's tier picker.
+var tierNames = []string{"tier:pinned", "tier:reference", "tier:working", "tier:off-context"}
+
+// nodeRow is one row of the node browser.
+type nodeRow struct {
+	ID        string
+	Type      string
+	Content   string
+	Snippet   template.HTML // highlighted excerpt for full-text results
+	Tokens    int
+	CreatedAt time.Time
+	Tags      []string
+	Clock     *timefmt.Clock
+}
+
+// currentTier returns the first tier tag in tags, or "".
+func currentTier(tags []string) string {
+	for _, t := range tags {
+		if strings.HasPrefix(t, "tier:") {
+			return t
+		}
+	}
+	return ""
+}
+
+func (s *Server) handleUITagAdd(w http.ResponseWriter, r *http.Request) {
+	s.uiNodeAction(w, r, func(id string) (bool, error) {
+		tag := strings.TrimSpace(r.FormValue("tag"))
+		if tag == "" {
+			return false, errBadRequest("tag is required")
+		}
+		if err := validate.Tag(tag); err != nil {
+			return false, errBadRequest(err.Error())
+		}
+		return true, s.storeFor(r).AddTags(id, []string{tag})
+	})
+}
+
+func (s *Server) handleUITagRemove(w http.ResponseWriter, r *http.Request) {
+	s.uiNodeAction(w, r, func(id string) (bool, error) {
+		return true, s.storeFor(r).RemoveTags(id, []string{r.FormValue("tag")})
+	})
+}
+
+func (s *Server) handleUITier(w http.ResponseWriter, r *http.Request) {
+	s.uiNodeAction(w, r, func(id string) (bool, error) {
+		tier := r.FormValue("tier")
+		valid := tier == ""
+		for _, t := range tierNames {
+			valid = valid || t == tier
+		}
+		if !valid {
+			return false, errBadRequest("unknown tier " + tier)
+		}
+
+		tags, err := s.storeFor(r).GetTags(id)
+		if err != nil {
+			return false, err
+		}
+		var remove, add []string
+		for _, t := range tags {
+			if strings.HasPrefix(t, "tier:") && t != tier {
+				remove = append(remove, t)
+			}
+		}
+		if tier != "" {
+			add = []string{tier}
+		}
+		return true, s.storeFor(r).UpdateTags(id, add, remove)
+	})
+}
+
+func (s *Server) handleUISupersede(w http.ResponseWriter, r *http.Request) {
+	s.uiNodeAction(w, r, func(id string) (bool, error) {
+		by, err := s.resolvePathID(r, r.FormValue("by"))
+		if err != nil {
+			return false, errBadRequest(err.Error())
+		}
+		if by == id {
+			return false, errBadRequest("a node cannot supersede itself")
+		}
+		_, err = approval.Apply(s.storeFor(r), approval.OpSupersede, map[string]string{"old": id, "new": by})
+		// Superseded nodes are hidden from the browser, so drop the row
+		return false, err
+	})
+}
+
+type errBadRequest string
+
+func (e errBadRequest) Error() string { return string(e) }
+
+// uiNodeAction resolves the node in the path, runs action on it and writes
+// the response. action reports whether the node's row should be re-rendered.
+func (s *Server) uiNodeAction(w http.ResponseWriter, r *http.Request, action func(id string) (bool, error)) {
+	id, err := s.resolvePathID(r, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keep, err := action(id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if _, ok := err.(errBadRequest); ok {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "" {
+		back := r.Referer()
+		if back == "" {
+			back = "/admin/nodes"
+		}
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !keep {
+		return
+	}
+	row, err := s.loadNodeRow(r, id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = nodesBrowserTmpl.ExecuteFragment(w, "row", row)
+}
+
+func (s *Server) loadNodeRow(r *http.Request, id string) (nodeRow, error) {
+	n, err := s.storeFor(r).GetNode(id)
+	if err != nil {
+		return nodeRow{}, err
+	}
+	content := n.Content
+	if r := []rune(content); len(r) > 200 {
+		content = string(r[:200])
+	}
+	return nodeRow{
+		ID:        n.ID,
+		Type:      n.Type,
+		Content:   content,
+		Tokens:    n.TokenEstimate,
+		CreatedAt: n.CreatedAt,
+		Tags:      n.Tags,
+		Clock:     s.clock,
+	}, nil
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// searchLimit caps the number of full-text results shown in the browser.
+const searchLimit = 50
+
+// snippetContext is how much text is kept on each side of the first match.
+const snippetContext = 80
+
+// searchNodeRows runs a full-text search through the store (FTS5 on SQLite,
+// tsvector on PostgreSQL) and returns active nodes in rank order, each with
+// a highlighted snippet around the first match.
+func (s *Server) searchNodeRows(r *http.Request, search, typeFilter string) ([]nodeRow, error) {
+	results, err := s.storeFor(r).Search(ftsQuery(search))
+	if err != nil {
+		return nil, err
+	}
+
+	terms := searchTerms(search)
+	var rows []nodeRow
+	for _, n := range results {
+		if n.SupersededBy != nil || (typeFilter != "" && n.Type != typeFilter) {
+			continue
+		}
+		rows = append(rows, nodeRow{
+			ID:        n.ID,
+			Type:      n.Type,
+			Snippet:   snippet(n.Content, terms),
+			Tokens:    n.TokenEstimate,
+			CreatedAt: n.CreatedAt,
+			Tags:      n.Tags,
+			Clock:     s.clock,
+		})
+		if len(rows) == searchLimit {
+			break
+		}
+	}
+	return rows, nil
+}
+
+// searchTerms splits a search box entry into bare terms.
+func searchTerms(search string) []string {
+	var terms []string
+	for _, t := range strings.Fields(search) {
+		if t = strings.Trim(t, `"*()`); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// ftsQuery turns free text into a query both backends accept: each term is
+// quoted so FTS5 treats punctuation literally (implicit AND), and
+// plainto_tsquery ignores the quotes.
+func ftsQuery(search string) string {
+	terms := searchTerms(search)
+	for i, t := range terms {
+		terms[i] = `"` + strings.ReplaceAll(t, `"`, "") + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// snippet returns an HTML-escaped excerpt of content around the first
+// occurrence of any term, with every occurrence wrapped in <mark>. Content
+// with no literal match (e.g. a stemmed hit) yields its opening excerpt.
+func snippet(content string, terms []string) template.HTML {
+	quoted := make([]string, 0, len(terms))
+	for _, t := range terms {
+		quoted = append(quoted, regexp.QuoteMeta(t))
+	}
+
+	start := 0
+	var re *regexp.Regexp
+	if len(quoted) > 0 {
+		re = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+		if loc := re.FindStringIndex(content); loc != nil {
+			start = max(0, loc[0]-snippetContext)
+		}
+	}
+	end := min(len(content), start+2*snippetContext+40)
+	start, end = runeBoundary(content, start), runeBoundary(content, end)
+	excerpt := content[start:end]
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	last := 0
+	if re != nil {
+		for _, loc := range re.FindAllStringIndex(excerpt, -1) {
+			b.WriteString(template.HTMLEscapeString(excerpt[last:loc[0]]))
+			b.WriteString("<mark>" + template.HTMLEscapeString(excerpt[loc[0]:loc[1]]) + "</mark>")
+			last = loc[1]
+		}
+	}
+	b.WriteString(template.HTMLEscapeString(excerpt[last:]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return template.HTML(b.String())
+}
+
+// runeBoundary moves i back to the start of the UTF-8 sequence containing it.
+func runeBoundary(s string, i int) int {
+	for i > 0 && i < len(s) && s[i]&0xC0 == 0x80 {
+		i--
+	}
+	return i
+}
@@ -0,0 +1,181 @@
+// Package snapshot saves and restores whole copies of a SQLite store, so
+// aggressive cleanup or consolidation can be tried and rolled back.
+// Snapshots live in a snapshots directory next to the database file.
+package snapshot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// PreRestore is the snapshot Restore takes of the current state before
+// replacing it, so a restore can itself be undone.
+const PreRestore = "pre-restore"
+
+var nameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Info describes a saved snapshot.
+type Info struct {
+	Name      string    `json:"name"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Dir returns the snapshot directory for the database at dbPath.
+func Dir(dbPath string) string {
+	return filepath.Join(filepath.Dir(dbPath), "snapshots")
+}
+
+func snapshotPath(dbPath, name string) (string, error) {
+	if !nameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return filepath.Join(Dir(dbPath), name+".db"), nil
+}
+
+// Create saves a consistent copy of the database at dbPath as name, using
+// VACUUM INTO so it is safe while other processes have the store open. An
+// existing snapshot of that name is replaced only with overwrite.
+func Create(dbPath, name string, overwrite bool) (*Info, error) {
+	path, err := snapshotPath(dbPath, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("no database at %s: %w", dbPath, err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		if !overwrite {
+			return nil, fmt.Errorf("snapshot %q already exists", name)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(Dir(dbPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	d, err := db.Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer d.Close()
+	if _, err := d.Exec("VACUUM INTO ?", path); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return stat(name, path)
+}
+
+// Restore replaces the database at dbPath with snapshot name, first saving
+// the current state as PreRestore. Other processes using the store should
+// be stopped first; they would keep writing to the replaced file.
+func Restore(dbPath, name string) error {
+	path, err := snapshotPath(dbPath, name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("no snapshot named %q", name)
+	}
+
+	// Stage the copy first: restoring PreRestore must read it before the
+	// current state overwrites it
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), ".restore-*.db")
+	if err != nil {
+		return fmt.Errorf("failed to stage snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := copyFile(tmp, path); err != nil {
+		return fmt.Errorf("failed to stage snapshot: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if _, err := Create(dbPath, PreRestore, true); err != nil {
+			return fmt.Errorf("failed to save the current state: %w", err)
+		}
+	}
+
+	// A leftover write-ahead log would be replayed onto the restored file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(tmp.Name(), dbPath); err != nil {
+		return fmt.Errorf("failed to replace database: %w", err)
+	}
+	return nil
+}
+
+func copyFile(dst *os.File, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, err := io.Copy(dst, in); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// List returns the snapshots for the database at dbPath, newest first.
+func List(dbPath string) ([]Info, error) {
+	entries, err := os.ReadDir(Dir(dbPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []Info
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".db")
+		if !ok || e.IsDir() || !nameRe.MatchString(name) {
+			continue
+		}
+		info, err := stat(name, filepath.Join(Dir(dbPath), e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+// Delete removes snapshot name.
+func Delete(dbPath, name string) error {
+	path, err := snapshotPath(dbPath, name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("no snapshot named %q", name)
+		}
+		return err
+	}
+	return nil
+}
+
+func stat(name, path string) (*Info, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{Name: name, Path: path, Size: fi.Size(), CreatedAt: fi.ModTime()}, nil
+}
@@ -0,0 +1,72 @@
+package snapshot_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/snapshot"
+)
+
+func countNodes(t *testing.T, path string) int {
+	t.Helper()
+	d, err := db.Open(path)
+	require.NoError(t, err)
+	defer d.Close()
+	nodes, err := d.ListNodes(db.ListOptions{})
+	require.NoError(t, err)
+	return len(nodes)
+}
+
+func addNode(t *testing.T, path, content string) {
+	t.Helper()
+	d, err := db.Open(path)
+	require.NoError(t, err)
+	defer d.Close()
+	_, err = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: content})
+	require.NoError(t, err)
+}
+
+func TestCreateAndRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	addNode(t, path, "kept")
+
+	info, err := snapshot.Create(path, "before-cleanup", false)
+	require.NoError(t, err)
+	assert.Equal(t, "before-cleanup", info.Name)
+	assert.Positive(t, info.Size)
+
+	_, err = snapshot.Create(path, "before-cleanup", false)
+	assert.Error(t, err, "existing snapshots are not overwritten by default")
+
+	addNode(t, path, "experiment")
+	require.Equal(t, 2, countNodes(t, path))
+
+	require.NoError(t, snapshot.Restore(path, "before-cleanup"))
+	assert.Equal(t, 1, countNodes(t, path))
+
+	// The state before the restore was saved and can be restored in turn
+	require.NoError(t, snapshot.Restore(path, snapshot.PreRestore))
+	assert.Equal(t, 2, countNodes(t, path))
+
+	list, err := snapshot.List(path)
+	require.NoError(t, err)
+	var names []string
+	for _, s := range list {
+		names = append(names, s.Name)
+	}
+	assert.ElementsMatch(t, []string{"before-cleanup", snapshot.PreRestore}, names)
+
+	require.NoError(t, snapshot.Delete(path, "before-cleanup"))
+	assert.Error(t, snapshot.Delete(path, "before-cleanup"))
+	assert.Error(t, snapshot.Restore(path, "before-cleanup"))
+}
+
+func TestInvalidName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	addNode(t, path, "x")
+	_, err := snapshot.Create(path, "../escape", false)
+	assert.Error(t, err)
+}
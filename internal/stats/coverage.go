@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+)
+
+// CoverageTypes are the node types that count as captured knowledge.
+var CoverageTypes = []string{"decision", "pattern", "fact"}
+
+// CoverageOptions controls Coverage.
+type CoverageOptions struct {
+	Project string   // only nodes tagged project:<Project>; all nodes when empty
+	Areas   []string // areas to report even when no node mentions them
+	Agent   string   // agent scope, as for other commands
+}
+
+// AreaCoverage counts the knowledge captured for one area.
+type AreaCoverage struct {
+	Area string `json:"area"`
+	// Counts holds the number of nodes per CoverageTypes entry.
+	Counts map[string]int `json:"counts"`
+	// Other counts nodes of any other type (observations, sources, ...)
+	// tagged with the area.
+	Other       int        `json:"other"`
+	LastUpdated *time.Time `json:"last_updated,omitempty"`
+}
+
+// Total returns the number of knowledge nodes in the area.
+func (a AreaCoverage) Total() int {
+	n := 0
+	for _, c := range a.Counts {
+		n += c
+	}
+	return n
+}
+
+// CoverageReport summarizes knowledge per area for a project.
+type CoverageReport struct {
+	Project string         `json:"project,omitempty"`
+	Areas   []AreaCoverage `json:"areas"`
+	// Empty lists the areas with no decisions, patterns or facts.
+	Empty []string `json:"empty"`
+}
+
+// Coverage counts the decisions, patterns and facts per area. Areas come
+// from tags: a plain tag ("auth") or an area: tag ("area:auth") names an
+// area; namespaced tags (tier:, project:, agent:, ...) and ctx's own
+// markers don't. Areas only mentioned by other node types, or listed in
+// opts.Areas, show up with zero knowledge so gaps are visible.
+func Coverage(d db.Store, opts CoverageOptions) (*CoverageReport, error) {
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+
+	knowledge := make(map[string]bool, len(CoverageTypes))
+	for _, t := range CoverageTypes {
+		knowledge[t] = true
+	}
+
+	areas := make(map[string]*AreaCoverage)
+	area := func(name string) *AreaCoverage {
+		a := areas[name]
+		if a == nil {
+			a = &AreaCoverage{Area: name, Counts: make(map[string]int, len(CoverageTypes))}
+			for _, t := range CoverageTypes {
+				a.Counts[t] = 0
+			}
+			areas[name] = a
+		}
+		return a
+	}
+	for _, name := range opts.Areas {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			area(name)
+		}
+	}
+
+	for _, n := range nodes {
+		if opts.Project != "" && !inProject(n.Tags, opts.Project) {
+			continue
+		}
+		for _, name := range nodeAreas(n.Tags) {
+			a := area(name)
+			if !knowledge[n.Type] {
+				a.Other++
+				continue
+			}
+			a.Counts[n.Type]++
+			if a.LastUpdated == nil || n.UpdatedAt.After(*a.LastUpdated) {
+				updated := n.UpdatedAt
+				a.LastUpdated = &updated
+			}
+		}
+	}
+
+	report := &CoverageReport{Project: opts.Project, Areas: []AreaCoverage{}, Empty: []string{}}
+	for _, a := range areas {
+		report.Areas = append(report.Areas, *a)
+		if a.Total() == 0 {
+			report.Empty = append(report.Empty, a.Area)
+		}
+	}
+	sort.Slice(report.Areas, func(i, j int) bool {
+		ti, tj := report.Areas[i].Total(), report.Areas[j].Total()
+		if ti != tj {
+			return ti > tj
+		}
+		return report.Areas[i].Area < report.Areas[j].Area
+	})
+	sort.Strings(report.Empty)
+	return report, nil
+}
+
+// nodeAreas returns the areas named by a node's tags, lowercased and
+// without duplicates.
+func nodeAreas(tags []string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		name := tag
+		if rest, ok := strings.CutPrefix(tag, "area:"); ok {
+			name = rest
+		} else if strings.Contains(tag, ":") || tag == hookpkg.RateLimitedTag {
+			continue
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+func inProject(tags []string, project string) bool {
+	for _, tag := range tags {
+		if p, ok := strings.CutPrefix(tag, "project:"); ok && strings.EqualFold(p, project) {
+			return true
+		}
+	}
+	return false
+}
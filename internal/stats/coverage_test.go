@@ -0,0 +1,36 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/stats"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestCoverage(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use JWT", Tags: []string{"project:app", "auth", "tier:reference"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "tokens expire hourly", Tags: []string{"project:app", "area:Auth"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "observation", Content: "sync was slow", Tags: []string{"project:app", "sync"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "pattern", Content: "other project", Tags: []string{"project:other", "auth"}})
+
+	report, err := stats.Coverage(d, stats.CoverageOptions{Project: "app", Areas: []string{"storage"}})
+	require.NoError(t, err)
+
+	require.Len(t, report.Areas, 3)
+	auth := report.Areas[0]
+	assert.Equal(t, "auth", auth.Area)
+	assert.Equal(t, 1, auth.Counts["decision"])
+	assert.Equal(t, 1, auth.Counts["fact"])
+	assert.Equal(t, 0, auth.Counts["pattern"])
+	require.NotNil(t, auth.LastUpdated)
+
+	assert.Equal(t, []string{"storage", "sync"}, report.Empty)
+	for _, a := range report.Areas[1:] {
+		assert.Nil(t, a.LastUpdated)
+	}
+}
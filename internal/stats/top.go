@@ -0,0 +1,156 @@
+// Package stats computes summary statistics over the knowledge graph.
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+)
+
+// DefaultTopLimit is the number of nodes listed per category by Top.
+const DefaultTopLimit = 10
+
+// TopOptions controls Top.
+type TopOptions struct {
+	Limit     int    // entries per category; DefaultTopLimit when <= 0
+	Agent     string // agent scope, as for other commands
+	AllAgents bool   // ignore agent scoping (server-side view)
+}
+
+// TopEntry is one node in a Top category.
+type TopEntry struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Preview   string    `json:"preview"`
+	Tokens    int       `json:"tokens"`
+	Links     int       `json:"links"`
+	Accesses  int       `json:"accesses"`
+	CreatedAt time.Time `json:"created_at"`
+	Age       string    `json:"age"`
+}
+
+// TopReport lists memory hotspots and bloat.
+type TopReport struct {
+	MostAccessed []TopEntry `json:"most_accessed"`
+	MostLinked   []TopEntry `json:"most_linked"`
+	Largest      []TopEntry `json:"largest"`
+	// MissedRecalls are recall queries that found nothing, most-missed
+	// first: knowledge the agent keeps looking for but never finds.
+	MissedRecalls []*db.MissedRecall `json:"missed_recalls"`
+}
+
+// Top returns the most-accessed, most-linked and largest active nodes, and
+// the most often missed recall queries. Accesses count the sessions in
+// which a node was referenced or recalled.
+func Top(d db.Store, opts TopOptions) (*TopReport, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultTopLimit
+	}
+
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !opts.AllAgents {
+		nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+	}
+
+	links, err := linkCounts(d)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := d.GetNodeUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]TopEntry, len(nodes))
+	for i, n := range nodes {
+		e := TopEntry{
+			ID:        n.ID,
+			Type:      n.Type,
+			Preview:   db.Preview(n.Content, 60),
+			Tokens:    n.TokenEstimate,
+			Links:     links[n.ID],
+			CreatedAt: n.CreatedAt,
+			Age:       FormatAge(now.Sub(n.CreatedAt)),
+		}
+		if u := usage[n.ID]; u != nil {
+			e.Accesses = u.Referenced + u.Recalled
+		}
+		entries[i] = e
+	}
+
+	missed, err := d.ListMissedRecalls(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TopReport{
+		MostAccessed:  topBy(entries, limit, func(e TopEntry) int { return e.Accesses }),
+		MostLinked:    topBy(entries, limit, func(e TopEntry) int { return e.Links }),
+		Largest:       topBy(entries, limit, func(e TopEntry) int { return e.Tokens }),
+		MissedRecalls: missed,
+	}, nil
+}
+
+// topBy returns up to limit entries with the highest non-zero key, ties
+// broken by ID for stable output.
+func topBy(entries []TopEntry, limit int, key func(TopEntry) int) []TopEntry {
+	var out []TopEntry
+	for _, e := range entries {
+		if key(e) > 0 {
+			out = append(out, e)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ki, kj := key(out[i]), key(out[j])
+		if ki != kj {
+			return ki > kj
+		}
+		return out[i].ID < out[j].ID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// linkCounts returns the number of edges (in either direction) per node.
+func linkCounts(d db.Store) (map[string]int, error) {
+	rows, err := d.Query("SELECT from_id, to_id FROM edges")
+	if err != nil {
+		return nil, fmt.Errorf("failed to count edges: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var from, to string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, err
+		}
+		counts[from]++
+		counts[to]++
+	}
+	return counts, rows.Err()
+}
+
+// FormatAge renders a duration compactly: "45m", "6h", "12d", "8w".
+func FormatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 14*24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	default:
+		return fmt.Sprintf("%dw", int(d.Hours()/(24*7)))
+	}
+}
@@ -0,0 +1,51 @@
+package stats_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/stats"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestTop(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	hub, _ := d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "hub"})
+	big, _ := d.CreateNode(db.CreateNodeInput{Type: "source", Content: strings.Repeat("é", 2000)})
+	leaf, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "leaf"})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "hidden", Tags: []string{"agent:other"}})
+	_, _ = d.CreateEdge(leaf.ID, hub.ID, "RELATES_TO")
+	_, _ = d.CreateEdge(big.ID, hub.ID, "RELATES_TO")
+	require.NoError(t, d.RecordNodeUsage(db.UsageRecalled, []string{leaf.ID}))
+
+	report, err := stats.Top(d, stats.TopOptions{Limit: 2})
+	require.NoError(t, err)
+
+	require.Len(t, report.MostLinked, 2)
+	assert.Equal(t, hub.ID, report.MostLinked[0].ID)
+	assert.Equal(t, 2, report.MostLinked[0].Links)
+
+	require.NotEmpty(t, report.Largest)
+	assert.Equal(t, big.ID, report.Largest[0].ID)
+	assert.Equal(t, 1000, report.Largest[0].Tokens)
+	assert.Equal(t, strings.Repeat("é", 60)+"...", report.Largest[0].Preview, "cut at 60 characters, not bytes")
+
+	require.Len(t, report.MostAccessed, 1)
+	assert.Equal(t, leaf.ID, report.MostAccessed[0].ID)
+
+	for _, e := range report.Largest {
+		assert.NotEqual(t, "hidden", e.Preview)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	assert.Equal(t, "5m", stats.FormatAge(5*time.Minute))
+	assert.Equal(t, "3h", stats.FormatAge(3*time.Hour))
+	assert.Equal(t, "2d", stats.FormatAge(50*time.Hour))
+	assert.Equal(t, "4w", stats.FormatAge(30*24*time.Hour))
+}
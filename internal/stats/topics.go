@@ -0,0 +1,190 @@
+package stats
+
+import (
+	"sort"
+	"strings"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+)
+
+// DefaultTopicSimilarity is the tag co-occurrence (Jaccard index) at which
+// two topic tags are grouped into one topic.
+const DefaultTopicSimilarity = 0.3
+
+// topicExamples is how many representative nodes a Topic lists.
+const topicExamples = 3
+
+// TopicsOptions controls Topics.
+type TopicsOptions struct {
+	Similarity float64 // DefaultTopicSimilarity when <= 0
+	Agent      string  // agent scope, as for other commands
+	AllAgents  bool    // ignore agent scoping (server-side view)
+}
+
+// Topic is a cluster of topic tags that tend to appear together, with the
+// nodes carrying any of them.
+type Topic struct {
+	Tags     []string       `json:"tags"` // most common first
+	Nodes    int            `json:"nodes"`
+	Tokens   int            `json:"tokens"`
+	ByType   map[string]int `json:"by_type"`
+	Examples []TopicExample `json:"examples"`
+}
+
+// TopicExample is a representative node of a Topic.
+type TopicExample struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Preview string `json:"preview"`
+}
+
+// TopicsReport gives a bird's-eye view of what the store contains.
+type TopicsReport struct {
+	Topics []Topic `json:"topics"` // largest first
+	// Untagged counts active nodes with no topic tag at all.
+	Untagged int `json:"untagged"`
+}
+
+// Topics groups active nodes into topics by tag co-occurrence. Topic tags
+// are the same as coverage areas (see Coverage). Two tags join one topic
+// when the nodes carrying both make up at least opts.Similarity of the
+// nodes carrying either; grouping is transitive. Each topic lists summary
+// nodes first as its examples, then the nodes matching most of its tags.
+func Topics(d db.Store, opts TopicsOptions) (*TopicsReport, error) {
+	similarity := opts.Similarity
+	if similarity <= 0 {
+		similarity = DefaultTopicSimilarity
+	}
+
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if !opts.AllAgents {
+		nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+	}
+
+	report := &TopicsReport{Topics: []Topic{}}
+	nodeTags := make([][]string, len(nodes))
+	tagCount := make(map[string]int)
+	pairCount := make(map[[2]string]int)
+	for i, n := range nodes {
+		tags := nodeAreas(n.Tags)
+		sort.Strings(tags)
+		nodeTags[i] = tags
+		if len(tags) == 0 {
+			report.Untagged++
+		}
+		for a, tag := range tags {
+			tagCount[tag]++
+			for _, other := range tags[a+1:] {
+				pairCount[[2]string{tag, other}]++
+			}
+		}
+	}
+
+	parent := make(map[string]string, len(tagCount))
+	for tag := range tagCount {
+		parent[tag] = tag
+	}
+	var find func(string) string
+	find = func(t string) string {
+		if parent[t] != t {
+			parent[t] = find(parent[t])
+		}
+		return parent[t]
+	}
+	for pair, both := range pairCount {
+		either := tagCount[pair[0]] + tagCount[pair[1]] - both
+		if float64(both)/float64(either) >= similarity {
+			parent[find(pair[0])] = find(pair[1])
+		}
+	}
+
+	topics := make(map[string]*Topic)
+	members := make(map[string][]int) // topic root -> node indexes
+	for tag := range tagCount {
+		root := find(tag)
+		if topics[root] == nil {
+			topics[root] = &Topic{ByType: map[string]int{}}
+		}
+		topics[root].Tags = append(topics[root].Tags, tag)
+	}
+	for i, tags := range nodeTags {
+		seen := make(map[string]bool)
+		for _, tag := range tags {
+			root := find(tag)
+			if seen[root] {
+				continue
+			}
+			seen[root] = true
+			t := topics[root]
+			t.Nodes++
+			t.Tokens += nodes[i].TokenEstimate
+			t.ByType[nodes[i].Type]++
+			members[root] = append(members[root], i)
+		}
+	}
+
+	for root, t := range topics {
+		sort.Slice(t.Tags, func(i, j int) bool {
+			ci, cj := tagCount[t.Tags[i]], tagCount[t.Tags[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return t.Tags[i] < t.Tags[j]
+		})
+		t.Examples = topicExamplesFor(t, members[root], nodes, nodeTags)
+		report.Topics = append(report.Topics, *t)
+	}
+	sort.Slice(report.Topics, func(i, j int) bool {
+		a, b := report.Topics[i], report.Topics[j]
+		if a.Nodes != b.Nodes {
+			return a.Nodes > b.Nodes
+		}
+		return a.Tags[0] < b.Tags[0]
+	})
+	return report, nil
+}
+
+// topicExamplesFor picks a topic's representative nodes: summaries first,
+// then nodes carrying more of the topic's tags, then the newest.
+func topicExamplesFor(t *Topic, idx []int, nodes []*db.Node, nodeTags [][]string) []TopicExample {
+	inTopic := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		inTopic[tag] = true
+	}
+	overlap := func(i int) int {
+		n := 0
+		for _, tag := range nodeTags[i] {
+			if inTopic[tag] {
+				n++
+			}
+		}
+		return n
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		na, nb := nodes[idx[a]], nodes[idx[b]]
+		if sa, sb := na.Type == "summary", nb.Type == "summary"; sa != sb {
+			return sa
+		}
+		if oa, ob := overlap(idx[a]), overlap(idx[b]); oa != ob {
+			return oa > ob
+		}
+		return na.UpdatedAt.After(nb.UpdatedAt)
+	})
+
+	var out []TopicExample
+	for _, i := range idx {
+		if len(out) == topicExamples {
+			break
+		}
+		preview := strings.Join(strings.Fields(nodes[i].Content), " ")
+		if len(preview) > 120 {
+			preview = preview[:120] + "..."
+		}
+		out = append(out, TopicExample{ID: nodes[i].ID, Type: nodes[i].Type, Preview: preview})
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package stats_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/stats"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestTopics(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	// auth and login co-occur; sync stands alone.
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "tokens expire", Tags: []string{"auth", "login", "tier:reference"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "decision", Content: "use OIDC", Tags: []string{"auth", "login"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "sessions are cookies", Tags: []string{"auth"}})
+	sum, _ := d.CreateNode(db.CreateNodeInput{Type: "summary", Content: "auth overview", Tags: []string{"login"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "observation", Content: "sync lag", Tags: []string{"sync"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "no topic", Tags: []string{"tier:working"}})
+
+	report, err := stats.Topics(d, stats.TopicsOptions{AllAgents: true})
+	require.NoError(t, err)
+
+	require.Len(t, report.Topics, 2)
+	auth := report.Topics[0]
+	assert.Equal(t, []string{"auth", "login"}, auth.Tags)
+	assert.Equal(t, 4, auth.Nodes)
+	assert.Equal(t, 2, auth.ByType["fact"])
+	require.NotEmpty(t, auth.Examples)
+	assert.Equal(t, sum.ID, auth.Examples[0].ID, "summaries represent a topic first")
+	assert.LessOrEqual(t, len(auth.Examples), 3)
+
+	assert.Equal(t, []string{"sync"}, report.Topics[1].Tags)
+	assert.Equal(t, 1, report.Untagged)
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/zate/ctx/internal/config"
 	"github.com/zate/ctx/internal/db"
 )
 
@@ -98,14 +99,24 @@ func GetLocalChanges(store db.Store, sinceVersion int64) ([]NodeChange, int64, e
 		node.CreatedAt, _ = time.Parse(time.RFC3339, createdAt)
 		node.UpdatedAt, _ = time.Parse(time.RFC3339, updatedAt)
 
-		tags, _ := store.GetTags(node.ID)
-		node.Tags = tags
-
 		changes = append(changes, NodeChange{Node: node})
 		if syncVersion > maxVersion {
 			maxVersion = syncVersion
 		}
 	}
+	rows.Close()
+
+	ids := make([]string, len(changes))
+	for i, c := range changes {
+		ids[i] = c.Node.ID
+	}
+	tags, err := store.GetTagsForNodes(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, c := range changes {
+		c.Node.Tags = tags[c.Node.ID]
+	}
 
 	return changes, maxVersion, nil
 }
@@ -145,6 +156,7 @@ func ApplyRemoteChanges(store db.Store, changes []NodeChange) (applied int, conf
 		if existing.UpdatedAt.After(change.Node.UpdatedAt) {
 			// Local is newer — conflict (last-write-wins keeps local)
 			conflicts++
+			recordConflict(store, change.Node.ID)
 			continue
 		}
 
@@ -177,6 +189,59 @@ func ApplyRemoteChanges(store db.Store, changes []NodeChange) (applied int, conf
 	return applied, conflicts, nil
 }
 
+// conflictsKey is the pending key listing nodes whose remote edit a pull
+// skipped because the local copy was newer.
+const conflictsKey = "sync_conflicts"
+
+func recordConflict(store db.Store, id string) {
+	ids := PendingConflicts(store)
+	for _, existing := range ids {
+		if existing == id {
+			return
+		}
+	}
+	data, _ := json.Marshal(append(ids, id))
+	_ = store.SetPending(conflictsKey, string(data))
+}
+
+// PendingConflicts returns the IDs of nodes with an unresolved pull
+// conflict. They resolve once a push lands the local copy on the server.
+func PendingConflicts(store db.Store) []string {
+	raw, err := store.GetPending(conflictsKey)
+	if err != nil || raw == "" {
+		return nil
+	}
+	var ids []string
+	_ = json.Unmarshal([]byte(raw), &ids)
+	return ids
+}
+
+// ClearConflicts forgets pull conflicts, after a push without conflicts.
+func ClearConflicts(store db.Store) {
+	_ = store.DeletePending(conflictsKey)
+}
+
+// Divergence is local state not yet reconciled with a remote.
+type Divergence struct {
+	Unsynced  int // local node changes not yet pushed
+	Conflicts int // pulled edits skipped because the local copy was newer
+}
+
+// CheckDivergence reports how far the store has drifted from serverURL.
+// With no server configured only conflicts are counted.
+func CheckDivergence(store db.Store, serverURL string) Divergence {
+	div := Divergence{Conflicts: len(PendingConflicts(store))}
+	if serverURL == "" {
+		return div
+	}
+	state, err := LoadSyncState(serverURL)
+	if err != nil {
+		return div
+	}
+	_ = store.QueryRow("SELECT COUNT(*) FROM nodes WHERE sync_version > ?", state.LastPushVersion).Scan(&div.Unsynced)
+	return div
+}
+
 // LoadSyncState loads sync state from ~/.ctx/sync_state.json.
 func LoadSyncState(serverURL string) (*SyncState, error) {
 	path, err := syncStatePath()
@@ -268,10 +333,12 @@ func NormalizeGitURL(raw string) string {
 	return url
 }
 
+// syncStatePath returns sync_state.json in the active profile's state
+// directory, so each profile tracks its own sync position.
 func syncStatePath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+	dir := config.Load().StateDir()
+	if dir == "" {
+		return "", fmt.Errorf("cannot determine home directory")
 	}
-	return filepath.Join(home, ".ctx", "sync_state.json"), nil
+	return filepath.Join(dir, "sync_state.json"), nil
 }
@@ -1,6 +1,7 @@
 package sync
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
@@ -123,6 +124,13 @@ func TestApplyRemoteChanges_Conflict_LocalNewer(t *testing.T) {
 	got, err := store.GetNode(node.ID)
 	require.NoError(t, err)
 	assert.Equal(t, "Local version", got.Content)
+
+	// The conflict is remembered once until a clean push clears it
+	_, _, err = ApplyRemoteChanges(store, []NodeChange{{Node: remoteNode}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{node.ID}, PendingConflicts(store))
+	ClearConflicts(store)
+	assert.Empty(t, PendingConflicts(store))
 }
 
 func TestApplyRemoteChanges_Update_RemoteNewer(t *testing.T) {
@@ -223,3 +231,21 @@ func TestNormalizeGitURL(t *testing.T) {
 		})
 	}
 }
+
+func TestCheckDivergence(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("CTX_PROFILE", "")
+	store := testutil.SetupTestDB(t)
+
+	for i, v := range []int{1, 2, 3} {
+		node, err := store.CreateNode(db.CreateNodeInput{Type: "fact", Content: fmt.Sprintf("fact %d", i)})
+		require.NoError(t, err)
+		_, err = store.Exec("UPDATE nodes SET sync_version = ? WHERE id = ?", v, node.ID)
+		require.NoError(t, err)
+	}
+	require.NoError(t, SaveSyncState(&SyncState{ServerURL: "http://test:8377", LastPushVersion: 1}))
+
+	assert.Equal(t, Divergence{Unsynced: 2}, CheckDivergence(store, "http://test:8377"))
+	assert.Equal(t, Divergence{Unsynced: 3}, CheckDivergence(store, "http://other:8377"), "never pushed")
+	assert.Equal(t, Divergence{}, CheckDivergence(store, ""), "no remote")
+}
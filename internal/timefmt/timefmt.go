@@ -0,0 +1,126 @@
+// Package timefmt renders timestamps for people: in a configured time zone
+// and, when asked, relative to now ("3 days ago"). Stored and exported times
+// stay RFC3339 UTC; this is for CLI, web UI and document output only.
+package timefmt
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layouts used for absolute times.
+const (
+	Short = "2006-01-02 15:04"
+	Long  = "2006-01-02 15:04:05 MST"
+)
+
+// Clock formats times in Location, optionally as relative times.
+type Clock struct {
+	Location *time.Location
+	Relative bool
+	// Now returns the current time; time.Now when nil.
+	Now func() time.Time
+}
+
+// New returns a Clock for zone (see LoadLocation). An unknown zone falls
+// back to the local zone, so display settings never break a command.
+func New(zone string, relative bool) *Clock {
+	loc, err := LoadLocation(zone)
+	if err != nil {
+		loc = time.Local
+	}
+	return &Clock{Location: loc, Relative: relative}
+}
+
+// LoadLocation resolves a display zone: "" or "local" for the system zone,
+// "UTC", or an IANA name such as Europe/Berlin.
+func LoadLocation(zone string) (*time.Location, error) {
+	switch strings.ToLower(zone) {
+	case "", "local":
+		return time.Local, nil
+	case "utc":
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q (use local, UTC or a name such as Europe/Berlin)", zone)
+	}
+	return loc, nil
+}
+
+func (c *Clock) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+func (c *Clock) location() *time.Location {
+	if c.Location == nil {
+		return time.Local
+	}
+	return c.Location
+}
+
+// Format renders t for a table or list: "3 days ago" when Relative is set,
+// otherwise the Short layout in the clock's zone. The zero time renders as
+// "never".
+func (c *Clock) Format(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	if c.Relative {
+		return Ago(t, c.now())
+	}
+	return c.Stamp(t)
+}
+
+// Stamp renders t in the Short layout in the clock's zone.
+func (c *Clock) Stamp(t time.Time) string {
+	return t.In(c.location()).Format(Short)
+}
+
+// Detail renders t with seconds and zone followed by the relative time,
+// e.g. "2025-02-01 12:00:00 CET (3 days ago)", for single-item views.
+func (c *Clock) Detail(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%s)", t.In(c.location()).Format(Long), Ago(t, c.now()))
+}
+
+// Ago describes t relative to now, e.g. "just now", "5 minutes ago",
+// "3 days ago" or "in 2 hours", using the largest whole unit.
+func Ago(t, now time.Time) string {
+	d := now.Sub(t)
+	future := d < 0
+	if future {
+		d = -d
+	}
+	if d < time.Minute {
+		return "just now"
+	}
+
+	var n int
+	var unit string
+	switch {
+	case d < time.Hour:
+		n, unit = int(d/time.Minute), "minute"
+	case d < 24*time.Hour:
+		n, unit = int(d/time.Hour), "hour"
+	case d < 30*24*time.Hour:
+		n, unit = int(d/(24*time.Hour)), "day"
+	case d < 365*24*time.Hour:
+		n, unit = int(d/(30*24*time.Hour)), "month"
+	default:
+		n, unit = int(d/(365*24*time.Hour)), "year"
+	}
+	if n != 1 {
+		unit += "s"
+	}
+	if future {
+		return fmt.Sprintf("in %d %s", n, unit)
+	}
+	return fmt.Sprintf("%d %s ago", n, unit)
+}
@@ -0,0 +1,51 @@
+package timefmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/timefmt"
+)
+
+func TestAgo(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Equal(t, "just now", timefmt.Ago(now.Add(-30*time.Second), now))
+	assert.Equal(t, "1 minute ago", timefmt.Ago(now.Add(-time.Minute), now))
+	assert.Equal(t, "5 hours ago", timefmt.Ago(now.Add(-5*time.Hour), now))
+	assert.Equal(t, "3 days ago", timefmt.Ago(now.Add(-75*time.Hour), now))
+	assert.Equal(t, "2 months ago", timefmt.Ago(now.AddDate(0, 0, -65), now))
+	assert.Equal(t, "1 year ago", timefmt.Ago(now.AddDate(-1, 0, -1), now))
+	assert.Equal(t, "in 2 hours", timefmt.Ago(now.Add(2*time.Hour), now))
+}
+
+func TestClock(t *testing.T) {
+	loc, err := timefmt.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	c := &timefmt.Clock{Location: loc, Now: func() time.Time { return now }}
+
+	created := now.Add(-50 * time.Hour)
+	assert.Equal(t, "2025-05-30 19:00", c.Stamp(created))
+	assert.Equal(t, "2025-05-30 19:00", c.Format(created))
+	assert.Equal(t, "2025-05-30 19:00:00 JST (2 days ago)", c.Detail(created))
+	assert.Equal(t, "never", c.Format(time.Time{}))
+
+	c.Relative = true
+	assert.Equal(t, "2 days ago", c.Format(created))
+}
+
+func TestLoadLocation(t *testing.T) {
+	loc, err := timefmt.LoadLocation("utc")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	loc, err = timefmt.LoadLocation("")
+	require.NoError(t, err)
+	assert.Equal(t, time.Local, loc)
+
+	_, err = timefmt.LoadLocation("Mars/Olympus")
+	assert.Error(t, err)
+	assert.Equal(t, time.Local, timefmt.New("Mars/Olympus", false).Location)
+}
@@ -0,0 +1,198 @@
+// Package usage correlates the nodes injected into a session with the nodes
+// the session actually used, so never-used pinned knowledge can be found and
+// demoted.
+//
+// Per-session state lives in the pending table: "session_injected" holds
+// the IDs composed at session start, and "session_used" the IDs already
+// counted as used this session, so each node is counted at most once per
+// session.
+package usage
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+const (
+	injectedKey = "session_injected"
+	usedKey     = "session_used"
+
+	// shortIDLen is the prefix length ctx prints for abbreviated node IDs.
+	shortIDLen = 8
+)
+
+// idTokenRe matches candidate node IDs (Crockford base32, as used by ULIDs).
+var idTokenRe = regexp.MustCompile(`[0-9A-HJKMNP-TV-Z]{8,26}`)
+
+// StartSession records the nodes composed into a new session.
+func StartSession(d db.Store, injected []string) error {
+	data, _ := json.Marshal(injected)
+	if err := d.SetPending(injectedKey, string(data)); err != nil {
+		return err
+	}
+	_ = d.DeletePending(usedKey)
+	if len(injected) == 0 {
+		return nil
+	}
+	return d.RecordNodeUsage(db.UsageInjected, injected)
+}
+
+// RecordRecalls counts nodes returned by a recall as used.
+func RecordRecalls(d db.Store, ids []string) error {
+	fresh := markUsed(d, ids)
+	if len(fresh) == 0 {
+		return nil
+	}
+	return d.RecordNodeUsage(db.UsageRecalled, fresh)
+}
+
+// RecordAccess counts each of nodes as read once more, for accessed:
+// queries. Unlike the session counts above it counts every read: each
+// recall, compose and show of a node.
+func RecordAccess(d db.Store, nodes ...*db.Node) error {
+	ids := make([]string, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID
+	}
+	return d.RecordAccess(ids)
+}
+
+// RecordMissedRecall notes a recall query that found nothing, so queries
+// the agent keeps repeating without result show up in ctx top. Whitespace
+// is collapsed so trivially different spellings count together.
+func RecordMissedRecall(d db.Store, query string) error {
+	query = strings.Join(strings.Fields(query), " ")
+	if query == "" {
+		return nil
+	}
+	return d.RecordMissedRecall(query)
+}
+
+// RecordReferences scans assistant text for IDs of nodes injected this
+// session and counts any it finds as used.
+func RecordReferences(d db.Store, text string) error {
+	raw, err := d.GetPending(injectedKey)
+	if err != nil || raw == "" {
+		return nil
+	}
+	var injected []string
+	if json.Unmarshal([]byte(raw), &injected) != nil {
+		return nil
+	}
+
+	fresh := markUsed(d, FindReferences(text, injected))
+	if len(fresh) == 0 {
+		return nil
+	}
+	return d.RecordNodeUsage(db.UsageReferenced, fresh)
+}
+
+// FindReferences returns the IDs in ids that text mentions, either in full
+// or by an unambiguous prefix of at least eight characters.
+func FindReferences(text string, ids []string) []string {
+	byPrefix := make(map[string][]string, len(ids))
+	for _, id := range ids {
+		if len(id) >= shortIDLen {
+			byPrefix[id[:shortIDLen]] = append(byPrefix[id[:shortIDLen]], id)
+		}
+	}
+
+	found := make(map[string]bool)
+	for _, tok := range idTokenRe.FindAllString(strings.ToUpper(text), -1) {
+		var match string
+		matches := 0
+		for _, id := range byPrefix[tok[:shortIDLen]] {
+			if strings.HasPrefix(id, tok) {
+				match = id
+				matches++
+			}
+		}
+		// IDs created close together share a timestamp prefix; an
+		// ambiguous prefix is not counted as a reference to either.
+		if matches == 1 {
+			found[match] = true
+		}
+	}
+
+	out := make([]string, 0, len(found))
+	for id := range found {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// markUsed adds ids to this session's used set and returns those that were
+// not already in it.
+func markUsed(d db.Store, ids []string) []string {
+	used := make(map[string]bool)
+	if raw, err := d.GetPending(usedKey); err == nil && raw != "" {
+		var prev []string
+		_ = json.Unmarshal([]byte(raw), &prev)
+		for _, id := range prev {
+			used[id] = true
+		}
+	}
+
+	var fresh []string
+	for _, id := range ids {
+		if !used[id] {
+			used[id] = true
+			fresh = append(fresh, id)
+		}
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+
+	all := make([]string, 0, len(used))
+	for id := range used {
+		all = append(all, id)
+	}
+	sort.Strings(all)
+	data, _ := json.Marshal(all)
+	_ = d.SetPending(usedKey, string(data))
+	return fresh
+}
+
+// Entry pairs a node with its usage counters.
+type Entry struct {
+	Node  *db.Node      `json:"node"`
+	Usage *db.NodeUsage `json:"usage"`
+}
+
+// Report returns usage for every active node carrying tag (all active nodes
+// when tag is empty), least-used first. Nodes never injected have zero
+// counters.
+func Report(d db.Store, tag string) ([]Entry, error) {
+	nodes, err := d.ListNodes(db.ListOptions{Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+	stats, err := d.GetNodeUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(nodes))
+	for _, n := range nodes {
+		u := stats[n.ID]
+		if u == nil {
+			u = &db.NodeUsage{NodeID: n.ID}
+		}
+		entries = append(entries, Entry{Node: n, Usage: u})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		ui, uj := entries[i].Usage, entries[j].Usage
+		if a, b := ui.Referenced+ui.Recalled, uj.Referenced+uj.Recalled; a != b {
+			return a < b
+		}
+		return ui.Injected > uj.Injected
+	})
+	return entries, nil
+}
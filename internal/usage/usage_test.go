@@ -0,0 +1,94 @@
+package usage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/usage"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestFindReferences(t *testing.T) {
+	ids := []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "01BX5ZZKBKACTAV9WEVGEMMVRZ"}
+
+	text := "Per [fact:01ARZ3NDEKTSV4RRFFQ69G5FAV] and 01bx5zzk, plus 01CCCCCC."
+
+	assert.Equal(t, ids, usage.FindReferences(text, ids))
+	assert.Empty(t, usage.FindReferences("nothing here", ids))
+}
+
+func TestFindReferences_IgnoresAmbiguousPrefix(t *testing.T) {
+	ids := []string{"01ARZ3NDEKTSV4RRFFQ69G5FAV", "01ARZ3NDEKXXXXXXXXXXXXXXXX"}
+
+	assert.Empty(t, usage.FindReferences("see 01ARZ3ND", ids))
+	assert.Equal(t, ids[:1], usage.FindReferences("see 01ARZ3NDEKTS", ids))
+}
+
+func TestSessionUsage_CountsOncePerSession(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	used, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "used", Tags: []string{"tier:pinned"}})
+	unused, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "unused", Tags: []string{"tier:pinned"}})
+
+	require.NoError(t, usage.StartSession(d, []string{used.ID, unused.ID}))
+	require.NoError(t, usage.RecordReferences(d, "Following "+used.ID+" here."))
+	require.NoError(t, usage.RecordReferences(d, "Again "+used.ID))
+	require.NoError(t, usage.RecordRecalls(d, []string{used.ID}))
+
+	stats, err := d.GetNodeUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats[used.ID].Injected)
+	assert.Equal(t, 1, stats[used.ID].Referenced)
+	assert.Equal(t, 0, stats[used.ID].Recalled, "already counted as used this session")
+	assert.NotNil(t, stats[used.ID].LastUsedAt)
+	assert.False(t, stats[unused.ID].Used())
+
+	require.NoError(t, usage.StartSession(d, []string{used.ID, unused.ID}))
+	require.NoError(t, usage.RecordRecalls(d, []string{used.ID}))
+
+	stats, err = d.GetNodeUsage()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats[used.ID].Injected)
+	assert.Equal(t, 1, stats[used.ID].Recalled)
+}
+
+func TestReport_LeastUsedFirst(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	used, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "used", Tags: []string{"tier:pinned"}})
+	unused, _ := d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "unused", Tags: []string{"tier:pinned"}})
+	_, _ = d.CreateNode(db.CreateNodeInput{Type: "fact", Content: "reference", Tags: []string{"tier:reference"}})
+
+	require.NoError(t, usage.StartSession(d, []string{used.ID, unused.ID}))
+	require.NoError(t, usage.RecordRecalls(d, []string{used.ID}))
+
+	entries, err := usage.Report(d, "tier:pinned")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, unused.ID, entries[0].Node.ID)
+	assert.Equal(t, 1, entries[0].Usage.Injected)
+	assert.Equal(t, used.ID, entries[1].Node.ID)
+}
+
+func TestRecordMissedRecall(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	require.NoError(t, usage.RecordMissedRecall(d, "tag:deploy  type:decision"))
+	require.NoError(t, usage.RecordMissedRecall(d, " tag:deploy type:decision\n"))
+	require.NoError(t, usage.RecordMissedRecall(d, "auth flow"))
+	require.NoError(t, usage.RecordMissedRecall(d, "   "))
+
+	missed, err := d.ListMissedRecalls(10)
+	require.NoError(t, err)
+	require.Len(t, missed, 2)
+	assert.Equal(t, "tag:deploy type:decision", missed[0].Query)
+	assert.Equal(t, 2, missed[0].Misses)
+	assert.Equal(t, "auth flow", missed[1].Query)
+	assert.False(t, missed[1].LastMissedAt.IsZero())
+
+	missed, err = d.ListMissedRecalls(1)
+	require.NoError(t, err)
+	assert.Len(t, missed, 1)
+}
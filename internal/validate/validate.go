@@ -0,0 +1,260 @@
+// Package validate checks user input at the edges of ctx — MCP tool
+// arguments, hook commands and HTTP requests — so every entry point rejects
+// the same bad node types, IDs and tags with the same message, and the
+// message says what would have been accepted.
+package validate
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Limits on the tags of a single request.
+const (
+	MaxTagLength = 128
+	MaxTags      = 32
+)
+
+// NodeTypes are the built-in node types. A store also accepts the types
+// registered in its type registry (see Registry).
+var NodeTypes = []string{
+	"fact", "decision", "pattern", "observation", "hypothesis",
+	"task", "summary", "source", "open-question", "entity",
+}
+
+// EdgeTypes are the built-in edge types. A store also accepts the types
+// registered in its type registry (see Registry).
+var EdgeTypes = []string{
+	"DERIVED_FROM", "DEPENDS_ON", "SUPERSEDES", "RELATES_TO", "CHILD_OF", "MENTIONS",
+}
+
+// MaxTypeNameLength caps the name of a registered node or edge type.
+const MaxTypeNameLength = 32
+
+// Registry holds the custom node and edge types one store accepts besides
+// the built-in ones; each store keeps its own, so types registered in one
+// are not valid in another in the same process. A nil *Registry accepts
+// only the built-in types. It is safe for concurrent use.
+type Registry struct {
+	mu        sync.RWMutex
+	nodeTypes []string
+	edgeTypes []string
+}
+
+// NewRegistry returns a registry with no custom types.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// AddNodeTypes makes NodeType accept the given custom node types. The store
+// adds the types in its registry when it is opened and as they are
+// registered.
+func (r *Registry) AddNodeTypes(types ...string) {
+	r.add(&r.nodeTypes, types)
+}
+
+// AddEdgeTypes makes EdgeType accept the given custom edge types, as
+// AddNodeTypes does for node types.
+func (r *Registry) AddEdgeTypes(types ...string) {
+	r.add(&r.edgeTypes, types)
+}
+
+func (r *Registry) add(custom *[]string, types []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range types {
+		if !slices.Contains(*custom, t) {
+			*custom = append(*custom, t)
+		}
+	}
+}
+
+// KnownNodeTypes returns the built-in node types followed by the custom
+// ones added so far.
+func (r *Registry) KnownNodeTypes() []string {
+	if r == nil {
+		return slices.Clone(NodeTypes)
+	}
+	return r.known(NodeTypes, &r.nodeTypes)
+}
+
+// KnownEdgeTypes returns the built-in edge types followed by the custom
+// ones added so far.
+func (r *Registry) KnownEdgeTypes() []string {
+	if r == nil {
+		return slices.Clone(EdgeTypes)
+	}
+	return r.known(EdgeTypes, &r.edgeTypes)
+}
+
+func (r *Registry) known(builtin []string, custom *[]string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append(slices.Clone(builtin), *custom...)
+}
+
+// NodeType checks that t is a known node type, built in or custom.
+func (r *Registry) NodeType(t string) error {
+	return oneOf("type", t, r.KnownNodeTypes())
+}
+
+// EdgeType checks that t is a known edge type, built in or custom.
+func (r *Registry) EdgeType(t string) error {
+	return oneOf("edge type", t, r.KnownEdgeTypes())
+}
+
+// Tiers are the values a tier: tag may take.
+var Tiers = []string{"pinned", "reference", "working", "off-context"}
+
+// ReservedTagPrefixes start the tags ctx sets itself: review:pending on
+// held-back and summarized nodes, stale:true on nodes derived from replaced
+// knowledge, and rate-limited on the inbox of held-back commands. Hook
+// commands come from model output, so CommandTags keeps them from setting
+// these, as well as agent: tags naming another agent.
+var ReservedTagPrefixes = []string{"review:", "stale:", "rate-limited"}
+
+// tagPunct is the punctuation allowed in tags besides letters and digits.
+const tagPunct = ":-_./@#+="
+
+// crockford is the ULID alphabet: base32 without I, L, O and U.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// Error describes one rejected value.
+type Error struct {
+	Field  string // what was checked: "type", "tag", "ID", ...
+	Value  string
+	Reason string // what would have been accepted
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// NodeTypeName checks the name of a node type to register: lower-case
+// letters, digits and hyphens, starting with a letter, like open-question.
+func NodeTypeName(name string) error {
+	return typeName("type", name, 'a', 'z', '-')
+}
+
+// EdgeTypeName checks the name of an edge type to register: upper-case
+// letters, digits and underscores, starting with a letter, like DEPENDS_ON.
+func EdgeTypeName(name string) error {
+	return typeName("edge type", name, 'A', 'Z', '_')
+}
+
+func typeName(field, name string, lo, hi, sep rune) error {
+	switch {
+	case name == "":
+		return &Error{Field: field, Value: name, Reason: "must not be empty"}
+	case len(name) > MaxTypeNameLength:
+		return &Error{Field: field, Value: name, Reason: fmt.Sprintf("must be at most %d characters, got %d", MaxTypeNameLength, len(name))}
+	case rune(name[0]) < lo || rune(name[0]) > hi:
+		return &Error{Field: field, Value: name, Reason: fmt.Sprintf("must start with a letter %c-%c", lo, hi)}
+	}
+	for _, r := range name {
+		if (r < lo || r > hi) && (r < '0' || r > '9') && r != sep {
+			return &Error{Field: field, Value: name, Reason: fmt.Sprintf("contains %q; use %c-%c, 0-9 and %c", r, lo, hi, sep)}
+		}
+	}
+	return nil
+}
+
+func oneOf(field, v string, valid []string) error {
+	if slices.Contains(valid, v) {
+		return nil
+	}
+	return &Error{Field: field, Value: v, Reason: "must be one of " + strings.Join(valid, ", ")}
+}
+
+// ID checks that id is a complete ULID: 26 Crockford base32 characters,
+// starting with 0-7.
+func ID(id string) error {
+	if len(id) != 26 {
+		return &Error{Field: "ID", Value: id, Reason: fmt.Sprintf("must be 26 characters, got %d", len(id))}
+	}
+	return IDPrefix(id)
+}
+
+// IDPrefix checks that s could start a ULID, so it is worth looking up: 1
+// to 26 Crockford base32 characters (either case), starting with 0-7.
+func IDPrefix(s string) error {
+	switch {
+	case s == "":
+		return &Error{Field: "ID", Value: s, Reason: "must not be empty"}
+	case len(s) > 26:
+		return &Error{Field: "ID", Value: s, Reason: fmt.Sprintf("must be at most 26 characters, got %d", len(s))}
+	case s[0] < '0' || s[0] > '7':
+		return &Error{Field: "ID", Value: s, Reason: "must start with a digit 0-7"}
+	}
+	for _, r := range strings.ToUpper(s) {
+		if !strings.ContainsRune(crockford, r) {
+			return &Error{Field: "ID", Value: s, Reason: fmt.Sprintf("contains %q; IDs use 0-9 and A-Z without I, L, O and U", r)}
+		}
+	}
+	return nil
+}
+
+// Tag checks a single tag: non-empty, at most MaxTagLength characters of
+// letters, digits and :-_./@#+=, and, for tier: tags, a known tier.
+// Surrounding space is ignored, as the store trims tags.
+func Tag(tag string) error {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return &Error{Field: "tag", Value: tag, Reason: "must not be empty"}
+	}
+	if n := len([]rune(tag)); n > MaxTagLength {
+		return &Error{Field: "tag", Value: tag, Reason: fmt.Sprintf("must be at most %d characters, got %d", MaxTagLength, n)}
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(tagPunct, r) {
+			return &Error{Field: "tag", Value: tag, Reason: fmt.Sprintf("contains %q; tags use letters, digits and %s", r, tagPunct)}
+		}
+	}
+	if tier, ok := strings.CutPrefix(tag, "tier:"); ok {
+		if !slices.Contains(Tiers, tier) {
+			return &Error{Field: "tag", Value: tag, Reason: "tier must be one of " + strings.Join(Tiers, ", ")}
+		}
+	}
+	return nil
+}
+
+// Tags checks each tag and that there are at most MaxTags of them.
+func Tags(tags []string) error {
+	if len(tags) > MaxTags {
+		return &Error{Field: "tags", Value: strings.Join(tags, ","), Reason: fmt.Sprintf("at most %d tags per request, got %d", MaxTags, len(tags))}
+	}
+	for _, t := range tags {
+		if err := Tag(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommandTags checks tags written in a hook command: Tags, and that none
+// starts with one of ReservedTagPrefixes or is an agent: tag for an agent
+// other than agent, the session's own (so with no agent, none is allowed).
+func CommandTags(tags []string, agent string) error {
+	if err := Tags(tags); err != nil {
+		return err
+	}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		for _, p := range ReservedTagPrefixes {
+			if strings.HasPrefix(t, p) {
+				return &Error{Field: "tag", Value: t, Reason: "tags starting with " + p + " are set by ctx, not by commands"}
+			}
+		}
+		if name, ok := strings.CutPrefix(t, "agent:"); ok && name != agent {
+			reason := "commands may not tag memory for another agent"
+			if agent != "" {
+				reason += "; use agent:" + agent + " or leave it out"
+			}
+			return &Error{Field: "tag", Value: t, Reason: reason}
+		}
+	}
+	return nil
+}
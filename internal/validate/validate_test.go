@@ -0,0 +1,112 @@
+package validate_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zate/ctx/internal/validate"
+)
+
+func TestNodeType(t *testing.T) {
+	var r *validate.Registry
+	assert.NoError(t, r.NodeType("open-question"))
+	err := r.NodeType("facts")
+	assert.EqualError(t, err, `invalid type "facts": must be one of `+strings.Join(validate.NodeTypes, ", "))
+	var verr *validate.Error
+	assert.ErrorAs(t, err, &verr)
+	assert.Equal(t, "type", verr.Field)
+}
+
+func TestEdgeType(t *testing.T) {
+	var r *validate.Registry
+	assert.NoError(t, r.EdgeType("DERIVED_FROM"))
+	assert.ErrorContains(t, r.EdgeType("derived_from"), "must be one of DERIVED_FROM")
+}
+
+func TestRegistry(t *testing.T) {
+	r := validate.NewRegistry()
+	assert.Error(t, r.NodeType("runbook"))
+	r.AddNodeTypes("runbook", "runbook")
+	r.AddEdgeTypes("RUNS_ON")
+
+	assert.NoError(t, r.NodeType("runbook"))
+	assert.NoError(t, r.EdgeType("RUNS_ON"))
+	known := r.KnownNodeTypes()
+	assert.Equal(t, validate.NodeTypes, known[:len(validate.NodeTypes)], "built-in types come first")
+	assert.Equal(t, 1, strings.Count(strings.Join(known, " "), "runbook"))
+
+	other := validate.NewRegistry()
+	assert.Error(t, other.NodeType("runbook"), "registries do not share types")
+}
+
+func TestTypeNames(t *testing.T) {
+	assert.NoError(t, validate.NodeTypeName("post-mortem2"))
+	assert.NoError(t, validate.EdgeTypeName("CAUSED_BY"))
+	for in, want := range map[string]string{
+		"":                      "must not be empty",
+		"Incident":              "must start with a letter a-z",
+		"2fa":                   "must start with a letter a-z",
+		"post_mortem":           `contains '_'`,
+		"on call":               `contains ' '`,
+		strings.Repeat("a", 33): "at most 32 characters",
+	} {
+		assert.ErrorContains(t, validate.NodeTypeName(in), want, in)
+	}
+	assert.ErrorContains(t, validate.EdgeTypeName("caused_by"), "must start with a letter A-Z")
+	assert.ErrorContains(t, validate.EdgeTypeName("CAUSED-BY"), `contains '-'`)
+}
+
+func TestID(t *testing.T) {
+	assert.NoError(t, validate.ID("01HV3K2M8ZQ4X7N5P6R9S0T1VW"))
+	assert.ErrorContains(t, validate.ID("01HV3K2M"), "must be 26 characters, got 8")
+
+	assert.NoError(t, validate.IDPrefix("01HV3K2M"))
+	assert.NoError(t, validate.IDPrefix("01hv3k"), "prefixes may be lowercase")
+	for in, want := range map[string]string{
+		"":                            "must not be empty",
+		"9ABC":                        "must start with a digit 0-7",
+		"01HV3K2MI":                   `contains 'I'`,
+		"01HV-3K2M":                   `contains '-'`,
+		"01HV3K2M8ZQ4X7N5P6R9S0T1VWX": "at most 26 characters",
+	} {
+		assert.ErrorContains(t, validate.IDPrefix(in), want, in)
+	}
+}
+
+func TestTags(t *testing.T) {
+	assert.NoError(t, validate.Tags([]string{"tier:reference", "project:ctx", "area/db", "v1.2", "owner@team", " padded "}))
+
+	for in, want := range map[string]string{
+		"":           "must not be empty",
+		"tier:hot":   "tier must be one of pinned, reference, working, off-context",
+		"two words":  `contains ' '`,
+		"a,b":        `contains ','`,
+		"quote\"tag": `contains '"'`,
+	} {
+		assert.ErrorContains(t, validate.Tag(in), want, in)
+	}
+	assert.ErrorContains(t, validate.Tag(strings.Repeat("x", validate.MaxTagLength+1)), "must be at most 128 characters")
+
+	many := make([]string, validate.MaxTags+1)
+	for i := range many {
+		many[i] = "t"
+	}
+	assert.ErrorContains(t, validate.Tags(many), "at most 32 tags per request, got 33")
+}
+
+func TestCommandTags(t *testing.T) {
+	assert.NoError(t, validate.CommandTags([]string{"tier:working", "project:ctx", "agent:alice"}, "alice"))
+	assert.NoError(t, validate.CommandTags(nil, ""))
+
+	for in, want := range map[string]string{
+		"review:pending": "tags starting with review: are set by ctx",
+		"stale:true":     "tags starting with stale: are set by ctx",
+		"rate-limited":   "tags starting with rate-limited are set by ctx",
+		"agent:bob":      "another agent; use agent:alice or leave it out",
+		"line\nbreak":    `contains '\n'`,
+	} {
+		assert.ErrorContains(t, validate.CommandTags([]string{"project:ctx", in}, "alice"), want, in)
+	}
+	assert.ErrorContains(t, validate.CommandTags([]string{"agent:alice"}, ""), "may not tag memory for another agent")
+}
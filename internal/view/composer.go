@@ -1,14 +1,20 @@
 package view
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	agentpkg "github.com/zate/ctx/internal/agent"
 	"github.com/zate/ctx/internal/db"
+	hookpkg "github.com/zate/ctx/internal/hook"
+	"github.com/zate/ctx/internal/provenance"
 	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/timefmt"
 )
 
 type ComposeOptions struct {
@@ -21,6 +27,8 @@ type ComposeOptions struct {
 	Agent                 string   // If set, filter to agent-scoped + global nodes
 	IncludeReferenceStats bool     // If true, count available tier:reference nodes
 	IncludeEdges          bool     // If true, fetch and include edges between composed nodes
+	Translate             *Translation // If set, translate nodes in other languages
+	TaskPins              []string     // Nodes pinned to the current task: composed first, as working context
 }
 
 type ComposeResult struct {
@@ -33,17 +41,38 @@ type ComposeResult struct {
 	ReferenceCount    int            // Number of available tier:reference nodes
 	ReferenceByType   map[string]int // Breakdown by node type
 	Primer            string         // Custom primer text (replaces built-in if set)
+	StaleCount        int            // Composed nodes derived from outdated sources
+	Unsynced          int            // Local changes not yet pushed to the remote
+	SyncConflicts     int            // Pulled edits skipped because the local copy was newer
+	QueuedJobs        int            // Deferred hook work still waiting in the queue
+	Resurfaced        []*db.Node     // Old knowledge to re-confirm (see Resurface)
+	Translated        int            // Nodes whose content was translated (see Translation)
+	Untranslated      int            // Nodes left in their language because the LLM failed
+	Clock             *timefmt.Clock `json:"-"` // If set, document templates show when they were composed
+	Layout            Layout         `json:"-"` // Section order, headings and icons for RenderMarkdown
+	TaskPinned        map[string]bool `json:"-"` // IDs of composed nodes pinned to the current task
 }
 
 func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
+	return ComposeContext(context.Background(), d, opts)
+}
+
+// ComposeContext is Compose bounded by ctx. Once ctx is done the query is
+// interrupted and the error wraps ctx.Err(), so a pathological query can't
+// hold up a hook indefinitely.
+func ComposeContext(ctx context.Context, d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 	var nodes []*db.Node
 	var err error
 	explicitIDs := false // true when user explicitly requested specific nodes
+	d = d.WithContext(ctx)
 
 	if len(opts.IDs) > 0 {
 		explicitIDs = true
 		// Fetch specific nodes by ID (supports short prefixes)
 		for _, id := range opts.IDs {
+			if err := ctx.Err(); err != nil {
+				return nil, composeCancelled(err)
+			}
 			resolved, resolveErr := d.ResolveID(id)
 			if resolveErr != nil {
 				return nil, fmt.Errorf("failed to resolve node ID %q: %w", id, resolveErr)
@@ -66,6 +95,9 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 		}
 		collected := traverseGraph(d, resolved, depth)
 		for _, id := range collected {
+			if err := ctx.Err(); err != nil {
+				return nil, composeCancelled(err)
+			}
 			node, getErr := d.GetNode(id)
 			if getErr != nil {
 				continue
@@ -75,10 +107,13 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 		// Enable edges automatically for seed traversal
 		opts.IncludeEdges = true
 	} else if opts.Query != "" {
-		nodes, err = query.ExecuteQuery(d, opts.Query, false)
+		nodes, err = query.ExecuteQueryContext(ctx, d, opts.Query, false)
 	} else {
 		nodes, err = d.ListNodes(db.ListOptions{})
 	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, composeCancelled(ctxErr)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute query: %w", err)
 	}
@@ -98,19 +133,7 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 
 	// Skip project/agent filtering when user explicitly requested specific nodes
 	if !explicitIDs {
-		// Filter by project scope
-		// When project is empty, include global nodes AND project-scoped nodes
-		// (don't exclude project-scoped nodes just because no --project was given)
-		var filtered []*db.Node
-		for _, n := range nodes {
-			if shouldIncludeForProject(n, opts.Project) {
-				filtered = append(filtered, n)
-			}
-		}
-		nodes = filtered
-
-		// Filter by agent partition
-		nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+		nodes = filterScope(nodes, opts)
 	}
 
 	// Apply budget
@@ -123,13 +146,44 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 		return result, nil
 	}
 
+	// Nodes pinned to the task come first whatever the query and tier, but
+	// pass the same filters as the rest and count against the same budget
+	if !explicitIDs && len(opts.TaskPins) > 0 {
+		var pins []*db.Node
+		for _, id := range opts.TaskPins {
+			if node, err := d.GetNode(id); err == nil && node.SupersededBy == nil {
+				pins = append(pins, node)
+			}
+		}
+		result.TaskPinned = make(map[string]bool, len(pins))
+		for _, n := range filterScope(pins, opts) {
+			if result.TaskPinned[n.ID] || result.TotalTokens+n.TokenEstimate > opts.Budget {
+				continue
+			}
+			result.TaskPinned[n.ID] = true
+			result.Nodes = append(result.Nodes, n)
+			result.TotalTokens += n.TokenEstimate
+			result.NodeCount++
+			if provenance.IsStale(n) {
+				result.StaleCount++
+			}
+		}
+	}
+
 	for _, n := range nodes {
-		if result.TotalTokens+n.TokenEstimate > opts.Budget {
+		if result.TaskPinned[n.ID] || result.TotalTokens+n.TokenEstimate > opts.Budget {
 			continue
 		}
 		result.Nodes = append(result.Nodes, n)
 		result.TotalTokens += n.TokenEstimate
 		result.NodeCount++
+		if provenance.IsStale(n) {
+			result.StaleCount++
+		}
+	}
+
+	if opts.Translate != nil {
+		opts.Translate.translate(ctx, d, result)
 	}
 
 	// Fetch edges between composed nodes if requested
@@ -139,6 +193,9 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 			nodeSet[n.ID] = true
 		}
 		for _, n := range result.Nodes {
+			if err := ctx.Err(); err != nil {
+				return nil, composeCancelled(err)
+			}
 			edges, edgeErr := d.GetEdgesFrom(n.ID)
 			if edgeErr != nil {
 				continue
@@ -153,7 +210,7 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 
 	// Count available reference nodes if requested
 	if opts.IncludeReferenceStats {
-		refNodes, err := query.ExecuteQuery(d, "tag:tier:reference", false)
+		refNodes, err := query.ExecuteQueryContext(ctx, d, "tag:tier:reference", false)
 		if err == nil {
 			// Apply same project filtering (always filter)
 			var filteredRef []*db.Node
@@ -174,6 +231,28 @@ func Compose(d db.Store, opts ComposeOptions) (*ComposeResult, error) {
 	return result, nil
 }
 
+func composeCancelled(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("compose timed out: %w", err)
+	}
+	return fmt.Errorf("compose cancelled: %w", err)
+}
+
+// filterScope keeps the nodes compose loads for opts' project and agent.
+// When project is empty, global nodes AND project-scoped nodes are kept
+// (project-scoped nodes aren't excluded just because no --project was
+// given). Hook-created nodes still in the review inbox are left out until
+// they are accepted.
+func filterScope(nodes []*db.Node, opts ComposeOptions) []*db.Node {
+	var filtered []*db.Node
+	for _, n := range nodes {
+		if shouldIncludeForProject(n, opts.Project) && !slices.Contains(n.Tags, hookpkg.ReviewPendingTag) {
+			filtered = append(filtered, n)
+		}
+	}
+	return agentpkg.FilterNodes(filtered, opts.Agent)
+}
+
 // shouldIncludeForProject returns true if a node should be included given the current project.
 // A node is project-scoped if it has any tag matching "project:*" (excluding "project:global").
 // If project-scoped, it only loads if one of its project tags matches the current project.
@@ -218,6 +297,47 @@ func tierPriority(tags []string) int {
 	return 3
 }
 
+// edgeRefs renders each node's outgoing edges as reference lines, one per
+// edge type, e.g. "Derived from: 01HV3K2M, 01HV3K9P" (short IDs, which ctx
+// show accepts).
+func edgeRefs(edges []*db.Edge) map[string][]string {
+	type group struct {
+		edgeType string
+		ids      []string
+	}
+	groups := map[string][]*group{}
+	for _, e := range edges {
+		var g *group
+		for _, existing := range groups[e.FromID] {
+			if existing.edgeType == e.Type {
+				g = existing
+			}
+		}
+		if g == nil {
+			g = &group{edgeType: e.Type}
+			groups[e.FromID] = append(groups[e.FromID], g)
+		}
+		g.ids = append(g.ids, shortID(e.ToID))
+	}
+
+	refs := make(map[string][]string, len(groups))
+	for from, gs := range groups {
+		for _, g := range gs {
+			label := formatEdgeType(g.edgeType)
+			label = strings.ToUpper(label[:1]) + label[1:]
+			refs[from] = append(refs[from], fmt.Sprintf("%s: %s", label, strings.Join(g.ids, ", ")))
+		}
+	}
+	return refs
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
 func RenderMarkdown(result *ComposeResult) string {
 	var b strings.Builder
 
@@ -230,17 +350,37 @@ func RenderMarkdown(result *ComposeResult) string {
 	} else if result.LastSessionStores == 0 {
 		header += " | last session: no new knowledge stored"
 	}
+	if result.StaleCount > 0 {
+		header += fmt.Sprintf(" | %d stale (derived from superseded or deleted sources)", result.StaleCount)
+	}
+	if result.Unsynced > 0 {
+		header += fmt.Sprintf(" | %d local changes not pushed (ctx sync push)", result.Unsynced)
+	}
+	if result.SyncConflicts > 0 {
+		header += fmt.Sprintf(" | %d sync conflicts kept local copy (ctx sync status)", result.SyncConflicts)
+	}
+	if result.QueuedJobs > 0 {
+		header += fmt.Sprintf(" | %d queued jobs (ctx hook flush)", result.QueuedJobs)
+	}
+	if result.Translated > 0 {
+		header += fmt.Sprintf(" | %d translated", result.Translated)
+	}
+	if result.Untranslated > 0 {
+		header += fmt.Sprintf(" | %d not translated (llm.command failed)", result.Untranslated)
+	}
 	header += " -->\n\n"
 	b.WriteString(header)
 
-	// Usage primer — custom or built-in
-	if result.Primer != "" {
+	// Usage primer — custom or built-in, unless the view's layout drops it
+	switch {
+	case result.Layout.NoPrimer:
+	case result.Primer != "":
 		b.WriteString(result.Primer)
 		if !strings.HasSuffix(result.Primer, "\n") {
 			b.WriteString("\n")
 		}
 		b.WriteString("\n")
-	} else {
+	default:
 		b.WriteString("You have persistent memory via `ctx`. Use the `ctx` CLI (via Bash) to store and query knowledge.\n\n")
 		b.WriteString("**Store knowledge when:**\n")
 		b.WriteString("- You make or learn a **decision** -- `ctx add --type decision --tag tier:pinned \"...\"`\n")
@@ -283,6 +423,10 @@ func RenderMarkdown(result *ComposeResult) string {
 
 	for _, n := range result.Nodes {
 		tier := "other"
+		if result.TaskPinned[n.ID] {
+			groups["working"] = append(groups["working"], n)
+			continue
+		}
 		for _, t := range n.Tags {
 			switch t {
 			case "tier:pinned":
@@ -296,6 +440,8 @@ func RenderMarkdown(result *ComposeResult) string {
 		groups[tier] = append(groups[tier], n)
 	}
 
+	refs := edgeRefs(result.Edges)
+
 	renderGroup := func(title string, nodes []*db.Node) {
 		if len(nodes) == 0 {
 			return
@@ -317,47 +463,38 @@ func RenderMarkdown(result *ComposeResult) string {
 				fmt.Fprintf(&b, "### %s\n\n", titleCase(t))
 			}
 			for _, n := range byType[t] {
-				content := n.Content
-				if len(content) > 200 {
-					content = content[:200] + "..."
+				content := db.Preview(n.Content, 200)
+				b.WriteString("- ")
+				if icon := result.Layout.Icons[n.Type]; icon != "" {
+					b.WriteString(icon + " ")
 				}
-				fmt.Fprintf(&b, "- [%s:%s] %s\n", n.Type, n.ID, content)
+				fmt.Fprintf(&b, "[%s:%s] %s", n.Type, n.ID, content)
+				if provenance.IsStale(n) {
+					b.WriteString(" <!-- stale: source changed, review -->")
+				}
+				b.WriteString("\n")
 				if len(n.Tags) > 0 {
 					fmt.Fprintf(&b, "  - Tags: %s\n", strings.Join(n.Tags, ", "))
 				}
+				for _, ref := range refs[n.ID] {
+					fmt.Fprintf(&b, "  - %s\n", ref)
+				}
 			}
 			b.WriteString("\n")
 		}
 	}
 
-	renderGroup("Pinned", groups["pinned"])
-	renderGroup("Reference", groups["reference"])
-	renderGroup("Working Context", groups["working"])
-	renderGroup("Other", groups["other"])
-
-	// Render relationships between composed nodes
-	if len(result.Edges) > 0 {
-		// Build ID-to-short-label map
-		nodeLabels := make(map[string]string, len(result.Nodes))
-		for _, n := range result.Nodes {
-			label := n.Content
-			if len(label) > 40 {
-				label = label[:40] + "..."
-			}
-			nodeLabels[n.ID] = fmt.Sprintf("[%s:%s]", n.Type, n.ID)
-		}
+	for _, tier := range result.Layout.sections() {
+		renderGroup(result.Layout.heading(tier), groups[tier])
+	}
 
-		b.WriteString("## Relationships\n\n")
-		for _, e := range result.Edges {
-			fromLabel := nodeLabels[e.FromID]
-			toLabel := nodeLabels[e.ToID]
-			if fromLabel == "" {
-				fromLabel = e.FromID
-			}
-			if toLabel == "" {
-				toLabel = e.ToID
-			}
-			fmt.Fprintf(&b, "- %s —%s→ %s\n", fromLabel, e.Type, toLabel)
+	if len(result.Resurfaced) > 0 {
+		b.WriteString("## Still True?\n\n")
+		for _, n := range result.Resurfaced {
+			content := db.Preview(n.Content, 200)
+			days := int(time.Since(n.CreatedAt).Hours() / 24)
+			fmt.Fprintf(&b, "- [%s:%s] %s\n", n.Type, n.ID, content)
+			fmt.Fprintf(&b, "  - You stored this %d days ago — still true? Confirm with `<ctx:confirm id=\"%s\"/>` or replace it with `<ctx:supersede old=\"%s\">corrected text</ctx:supersede>`.\n", days, n.ID, n.ID)
 		}
 		b.WriteString("\n")
 	}
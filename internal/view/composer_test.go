@@ -1,11 +1,16 @@
 package view_test
 
 import (
+	"context"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/query"
+	"github.com/zate/ctx/internal/timefmt"
 	"github.com/zate/ctx/internal/view"
 	"github.com/zate/ctx/testutil"
 )
@@ -125,6 +130,23 @@ func nodeContents(nodes []*db.Node) []string {
 	return contents
 }
 
+func TestCompose_ExcludesNodesAwaitingReview(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	kept := createNode(t, d, "fact", "accepted fact", []string{"tier:pinned"})
+	pending := createNode(t, d, "fact", "unreviewed hook fact", []string{"tier:pinned", "review:pending"})
+
+	result, err := view.Compose(d, view.ComposeOptions{Query: "tag:tier:pinned", Budget: 50000})
+	require.NoError(t, err)
+	require.Len(t, result.Nodes, 1)
+	assert.Equal(t, kept.ID, result.Nodes[0].ID)
+
+	// Asking for the node by ID still shows it
+	result, err = view.Compose(d, view.ComposeOptions{IDs: []string{pending.ID}, Budget: 50000})
+	require.NoError(t, err)
+	assert.Len(t, result.Nodes, 1)
+}
+
 func TestCompose_DefaultQuery_ExcludesReference(t *testing.T) {
 	d := testutil.SetupTestDB(t)
 
@@ -209,6 +231,22 @@ func TestRenderMarkdown_ShowsReferenceAvailability(t *testing.T) {
 	assert.Contains(t, output, "2 facts")
 }
 
+func TestRenderMarkdown_HeaderNotices(t *testing.T) {
+	output := view.RenderMarkdown(&view.ComposeResult{LastSessionStores: -1})
+	assert.NotContains(t, output, "not pushed")
+
+	output = view.RenderMarkdown(&view.ComposeResult{
+		LastSessionStores: -1,
+		Unsynced:          4,
+		SyncConflicts:     1,
+		QueuedJobs:        2,
+	})
+	header, _, _ := strings.Cut(output, "\n")
+	assert.Contains(t, header, "4 local changes not pushed")
+	assert.Contains(t, header, "1 sync conflicts")
+	assert.Contains(t, header, "2 queued jobs")
+}
+
 func TestRenderMarkdown_HidesReferenceWhenZero(t *testing.T) {
 	result := &view.ComposeResult{
 		NodeCount:      1,
@@ -298,3 +336,104 @@ func TestCompose_ExplicitIDs_WithDifferentProject(t *testing.T) {
 
 	assert.Equal(t, 1, result.NodeCount, "explicit IDs should bypass project filtering")
 }
+
+func TestCompose_TaskPins(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	createNode(t, d, "fact", "pinned fact", []string{"tier:pinned"})
+	ref := createNode(t, d, "decision", "reference decision", []string{"tier:reference", "project:myapp"})
+	working := createNode(t, d, "observation", "working observation", []string{"tier:working"})
+
+	result, err := view.Compose(d, view.ComposeOptions{
+		Query:    "tag:tier:pinned OR tag:tier:working",
+		Budget:   ref.TokenEstimate + working.TokenEstimate,
+		Project:  "myapp",
+		TaskPins: []string{ref.ID, working.ID, "01HV3K2M0000000000000000ZZ"},
+	})
+	require.NoError(t, err)
+
+	// Pins are kept whatever the query and tier, and crowd out other nodes
+	// before the budget does
+	assert.Equal(t, []string{"reference decision", "working observation"}, nodeContents(result.Nodes))
+	assert.Equal(t, 2, result.NodeCount)
+
+	output := view.RenderMarkdown(result)
+	_, section, found := strings.Cut(output, "## Working Context")
+	require.True(t, found)
+	assert.Contains(t, section, "reference decision", "a pinned node renders as working context")
+	assert.NotContains(t, output, "## Reference")
+}
+
+func TestCompose_TaskPinsFilteredAndBudgeted(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	kept := createNode(t, d, "fact", "kept pin", []string{"tier:reference"})
+	other := createNode(t, d, "fact", "other project pin", []string{"project:other"})
+	pending := createNode(t, d, "fact", "pending pin", []string{"review:pending"})
+	old := createNode(t, d, "decision", "superseded pin", nil)
+	replacement := createNode(t, d, "decision", "replacement", nil)
+	_, err := d.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", replacement.ID, old.ID)
+	require.NoError(t, err)
+	big := createNode(t, d, "fact", strings.Repeat("too big for the budget ", 50), nil)
+
+	result, err := view.Compose(d, view.ComposeOptions{
+		Query:    "type:none",
+		Budget:   kept.TokenEstimate + 10,
+		Project:  "myapp",
+		TaskPins: []string{other.ID, pending.ID, old.ID, big.ID, kept.ID},
+	})
+	require.NoError(t, err)
+
+	// Pins go through the project, review and superseded filters, and one
+	// that doesn't fit the budget is left out rather than overrunning it
+	assert.Equal(t, []string{"kept pin"}, nodeContents(result.Nodes))
+	assert.Equal(t, map[string]bool{kept.ID: true}, result.TaskPinned)
+	assert.LessOrEqual(t, result.TotalTokens, kept.TokenEstimate+10)
+}
+
+func TestCompose_FlagsStaleNodes(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	createNode(t, d, "summary", "derived summary", []string{"tier:pinned", "stale:true"})
+	createNode(t, d, "fact", "fresh fact", []string{"tier:pinned"})
+
+	result, err := view.Compose(d, view.ComposeOptions{Query: "tag:tier:pinned", Budget: 50000})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.StaleCount)
+
+	output := view.RenderMarkdown(result)
+	assert.Contains(t, output, "| 1 stale (derived from superseded or deleted sources)")
+	assert.Contains(t, output, "derived summary <!-- stale: source changed, review -->")
+	assert.NotContains(t, output, "fresh fact <!--")
+}
+
+func TestComposeContext_Timeout(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+	createNode(t, d, "fact", "pinned fact", []string{"tier:pinned"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+
+	_, err := view.ComposeContext(ctx, d, view.ComposeOptions{Query: "tag:tier:pinned", Budget: 50000})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = query.ExecuteQueryContext(ctx, d, "tag:tier:pinned", false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = query.ExecuteQueryContext(ctx, d, "", false)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "listing every node stops too")
+
+	result, err := view.ComposeContext(context.Background(), d, view.ComposeOptions{Query: "tag:tier:pinned", Budget: 50000})
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.NodeCount)
+}
+
+func TestRenderTemplate_ComposedAt(t *testing.T) {
+	result := &view.ComposeResult{NodeCount: 0, RenderedAt: time.Date(2025, 3, 1, 9, 30, 0, 0, time.UTC)}
+	assert.Contains(t, view.RenderTemplate(result, "default"), "> 0 nodes, 0 tokens\n")
+
+	result.Clock = &timefmt.Clock{Location: time.UTC}
+	assert.Contains(t, view.RenderTemplate(result, "default"), "> 0 nodes, 0 tokens · composed 2025-03-01 09:30\n")
+	assert.Contains(t, view.RenderTemplate(result, "document"), "(0 tokens) · composed 2025-03-01 09:30_")
+}
@@ -0,0 +1,178 @@
+package view
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zate/ctx/internal/db"
+)
+
+// Tier sections rendered by RenderMarkdown, in their built-in order.
+var defaultSections = []string{"pinned", "reference", "working", "other"}
+
+var defaultHeadings = map[string]string{
+	"pinned":    "Pinned",
+	"reference": "Reference",
+	"working":   "Working Context",
+	"other":     "Other",
+}
+
+// DefaultIcons are the bullet icons used by `ctx view create --icons`.
+var DefaultIcons = map[string]string{
+	"fact":          "📌",
+	"decision":      "⚖️",
+	"pattern":       "🔁",
+	"observation":   "👀",
+	"hypothesis":    "🤔",
+	"task":          "✅",
+	"summary":       "📝",
+	"source":        "📚",
+	"open-question": "❓",
+	"entity":        "🏷️",
+}
+
+// Layout customizes how RenderMarkdown lays out a saved view. The zero
+// Layout is the built-in layout.
+type Layout struct {
+	// Sections lists the tier sections (pinned, reference, working, other)
+	// in render order. Tiers left out are not rendered, though their nodes
+	// still count toward the budget; narrow the view query to drop them.
+	Sections []string `json:"sections,omitempty"`
+	// Headings replaces section headings, keyed by tier.
+	Headings map[string]string `json:"headings,omitempty"`
+	// Icons prefixes each node's bullet with an icon, keyed by node type.
+	Icons map[string]string `json:"icons,omitempty"`
+	// NoPrimer leaves out the usage primer.
+	NoPrimer bool `json:"no_primer,omitempty"`
+}
+
+// Validate reports unknown tiers in Sections and Headings.
+func (l Layout) Validate() error {
+	seen := map[string]bool{}
+	for _, s := range l.Sections {
+		if _, ok := defaultHeadings[s]; !ok {
+			return fmt.Errorf("unknown section %q (use %s)", s, strings.Join(defaultSections, ", "))
+		}
+		if seen[s] {
+			return fmt.Errorf("section %q listed twice", s)
+		}
+		seen[s] = true
+	}
+	for tier := range l.Headings {
+		if _, ok := defaultHeadings[tier]; !ok {
+			return fmt.Errorf("unknown section %q in headings (use %s)", tier, strings.Join(defaultSections, ", "))
+		}
+	}
+	return nil
+}
+
+// String summarizes the customizations in l, e.g.
+// "sections working,pinned; icons; no primer", or "" for the built-in layout.
+func (l Layout) String() string {
+	var parts []string
+	if len(l.Sections) > 0 {
+		parts = append(parts, "sections "+strings.Join(l.Sections, ","))
+	}
+	if len(l.Headings) > 0 {
+		parts = append(parts, "custom headings")
+	}
+	if len(l.Icons) > 0 {
+		parts = append(parts, "icons")
+	}
+	if l.NoPrimer {
+		parts = append(parts, "no primer")
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (l Layout) sections() []string {
+	if len(l.Sections) == 0 {
+		return defaultSections
+	}
+	return l.Sections
+}
+
+func (l Layout) heading(tier string) string {
+	if h := l.Headings[tier]; h != "" {
+		return h
+	}
+	return defaultHeadings[tier]
+}
+
+// Saved is a named view stored in the views table.
+type Saved struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Budget int    `json:"budget"`
+	Layout Layout `json:"layout"`
+}
+
+// ErrNoView is returned by Get when no view has the name.
+var ErrNoView = errors.New("view not found")
+
+// Get loads the saved view called name.
+func Get(d db.Store, name string) (*Saved, error) {
+	v := &Saved{Name: name}
+	var layout string
+	err := d.QueryRow("SELECT query, budget, layout FROM views WHERE name = ?", name).Scan(&v.Query, &v.Budget, &layout)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNoView, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load view %s: %w", name, err)
+	}
+	v.Layout = parseLayout(layout)
+	return v, nil
+}
+
+// List returns every saved view, by name.
+func List(d db.Store) ([]*Saved, error) {
+	rows, err := d.Query("SELECT name, query, budget, layout FROM views ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+	defer rows.Close()
+	var views []*Saved
+	for rows.Next() {
+		v := &Saved{}
+		var layout string
+		if err := rows.Scan(&v.Name, &v.Query, &v.Budget, &layout); err != nil {
+			return nil, fmt.Errorf("failed to scan view: %w", err)
+		}
+		v.Layout = parseLayout(layout)
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
+
+// Save creates v, or replaces the view of the same name.
+func Save(d db.Store, v *Saved) error {
+	if err := v.Layout.Validate(); err != nil {
+		return err
+	}
+	layout, err := json.Marshal(v.Layout)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err = d.Exec(`INSERT INTO views (name, query, budget, layout, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET query = excluded.query, budget = excluded.budget,
+		layout = excluded.layout, updated_at = excluded.updated_at`,
+		v.Name, v.Query, v.Budget, string(layout), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to save view: %w", err)
+	}
+	return nil
+}
+
+// parseLayout decodes a stored layout; unreadable layouts render as the
+// built-in one.
+func parseLayout(s string) Layout {
+	var l Layout
+	_ = json.Unmarshal([]byte(s), &l)
+	return l
+}
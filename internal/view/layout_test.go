@@ -0,0 +1,104 @@
+package view_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/view"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestSavedViewRoundTrip(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	layout := view.Layout{
+		Sections: []string{"working", "pinned"},
+		Headings: map[string]string{"working": "Current Task"},
+		Icons:    map[string]string{"decision": "⚖️"},
+		NoPrimer: true,
+	}
+	require.NoError(t, view.Save(d, &view.Saved{Name: "focus", Query: "tag:tier:working", Budget: 3000, Layout: layout}))
+
+	got, err := view.Get(d, "focus")
+	require.NoError(t, err)
+	assert.Equal(t, "tag:tier:working", got.Query)
+	assert.Equal(t, 3000, got.Budget)
+	assert.Equal(t, layout, got.Layout)
+	assert.Equal(t, "sections working,pinned; custom headings; icons; no primer", got.Layout.String())
+
+	require.NoError(t, view.Save(d, &view.Saved{Name: "focus", Query: "type:task", Budget: 100}))
+	got, err = view.Get(d, "focus")
+	require.NoError(t, err)
+	assert.Equal(t, "type:task", got.Query)
+	assert.Empty(t, got.Layout.String())
+
+	def, err := view.Get(d, "default")
+	require.NoError(t, err)
+	assert.Equal(t, view.Layout{}, def.Layout)
+
+	_, err = view.Get(d, "missing")
+	assert.ErrorIs(t, err, view.ErrNoView)
+
+	views, err := view.List(d)
+	require.NoError(t, err)
+	require.Len(t, views, 2)
+	assert.Equal(t, "default", views[0].Name)
+
+	err = view.Save(d, &view.Saved{Name: "bad", Query: "type:fact", Layout: view.Layout{Sections: []string{"archive"}}})
+	assert.ErrorContains(t, err, `unknown section "archive"`)
+}
+
+func TestRenderMarkdown_Layout(t *testing.T) {
+	result := &view.ComposeResult{
+		LastSessionStores: -1,
+		Nodes: []*db.Node{
+			{ID: "P1", Type: "decision", Content: "pinned decision", Tags: []string{"tier:pinned"}},
+			{ID: "W1", Type: "task", Content: "working task", Tags: []string{"tier:working"}},
+			{ID: "O1", Type: "fact", Content: "untiered fact"},
+		},
+		Layout: view.Layout{
+			Sections: []string{"working", "pinned"},
+			Headings: map[string]string{"working": "Current Task"},
+			Icons:    map[string]string{"decision": "⚖️"},
+			NoPrimer: true,
+		},
+	}
+
+	output := view.RenderMarkdown(result)
+	assert.NotContains(t, output, "You have persistent memory")
+	assert.Contains(t, output, "## Current Task\n\n- [task:W1] working task")
+	assert.Contains(t, output, "- ⚖️ [decision:P1] pinned decision")
+	assert.Less(t, strings.Index(output, "## Current Task"), strings.Index(output, "## Pinned"))
+	assert.NotContains(t, output, "untiered fact")
+
+	result.Layout = view.Layout{}
+	output = view.RenderMarkdown(result)
+	assert.Contains(t, output, "You have persistent memory")
+	assert.Less(t, strings.Index(output, "## Pinned"), strings.Index(output, "## Working Context"))
+	assert.Contains(t, output, "## Other")
+}
+
+func TestRenderMarkdown_EdgeReferences(t *testing.T) {
+	result := &view.ComposeResult{
+		LastSessionStores: -1,
+		Nodes: []*db.Node{
+			{ID: "01HV3K2MAAAA", Type: "decision", Content: "use postgres"},
+			{ID: "01HV3K9PBBBB", Type: "fact", Content: "traffic doubled"},
+			{ID: "01HV3KCCCCCC", Type: "fact", Content: "ops team is small"},
+		},
+		Edges: []*db.Edge{
+			{FromID: "01HV3K2MAAAA", ToID: "01HV3K9PBBBB", Type: "DERIVED_FROM"},
+			{FromID: "01HV3K2MAAAA", ToID: "01HV3KCCCCCC", Type: "DEPENDS_ON"},
+			{FromID: "01HV3K2MAAAA", ToID: "01HV3KCCCCCC", Type: "DERIVED_FROM"},
+		},
+		Layout: view.Layout{NoPrimer: true},
+	}
+
+	output := view.RenderMarkdown(result)
+	assert.Contains(t, output, "- [decision:01HV3K2MAAAA] use postgres\n  - Derived from: 01HV3K9P, 01HV3KCC\n  - Depends on: 01HV3KCC\n")
+	assert.Contains(t, output, "- [fact:01HV3K9PBBBB] traffic doubled\n- [fact")
+	assert.NotContains(t, output, "## Relationships")
+}
@@ -0,0 +1,99 @@
+package view
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	agentpkg "github.com/zate/ctx/internal/agent"
+	"github.com/zate/ctx/internal/db"
+)
+
+// resurfacedKey is the pending key mapping node IDs to when they were last
+// resurfaced, so an unanswered question isn't repeated every session.
+const resurfacedKey = "resurfaced"
+
+// ResurfaceOptions controls Resurface.
+type ResurfaceOptions struct {
+	After   time.Duration // minimum time since a node was last touched
+	Max     int           // nodes to return
+	Project string
+	Agent   string
+	Now     time.Time // zero means time.Now()
+}
+
+// Resurface picks up to opts.Max pinned or reference nodes nobody has
+// touched for opts.After, oldest first, and records that they were shown.
+// A node counts as touched when it is updated (confirming it does that),
+// referenced or recalled; once resurfaced it waits another opts.After
+// before it is asked about again.
+func Resurface(d db.Store, opts ResurfaceOptions) ([]*db.Node, error) {
+	if opts.After <= 0 || opts.Max <= 0 {
+		return nil, nil
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	nodes, err := d.ListNodes(db.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	nodes = agentpkg.FilterNodes(nodes, opts.Agent)
+	usage, err := d.GetNodeUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	shown := map[string]time.Time{}
+	if raw, err := d.GetPending(resurfacedKey); err == nil && raw != "" {
+		_ = json.Unmarshal([]byte(raw), &shown)
+	}
+	for id, at := range shown {
+		if now.Sub(at) >= opts.After {
+			delete(shown, id)
+		}
+	}
+
+	type candidate struct {
+		node    *db.Node
+		touched time.Time
+	}
+	var candidates []candidate
+	for _, n := range nodes {
+		if tierPriority(n.Tags) > 1 || !shouldIncludeForProject(n, opts.Project) {
+			continue
+		}
+		if _, ok := shown[n.ID]; ok {
+			continue
+		}
+		touched := n.UpdatedAt
+		if u := usage[n.ID]; u != nil && u.LastUsedAt != nil && u.LastUsedAt.After(touched) {
+			touched = *u.LastUsedAt
+		}
+		if now.Sub(touched) < opts.After {
+			continue
+		}
+		candidates = append(candidates, candidate{n, touched})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].touched.Before(candidates[j].touched)
+	})
+	if len(candidates) > opts.Max {
+		candidates = candidates[:opts.Max]
+	}
+
+	picked := make([]*db.Node, len(candidates))
+	for i, c := range candidates {
+		picked[i] = c.node
+		shown[c.node.ID] = now
+	}
+	if len(picked) > 0 {
+		data, _ := json.Marshal(shown)
+		if err := d.SetPending(resurfacedKey, string(data)); err != nil {
+			return nil, err
+		}
+	}
+	return picked, nil
+}
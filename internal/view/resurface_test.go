@@ -0,0 +1,66 @@
+package view_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/view"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestResurface(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	ref := createNode(t, d, "decision", "use SQLite", []string{"tier:reference"})
+	used := createNode(t, d, "fact", "recalled lately", []string{"tier:reference"})
+	createNode(t, d, "observation", "working notes", []string{"tier:working"})
+	createNode(t, d, "fact", "other project", []string{"tier:pinned", "project:other"})
+	require.NoError(t, d.RecordNodeUsage(db.UsageRecalled, []string{used.ID}))
+
+	// Usage is stamped with the real clock, so pretend 90 days pass only
+	// for the node nobody touched.
+	later := time.Now().Add(90 * 24 * time.Hour)
+	opts := view.ResurfaceOptions{After: 60 * 24 * time.Hour, Max: 5, Project: "app", Now: later}
+	_, err := d.Exec("UPDATE node_usage SET last_used_at = ? WHERE node_id = ?", later.UTC().Format(time.RFC3339), used.ID)
+	require.NoError(t, err)
+
+	nodes, err := view.Resurface(d, opts)
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, ref.ID, nodes[0].ID)
+
+	// Once shown, a node waits another interval before it comes back.
+	nodes, err = view.Resurface(d, opts)
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+
+	opts.Now = later.Add(61 * 24 * time.Hour)
+	nodes, err = view.Resurface(d, opts)
+	require.NoError(t, err)
+	require.NotEmpty(t, nodes)
+	assert.Equal(t, ref.ID, nodes[0].ID)
+
+	// Disabled by default.
+	nodes, err = view.Resurface(d, view.ResurfaceOptions{Max: 1})
+	require.NoError(t, err)
+	assert.Empty(t, nodes)
+}
+
+func TestRenderMarkdown_Resurfaced(t *testing.T) {
+	node := &db.Node{ID: "01HQ1234", Type: "fact", Content: "the API is v2", CreatedAt: time.Now().Add(-91 * 24 * time.Hour)}
+	output := view.RenderMarkdown(&view.ComposeResult{LastSessionStores: -1, Resurfaced: []*db.Node{node}})
+	assert.Contains(t, output, "## Still True?")
+	assert.Contains(t, output, "You stored this 91 days ago")
+	assert.Contains(t, output, `<ctx:confirm id="01HQ1234"/>`)
+
+	// Long content is cut by characters, so composed context stays valid UTF-8
+	node.Content = strings.Repeat("é", 300)
+	output = view.RenderMarkdown(&view.ComposeResult{LastSessionStores: -1, Resurfaced: []*db.Node{node}})
+	assert.True(t, utf8.ValidString(output))
+	assert.Contains(t, output, strings.Repeat("é", 200)+"...\n")
+}
@@ -25,18 +25,13 @@ func renderDefaultTemplate(result *ComposeResult) string {
 	var b strings.Builder
 
 	fmt.Fprintf(&b, "# Composed Document\n\n")
-	fmt.Fprintf(&b, "> %d nodes, %d tokens\n\n", result.NodeCount, result.TotalTokens)
+	fmt.Fprintf(&b, "> %d nodes, %d tokens%s\n\n", result.NodeCount, result.TotalTokens, composedAt(result))
 
 	for i, n := range result.Nodes {
 		if i > 0 {
 			b.WriteString("---\n\n")
 		}
-		shortID := n.ID
-		if len(shortID) > 8 {
-			shortID = shortID[:8]
-		}
-
-		fmt.Fprintf(&b, "### %s `%s`\n\n", titleCase(n.Type), shortID)
+		fmt.Fprintf(&b, "### %s `%s`\n\n", titleCase(n.Type), shortID(n.ID))
 
 		if n.Summary != nil && *n.Summary != "" {
 			fmt.Fprintf(&b, "*%s*\n\n", *n.Summary)
@@ -73,7 +68,7 @@ func renderDocumentTemplate(result *ComposeResult) string {
 	var b strings.Builder
 
 	fmt.Fprintf(&b, "# Knowledge Document\n\n")
-	fmt.Fprintf(&b, "_Generated from %d nodes (%d tokens)_\n\n", result.NodeCount, result.TotalTokens)
+	fmt.Fprintf(&b, "_Generated from %d nodes (%d tokens)%s_\n\n", result.NodeCount, result.TotalTokens, composedAt(result))
 
 	// Group by type
 	byType := make(map[string][]*db.Node)
@@ -90,11 +85,7 @@ func renderDocumentTemplate(result *ComposeResult) string {
 		fmt.Fprintf(&b, "## %s (%d)\n\n", titleCase(t)+"s", len(nodes))
 
 		for _, n := range nodes {
-			shortID := n.ID
-			if len(shortID) > 8 {
-				shortID = shortID[:8]
-			}
-			fmt.Fprintf(&b, "### %s\n\n", shortID)
+			fmt.Fprintf(&b, "### %s\n\n", shortID(n.ID))
 			b.WriteString(n.Content)
 			b.WriteString("\n\n")
 		}
@@ -115,6 +106,15 @@ func renderDocumentTemplate(result *ComposeResult) string {
 	return b.String()
 }
 
+// composedAt returns " · composed <time>" in the result's clock zone, or
+// "" when the result has no clock.
+func composedAt(result *ComposeResult) string {
+	if result.Clock == nil || result.RenderedAt.IsZero() {
+		return ""
+	}
+	return " · composed " + result.Clock.Stamp(result.RenderedAt)
+}
+
 func buildNodeLabels(nodes []*db.Node) map[string]string {
 	labels := make(map[string]string, len(nodes))
 	for _, n := range nodes {
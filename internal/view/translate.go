@@ -0,0 +1,89 @@
+package view
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/db"
+	"github.com/zate/ctx/internal/llm"
+)
+
+// translationKeyPrefix keys translations in the pending table by target
+// language and content hash, so a node is sent to the LLM once per edit
+// rather than once per session.
+const translationKeyPrefix = "translation:"
+
+// Translation translates composed nodes written in another language into
+// Lang by running Command, the configured llm.command.
+type Translation struct {
+	Lang    string
+	Command string
+}
+
+// TranslationFor returns the translation cfg asks compose to apply, or nil
+// when lang.translate is off or lang.primary or llm.command is unset.
+func TranslationFor(cfg *config.Config) *Translation {
+	if !cfg.Lang.Translate || cfg.Lang.Primary == "" || cfg.LLM.Command == "" {
+		return nil
+	}
+	return &Translation{Lang: cfg.Lang.Primary, Command: cfg.LLM.Command}
+}
+
+// translate replaces the content of result's nodes whose detected language
+// isn't t.Lang with a translation. Nodes the LLM fails on keep their
+// content, so compose never fails because of translation.
+func (t *Translation) translate(ctx context.Context, d db.Store, result *ComposeResult) {
+	for i, n := range result.Nodes {
+		from := nodeLang(n)
+		if from == "" || sameLang(from, t.Lang) {
+			continue
+		}
+		sum := sha256.Sum256([]byte(n.Content))
+		key := translationKeyPrefix + t.Lang + ":" + hex.EncodeToString(sum[:16])
+		text, err := d.GetPending(key)
+		if err != nil {
+			text, err = llm.Run(ctx, t.Command, translatePrompt(n.Content, from, t.Lang))
+			if err != nil {
+				result.Untranslated++
+				continue
+			}
+			_ = d.SetPending(key, text)
+		}
+		translated := *n
+		translated.Content = text
+		result.Nodes[i] = &translated
+		result.Translated++
+	}
+}
+
+// nodeLang returns the language code recorded in n's metadata, if any.
+func nodeLang(n *db.Node) string {
+	var meta struct {
+		Lang string `json:"lang"`
+	}
+	if json.Unmarshal([]byte(n.Metadata), &meta) != nil {
+		return ""
+	}
+	return meta.Lang
+}
+
+// sameLang compares language codes by their primary subtag, so pt-BR and
+// pt match.
+func sameLang(a, b string) bool {
+	a, _, _ = strings.Cut(a, "-")
+	b, _, _ = strings.Cut(b, "-")
+	return strings.EqualFold(a, b)
+}
+
+func translatePrompt(content, from, to string) string {
+	return fmt.Sprintf(`Translate the following note from the language with code %q into the language with code %q.
+Keep code, identifiers, file paths, URLs and markdown formatting exactly as they are.
+Reply with the translation only.
+
+%s`, from, to, content)
+}
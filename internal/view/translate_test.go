@@ -0,0 +1,73 @@
+package view_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/config"
+	"github.com/zate/ctx/internal/view"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestCompose_TranslatesOtherLanguages(t *testing.T) {
+	d := testutil.SetupTestDB(t)
+
+	german := createNode(t, d, "fact", "Der Server wird nicht neu gestartet, wenn die Konfiguration sich ändert.", []string{"tier:pinned"})
+	createNode(t, d, "fact", "The server is not restarted when the config changes.", []string{"tier:pinned"})
+	createNode(t, d, "fact", "ctx-server-2", []string{"tier:pinned"})
+
+	compose := func(command string) *view.ComposeResult {
+		t.Helper()
+		result, err := view.Compose(d, view.ComposeOptions{
+			Query:     "tag:tier:pinned",
+			Budget:    50000,
+			Translate: &view.Translation{Lang: "en", Command: command},
+		})
+		require.NoError(t, err)
+		return result
+	}
+
+	result := compose("cat >/dev/null; echo 'The server is not restarted (translated).'")
+	assert.Equal(t, 1, result.Translated)
+	assert.Equal(t, 0, result.Untranslated)
+	contents := nodeContents(result.Nodes)
+	assert.Contains(t, contents, "The server is not restarted (translated).")
+	assert.NotContains(t, contents, german.Content)
+	assert.Contains(t, view.RenderMarkdown(result), "1 translated")
+
+	stored, err := d.GetNode(german.ID)
+	require.NoError(t, err)
+	assert.Equal(t, german.Content, stored.Content, "the stored node keeps its language")
+
+	// The translation is cached, so a failing command doesn't matter
+	result = compose("exit 1")
+	assert.Equal(t, 1, result.Translated)
+	assert.Contains(t, nodeContents(result.Nodes), "The server is not restarted (translated).")
+
+	// Without a cached translation, a failure keeps the original
+	result, err = view.Compose(d, view.ComposeOptions{
+		Query:     "tag:tier:pinned",
+		Budget:    50000,
+		Translate: &view.Translation{Lang: "fr", Command: "exit 1"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Translated)
+	assert.Equal(t, 2, result.Untranslated)
+	assert.Contains(t, nodeContents(result.Nodes), german.Content)
+	header, _, _ := strings.Cut(view.RenderMarkdown(result), "\n")
+	assert.Contains(t, header, "2 not translated")
+}
+
+func TestTranslationFor(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Lang = config.Lang{Primary: "en", Translate: true}
+	assert.Nil(t, view.TranslationFor(cfg), "needs llm.command")
+
+	cfg.LLM.Command = "claude -p"
+	assert.Equal(t, &view.Translation{Lang: "en", Command: "claude -p"}, view.TranslationFor(cfg))
+
+	cfg.Lang.Translate = false
+	assert.Nil(t, view.TranslationFor(cfg))
+}
@@ -0,0 +1,56 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// NodeOption sets an optional field of a node made by CreateNode.
+type NodeOption func(*CreateNodeInput)
+
+// WithTags tags the node, e.g. WithTags("tier:pinned", "project:ctx").
+func WithTags(tags ...string) NodeOption {
+	return func(in *CreateNodeInput) { in.Tags = append(in.Tags, tags...) }
+}
+
+// WithSummary sets the node's summary.
+func WithSummary(summary string) NodeOption {
+	return func(in *CreateNodeInput) { in.Summary = &summary }
+}
+
+// WithMetadata sets the node's metadata, a JSON object.
+func WithMetadata(metadata string) NodeOption {
+	return func(in *CreateNodeInput) { in.Metadata = metadata }
+}
+
+// CreateNode adds a node of the given type and content to s, failing the
+// test if it cannot.
+func CreateNode(t testing.TB, s Store, nodeType, content string, opts ...NodeOption) *Node {
+	t.Helper()
+	in := CreateNodeInput{Type: nodeType, Content: content}
+	for _, opt := range opts {
+		opt(&in)
+	}
+	n, err := s.CreateNode(in)
+	require.NoError(t, err)
+	return n
+}
+
+// CreateEdge links from to to with an edge of the given type, such as
+// DEPENDS_ON or RELATES_TO, failing the test if it cannot.
+func CreateEdge(t testing.TB, s Store, from, to *Node, edgeType string) *Edge {
+	t.Helper()
+	e, err := s.CreateEdge(from.ID, to.ID, edgeType)
+	require.NoError(t, err)
+	return e
+}
+
+// Supersede records that newer replaces old, as ctx supersede does: a
+// SUPERSEDES edge, and old marked so recall leaves it out.
+func Supersede(t testing.TB, s Store, old, newer *Node) {
+	t.Helper()
+	CreateEdge(t, s, newer, old, "SUPERSEDES")
+	_, err := s.Exec("UPDATE nodes SET superseded_by = ? WHERE id = ?", newer.ID, old.ID)
+	require.NoError(t, err)
+}
@@ -0,0 +1,59 @@
+package testutil
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/internal/db"
+)
+
+// These name the store and the types its methods take and return for
+// packages outside this module, which cannot import ctx's internal
+// packages themselves.
+type (
+	Store = db.Store
+	Node  = db.Node
+	Edge  = db.Edge
+
+	CreateNodeInput = db.CreateNodeInput
+	UpdateNodeInput = db.UpdateNodeInput
+	ListOptions     = db.ListOptions
+
+	Attachment   = db.Attachment
+	Device       = db.Device
+	Embedder     = db.Embedder
+	Embedding    = db.Embedding
+	HookRun      = db.HookRun
+	HookStat     = db.HookStat
+	MissedRecall = db.MissedRecall
+	NodeAccess   = db.NodeAccess
+	NodeUsage    = db.NodeUsage
+	RepoMapping  = db.RepoMapping
+	Revision     = db.Revision
+	ScoredNode   = db.ScoredNode
+	Stats        = db.Stats
+	ToolStat     = db.ToolStat
+	TypeDef      = db.TypeDef
+	Usage        = db.Usage
+	User         = db.User
+)
+
+var memoryStores atomic.Int64
+
+// NewMemoryStore returns an empty store held in memory and closed when the
+// test ends. It is the SQLite store ctx uses locally, with the same schema
+// and queries, so code under test behaves as it would against a real
+// ~/.ctx/store.db, but nothing touches the disk and each test gets a store
+// of its own.
+func NewMemoryStore(t testing.TB) Store {
+	t.Helper()
+	// A named, shared-cache database lets every connection in the pool
+	// see the same data; a plain :memory: would give each its own.
+	name := fmt.Sprintf("file:ctx-test-%d?mode=memory&cache=shared", memoryStores.Add(1))
+	store, err := db.Open(name)
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
@@ -1,3 +1,7 @@
+// Package testutil helps test code that works with a ctx store, both in
+// this module and in integrations built on it, such as plugins and
+// importers. NewMemoryStore gives each test its own store, and CreateNode
+// and CreateEdge build fixtures in it in a line each.
 package testutil
 
 import (
@@ -9,7 +13,7 @@ import (
 )
 
 // SetupTestDB creates a test database and returns it.
-func SetupTestDB(t *testing.T) db.Store {
+func SetupTestDB(t testing.TB) db.Store {
 	t.Helper()
 	path := filepath.Join(t.TempDir(), "test.db")
 	database, err := db.Open(path)
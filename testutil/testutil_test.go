@@ -0,0 +1,63 @@
+package testutil_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zate/ctx/testutil"
+)
+
+func TestNewMemoryStore_Isolated(t *testing.T) {
+	a := testutil.NewMemoryStore(t)
+	b := testutil.NewMemoryStore(t)
+	testutil.CreateNode(t, a, "fact", "Only in a")
+
+	nodes, err := a.ListNodes(testutil.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 1)
+	nodes, err = b.ListNodes(testutil.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, nodes, "each store starts empty")
+}
+
+func TestFixtures(t *testing.T) {
+	s := testutil.NewMemoryStore(t)
+	old := testutil.CreateNode(t, s, "decision", "Use MySQL")
+	newer := testutil.CreateNode(t, s, "decision", "Use Postgres",
+		testutil.WithTags("tier:pinned", "project:ctx"),
+		testutil.WithSummary("Postgres"),
+		testutil.WithMetadata(`{"source":"test"}`))
+	dep := testutil.CreateNode(t, s, "pattern", "Expand/contract migrations")
+	edge := testutil.CreateEdge(t, s, dep, newer, "DEPENDS_ON")
+	testutil.Supersede(t, s, old, newer)
+
+	got, err := s.GetNode(newer.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"tier:pinned", "project:ctx"}, got.Tags)
+	require.NotNil(t, got.Summary)
+	assert.Equal(t, "Postgres", *got.Summary)
+	assert.JSONEq(t, `{"source":"test"}`, got.Metadata)
+
+	assert.Equal(t, dep.ID, edge.FromID)
+	assert.Equal(t, newer.ID, edge.ToID)
+
+	nodes, err := s.ListNodes(testutil.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2, "the superseded node is left out")
+}
+
+func TestStoreInputTypes(t *testing.T) {
+	// Callers outside this module build store inputs from the aliases.
+	s := testutil.NewMemoryStore(t)
+	n, err := s.CreateNode(testutil.CreateNodeInput{Type: "fact", Content: "Draft"})
+	require.NoError(t, err)
+	content := "Final"
+	_, err = s.UpdateNode(n.ID, testutil.UpdateNodeInput{Content: &content})
+	require.NoError(t, err)
+
+	nodes, err := s.ListNodes(testutil.ListOptions{Type: "fact"})
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "Final", nodes[0].Content)
+}